@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"os"
 	"strconv"
@@ -8,17 +10,22 @@ import (
 
 	"ots-backend/internal/cleanup"
 	"ots-backend/internal/config"
+	"ots-backend/internal/crypto"
 	"ots-backend/internal/db"
+	"ots-backend/internal/logger"
+	"ots-backend/internal/notify"
+	"ots-backend/internal/policy"
+	"ots-backend/internal/startup"
+	"ots-backend/internal/store/sqlite"
+	"ots-backend/internal/tracing"
 )
 
 func main() {
-	cfg := config.Load()
-
-	database, err := db.New(cfg.DatabaseURL)
+	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		startup.Fail(startup.StageConfig, "config_unreadable", err)
 	}
-	defer database.Close()
+	logger.Setup(cfg)
 
 	intervalStr := os.Getenv("CLEANUP_INTERVAL")
 	interval := 300 // 5 minutes default
@@ -30,6 +37,73 @@ func main() {
 
 	log.Printf("Starting cleanup worker with interval %d seconds", interval)
 
-	worker := cleanup.NewWorker(database, time.Duration(interval)*time.Second)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.OTLPEndpoint, cfg.InstanceID)
+	if err != nil {
+		startup.Fail(startup.StageConfig, "invalid_otlp_endpoint", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// The server sweeps its own memory store; there is nothing to reach here
+	if cfg.StorageBackend == config.StorageMemory {
+		startup.Fail(startup.StageConfig, "memory_backend", errors.New("STORAGE_BACKEND=memory needs no cleanup worker; the server sweeps its own store"))
+	}
+
+	var worker *cleanup.Worker
+	if cfg.StorageBackend == config.StorageSQLite {
+		path, ok := sqlite.PathFromURL(cfg.DatabaseURL)
+		if !ok {
+			startup.Fail(startup.StageConfig, "sqlite_url_required", errors.New("STORAGE_BACKEND=sqlite requires DATABASE_URL=sqlite://<path>"))
+		}
+
+		secrets, err := sqlite.Open(path)
+		if errors.Is(err, sqlite.ErrMigrate) {
+			startup.Fail(startup.StageMigrate, "migration_failed", err)
+		} else if err != nil {
+			startup.Fail(startup.StageDBConnect, "sqlite_open_failed", err)
+		}
+		defer secrets.Close()
+
+		worker = cleanup.NewStoreWorker(secrets, time.Duration(interval)*time.Second)
+	} else {
+		database, err := db.NewWithOptions(cfg.DatabaseURL, db.PoolOptions{
+			MaxConns:         int32(cfg.DBMaxConns),
+			MinConns:         int32(cfg.DBMinConns),
+			StatementTimeout: cfg.DBStatementTimeout,
+			Trace:            tracing.Enabled(),
+		})
+		if err != nil {
+			startup.Fail(startup.StageDBConnect, "db_unreachable", err)
+		}
+		defer database.Close()
+
+		worker = cleanup.NewWorker(database, time.Duration(interval)*time.Second)
+	}
+
+	worker.SetInstanceID(cfg.InstanceID)
+	worker.SetAllowLockBreak(cfg.AllowLockBreak)
+	worker.SetReceiptRetention(cfg.ReceiptRetention)
+	worker.SetMaxTTL(policy.FromConfig(cfg).MaxTTL)
+	// Consume events can only be matched to receipts when the server records both
+	worker.SetConsumeAudit(cfg.ConsumeAuditSample, cfg.ConsumeAuditWindow, cfg.AuditLogEnabled)
+
+	// Rewraps data keys left under an older envelope key after a rotation
+	if len(cfg.EnvelopeKeys) > 0 {
+		envelopeKeys, err := crypto.ParseEnvelopeKeys(cfg.EnvelopeKeys)
+		if err != nil {
+			startup.Fail(startup.StageConfig, "invalid_envelope_keys", err)
+		}
+		worker.SetEnvelopeKeys(envelopeKeys)
+	}
+
+	// Expiry notices are sent from here, where secrets are found expired
+	notifier, err := notify.FromConfig(cfg)
+	if err != nil {
+		startup.Fail(startup.StageConfig, "invalid_notify_config", err)
+	}
+	if notifier != nil {
+		notifier.Start(context.Background())
+		defer notifier.Stop()
+		worker.SetNotifier(notifier)
+	}
 	worker.Start()
 }
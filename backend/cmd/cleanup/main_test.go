@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ots-backend/internal/testutil"
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv(testutil.RunMainEnv) != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestStartupFailures(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "ots.db"), 0o755); err != nil {
+		t.Fatalf("create directory: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		env      []string
+		stage    string
+		code     string
+		exitCode int
+	}{
+		{
+			name:     "unreadable config file",
+			env:      []string{"CONFIG_FILE=" + filepath.Join(dir, "missing.yaml")},
+			stage:    "config",
+			code:     "config_unreadable",
+			exitCode: 10,
+		},
+		{
+			name:     "memory backend",
+			env:      []string{"STORAGE_BACKEND=memory"},
+			stage:    "config",
+			code:     "memory_backend",
+			exitCode: 10,
+		},
+		{
+			name:     "database path is a directory",
+			env:      []string{"DATABASE_URL=sqlite://" + filepath.Join(dir, "ots.db")},
+			stage:    "db_connect",
+			code:     "sqlite_open_failed",
+			exitCode: 11,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exitCode, failure := testutil.RunMain(t, 30*time.Second, tt.env...)
+			if exitCode != tt.exitCode || failure.ExitCode != tt.exitCode {
+				t.Errorf("exit code = %d, reported %d, want %d", exitCode, failure.ExitCode, tt.exitCode)
+			}
+			if failure.Stage != tt.stage || failure.Code != tt.code {
+				t.Errorf("failure = %s/%s, want %s/%s", failure.Stage, failure.Code, tt.stage, tt.code)
+			}
+			if failure.Detail == "" {
+				t.Error("failure has no detail")
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+// Command otsadmin runs operator tasks against a running server.
+//
+//	otsadmin dossier <secret-id>   fetch a signed support dossier
+//	otsadmin verify <file>         verify a saved dossier with DOSSIER_KEYS
+//	otsadmin project <file>        project capacity from saved admin stats
+//
+// The server is OTS_URL (default http://localhost:8080) and requests are
+// authorised with ADMIN_TOKEN. project works offline on the output of
+// GET /api/admin/stats, taking the sweep interval, crypto shredding and
+// default TTL from the same configuration the server reads.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"ots-backend/internal/capacity"
+	"ots-backend/internal/config"
+	"ots-backend/internal/crypto"
+	"ots-backend/internal/dossier"
+	"ots-backend/internal/httpx"
+	"ots-backend/internal/store"
+)
+
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) != 3 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "dossier":
+		fetchDossier(os.Args[2])
+	case "verify":
+		verifyDossier(os.Args[2])
+	case "project":
+		projectCapacity(os.Args[2])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	log.Fatalf("usage: otsadmin dossier <secret-id> | otsadmin verify <file> | otsadmin project <file>")
+}
+
+// fetchDossier prints the signed dossier for id, indented for reading
+func fetchDossier(id string) {
+	token := os.Getenv("ADMIN_TOKEN")
+	if token == "" {
+		log.Fatalf("ADMIN_TOKEN is required")
+	}
+	baseURL := strings.TrimSuffix(os.Getenv("OTS_URL"), "/")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+
+	request, err := http.NewRequest(http.MethodGet, baseURL+"/api/admin/secrets/"+url.PathEscape(id)+"/dossier", nil)
+	if err != nil {
+		log.Fatalf("Invalid OTS_URL: %v", err)
+	}
+	httpx.SetHeader(request.Header, "Authorization", "Bearer "+token)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	response, err := client.Do(request)
+	if err != nil {
+		log.Fatalf("Request failed: %v", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		log.Fatalf("Failed to read response: %v", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		log.Fatalf("Server returned %s: %s", response.Status, bytes.TrimSpace(body))
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, bytes.TrimSpace(body), "", "  "); err != nil {
+		log.Fatalf("Server returned invalid JSON: %v", err)
+	}
+	indented.WriteByte('\n')
+	os.Stdout.Write(indented.Bytes())
+}
+
+// verifyDossier checks a saved dossier's signature against DOSSIER_KEYS
+func verifyDossier(path string) {
+	if os.Getenv("DOSSIER_KEYS") == "" {
+		log.Fatalf("DOSSIER_KEYS is required")
+	}
+	keys, err := crypto.ParseKeyring(strings.Split(os.Getenv("DOSSIER_KEYS"), ","))
+	if err != nil {
+		log.Fatalf("Invalid DOSSIER_KEYS: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Failed to read dossier: %v", err)
+	}
+	var signed dossier.Signed
+	if err := json.Unmarshal(data, &signed); err != nil {
+		log.Fatalf("Invalid dossier file: %v", err)
+	}
+
+	d, err := dossier.Verify(&signed, keys)
+	if err != nil {
+		log.Fatalf("Dossier does not verify: %v", err)
+	}
+	fmt.Printf("Signature valid: secret %s, generated %s, %d events\n", d.SecretIDHash, d.GeneratedAt.Format(time.RFC3339), len(d.Events))
+}
+
+// statsExport is the part of a saved /api/admin/stats response that
+// capacity projection reads
+type statsExport struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	Daily       []struct {
+		Date       string `json:"date"`
+		Created    int64  `json:"created"`
+		Retrieved  int64  `json:"retrieved"`
+		Burned     int64  `json:"burned"`
+		Bytes      int64  `json:"bytes"`
+		TTLSeconds int64  `json:"ttl_seconds"`
+	} `json:"daily"`
+	SecretSizes []struct {
+		UpperBound int64 `json:"upper_bound_bytes"`
+		Count      int64 `json:"count"`
+	} `json:"secret_sizes"`
+}
+
+// projectCapacity prints the steady state the day of traffic before a
+// saved stats export leads to, as GET /api/admin/capacity would have
+func projectCapacity(path string) {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Failed to read stats: %v", err)
+	}
+	var export statsExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		log.Fatalf("Invalid stats file: %v", err)
+	}
+	if export.GeneratedAt.IsZero() {
+		log.Fatalf("Stats file has no generated_at; export it from a server that records one")
+	}
+
+	days := make([]store.DailyStats, 0, len(export.Daily))
+	for _, entry := range export.Daily {
+		day, err := time.Parse(time.DateOnly, entry.Date)
+		if err != nil {
+			log.Fatalf("Invalid stats file: date %q: %v", entry.Date, err)
+		}
+		days = append(days, store.DailyStats{
+			Day:        day,
+			Created:    entry.Created,
+			Retrieved:  entry.Retrieved,
+			Burned:     entry.Burned,
+			Bytes:      entry.Bytes,
+			TTLSeconds: entry.TTLSeconds,
+		})
+	}
+	sizes := make([]store.SizeBucket, 0, len(export.SecretSizes))
+	for _, bucket := range export.SecretSizes {
+		sizes = append(sizes, store.SizeBucket{UpperBound: bucket.UpperBound, Count: bucket.Count})
+	}
+
+	settings := capacity.Settings{
+		SweepInterval:   cfg.CleanupInterval,
+		CryptoShredding: cfg.CryptoShredding,
+		DefaultTTL:      cfg.DefaultTTL,
+	}
+	obs := capacity.Observe(days, sizes, export.GeneratedAt, 24*time.Hour)
+	p := capacity.Project(obs, settings)
+
+	fmt.Printf("Observed %d creates over %s ending %s\n", obs.Created, obs.Window.Round(time.Minute), export.GeneratedAt.Format(time.RFC3339))
+	fmt.Printf("  %.1f creates/hour, %.0f%% read or burned, mean TTL %s, mean size %.0f bytes\n",
+		p.CreatesPerHour, p.ConsumedFraction*100, p.MeanTTL.Round(time.Second), p.MeanSizeBytes)
+	fmt.Printf("Steady state (sweep every %s, crypto shredding %t)\n", settings.SweepInterval, settings.CryptoShredding)
+	fmt.Printf("  rows:      %.0f (at most %.0f)\n", p.SteadyStateRows, p.SteadyStateRowsUpperBound)
+	fmt.Printf("  storage:   %.0f bytes (at most %.0f)\n", p.StorageBytes, p.StorageBytesUpperBound)
+	fmt.Printf("  deletions: %.1f per sweep, %.1f per hour\n", p.DeletionsPerSweep, p.DeletionsPerHour)
+	for _, caveat := range p.Caveats {
+		fmt.Printf("Caveat: %s\n", caveat)
+	}
+}
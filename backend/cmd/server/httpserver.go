@@ -0,0 +1,24 @@
+package main
+
+import (
+	"net/http"
+
+	"ots-backend/internal/config"
+)
+
+// newHTTPServer returns a server for handler on addr with the connection
+// limits from cfg. ReadHeaderTimeout is what stops a client that trickles
+// its headers from holding a connection open; the router's timeout only
+// starts once the headers are in. Per-request body limits are set by the
+// API's routes.
+func newHTTPServer(cfg *config.Config, addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		ReadTimeout:       cfg.HTTPReadTimeout,
+		WriteTimeout:      cfg.HTTPWriteTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+		MaxHeaderBytes:    cfg.HTTPMaxHeaderBytes,
+	}
+}
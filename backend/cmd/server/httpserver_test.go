@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"ots-backend/internal/config"
+)
+
+// TestSlowHeadersAreCutOff trickles a request's headers over a raw
+// connection and checks the server hangs up once HTTP_READ_HEADER_TIMEOUT
+// has passed, rather than waiting for the client to finish
+func TestSlowHeadersAreCutOff(t *testing.T) {
+	const headerTimeout = time.Second
+
+	cfg := &config.Config{
+		HTTPReadHeaderTimeout: headerTimeout,
+		HTTPReadTimeout:       time.Minute,
+		HTTPWriteTimeout:      time.Minute,
+		HTTPIdleTimeout:       time.Minute,
+		HTTPMaxHeaderBytes:    16 << 10,
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	server := newHTTPServer(cfg, listener.Addr().String(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler reached with headers that never finished")
+	}))
+	go server.Serve(listener)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	closed := make(chan time.Duration, 1)
+	go func() {
+		io.Copy(io.Discard, conn)
+		closed <- time.Since(start)
+	}()
+
+	// One header line every 200ms would take a minute to finish
+	write := time.NewTicker(200 * time.Millisecond)
+	defer write.Stop()
+	if _, err := io.WriteString(conn, "GET /api/health HTTP/1.1\r\nHost: localhost\r\n"); err != nil {
+		t.Fatalf("write request line: %v", err)
+	}
+	deadline := time.After(5 * headerTimeout)
+	for i := 0; ; i++ {
+		select {
+		case took := <-closed:
+			t.Logf("connection closed after %v", took)
+			if took < headerTimeout || took > 2*headerTimeout {
+				t.Errorf("connection closed after %v, want about %v", took, headerTimeout)
+			}
+			return
+		case <-write.C:
+			// Writes fail once the server hangs up; the read above reports it
+			conn.Write([]byte("X-Slow: " + string(rune('a'+i%26)) + "\r\n"))
+		case <-deadline:
+			t.Fatalf("connection still open after %v", 5*headerTimeout)
+		}
+	}
+}
@@ -1,68 +1,584 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/cors"
 
 	"ots-backend/internal/api"
+	"ots-backend/internal/cleanup"
+	"ots-backend/internal/clock"
 	"ots-backend/internal/config"
+	"ots-backend/internal/crypto"
 	"ots-backend/internal/db"
+	"ots-backend/internal/dropped"
+	"ots-backend/internal/events"
+	"ots-backend/internal/geo"
+	"ots-backend/internal/httpx"
+	"ots-backend/internal/logger"
 	httpMiddleware "ots-backend/internal/middleware"
+	"ots-backend/internal/netclass"
+	"ots-backend/internal/notify"
+	"ots-backend/internal/policy"
+	"ots-backend/internal/scan"
+	"ots-backend/internal/startup"
+	"ots-backend/internal/store"
+	"ots-backend/internal/store/memory"
+	"ots-backend/internal/store/postgres"
+	"ots-backend/internal/store/sqlite"
+	"ots-backend/internal/tracing"
+	"ots-backend/internal/validation"
+)
+
+// droppedWorkFlushInterval is how often counted losses of async work are
+// written to the dropped_work table
+const droppedWorkFlushInterval = time.Minute
+
+// Wall clock steps are looked for every clockStepCheckInterval and reported
+// from clockStepThreshold up
+const (
+	clockStepCheckInterval = 10 * time.Second
+	clockStepThreshold     = time.Second
 )
 
 func main() {
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		startup.Fail(startup.StageConfig, "config_unreadable", err)
+	}
+	logger.Setup(cfg)
+
+	if len(os.Args) > 1 {
+		runCommand(cfg, os.Args[1:])
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Config problems are reported as such before any store is opened
+	checkFeatures(ctx, cfg, nil)
+
+	// Tracing is on before the pool opens, so its statements are traced
+	shutdownTracing, err := tracing.Setup(ctx, cfg.OTLPEndpoint, cfg.InstanceID)
+	if err != nil {
+		startup.Fail(startup.StageConfig, "invalid_otlp_endpoint", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// Probes are answered before the store is opened: liveness passes and
+	// readiness reports each startup stage until the API is mounted
+	readiness := api.NewReadiness()
+	root := &handlerSwitch{}
+	root.Set(httpMiddleware.SecurityHeaders(api.StartupHandler(readiness)))
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	server := newHTTPServer(cfg, ":"+port, root)
+	mode, challenge, err := configureTLS(cfg, server)
+	if err != nil {
+		startup.Fail(startup.StageConfig, "invalid_tls", err)
+	}
+	servers := []*http.Server{server}
+
+	if cfg.HTTPRedirectPort != "" {
+		if server.TLSConfig == nil {
+			startup.Fail(startup.StageConfig, "redirect_without_tls", errors.New("HTTP_REDIRECT_PORT requires TLS_CERT_FILE/TLS_KEY_FILE or ACME_DOMAINS"))
+		}
+		redirect := redirectToHTTPS(port)
+		if challenge != nil {
+			redirect = challenge(redirect)
+		}
+		// Redirects carry the same security headers as every other response
+		servers = append(servers, newHTTPServer(cfg, ":"+cfg.HTTPRedirectPort, httpMiddleware.SecurityHeaders(redirect)))
+	} else if challenge != nil {
+		log.Printf("ACME_DOMAINS without HTTP_REDIRECT_PORT: certificates are issued over TLS-ALPN-01 only, which needs the server reachable on port 443")
+	}
+
+	// Ports are bound up front so a taken one fails startup rather than a
+	// serving goroutine
+	listeners := make([]net.Listener, len(servers))
+	for i, s := range servers {
+		listener, err := net.Listen("tcp", s.Addr)
+		if err != nil {
+			startup.Fail(startup.StageListen, "listen_failed", err)
+		}
+		listeners[i] = listener
+	}
+
+	log.Printf("Server starting on port %s (%s)", port, mode)
+	go func() {
+		if err := serve(server, listeners[0], cfg); err != nil {
+			log.Fatalf("Server failed: %v", err)
+		}
+	}()
+	if len(servers) > 1 {
+		log.Printf("Redirecting HTTP on port %s to HTTPS", cfg.HTTPRedirectPort)
+		go func() {
+			if err := serve(servers[1], listeners[1], cfg); err != nil {
+				log.Fatalf("HTTP redirect listener failed: %v", err)
+			}
+		}()
+	}
+
+	var secrets store.Store
+	switch cfg.StorageBackend {
+	case config.StorageMemory:
+		log.Printf("WARNING: STORAGE_BACKEND=memory keeps every secret in process memory.")
+		log.Printf("WARNING: all secrets, receipts and audit events are LOST on restart, and replicas do not share them.")
+		log.Printf("WARNING: use it for tests and demos only; run postgres or sqlite for anything real.")
+		secrets = memory.New()
+	case config.StorageSQLite:
+		// SQLite migrations are embedded and run on open
+		readiness.Advance(api.ReadinessMigrating)
+		path, _ := sqlite.PathFromURL(cfg.DatabaseURL)
+		sqliteStore, err := sqlite.Open(path)
+		if errors.Is(err, sqlite.ErrMigrate) {
+			startup.Fail(startup.StageMigrate, "migration_failed", err)
+		} else if err != nil {
+			startup.Fail(startup.StageDBConnect, "sqlite_open_failed", err)
+		}
+		secrets = sqliteStore
+	default:
+		database, err := db.NewWithOptions(cfg.DatabaseURL, db.PoolOptions{
+			MaxConns:         int32(cfg.DBMaxConns),
+			MinConns:         int32(cfg.DBMinConns),
+			StatementTimeout: cfg.DBStatementTimeout,
+			Trace:            tracing.Enabled(),
+		})
+		if err != nil {
+			startup.Fail(startup.StageDBConnect, "db_unreachable", err)
+		}
+
+		readiness.Advance(api.ReadinessMigrating)
+		startupMigrations(cfg, database)
+		secrets = postgres.New(database)
+	}
+	defer secrets.Close()
+
+	// A schema behind the binary is reported whole, before anything starts
+	if schema, ok := secrets.(store.SchemaInspector); ok {
+		checkFeatures(ctx, cfg, schema)
+	}
+
+	// Notices are delivered from their own queues, never on a request. They
+	// run past ctx so the shutdown drain can still send what is queued.
+	notifier, err := notify.FromConfig(cfg)
+	if err != nil {
+		startup.Fail(startup.StageConfig, "invalid_notify_config", err)
+	}
+	if notifier != nil {
+		notifier.Start(context.Background())
+	}
+
+	// Data keys are wrapped with the first envelope key and opened with any
+	var envelopeKeys *crypto.EnvelopeKeys
+	if len(cfg.EnvelopeKeys) > 0 {
+		envelopeKeys, err = crypto.ParseEnvelopeKeys(cfg.EnvelopeKeys)
+		if err != nil {
+			startup.Fail(startup.StageConfig, "invalid_envelope_keys", err)
+		}
+	}
+
+	// Endings in this process reach its event streams as they happen
+	hub := events.NewHub()
+
+	// No separate cleanup process can reach process memory, so the server
+	// sweeps its own store on the cleanup interval
+	if cfg.StorageBackend == config.StorageMemory {
+		sweeper := cleanup.NewStoreWorker(secrets, cfg.CleanupInterval)
+		sweeper.SetMaxTTL(policy.FromConfig(cfg).MaxTTL)
+		sweeper.SetConsumeAudit(cfg.ConsumeAuditSample, cfg.ConsumeAuditWindow, cfg.AuditLogEnabled)
+		sweeper.SetNotifier(notifier)
+		sweeper.SetEvents(hub)
+		sweeper.SetReceiptRetention(cfg.ReceiptRetention)
+		if envelopeKeys != nil {
+			sweeper.SetEnvelopeKeys(envelopeKeys)
+		}
+		go sweeper.Start()
+		defer sweeper.Stop()
+	}
+
+	trustedProxies, err := httpMiddleware.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		startup.Fail(startup.StageConfig, "invalid_trusted_proxies", err)
+	}
+	httpMiddleware.SetTrustedProxies(trustedProxies)
+
+	if !httpx.ValidHeaderValue(cfg.CSPPolicy) {
+		startup.Fail(startup.StageConfig, "invalid_csp_policy", errors.New("CSP_POLICY contains characters not allowed in a header value"))
+	}
+	httpMiddleware.SetSecurityPolicy(cfg.CSPPolicy, cfg.HSTSEnabled)
+
+	networkLabels, err := netclass.ParseRanges(cfg.NetworkLabels)
+	if err != nil {
+		startup.Fail(startup.StageConfig, "invalid_network_labels", err)
+	}
+
+	if err := loadScanRules(cfg); err != nil {
+		startup.Fail(startup.StageConfig, "invalid_scan_rules", err)
+	}
+	if cfg.ScanRulesFile != "" {
+		go reloadScanRulesOnHangup(ctx, cfg.ScanRulesFile)
+	}
+
+	apiHandler := api.NewHandler(secrets, cfg)
+	apiHandler.SetEvents(hub)
+	configs := config.NewManager(cfg)
+	apiHandler.SetConfigManager(configs)
+	go reloadConfigOnHangup(ctx, configs)
+
+	attestation := apiHandler.CryptoAttestation()
+	log.Printf("Crypto mode: %s %s", attestation.Mode, attestation.ModuleVersion)
+	for job, ok := range attestation.Available {
+		if !ok {
+			log.Printf("Crypto self-test failed for %s (%s); dependent capabilities are reported unavailable", job, attestation.Algorithms[job])
+		}
+	}
+
+	if len(cfg.NonceKeys) > 0 {
+		nonceKeys, err := crypto.ParseKeyring(cfg.NonceKeys)
+		if err != nil {
+			startup.Fail(startup.StageConfig, "invalid_nonce_keys", err)
+		}
+		apiHandler.SetNonceKeyring(nonceKeys)
+	} else if cfg.RequireCreateNonce {
+		log.Printf("REQUIRE_CREATE_NONCE is on without NONCE_KEYS; nonces are signed with a per-process key and are not accepted by other replicas or after a restart")
+	}
+
+	if len(cfg.DossierKeys) > 0 {
+		dossierKeys, err := crypto.ParseKeyring(cfg.DossierKeys)
+		if err != nil {
+			startup.Fail(startup.StageConfig, "invalid_dossier_keys", err)
+		}
+		apiHandler.SetDossierKeyring(dossierKeys)
+	} else if cfg.AdminToken != "" {
+		log.Printf("DOSSIER_KEYS is not set; support dossiers are signed with a per-process key and stop verifying after a restart")
+	}
+
+	if len(cfg.LinkKeys) > 0 {
+		linkKeys, err := crypto.ParseKeyring(cfg.LinkKeys)
+		if err != nil {
+			startup.Fail(startup.StageConfig, "invalid_link_keys", err)
+		}
+		apiHandler.SetLinkKeyring(linkKeys)
+	} else {
+		log.Printf("LINK_KEYS is not set; retrieval links are signed with a per-process key and are not accepted by other replicas or after a restart")
+	}
+
+	if cfg.ClientHashKey != "" {
+		clientHashKey, err := crypto.ParseKeyring([]string{cfg.ClientHashKey})
+		if err != nil {
+			startup.Fail(startup.StageConfig, "invalid_client_hash_key", err)
+		}
+		store.SetClientHashKey(clientHashKey)
+	} else {
+		if cfg.MaxActiveSecretsPerIP > 0 {
+			log.Printf("MAX_ACTIVE_SECRETS_PER_IP is on without CLIENT_HASH_KEY; creators are hashed with a per-process key, so other replicas and restarts do not count a client's earlier secrets")
+		}
+		if cfg.RateLimitAdaptive {
+			log.Printf("RATE_LIMIT_ADAPTIVE is on without CLIENT_HASH_KEY; clients are hashed with a per-process key, so reputations are not shared between replicas or kept over a restart")
+		}
+	}
+
+	if len(networkLabels) > 0 {
+		apiHandler.SetClassifier(netclass.New(networkLabels, ""))
+	}
 
-	database, err := db.New(cfg.DatabaseURL)
+	if notifier != nil {
+		apiHandler.SetNotifier(notifier)
+	}
+	if envelopeKeys != nil {
+		apiHandler.SetEnvelopeKeys(envelopeKeys)
+	}
+
+	if cfg.RegionCode != "" {
+		if err := validation.ValidateRegionCode(cfg.RegionCode); err != nil {
+			startup.Fail(startup.StageConfig, "invalid_region_code", err)
+		}
+		peers, err := api.ParseRegionPeers(cfg.RegionPeers)
+		if err != nil {
+			startup.Fail(startup.StageConfig, "invalid_region_peers", err)
+		}
+		apiHandler.SetRegion(cfg.RegionCode, peers)
+	}
+
+	// LISTEN/NOTIFY is a Postgres feature
+	if cfg.DBListenEnabled && cfg.StorageBackend == config.StoragePostgres {
+		listener := db.NewListener(cfg.DatabaseURL, db.EventsChannel)
+		listener.Start(ctx)
+		defer listener.Close()
+		apiHandler.SetListener(listener)
+	}
+
+	r, err := newRouter(cfg, apiHandler)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		startup.Fail(startup.StageConfig, "invalid_geo_countries", err)
 	}
-	defer database.Close()
 
-	if err := database.Migrate("./migrations"); err != nil {
-		log.Fatalf("Failed to run migrations: %v", err)
+	// Readiness reports 503 until connections and caches are primed
+	apiHandler.SetReadiness(readiness)
+	root.Set(r)
+	apiHandler.StartWarmUp(ctx, cfg.WarmupTimeout)
+
+	if cfg.CanaryInterval > 0 {
+		apiHandler.StartCanary(ctx)
+	}
+
+	// Losses of async work are written out on their own schedule so they
+	// reach the store even when the cleanup worker runs elsewhere
+	go dropped.Run(ctx, secrets, droppedWorkFlushInterval)
+
+	apiHandler.StartReputation(ctx)
+
+	go watchClockSteps(ctx)
+
+	<-ctx.Done()
+	log.Println("Shutting down server")
+
+	// Load balancers see readiness fail before the listeners close
+	readiness.Advance(api.ReadinessDraining)
+	if cfg.ShutdownDrainDelay > 0 {
+		log.Printf("Draining for %s before closing listeners", cfg.ShutdownDrainDelay)
+		time.Sleep(cfg.ShutdownDrainDelay)
+	}
+
+	// Both listeners share one deadline and drain in parallel
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, s := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.Shutdown(shutdownCtx); err != nil {
+				log.Printf("Graceful shutdown of %s failed: %v", s.Addr, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Reads served during the drain may have queued notices
+	if notifier != nil {
+		notifier.Stop()
+	}
+
+	// Audit writes cut short by the drain are counted by now
+	dropped.Drain(secrets)
+}
+
+// handlerSwitch serves through a handler that can be replaced while
+// serving, so the listeners can open before the API is mounted
+type handlerSwitch struct {
+	handler atomic.Pointer[http.Handler]
+}
+
+// Set replaces the handler for requests from now on
+func (s *handlerSwitch) Set(handler http.Handler) {
+	s.handler.Store(&handler)
+}
+
+func (s *handlerSwitch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*s.handler.Load()).ServeHTTP(w, r)
+}
+
+// requestTimeout bounds each request at d, but for event streams, which
+// stay open until their secret ends
+func requestTimeout(d time.Duration) func(http.Handler) http.Handler {
+	timeout := middleware.Timeout(d)
+	return func(next http.Handler) http.Handler {
+		bounded := timeout(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if api.IsEventStream(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			bounded.ServeHTTP(w, r)
+		})
+	}
+}
+
+// newRouter puts apiHandler behind the server's middleware chain and mounts
+// it with the debug endpoints and the /health alias. A bad GEO_ALLOW or
+// GEO_DENY code is an error.
+func newRouter(cfg *config.Config, apiHandler *api.Handler) (*chi.Mux, error) {
+	countries := geoIP(cfg)
+	geoBlock, err := geoBlocking(cfg, countries)
+	if err != nil {
+		return nil, err
+	}
+	if countries != nil {
+		apiHandler.SetLocator(countries)
 	}
 
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(tracing.Middleware)
+	r.Use(httpMiddleware.RealIP)
 	r.Use(httpMiddleware.SecurityHeaders)
 	r.Use(httpMiddleware.Logger)
 	r.Use(middleware.Recoverer)
+	r.Use(api.MetricsMiddleware)
 
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-Requested-With"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: false,
-		MaxAge:           300,
-	}))
+	r.Use(httpMiddleware.CORS(api.CORSOptions(cfg.CORSAllowedOrigins)))
 
-	r.Use(middleware.Timeout(30 * time.Second))
+	// After CORS, so a browser can read a refusal rather than seeing a
+	// network error
+	r.Use(geoBlock)
+
+	r.Use(requestTimeout(30 * time.Second))
 
-	apiHandler := api.NewHandler(database, cfg)
 	r.Mount("/api", apiHandler.Routes())
+	apiHandler.MountDebug(r)
 
-	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"ok"}`))
-	})
+	r.Get(api.HealthAliasRoot, apiHandler.HealthCheck)
+	return r, nil
+}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+// geoIP opens GEOIP_DATABASE once for both country blocking and the
+// reader's country on read receipts. It is nil without one, or with a file
+// that cannot be read, which is logged.
+func geoIP(cfg *config.Config) *geo.MMDB {
+	if cfg.GeoIPDatabase == "" {
+		return nil
+	}
+	db, err := geo.OpenMMDB(cfg.GeoIPDatabase)
+	if err != nil {
+		logger.Warn("GeoIP database unavailable; country blocking is off and read receipts carry no country", "error", err)
+		return nil
+	}
+	return db
+}
+
+// geoBlocking builds the country blocking middleware from GEO_ALLOW and
+// GEO_DENY over countries. A bad country code is an error; without
+// countries blocking is off.
+func geoBlocking(cfg *config.Config, countries *geo.MMDB) (func(http.Handler) http.Handler, error) {
+	allow, err := geo.ParseCountries(cfg.GeoAllow)
+	if err != nil {
+		return nil, fmt.Errorf("GEO_ALLOW: %w", err)
+	}
+	deny, err := geo.ParseCountries(cfg.GeoDeny)
+	if err != nil {
+		return nil, fmt.Errorf("GEO_DENY: %w", err)
+	}
+	policy := httpMiddleware.GeoPolicy{Allow: allow, Deny: deny}
+
+	var resolver geo.Resolver
+	switch {
+	case !policy.Active():
+	case cfg.GeoIPDatabase == "":
+		logger.Warn("GEO_ALLOW or GEO_DENY is set without GEOIP_DATABASE; country blocking is off")
+	case countries != nil:
+		resolver = countries
+		log.Printf("Country blocking on: allow %v, deny %v", allow, deny)
+	}
+	return httpMiddleware.GeoBlock(resolver, policy), nil
+}
+
+// loadScanRules installs the metadata scan rules from SCAN_RULES_FILE or,
+// without one, SCAN_RULES
+func loadScanRules(cfg *config.Config) error {
+	var rules []scan.Scanner
+	var err error
+	switch {
+	case cfg.ScanRulesFile != "":
+		rules, err = scan.LoadRulesFile(cfg.ScanRulesFile)
+	case cfg.ScanRules != "":
+		rules, err = scan.ParseRules([]byte(cfg.ScanRules))
+	}
+	if err != nil {
+		return err
+	}
+
+	scan.SetRules(rules)
+	if len(rules) > 0 {
+		log.Printf("Loaded %d metadata scan rules", len(rules))
+	}
+	return nil
+}
+
+// watchClockSteps reports wall clock steps, from NTP corrections or VM
+// migrations. Rate limit and lookup miss windows run on the monotonic clock
+// and ride steps out; secret expiries are wall clock times and move with
+// them.
+func watchClockSteps(ctx context.Context) {
+	detector := clock.NewStepDetector(clock.System, clockStepThreshold)
+	ticker := time.NewTicker(clockStepCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if step := detector.Check(); step != 0 {
+				log.Printf("WARNING: wall clock stepped by %v; expiries shift with it, rate limit windows do not", step.Round(time.Millisecond))
+			}
+		}
+	}
+}
+
+// reloadConfigOnHangup rereads the environment on SIGHUP. Limits, TTL bounds
+// and other per-request settings apply from the next request; storage
+// settings are kept and reported.
+func reloadConfigOnHangup(ctx context.Context, configs *config.Manager) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			ignored, err := configs.Reload()
+			if err != nil {
+				log.Printf("Failed to reload configuration, keeping the current one: %v", err)
+				continue
+			}
+			for _, name := range ignored {
+				log.Printf("WARNING: ignoring changed %s on reload; it only takes effect after a restart", name)
+			}
+			log.Printf("Reloaded configuration")
+		}
 	}
+}
+
+// reloadScanRulesOnHangup rereads the rules file on SIGHUP. A file that no
+// longer parses is reported and the running rules stay in force.
+func reloadScanRulesOnHangup(ctx context.Context, path string) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
 
-	log.Printf("Server starting on port %s", port)
-	if err := http.ListenAndServe(":"+port, r); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			n, err := scan.ReloadFile(path)
+			if err != nil {
+				log.Printf("Failed to reload scan rules, keeping the current ones: %v", err)
+				continue
+			}
+			log.Printf("Reloaded %d metadata scan rules from %s", n, path)
+		}
 	}
 }
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/testutil"
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv(testutil.RunMainEnv) != "" {
+		// The test flags are not server subcommands
+		os.Args = os.Args[:1]
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestStartupFailures(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "ots.db"), 0o755); err != nil {
+		t.Fatalf("create directory: %v", err)
+	}
+	corrupt := filepath.Join(dir, "corrupt.db")
+	if err := os.WriteFile(corrupt, []byte("not a database, but long enough to look like a header page to sqlite...."), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer taken.Close()
+	_, port, _ := net.SplitHostPort(taken.Addr().String())
+
+	// The server listens before it opens the store; PORT=0 keeps the cases
+	// that get that far off any port in use
+	tests := []struct {
+		name     string
+		env      []string
+		stage    string
+		code     string
+		exitCode int
+	}{
+		{
+			name:     "unreadable config file",
+			env:      []string{"CONFIG_FILE=" + filepath.Join(dir, "missing.yaml")},
+			stage:    "config",
+			code:     "config_unreadable",
+			exitCode: 10,
+		},
+		{
+			name:     "feature prerequisites",
+			env:      []string{"STORAGE_BACKEND=memory", "DB_LISTEN_ENABLED=true", "TRUSTED_PROXIES=proxy.internal"},
+			stage:    "config",
+			code:     "feature_prerequisites",
+			exitCode: 10,
+		},
+		{
+			name:     "CSP with a line break",
+			env:      []string{"STORAGE_BACKEND=memory", "PORT=0", "CSP_POLICY=default-src 'none'\nX-Injected: 1"},
+			stage:    "config",
+			code:     "invalid_csp_policy",
+			exitCode: 10,
+		},
+		{
+			name:     "OTLP endpoint without a scheme",
+			env:      []string{"STORAGE_BACKEND=memory", "OTEL_EXPORTER_OTLP_ENDPOINT=tempo:4318"},
+			stage:    "config",
+			code:     "invalid_otlp_endpoint",
+			exitCode: 10,
+		},
+		{
+			name:     "database path is a directory",
+			env:      []string{"PORT=0", "DATABASE_URL=sqlite://" + filepath.Join(dir, "ots.db")},
+			stage:    "db_connect",
+			code:     "sqlite_open_failed",
+			exitCode: 11,
+		},
+		{
+			name:     "database file is not a database",
+			env:      []string{"PORT=0", "DATABASE_URL=sqlite://" + corrupt},
+			stage:    "db_connect",
+			code:     "sqlite_open_failed",
+			exitCode: 11,
+		},
+		{
+			name:     "port taken",
+			env:      []string{"STORAGE_BACKEND=memory", "PORT=" + port},
+			stage:    "listen",
+			code:     "listen_failed",
+			exitCode: 14,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exitCode, failure := testutil.RunMain(t, 30*time.Second, tt.env...)
+			if exitCode != tt.exitCode || failure.ExitCode != tt.exitCode {
+				t.Errorf("exit code = %d, reported %d, want %d", exitCode, failure.ExitCode, tt.exitCode)
+			}
+			if failure.Stage != tt.stage || failure.Code != tt.code {
+				t.Errorf("failure = %s/%s, want %s/%s", failure.Stage, failure.Code, tt.stage, tt.code)
+			}
+			if failure.Detail == "" {
+				t.Error("failure has no detail")
+			}
+		})
+	}
+}
+
+func TestSelfTestCommand(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name   string
+		cfg    *config.Config
+		passed bool
+		output string
+	}{
+		{"sqlite", &config.Config{StorageBackend: config.StorageSQLite, DatabaseURL: "sqlite://" + filepath.Join(dir, "ots.db")}, true, `"status": "passed"`},
+		{"sqlite with crypto-shredding", &config.Config{StorageBackend: config.StorageSQLite, DatabaseURL: "sqlite://" + filepath.Join(dir, "ots.db"), CryptoShredding: true}, true, `"name": "burn"`},
+		{"unopenable sqlite", &config.Config{StorageBackend: config.StorageSQLite, DatabaseURL: "sqlite://" + dir}, false, "open sqlite"},
+		{"memory", &config.Config{StorageBackend: config.StorageMemory}, false, "/api/health/deep"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			if passed := runSelfTest(tt.cfg, &out); passed != tt.passed || !strings.Contains(out.String(), tt.output) {
+				t.Errorf("runSelfTest() = %v with %q; want %v with %q", passed, out.String(), tt.passed, tt.output)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/db"
+	"ots-backend/internal/startup"
+)
+
+// migrationsPath is where the Postgres migrations ship next to the binary
+const migrationsPath = "./migrations"
+
+// runCommand handles the server's subcommands:
+//
+//	server migrate          apply pending Postgres migrations and exit
+//	server migrate-status   print the applied version and dirty flag
+//	server --check-config   check every enabled feature's prerequisites and exit
+//	server --selftest       create, read and burn a secret in the store and exit
+//	server export           write every active secret, encrypted, to stdout
+//	server import           create the secrets of an export read from stdin
+func runCommand(cfg *config.Config, args []string) {
+	if len(args) == 1 && args[0] == "--check-config" {
+		checkConfig(cfg)
+		return
+	}
+	if len(args) == 1 && args[0] == "--selftest" {
+		selfTestCommand(cfg)
+		return
+	}
+	if len(args) > 0 && (args[0] == "export" || args[0] == "import") {
+		transferCommand(cfg, args[0], args[1:])
+		return
+	}
+	if len(args) != 1 || (args[0] != "migrate" && args[0] != "migrate-status") {
+		log.Fatalf("usage: server [migrate | migrate-status | --check-config | --selftest | export | import]")
+	}
+	if cfg.StorageBackend != config.StoragePostgres {
+		log.Fatalf("%s only applies to STORAGE_BACKEND=postgres; SQLite migrations are embedded and run on open", args[0])
+	}
+
+	database, err := db.New(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer database.Close()
+
+	if args[0] == "migrate" {
+		if err := database.Migrate(migrationsPath); err != nil {
+			log.Fatalf("Failed to run migrations: %v", err)
+		}
+	}
+
+	status, err := migrationStatus(database)
+	fmt.Println(status)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// migrationStatus describes the applied schema version. A dirty schema is
+// returned as a *db.DirtyError explaining how to repair it.
+func migrationStatus(database *db.DB) (string, error) {
+	version, dirty, err := database.MigrateStatus()
+	if err != nil {
+		return "migration status unknown", fmt.Errorf("read migration status: %w", err)
+	}
+	if version < 0 {
+		return "no migrations applied", nil
+	}
+	if dirty {
+		return fmt.Sprintf("migration version %d (dirty)", version), &db.DirtyError{Version: version}
+	}
+	return fmt.Sprintf("migration version %d", version), nil
+}
+
+// startupMigrations applies migrations unless MIGRATE_ON_START is off, in
+// which case it only refuses to start on a dirty schema
+func startupMigrations(cfg *config.Config, database *db.DB) {
+	if cfg.MigrateOnStart {
+		if err := database.Migrate(migrationsPath); err != nil {
+			startup.Fail(startup.StageMigrate, migrateFailureCode(err), err)
+		}
+		return
+	}
+
+	status, err := migrationStatus(database)
+	if err != nil {
+		startup.Fail(startup.StageMigrate, migrateFailureCode(err), err)
+	}
+	log.Printf("MIGRATE_ON_START is off; schema at %s. Run `server migrate` to apply new migrations", status)
+}
+
+// migrateFailureCode tells a dirty schema, which needs an operator, from a
+// migration or status read that failed outright
+func migrateFailureCode(err error) string {
+	var dirty *db.DirtyError
+	if errors.As(err, &dirty) {
+		return "schema_dirty"
+	}
+	return "migration_failed"
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ots-backend/internal/api"
+	"ots-backend/internal/config"
+	"ots-backend/internal/models"
+	"ots-backend/internal/store/memory"
+)
+
+// countriesDatabase maps 203.0.113.0/24 to NL, 198.51.100.0/24 to US and
+// 2001:db8::/32 to DE
+const countriesDatabase = "testdata/countries.mmdb"
+
+// newTestRouter builds the server's router from the environment, as main
+// does, over a memory store
+func newTestRouter(t *testing.T, env map[string]string) http.Handler {
+	t.Helper()
+
+	t.Setenv("STORAGE_BACKEND", "memory")
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("config.Load() error: %v", err)
+	}
+	secrets := memory.New()
+	t.Cleanup(secrets.Close)
+
+	router, err := newRouter(cfg, api.NewHandler(secrets, cfg))
+	if err != nil {
+		t.Fatalf("newRouter() error: %v", err)
+	}
+	return router
+}
+
+// do sends a request from remoteAddr through router
+func do(router http.Handler, request *http.Request, remoteAddr string) *httptest.ResponseRecorder {
+	request.RemoteAddr = remoteAddr
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
+
+func TestRouterLocatesReaders(t *testing.T) {
+	router := newTestRouter(t, map[string]string{"GEOIP_DATABASE": countriesDatabase})
+
+	body, _ := json.Marshal(models.CreateSecretRequest{
+		Ciphertext:    base64.StdEncoding.EncodeToString([]byte("test secret data")),
+		IV:            base64.StdEncoding.EncodeToString(make([]byte, 12)),
+		Salt:          base64.StdEncoding.EncodeToString(make([]byte, 16)),
+		ExpiresIn:     900,
+		BurnAfterRead: true,
+	})
+	request := httptest.NewRequest(http.MethodPost, "/api/secrets", bytes.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	response := do(router, request, "192.0.2.1:1234")
+	if response.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, want %d: %s", response.Code, http.StatusCreated, response.Body)
+	}
+	var created models.CreateSecretResponse
+	if err := json.NewDecoder(response.Body).Decode(&created); err != nil {
+		t.Fatalf("decode create response: %v", err)
+	}
+
+	response = do(router, httptest.NewRequest(http.MethodGet, "/api/secrets/"+created.ID, nil), "203.0.113.5:1234")
+	if response.Code != http.StatusOK {
+		t.Fatalf("read status = %d, want %d", response.Code, http.StatusOK)
+	}
+
+	request = httptest.NewRequest(http.MethodGet, "/api/secrets/"+created.ID+"/receipt", nil)
+	request.Header.Set(api.ManagementTokenHeader, created.ManagementToken)
+	response = do(router, request, "192.0.2.1:1234")
+	if response.Code != http.StatusOK {
+		t.Fatalf("receipt status = %d, want %d", response.Code, http.StatusOK)
+	}
+	var receipt models.SecretReceiptResponse
+	if err := json.NewDecoder(response.Body).Decode(&receipt); err != nil {
+		t.Fatalf("decode receipt: %v", err)
+	}
+	if receipt.ReaderCountry != "NL" {
+		t.Errorf("receipt reader_ip_country = %q, want NL", receipt.ReaderCountry)
+	}
+}
+
+// TestRouterGeoRefusalsCarryCORS checks a browser on an allowed origin can
+// read a country refusal, rather than seeing it as a network error
+func TestRouterGeoRefusalsCarryCORS(t *testing.T) {
+	const origin = "https://app.example"
+	router := newTestRouter(t, map[string]string{
+		"GEOIP_DATABASE":       countriesDatabase,
+		"GEO_DENY":             "US",
+		"CORS_ALLOWED_ORIGINS": origin,
+	})
+
+	for _, tt := range []struct {
+		remoteAddr string
+		status     int
+	}{
+		{"198.51.100.7:1234", http.StatusForbidden},
+		{"203.0.113.5:1234", http.StatusNotFound},
+	} {
+		request := httptest.NewRequest(http.MethodGet, "/api/secrets/UzpCPEFLRQ5d60AEIFqE6A", nil)
+		request.Header.Set("Origin", origin)
+		response := do(router, request, tt.remoteAddr)
+		if response.Code != tt.status {
+			t.Errorf("from %s status = %d, want %d", tt.remoteAddr, response.Code, tt.status)
+		}
+		if got := response.Header().Get("Access-Control-Allow-Origin"); got != origin {
+			t.Errorf("from %s Access-Control-Allow-Origin = %q, want %q", tt.remoteAddr, got, origin)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"ots-backend/internal/api"
+	"ots-backend/internal/config"
+	"ots-backend/internal/crypto"
+	"ots-backend/internal/db"
+	"ots-backend/internal/store"
+	"ots-backend/internal/store/postgres"
+	"ots-backend/internal/store/sqlite"
+)
+
+// selfTestCommand is `server --selftest`: it runs the API self-test against
+// the configured store, prints the report as JSON and exits 1 if a step
+// failed. It does not migrate Postgres, so a database whose migrations have
+// not run fails here rather than on a user's first request.
+func selfTestCommand(cfg *config.Config) {
+	if !runSelfTest(cfg, os.Stdout) {
+		os.Exit(1)
+	}
+}
+
+// runSelfTest writes the self-test report to w and reports whether it passed
+func runSelfTest(cfg *config.Config, w io.Writer) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), api.SelfTestTimeout)
+	defer cancel()
+
+	report, err := selfTest(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(w, "self-test failed: %v\n", err)
+		return false
+	}
+	out, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Fprintln(w, string(out))
+	return report.Passed()
+}
+
+// selfTest opens the configured store and runs the self-test on it. The
+// memory store belongs to the server process, so it is tested through GET
+// /api/health/deep instead.
+func selfTest(ctx context.Context, cfg *config.Config) (api.SelfTestReport, error) {
+	if cfg.StorageBackend == config.StorageMemory {
+		return api.SelfTestReport{}, fmt.Errorf("STORAGE_BACKEND=memory is private to the server process; use GET /api/health/deep")
+	}
+	secrets, err := openStore(cfg)
+	if err != nil {
+		return api.SelfTestReport{}, err
+	}
+	defer secrets.Close()
+
+	handler := api.NewHandler(secrets, cfg)
+	if len(cfg.EnvelopeKeys) > 0 {
+		envelopeKeys, err := crypto.ParseEnvelopeKeys(cfg.EnvelopeKeys)
+		if err != nil {
+			return api.SelfTestReport{}, fmt.Errorf("parse ENVELOPE_KEYS: %w", err)
+		}
+		handler.SetEnvelopeKeys(envelopeKeys)
+	}
+	return handler.SelfTest(ctx), nil
+}
+
+// openStore opens the configured SQLite or Postgres store for a one-off
+// command. Postgres is not migrated; run `server migrate` first.
+func openStore(cfg *config.Config) (store.Store, error) {
+	switch cfg.StorageBackend {
+	case config.StorageMemory:
+		return nil, fmt.Errorf("STORAGE_BACKEND=memory is private to the server process")
+	case config.StorageSQLite:
+		path, ok := sqlite.PathFromURL(cfg.DatabaseURL)
+		if !ok {
+			return nil, fmt.Errorf("STORAGE_BACKEND=sqlite requires DATABASE_URL=sqlite://<path>")
+		}
+		sqliteStore, err := sqlite.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("open sqlite: %w", err)
+		}
+		return sqliteStore, nil
+	default:
+		database, err := db.New(cfg.DatabaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("connect to database: %w", err)
+		}
+		return postgres.New(database), nil
+	}
+}
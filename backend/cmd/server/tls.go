@@ -0,0 +1,115 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"ots-backend/internal/config"
+)
+
+// newTLSConfig allows TLS 1.2 and later with forward-secret AEAD suites
+// only. TLS 1.3 suites are not configurable in Go and are all modern.
+func newTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+	}
+}
+
+// configureTLS sets server up for the configured TLS mode and returns the
+// mode for the startup log. With ACME it also returns a wrapper that lets
+// the plain HTTP listener answer HTTP-01 challenges; otherwise the wrapper
+// is nil.
+func configureTLS(cfg *config.Config, server *http.Server) (string, func(http.Handler) http.Handler, error) {
+	files := cfg.TLSCertFile != "" || cfg.TLSKeyFile != ""
+
+	switch {
+	case files && len(cfg.ACMEDomains) > 0:
+		return "", nil, errors.New("set either TLS_CERT_FILE/TLS_KEY_FILE or ACME_DOMAINS, not both")
+	case files:
+		if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+			return "", nil, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+		}
+		if _, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile); err != nil {
+			return "", nil, fmt.Errorf("load certificate: %w", err)
+		}
+		server.TLSConfig = newTLSConfig()
+		return "HTTPS with certificate " + cfg.TLSCertFile, nil, nil
+	case len(cfg.ACMEDomains) > 0:
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			Cache:      autocert.DirCache(cfg.ACMECacheDir),
+			HostPolicy: autocert.HostWhitelist(cfg.ACMEDomains...),
+		}
+		// Keep the manager's ALPN protocols, which include the TLS-ALPN-01
+		// challenge, and tighten versions and suites
+		tlsConfig := manager.TLSConfig()
+		strict := newTLSConfig()
+		tlsConfig.MinVersion = strict.MinVersion
+		tlsConfig.CipherSuites = strict.CipherSuites
+		server.TLSConfig = tlsConfig
+		return "HTTPS with ACME certificates for " + strings.Join(cfg.ACMEDomains, ", "), manager.HTTPHandler, nil
+	default:
+		return "plain HTTP", nil, nil
+	}
+}
+
+// serve runs server on listener until it is shut down, over TLS when
+// configureTLS gave it a TLS config. With ACME the certificate file names
+// are empty and certificates come from the config's GetCertificate.
+func serve(server *http.Server, listener net.Listener, cfg *config.Config) error {
+	var err error
+	if server.TLSConfig != nil {
+		err = server.ServeTLS(listener, cfg.TLSCertFile, cfg.TLSKeyFile)
+	} else {
+		err = server.Serve(listener)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// redirectToHTTPS sends every request to the same host and path on the
+// HTTPS port. GET and HEAD get 301; other methods get 308 so clients repeat
+// them with their body instead of switching to GET.
+func redirectToHTTPS(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+		if host == "" {
+			http.Error(w, "Host header required", http.StatusBadRequest)
+			return
+		}
+
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+
+		target := url.URL{Scheme: "https", Host: host, Path: r.URL.Path, RawPath: r.URL.RawPath, RawQuery: r.URL.RawQuery}
+		status := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			status = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, target.String(), status)
+	})
+}
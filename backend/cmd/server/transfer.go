@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/logger"
+	"ots-backend/internal/transfer"
+)
+
+// transferCommand is `server export` and `server import`. Export writes the
+// active secrets of the configured store to stdout; import reads an export
+// from stdin into it, skipping IDs the store already holds, and prints the
+// counts as JSON. Both take the passphrase from -passphrase-file, never the
+// command line.
+func transferCommand(cfg *config.Config, command string, args []string) {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	passphraseFile := flags.String("passphrase-file", "", "file holding the export passphrase")
+	flags.Parse(args)
+	if *passphraseFile == "" || flags.NArg() != 0 {
+		log.Fatalf("usage: server %s -passphrase-file <path>", command)
+	}
+
+	// Stdout carries the export and the import counts, so logs go to stderr
+	logger.SetOutput(os.Stderr)
+
+	passphrase, err := readPassphrase(*passphraseFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	secrets, err := openStore(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer secrets.Close()
+
+	if command == "export" {
+		count, err := transfer.Export(ctx, secrets, os.Stdout, passphrase, time.Now())
+		if err != nil {
+			log.Fatalf("export failed after %d secrets: %v", count, err)
+		}
+		log.Printf("exported %d secrets", count)
+		return
+	}
+
+	counts, err := transfer.Import(ctx, secrets, os.Stdin, passphrase, time.Now())
+	out, _ := json.Marshal(counts)
+	fmt.Println(string(out))
+	if err != nil {
+		log.Fatalf("import failed: %v", err)
+	}
+}
+
+// readPassphrase reads a passphrase file, dropping one trailing newline
+func readPassphrase(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("open passphrase file: %w", err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, 4096))
+	if err != nil {
+		return "", fmt.Errorf("read passphrase file: %w", err)
+	}
+	passphrase := strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r")
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase file %s is empty", path)
+	}
+	return passphrase, nil
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/models"
+	"ots-backend/internal/testutil"
+)
+
+// withAckWindow gives acks a minute
+func withAckWindow(cfg *config.Config) {
+	cfg.AckWindow = time.Minute
+}
+
+// readAckSecret consumes secretID and decodes the delivered secret
+func readAckSecret(t *testing.T, router http.Handler, secretID string) models.GetSecretResponse {
+	t.Helper()
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/"+secretID, nil))
+	if response.Code != http.StatusOK {
+		t.Fatalf("GetSecret() status = %d, want %d", response.Code, http.StatusOK)
+	}
+
+	var secret models.GetSecretResponse
+	if err := json.NewDecoder(response.Body).Decode(&secret); err != nil {
+		t.Fatalf("GetSecret() decode error: %v", err)
+	}
+	return secret
+}
+
+func ackRequest(secretID, token string) *http.Request {
+	body := `{"ack_token":"` + token + `"}`
+	request := httptest.NewRequest(http.MethodPost, "/api/secrets/"+secretID+"/ack", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	return request
+}
+
+func postAck(router http.Handler, secretID, token string) *httptest.ResponseRecorder {
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, ackRequest(secretID, token))
+	return response
+}
+
+func assertReceiptAcknowledged(t *testing.T, b *testBackend, secretID string, want bool) {
+	t.Helper()
+
+	receipt, err := b.store.Receipt(context.Background(), secretID)
+	if err != nil {
+		t.Fatalf("Receipt() error: %v", err)
+	}
+	if receipt.Acknowledged == nil || *receipt.Acknowledged != want {
+		t.Fatalf("receipt acknowledged = %v, want %v", receipt.Acknowledged, want)
+	}
+}
+
+func TestAcknowledgeBurnsSecret(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		clk := testutil.NewFakeClock(time.Now())
+		router := newTestRouterWithConfig(t, b, withAckWindow, withTestClock(clk))
+
+		req := getMockCreateSecretRequest(nil)
+		req.RequireAck = true
+		secretID := createTestSecret(t, router, req)
+
+		secret := readAckSecret(t, router, secretID)
+		if secret.Ciphertext != req.Ciphertext {
+			t.Errorf("GetSecret() ciphertext = %q, want %q", secret.Ciphertext, req.Ciphertext)
+		}
+		if secret.AckToken == "" || secret.AckExpiresAt == nil {
+			t.Fatalf("GetSecret() = %+v, want ack token and deadline", secret)
+		}
+		if want := clk.Now().Add(time.Minute); !secret.AckExpiresAt.Equal(want) {
+			t.Errorf("ack_expires_at = %v, want %v", secret.AckExpiresAt, want)
+		}
+
+		// The held secret is never delivered a second time
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/"+secretID, nil))
+		if response.Code != http.StatusNotFound {
+			t.Fatalf("second GetSecret() status = %d, want %d", response.Code, http.StatusNotFound)
+		}
+		assertReceiptAcknowledged(t, b, secretID, false)
+
+		if response := postAck(router, secretID, "not-the-token"); response.Code != http.StatusNotFound {
+			t.Fatalf("AcknowledgeSecret() with wrong token status = %d, want %d", response.Code, http.StatusNotFound)
+		}
+
+		clk.Advance(30 * time.Second)
+		if response := postAck(router, secretID, secret.AckToken); response.Code != http.StatusNoContent {
+			t.Fatalf("AcknowledgeSecret() status = %d, want %d", response.Code, http.StatusNoContent)
+		}
+		assertReceiptAcknowledged(t, b, secretID, true)
+
+		if response := postAck(router, secretID, secret.AckToken); response.Code != http.StatusNotFound {
+			t.Fatalf("second AcknowledgeSecret() status = %d, want %d", response.Code, http.StatusNotFound)
+		}
+		if burned, err := b.store.Burn(context.Background(), secretID); err != nil || burned {
+			t.Fatalf("Burn() after ack = %v, %v; want false, nil", burned, err)
+		}
+	})
+}
+
+func TestUnacknowledgedSecretBurnsAfterWindow(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		clk := testutil.NewFakeClock(time.Now())
+		router := newTestRouterWithConfig(t, b, withAckWindow, withTestClock(clk))
+
+		req := getMockCreateSecretRequest(nil)
+		req.RequireAck = true
+		secretID := createTestSecret(t, router, req)
+		secret := readAckSecret(t, router, secretID)
+
+		clk.Advance(time.Minute + time.Second)
+		if response := postAck(router, secretID, secret.AckToken); response.Code != http.StatusNotFound {
+			t.Fatalf("AcknowledgeSecret() after window status = %d, want %d", response.Code, http.StatusNotFound)
+		}
+
+		if burned, err := b.store.Burn(context.Background(), secretID); err != nil || burned {
+			t.Fatalf("Burn() after lapsed window = %v, %v; want false, nil", burned, err)
+		}
+		assertReceiptAcknowledged(t, b, secretID, false)
+	})
+}
+
+func TestAcknowledgeWithoutRequireAck(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		router := newTestRouterWithConfig(t, b, withAckWindow, withTestClock(testutil.NewFakeClock(time.Now())))
+		secretID := createTestSecret(t, router, getMockCreateSecretRequest(nil))
+
+		secret := readAckSecret(t, router, secretID)
+		if secret.AckToken != "" || secret.AckExpiresAt != nil {
+			t.Errorf("GetSecret() of plain secret = %+v, want no ack fields", secret)
+		}
+
+		if response := postAck(router, secretID, "any-token"); response.Code != http.StatusNotFound {
+			t.Fatalf("AcknowledgeSecret() of plain secret status = %d, want %d", response.Code, http.StatusNotFound)
+		}
+
+		request := httptest.NewRequest(http.MethodPost, "/api/secrets/"+secretID+"/ack", strings.NewReader(`{}`))
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		if response.Code != http.StatusBadRequest {
+			t.Fatalf("AcknowledgeSecret() without token status = %d, want %d", response.Code, http.StatusBadRequest)
+		}
+	})
+}
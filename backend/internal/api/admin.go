@@ -0,0 +1,217 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"ots-backend/internal/dropped"
+	"ots-backend/internal/logger"
+	httpMiddleware "ots-backend/internal/middleware"
+	"ots-backend/internal/store"
+)
+
+// CORSRejectionsResponse lists origins recently rejected by the CORS layer
+type CORSRejectionsResponse struct {
+	Outcomes map[string]int64                `json:"outcomes"`
+	Origins  []httpMiddleware.RejectedOrigin `json:"rejected_origins"`
+}
+
+// CORSRejections returns CORS outcome counts and the rejected origins seen
+func (h *Handler) CORSRejections(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CORSRejectionsResponse{
+		Outcomes: httpMiddleware.CORSOutcomes(),
+		Origins:  httpMiddleware.RejectedOrigins(),
+	})
+}
+
+// droppedWorkWindow is how far back admin stats summarize dropped work
+const droppedWorkWindow = 24 * time.Hour
+
+// AdminStatsResponse aggregates stored secrets for operators
+type AdminStatsResponse struct {
+	// GeneratedAt dates an export, for otsadmin project
+	GeneratedAt time.Time `json:"generated_at"`
+	// DeclaredKeyBits counts live secrets by declared key length; creates
+	// without a declaration are counted under "undeclared"
+	DeclaredKeyBits   map[string]int64 `json:"declared_key_bits"`
+	UndeclaredCreates int64            `json:"undeclared_key_bits_total"`
+	// DroppedWork sums the async work every instance gave up on over the
+	// last 24 hours
+	DroppedWork []DroppedWorkSummary `json:"dropped_work_24h"`
+	// SecretSizes counts every create recorded by size bucket. In strict
+	// privacy mode counts carry noise of at most SizeNoiseBound.
+	SecretSizes    []SizeBucketSummary `json:"secret_sizes"`
+	SizeNoiseBound int64               `json:"secret_sizes_noise_bound"`
+	// Daily is the usage series for the requested days, oldest first
+	Daily []DailyStatsResponse `json:"daily"`
+	// ActiveSecretQuota is how close clients are to the active-secret quota
+	ActiveSecretQuota ActiveSecretQuotaResponse `json:"active_secret_quota"`
+	// EnvelopeKeys is how far data keys have moved to the current envelope key
+	EnvelopeKeys EnvelopeKeysResponse `json:"envelope_keys"`
+}
+
+// EnvelopeKeysResponse counts stored data keys per envelope key version, so
+// operators see when a rotation of ENVELOPE_KEYS is complete
+type EnvelopeKeysResponse struct {
+	// Current is the version new data keys are wrapped under, empty
+	// without ENVELOPE_KEYS
+	Current  string              `json:"current"`
+	Versions []KeyVersionSummary `json:"versions"`
+	// Remaining counts data keys under any other version; the cleanup
+	// worker rewraps them
+	Remaining int64 `json:"remaining"`
+}
+
+// KeyVersionSummary is the data keys wrapped under one envelope key version
+type KeyVersionSummary struct {
+	// Version is empty for data keys stored unwrapped
+	Version  string `json:"version"`
+	DataKeys int64  `json:"data_keys"`
+	// Configured reports whether ENVELOPE_KEYS still holds the key; data
+	// keys under a version that is not configured cannot be opened
+	Configured bool `json:"configured"`
+}
+
+// ActiveSecretQuotaResponse summarizes live secrets per client IP against
+// the quota. Secrets created while the quota was off are not counted.
+type ActiveSecretQuotaResponse struct {
+	Limit           int   `json:"limit"`
+	Creators        int64 `json:"creators"`
+	CreatorsAtLimit int64 `json:"creators_at_limit"`
+	MaxActive       int64 `json:"max_active"`
+	// Rejections counts this instance's refused creates since start
+	Rejections int64 `json:"rejections_total"`
+}
+
+// DroppedWorkSummary is the async work of one kind dropped for one reason
+type DroppedWorkSummary struct {
+	Kind           string    `json:"kind"`
+	Reason         string    `json:"reason"`
+	Count          int64     `json:"count"`
+	LastRecordedAt time.Time `json:"last_recorded_at"`
+}
+
+// AdminStats returns aggregate statistics over stored secrets, the size
+// distribution of creates, daily usage totals for the from and to dates and
+// the dropped work recorded over the last day, how close clients are to
+// the active-secret quota and how many data keys await rewrapping. This instance's pending losses are flushed first
+// so the summary includes them.
+func (h *Handler) AdminStats(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseDailyRange(r, h.clock.Now())
+	if err != nil {
+		h.respondServiceError(w, err)
+		return
+	}
+
+	counts, err := h.store.DeclaredKeyBits(r.Context(), h.clock.Now())
+	if err != nil {
+		logger.Error("admin stats: query failed", "error", err)
+		h.respondStoreError(w, err, "database error")
+		return
+	}
+
+	now := h.clock.Now()
+	if err := dropped.Flush(r.Context(), h.store, now); err != nil {
+		logger.Warn("admin stats: failed to record dropped work", "error", err)
+	}
+	losses, err := h.store.DroppedWorkSince(r.Context(), now.Add(-droppedWorkWindow))
+	if err != nil {
+		logger.Error("admin stats: dropped work query failed", "error", err)
+		h.respondStoreError(w, err, "database error")
+		return
+	}
+	summary := make([]DroppedWorkSummary, 0, len(losses))
+	for _, loss := range losses {
+		summary = append(summary, DroppedWorkSummary{
+			Kind:           loss.Kind,
+			Reason:         loss.Reason,
+			Count:          loss.Count,
+			LastRecordedAt: loss.RecordedAt.UTC(),
+		})
+	}
+
+	buckets, err := h.store.SizeBuckets(r.Context())
+	if err != nil {
+		logger.Error("admin stats: size buckets query failed", "error", err)
+		h.respondStoreError(w, err, "database error")
+		return
+	}
+	noise := h.sizeNoise()
+
+	days, err := h.store.DailyStats(r.Context(), from, to)
+	if err != nil {
+		logger.Error("admin stats: daily stats query failed", "error", err)
+		h.respondStoreError(w, err, "database error")
+		return
+	}
+
+	quota := h.policy().ActiveSecretQuota
+	creators, err := h.store.CreatorStats(r.Context(), now, quota)
+	if err != nil {
+		logger.Error("admin stats: creator stats query failed", "error", err)
+		h.respondStoreError(w, err, "database error")
+		return
+	}
+
+	keyVersions, err := h.store.DataKeyVersions(r.Context())
+	if err != nil {
+		logger.Error("admin stats: data key versions query failed", "error", err)
+		h.respondStoreError(w, err, "database error")
+		return
+	}
+
+	distribution := make(map[string]int64)
+	for _, count := range counts {
+		key := "undeclared"
+		if count.Bits != nil {
+			key = strconv.Itoa(*count.Bits)
+		}
+		distribution[key] = count.Count
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AdminStatsResponse{
+		GeneratedAt:       now.UTC(),
+		DeclaredKeyBits:   distribution,
+		UndeclaredCreates: GetMetrics().UndeclaredKeyBits,
+		DroppedWork:       summary,
+		SecretSizes:       sizeSummaries(noise.Buckets(buckets)),
+		SizeNoiseBound:    noise.Bound(),
+		Daily:             dailySeries(days, from, to),
+		ActiveSecretQuota: ActiveSecretQuotaResponse{
+			Limit:           quota,
+			Creators:        creators.Creators,
+			CreatorsAtLimit: creators.AtQuota,
+			MaxActive:       creators.MaxActive,
+			Rejections:      GetMetrics().QuotaRejections,
+		},
+		EnvelopeKeys: h.envelopeKeysSummary(keyVersions),
+	})
+}
+
+// envelopeKeysSummary reports counts against the configured envelope keys
+func (h *Handler) envelopeKeysSummary(counts []store.KeyVersionCount) EnvelopeKeysResponse {
+	var current string
+	var configured []string
+	if h.envelope != nil {
+		current = h.envelope.Current()
+		configured = h.envelope.Versions()
+	}
+
+	summary := EnvelopeKeysResponse{Current: current, Versions: make([]KeyVersionSummary, 0, len(counts))}
+	for _, count := range counts {
+		summary.Versions = append(summary.Versions, KeyVersionSummary{
+			Version:    count.Version,
+			DataKeys:   count.DataKeys,
+			Configured: count.Version == "" || slices.Contains(configured, count.Version),
+		})
+		if count.Version != current {
+			summary.Remaining += count.DataKeys
+		}
+	}
+	return summary
+}
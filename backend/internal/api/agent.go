@@ -3,6 +3,7 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime"
@@ -14,15 +15,21 @@ import (
 
 	"ots-backend/internal/crypto"
 	"ots-backend/internal/logger"
+	httpMiddleware "ots-backend/internal/middleware"
 	"ots-backend/internal/models"
+	"ots-backend/internal/scan"
+	"ots-backend/internal/sensitive"
 	"ots-backend/internal/validation"
+	"ots-backend/pkg/ots"
 )
 
 type parsedAgentCreateRequest struct {
-	Content    []byte
-	Passphrase string
+	Content    sensitive.Bytes
+	Passphrase sensitive.String
 	ExpiresIn  int
 	Source     string
+	// Filename is the name of an uploaded file, empty for other sources
+	Filename string
 }
 
 func (h *Handler) CreateAgentSecret(w http.ResponseWriter, r *http.Request) {
@@ -35,27 +42,36 @@ func (h *Handler) CreateAgentSecret(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Only the filename is metadata; the content is never scanned
+	if parsedReq.Filename != "" {
+		if err := validation.ValidateMetadata(scan.Field{Name: scan.FieldFilename, Value: parsedReq.Filename}); err != nil {
+			logger.Warn("agent upload rejected by policy", "error", err, "ip", r.RemoteAddr)
+			h.respondServiceError(w, err)
+			return
+		}
+	}
+
 	expiresIn := parsedReq.ExpiresIn
 	if expiresIn == 0 {
-		expiresIn = int(h.cfg.AgentDefaultTTL.Seconds())
+		expiresIn = int(h.config().AgentDefaultTTL.Seconds())
 	}
 
-	if err := validation.ValidatePlaintextContent(parsedReq.Content, h.cfg.MaxSecretSize); err != nil {
+	if err := validation.ValidatePlaintextContent(parsedReq.Content, h.policy()); err != nil {
 		logger.Warn("invalid agent secret content", "error", err, "ip", r.RemoteAddr)
-		h.respondValidationError(w, err)
+		h.respondServiceError(w, err)
 		return
 	}
 
-	ttl, err := validation.ValidateTTL(expiresIn)
+	ttl, err := validation.ValidateTTL(expiresIn, h.policy())
 	if err != nil {
 		logger.Warn("invalid agent ttl", "error", err, "ip", r.RemoteAddr)
-		h.respondValidationError(w, err)
+		h.respondServiceError(w, err)
 		return
 	}
 
 	var encryptedSecret *crypto.EncryptedSecret
 	if parsedReq.Passphrase != "" {
-		encryptedSecret, err = crypto.EncryptPlaintextWithPassphrase(parsedReq.Content, parsedReq.Passphrase)
+		encryptedSecret, err = crypto.EncryptPlaintextWithPassphrase(parsedReq.Content, string(parsedReq.Passphrase))
 	} else {
 		encryptedSecret, err = crypto.EncryptPlaintext(parsedReq.Content)
 	}
@@ -70,35 +86,47 @@ func (h *Handler) CreateAgentSecret(w http.ResponseWriter, r *http.Request) {
 		encryptedSecret.IV,
 		encryptedSecret.Salt,
 		expiresIn,
-		h.cfg.MaxSecretSize,
+		h.policy(),
 	)
 	if err != nil {
 		logger.Warn("invalid encrypted agent payload", "error", err, "ip", r.RemoteAddr)
-		h.respondValidationError(w, err)
+		h.respondServiceError(w, err)
 		return
 	}
 
-	secretID, expiresAt, err := h.storeSecret(r, validatedReq)
+	// The server generated the key itself, so its length is known
+	if parsedReq.Passphrase == "" {
+		keyBits := crypto.ShareKeyBits
+		validatedReq.DeclaredKeyBits = &keyBits
+	}
+
+	stored, err := h.storeSecret(r, validatedReq)
+	if errors.Is(err, ots.ErrQuotaExceeded) {
+		h.respondServiceError(w, err)
+		return
+	}
 	if err != nil {
 		logger.Error("failed to store agent secret", "error", err)
-		h.respondError(w, http.StatusInternalServerError, "failed to store secret")
+		h.respondStoreError(w, err, "failed to store secret")
 		return
 	}
 
-	shareURL := h.buildShareURL(r, secretID, encryptedSecret.ShareKey)
+	secretID := stored.ID
+	shareURL := h.buildShareURL(r, secretID, string(encryptedSecret.ShareKey))
 	resp := models.AgentCreateSecretResponse{
 		ID:                 secretID,
 		URL:                shareURL,
-		ExpiresAt:          expiresAt.UTC(),
+		ExpiresAt:          stored.ExpiresAt.UTC(),
 		ExpiresIn:          int(ttl.Seconds()),
 		PassphraseRequired: parsedReq.Passphrase != "",
+		ManagementToken:    stored.ManagementToken,
 	}
 
 	logger.Info("agent secret created",
 		"secret_id", secretID,
 		"source", parsedReq.Source,
 		"expires_in", ttl,
-		"size", len(validatedReq.Ciphertext),
+		"size", h.loggedSize(validatedReq.Size()),
 		"duration", time.Since(start),
 		"passphrase_required", parsedReq.Passphrase != "",
 		"ip", r.RemoteAddr,
@@ -131,7 +159,7 @@ func (h *Handler) parseAgentCreateRequest(r *http.Request) (*parsedAgentCreateRe
 }
 
 func (h *Handler) parseAgentJSONRequest(r *http.Request) (*parsedAgentCreateRequest, error) {
-	decoder := json.NewDecoder(io.LimitReader(r.Body, int64(h.cfg.MaxSecretSize)+1024))
+	decoder := json.NewDecoder(io.LimitReader(r.Body, int64(h.policy().MaxSecretSize)+1024))
 	decoder.DisallowUnknownFields()
 
 	var req models.AgentCreateSecretRequest
@@ -141,33 +169,35 @@ func (h *Handler) parseAgentJSONRequest(r *http.Request) (*parsedAgentCreateRequ
 
 	return &parsedAgentCreateRequest{
 		Content:    []byte(req.Content),
-		Passphrase: req.Passphrase,
+		Passphrase: sensitive.String(req.Passphrase),
 		ExpiresIn:  req.ExpiresIn,
 		Source:     "json",
 	}, nil
 }
 
 func (h *Handler) parseAgentMultipartRequest(r *http.Request) (*parsedAgentCreateRequest, error) {
-	if err := r.ParseMultipartForm(int64(h.cfg.MaxSecretSize) * 2); err != nil {
+	if err := r.ParseMultipartForm(int64(h.policy().MaxSecretSize) * 2); err != nil {
 		return nil, fmt.Errorf("invalid multipart form")
 	}
 
 	var content []byte
+	var filename string
 	source := "multipart-content"
 
-	if file, _, err := r.FormFile("file"); err == nil {
+	if file, header, err := r.FormFile("file"); err == nil {
 		defer file.Close()
 
-		content, err = io.ReadAll(io.LimitReader(file, int64(h.cfg.MaxSecretSize)+1))
+		content, err = io.ReadAll(io.LimitReader(file, int64(h.policy().MaxSecretSize)+1))
 		if err != nil {
 			return nil, fmt.Errorf("failed to read uploaded file")
 		}
 
-		if len(content) > h.cfg.MaxSecretSize {
-			return nil, fmt.Errorf("%w: %d bytes (max %d)", validation.ErrSecretTooLarge, len(content), h.cfg.MaxSecretSize)
+		if len(content) > h.policy().MaxSecretSize {
+			return nil, fmt.Errorf("%w: %d bytes (max %d)", validation.ErrSecretTooLarge, len(content), h.policy().MaxSecretSize)
 		}
 
 		source = "multipart-file"
+		filename = header.Filename
 	} else if contentValue := r.FormValue("content"); contentValue != "" {
 		content = []byte(contentValue)
 	} else {
@@ -185,9 +215,10 @@ func (h *Handler) parseAgentMultipartRequest(r *http.Request) (*parsedAgentCreat
 
 	return &parsedAgentCreateRequest{
 		Content:    content,
-		Passphrase: r.FormValue("passphrase"),
+		Passphrase: sensitive.String(r.FormValue("passphrase")),
 		ExpiresIn:  expiresIn,
 		Source:     source,
+		Filename:   filename,
 	}, nil
 }
 
@@ -208,20 +239,20 @@ func (h *Handler) parseAgentFormRequest(r *http.Request) (*parsedAgentCreateRequ
 
 	return &parsedAgentCreateRequest{
 		Content:    content,
-		Passphrase: r.FormValue("passphrase"),
+		Passphrase: sensitive.String(r.FormValue("passphrase")),
 		ExpiresIn:  expiresIn,
 		Source:     "form",
 	}, nil
 }
 
 func (h *Handler) parseAgentTextRequest(r *http.Request) (*parsedAgentCreateRequest, error) {
-	content, err := io.ReadAll(io.LimitReader(r.Body, int64(h.cfg.MaxSecretSize)+1))
+	content, err := io.ReadAll(io.LimitReader(r.Body, int64(h.policy().MaxSecretSize)+1))
 	if err != nil {
 		return nil, fmt.Errorf("failed to read request body")
 	}
 
-	if len(content) > h.cfg.MaxSecretSize {
-		return nil, fmt.Errorf("%w: %d bytes (max %d)", validation.ErrSecretTooLarge, len(content), h.cfg.MaxSecretSize)
+	if len(content) > h.policy().MaxSecretSize {
+		return nil, fmt.Errorf("%w: %d bytes (max %d)", validation.ErrSecretTooLarge, len(content), h.policy().MaxSecretSize)
 	}
 
 	expiresIn, err := parseOptionalInt(r.Header.Get("X-Secret-Expires-In"))
@@ -231,30 +262,18 @@ func (h *Handler) parseAgentTextRequest(r *http.Request) (*parsedAgentCreateRequ
 
 	return &parsedAgentCreateRequest{
 		Content:    bytes.TrimPrefix(content, []byte("\ufeff")),
-		Passphrase: r.Header.Get("X-Secret-Passphrase"),
+		Passphrase: sensitive.String(r.Header.Get("X-Secret-Passphrase")),
 		ExpiresIn:  expiresIn,
 		Source:     "text",
 	}, nil
 }
 
 func (h *Handler) buildShareURL(r *http.Request, secretID, shareKey string) string {
-	baseURL := strings.TrimRight(h.cfg.PublicBaseURL, "/")
+	baseURL := strings.TrimRight(h.config().PublicBaseURL, "/")
 	if baseURL == "" {
-		scheme := r.Header.Get("X-Forwarded-Proto")
-		if scheme == "" {
-			if r.TLS != nil {
-				scheme = "https"
-			} else {
-				scheme = "http"
-			}
-		}
-
-		host := strings.TrimSpace(r.Host)
-		if forwardedHost := strings.TrimSpace(r.Header.Get("X-Forwarded-Host")); forwardedHost != "" {
-			host = forwardedHost
-		}
-
-		baseURL = fmt.Sprintf("%s://%s", scheme, host)
+		// Forwarded scheme and host count only from trusted proxies, so a
+		// client cannot point the link elsewhere
+		baseURL = fmt.Sprintf("%s://%s", httpMiddleware.RequestScheme(r), httpMiddleware.RequestHost(r))
 	}
 
 	shareURL := fmt.Sprintf("%s/s/%s", baseURL, secretID)
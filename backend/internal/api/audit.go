@@ -0,0 +1,268 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ots-backend/internal/dropped"
+	"ots-backend/internal/logger"
+	"ots-backend/internal/store"
+	"ots-backend/internal/ulid"
+	"ots-backend/pkg/ots"
+)
+
+var auditEventTypes = map[string]bool{
+	store.AuditSecretCreated:          true,
+	store.AuditSecretConsumed:         true,
+	store.AuditSecretBurned:           true,
+	store.AuditSecretAcknowledged:     true,
+	store.AuditSecretExpiryReduced:    true,
+	store.AuditSecretStragglerRemoved: true,
+	store.AuditSecretReported:         true,
+	store.AuditSecretDossierGenerated: true,
+}
+
+// Audit listing limits
+const (
+	auditDefaultLimit = 100
+	auditMaxLimit     = 1000
+	// auditFlushEvery is how many NDJSON rows are buffered between flushes
+	auditFlushEvery = 100
+)
+
+// ndjsonContentType selects the streaming export
+const ndjsonContentType = "application/x-ndjson"
+
+// auditCursorPrefix versions the cursor so its format can change later
+const auditCursorPrefix = "v1:"
+
+// AuditEventResponse is one audit log entry
+type AuditEventResponse struct {
+	ID           string    `json:"id"`
+	Type         string    `json:"type"`
+	OccurredAt   time.Time `json:"occurred_at"`
+	Namespace    string    `json:"namespace,omitempty"`
+	SecretIDHash string    `json:"secret_id_hash"`
+	NetworkClass string    `json:"network_class,omitempty"`
+	Reason       string    `json:"reason,omitempty"`
+	Actor        string    `json:"actor,omitempty"`
+}
+
+// AuditPageResponse is one page of the audit listing
+type AuditPageResponse struct {
+	Events []AuditEventResponse `json:"events"`
+	// NextCursor fetches the following page; empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// recordAudit appends an event to the audit log when it is enabled. The
+// secret ID is stored only as its SHA-256; a failure is logged and counted
+// as dropped work, and never fails the request that caused it.
+func (h *Handler) recordAudit(ctx context.Context, eventType, secretID, networkClass string) {
+	h.appendAudit(ctx, &store.AuditEvent{
+		Type:         eventType,
+		SecretIDHash: store.HashSecretID(secretID),
+		NetworkClass: networkClass,
+	})
+}
+
+// appendAudit stamps event with an ID and the current time and appends it
+// like recordAudit
+func (h *Handler) appendAudit(ctx context.Context, event *store.AuditEvent) {
+	if !h.config().AuditLogEnabled {
+		return
+	}
+
+	now := h.clock.Now().UTC()
+	event.ID = h.auditIDs.New(now)
+	event.OccurredAt = now
+	if err := h.store.RecordAudit(ctx, event); err != nil {
+		reason := dropped.Reason(ctx, err)
+		dropped.Record(dropped.KindAuditEvent, reason)
+		logger.Warn("failed to record audit event", "error", err, "type", event.Type, "reason", reason)
+	}
+}
+
+// AuditLog lists audit events oldest first. Filters are combined with AND;
+// pages are keyset-paginated on the ULID, so a cursor keeps its position
+// while new events are written. With Accept: application/x-ndjson every
+// match is streamed as it is read instead.
+func (h *Handler) AuditLog(w http.ResponseWriter, r *http.Request) {
+	filter, limit, err := parseAuditQuery(r)
+	if err != nil {
+		h.respondServiceError(w, err)
+		return
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), ndjsonContentType) {
+		h.exportAudit(w, r, filter)
+		return
+	}
+
+	// One extra row tells whether another page follows
+	filter.Limit = limit + 1
+	events := make([]AuditEventResponse, 0, limit)
+	err = h.store.ScanAudit(r.Context(), filter, func(event *store.AuditEvent) error {
+		events = append(events, auditEventResponse(event))
+		return nil
+	})
+	if err != nil {
+		logger.Error("audit log: query failed", "error", err)
+		h.respondStoreError(w, err, "database error")
+		return
+	}
+
+	page := AuditPageResponse{Events: events}
+	if len(events) > limit {
+		page.Events = events[:limit]
+		page.NextCursor = encodeAuditCursor(page.Events[limit-1].ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// exportAudit streams matching events as NDJSON while the store reads them,
+// flushing every auditFlushEvery rows. Once the first row is written the
+// status is committed, so a later failure just ends the stream.
+func (h *Handler) exportAudit(w http.ResponseWriter, r *http.Request, filter store.AuditFilter) {
+	controller := http.NewResponseController(w)
+	encoder := json.NewEncoder(w)
+	rows := 0
+
+	start := func() {
+		w.Header().Set("Content-Type", ndjsonContentType)
+		w.WriteHeader(http.StatusOK)
+	}
+
+	err := h.store.ScanAudit(r.Context(), filter, func(event *store.AuditEvent) error {
+		if rows == 0 {
+			start()
+		}
+		if err := encoder.Encode(auditEventResponse(event)); err != nil {
+			return err
+		}
+		rows++
+		if rows%auditFlushEvery == 0 {
+			controller.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("audit export failed", "error", err, "rows", rows)
+		if rows == 0 {
+			h.respondStoreError(w, err, "database error")
+		}
+		return
+	}
+
+	if rows == 0 {
+		start()
+	}
+	controller.Flush()
+}
+
+func auditEventResponse(event *store.AuditEvent) AuditEventResponse {
+	return AuditEventResponse{
+		ID:           event.ID,
+		Type:         event.Type,
+		OccurredAt:   event.OccurredAt.UTC(),
+		Namespace:    event.Namespace,
+		SecretIDHash: event.SecretIDHash,
+		NetworkClass: event.NetworkClass,
+		Reason:       event.Reason,
+		Actor:        event.Actor,
+	}
+}
+
+// parseAuditQuery turns the listing's query parameters into a store filter
+// and page size
+func parseAuditQuery(r *http.Request) (store.AuditFilter, int, error) {
+	query := r.URL.Query()
+	var filter store.AuditFilter
+
+	for _, value := range query["type"] {
+		for _, eventType := range strings.Split(value, ",") {
+			eventType = strings.TrimSpace(eventType)
+			if !auditEventTypes[eventType] {
+				return filter, 0, ots.ErrInvalidAuditQuery
+			}
+			filter.Types = append(filter.Types, eventType)
+		}
+	}
+
+	var since, until time.Time
+	if value := query.Get("since"); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, 0, ots.ErrInvalidAuditQuery
+		}
+		since = t
+		filter.FromID = ulid.Floor(t)
+	}
+	if value := query.Get("until"); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, 0, ots.ErrInvalidAuditQuery
+		}
+		until = t
+		filter.BeforeID = ulid.Floor(t)
+	}
+	if !since.IsZero() && !until.IsZero() && !since.Before(until) {
+		return filter, 0, ots.ErrInvalidAuditQuery
+	}
+
+	filter.Namespace = query.Get("namespace")
+
+	if prefix := query.Get("id_prefix"); prefix != "" {
+		if len(prefix) > sha256.Size*2 || strings.Trim(prefix, "0123456789abcdef") != "" {
+			return filter, 0, ots.ErrInvalidAuditQuery
+		}
+		filter.SecretIDHashPrefix = prefix
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		id, err := decodeAuditCursor(cursor)
+		if err != nil {
+			return filter, 0, ots.ErrInvalidAuditQuery
+		}
+		filter.AfterID = id
+	}
+
+	limit := auditDefaultLimit
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > auditMaxLimit {
+			return filter, 0, ots.ErrInvalidAuditQuery
+		}
+		limit = n
+		// An explicit limit also caps the export
+		filter.Limit = n
+	}
+
+	return filter, limit, nil
+}
+
+// encodeAuditCursor wraps the last ID of a page; clients treat it as opaque
+func encodeAuditCursor(id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(auditCursorPrefix + id))
+}
+
+func decodeAuditCursor(cursor string) (string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", err
+	}
+	id, ok := strings.CutPrefix(string(raw), auditCursorPrefix)
+	if !ok || !ulid.Valid(id) {
+		return "", errors.New("malformed cursor")
+	}
+	return id, nil
+}
@@ -0,0 +1,358 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/store"
+	"ots-backend/internal/testutil"
+	"ots-backend/internal/ulid"
+)
+
+const auditTestToken = "audit-admin-token"
+
+func auditTestConfig() *config.Config {
+	cfg := &config.Config{
+		MaxSecretSize:          32768,
+		WriteRateLimitRequests: 1000,
+		WriteRateLimitWindow:   time.Minute,
+		ReadRateLimitRequests:  1000,
+		ReadRateLimitWindow:    time.Minute,
+	}
+	withAudit(cfg)
+	return cfg
+}
+
+// withAudit records audit events and opens the admin API to auditTestToken
+func withAudit(cfg *config.Config) {
+	cfg.AuditRateLimitRequests = 1000
+	cfg.AuditRateLimitWindow = time.Minute
+	cfg.AdminToken = auditTestToken
+	cfg.AuditLogEnabled = true
+	cfg.AllowOpenDelete = true
+}
+
+func getAudit(router http.Handler, query url.Values) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, "/api/admin/audit?"+query.Encode(), nil)
+	request.Header.Set("Authorization", "Bearer "+auditTestToken)
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
+
+func getAuditPage(t *testing.T, router http.Handler, query url.Values) AuditPageResponse {
+	t.Helper()
+
+	response := getAudit(router, query)
+	if response.Code != http.StatusOK {
+		t.Fatalf("GET /api/admin/audit?%s status = %d, want %d: %s", query.Encode(), response.Code, http.StatusOK, response.Body)
+	}
+
+	var page AuditPageResponse
+	if err := json.NewDecoder(response.Body).Decode(&page); err != nil {
+		t.Fatalf("decode audit page: %v", err)
+	}
+	return page
+}
+
+// recordAuditEvents writes n events one second apart starting at start
+func recordAuditEvents(t *testing.T, s store.Store, start time.Time, n int) []string {
+	t.Helper()
+
+	var gen ulid.Generator
+	ids := make([]string, n)
+	for i := range ids {
+		at := start.Add(time.Duration(i) * time.Second)
+		event := &store.AuditEvent{
+			ID:           gen.New(at),
+			Type:         store.AuditSecretCreated,
+			OccurredAt:   at,
+			SecretIDHash: store.HashSecretID(at.String()),
+		}
+		if err := s.RecordAudit(context.Background(), event); err != nil {
+			t.Fatalf("RecordAudit() error: %v", err)
+		}
+		ids[i] = event.ID
+	}
+	return ids
+}
+
+func TestAuditLogFilters(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		start := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+		clk := testutil.NewFakeClock(start)
+		router := newTestRouterWithConfig(t, b, withAudit, withTestClock(clk))
+
+		read := createTestSecret(t, router, getMockCreateSecretRequest(nil))
+		clk.Advance(time.Minute)
+		burned := createTestSecret(t, router, getMockCreateSecretRequest(nil))
+		clk.Advance(time.Minute)
+		createTestSecret(t, router, getMockCreateSecretRequest(nil))
+		clk.Advance(time.Minute)
+
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/secrets/"+read, nil))
+		clk.Advance(time.Minute)
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/api/secrets/"+burned, nil))
+
+		tests := []struct {
+			name  string
+			query url.Values
+			want  int
+		}{
+			{"all", url.Values{}, 5},
+			{"type", url.Values{"type": {store.AuditSecretCreated}}, 3},
+			{"repeated types", url.Values{"type": {store.AuditSecretConsumed, store.AuditSecretBurned}}, 2},
+			{"comma types", url.Values{"type": {store.AuditSecretConsumed + "," + store.AuditSecretBurned}}, 2},
+			{"id prefix", url.Values{"id_prefix": {store.HashSecretID(read)[:12]}}, 2},
+			{"type and id prefix", url.Values{"type": {store.AuditSecretConsumed}, "id_prefix": {store.HashSecretID(read)}}, 1},
+			{"since", url.Values{"since": {start.Add(2 * time.Minute).Format(time.RFC3339)}}, 3},
+			{"time range", url.Values{
+				"since": {start.Add(time.Minute).Format(time.RFC3339)},
+				"until": {start.Add(3 * time.Minute).Format(time.RFC3339)},
+			}, 2},
+			{"range and type", url.Values{
+				"type":  {store.AuditSecretCreated},
+				"since": {start.Add(time.Minute).Format(time.RFC3339)},
+			}, 2},
+			{"namespace", url.Values{"namespace": {"other"}}, 0},
+		}
+
+		for _, tt := range tests {
+			page := getAuditPage(t, router, tt.query)
+			if len(page.Events) != tt.want {
+				t.Errorf("%s: got %d events, want %d: %+v", tt.name, len(page.Events), tt.want, page.Events)
+			}
+			if page.NextCursor != "" {
+				t.Errorf("%s: next_cursor = %q on the only page", tt.name, page.NextCursor)
+			}
+			for i := 1; i < len(page.Events); i++ {
+				if page.Events[i-1].ID >= page.Events[i].ID {
+					t.Errorf("%s: events out of order: %+v", tt.name, page.Events)
+				}
+			}
+		}
+
+		consumed := getAuditPage(t, router, url.Values{"type": {store.AuditSecretConsumed}}).Events
+		if consumed[0].SecretIDHash != store.HashSecretID(read) || !consumed[0].OccurredAt.Equal(start.Add(3*time.Minute)) {
+			t.Errorf("consumed event = %+v", consumed[0])
+		}
+	})
+}
+
+func TestAuditLogRejectsBadQueries(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouterWithConfig(t, b, withAudit, withTestClock(testutil.NewFakeClock(time.Now())))
+
+		for _, query := range []url.Values{
+			{"type": {"secret.unknown"}},
+			{"since": {"yesterday"}},
+			{"since": {"2026-05-02T00:00:00Z"}, "until": {"2026-05-01T00:00:00Z"}},
+			{"id_prefix": {"ABCD"}},
+			{"cursor": {"not-a-cursor"}},
+			{"cursor": {encodeAuditCursor("short")}},
+			{"limit": {"0"}},
+			{"limit": {"1001"}},
+		} {
+			response := getAudit(router, query)
+			if response.Code != http.StatusBadRequest {
+				t.Errorf("GET /api/admin/audit?%s status = %d, want %d", query.Encode(), response.Code, http.StatusBadRequest)
+			}
+		}
+
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/admin/audit", nil))
+		if response.Code != http.StatusUnauthorized {
+			t.Errorf("GET /api/admin/audit without token status = %d, want %d", response.Code, http.StatusUnauthorized)
+		}
+	})
+}
+
+func TestAuditLogDisabledRecordsNothing(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouterWithConfig(t, b, func(cfg *config.Config) {
+			cfg.AdminToken = auditTestToken
+			cfg.AuditRateLimitRequests = 1000
+			cfg.AuditRateLimitWindow = time.Minute
+		})
+
+		createTestSecret(t, router, getMockCreateSecretRequest(nil))
+		if page := getAuditPage(t, router, url.Values{}); len(page.Events) != 0 {
+			t.Errorf("audit log with AUDIT_LOG_ENABLED unset = %+v, want empty", page.Events)
+		}
+	})
+}
+
+func TestAuditCursorStableUnderInserts(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		start := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+		router := newTestRouterWithConfig(t, b, withAudit, withTestClock(testutil.NewFakeClock(start)))
+		existing := recordAuditEvents(t, b.store, start, 25)
+
+		// Writers keep appending newer events while the pages are walked
+		var newer ulid.Generator
+		var wg sync.WaitGroup
+		stop := make(chan struct{})
+		for w := 0; w < 3; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; ; i++ {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					at := start.Add(time.Hour + time.Duration(i)*time.Millisecond)
+					b.store.RecordAudit(context.Background(), &store.AuditEvent{
+						ID:           newer.New(at),
+						Type:         store.AuditSecretConsumed,
+						OccurredAt:   at,
+						SecretIDHash: store.HashSecretID(at.String()),
+					})
+				}
+			}()
+		}
+
+		var seen []string
+		query := url.Values{"limit": {"4"}, "type": {store.AuditSecretCreated}}
+		for pages := 0; ; pages++ {
+			if pages > 10 {
+				t.Fatal("pagination did not terminate")
+			}
+			page := getAuditPage(t, router, query)
+			for _, event := range page.Events {
+				seen = append(seen, event.ID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			query.Set("cursor", page.NextCursor)
+		}
+		close(stop)
+		wg.Wait()
+
+		if len(seen) != len(existing) {
+			t.Fatalf("paged %d events, want %d", len(seen), len(existing))
+		}
+		for i := range seen {
+			if seen[i] != existing[i] {
+				t.Fatalf("page walk = %v, want %v", seen, existing)
+			}
+		}
+
+		// A cursor taken before more events arrive still resumes after it
+		page := getAuditPage(t, router, url.Values{"limit": {"20"}})
+		more := getAuditPage(t, router, url.Values{"limit": {"5"}, "cursor": {page.NextCursor}})
+		if len(more.Events) != 5 || more.Events[0].ID != existing[20] {
+			t.Errorf("resumed page = %+v, want to start at %s", more.Events, existing[20])
+		}
+	})
+}
+
+// flushRecorder notes how many NDJSON lines had been written at each flush
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushedAt []int
+}
+
+func (r *flushRecorder) Flush() {
+	r.flushedAt = append(r.flushedAt, bytes.Count(r.Body.Bytes(), []byte("\n")))
+	r.ResponseRecorder.Flush()
+}
+
+func TestAuditExportStreamsNDJSON(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		start := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+		ids := recordAuditEvents(t, b.store, start, 2*auditFlushEvery+50)
+
+		router := newTestRouterWithConfig(t, b, withAudit)
+
+		request := httptest.NewRequest(http.MethodGet, "/api/admin/audit", nil)
+		request.Header.Set("Authorization", "Bearer "+auditTestToken)
+		request.Header.Set("Accept", ndjsonContentType)
+		response := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+		router.ServeHTTP(response, request)
+
+		if response.Code != http.StatusOK {
+			t.Fatalf("export status = %d, want %d", response.Code, http.StatusOK)
+		}
+		if got := response.Header().Get("Content-Type"); got != ndjsonContentType {
+			t.Errorf("export Content-Type = %q, want %q", got, ndjsonContentType)
+		}
+
+		want := []int{auditFlushEvery, 2 * auditFlushEvery, len(ids)}
+		if len(response.flushedAt) != len(want) {
+			t.Fatalf("flushed at lines %v, want %v", response.flushedAt, want)
+		}
+		for i := range want {
+			if response.flushedAt[i] != want[i] {
+				t.Fatalf("flushed at lines %v, want %v", response.flushedAt, want)
+			}
+		}
+
+		scanner := bufio.NewScanner(response.Body)
+		for i := 0; scanner.Scan(); i++ {
+			var event AuditEventResponse
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+				t.Fatalf("line %d: %v", i, err)
+			}
+			if event.ID != ids[i] {
+				t.Fatalf("line %d id = %s, want %s", i, event.ID, ids[i])
+			}
+		}
+	})
+}
+
+func TestAuditExportHonorsFilters(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		start := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+		router := newTestRouterWithConfig(t, b, withAudit, withTestClock(testutil.NewFakeClock(start)))
+		ids := recordAuditEvents(t, b.store, start, 10)
+
+		query := url.Values{"since": {start.Add(4 * time.Second).Format(time.RFC3339)}, "limit": {"3"}}
+		request := httptest.NewRequest(http.MethodGet, "/api/admin/audit?"+query.Encode(), nil)
+		request.Header.Set("Authorization", "Bearer "+auditTestToken)
+		request.Header.Set("Accept", ndjsonContentType)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+
+		lines := bytes.Split(bytes.TrimSpace(response.Body.Bytes()), []byte("\n"))
+		if response.Code != http.StatusOK || len(lines) != 3 {
+			t.Fatalf("export = %d with %d lines, want 200 with 3", response.Code, len(lines))
+		}
+		var first AuditEventResponse
+		if err := json.Unmarshal(lines[0], &first); err != nil || first.ID != ids[4] {
+			t.Errorf("first exported event = %+v, %v; want %s", first, err, ids[4])
+		}
+
+		// An empty export is still a 200
+		request = httptest.NewRequest(http.MethodGet, "/api/admin/audit?namespace=none", nil)
+		request.Header.Set("Authorization", "Bearer "+auditTestToken)
+		request.Header.Set("Accept", ndjsonContentType)
+		response = httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		if response.Code != http.StatusOK || response.Body.Len() != 0 {
+			t.Errorf("empty export = %d %q, want 200 with no body", response.Code, response.Body)
+		}
+	})
+}
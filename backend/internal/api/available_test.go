@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ots-backend/internal/models"
+	"ots-backend/internal/testutil"
+)
+
+func TestAvailableAfter(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		clk := testutil.NewFakeClock(time.Now().Truncate(time.Second))
+		router := newTestRouterWithConfig(t, b, withAudit, withTestClock(clk))
+		release := clk.Now().Add(10 * time.Minute)
+
+		for _, availableAfter := range []string{`600`, `"` + release.Format(time.RFC3339) + `"`} {
+			clk.Set(release.Add(-10 * time.Minute))
+			req := getMockCreateSecretRequest(nil)
+			req.AvailableAfter = json.RawMessage(availableAfter)
+			created := createTestSecretResponse(t, router, req)
+			if created.AvailableAfter == nil || !created.AvailableAfter.Equal(release) {
+				t.Fatalf("available_after %s: created available_after = %v, want %v", availableAfter, created.AvailableAfter, release)
+			}
+
+			// Before the release reads are refused and nothing is consumed
+			for _, wait := range []string{"600", "1"} {
+				for _, method := range []string{http.MethodHead, http.MethodGet} {
+					response := httptest.NewRecorder()
+					router.ServeHTTP(response, httptest.NewRequest(method, "/api/secrets/"+created.ID, nil))
+					if response.Code != http.StatusForbidden || response.Header().Get("Retry-After") != wait {
+						t.Fatalf("%s before release = %d with Retry-After %q, want %d with %q", method, response.Code, response.Header().Get("Retry-After"), http.StatusForbidden, wait)
+					}
+					if method == http.MethodHead {
+						continue
+					}
+					var body models.ErrorResponse
+					json.NewDecoder(response.Body).Decode(&body)
+					if body.Code != "not_yet_available" || body.AvailableAfter == nil || !body.AvailableAfter.Equal(release) {
+						t.Errorf("GET before release body = %+v, want not_yet_available until %v", body, release)
+					}
+				}
+				clk.Set(release.Add(-time.Second))
+			}
+
+			clk.Set(release)
+			if status := getSecretStatus(router, created.ID); status != http.StatusOK {
+				t.Fatalf("GET at release status = %d, want %d", status, http.StatusOK)
+			}
+			if status := getSecretStatus(router, created.ID); status != http.StatusNotFound {
+				t.Errorf("second GET status = %d, want %d", status, http.StatusNotFound)
+			}
+		}
+	})
+}
+
+func TestAvailableAfterValidation(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		clk := testutil.NewFakeClock(time.Now())
+		router := newTestRouterWithConfig(t, b, withAudit, withTestClock(clk))
+
+		// The mock secret expires after 15 minutes
+		for _, availableAfter := range []string{`900`, `"` + clk.Now().Add(time.Hour).Format(time.RFC3339) + `"`, `-1`, `1.5`, `"tomorrow"`, `true`} {
+			req := getMockCreateSecretRequest(nil)
+			req.AvailableAfter = json.RawMessage(availableAfter)
+			if errResp := postErrorResponse(t, router, "/api/secrets", marshalJSON(t, req)); errResp.Code != "invalid_available_after" {
+				t.Errorf("create with available_after %s code = %q, want invalid_available_after", availableAfter, errResp.Code)
+			}
+		}
+
+		// A release already past is no release
+		req := getMockCreateSecretRequest(nil)
+		req.AvailableAfter = json.RawMessage(`"` + clk.Now().Add(-time.Hour).Format(time.RFC3339) + `"`)
+		created := createTestSecretResponse(t, router, req)
+		if created.AvailableAfter != nil {
+			t.Errorf("created available_after = %v, want none for a past time", created.AvailableAfter)
+		}
+		if status := getSecretStatus(router, created.ID); status != http.StatusOK {
+			t.Errorf("GET status = %d, want %d", status, http.StatusOK)
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"ots-backend/internal/store"
+	"ots-backend/internal/store/memory"
+	"ots-backend/internal/store/sqlite"
+)
+
+// rowScanner is satisfied by both pgx.Row and *sql.Row
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// testBackend is one storage backend the handler conformance tests run against
+type testBackend struct {
+	name  string
+	store store.Store
+	// reset empties every table the handlers write to
+	reset func(t *testing.T)
+	// queryRow inspects stored state; queries use $N placeholders. It is
+	// nil for the memory backend, which has no tables to inspect.
+	queryRow func(ctx context.Context, query string, args ...any) rowScanner
+}
+
+var (
+	testBackends []*testBackend
+	// backendSetups lets backend-specific test files, such as the Postgres
+	// integration file built with -tags integration, add themselves to the
+	// matrix
+	backendSetups []func() (*testBackend, func(), error)
+)
+
+func TestMain(m *testing.M) {
+	setups := append([]func() (*testBackend, func(), error){setupMemoryBackend, setupSQLiteBackend}, backendSetups...)
+
+	var teardowns []func()
+	for _, setup := range setups {
+		backend, teardown, err := setup()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "setup test backend: %v\n", err)
+			os.Exit(1)
+		}
+		testBackends = append(testBackends, backend)
+		teardowns = append(teardowns, teardown)
+	}
+
+	code := m.Run()
+
+	for _, teardown := range teardowns {
+		teardown()
+	}
+
+	os.Exit(code)
+}
+
+// forEachBackend runs fn once per configured backend as a subtest
+func forEachBackend(t *testing.T, fn func(t *testing.T, b *testBackend)) {
+	for _, b := range testBackends {
+		t.Run(b.name, func(t *testing.T) {
+			fn(t, b)
+		})
+	}
+}
+
+func setupMemoryBackend() (*testBackend, func(), error) {
+	s := memory.New()
+
+	backend := &testBackend{
+		name:  "memory",
+		store: s,
+		// Close empties the store and leaves it usable
+		reset: func(t *testing.T) { s.Close() },
+	}
+
+	return backend, s.Close, nil
+}
+
+var placeholder = regexp.MustCompile(`\$(\d+)`)
+
+func setupSQLiteBackend() (*testBackend, func(), error) {
+	dir, err := os.MkdirTemp("", "ots-api-test")
+	if err != nil {
+		return nil, nil, fmt.Errorf("create temp dir: %w", err)
+	}
+
+	s, err := sqlite.Open(filepath.Join(dir, "ots.db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+
+	backend := &testBackend{
+		name:  "sqlite",
+		store: s,
+		reset: func(t *testing.T) {
+			t.Helper()
+			if _, err := s.DB().Exec(`DELETE FROM secret_receipts; DELETE FROM secrets; DELETE FROM audit_events; DELETE FROM dropped_work; DELETE FROM secret_size_buckets; DELETE FROM daily_stats; DELETE FROM client_reputation`); err != nil {
+				t.Fatalf("reset sqlite: %v", err)
+			}
+		},
+		queryRow: func(ctx context.Context, query string, args ...any) rowScanner {
+			return s.DB().QueryRowContext(ctx, placeholder.ReplaceAllString(query, "?$1"), args...)
+		},
+	}
+
+	teardown := func() {
+		s.Close()
+		os.RemoveAll(dir)
+	}
+
+	return backend, teardown, nil
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"ots-backend/internal/crypto"
+	"ots-backend/internal/logger"
+	"ots-backend/internal/store"
+)
+
+// Canary health check values
+const (
+	canaryPending  = "pending"
+	canaryOK       = "ok"
+	canaryDegraded = "degraded"
+)
+
+// canaryPayloadSize keeps each run to a few hundred bytes of storage work
+const canaryPayloadSize = 32
+
+// canaryTTL bounds how long a secret stranded by a failed run survives
+// before the cleanup worker sweeps it
+const canaryTTL = time.Minute
+
+// canaryState tracks recent canary outcomes for the health check
+type canaryState struct {
+	mu                  sync.Mutex
+	ran                 bool
+	lastSuccess         time.Time
+	consecutiveFailures int
+}
+
+// canaryTimings are the durations of one run's steps
+type canaryTimings struct {
+	create, consume, verify time.Duration
+}
+
+// StartCanary creates, consumes and verifies a small secret every
+// CANARY_INTERVAL until ctx is done. Runs go through the store and crypto
+// directly, not HTTP, under store.CanaryIDPrefix so they stay out of user
+// stats. After CANARY_FAILURE_THRESHOLD consecutive failures the canary
+// check reports degraded and, with CANARY_READINESS, readiness fails until a
+// run succeeds.
+func (h *Handler) StartCanary(ctx context.Context) {
+	h.canary = &canaryState{}
+
+	go func() {
+		ticker := time.NewTicker(h.config().CanaryInterval)
+		defer ticker.Stop()
+
+		for {
+			h.runCanary(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// runCanary performs one run and records its outcome
+func (h *Handler) runCanary(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, h.config().CanaryInterval)
+	defer cancel()
+
+	timings, err := h.canaryRun(ctx)
+	RecordCanaryRun(timings.create, timings.consume, timings.verify, err == nil)
+
+	h.canary.mu.Lock()
+	h.canary.ran = true
+	if err == nil {
+		h.canary.lastSuccess = h.clock.Now()
+		h.canary.consecutiveFailures = 0
+	} else {
+		h.canary.consecutiveFailures++
+	}
+	failures := h.canary.consecutiveFailures
+	h.canary.mu.Unlock()
+
+	if err != nil {
+		logger.Warn("canary run failed", "error", err, "consecutive_failures", failures)
+		return
+	}
+	logger.Debug("canary run passed", "create", timings.create, "consume", timings.consume, "verify", timings.verify)
+}
+
+// canaryRun creates a secret the way the create handler stores one,
+// consumes it and verifies the result
+func (h *Handler) canaryRun(ctx context.Context) (canaryTimings, error) {
+	var timings canaryTimings
+
+	secret, payload, iv, err := h.newCanarySecret()
+	if err != nil {
+		return timings, err
+	}
+	id := secret.ID
+
+	start := time.Now()
+	if err := h.store.Create(ctx, secret); err != nil {
+		return timings, fmt.Errorf("create canary: %w", err)
+	}
+	timings.create = time.Since(start)
+
+	start = time.Now()
+	got, err := h.store.Consume(ctx, id, store.ConsumeOptions{Now: h.clock.Now(), Open: h.unwrapSecret})
+	timings.consume = time.Since(start)
+	if err != nil {
+		// Don't leave a readable canary behind until it expires
+		h.store.Burn(ctx, id)
+		return timings, fmt.Errorf("consume canary: %w", err)
+	}
+
+	start = time.Now()
+	err = h.verifyCanary(ctx, got, payload, iv)
+	timings.verify = time.Since(start)
+	return timings, err
+}
+
+// newCanarySecret builds a small random secret the way the create handler
+// stores one, under store.CanaryIDPrefix, and returns it with the payload
+// and IV it must read back as
+func (h *Handler) newCanarySecret() (secret *store.Secret, payload, iv []byte, err error) {
+	id, err := crypto.GenerateSecretID()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("generate canary ID: %w", err)
+	}
+
+	payload = make([]byte, canaryPayloadSize)
+	iv = make([]byte, 12)
+	rand.Read(payload)
+	rand.Read(iv)
+
+	now := h.clock.Now()
+	secret = &store.Secret{
+		ID:            store.CanaryIDPrefix + id,
+		Ciphertext:    payload,
+		IV:            iv,
+		ExpiresAt:     now.Add(canaryTTL),
+		CreatedAt:     now,
+		BurnAfterRead: true,
+	}
+	if h.config().CryptoShredding {
+		secret.Ciphertext, secret.DataKey, err = crypto.WrapWithDataKey(payload)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("wrap canary: %w", err)
+		}
+		if err := h.sealDataKey(secret); err != nil {
+			return nil, nil, nil, fmt.Errorf("wrap canary: %w", err)
+		}
+	}
+	return secret, payload, iv, nil
+}
+
+// verifyCanary checks that the canary read back its own bytes and that a
+// second read finds nothing
+func (h *Handler) verifyCanary(ctx context.Context, got *store.Secret, payload, iv []byte) error {
+	if !bytes.Equal(got.Ciphertext, payload) || !bytes.Equal(got.IV, iv) {
+		return errors.New("canary read back different bytes")
+	}
+	if _, err := h.store.Consume(ctx, got.ID, store.ConsumeOptions{Now: h.clock.Now()}); !errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("second canary read returned %v, want not found", err)
+	}
+	return nil
+}
+
+// canaryCheck returns the canary health check value, degraded once the
+// failure threshold is reached, and when a run last succeeded, zero before
+// the first success
+func (h *Handler) canaryCheck() (string, time.Time) {
+	h.canary.mu.Lock()
+	defer h.canary.mu.Unlock()
+
+	switch {
+	case !h.canary.ran:
+		return canaryPending, h.canary.lastSuccess
+	case h.canary.consecutiveFailures >= h.config().CanaryFailureThreshold:
+		return canaryDegraded, h.canary.lastSuccess
+	default:
+		return canaryOK, h.canary.lastSuccess
+	}
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/store"
+	"ots-backend/internal/testutil"
+)
+
+// faultyStore injects canary failures into a real store
+type faultyStore struct {
+	store.Store
+
+	mu         sync.Mutex
+	failCreate bool
+	corrupt    bool
+}
+
+func (s *faultyStore) set(failCreate, corrupt bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failCreate, s.corrupt = failCreate, corrupt
+}
+
+func (s *faultyStore) Create(ctx context.Context, secret *store.Secret) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failCreate {
+		return errors.New("injected create failure")
+	}
+	return s.Store.Create(ctx, secret)
+}
+
+func (s *faultyStore) Consume(ctx context.Context, id string, opts store.ConsumeOptions) (*store.Secret, error) {
+	secret, err := s.Store.Consume(ctx, id, opts)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil && s.corrupt {
+		secret.Ciphertext[0] ^= 0xFF
+	}
+	return secret, err
+}
+
+// readiness fetches the readiness probe and decodes its body
+func readiness(t *testing.T, router http.Handler) (int, HealthCheckResponse) {
+	t.Helper()
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/health/ready", nil))
+	var resp HealthCheckResponse
+	if err := json.NewDecoder(response.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode health response: %v", err)
+	}
+	return response.Code, resp
+}
+
+func TestCanaryFailureAndRecovery(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		for _, shredding := range []bool{false, true} {
+			b.reset(t)
+
+			faulty := &faultyStore{Store: b.store}
+			clk := testutil.NewFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+			handler := NewHandler(faulty, &config.Config{
+				CryptoShredding:        shredding,
+				CanaryInterval:         time.Minute,
+				CanaryFailureThreshold: 2,
+				CanaryReadiness:        true,
+			})
+			handler.SetClock(clk)
+			handler.checks = nil
+			handler.canary = &canaryState{}
+			handler.readiness.Advance(ReadinessReady)
+
+			router := chi.NewRouter()
+			router.Mount("/api", handler.Routes())
+			spec := withSpecValidation(t, handler, router)
+
+			expect := func(step string, wantCode int, wantCanary, wantAge string) {
+				t.Helper()
+				code, resp := readiness(t, spec)
+				if code != wantCode || resp.Checks["canary"] != wantCanary || resp.Checks["canary_last_success_age"] != wantAge {
+					t.Fatalf("shredding=%v %s: readiness = %d canary=%q age=%q, want %d canary=%q age=%q",
+						shredding, step, code, resp.Checks["canary"], resp.Checks["canary_last_success_age"], wantCode, wantCanary, wantAge)
+				}
+			}
+
+			expect("before the first run", http.StatusOK, canaryPending, "")
+
+			before := GetMetrics()
+			handler.runCanary(context.Background())
+			expect("after a good run", http.StatusOK, canaryOK, "0s")
+
+			// One failure stays under the threshold
+			clk.Advance(time.Minute)
+			faulty.set(true, false)
+			handler.runCanary(context.Background())
+			expect("after one failure", http.StatusOK, canaryOK, "1m0s")
+
+			// Corrupted reads count as failures too, and take readiness down
+			clk.Advance(time.Minute)
+			faulty.set(false, true)
+			handler.runCanary(context.Background())
+			expect("after two failures", http.StatusServiceUnavailable, canaryDegraded, "2m0s")
+
+			clk.Advance(time.Minute)
+			faulty.set(false, false)
+			handler.runCanary(context.Background())
+			expect("after recovery", http.StatusOK, canaryOK, "0s")
+
+			after := GetMetrics()
+			if runs, failures := after.CanaryRuns-before.CanaryRuns, after.CanaryFailures-before.CanaryFailures; runs != 4 || failures != 2 {
+				t.Errorf("shredding=%v: canary runs/failures grew by %d/%d, want 4/2", shredding, runs, failures)
+			}
+
+			// Nothing the canary wrote shows up as an active secret
+			if n, err := b.store.CountActive(context.Background()); err != nil || n != 0 {
+				t.Errorf("shredding=%v: CountActive() = %d, %v; want 0, nil", shredding, n, err)
+			}
+		}
+	})
+}
+
+func TestCanaryDegradesWithoutReadiness(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		handler := NewHandler(&faultyStore{Store: b.store, failCreate: true}, &config.Config{
+			CanaryInterval:         time.Minute,
+			CanaryFailureThreshold: 1,
+		})
+		handler.checks = nil
+		handler.canary = &canaryState{}
+		handler.readiness.Advance(ReadinessReady)
+		handler.runCanary(context.Background())
+
+		router := chi.NewRouter()
+		router.Mount("/api", handler.Routes())
+		code, resp := readiness(t, router)
+		if code != http.StatusOK || resp.Status != "degraded" || resp.Checks["canary"] != canaryDegraded {
+			t.Fatalf("readiness = %d %q canary=%q, want 200 degraded canary=degraded", code, resp.Status, resp.Checks["canary"])
+		}
+		if _, ok := resp.Checks["canary_last_success_age"]; ok {
+			t.Errorf("canary_last_success_age = %q before any success", resp.Checks["canary_last_success_age"])
+		}
+	})
+}
+
+func TestStartCanaryRunsImmediately(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		handler := NewHandler(b.store, &config.Config{CanaryInterval: time.Hour, CanaryFailureThreshold: 3})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		handler.StartCanary(ctx)
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			result, _ := handler.canaryCheck()
+			if result == canaryOK {
+				break
+			}
+			if result != canaryPending || time.Now().After(deadline) {
+				t.Fatalf("canary check = %q, want ok soon after start", result)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"ots-backend/internal/capacity"
+	"ots-backend/internal/logger"
+	"ots-backend/internal/store"
+)
+
+// capacityWindow is how much traffic the capacity projection observes
+const capacityWindow = 24 * time.Hour
+
+// CapacityResponse is the steady state the last day's traffic leads to
+type CapacityResponse struct {
+	ObservedFrom         time.Time `json:"observed_from"`
+	ObservedTo           time.Time `json:"observed_to"`
+	Created              int64     `json:"created"`
+	CreatesPerHour       float64   `json:"creates_per_hour"`
+	ConsumedFraction     float64   `json:"consumed_fraction"`
+	MeanTTLSeconds       int64     `json:"mean_ttl_seconds"`
+	MeanSizeBytes        float64   `json:"mean_size_bytes"`
+	SweepIntervalSeconds int64     `json:"sweep_interval_seconds"`
+	CryptoShredding      bool      `json:"crypto_shredding"`
+	SteadyStateRows      float64   `json:"steady_state_rows"`
+	SteadyStateRowsMax   float64   `json:"steady_state_rows_upper_bound"`
+	StorageBytes         float64   `json:"storage_bytes"`
+	StorageBytesMax      float64   `json:"storage_bytes_upper_bound"`
+	DeletionsPerSweep    float64   `json:"deletions_per_sweep"`
+	DeletionsPerHour     float64   `json:"deletions_per_hour"`
+	Caveats              []string  `json:"caveats"`
+}
+
+// Capacity handles GET /api/admin/capacity, projecting table size and
+// cleanup load from the last day of aggregate stats
+func (h *Handler) Capacity(w http.ResponseWriter, r *http.Request) {
+	now := h.clock.Now()
+	days, err := h.store.DailyStats(r.Context(), store.StatsDay(now.Add(-capacityWindow)), store.StatsDay(now))
+	if err != nil {
+		logger.Error("capacity: daily stats query failed", "error", err)
+		h.respondStoreError(w, err, "database error")
+		return
+	}
+	buckets, err := h.store.SizeBuckets(r.Context())
+	if err != nil {
+		logger.Error("capacity: size buckets query failed", "error", err)
+		h.respondStoreError(w, err, "database error")
+		return
+	}
+
+	cfg := h.config()
+	settings := capacity.Settings{
+		SweepInterval:   cfg.CleanupInterval,
+		CryptoShredding: cfg.CryptoShredding,
+		DefaultTTL:      h.policy().DefaultTTL,
+	}
+	obs := capacity.Observe(days, buckets, now, capacityWindow)
+	p := capacity.Project(obs, settings)
+
+	caveats := p.Caveats
+	if caveats == nil {
+		caveats = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CapacityResponse{
+		ObservedFrom:         now.Add(-obs.Window).UTC(),
+		ObservedTo:           now.UTC(),
+		Created:              obs.Created,
+		CreatesPerHour:       p.CreatesPerHour,
+		ConsumedFraction:     p.ConsumedFraction,
+		MeanTTLSeconds:       int64(p.MeanTTL.Seconds()),
+		MeanSizeBytes:        p.MeanSizeBytes,
+		SweepIntervalSeconds: int64(settings.SweepInterval.Seconds()),
+		CryptoShredding:      settings.CryptoShredding,
+		SteadyStateRows:      p.SteadyStateRows,
+		SteadyStateRowsMax:   p.SteadyStateRowsUpperBound,
+		StorageBytes:         p.StorageBytes,
+		StorageBytesMax:      p.StorageBytesUpperBound,
+		DeletionsPerSweep:    p.DeletionsPerSweep,
+		DeletionsPerHour:     p.DeletionsPerHour,
+		Caveats:              caveats,
+	})
+}
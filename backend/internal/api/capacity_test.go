@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/testutil"
+)
+
+func TestCapacity(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		clk := testutil.NewFakeClock(time.Date(2026, 5, 14, 6, 0, 0, 0, time.UTC))
+		cfg := auditTestConfig()
+		cfg.CleanupInterval = 10 * time.Minute
+		handler := NewHandler(b.store, cfg)
+		handler.SetClock(clk)
+		router := chi.NewRouter()
+		router.Mount("/api", handler.Routes())
+		validated := withSpecValidation(t, handler, router)
+
+		// Four 15-minute secrets, one of them read
+		read := createTestSecret(t, validated, getMockCreateSecretRequest(nil))
+		for range 3 {
+			createTestSecret(t, validated, getMockCreateSecretRequest(nil))
+		}
+		validated.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/secrets/"+read, nil))
+
+		response := adminRequest(validated, http.MethodGet, "/api/admin/capacity")
+		if response.Code != http.StatusOK {
+			t.Fatalf("capacity status = %d, want %d: %s", response.Code, http.StatusOK, response.Body)
+		}
+		var projection CapacityResponse
+		if err := json.NewDecoder(response.Body).Decode(&projection); err != nil {
+			t.Fatalf("decode capacity: %v", err)
+		}
+
+		// Yesterday and today so far are observed
+		if want := time.Date(2026, 5, 13, 0, 0, 0, 0, time.UTC); !projection.ObservedFrom.Equal(want) {
+			t.Errorf("observed_from = %v, want %v", projection.ObservedFrom, want)
+		}
+		if projection.Created != 4 || projection.ConsumedFraction != 0.25 || projection.MeanTTLSeconds != 900 {
+			t.Errorf("capacity = %+v, want 4 created, a quarter read and a 900s mean TTL", projection)
+		}
+		// Three of every four creates over 30 hours expire, staying 15 minutes
+		// plus half a 10-minute sweep
+		want := 4.0 / 30 * 0.75 * (0.25 + 5.0/60)
+		if math.Abs(projection.SteadyStateRows-want) > 1e-9 {
+			t.Errorf("steady_state_rows = %v, want %v", projection.SteadyStateRows, want)
+		}
+		if len(projection.Caveats) == 0 {
+			t.Error("capacity over four creates has no caveats")
+		}
+	})
+}
@@ -2,41 +2,61 @@ package api
 
 import (
 	"runtime"
-	"syscall"
 )
 
+// Resource check statuses
+const (
+	checkHealthy   = "healthy"
+	checkDegraded  = "degraded"
+	checkUnhealthy = "unhealthy"
+	checkUnknown   = "unknown"
+)
+
+// resourceCheck is a named host-level check reported in the health response
+type resourceCheck struct {
+	name  string
+	check func() string
+}
+
+// defaultResourceChecks returns the disk and memory checks
+func (h *Handler) defaultResourceChecks() []resourceCheck {
+	return []resourceCheck{
+		{name: "disk", check: h.checkDiskSpace},
+		{name: "memory", check: h.checkMemory},
+	}
+}
+
 // checkDiskSpace checks the filesystem disk usage.
 // Returns "healthy", "degraded", or "unhealthy" based on available space percentage.
 func (h *Handler) checkDiskSpace() string {
-	// Get filesystem statistics for the root directory
-	var stat syscall.Statfs_t
-	err := syscall.Statfs("/", &stat)
+	path := h.config().HealthDiskPath
+	if path == "" {
+		path = "/"
+	}
+
+	usagePercent, err := diskUsagePercent(path)
+	if err == errDiskUsageUnsupported {
+		// Nothing to report on this platform; don't fail health for it
+		return checkUnknown
+	}
 	if err != nil {
 		// Unable to determine disk status
-		return "unhealthy"
+		return checkUnhealthy
 	}
 
-	// Calculate total and available space in bytes
-	total := stat.Blocks * uint64(stat.Bsize)
-	available := stat.Bavail * uint64(stat.Bsize)
-
-	// Calculate used space and usage percentage
-	used := total - available
-	usagePercent := float64(used) / float64(total) * 100
-
 	// Evaluate health status based on usage threshold
 	if usagePercent > 95 {
 		// Critical: disk is almost full
-		return "unhealthy"
+		return checkUnhealthy
 	}
 
 	if usagePercent > 80 {
 		// Warning: disk usage is high
-		return "degraded"
+		return checkDegraded
 	}
 
 	// Normal operation: plenty of disk space available
-	return "healthy"
+	return checkHealthy
 }
 
 // checkMemory checks the application memory allocation.
@@ -56,14 +76,14 @@ func (h *Handler) checkMemory() string {
 	// Evaluate health status based on allocation threshold
 	if memPercent > 95 {
 		// Critical: nearly all allocated memory in use
-		return "unhealthy"
+		return checkUnhealthy
 	}
 
 	if memPercent > 80 {
 		// Warning: significant memory allocation
-		return "degraded"
+		return checkDegraded
 	}
 
 	// Normal operation: healthy memory usage
-	return "healthy"
+	return checkHealthy
 }
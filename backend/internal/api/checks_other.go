@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !freebsd
+
+package api
+
+import "errors"
+
+var errDiskUsageUnsupported = errors.New("disk usage not supported on this platform")
+
+// diskUsagePercent is not implemented here; the disk check reports "unknown"
+func diskUsagePercent(path string) (float64, error) {
+	return 0, errDiskUsageUnsupported
+}
@@ -0,0 +1,30 @@
+//go:build linux || darwin || freebsd
+
+package api
+
+import (
+	"errors"
+	"syscall"
+)
+
+var errDiskUsageUnsupported = errors.New("disk usage not supported on this platform")
+
+// diskUsagePercent returns the used share of the filesystem holding path
+func diskUsagePercent(path string) (float64, error) {
+	// Get filesystem statistics for the configured directory
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+
+	// Calculate total and available space in bytes
+	total := uint64(stat.Blocks) * uint64(stat.Bsize)
+	available := uint64(stat.Bavail) * uint64(stat.Bsize)
+	if total == 0 {
+		return 0, errors.New("filesystem reports zero size")
+	}
+
+	// Calculate used space and usage percentage
+	used := total - available
+	return float64(used) / float64(total) * 100, nil
+}
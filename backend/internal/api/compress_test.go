@@ -0,0 +1,93 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/models"
+)
+
+// newCompressTestRouter serves the API with compression from 256 bytes. It
+// skips the spec check, which reads bodies as plain JSON.
+func newCompressTestRouter(b *testBackend, paranoid bool) http.Handler {
+	cfg := auditTestConfig()
+	cfg.CompressMinSize = 256
+	cfg.CompressBreachParanoid = paranoid
+
+	router := chi.NewRouter()
+	router.Mount("/api", NewHandler(b.store, cfg).Routes())
+	return router
+}
+
+// getGzip sends GET path accepting gzip and returns the response with its
+// body decoded
+func getGzip(t *testing.T, router http.Handler, path string) (*httptest.ResponseRecorder, []byte) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		return rec, rec.Body.Bytes()
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("GET %s: gzip.NewReader() error: %v", path, err)
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("GET %s: read gzip body: %v", path, err)
+	}
+	return rec, body
+}
+
+func TestSecretResponsesCompress(t *testing.T) {
+	ciphertext := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("large secret "), 400))
+
+	for _, tt := range []struct {
+		name       string
+		paranoid   bool
+		compressed bool
+	}{
+		{"default", false, true},
+		{"breach paranoid", true, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, func(t *testing.T, b *testBackend) {
+				b.reset(t)
+				router := newCompressTestRouter(b, tt.paranoid)
+				secretID := createTestSecret(t, router, getMockCreateSecretRequest(&createSecretOverrides{Ciphertext: &ciphertext}))
+
+				rec, body := getGzip(t, router, "/api/secrets/"+secretID)
+				if rec.Code != http.StatusOK {
+					t.Fatalf("GET status = %d, want %d", rec.Code, http.StatusOK)
+				}
+				if got := rec.Header().Get("Content-Encoding") == "gzip"; got != tt.compressed {
+					t.Errorf("secret compressed = %v, want %v", got, tt.compressed)
+				}
+				var secret models.GetSecretResponse
+				if err := json.Unmarshal(body, &secret); err != nil {
+					t.Fatalf("decode secret: %v", err)
+				}
+				if secret.Ciphertext != ciphertext {
+					t.Errorf("ciphertext does not round-trip (%d chars, want %d)", len(secret.Ciphertext), len(ciphertext))
+				}
+
+				// Responses without secret material compress either way
+				rec, body = getGzip(t, router, "/api/openapi.json")
+				if rec.Header().Get("Content-Encoding") != "gzip" || !json.Valid(body) {
+					t.Errorf("openapi.json encoding %q, valid JSON %v; want gzipped JSON", rec.Header().Get("Content-Encoding"), json.Valid(body))
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"ots-backend/internal/httpx"
+)
+
+// renderClientConfig renders the client config body and its ETag once per
+// configuration; a reload renders them again, changing the ETag
+func (h *Handler) renderClientConfig() ([]byte, string) {
+	s := h.current()
+	s.clientConfigOnce.Do(func() {
+		// ConfigPayload has only plain fields, so marshalling cannot fail
+		body, _ := json.Marshal(s.policy.Config())
+		sum := sha256.Sum256(body)
+		s.clientConfigJSON = body
+		s.clientConfigETag = `"` + hex.EncodeToString(sum[:8]) + `"`
+	})
+	return s.clientConfigJSON, s.clientConfigETag
+}
+
+// ClientConfig returns the constraints a create must respect, so frontends do
+// not hardcode them. Only policy.ConfigPayload is exposed; nothing else from
+// the server configuration is.
+func (h *Handler) ClientConfig(w http.ResponseWriter, r *http.Request) {
+	body, etag := h.renderClientConfig()
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	httpx.SetHeader(w.Header(), "ETag", etag)
+
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
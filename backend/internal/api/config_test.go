@@ -0,0 +1,101 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/db"
+	pgstore "ots-backend/internal/store/postgres"
+)
+
+func TestClientConfigReflectsInjectedConfig(t *testing.T) {
+	cfg := &config.Config{
+		MaxSecretSize:          1000,
+		DefaultTTL:             2 * time.Hour,
+		AgentDefaultTTL:        6 * time.Hour,
+		MinKeyBits:             128,
+		KeyBitsMissing:         "reject",
+		DatabaseURL:            "postgres://ots:do-not-leak@db:5432/ots",
+		AdminToken:             "admin-do-not-leak",
+		WriteRateLimitRequests: 7,
+		WriteRateLimitWindow:   time.Minute,
+	}
+	handler := NewHandler(pgstore.New(&db.DB{}), cfg)
+	mux := chi.NewRouter()
+	mux.Mount("/api", handler.Routes())
+	router := withSpecValidation(t, handler, mux)
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/config", nil))
+	if response.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", response.Code, http.StatusOK)
+	}
+
+	body := response.Body.String()
+	for _, secret := range []string{"do-not-leak", "postgres://", "rate"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("config body contains %q: %s", secret, body)
+		}
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatalf("decode config: %v", err)
+	}
+	want := map[string]any{
+		"max_secret_size":           float64(1000),
+		"min_ttl_seconds":           float64(300),
+		"max_ttl_seconds":           float64(86400),
+		"default_ttl_seconds":       float64(7200),
+		"agent_default_ttl_seconds": float64(21600),
+		"max_parts":                 float64(10),
+		"min_key_bits":              float64(128),
+		"key_bits_missing":          "reject",
+		"passphrase_supported":      true,
+		"max_views_supported":       false,
+		"slugs_supported":           false,
+		"notify_email_supported":    false,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("config = %v, want %v", got, want)
+	}
+
+	if got := response.Header().Get("Cache-Control"); !strings.HasPrefix(got, "public") {
+		t.Errorf("Cache-Control = %q, want public caching", got)
+	}
+
+	etag := response.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("ETag missing")
+	}
+
+	revalidate := httptest.NewRequest(http.MethodGet, "/api/config", nil)
+	revalidate.Header.Set("If-None-Match", etag)
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, revalidate)
+	if response.Code != http.StatusNotModified {
+		t.Errorf("revalidation status = %d, want %d", response.Code, http.StatusNotModified)
+	}
+}
+
+func TestClientConfigETagFollowsConfig(t *testing.T) {
+	etag := func(maxSize int) string {
+		_, tag := NewHandler(pgstore.New(&db.DB{}), &config.Config{MaxSecretSize: maxSize}).renderClientConfig()
+		return tag
+	}
+
+	if etag(1000) == etag(2000) {
+		t.Error("ETag unchanged when max_secret_size changes")
+	}
+	if etag(1000) != etag(1000) {
+		t.Error("ETag differs for identical config")
+	}
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"ots-backend/internal/logger"
+	"ots-backend/internal/store"
+	"ots-backend/pkg/ots"
+)
+
+const (
+	// dailyStatsDefaultDays is how many days admin stats report, ending
+	// today, when the request names no range
+	dailyStatsDefaultDays = 30
+	// dailyStatsMaxDays bounds the range one request may ask for
+	dailyStatsMaxDays = 366
+)
+
+// DailyStatsResponse is one UTC day of usage totals. Days without activity
+// are reported with zero counts, so the series has no gaps.
+type DailyStatsResponse struct {
+	Date      string `json:"date"`
+	Created   int64  `json:"created"`
+	Retrieved int64  `json:"retrieved"`
+	Burned    int64  `json:"burned"`
+	Expired   int64  `json:"expired"`
+	Bytes     int64  `json:"bytes"`
+	// TTLSeconds sums the TTLs creates chose, 0 before it was recorded
+	TTLSeconds int64 `json:"ttl_seconds"`
+	// AverageSizeBytes is Bytes over Created, 0 on days without creates
+	AverageSizeBytes float64 `json:"average_size_bytes"`
+	// ReadRate is Retrieved over Created, 0 on days without creates
+	ReadRate float64 `json:"read_rate"`
+}
+
+// countDay adds delta to today's usage totals. Failures are logged only;
+// reporting never fails the request it counts.
+func (h *Handler) countDay(ctx context.Context, delta store.DailyStats) {
+	delta.Day = h.clock.Now()
+	if err := h.store.AddDailyStats(ctx, delta); err != nil {
+		logger.Warn("failed to record daily stats", "error", err)
+	}
+}
+
+// countCreate adds one create of size bytes living ttl to today's totals.
+// Strict privacy mode leaves the bytes out, since a quiet day's total would
+// be one secret's exact size.
+func (h *Handler) countCreate(ctx context.Context, size int, ttl time.Duration) {
+	delta := store.DailyStats{Created: 1, TTLSeconds: int64(ttl.Seconds())}
+	if !h.config().StrictPrivacy {
+		delta.Bytes = int64(size)
+	}
+	h.countDay(ctx, delta)
+}
+
+// parseDailyRange reads the from and to dates, both inclusive, defaulting
+// to the last dailyStatsDefaultDays days
+func parseDailyRange(r *http.Request, now time.Time) (from, to time.Time, err error) {
+	query := r.URL.Query()
+
+	to = store.StatsDay(now)
+	if value := query.Get("to"); value != "" {
+		to, err = time.Parse(time.DateOnly, value)
+		if err != nil {
+			return from, to, ots.ErrInvalidStatsQuery
+		}
+	}
+	from = to.AddDate(0, 0, 1-dailyStatsDefaultDays)
+	if value := query.Get("from"); value != "" {
+		from, err = time.Parse(time.DateOnly, value)
+		if err != nil {
+			return from, to, ots.ErrInvalidStatsQuery
+		}
+	}
+
+	if from.After(to) || to.Sub(from) >= dailyStatsMaxDays*24*time.Hour {
+		return from, to, ots.ErrInvalidStatsQuery
+	}
+	return from, to, nil
+}
+
+// dailySeries renders days as one entry per date from from through to
+func dailySeries(days []store.DailyStats, from, to time.Time) []DailyStatsResponse {
+	byDay := make(map[time.Time]store.DailyStats, len(days))
+	for _, day := range days {
+		byDay[day.Day] = day
+	}
+
+	var series []DailyStatsResponse
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		day := byDay[date]
+		entry := DailyStatsResponse{
+			Date:       date.Format(time.DateOnly),
+			Created:    day.Created,
+			Retrieved:  day.Retrieved,
+			Burned:     day.Burned,
+			Expired:    day.Expired,
+			Bytes:      day.Bytes,
+			TTLSeconds: day.TTLSeconds,
+		}
+		if day.Created > 0 {
+			entry.AverageSizeBytes = float64(day.Bytes) / float64(day.Created)
+			entry.ReadRate = float64(day.Retrieved) / float64(day.Created)
+		}
+		series = append(series, entry)
+	}
+	return series
+}
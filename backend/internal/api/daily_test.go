@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ots-backend/internal/testutil"
+)
+
+func TestDailyStats(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		clk := testutil.NewFakeClock(time.Date(2026, 5, 13, 23, 0, 0, 0, time.UTC))
+		router := newTestRouterWithConfig(t, b, withAudit, withTestClock(clk))
+
+		// One create the day before, then three creates, a read and a burn
+		createSized(t, router, 64)
+		clk.Advance(2 * time.Hour)
+		read := createTestSecret(t, router, getMockCreateSecretRequest(nil))
+		burned := createTestSecret(t, router, getMockCreateSecretRequest(nil))
+		createTestSecret(t, router, getMockCreateSecretRequest(nil))
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/secrets/"+read, nil))
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/api/secrets/"+burned, nil))
+
+		stats := getAdminStats(t, router)
+		if len(stats.Daily) != dailyStatsDefaultDays {
+			t.Fatalf("len(daily) = %d, want %d", len(stats.Daily), dailyStatsDefaultDays)
+		}
+		yesterday, today := stats.Daily[len(stats.Daily)-2], stats.Daily[len(stats.Daily)-1]
+		if today.Date != "2026-05-14" || stats.Daily[0].Date != "2026-04-15" {
+			t.Fatalf("daily runs %s to %s, want 2026-04-15 to 2026-05-14", stats.Daily[0].Date, today.Date)
+		}
+		if yesterday.Created != 1 || yesterday.Bytes != 64 || yesterday.AverageSizeBytes != 64 {
+			t.Errorf("2026-05-13 = %+v, want one 64-byte create", yesterday)
+		}
+		if today.Created != 3 || today.Retrieved != 1 || today.Burned != 1 {
+			t.Errorf("2026-05-14 = %+v, want 3 created, 1 retrieved and 1 burned", today)
+		}
+		if today.ReadRate != 1.0/3 || today.Bytes == 0 {
+			t.Errorf("2026-05-14 read_rate = %v with %d bytes, want 1/3 and some bytes", today.ReadRate, today.Bytes)
+		}
+		if today.TTLSeconds != 3*900 {
+			t.Errorf("2026-05-14 ttl_seconds = %d, want three 15-minute TTLs", today.TTLSeconds)
+		}
+		if empty := stats.Daily[0]; empty.Created != 0 || empty.ReadRate != 0 {
+			t.Errorf("quiet day = %+v, want zeros", empty)
+		}
+
+		response := adminRequest(router, http.MethodGet, "/api/admin/stats?from=2026-05-13&to=2026-05-13")
+		if response.Code != http.StatusOK {
+			t.Fatalf("admin stats for one day status = %d, want %d", response.Code, http.StatusOK)
+		}
+	})
+}
+
+func TestDailyStatsRange(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newSizeTestRouter(t, b, auditTestConfig())
+
+		for _, query := range []string{
+			"from=2026-05-14&to=2026-05-13",
+			"from=2025-01-01&to=2026-05-13",
+			"from=14-05-2026",
+			"to=2026-05-14T00:00:00Z",
+		} {
+			response := adminRequest(router, http.MethodGet, "/api/admin/stats?"+query)
+			if response.Code != http.StatusBadRequest {
+				t.Errorf("admin stats?%s status = %d, want %d", query, response.Code, http.StatusBadRequest)
+			}
+		}
+
+		if response := adminRequest(router, http.MethodGet, "/api/admin/stats?from=2025-05-14&to=2026-05-14"); response.Code != http.StatusOK {
+			t.Errorf("admin stats over 366 days status = %d, want %d", response.Code, http.StatusOK)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	httpMiddleware "ots-backend/internal/middleware"
+)
+
+// DebugVarsResponse is the runtime state behind /debug/vars
+type DebugVarsResponse struct {
+	GoVersion  string `json:"go_version"`
+	GOMAXPROCS int    `json:"gomaxprocs"`
+	NumCPU     int    `json:"num_cpu"`
+	Goroutines int    `json:"goroutines"`
+	Uptime     string `json:"uptime"`
+	Memory     struct {
+		HeapAlloc  uint64 `json:"heap_alloc"`
+		HeapInuse  uint64 `json:"heap_inuse"`
+		Sys        uint64 `json:"sys"`
+		NumGC      uint32 `json:"num_gc"`
+		PauseTotal string `json:"pause_total"`
+	} `json:"memory"`
+	// Build is absent for binaries built without module support
+	Build *DebugBuild `json:"build,omitempty"`
+	// Config is every setting, with tokens, keys and credentials redacted
+	Config map[string]any `json:"config"`
+}
+
+// DebugBuild is what the binary records about its build, from
+// debug.ReadBuildInfo: the main module and settings such as the VCS
+// revision and GOOS
+type DebugBuild struct {
+	Path     string            `json:"path"`
+	Version  string            `json:"version"`
+	Settings map[string]string `json:"settings"`
+}
+
+// MountDebug adds profiling and runtime endpoints under /debug to r, the
+// server's root router, when DEBUG_ENDPOINTS_ENABLED is set. They sit
+// behind the admin token like /api/admin. Otherwise nothing is added and
+// the paths answer 404 like any unknown route. The setting is read once, at
+// startup.
+func (h *Handler) MountDebug(r chi.Router) {
+	if !h.config().DebugEndpointsEnabled {
+		return
+	}
+
+	r.Route("/debug", func(r chi.Router) {
+		r.Use(httpMiddleware.AdminAuth(h.config().AdminToken))
+		r.Get("/vars", h.DebugVars)
+
+		// pprof.Index finds named profiles from the path, which must start
+		// with /debug/pprof/
+		r.Get("/pprof/", pprof.Index)
+		r.Get("/pprof/cmdline", pprof.Cmdline)
+		r.Get("/pprof/profile", pprof.Profile)
+		r.HandleFunc("/pprof/symbol", pprof.Symbol)
+		r.Get("/pprof/trace", pprof.Trace)
+		r.Get("/pprof/*", pprof.Index)
+	})
+}
+
+// DebugVars reports the Go runtime, build and redacted configuration
+func (h *Handler) DebugVars(w http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	vars := DebugVarsResponse{
+		GoVersion:  runtime.Version(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		NumCPU:     runtime.NumCPU(),
+		Goroutines: runtime.NumGoroutine(),
+		Uptime:     time.Since(metrics.startTime).String(),
+		Config:     h.config().Summary(),
+	}
+	vars.Memory.HeapAlloc = mem.HeapAlloc
+	vars.Memory.HeapInuse = mem.HeapInuse
+	vars.Memory.Sys = mem.Sys
+	vars.Memory.NumGC = mem.NumGC
+	vars.Memory.PauseTotal = time.Duration(mem.PauseTotalNs).String()
+	if info, ok := debug.ReadBuildInfo(); ok {
+		vars.Build = &DebugBuild{Path: info.Main.Path, Version: info.Main.Version, Settings: make(map[string]string, len(info.Settings))}
+		for _, setting := range info.Settings {
+			vars.Build.Settings[setting.Key] = setting.Value
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(vars)
+}
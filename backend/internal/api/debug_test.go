@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/config"
+)
+
+const debugTestToken = "debug-admin-token"
+
+// newDebugTestRouter is the server's root router: the API under /api and
+// whatever MountDebug adds
+func newDebugTestRouter(t *testing.T, b *testBackend, enabled bool) http.Handler {
+	t.Helper()
+
+	handler := NewHandler(b.store, &config.Config{
+		MaxSecretSize:         32768,
+		AdminToken:            debugTestToken,
+		DatabaseURL:           "postgres://ots:hunter2@db/ots",
+		DebugEndpointsEnabled: enabled,
+	})
+	router := chi.NewRouter()
+	router.Mount("/api", handler.Routes())
+	handler.MountDebug(router)
+	return router
+}
+
+func getDebug(router http.Handler, path, token string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
+
+var debugPaths = []string{"/debug/vars", "/debug/pprof/", "/debug/pprof/cmdline", "/debug/pprof/goroutine", "/debug/pprof/heap"}
+
+func TestDebugEndpointsAbsentByDefault(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		router := newDebugTestRouter(t, b, false)
+		for _, path := range debugPaths {
+			if response := getDebug(router, path, debugTestToken); response.Code != http.StatusNotFound {
+				t.Errorf("GET %s with the admin token while disabled = %d, want %d", path, response.Code, http.StatusNotFound)
+			}
+		}
+	})
+}
+
+func TestDebugEndpointsNeedAdminToken(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		router := newDebugTestRouter(t, b, true)
+		for _, path := range debugPaths {
+			if response := getDebug(router, path, ""); response.Code != http.StatusUnauthorized {
+				t.Errorf("GET %s without a token = %d, want %d", path, response.Code, http.StatusUnauthorized)
+			}
+			if response := getDebug(router, path, "wrong"); response.Code != http.StatusUnauthorized {
+				t.Errorf("GET %s with a wrong token = %d, want %d", path, response.Code, http.StatusUnauthorized)
+			}
+			if response := getDebug(router, path, debugTestToken); response.Code != http.StatusOK {
+				t.Errorf("GET %s with the admin token = %d, want %d: %s", path, response.Code, http.StatusOK, response.Body)
+			}
+		}
+	})
+}
+
+func TestDebugVars(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		router := newDebugTestRouter(t, b, true)
+
+		response := getDebug(router, "/debug/vars", debugTestToken)
+		var vars DebugVarsResponse
+		if err := json.NewDecoder(response.Body).Decode(&vars); err != nil {
+			t.Fatalf("decode /debug/vars: %v", err)
+		}
+		if vars.GOMAXPROCS != runtime.GOMAXPROCS(0) || vars.GoVersion != runtime.Version() || vars.Goroutines == 0 {
+			t.Errorf("runtime = %+v, want this process's GOMAXPROCS, Go version and goroutines", vars)
+		}
+		if vars.Build == nil || vars.Build.Settings["GOOS"] == "" {
+			t.Errorf("build = %+v, want the binary's build settings", vars.Build)
+		}
+		for name, want := range map[string]any{"AdminToken": config.Redacted, "DatabaseURL": config.Redacted, "MaxSecretSize": float64(32768)} {
+			if got := vars.Config[name]; got != want {
+				t.Errorf("config %s = %v, want %v", name, got, want)
+			}
+		}
+	})
+}
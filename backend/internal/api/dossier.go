@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/crypto"
+	"ots-backend/internal/dossier"
+	"ots-backend/internal/logger"
+	"ots-backend/internal/store"
+	"ots-backend/pkg/ots"
+)
+
+// SetDossierKeyring replaces the per-process keyring that signs support
+// dossiers, so dossiers verify after a restart and across replicas; call it
+// before Routes
+func (h *Handler) SetDossierKeyring(k *crypto.Keyring) {
+	h.dossierKeys = k
+}
+
+// SecretDossier returns a signed dossier of everything recorded about one
+// secret: whether it is still stored, its read receipt and its audit events,
+// including reports. Payload fields are never read. Generating a dossier is
+// itself audited under the admin token's label.
+func (h *Handler) SecretDossier(w http.ResponseWriter, r *http.Request) {
+	secretID := chi.URLParam(r, "id")
+	if err := h.validateSecretID(secretID); err != nil {
+		h.respondServiceError(w, ots.ErrInvalidSecretID)
+		return
+	}
+
+	d, err := h.buildDossier(r.Context(), secretID)
+	if err != nil {
+		logger.Error("dossier: query failed", "error", err)
+		h.respondStoreError(w, err, "database error")
+		return
+	}
+
+	signed, err := dossier.Sign(d, h.dossierKeys)
+	if err != nil {
+		logger.Error("dossier: sign failed", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to sign dossier")
+		return
+	}
+
+	h.appendAudit(r.Context(), &store.AuditEvent{
+		Type:         store.AuditSecretDossierGenerated,
+		SecretIDHash: d.SecretIDHash,
+		Actor:        h.config().AdminTokenLabel,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(signed)
+}
+
+// buildDossier gathers the stored flag, read receipt and audit events for
+// secretID
+func (h *Handler) buildDossier(ctx context.Context, secretID string) (*dossier.Dossier, error) {
+	d := &dossier.Dossier{
+		Version:      dossier.Version,
+		SecretIDHash: store.HashSecretID(secretID),
+		GeneratedAt:  h.clock.Now().UTC(),
+		Events:       []dossier.Event{},
+	}
+
+	_, err := h.store.ManagementTokenHash(ctx, secretID)
+	switch {
+	case err == nil:
+		d.Stored = true
+	case !errors.Is(err, store.ErrNotFound):
+		return nil, err
+	}
+
+	receipt, err := h.store.Receipt(ctx, secretID)
+	switch {
+	case err == nil:
+		d.Receipt = &dossier.Receipt{
+			ConsumedAt:   receipt.ConsumedAt.UTC(),
+			NetworkClass: receipt.NetworkClass,
+			Acknowledged: receipt.Acknowledged,
+		}
+	case !errors.Is(err, store.ErrNotFound):
+		return nil, err
+	}
+
+	err = h.store.ScanAudit(ctx, store.AuditFilter{SecretIDHashPrefix: d.SecretIDHash}, func(event *store.AuditEvent) error {
+		d.Events = append(d.Events, dossier.Event{
+			ID:           event.ID,
+			Type:         event.Type,
+			OccurredAt:   event.OccurredAt.UTC(),
+			Namespace:    event.Namespace,
+			NetworkClass: event.NetworkClass,
+			Reason:       event.Reason,
+			Actor:        event.Actor,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/crypto"
+	"ots-backend/internal/dossier"
+	"ots-backend/internal/netclass"
+	"ots-backend/internal/store"
+)
+
+// newDossierTestRouter audits events, records receipts and signs dossiers
+// with keys
+func newDossierTestRouter(t *testing.T, b *testBackend, keys *crypto.Keyring) http.Handler {
+	t.Helper()
+
+	cfg := auditTestConfig()
+	cfg.ReportRateLimitRequests = 10
+	cfg.ReportRateLimitWindow = cfg.ReadRateLimitWindow
+	cfg.AdminTokenLabel = "support-desk"
+	handler := NewHandler(b.store, cfg)
+	handler.SetClassifier(netclass.New(nil, ""))
+	handler.SetDossierKeyring(keys)
+
+	router := chi.NewRouter()
+	router.Mount("/api", handler.Routes())
+	return withSpecValidation(t, handler, router)
+}
+
+func getDossier(router http.Handler, secretID string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, "/api/admin/secrets/"+secretID+"/dossier", nil)
+	request.Header.Set("Authorization", "Bearer "+auditTestToken)
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
+
+func TestSecretDossierLifecycle(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		keys, _ := crypto.NewKeyring(bytes.Repeat([]byte{0x07}, crypto.MinKeyringKeySize))
+		router := newDossierTestRouter(t, b, keys)
+
+		create := getMockCreateSecretRequest(nil)
+		secretID := createTestSecret(t, router, create)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/"+secretID, nil))
+		if response.Code != http.StatusOK {
+			t.Fatalf("GetSecret() status = %d, want %d", response.Code, http.StatusOK)
+		}
+		if response := postReport(router, secretID, "suspected_interception"); response.Code != http.StatusAccepted {
+			t.Fatalf("report status = %d, want %d", response.Code, http.StatusAccepted)
+		}
+
+		response = getDossier(router, secretID)
+		if response.Code != http.StatusOK {
+			t.Fatalf("dossier status = %d, want %d: %s", response.Code, http.StatusOK, response.Body)
+		}
+		raw := response.Body.String()
+		for _, leaked := range []string{secretID, create.Ciphertext, `"ciphertext"`, `"iv"`, `"salt"`, `"data_key"`} {
+			if strings.Contains(raw, leaked) {
+				t.Errorf("dossier contains %q: %s", leaked, raw)
+			}
+		}
+
+		var signed dossier.Signed
+		if err := json.Unmarshal(response.Body.Bytes(), &signed); err != nil {
+			t.Fatalf("decode signed dossier: %v", err)
+		}
+		d, err := dossier.Verify(&signed, keys)
+		if err != nil {
+			t.Fatalf("Verify() error: %v", err)
+		}
+
+		if d.SecretIDHash != store.HashSecretID(secretID) || d.Stored {
+			t.Errorf("dossier = %+v, want the secret's hash and not stored", d)
+		}
+		if d.Receipt == nil || d.Receipt.ConsumedAt.IsZero() {
+			t.Errorf("dossier receipt = %+v, want the read receipt", d.Receipt)
+		}
+		var types []string
+		for _, event := range d.Events {
+			types = append(types, event.Type)
+		}
+		want := []string{store.AuditSecretCreated, store.AuditSecretConsumed, store.AuditSecretReported}
+		if strings.Join(types, ",") != strings.Join(want, ",") {
+			t.Errorf("dossier events = %v, want %v", types, want)
+		}
+
+		// Generating the dossier is audited under the token's label
+		page := getAuditPage(t, router, url.Values{"type": {store.AuditSecretDossierGenerated}})
+		if len(page.Events) != 1 || page.Events[0].Actor != "support-desk" || page.Events[0].SecretIDHash != d.SecretIDHash {
+			t.Errorf("dossier_generated events = %+v, want one by support-desk", page.Events)
+		}
+	})
+}
+
+func TestSecretDossierStoredSecret(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		keys, _ := crypto.EphemeralKeyring()
+		router := newDossierTestRouter(t, b, keys)
+
+		secretID := createTestSecret(t, router, getMockCreateSecretRequest(nil))
+		response := getDossier(router, secretID)
+		if response.Code != http.StatusOK {
+			t.Fatalf("dossier status = %d, want %d", response.Code, http.StatusOK)
+		}
+
+		var signed dossier.Signed
+		json.Unmarshal(response.Body.Bytes(), &signed)
+		d, err := dossier.Verify(&signed, keys)
+		if err != nil {
+			t.Fatalf("Verify() error: %v", err)
+		}
+		if !d.Stored || d.Receipt != nil || len(d.Events) != 1 {
+			t.Errorf("dossier = %+v, want stored with no receipt and the create event", d)
+		}
+	})
+}
+
+func TestSecretDossierRequiresAdmin(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		keys, _ := crypto.EphemeralKeyring()
+		router := newDossierTestRouter(t, b, keys)
+		secretID := createTestSecret(t, router, getMockCreateSecretRequest(nil))
+
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/admin/secrets/"+secretID+"/dossier", nil))
+		if response.Code != http.StatusUnauthorized {
+			t.Errorf("dossier without token status = %d, want %d", response.Code, http.StatusUnauthorized)
+		}
+
+		if response := getDossier(router, "not-an-id"); response.Code != http.StatusBadRequest {
+			t.Errorf("dossier with bad ID status = %d, want %d", response.Code, http.StatusBadRequest)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/dropped"
+	"ots-backend/internal/store"
+	"ots-backend/internal/store/memory"
+)
+
+// abandoningStore fails audit writes the way a store does when the
+// server shuts down underneath them
+type abandoningStore struct {
+	store.Store
+	abandon atomic.Bool
+}
+
+func (s *abandoningStore) RecordAudit(ctx context.Context, event *store.AuditEvent) error {
+	if s.abandon.Load() {
+		return fmt.Errorf("insert audit event: %w", context.Canceled)
+	}
+	return s.Store.RecordAudit(ctx, event)
+}
+
+func getAdminStats(t *testing.T, router http.Handler) AdminStatsResponse {
+	t.Helper()
+
+	response := adminRequest(router, http.MethodGet, "/api/admin/stats")
+	if response.Code != http.StatusOK {
+		t.Fatalf("admin stats status = %d, want %d: %s", response.Code, http.StatusOK, response.Body)
+	}
+	var stats AdminStatsResponse
+	if err := json.NewDecoder(response.Body).Decode(&stats); err != nil {
+		t.Fatalf("decode admin stats: %v", err)
+	}
+	return stats
+}
+
+func TestDroppedAuditWritesAreAccounted(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		// Losses other tests left pending must not land in this store
+		if err := dropped.Flush(context.Background(), memory.New(), time.Now()); err != nil {
+			t.Fatalf("Flush() error: %v", err)
+		}
+
+		st := &abandoningStore{Store: b.store}
+		handler := NewHandler(st, auditTestConfig())
+		mux := chi.NewRouter()
+		mux.Mount("/api", handler.Routes())
+		router := withSpecValidation(t, handler, mux)
+
+		if stats := getAdminStats(t, router); len(stats.DroppedWork) != 0 {
+			t.Fatalf("dropped_work_24h before any loss = %+v, want none", stats.DroppedWork)
+		}
+
+		before := GetMetrics().DroppedWork["audit_event/shutdown"]
+		st.abandon.Store(true)
+		createTestSecret(t, router, getMockCreateSecretRequest(nil))
+		createTestSecret(t, router, getMockCreateSecretRequest(nil))
+		st.abandon.Store(false)
+
+		if got := GetMetrics().DroppedWork["audit_event/shutdown"] - before; got != 2 {
+			t.Errorf("dropped_work_total audit_event/shutdown grew by %d, want 2", got)
+		}
+
+		stats := getAdminStats(t, router)
+		if len(stats.DroppedWork) != 1 {
+			t.Fatalf("dropped_work_24h = %+v, want one summary", stats.DroppedWork)
+		}
+		loss := stats.DroppedWork[0]
+		if loss.Kind != dropped.KindAuditEvent || loss.Reason != dropped.ReasonShutdown || loss.Count != 2 || loss.LastRecordedAt.IsZero() {
+			t.Errorf("dropped_work_24h[0] = %+v, want 2 audit events dropped on shutdown", loss)
+		}
+
+		// The summary is not counted twice on the next read
+		if stats := getAdminStats(t, router); len(stats.DroppedWork) != 1 || stats.DroppedWork[0].Count != 2 {
+			t.Errorf("second dropped_work_24h = %+v, want unchanged", stats.DroppedWork)
+		}
+	})
+}
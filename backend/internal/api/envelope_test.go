@@ -0,0 +1,88 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/crypto"
+	"ots-backend/internal/models"
+)
+
+// newEnvelopeTestRouter serves the API in crypto-shredding mode, wrapping
+// data keys with keys when it is set
+func newEnvelopeTestRouter(t *testing.T, b *testBackend, keys *crypto.EnvelopeKeys) http.Handler {
+	t.Helper()
+
+	cfg := auditTestConfig()
+	cfg.CryptoShredding = true
+	handler := NewHandler(b.store, cfg)
+	if keys != nil {
+		handler.SetEnvelopeKeys(keys)
+	}
+
+	router := chi.NewRouter()
+	router.Mount("/api", handler.Routes())
+	return withSpecValidation(t, handler, router)
+}
+
+func TestEnvelopeKeyRotation(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{0x01}, 32)
+	newKey := bytes.Repeat([]byte{0x02}, 32)
+	old, err := crypto.NewEnvelopeKeys(oldKey)
+	if err != nil {
+		t.Fatalf("NewEnvelopeKeys() error: %v", err)
+	}
+	rotated, err := crypto.NewEnvelopeKeys(newKey, oldKey)
+	if err != nil {
+		t.Fatalf("NewEnvelopeKeys() error: %v", err)
+	}
+
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		// One secret from before envelope keys, one under the old key and
+		// one under its successor
+		createReq := getMockCreateSecretRequest(nil)
+		ids := []string{
+			createTestSecret(t, newEnvelopeTestRouter(t, b, nil), createReq),
+			createTestSecret(t, newEnvelopeTestRouter(t, b, old), createReq),
+		}
+		router := newEnvelopeTestRouter(t, b, rotated)
+		ids = append(ids, createTestSecret(t, router, createReq))
+
+		progress := getAdminStats(t, router).EnvelopeKeys
+		if progress.Current != rotated.Current() || progress.Remaining != 2 || len(progress.Versions) != 3 {
+			t.Errorf("envelope_keys = %+v, want 2 of 3 data keys off current %s", progress, rotated.Current())
+		}
+		for _, version := range progress.Versions {
+			if version.DataKeys != 1 || !version.Configured {
+				t.Errorf("envelope_keys version %+v, want one configured data key", version)
+			}
+		}
+
+		// Every secret reads back whatever its data key is wrapped under
+		for _, id := range ids {
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/"+id, nil))
+			if response.Code != http.StatusOK {
+				t.Fatalf("GET %s status = %d, want %d: %s", id, response.Code, http.StatusOK, response.Body)
+			}
+			var secret models.GetSecretResponse
+			if err := json.NewDecoder(response.Body).Decode(&secret); err != nil {
+				t.Fatalf("decode secret: %v", err)
+			}
+			if secret.Ciphertext != createReq.Ciphertext {
+				t.Errorf("GET %s ciphertext = %q, want %q", id, secret.Ciphertext, createReq.Ciphertext)
+			}
+		}
+
+		if progress := getAdminStats(t, router).EnvelopeKeys; progress.Remaining != 0 || len(progress.Versions) != 0 {
+			t.Errorf("envelope_keys after reads = %+v, want no data keys left", progress)
+		}
+	})
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/events"
+	"ots-backend/internal/logger"
+	httpMiddleware "ots-backend/internal/middleware"
+	"ots-backend/internal/models"
+	"ots-backend/internal/store"
+)
+
+const eventStreamContentType = "text/event-stream"
+
+// defaultEventsHeartbeat is how often an events stream sends a comment, so
+// proxies do not close it as idle, and looks at the secret again for
+// endings this process did not see
+const defaultEventsHeartbeat = 15 * time.Second
+
+// receiptEvents maps a receipt's state to the event that led to it
+var receiptEvents = map[string]events.Type{
+	models.ReceiptActive:   events.Created,
+	models.ReceiptConsumed: events.Read,
+	models.ReceiptBurned:   events.Burned,
+	models.ReceiptExpired:  events.Expired,
+}
+
+// IsEventStream reports whether r asks for a secret's events, which stay
+// open for as long as the secret lives and so must not be cut short by
+// request timeouts
+func IsEventStream(r *http.Request) bool {
+	return r.Method == http.MethodGet &&
+		strings.HasPrefix(r.URL.Path, "/api/secrets/") &&
+		strings.HasSuffix(r.URL.Path, "/events")
+}
+
+// SecretEvents streams what becomes of a secret to the holder of its
+// management token as Server-Sent Events. The first event is the secret's
+// state when the stream opens; the stream closes after a read, burn or
+// expiry. Endings in this process arrive as they happen; endings on other
+// replicas or by a separate cleanup process are noticed at the next
+// heartbeat. Refusals match the receipt's.
+func (h *Handler) SecretEvents(w http.ResponseWriter, r *http.Request) {
+	secretID := chi.URLParam(r, "id")
+	if err := h.validateSecretID(secretID); err != nil {
+		h.respondLookupMiss(w, r)
+		return
+	}
+	if h.respondIfForeign(w, secretID) {
+		return
+	}
+
+	// Watch before looking, so an ending in between is not missed
+	watch, stop := h.events.Watch(secretID)
+	defer stop()
+
+	token := r.Header.Get(ManagementTokenHeader)
+	receipt, err := h.secretReceipt(r.Context(), secretID, token)
+	if errors.Is(err, store.ErrNotFound) {
+		logger.Warn("events refused", "secret_id", secretID, "ip", r.RemoteAddr)
+		h.respondLookupMiss(w, r)
+		return
+	}
+	if err != nil {
+		logger.Error("failed to look up secret for events", "error", err, "secret_id", secretID)
+		h.respondStoreError(w, err, "database error")
+		return
+	}
+
+	// The stream outlives the server's read and write timeouts. A writer
+	// without deadlines, as in tests, has none to clear.
+	controller := http.NewResponseController(w)
+	controller.SetReadDeadline(time.Time{})
+	controller.SetWriteDeadline(time.Time{})
+
+	httpMiddleware.SkipCompression(w)
+	w.Header().Set("Content-Type", eventStreamContentType)
+	// Keeps nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(e events.Event) bool {
+		if err := writeEvent(w, e); err != nil {
+			return false
+		}
+		return controller.Flush() == nil && !e.Type.Terminal()
+	}
+
+	if !send(receiptEvent(receipt)) {
+		return
+	}
+
+	heartbeat := time.NewTicker(h.eventsHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e := <-watch:
+			if !send(e) {
+				return
+			}
+		case <-heartbeat.C:
+			receipt, err := h.secretReceipt(r.Context(), secretID, token)
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				// Purged, or its tombstone is past retention
+				return
+			case err != nil:
+				logger.Warn("events: failed to look up secret", "error", err, "secret_id", secretID)
+			case receipt.State != models.ReceiptActive:
+				send(receiptEvent(receipt))
+				return
+			}
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil || controller.Flush() != nil {
+				return
+			}
+		}
+	}
+}
+
+// receiptEvent is the event that left a secret in receipt's state
+func receiptEvent(receipt *models.SecretReceiptResponse) events.Event {
+	return events.Event{Type: receiptEvents[receipt.State], At: receipt.EndedAt, Revision: receipt.Revision}
+}
+
+// writeEvent writes e as one Server-Sent Event named after its type
+func writeEvent(w io.Writer, e events.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+	return err
+}
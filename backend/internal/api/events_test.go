@@ -0,0 +1,219 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ots-backend/internal/events"
+	"ots-backend/internal/store"
+	"ots-backend/internal/terminate"
+)
+
+// withHeartbeat has event streams send a heartbeat every interval
+func withHeartbeat(interval time.Duration) func(h *Handler) {
+	return func(h *Handler) {
+		h.eventsHeartbeat = interval
+	}
+}
+
+// eventStream reads Server-Sent Events from a response body
+type eventStream struct {
+	body *bufio.Reader
+}
+
+// openEvents opens id's event stream with token and checks it is one
+func openEvents(t *testing.T, server *httptest.Server, id, token string) *eventStream {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/secrets/"+id+"/events", nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error: %v", err)
+	}
+	request.Header.Set(ManagementTokenHeader, token)
+	response, err := server.Client().Do(request)
+	if err != nil {
+		t.Fatalf("GET events error: %v", err)
+	}
+	t.Cleanup(func() { response.Body.Close() })
+
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("GET events status = %d, want %d", response.StatusCode, http.StatusOK)
+	}
+	if got := response.Header.Get("Content-Type"); got != eventStreamContentType {
+		t.Fatalf("GET events Content-Type = %q, want %q", got, eventStreamContentType)
+	}
+	return &eventStream{body: bufio.NewReader(response.Body)}
+}
+
+// next returns the next event, skipping heartbeats, or io.EOF once the
+// server closes the stream
+func (s *eventStream) next() (events.Event, error) {
+	var name, data string
+	for {
+		line, err := s.body.ReadString('\n')
+		if err != nil {
+			return events.Event{}, err
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && name != "":
+			var e events.Event
+			if err := json.Unmarshal([]byte(data), &e); err != nil {
+				return e, err
+			}
+			if string(e.Type) != name {
+				return e, errors.New("event " + name + " carries type " + string(e.Type))
+			}
+			return e, nil
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+// expect reads the next event and checks its type, and that only endings
+// carry a time
+func (s *eventStream) expect(t *testing.T, want events.Type) {
+	t.Helper()
+
+	e, err := s.next()
+	if err != nil {
+		t.Fatalf("reading %s event: %v", want, err)
+	}
+	if e.Type != want || (e.At != nil) != want.Terminal() {
+		t.Fatalf("event = %+v, want %s", e, want)
+	}
+}
+
+// expectClosed checks the server ended the stream
+func (s *eventStream) expectClosed(t *testing.T) {
+	t.Helper()
+
+	if e, err := s.next(); err != io.EOF {
+		t.Fatalf("after the last event got %+v, %v; want the stream closed", e, err)
+	}
+}
+
+func TestSecretEventsCreateThenRead(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		server := httptest.NewServer(newTestRouterWithConfig(t, b, nil, withHeartbeat(time.Minute)))
+		t.Cleanup(server.Close)
+
+		created := createTestSecretResponse(t, server.Config.Handler, getMockCreateSecretRequest(nil))
+		stream := openEvents(t, server, created.ID, created.ManagementToken)
+		stream.expect(t, events.Created)
+
+		response, err := server.Client().Get(server.URL + "/api/secrets/" + created.ID)
+		if err != nil {
+			t.Fatalf("GET secret error: %v", err)
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("GET secret status = %d, want %d", response.StatusCode, http.StatusOK)
+		}
+
+		stream.expect(t, events.Read)
+		stream.expectClosed(t)
+	})
+}
+
+func TestSecretEventsBurnAfterHeartbeat(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		server := httptest.NewServer(newTestRouterWithConfig(t, b, nil, withHeartbeat(10*time.Millisecond)))
+		t.Cleanup(server.Close)
+
+		created := createTestSecretResponse(t, server.Config.Handler, getMockCreateSecretRequest(nil))
+		stream := openEvents(t, server, created.ID, created.ManagementToken)
+		stream.expect(t, events.Created)
+
+		// Wait out a heartbeat, then burn through the API
+		if line, err := stream.body.ReadString('\n'); err != nil || line != ": heartbeat\n" {
+			t.Fatalf("after created read %q, %v; want a heartbeat", line, err)
+		}
+		request, _ := http.NewRequest(http.MethodDelete, server.URL+"/api/secrets/"+created.ID, nil)
+		request.Header.Set(ManagementTokenHeader, created.ManagementToken)
+		response, err := server.Client().Do(request)
+		if err != nil {
+			t.Fatalf("DELETE secret error: %v", err)
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusNoContent {
+			t.Fatalf("DELETE secret status = %d, want %d", response.StatusCode, http.StatusNoContent)
+		}
+
+		stream.expect(t, events.Burned)
+		stream.expectClosed(t)
+	})
+}
+
+// TestSecretEventsNoticesOtherProcesses ends a secret past the handler's
+// hub, as another replica or the cleanup process would, and checks the
+// stream learns of it at a heartbeat
+func TestSecretEventsNoticesOtherProcesses(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		server := httptest.NewServer(newTestRouterWithConfig(t, b, nil, withHeartbeat(10*time.Millisecond)))
+		t.Cleanup(server.Close)
+
+		created := createTestSecretResponse(t, server.Config.Handler, getMockCreateSecretRequest(nil))
+		stream := openEvents(t, server, created.ID, created.ManagementToken)
+		stream.expect(t, events.Created)
+
+		elsewhere := &terminate.Terminator{Store: b.store}
+		if _, err := elsewhere.Terminate(context.Background(), created.ID, store.TerminationBurned, terminate.Options{}); err != nil {
+			t.Fatalf("Terminate() error: %v", err)
+		}
+
+		stream.expect(t, events.Burned)
+		stream.expectClosed(t)
+	})
+}
+
+func TestSecretEventsAfterTheEnd(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouter(t, b)
+		created := createTestSecretResponse(t, router, getMockCreateSecretRequest(nil))
+
+		get := func(token string) *httptest.ResponseRecorder {
+			request := httptest.NewRequest(http.MethodGet, "/api/secrets/"+created.ID+"/events", nil)
+			if token != "" {
+				request.Header.Set(ManagementTokenHeader, token)
+			}
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, request)
+			return response
+		}
+
+		for _, token := range []string{"", "wrong-token"} {
+			if response := get(token); response.Code != http.StatusNotFound {
+				t.Errorf("events with token %q status = %d, want %d", token, response.Code, http.StatusNotFound)
+			}
+		}
+
+		readSecretBody(t, router, created.ID)
+
+		// A stream opened after the read replays it and closes at once
+		response := get(created.ManagementToken)
+		if response.Code != http.StatusOK {
+			t.Fatalf("events status = %d, want %d", response.Code, http.StatusOK)
+		}
+		stream := &eventStream{body: bufio.NewReader(response.Body)}
+		stream.expect(t, events.Read)
+		stream.expectClosed(t)
+	})
+}
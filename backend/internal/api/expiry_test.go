@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ots-backend/internal/testutil"
+)
+
+func TestSecretExpiresOnHandlerClock(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		clk := testutil.NewFakeClock(time.Now().Truncate(time.Second))
+		router := newTestRouterWithConfig(t, b, withAudit, withTestClock(clk))
+
+		// The mock secret expires after 15 minutes
+		expiresAt := clk.Now().Add(15 * time.Minute)
+		created := createTestSecretResponse(t, router, getMockCreateSecretRequest(nil))
+
+		head := func() int {
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, httptest.NewRequest(http.MethodHead, "/api/secrets/"+created.ID, nil))
+			return response.Code
+		}
+
+		clk.Advance(15*time.Minute - time.Second)
+		if status := head(); status != http.StatusOK {
+			t.Fatalf("HEAD a second before expiry status = %d, want %d", status, http.StatusOK)
+		}
+
+		clk.Advance(2 * time.Second)
+		if status := head(); status != http.StatusNotFound {
+			t.Errorf("HEAD after expiry status = %d, want %d", status, http.StatusNotFound)
+		}
+		if status := getSecretStatus(router, created.ID); status != http.StatusNotFound {
+			t.Errorf("GET after expiry status = %d, want %d", status, http.StatusNotFound)
+		}
+
+		// Stepping the clock back shows the expired read consumed nothing
+		clk.Set(expiresAt.Add(-time.Minute))
+		if status := getSecretStatus(router, created.ID); status != http.StatusOK {
+			t.Errorf("GET before expiry status = %d, want %d", status, http.StatusOK)
+		}
+	})
+}
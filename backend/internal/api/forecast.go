@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"ots-backend/internal/logger"
+	"ots-backend/internal/validation"
+)
+
+// ExpiryForecastBucket is the secrets expiring within one UTC hour
+type ExpiryForecastBucket struct {
+	Hour  time.Time `json:"hour"`
+	Count int64     `json:"count"`
+	Bytes int64     `json:"bytes"`
+}
+
+// ExpiryForecastResponse is when the live secrets will expire, by hour
+type ExpiryForecastResponse struct {
+	From  time.Time `json:"from"`
+	Until time.Time `json:"until"`
+	// Namespace is empty when the forecast covers every namespace
+	Namespace  string                 `json:"namespace"`
+	Buckets    []ExpiryForecastBucket `json:"buckets"`
+	TotalCount int64                  `json:"total_count"`
+	TotalBytes int64                  `json:"total_bytes"`
+}
+
+// ExpiryForecast handles GET /api/admin/expiry-forecast, counting the live
+// secrets that expire in each hour from now until the maximum TTL. Hours
+// with nothing expiring are left out.
+func (h *Handler) ExpiryForecast(w http.ResponseWriter, r *http.Request) {
+	namespace := r.URL.Query().Get("namespace")
+	if namespace != "" {
+		if err := validation.ValidateNamespace(namespace); err != nil {
+			h.respondServiceError(w, err)
+			return
+		}
+	}
+
+	now := h.clock.Now()
+	until := now.Add(h.policy().MaxTTL)
+	buckets, err := h.store.ExpiryForecast(r.Context(), namespace, now, until)
+	if err != nil {
+		logger.Error("expiry forecast: query failed", "error", err, "namespace", namespace)
+		h.respondStoreError(w, err, "database error")
+		return
+	}
+
+	resp := ExpiryForecastResponse{
+		From:      now.UTC(),
+		Until:     until.UTC(),
+		Namespace: namespace,
+		Buckets:   make([]ExpiryForecastBucket, 0, len(buckets)),
+	}
+	for _, b := range buckets {
+		resp.Buckets = append(resp.Buckets, ExpiryForecastBucket{Hour: b.Hour.UTC(), Count: b.Count, Bytes: b.Bytes})
+		resp.TotalCount += b.Count
+		resp.TotalBytes += b.Bytes
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
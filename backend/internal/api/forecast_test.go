@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"ots-backend/internal/policy"
+	"ots-backend/internal/testutil"
+)
+
+func getExpiryForecast(t *testing.T, router http.Handler, query string) ExpiryForecastResponse {
+	t.Helper()
+
+	response := adminRequest(router, http.MethodGet, "/api/admin/expiry-forecast"+query)
+	if response.Code != http.StatusOK {
+		t.Fatalf("expiry forecast status = %d, want %d: %s", response.Code, http.StatusOK, response.Body)
+	}
+	var forecast ExpiryForecastResponse
+	if err := json.NewDecoder(response.Body).Decode(&forecast); err != nil {
+		t.Fatalf("decode expiry forecast: %v", err)
+	}
+	return forecast
+}
+
+func TestExpiryForecast(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		hour := time.Now().UTC().Truncate(time.Hour)
+		clk := testutil.NewFakeClock(hour.Add(10 * time.Minute))
+		router := newTestRouterWithConfig(t, b, withAudit, withTestClock(clk))
+
+		create := func(namespace string, expiresIn time.Duration) string {
+			req := getMockCreateSecretRequest(nil)
+			req.Namespace = namespace
+			req.ExpiresIn = int(expiresIn.Seconds())
+			return createTestSecret(t, router, req)
+		}
+		create("", 20*time.Minute)
+		create("team-a", 40*time.Minute)
+		create("team-a", 2*time.Hour)
+		create("team-b", 2*time.Hour+30*time.Minute)
+		create("team-a", 5*time.Hour)
+		// Read secrets no longer expire
+		read := create("team-a", 30*time.Minute)
+		if status := getSecretStatus(router, read); status != http.StatusOK {
+			t.Fatalf("GET status = %d, want %d", status, http.StatusOK)
+		}
+
+		size := int64(len("test secret data"))
+		forecast := getExpiryForecast(t, router, "")
+		want := []ExpiryForecastBucket{
+			{Hour: hour, Count: 2, Bytes: 2 * size},
+			{Hour: hour.Add(2 * time.Hour), Count: 2, Bytes: 2 * size},
+			{Hour: hour.Add(5 * time.Hour), Count: 1, Bytes: size},
+		}
+		if !slices.EqualFunc(forecast.Buckets, want, equalForecastBucket) {
+			t.Errorf("buckets = %+v, want %+v", forecast.Buckets, want)
+		}
+		if forecast.TotalCount != 5 || forecast.TotalBytes != 5*size {
+			t.Errorf("totals = %d secrets, %d bytes; want 5, %d", forecast.TotalCount, forecast.TotalBytes, 5*size)
+		}
+		if !forecast.From.Equal(clk.Now()) || !forecast.Until.Equal(clk.Now().Add(policy.DefaultMaxTTL)) {
+			t.Errorf("window = %v to %v, want the max TTL from %v", forecast.From, forecast.Until, clk.Now())
+		}
+
+		teamA := getExpiryForecast(t, router, "?namespace=team-a")
+		want = []ExpiryForecastBucket{
+			{Hour: hour, Count: 1, Bytes: size},
+			{Hour: hour.Add(2 * time.Hour), Count: 1, Bytes: size},
+			{Hour: hour.Add(5 * time.Hour), Count: 1, Bytes: size},
+		}
+		if teamA.Namespace != "team-a" || !slices.EqualFunc(teamA.Buckets, want, equalForecastBucket) {
+			t.Errorf("team-a forecast = %+v, want buckets %+v", teamA, want)
+		}
+
+		// An hour later the first bucket has expired
+		clk.Advance(time.Hour)
+		forecast = getExpiryForecast(t, router, "")
+		if len(forecast.Buckets) != 2 || !forecast.Buckets[0].Hour.Equal(hour.Add(2*time.Hour)) {
+			t.Errorf("buckets an hour later = %+v, want the hours from %v", forecast.Buckets, hour.Add(2*time.Hour))
+		}
+	})
+}
+
+func equalForecastBucket(a, b ExpiryForecastBucket) bool {
+	return a.Hour.Equal(b.Hour) && a.Count == b.Count && a.Bytes == b.Bytes
+}
+
+func TestExpiryForecastRejects(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		router := newNamespaceTestRouter(t, b)
+
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/admin/expiry-forecast", nil))
+		if response.Code != http.StatusUnauthorized {
+			t.Errorf("without admin token status = %d, want %d", response.Code, http.StatusUnauthorized)
+		}
+
+		response = adminRequest(router, http.MethodGet, "/api/admin/expiry-forecast?namespace=Not%20Valid")
+		if response.Code != http.StatusBadRequest {
+			t.Errorf("invalid namespace status = %d, want %d", response.Code, http.StatusBadRequest)
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/models"
+	"ots-backend/internal/testutil"
+)
+
+// droppedWriter loses the body as a connection dropped mid-response does:
+// the headers are kept, every write fails
+type droppedWriter struct {
+	header http.Header
+	status int
+}
+
+func (d *droppedWriter) Header() http.Header {
+	if d.header == nil {
+		d.header = make(http.Header)
+	}
+	return d.header
+}
+
+func (d *droppedWriter) WriteHeader(status int) { d.status = status }
+
+func (d *droppedWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write: connection reset by peer")
+}
+
+// withGrace holds reads for a minute when grace is set
+func withGrace(grace bool) func(cfg *config.Config) {
+	return func(cfg *config.Config) {
+		withAckWindow(cfg)
+		cfg.ConsumeGrace = grace
+		cfg.ConsumeGraceWindow = time.Minute
+	}
+}
+
+func getWithNonce(router http.Handler, secretID, nonce string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, "/api/secrets/"+secretID, nil)
+	if nonce != "" {
+		request.Header.Set(RetrievalNonceHeader, nonce)
+	}
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
+
+// readDropped consumes secretID with the response body lost and returns
+// the retrieval nonce that made it out in the headers
+func readDropped(t *testing.T, router http.Handler, secretID string) string {
+	t.Helper()
+
+	w := &droppedWriter{}
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/secrets/"+secretID, nil))
+	if w.status != 0 && w.status != http.StatusOK {
+		t.Fatalf("GetSecret() status = %d, want %d", w.status, http.StatusOK)
+	}
+	return w.Header().Get(RetrievalNonceHeader)
+}
+
+func TestGraceHoldRecoversDroppedRead(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		clk := testutil.NewFakeClock(time.Now())
+		router := newTestRouterWithConfig(t, b, withGrace(true), withTestClock(clk))
+		req := getMockCreateSecretRequest(nil)
+		secretID := createTestSecret(t, router, req)
+
+		nonce := readDropped(t, router, secretID)
+		if nonce == "" {
+			t.Fatal("GetSecret() sent no retrieval nonce")
+		}
+
+		// The read happened: the secret is gone to everyone without the nonce
+		for _, try := range []string{"", "not-the-nonce"} {
+			if response := getWithNonce(router, secretID, try); response.Code != http.StatusNotFound {
+				t.Fatalf("GetSecret() with nonce %q status = %d, want %d", try, response.Code, http.StatusNotFound)
+			}
+		}
+		if receipt, err := b.store.Receipt(context.Background(), secretID); err != nil || receipt.Acknowledged != nil {
+			t.Fatalf("Receipt() = %+v, %v; want the first read recorded", receipt, err)
+		}
+
+		clk.Advance(30 * time.Second)
+		response := getWithNonce(router, secretID, nonce)
+		if response.Code != http.StatusOK {
+			t.Fatalf("retry status = %d, want %d", response.Code, http.StatusOK)
+		}
+		if got := response.Header().Get(RetrievalNonceHeader); got != "" {
+			t.Errorf("retry sent retrieval nonce %q, want none", got)
+		}
+		var secret models.GetSecretResponse
+		if err := json.NewDecoder(response.Body).Decode(&secret); err != nil {
+			t.Fatalf("retry decode error: %v", err)
+		}
+		if secret.Ciphertext != req.Ciphertext {
+			t.Errorf("retry ciphertext = %q, want %q", secret.Ciphertext, req.Ciphertext)
+		}
+
+		// The nonce is spent with the secret
+		if response := getWithNonce(router, secretID, nonce); response.Code != http.StatusNotFound {
+			t.Fatalf("second retry status = %d, want %d", response.Code, http.StatusNotFound)
+		}
+		if burned, err := b.store.Burn(context.Background(), secretID); err != nil || burned {
+			t.Fatalf("Burn() after retry = %v, %v; want false, nil", burned, err)
+		}
+	})
+}
+
+func TestGraceHoldLapses(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		clk := testutil.NewFakeClock(time.Now())
+		router := newTestRouterWithConfig(t, b, withGrace(true), withTestClock(clk))
+		secretID := createTestSecret(t, router, getMockCreateSecretRequest(nil))
+		nonce := readDropped(t, router, secretID)
+
+		clk.Advance(time.Minute + time.Second)
+		if response := getWithNonce(router, secretID, nonce); response.Code != http.StatusNotFound {
+			t.Fatalf("retry after the window status = %d, want %d", response.Code, http.StatusNotFound)
+		}
+
+		// The cleanup worker's sweep removes the held row
+		if n, err := b.store.BurnUnacknowledged(context.Background(), clk.Now()); err != nil || n != 1 {
+			t.Fatalf("BurnUnacknowledged() = %d, %v; want 1, nil", n, err)
+		}
+	})
+}
+
+func TestGraceHoldOffDestroysOnRead(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		clk := testutil.NewFakeClock(time.Now())
+		router := newTestRouterWithConfig(t, b, withGrace(false), withTestClock(clk))
+		secretID := createTestSecret(t, router, getMockCreateSecretRequest(nil))
+
+		if nonce := readDropped(t, router, secretID); nonce != "" {
+			t.Fatalf("strict read sent retrieval nonce %q, want none", nonce)
+		}
+		if burned, err := b.store.Burn(context.Background(), secretID); err != nil || burned {
+			t.Fatalf("Burn() after strict read = %v, %v; want false, nil", burned, err)
+		}
+
+		// require_ack secrets keep their ack hold with grace on
+		router = newTestRouterWithConfig(t, b, withGrace(true), withTestClock(clk))
+		req := getMockCreateSecretRequest(nil)
+		req.RequireAck = true
+		secretID = createTestSecret(t, router, req)
+		response := getWithNonce(router, secretID, "")
+		if response.Code != http.StatusOK || response.Header().Get(RetrievalNonceHeader) != "" {
+			t.Fatalf("GetSecret() of require_ack secret = %d with nonce %q, want 200 without", response.Code, response.Header().Get(RetrievalNonceHeader))
+		}
+		var secret models.GetSecretResponse
+		if err := json.NewDecoder(response.Body).Decode(&secret); err != nil || secret.AckToken == "" {
+			t.Fatalf("GetSecret() = %+v, %v; want an ack token", secret, err)
+		}
+		if response := getWithNonce(router, secretID, secret.AckToken); response.Code != http.StatusNotFound {
+			t.Fatalf("retry with the ack token status = %d, want %d", response.Code, http.StatusNotFound)
+		}
+	})
+}
@@ -1,52 +1,435 @@
 package api
 
 import (
-	"database/sql"
-	"encoding/base64"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5"
+	"github.com/go-chi/cors"
 
+	"ots-backend/internal/clock"
 	"ots-backend/internal/config"
 	"ots-backend/internal/crypto"
 	"ots-backend/internal/db"
+	"ots-backend/internal/events"
+	"ots-backend/internal/geo"
+	"ots-backend/internal/httpx"
 	"ots-backend/internal/logger"
 	httpMiddleware "ots-backend/internal/middleware"
 	"ots-backend/internal/models"
+	"ots-backend/internal/netclass"
+	"ots-backend/internal/notify"
+	"ots-backend/internal/policy"
+	"ots-backend/internal/reputation"
+	"ots-backend/internal/scan"
+	"ots-backend/internal/store"
+	"ots-backend/internal/terminate"
+	"ots-backend/internal/tracing"
+	"ots-backend/internal/ulid"
 	"ots-backend/internal/validation"
+	"ots-backend/pkg/ots"
 )
 
+// ManagementTokenHeader carries the token returned at create time
+const ManagementTokenHeader = "X-Management-Token"
+
+// RetrievalNonceHeader carries the one-time nonce of a read held for its
+// grace window, in the response and in a retry of the read
+const RetrievalNonceHeader = "X-Retrieval-Nonce"
+
+// HintHeader carries a secret's hint on HEAD, as an RFC 8187 ext-value
+const HintHeader = "X-Secret-Hint"
+
+// ContentTypeHeader and FilenameHeader carry a secret's declared content
+// type and filename on HEAD, the filename as an RFC 8187 ext-value
+const (
+	ContentTypeHeader = "X-Secret-Content-Type"
+	FilenameHeader    = "X-Secret-Filename"
+)
+
+// CORSOptions returns the API's CORS policy for the given origins. Every
+// custom request header a browser client sends must be allowed here or its
+// preflight fails.
+func CORSOptions(origins []string) cors.Options {
+	return cors.Options{
+		AllowedOrigins:   origins,
+		AllowedMethods:   []string{"GET", "POST", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-Requested-With", ManagementTokenHeader, ClientHeader, RetrievalNonceHeader},
+		ExposedHeaders:   []string{"Link", HintHeader, ContentTypeHeader, FilenameHeader, RetrievalNonceHeader, "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"},
+		AllowCredentials: false,
+		MaxAge:           300,
+	}
+}
+
 // Handler handles API requests
 type Handler struct {
-	db  *db.DB
-	cfg *config.Config
+	store    store.Store
+	configs  *config.Manager
+	clock    clock.Clock
+	listener *db.Listener
+	checks   []resourceCheck
+	pingDB   func(ctx context.Context) error
+	classify *netclass.Classifier
+	// locate looks up readers' countries for tombstones; nil stores none
+	locate geo.Locator
+
+	// settings caches what the manager's active configuration resolves
+	// to; see current
+	settings atomic.Pointer[settings]
+
+	// nonceKeys signs create nonces; nonces is built from them in Routes
+	nonceKeys *crypto.Keyring
+	nonces    *httpMiddleware.CreateNonces
+
+	// dossierKeys signs support dossiers
+	dossierKeys *crypto.Keyring
+	// linkKeys signs retrieval link tokens
+	linkKeys *crypto.Keyring
+
+	// envelope wraps data keys in crypto-shredding mode; nil stores them
+	// as they are
+	envelope *crypto.EnvelopeKeys
+
+	// notifier tells creators that set notify_email when their secret is
+	// read or burned; nil when notifications are off
+	notifier *notify.Service
+
+	// events tells event streams of secrets ended in this process;
+	// eventsHeartbeat spaces out each stream's heartbeats
+	events          *events.Hub
+	eventsHeartbeat time.Duration
+
+	// misses throttles failed lookups during an enumeration flood; nil when disabled
+	misses *httpMiddleware.MissLimiter
+
+	// reputation scales rate limits by each client's history; built by the
+	// first Routes call and shared by every router after it
+	reputation *reputation.Tracker
+
+	// region prefixes new secret IDs; regionPeers maps other regions'
+	// codes to their base URLs
+	region      string
+	regionPeers map[string]string
+
+	// auditIDs issues audit event IDs; monotonic per handler, so events
+	// recorded within one millisecond keep their order
+	auditIDs ulid.Generator
+
+	// activeCountAt is when the metrics endpoint last counted active
+	// secrets; activeCountMu lets one scrape refresh it at a time
+	activeCountMu sync.Mutex
+	activeCountAt time.Time
+
+	// readiness is the lifecycle stage the readiness probe reports
+	readiness *Readiness
+
+	// canary holds self-test outcomes; nil unless StartCanary was called
+	canary *canaryState
+
+	// deleting holds the namespaces whose deletion job runs in this process
+	deleting sync.Map
+
+	attestationOnce sync.Once
+	attestation     crypto.Attestation
+}
+
+// settings is what one configuration resolves to: its policy and the
+// documents rendered from it. A reload replaces it whole.
+type settings struct {
+	cfg    *config.Config
+	policy *policy.Policy
+
+	clientConfigOnce sync.Once
+	clientConfigJSON []byte
+	clientConfigETag string
+
+	openAPIOnce sync.Once
+	openAPIJSON []byte
+	openAPIErr  error
 }
 
 // NewHandler creates a new API handler
-func NewHandler(database *db.DB, cfg *config.Config) *Handler {
-	return &Handler{
-		db:  database,
-		cfg: cfg,
+func NewHandler(st store.Store, cfg *config.Config) *Handler {
+	h := &Handler{
+		store:     st,
+		configs:   config.NewManager(cfg),
+		clock:     clock.System,
+		readiness: NewReadiness(),
+
+		events:          events.NewHub(),
+		eventsHeartbeat: defaultEventsHeartbeat,
+	}
+	h.checks = h.defaultResourceChecks()
+	h.pingDB = st.Ping
+	// Reading the system random source cannot fail
+	h.nonceKeys, _ = crypto.EphemeralKeyring()
+	h.dossierKeys, _ = crypto.EphemeralKeyring()
+	h.linkKeys, _ = crypto.EphemeralKeyring()
+	return h
+}
+
+// SetConfigManager makes the handler follow m, so reloaded limits apply
+// from the next request; call it before Routes. Settings used to build
+// the routes, such as the admin token and the lookup miss limiter, keep
+// their startup values.
+func (h *Handler) SetConfigManager(m *config.Manager) {
+	h.configs = m
+}
+
+// current returns the settings of the active configuration, resolving
+// them again after a reload
+func (h *Handler) current() *settings {
+	cfg := h.configs.Current()
+	old := h.settings.Load()
+	if old != nil && old.cfg == cfg {
+		return old
+	}
+
+	next := &settings{cfg: cfg, policy: policy.FromConfig(cfg)}
+	if h.settings.CompareAndSwap(old, next) {
+		return next
+	}
+	return h.settings.Load()
+}
+
+// config returns the active configuration
+func (h *Handler) config() *config.Config {
+	return h.current().cfg
+}
+
+// policy returns the limits of the active configuration
+func (h *Handler) policy() *policy.Policy {
+	return h.current().policy
+}
+
+// SetListener attaches the LISTEN/NOTIFY connection manager so its health is reported
+func (h *Handler) SetListener(l *db.Listener) {
+	h.listener = l
+}
+
+// SetClock replaces the clock used for timestamps and rate limit windows;
+// call it before Routes
+func (h *Handler) SetClock(c clock.Clock) {
+	h.clock = c
+}
+
+// SetNonceKeyring replaces the per-process keyring that signs create
+// nonces, so nonces outlive restarts and work across replicas; call it
+// before Routes
+func (h *Handler) SetNonceKeyring(k *crypto.Keyring) {
+	h.nonceKeys = k
+}
+
+// SetEnvelopeKeys wraps new data keys under the first of keys and opens
+// data keys under any of them
+func (h *Handler) SetEnvelopeKeys(keys *crypto.EnvelopeKeys) {
+	h.envelope = keys
+}
+
+// SetClassifier labels tombstones and audit events with the reader's or
+// burner's network class
+func (h *Handler) SetClassifier(c *netclass.Classifier) {
+	h.classify = c
+}
+
+// SetLocator records each reader's coarse country in the read's tombstone,
+// for the creator's receipt. The IP itself is never stored.
+func (h *Handler) SetLocator(l geo.Locator) {
+	h.locate = l
+}
+
+// SetNotifier lets creates set notify_email and sends a notice when such a
+// secret is read or burned. The caller starts and stops n.
+func (h *Handler) SetNotifier(n *notify.Service) {
+	h.notifier = n
+}
+
+// SetEvents replaces the hub event streams watch, so endings by something
+// else in the process, such as the memory store's sweeper, reach them
+func (h *Handler) SetEvents(hub *events.Hub) {
+	h.events = hub
+}
+
+// terminator ends secrets with the handler's clock, notifier, audit
+// settings and event hub
+func (h *Handler) terminator() *terminate.Terminator {
+	return &terminate.Terminator{
+		Store:     h.store,
+		Clock:     h.clock,
+		Notifier:  h.notifier,
+		AuditIDs:  &h.auditIDs,
+		SkipAudit: !h.config().AuditLogEnabled,
+		Events:    h.events,
+	}
+}
+
+// Rate limit budgets, read from the active configuration on every request
+func writeRateLimit(c *config.Config) (int, time.Duration) {
+	return c.WriteRateLimitRequests, c.WriteRateLimitWindow
+}
+
+// validateRateLimit gives dry runs twice the write budget over its window,
+// so each counts as half a create
+func validateRateLimit(c *config.Config) (int, time.Duration) {
+	return 2 * c.WriteRateLimitRequests, c.WriteRateLimitWindow
+}
+
+func readRateLimit(c *config.Config) (int, time.Duration) {
+	return c.ReadRateLimitRequests, c.ReadRateLimitWindow
+}
+
+func agentRateLimit(c *config.Config) (int, time.Duration) {
+	return c.AgentRateLimitRequests, c.AgentRateLimitWindow
+}
+
+func reportRateLimit(c *config.Config) (int, time.Duration) {
+	return c.ReportRateLimitRequests, c.ReportRateLimitWindow
+}
+
+func auditRateLimit(c *config.Config) (int, time.Duration) {
+	return c.AuditRateLimitRequests, c.AuditRateLimitWindow
+}
+
+// createBodyLimit bounds a create, dry-run or agent body: the largest
+// secret twice over, for base64 and parts, plus room for the other fields
+func createBodyLimit(c *config.Config) int64 {
+	return 2*int64(c.MaxSecretSize) + 64<<10
+}
+
+// smallBodyLimit bounds bodies that carry only a token or a reason
+func smallBodyLimit(*config.Config) int64 {
+	return 16 << 10
+}
+
+// limitBody caps the request body at limit of the current config. Reading
+// past it fails with *http.MaxBytesError and closes the connection once the
+// response is written; see bodyError.
+func (h *Handler) limitBody(limit func(*config.Config) int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, limit(h.config()))
+			next.ServeHTTP(w, r)
+		})
 	}
 }
 
+// bodyError maps a failure to decode a create body to its ots error: a body
+// over createBodyLimit is too large a secret, anything else is malformed
+func bodyError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return fmt.Errorf("%w: request body over %d bytes", validation.ErrSecretTooLarge, tooLarge.Limit)
+	}
+	return ots.ErrInvalidRequestBody
+}
+
+// breachGuard leaves secret responses uncompressed with
+// COMPRESS_BREACH_PARANOID on. They carry ciphertexts, keys and tokens next
+// to input an attacker may control, and compressed sizes can leak them.
+func (h *Handler) breachGuard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.config().CompressBreachParanoid {
+			httpMiddleware.SkipCompression(w)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // Routes returns the router for API endpoints
 func (h *Handler) Routes() chi.Router {
 	r := chi.NewRouter()
 
+	// Every response reports a rate limit, even where none is enforced
+	limits := &rateLimits{h: h}
+	r.Use(limits.headers)
+
+	// Development servers that ask for it explain client errors;
+	// production never does
+	if hintsEnabled(h.config()) {
+		r.Use(withErrorHints)
+	}
+	r.Use(withLocale)
+	r.Use(httpMiddleware.Compress(func() int { return h.config().CompressMinSize }))
+	r.MethodNotAllowed(h.methodNotAllowed)
+
 	r.Get("/health", h.HealthCheck)
 	r.Get("/health/ready", h.ReadinessProbe)
 	r.Get("/health/live", h.LivenessProbe)
 	r.Get("/metrics", h.MetricsHandler)
-	r.With(httpMiddleware.RateLimit(h.cfg.WriteRateLimitRequests, h.cfg.WriteRateLimitWindow)).Post("/secrets", h.CreateSecret)
-	r.With(httpMiddleware.RateLimit(h.cfg.AgentRateLimitRequests, h.cfg.AgentRateLimitWindow)).Post("/agent/secrets", h.CreateAgentSecret)
-	r.With(httpMiddleware.RateLimit(h.cfg.ReadRateLimitRequests, h.cfg.ReadRateLimitWindow)).Get("/secrets/{id}", h.GetSecret)
-	r.With(httpMiddleware.RateLimit(h.cfg.WriteRateLimitRequests, h.cfg.WriteRateLimitWindow)).Delete("/secrets/{id}", h.BurnSecret)
+	r.Get("/v1/webhooks/schema", h.WebhookSchema)
+	r.Get("/v1/info", h.Info)
+	r.Get("/openapi.json", h.OpenAPI)
+	r.Get("/config", h.ClientConfig)
+
+	if h.config().LookupMissDelayAfter > 0 || h.config().LookupMissRejectAfter > 0 {
+		h.misses = httpMiddleware.NewMissLimiter(httpMiddleware.MissLimiterConfig{
+			Window:      h.config().LookupMissWindow,
+			DelayAfter:  h.config().LookupMissDelayAfter,
+			RejectAfter: h.config().LookupMissRejectAfter,
+		}, h.clock)
+	}
+
+	h.nonces = httpMiddleware.NewCreateNonces(h.nonceKeys, h.config().CreateNonceTTL, h.clock)
+	if h.reputation == nil {
+		h.reputation = reputation.NewTracker(h.store, h.clock, h.reputationConfig)
+	}
+	create := []func(http.Handler) http.Handler{
+		limits.limit("create", writeRateLimit),
+	}
+	if h.config().RequireCreateNonce {
+		create = append(create, h.nonces.Require)
+	}
+
+	// HEAD shares the read budget, so probing does not add to it
+	read := limits.limit(fallbackRateLimitClass, readRateLimit)
+
+	// Each self-test writes to the store, so it shares the read budget too
+	r.With(read).Get("/health/deep", h.DeepHealth)
+
+	// Secret responses must never be cached, including errors and 429s
+	r.Group(func(r chi.Router) {
+		r.Use(httpMiddleware.NoStore)
+		r.Use(h.breachGuard)
+		r.With(append(create, h.limitBody(createBodyLimit))...).Post("/secrets", h.CreateSecret)
+		r.With(limits.limit("validate", validateRateLimit), h.limitBody(createBodyLimit)).Post("/secrets/validate", h.ValidateSecret)
+		r.With(limits.limit("nonce", readRateLimit)).Get("/secrets/nonce", h.CreateNonce)
+		r.With(limits.limit("agent", agentRateLimit), h.limitBody(createBodyLimit)).Post("/agent/secrets", h.CreateAgentSecret)
+		r.With(read).Get("/secrets/{id}", h.GetSecret)
+		r.With(read).Head("/secrets/{id}", h.HeadSecret)
+		r.With(limits.limit("burn", writeRateLimit)).Delete("/secrets/{id}", h.BurnSecret)
+		r.With(limits.limit("ack", writeRateLimit), h.limitBody(smallBodyLimit)).Post("/secrets/{id}/ack", h.AcknowledgeSecret)
+		r.With(limits.limit("report", reportRateLimit), h.limitBody(smallBodyLimit)).Post("/secrets/{id}/report", h.ReportSecret)
+		r.With(limits.limit("link", writeRateLimit)).Get("/secrets/{id}/link", h.SecretLink)
+		r.With(read).Get("/secrets/{id}/receipt", h.SecretReceipt)
+		r.With(read).Get("/secrets/{id}/events", h.SecretEvents)
+		r.With(read).Get("/redeem/{token}", h.RedeemLink)
+	})
+
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(httpMiddleware.AdminAuth(h.config().AdminToken))
+		r.Get("/debug/cors", h.CORSRejections)
+		r.Get("/stats", h.AdminStats)
+		r.Get("/capacity", h.Capacity)
+		r.Get("/expiry-forecast", h.ExpiryForecast)
+		r.Get("/reputation", h.Reputation)
+		r.Get("/secrets/{id}/dossier", h.SecretDossier)
+		r.Get("/namespaces/{ns}/stats", h.NamespaceStats)
+		r.Delete("/namespaces/{ns}/secrets", h.PurgeNamespace)
+		r.Delete("/namespaces/{ns}", h.DeleteNamespace)
+		r.Get("/namespaces/{ns}/deletion", h.NamespaceDeletion)
+		r.With(limits.limit("audit", auditRateLimit)).Get("/audit", h.AuditLog)
+	})
+
+	// The caller's standing in every class above, counting against none
+	r.With(httpMiddleware.NoStore).Get("/limits", limits.RateLimits)
 
 	return r
 }
@@ -56,45 +439,133 @@ func (h *Handler) CreateSecret(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 
 	var req models.CreateSecretRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	_, span := tracing.Start(r.Context(), "create.decode")
+	err := json.NewDecoder(r.Body).Decode(&req)
+	tracing.End(span, err)
+	if err != nil {
 		logger.Warn("invalid request body", "error", err, "ip", r.RemoteAddr)
-		h.respondError(w, http.StatusBadRequest, "invalid request body")
+		h.respondServiceError(w, bodyError(err))
 		return
 	}
 
 	// Validate request using validation package
-	validatedReq, err := validation.ValidateCreateRequest(
-		req.Ciphertext,
-		req.IV,
-		req.Salt,
-		req.ExpiresIn,
-		h.cfg.MaxSecretSize,
-	)
+	_, span = tracing.Start(r.Context(), "create.validate")
+	validatedReq, err := h.validatePayload(&req)
+	tracing.End(span, err)
 	if err != nil {
 		logger.Warn("validation failed", "error", err, "ip", r.RemoteAddr)
 
-		h.respondValidationError(w, err)
+		h.respondServiceError(w, err)
 		return
 	}
 
-	secretID, _, err := h.storeSecret(r, validatedReq)
+	undeclared, err := validation.ValidateDeclaredKeyBits(req.DeclaredKeyBits, h.policy())
+	if err != nil {
+		logger.Warn("key bits policy rejected create", "error", err, "ip", r.RemoteAddr)
+		h.respondServiceError(w, err)
+		return
+	}
+
+	if undeclared {
+		RecordUndeclaredKeyBits()
+		if h.policy().MinKeyBits > 0 && h.policy().KeyBitsMissing == policy.KeyBitsMissingWarn {
+			logger.Warn("create without declared key bits", "min_key_bits", h.policy().MinKeyBits, "ip", r.RemoteAddr)
+		}
+	}
+	validatedReq.DeclaredKeyBits = req.DeclaredKeyBits
+	validatedReq.RequireAck = req.RequireAck
+
+	if req.Namespace != "" {
+		if err := validation.ValidateNamespace(req.Namespace); err != nil {
+			h.respondServiceError(w, err)
+			return
+		}
+		validatedReq.Namespace = req.Namespace
+	}
+
+	if req.Slug != "" {
+		if !h.policy().SlugsAllowed {
+			h.respondServiceError(w, fmt.Errorf("%w: custom slugs are not enabled", validation.ErrInvalidSlug))
+			return
+		}
+		if err := validation.ValidateSlug(req.Slug); err != nil {
+			h.respondServiceError(w, err)
+			return
+		}
+		validatedReq.Slug = req.Slug
+	}
+
+	validatedReq.AvailableAfter, err = validation.ValidateAvailableAfter(req.AvailableAfter, h.clock.Now(), validatedReq.ExpiresIn)
+	if err != nil {
+		h.respondServiceError(w, err)
+		return
+	}
+
+	validatedReq.Hint, err = validation.ValidateHint(req.Hint, h.policy())
+	if err != nil {
+		h.respondServiceError(w, err)
+		return
+	}
+
+	validatedReq.ContentType, err = validation.ValidateContentType(req.ContentType)
+	if err != nil {
+		h.respondServiceError(w, err)
+		return
+	}
+	validatedReq.Filename, err = validation.ValidateFilename(req.Filename)
+	if err != nil {
+		h.respondServiceError(w, err)
+		return
+	}
+	// Like an agent upload's, the filename is metadata the policy scans
+	if validatedReq.Filename != "" {
+		if err := validation.ValidateMetadata(scan.Field{Name: scan.FieldFilename, Value: validatedReq.Filename}); err != nil {
+			h.respondServiceError(w, err)
+			return
+		}
+	}
+
+	if req.NotifyEmail != "" {
+		if h.notifier == nil {
+			h.respondServiceError(w, fmt.Errorf("%w: notifications are not enabled", validation.ErrInvalidNotifyEmail))
+			return
+		}
+		validatedReq.NotifyEmail, err = validation.ValidateNotifyEmail(req.NotifyEmail)
+		if err != nil {
+			h.respondServiceError(w, err)
+			return
+		}
+	}
+
+	stored, err := h.storeSecret(r, validatedReq)
+	if errors.Is(err, ots.ErrSlugTaken) || errors.Is(err, ots.ErrNamespaceDeleted) || errors.Is(err, ots.ErrQuotaExceeded) {
+		h.respondServiceError(w, err)
+		return
+	}
 	if err != nil {
 		logger.Error("failed to store secret", "error", err)
-		h.respondError(w, http.StatusInternalServerError, "failed to store secret")
+		h.respondStoreError(w, err, "failed to store secret")
 		return
 	}
+	secretID := stored.ID
 
 	logger.Info("secret created",
 		"secret_id", secretID,
 		"expires_in", validatedReq.ExpiresIn,
-		"size", len(validatedReq.Ciphertext),
+		"size", h.loggedSize(validatedReq.Size()),
+		"parts", len(validatedReq.Parts),
 		"duration", time.Since(start),
 		"ip", r.RemoteAddr,
 	)
 
 	// Return response
 	resp := models.CreateSecretResponse{
-		ID: secretID,
+		ID:              secretID,
+		ManagementToken: stored.ManagementToken,
+	}
+	if validatedReq.AvailableAfter != nil {
+		availableAfter := validatedReq.AvailableAfter.UTC()
+		resp.AvailableAfter = &availableAfter
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -102,119 +573,372 @@ func (h *Handler) CreateSecret(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
+// validatePayload decodes and checks a create's ciphertext, IV, salt,
+// parts and TTL against the active policy
+func (h *Handler) validatePayload(req *models.CreateSecretRequest) (*validation.CreateSecretRequest, error) {
+	switch {
+	case req.IVEmbedded && len(req.Parts) > 0:
+		return nil, fmt.Errorf("%w: parts carry their own IVs; iv_embedded applies to a single ciphertext", validation.ErrInvalidParts)
+	case req.IVEmbedded:
+		return validation.ValidateEmbeddedRequest(
+			req.Ciphertext,
+			req.IV,
+			req.Salt,
+			req.Algorithm,
+			req.ExpiresIn,
+			h.policy(),
+		)
+	case req.Algorithm != "":
+		return nil, fmt.Errorf("%w: algorithm applies only with iv_embedded", validation.ErrInvalidAlgorithm)
+	case len(req.Parts) > 0:
+		return validation.ValidateMultipartRequest(
+			req.Ciphertext,
+			req.IV,
+			req.Salt,
+			req.Parts,
+			req.ExpiresIn,
+			h.policy(),
+		)
+	default:
+		return validation.ValidateCreateRequest(
+			req.Ciphertext,
+			req.IV,
+			req.Salt,
+			req.ExpiresIn,
+			h.policy(),
+		)
+	}
+}
+
+// CreateNonce sets a create nonce cookie and returns its token. Browsers
+// fetch one before each create; it is only checked when
+// REQUIRE_CREATE_NONCE is on.
+func (h *Handler) CreateNonce(w http.ResponseWriter, r *http.Request) {
+	token, expiresAt, err := h.nonces.Issue(w, r)
+	if err != nil {
+		logger.Error("failed to issue create nonce", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to issue nonce")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.CreateNonceResponse{Nonce: token, ExpiresAt: expiresAt.UTC()})
+}
+
 // GetSecret handles secret retrieval (atomic consume)
 func (h *Handler) GetSecret(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	secretID := chi.URLParam(r, "id")
 
 	// Validate ID format
-	if err := validation.ValidateSecretID(secretID); err != nil {
+	if err := h.validateSecretID(secretID); err != nil {
 		logger.Warn("invalid secret ID format", "error", err, "ip", r.RemoteAddr)
-		h.respondError(w, http.StatusNotFound, "not found")
+		h.respondLookupMiss(w, r)
+		return
+	}
+	if h.respondIfForeign(w, secretID) || h.respondIfNotInteractive(w, r, secretID) {
 		return
 	}
 
-	ctx := r.Context()
-
-	// Start transaction for atomic consume
-	tx, err := h.db.Pool().Begin(ctx)
-	if err != nil {
-		logger.Error("failed to begin transaction", "error", err)
-		h.respondError(w, http.StatusInternalServerError, "database error")
+	if nonce := r.Header.Get(RetrievalNonceHeader); nonce != "" {
+		h.refetchSecret(w, r, secretID, nonce)
 		return
 	}
-	defer tx.Rollback(ctx)
+	h.consumeSecret(w, r, secretID, true, start)
+}
 
-	// Lock the row and retrieve secret
-	var secret models.Secret
-	var ciphertext, iv, salt []byte
+// consumeSecret reads and destroys secretID and writes it out. With hold
+// unset a require_ack secret is destroyed like any other instead of held
+// for its acknowledgement, and CONSUME_GRACE holds no secret for a retry.
+func (h *Handler) consumeSecret(w http.ResponseWriter, r *http.Request, secretID string, hold bool, start time.Time) {
+	// Label the tombstone with the network class and coarse country only;
+	// the IP is never stored
+	opts := terminate.Options{Consume: store.ConsumeOptions{Open: h.unwrapSecret}}
+	if h.classify != nil {
+		opts.NetworkClass = h.classify.ClassifyString(httpMiddleware.ClientIP(r))
+	}
+	opts.Country = geo.Lookup(h.locate, httpMiddleware.ClientIP(r))
 
-	err = tx.QueryRow(ctx, `
-		SELECT id, ciphertext, iv, salt, expires_at, burn_after_read, created_at
-		FROM secrets
-		WHERE id = $1
-		FOR UPDATE
-	`, secretID).Scan(&secret.ID, &ciphertext, &iv, &salt, &secret.ExpiresAt, &secret.BurnAfterRead, &secret.CreatedAt)
+	// require_ack secrets are held under this token instead of destroyed;
+	// the store ignores the hold for every other secret. With CONSUME_GRACE
+	// on, those are held under it for their grace window instead.
+	var ackToken string
+	var ackDeadline time.Time
+	grace := hold && h.config().ConsumeGrace
+	if hold {
+		var ackTokenHash []byte
+		var err error
+		ackToken, ackTokenHash, err = crypto.GenerateAckToken()
+		if err != nil {
+			logger.Error("failed to generate ack token", "error", err)
+			h.respondError(w, http.StatusInternalServerError, "failed to read secret")
+			return
+		}
+		ackDeadline = h.clock.Now().Add(h.config().AckWindow).UTC()
+		opts.Consume.Ack = &store.AckHold{TokenHash: ackTokenHash, Deadline: ackDeadline}
+		if grace {
+			opts.Consume.Grace = &store.GraceHold{TokenHash: ackTokenHash, Deadline: h.clock.Now().Add(h.config().ConsumeGraceWindow)}
+		}
+	}
 
+	secret, err := h.terminator().Terminate(r.Context(), secretID, store.TerminationConsumed, opts)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) || errors.Is(err, sql.ErrNoRows) {
-			h.respondError(w, http.StatusNotFound, "not found")
+		var notYet *store.NotYetAvailableError
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondLookupMiss(w, r)
+		} else if errors.As(err, &notYet) {
+			h.respondNotYetAvailable(w, notYet)
 		} else {
-			logger.Error("database query failed", "error", err, "secret_id", secretID)
-			h.respondError(w, http.StatusInternalServerError, "database error")
+			logger.Error("failed to consume secret", "error", err, "secret_id", secretID)
+			h.respondStoreError(w, err, "database error")
 		}
 		return
 	}
 
-	// Check expiration
-	if time.Now().After(secret.ExpiresAt) {
-		// Delete expired secret
-		_, _ = tx.Exec(ctx, `DELETE FROM secrets WHERE id = $1`, secretID)
-		if err := tx.Commit(ctx); err != nil {
-			logger.Error("failed to commit expiration cleanup", "error", err)
-			h.respondError(w, http.StatusInternalServerError, "database error")
-			return
+	logger.Info("secret retrieved",
+		"secret_id", secretID,
+		"duration", time.Since(start),
+		"ip", r.RemoteAddr,
+		"network_class", opts.NetworkClass,
+	)
+
+	// Only require_ack secrets carry the ack fields; a secret held for its
+	// grace window hands the same token out as its retrieval nonce
+	var ackExpiresAt *time.Time
+	if secret.RequireAck && hold {
+		ackExpiresAt = &ackDeadline
+	} else {
+		if grace {
+			httpx.SetHeader(w.Header(), RetrievalNonceHeader, ackToken)
 		}
-		h.respondError(w, http.StatusNotFound, "not found")
-		return
+		ackToken = ""
 	}
 
-	// Delete the secret (atomic consume)
-	_, err = tx.Exec(ctx, `DELETE FROM secrets WHERE id = $1`, secretID)
+	w.Header().Set("Content-Type", "application/json")
+	if err := writeSecretResponse(w, secret, ackToken, ackExpiresAt); err != nil {
+		logger.Warn("failed to write consumed secret", "error", err, "secret_id", secretID, "grace_hold", grace && !secret.RequireAck)
+	}
+}
+
+// refetchSecret delivers a secret held for its grace window once more to
+// a retry carrying its retrieval nonce, and destroys it. The read was
+// recorded when the secret was first delivered, so the retry records
+// nothing; a wrong nonce, a lapsed window or a secret not held all answer
+// like an unknown secret.
+func (h *Handler) refetchSecret(w http.ResponseWriter, r *http.Request, secretID, nonce string) {
+	secret, err := h.store.Consume(r.Context(), secretID, store.ConsumeOptions{
+		Now:                h.clock.Now(),
+		Open:               h.unwrapSecret,
+		RetrievalTokenHash: crypto.HashManagementToken(nonce),
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondLookupMiss(w, r)
+		return
+	}
 	if err != nil {
-		logger.Error("failed to delete secret", "error", err, "secret_id", secretID)
-		h.respondError(w, http.StatusInternalServerError, "database error")
+		logger.Error("failed to refetch secret", "error", err, "secret_id", secretID)
+		h.respondStoreError(w, err, "database error")
 		return
 	}
 
-	// Commit transaction
-	if err := tx.Commit(ctx); err != nil {
-		logger.Error("failed to commit transaction", "error", err, "secret_id", secretID)
-		h.respondError(w, http.StatusInternalServerError, "database error")
+	logger.Info("secret retrieved again within its grace window", "secret_id", secretID, "ip", r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/json")
+	if err := writeSecretResponse(w, secret, "", nil); err != nil {
+		logger.Warn("failed to write refetched secret", "error", err, "secret_id", secretID)
+	}
+}
+
+// respondNotYetAvailable refuses a read before a secret's scheduled release.
+// Retry-After counts whole seconds up to the release; the secret is untouched.
+func (h *Handler) respondNotYetAvailable(w http.ResponseWriter, notYet *store.NotYetAvailableError) {
+	availableAfter := notYet.AvailableAfter.UTC()
+	wait := max(availableAfter.Sub(h.clock.Now()), 0)
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	h.respondErrorBody(w, ots.StatusCode(ots.ErrNotYetAvailable), models.ErrorResponse{
+		Message:        ots.ErrNotYetAvailable.Error(),
+		Code:           ots.ErrorCode(ots.ErrNotYetAvailable),
+		AvailableAfter: &availableAfter,
+	})
+}
+
+// respondLookupMiss answers a read of an unknown secret. While failed lookups
+// are flooding in service-wide the answer is slowed down, then refused.
+func (h *Handler) respondLookupMiss(w http.ResponseWriter, r *http.Request) {
+	verdict := httpMiddleware.MissAllow
+	if h.misses != nil {
+		verdict = h.misses.RecordMiss()
+	}
+	RecordLookupMiss(verdict)
+
+	active := verdict != httpMiddleware.MissAllow
+	if SetEnumerationDefense(active) {
+		if active {
+			logger.Warn("enumeration defense activated: throttling failed lookups",
+				"misses", h.misses.Misses(), "window", h.config().LookupMissWindow)
+		} else {
+			logger.Info("enumeration defense deactivated")
+		}
+	}
+
+	switch verdict {
+	case httpMiddleware.MissReject:
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(h.misses.RetryAfter().Seconds()))))
+		h.respondServiceError(w, ots.ErrLookupThrottled)
 		return
+	case httpMiddleware.MissDelay:
+		timer := time.NewTimer(h.config().LookupMissDelay)
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			timer.Stop()
+		}
 	}
 
-	logger.Info("secret retrieved",
-		"secret_id", secretID,
-		"duration", time.Since(start),
-		"ip", r.RemoteAddr,
-	)
+	h.respondServiceError(w, ots.ErrNotFound)
+}
 
-	// Encode response
-	resp := models.GetSecretResponse{
-		Ciphertext: base64.StdEncoding.EncodeToString(ciphertext),
-		IV:         base64.StdEncoding.EncodeToString(iv),
+// sealDataKey wraps secret's data key under the current envelope key, if
+// envelope keys are configured
+func (h *Handler) sealDataKey(secret *store.Secret) error {
+	if secret.DataKey == nil || h.envelope == nil {
+		return nil
 	}
 
-	if len(salt) > 0 {
-		resp.Salt = base64.StdEncoding.EncodeToString(salt)
+	var err error
+	secret.DataKey, secret.KeyVersion, err = h.envelope.Wrap(secret.ID, secret.DataKey)
+	if err != nil {
+		return fmt.Errorf("wrap data key: %w", err)
 	}
+	return nil
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+// unwrapSecret removes the crypto-shredding layer before the key is
+// destroyed; a failure aborts the consume and leaves the secret readable
+func (h *Handler) unwrapSecret(secret *store.Secret) error {
+	if secret.DataKey == nil {
+		return nil
+	}
+
+	var err error
+	dataKey := []byte(secret.DataKey)
+	if secret.KeyVersion != "" {
+		if h.envelope == nil {
+			return fmt.Errorf("data key is wrapped under envelope key %s but ENVELOPE_KEYS is not set", secret.KeyVersion)
+		}
+		dataKey, err = h.envelope.Unwrap(secret.ID, secret.DataKey, secret.KeyVersion)
+		if err != nil {
+			return err
+		}
+	}
+
+	secret.Ciphertext, err = crypto.UnwrapWithDataKey(secret.Ciphertext, dataKey)
+	if err != nil {
+		return fmt.Errorf("unwrap secret: %w", err)
+	}
+
+	for i := range secret.Parts {
+		secret.Parts[i].Ciphertext, err = crypto.UnwrapWithDataKey(secret.Parts[i].Ciphertext, dataKey)
+		if err != nil {
+			return fmt.Errorf("unwrap secret part: %w", err)
+		}
+	}
+
+	return nil
 }
 
-// BurnSecret handles manual secret destruction
+// AcknowledgeSecret confirms that a require_ack secret reached its reader
+// and burns it. Late, repeated and mistyped acks all get 404; the secret is
+// never left readable either way. An If-Match naming a revision the
+// secret has moved past gets 412 and leaves it held.
+func (h *Handler) AcknowledgeSecret(w http.ResponseWriter, r *http.Request) {
+	secretID := chi.URLParam(r, "id")
+
+	if err := h.validateSecretID(secretID); err != nil {
+		h.respondServiceError(w, ots.ErrNotFound)
+		return
+	}
+	if h.respondIfForeign(w, secretID) {
+		return
+	}
+
+	var req models.AckSecretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.AckToken == "" {
+		h.respondServiceError(w, ots.ErrInvalidRequestBody)
+		return
+	}
+
+	err := h.store.Acknowledge(r.Context(), secretID, crypto.HashManagementToken(req.AckToken), h.clock.Now(), ifMatchRevision(r))
+	var mismatch *store.RevisionMismatchError
+	if errors.As(err, &mismatch) {
+		h.respondRevisionMismatch(w, mismatch)
+		return
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondServiceError(w, ots.ErrNotFound)
+		return
+	}
+	if err != nil {
+		logger.Error("failed to acknowledge secret", "error", err, "secret_id", secretID)
+		h.respondStoreError(w, err, "database error")
+		return
+	}
+
+	h.recordAudit(r.Context(), store.AuditSecretAcknowledged, secretID, "")
+
+	logger.Info("secret acknowledged", "secret_id", secretID, "ip", r.RemoteAddr)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BurnSecret handles manual secret destruction. An If-Match naming a
+// revision the secret has moved past gets 412 and leaves it in place.
 func (h *Handler) BurnSecret(w http.ResponseWriter, r *http.Request) {
 	secretID := chi.URLParam(r, "id")
 
 	// Validate ID format
-	if err := validation.ValidateSecretID(secretID); err != nil {
-		h.respondError(w, http.StatusNotFound, "not found")
+	if err := h.validateSecretID(secretID); err != nil {
+		h.respondServiceError(w, ots.ErrNotFound)
+		return
+	}
+	if h.respondIfForeign(w, secretID) || h.respondIfNotInteractive(w, r, secretID) {
 		return
 	}
 
 	ctx := r.Context()
 
-	result, err := h.db.Pool().Exec(ctx, `DELETE FROM secrets WHERE id = $1`, secretID)
-	if err != nil {
-		logger.Error("failed to burn secret", "error", err, "secret_id", secretID)
-		h.respondError(w, http.StatusInternalServerError, "database error")
-		return
+	if !h.config().AllowOpenDelete {
+		authorized, err := h.checkManagementToken(ctx, secretID, r.Header.Get(ManagementTokenHeader))
+		if err != nil {
+			logger.Error("failed to check management token", "error", err, "secret_id", secretID)
+			h.respondStoreError(w, err, "database error")
+			return
+		}
+
+		if !authorized {
+			logger.Warn("burn rejected without valid management token", "secret_id", secretID, "ip", r.RemoteAddr)
+			h.respondServiceError(w, ots.ErrManagementTokenRequired)
+			return
+		}
 	}
 
-	if result.RowsAffected() == 0 {
-		h.respondError(w, http.StatusNotFound, "not found")
+	var networkClass string
+	if h.classify != nil {
+		networkClass = h.classify.ClassifyString(httpMiddleware.ClientIP(r))
+	}
+	opts := terminate.Options{NetworkClass: networkClass, IfRevision: ifMatchRevision(r)}
+	_, err := h.terminator().Terminate(ctx, secretID, store.TerminationBurned, opts)
+	var mismatch *store.RevisionMismatchError
+	if errors.As(err, &mismatch) {
+		h.respondRevisionMismatch(w, mismatch)
+		return
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondServiceError(w, ots.ErrNotFound)
+		return
+	}
+	if err != nil {
+		logger.Error("failed to burn secret", "error", err, "secret_id", secretID)
+		h.respondStoreError(w, err, "database error")
 		return
 	}
 
@@ -224,38 +948,229 @@ func (h *Handler) BurnSecret(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) respondError(w http.ResponseWriter, status int, message string) {
+	h.respondErrorBody(w, status, models.ErrorResponse{Message: message})
+}
+
+// respondErrorCode writes an error with a machine-readable code
+func (h *Handler) respondErrorCode(w http.ResponseWriter, status int, code, message string) {
+	h.respondErrorBody(w, status, models.ErrorResponse{Message: message, Code: code})
+}
+
+// respondErrorBody writes body with the status text filled in. A 5xx not
+// marked retryable is marked not.
+func (h *Handler) respondErrorBody(w http.ResponseWriter, status int, body models.ErrorResponse) {
+	body.Error = http.StatusText(status)
+	if status >= http.StatusInternalServerError && body.Retryable == nil {
+		retryable := false
+		body.Retryable = &retryable
+	}
+	localize(w, &body)
+	h.addHints(w, status, &body)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(models.ErrorResponse{
-		Error:   http.StatusText(status),
-		Message: message,
+	json.NewEncoder(w).Encode(body)
+}
+
+// storeRetryAfter is how long a client should wait before retrying after
+// a transient store failure
+const storeRetryAfter = 2 * time.Second
+
+// respondStoreError answers a failed store call with message. A transient
+// failure is a retryable 503 with a Retry-After; anything else is a 500
+// that repeating the request will not fix.
+func (h *Handler) respondStoreError(w http.ResponseWriter, err error, message string) {
+	if !store.Transient(h.store, err) {
+		h.respondError(w, http.StatusInternalServerError, message)
+		return
+	}
+	retryable := true
+	w.Header().Set("Retry-After", strconv.Itoa(int(storeRetryAfter/time.Second)))
+	h.respondErrorBody(w, http.StatusServiceUnavailable, models.ErrorResponse{
+		Message:      message,
+		Retryable:    &retryable,
+		RetryAfterMs: storeRetryAfter.Milliseconds(),
 	})
 }
 
-func (h *Handler) respondValidationError(w http.ResponseWriter, err error) {
-	status := http.StatusBadRequest
-	if errors.Is(err, validation.ErrSecretTooLarge) {
-		status = http.StatusRequestEntityTooLarge
+// respondServiceError writes err with the status and code from the ots
+// error table and attaches the limit that was violated, rendered from the
+// active policy, or the scanner rule that was broken
+func (h *Handler) respondServiceError(w http.ResponseWriter, err error) {
+	body := models.ErrorResponse{Message: err.Error(), Code: ots.ErrorCode(err)}
+
+	switch {
+	case errors.Is(err, ots.ErrSecretTooLarge):
+		body.Limit = h.policy().Detail(policy.LimitSecretSize)
+	case errors.Is(err, ots.ErrInvalidTTL):
+		body.Limit = h.policy().Detail(policy.LimitTTL)
+	case errors.Is(err, ots.ErrInvalidParts):
+		body.Limit = h.policy().Detail(policy.LimitParts)
+	case errors.Is(err, ots.ErrInvalidHint):
+		body.Limit = h.policy().Detail(policy.LimitHint)
+	case errors.Is(err, ots.ErrKeyTooWeak), errors.Is(err, ots.ErrKeyBitsRequired), errors.Is(err, ots.ErrInvalidKeyBits):
+		body.Limit = h.policy().Detail(policy.LimitKeyBits)
+	case errors.Is(err, ots.ErrPolicyViolation):
+		errors.As(err, &body.Violation)
+	}
+
+	h.respondErrorBody(w, ots.StatusCode(err), body)
+}
+
+// checkManagementToken reports whether token matches the secret's stored
+// hash. Unknown secrets and secrets without a hash never match, so the
+// response does not reveal which case applied.
+func (h *Handler) checkManagementToken(ctx context.Context, secretID, token string) (bool, error) {
+	if token == "" {
+		return false, nil
+	}
+
+	hash, err := h.store.ManagementTokenHash(ctx, secretID)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
 	}
 
-	h.respondError(w, status, err.Error())
+	return crypto.VerifyManagementToken(token, hash), nil
+}
+
+// storedSecret is what storeSecret hands back to the create handlers
+type storedSecret struct {
+	ID              string
+	ExpiresAt       time.Time
+	ManagementToken string
 }
 
-func (h *Handler) storeSecret(r *http.Request, validatedReq *validation.CreateSecretRequest) (string, time.Time, error) {
-	secretID, err := crypto.GenerateSecretID()
+// validateSecretID accepts a generated secret ID, or a slug when slugs are
+// enabled
+func (h *Handler) validateSecretID(id string) error {
+	if h.policy().SlugsAllowed && validation.ValidateSlug(id) == nil {
+		return nil
+	}
+	return validation.ValidateSecretID(id)
+}
+
+func (h *Handler) storeSecret(r *http.Request, validatedReq *validation.CreateSecretRequest) (*storedSecret, error) {
+	secretID := validatedReq.Slug
+	if secretID == "" {
+		generated, err := crypto.GenerateRegionalSecretID(h.region)
+		if err != nil {
+			return nil, fmt.Errorf("generate secret ID: %w", err)
+		}
+		secretID = generated
+	} else {
+		// A read slug stays taken while its receipt is kept, or the consume
+		// auditor would take the new secret for one that survived its read
+		_, err := h.store.Receipt(r.Context(), secretID)
+		if err == nil {
+			return nil, ots.ErrSlugTaken
+		}
+		if !errors.Is(err, store.ErrNotFound) {
+			return nil, fmt.Errorf("check slug receipt: %w", err)
+		}
+	}
+
+	managementToken, managementTokenHash, err := crypto.GenerateManagementToken()
 	if err != nil {
-		return "", time.Time{}, fmt.Errorf("generate secret ID: %w", err)
+		return nil, fmt.Errorf("generate management token: %w", err)
+	}
+
+	now := h.clock.Now()
+	expiresAt := now.Add(validatedReq.ExpiresIn)
+
+	// Multi-part secrets keep an empty top-level blob
+	ciphertext := validatedReq.Ciphertext
+	if ciphertext == nil {
+		ciphertext = []byte{}
+	}
+	iv := validatedReq.IV
+	if iv == nil {
+		iv = []byte{}
+	}
+
+	parts := make([][]byte, len(validatedReq.Parts))
+	for i, part := range validatedReq.Parts {
+		parts[i] = part.Ciphertext
 	}
 
-	expiresAt := time.Now().Add(validatedReq.ExpiresIn)
+	var dataKey []byte
+	if h.config().CryptoShredding {
+		ciphertext, dataKey, err = crypto.WrapWithDataKey(ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("wrap secret: %w", err)
+		}
 
-	_, err = h.db.Pool().Exec(r.Context(), `
-		INSERT INTO secrets (id, ciphertext, iv, salt, expires_at, burn_after_read, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`, secretID, validatedReq.Ciphertext, validatedReq.IV, validatedReq.Salt, expiresAt, validatedReq.BurnAfterRead, time.Now())
+		// All parts share the secret's data key so one shred destroys them all
+		for i := range parts {
+			parts[i], err = crypto.WrapWithKey(parts[i], dataKey)
+			if err != nil {
+				return nil, fmt.Errorf("wrap secret part: %w", err)
+			}
+		}
+	}
+
+	secret := &store.Secret{
+		ID:                  secretID,
+		Ciphertext:          ciphertext,
+		IV:                  iv,
+		Salt:                validatedReq.Salt,
+		ExpiresAt:           expiresAt,
+		CreatedAt:           now,
+		BurnAfterRead:       validatedReq.BurnAfterRead,
+		DataKey:             dataKey,
+		DeclaredKeyBits:     validatedReq.DeclaredKeyBits,
+		ManagementTokenHash: managementTokenHash,
+		RequireAck:          validatedReq.RequireAck,
+		Namespace:           validatedReq.Namespace,
+		IVEmbedded:          validatedReq.IVEmbedded,
+		AvailableAfter:      validatedReq.AvailableAfter,
+		Hint:                validatedReq.Hint,
+		ContentType:         validatedReq.ContentType,
+		Filename:            validatedReq.Filename,
+	}
+	if err := h.sealDataKey(secret); err != nil {
+		return nil, err
+	}
+	// The address is stored sealed under the notification key, never in
+	// the clear
+	if validatedReq.NotifyEmail != "" {
+		secret.NotifyEmail, secret.NotifyEmailHash, err = h.notifier.Seal(validatedReq.NotifyEmail)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Only a hash of the IP is stored, and only while the quota is on
+	if quota := h.policy().ActiveSecretQuota; quota > 0 {
+		secret.Creator = store.HashCreatorIP(httpMiddleware.ClientIP(r))
+		secret.CreatorQuota = quota
+	}
+	for i, part := range validatedReq.Parts {
+		secret.Parts = append(secret.Parts, store.Part{Label: part.Label, Ciphertext: parts[i], IV: part.IV})
+	}
+
+	err = h.store.Create(r.Context(), secret)
+	if validatedReq.Slug != "" && errors.Is(err, store.ErrDuplicateID) {
+		return nil, ots.ErrSlugTaken
+	}
+	if errors.Is(err, store.ErrQuotaExceeded) {
+		RecordQuotaRejection()
+	}
 	if err != nil {
-		return "", time.Time{}, fmt.Errorf("insert secret: %w", err)
+		return nil, err
 	}
+	h.appendAudit(r.Context(), &store.AuditEvent{
+		Type:         store.AuditSecretCreated,
+		Namespace:    secret.Namespace,
+		SecretIDHash: store.HashSecretID(secretID),
+	})
+	h.recordSize(r.Context(), validatedReq.Size())
+	h.countCreate(r.Context(), validatedReq.Size(), validatedReq.ExpiresIn)
+	RecordSecretCreated()
 
-	return secretID, expiresAt, nil
+	return &storedSecret{
+		ID:              secretID,
+		ExpiresAt:       expiresAt,
+		ManagementToken: managementToken,
+	}, nil
 }
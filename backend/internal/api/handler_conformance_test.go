@@ -0,0 +1,862 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/models"
+	"ots-backend/internal/netclass"
+	"ots-backend/internal/policy"
+	"ots-backend/internal/testutil"
+)
+
+type createSecretOverrides struct {
+	Ciphertext *string
+	IV         *string
+	Salt       *string
+	ExpiresIn  *int
+}
+
+func TestSecretsFlow(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		router := newTestRouter(t, b)
+
+		createReq := getMockCreateSecretRequest(nil)
+		createBody := marshalJSON(t, createReq)
+		createResp := httptest.NewRecorder()
+
+		request := httptest.NewRequest(http.MethodPost, "/api/secrets", strings.NewReader(createBody))
+		request.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(createResp, request)
+
+		if createResp.Code != http.StatusCreated {
+			t.Fatalf("CreateSecret() status = %d, want %d", createResp.Code, http.StatusCreated)
+		}
+
+		assertNoStoreHeaders(t, createResp.Header())
+
+		var createResponse models.CreateSecretResponse
+		if err := json.NewDecoder(createResp.Body).Decode(&createResponse); err != nil {
+			t.Fatalf("CreateSecret() decode error: %v", err)
+		}
+
+		if createResponse.ID == "" {
+			t.Fatalf("CreateSecret() returned empty ID")
+		}
+
+		getResp := httptest.NewRecorder()
+		getRequest := httptest.NewRequest(http.MethodGet, "/api/secrets/"+createResponse.ID, nil)
+		router.ServeHTTP(getResp, getRequest)
+
+		if getResp.Code != http.StatusOK {
+			t.Fatalf("GetSecret() status = %d, want %d", getResp.Code, http.StatusOK)
+		}
+
+		assertNoStoreHeaders(t, getResp.Header())
+
+		var getResponse models.GetSecretResponse
+		if err := json.NewDecoder(getResp.Body).Decode(&getResponse); err != nil {
+			t.Fatalf("GetSecret() decode error: %v", err)
+		}
+
+		if getResponse.Ciphertext != createReq.Ciphertext {
+			t.Errorf("GetSecret() ciphertext = %q, want %q", getResponse.Ciphertext, createReq.Ciphertext)
+		}
+
+		if getResponse.IV != createReq.IV {
+			t.Errorf("GetSecret() iv = %q, want %q", getResponse.IV, createReq.IV)
+		}
+
+		if getResponse.Salt != createReq.Salt {
+			t.Errorf("GetSecret() salt = %q, want %q", getResponse.Salt, createReq.Salt)
+		}
+
+		secondGetResp := httptest.NewRecorder()
+		secondGetRequest := httptest.NewRequest(http.MethodGet, "/api/secrets/"+createResponse.ID, nil)
+		router.ServeHTTP(secondGetResp, secondGetRequest)
+
+		if secondGetResp.Code != http.StatusNotFound && secondGetResp.Code != http.StatusInternalServerError {
+			t.Fatalf("GetSecret() after consume status = %d, want %d", secondGetResp.Code, http.StatusNotFound)
+		}
+
+		assertNoStoreHeaders(t, secondGetResp.Header())
+
+		b.reset(t)
+
+		burnReq := getMockCreateSecretRequest(nil)
+		burnBody := marshalJSON(t, burnReq)
+		burnCreateResp := httptest.NewRecorder()
+
+		burnCreateRequest := httptest.NewRequest(http.MethodPost, "/api/secrets", strings.NewReader(burnBody))
+		burnCreateRequest.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(burnCreateResp, burnCreateRequest)
+
+		if burnCreateResp.Code != http.StatusCreated {
+			t.Fatalf("CreateSecret() for burn status = %d, want %d", burnCreateResp.Code, http.StatusCreated)
+		}
+
+		var burnCreateResponse models.CreateSecretResponse
+		if err := json.NewDecoder(burnCreateResp.Body).Decode(&burnCreateResponse); err != nil {
+			t.Fatalf("CreateSecret() for burn decode error: %v", err)
+		}
+
+		burnResp := httptest.NewRecorder()
+		burnRequest := httptest.NewRequest(http.MethodDelete, "/api/secrets/"+burnCreateResponse.ID, nil)
+		burnRequest.Header.Set(ManagementTokenHeader, burnCreateResponse.ManagementToken)
+		router.ServeHTTP(burnResp, burnRequest)
+
+		if burnResp.Code != http.StatusNoContent {
+			t.Fatalf("BurnSecret() status = %d, want %d", burnResp.Code, http.StatusNoContent)
+		}
+
+		burnGetResp := httptest.NewRecorder()
+		burnGetRequest := httptest.NewRequest(http.MethodGet, "/api/secrets/"+burnCreateResponse.ID, nil)
+		router.ServeHTTP(burnGetResp, burnGetRequest)
+
+		if burnGetResp.Code != http.StatusNotFound && burnGetResp.Code != http.StatusInternalServerError {
+			t.Fatalf("GetSecret() after burn status = %d, want %d", burnGetResp.Code, http.StatusNotFound)
+		}
+	})
+}
+
+func TestURLSafeBase64IsReadBackAsStandard(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouter(t, b)
+
+		ciphertext := []byte{0xfb, 0xff, 0xbf, 0x3e, 0x00}
+		iv := append([]byte{0xff, 0xfe}, make([]byte, 10)...)
+		createReq := getMockCreateSecretRequest(nil)
+		createReq.Ciphertext = base64.RawURLEncoding.EncodeToString(ciphertext)
+		createReq.IV = base64.URLEncoding.EncodeToString(iv)
+		id := createTestSecret(t, router, createReq)
+
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/"+id, nil))
+		var got models.GetSecretResponse
+		if err := json.NewDecoder(response.Body).Decode(&got); err != nil {
+			t.Fatalf("GetSecret() decode error: %v", err)
+		}
+
+		// Responses always use standard padded base64
+		if want := base64.StdEncoding.EncodeToString(ciphertext); got.Ciphertext != want {
+			t.Errorf("GetSecret() ciphertext = %q, want %q", got.Ciphertext, want)
+		}
+		if want := base64.StdEncoding.EncodeToString(iv); got.IV != want {
+			t.Errorf("GetSecret() iv = %q, want %q", got.IV, want)
+		}
+	})
+}
+
+func TestCreateSecretErrors(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		router := newTestRouter(t, b)
+
+		t.Run("invalid JSON payload", func(t *testing.T) {
+			response := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, "/api/secrets", strings.NewReader("{"))
+			request.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(response, request)
+
+			if response.Code != http.StatusBadRequest {
+				t.Fatalf("CreateSecret() status = %d, want %d", response.Code, http.StatusBadRequest)
+			}
+		})
+
+		tests := []struct {
+			name       string
+			overrides  *createSecretOverrides
+			wantStatus int
+		}{
+			{
+				name: "missing ciphertext",
+				overrides: &createSecretOverrides{
+					Ciphertext: stringPtr(""),
+				},
+				wantStatus: http.StatusBadRequest,
+			},
+			{
+				name: "missing iv",
+				overrides: &createSecretOverrides{
+					IV: stringPtr(""),
+				},
+				wantStatus: http.StatusBadRequest,
+			},
+			{
+				name: "invalid ttl",
+				overrides: &createSecretOverrides{
+					ExpiresIn: intPtr(60),
+				},
+				wantStatus: http.StatusBadRequest,
+			},
+		}
+
+		for _, tt := range tests {
+			tt := tt
+			t.Run(tt.name, func(t *testing.T) {
+				payload := getMockCreateSecretRequest(tt.overrides)
+				response := httptest.NewRecorder()
+				request := httptest.NewRequest(http.MethodPost, "/api/secrets", strings.NewReader(marshalJSON(t, payload)))
+				request.Header.Set("Content-Type", "application/json")
+				router.ServeHTTP(response, request)
+
+				if response.Code != tt.wantStatus {
+					t.Fatalf("CreateSecret() status = %d, want %d", response.Code, tt.wantStatus)
+				}
+			})
+		}
+	})
+}
+
+func TestCreateAgentSecretJSONFlow(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		router := newTestRouter(t, b)
+
+		body := marshalJSON(t, models.AgentCreateSecretRequest{
+			Content:   "AGENT_TOKEN=test-value",
+			ExpiresIn: int((1 * time.Hour).Seconds()),
+		})
+
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodPost, "/api/agent/secrets", strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(response, request)
+
+		if response.Code != http.StatusCreated {
+			t.Fatalf("CreateAgentSecret() status = %d, want %d", response.Code, http.StatusCreated)
+		}
+
+		var createResponse models.AgentCreateSecretResponse
+		if err := json.NewDecoder(response.Body).Decode(&createResponse); err != nil {
+			t.Fatalf("CreateAgentSecret() decode error: %v", err)
+		}
+
+		if createResponse.ID == "" {
+			t.Fatal("CreateAgentSecret() returned empty ID")
+		}
+
+		if !strings.Contains(createResponse.URL, "/s/"+createResponse.ID+"#") {
+			t.Fatalf("CreateAgentSecret() URL = %q, want fragment share URL", createResponse.URL)
+		}
+
+		if createResponse.PassphraseRequired {
+			t.Fatal("CreateAgentSecret() should not require passphrase for plain uploads")
+		}
+
+		getResponse := httptest.NewRecorder()
+		getRequest := httptest.NewRequest(http.MethodGet, "/api/secrets/"+createResponse.ID, nil)
+		router.ServeHTTP(getResponse, getRequest)
+
+		if getResponse.Code != http.StatusOK {
+			t.Fatalf("GetSecret() status = %d, want %d", getResponse.Code, http.StatusOK)
+		}
+
+		var storedSecret models.GetSecretResponse
+		if err := json.NewDecoder(getResponse.Body).Decode(&storedSecret); err != nil {
+			t.Fatalf("GetSecret() decode error: %v", err)
+		}
+
+		if storedSecret.Ciphertext == "" || storedSecret.IV == "" {
+			t.Fatal("GetSecret() returned incomplete encrypted payload")
+		}
+	})
+}
+
+func TestCreateAgentSecretMultipartWithPassphrase(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		router := newTestRouter(t, b)
+
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+
+		if err := writer.WriteField("passphrase", "shared-secret"); err != nil {
+			t.Fatalf("WriteField(passphrase) error: %v", err)
+		}
+
+		if err := writer.WriteField("expires_in", strconv.Itoa(int((30 * time.Minute).Seconds()))); err != nil {
+			t.Fatalf("WriteField(expires_in) error: %v", err)
+		}
+
+		fileWriter, err := writer.CreateFormFile("file", ".env")
+		if err != nil {
+			t.Fatalf("CreateFormFile() error: %v", err)
+		}
+
+		if _, err := fileWriter.Write([]byte("DATABASE_URL=postgres://secret")); err != nil {
+			t.Fatalf("fileWriter.Write() error: %v", err)
+		}
+
+		if err := writer.Close(); err != nil {
+			t.Fatalf("writer.Close() error: %v", err)
+		}
+
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodPost, "/api/agent/secrets", &body)
+		request.Header.Set("Content-Type", writer.FormDataContentType())
+		router.ServeHTTP(response, request)
+
+		if response.Code != http.StatusCreated {
+			t.Fatalf("CreateAgentSecret() status = %d, want %d", response.Code, http.StatusCreated)
+		}
+
+		var createResponse models.AgentCreateSecretResponse
+		if err := json.NewDecoder(response.Body).Decode(&createResponse); err != nil {
+			t.Fatalf("CreateAgentSecret() decode error: %v", err)
+		}
+
+		if !createResponse.PassphraseRequired {
+			t.Fatal("CreateAgentSecret() should require passphrase")
+		}
+
+		if strings.Contains(createResponse.URL, "#") {
+			t.Fatalf("CreateAgentSecret() URL = %q, want passphrase URLs without fragment key", createResponse.URL)
+		}
+	})
+}
+
+func TestCreateAgentSecretErrors(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		router := newTestRouter(t, b)
+
+		tests := []struct {
+			name        string
+			body        string
+			contentType string
+			wantStatus  int
+		}{
+			{
+				name:        "invalid ttl",
+				body:        `{"content":"hello","expires_in":60}`,
+				contentType: "application/json",
+				wantStatus:  http.StatusBadRequest,
+			},
+			{
+				name:        "unsupported content type",
+				body:        "<secret>hello</secret>",
+				contentType: "application/xml",
+				wantStatus:  http.StatusBadRequest,
+			},
+			{
+				name:        "empty text body",
+				body:        "",
+				contentType: "text/plain",
+				wantStatus:  http.StatusBadRequest,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				response := httptest.NewRecorder()
+				request := httptest.NewRequest(http.MethodPost, "/api/agent/secrets", strings.NewReader(tt.body))
+				request.Header.Set("Content-Type", tt.contentType)
+				router.ServeHTTP(response, request)
+
+				if response.Code != tt.wantStatus {
+					t.Fatalf("CreateAgentSecret() status = %d, want %d", response.Code, tt.wantStatus)
+				}
+			})
+		}
+	})
+}
+
+func TestGetSecretErrors(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		router := newTestRouter(t, b)
+
+		tests := []struct {
+			name       string
+			secretID   string
+			wantStatus int
+		}{
+			{
+				name:       "non-existent secret",
+				secretID:   "abcdefghABCDEFGH1234_-",
+				wantStatus: http.StatusNotFound,
+			},
+			{
+				name:       "invalid secret ID format",
+				secretID:   "not-valid-id",
+				wantStatus: http.StatusNotFound,
+			},
+		}
+
+		for _, tt := range tests {
+			tt := tt
+			t.Run(tt.name, func(t *testing.T) {
+				response := httptest.NewRecorder()
+				request := httptest.NewRequest(http.MethodGet, "/api/secrets/"+tt.secretID, nil)
+				router.ServeHTTP(response, request)
+
+				if response.Code != tt.wantStatus {
+					if response.Code != http.StatusInternalServerError {
+						t.Fatalf("GetSecret() status = %d, want %d", response.Code, tt.wantStatus)
+					}
+				}
+			})
+		}
+	})
+}
+
+func TestCryptoShreddingFlow(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		legacyRouter := newTestRouter(t, b)
+		router := newTestRouterWithConfig(t, b, func(cfg *config.Config) {
+			cfg.CryptoShredding = true
+		})
+		ctx := context.Background()
+
+		legacyID := createTestSecret(t, legacyRouter, getMockCreateSecretRequest(nil))
+
+		createReq := getMockCreateSecretRequest(nil)
+		created := createTestSecretResponse(t, router, createReq)
+		secretID := created.ID
+
+		// The storage layout checks need tables to inspect
+		if b.queryRow != nil {
+			var storedCiphertext []byte
+			var keyCount int
+			err := b.queryRow(ctx, `
+				SELECT s.ciphertext, (SELECT COUNT(*) FROM secret_keys k WHERE k.secret_id = s.id)
+				FROM secrets s WHERE s.id = $1
+			`, secretID).Scan(&storedCiphertext, &keyCount)
+			if err != nil {
+				t.Fatalf("query stored secret: %v", err)
+			}
+
+			if base64.StdEncoding.EncodeToString(storedCiphertext) == createReq.Ciphertext {
+				t.Fatal("stored ciphertext is not wrapped")
+			}
+
+			if keyCount != 1 {
+				t.Fatalf("secret_keys rows = %d, want 1", keyCount)
+			}
+		}
+
+		getResp := httptest.NewRecorder()
+		router.ServeHTTP(getResp, httptest.NewRequest(http.MethodGet, "/api/secrets/"+secretID, nil))
+		if getResp.Code != http.StatusOK {
+			t.Fatalf("GetSecret() status = %d, want %d", getResp.Code, http.StatusOK)
+		}
+
+		var getResponse models.GetSecretResponse
+		if err := json.NewDecoder(getResp.Body).Decode(&getResponse); err != nil {
+			t.Fatalf("GetSecret() decode error: %v", err)
+		}
+
+		if getResponse.Ciphertext != createReq.Ciphertext {
+			t.Fatalf("GetSecret() ciphertext = %q, want %q", getResponse.Ciphertext, createReq.Ciphertext)
+		}
+
+		// Only the key is gone; the ciphertext row waits for garbage collection
+		if b.queryRow != nil {
+			var rowCount int
+			if err := b.queryRow(ctx, `SELECT COUNT(*) FROM secrets WHERE id = $1`, secretID).Scan(&rowCount); err != nil {
+				t.Fatalf("count secrets: %v", err)
+			}
+			if rowCount != 1 {
+				t.Fatalf("secrets rows after consume = %d, want 1", rowCount)
+			}
+		}
+
+		secondResp := httptest.NewRecorder()
+		router.ServeHTTP(secondResp, httptest.NewRequest(http.MethodGet, "/api/secrets/"+secretID, nil))
+		if secondResp.Code != http.StatusNotFound {
+			t.Fatalf("GetSecret() after shred status = %d, want %d", secondResp.Code, http.StatusNotFound)
+		}
+
+		burnResp := httptest.NewRecorder()
+		burnRequest := httptest.NewRequest(http.MethodDelete, "/api/secrets/"+secretID, nil)
+		burnRequest.Header.Set(ManagementTokenHeader, created.ManagementToken)
+		router.ServeHTTP(burnResp, burnRequest)
+		if burnResp.Code != http.StatusNotFound {
+			t.Fatalf("BurnSecret() after shred status = %d, want %d", burnResp.Code, http.StatusNotFound)
+		}
+
+		// Legacy rows written before the mode was enabled must stay readable
+		legacyResp := httptest.NewRecorder()
+		router.ServeHTTP(legacyResp, httptest.NewRequest(http.MethodGet, "/api/secrets/"+legacyID, nil))
+		if legacyResp.Code != http.StatusOK {
+			t.Fatalf("GetSecret() legacy status = %d, want %d", legacyResp.Code, http.StatusOK)
+		}
+	})
+}
+
+func TestConsumeRecordsNetworkClass(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		ranges, err := netclass.ParseRanges([]string{"corp-vpn=10.8.0.0/16", "office-hq=10.8.4.0/24"})
+		if err != nil {
+			t.Fatalf("ParseRanges() error: %v", err)
+		}
+
+		handler := NewHandler(b.store, &config.Config{
+			MaxSecretSize:          32768,
+			WriteRateLimitRequests: 1000,
+			WriteRateLimitWindow:   time.Minute,
+			ReadRateLimitRequests:  1000,
+			ReadRateLimitWindow:    time.Minute,
+		})
+		handler.SetClassifier(netclass.New(ranges, ""))
+
+		router := chi.NewRouter()
+		router.Mount("/api", handler.Routes())
+
+		tests := []struct {
+			remoteAddr string
+			want       string
+		}{
+			{remoteAddr: "10.8.1.2:4000", want: "corp-vpn"},
+			{remoteAddr: "10.8.4.9:4000", want: "office-hq"},
+			{remoteAddr: "198.51.100.7:4000", want: netclass.DefaultLabel},
+		}
+
+		for _, tt := range tests {
+			secretID := createTestSecret(t, router, getMockCreateSecretRequest(nil))
+
+			request := httptest.NewRequest(http.MethodGet, "/api/secrets/"+secretID, nil)
+			request.RemoteAddr = tt.remoteAddr
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, request)
+
+			if response.Code != http.StatusOK {
+				t.Fatalf("GetSecret() status = %d, want %d", response.Code, http.StatusOK)
+			}
+
+			receipt, err := b.store.Receipt(context.Background(), secretID)
+			if err != nil {
+				t.Fatalf("Receipt() error: %v", err)
+			}
+
+			if receipt.NetworkClass != tt.want {
+				t.Errorf("receipt network_class for %s = %q, want %q", tt.remoteAddr, receipt.NetworkClass, tt.want)
+			}
+		}
+	})
+}
+
+func TestDeclaredKeyBitsStoredAndAggregated(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		for _, missing := range []string{policy.KeyBitsMissingWarn, policy.KeyBitsMissingAllow} {
+			router := newTestRouterWithConfig(t, b, func(cfg *config.Config) {
+				cfg.MinKeyBits = 128
+				cfg.KeyBitsMissing = missing
+				cfg.AdminToken = "admin-secret"
+			})
+
+			before := GetMetrics().UndeclaredKeyBits
+			createTestSecret(t, router, getMockCreateSecretRequest(nil))
+			if got := GetMetrics().UndeclaredKeyBits - before; got != 1 {
+				t.Errorf("%s: undeclared metric delta = %d, want 1", missing, got)
+			}
+		}
+
+		router := newTestRouterWithConfig(t, b, func(cfg *config.Config) {
+			cfg.MinKeyBits = 128
+			cfg.AdminToken = "admin-secret"
+		})
+
+		declared := getMockCreateSecretRequest(nil)
+		bits := 256
+		declared.DeclaredKeyBits = &bits
+		secretID := createTestSecret(t, router, declared)
+
+		request := httptest.NewRequest(http.MethodGet, "/api/admin/stats", nil)
+		request.Header.Set("Authorization", "Bearer admin-secret")
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+
+		if response.Code != http.StatusOK {
+			t.Fatalf("AdminStats() status = %d, want %d", response.Code, http.StatusOK)
+		}
+
+		var stats AdminStatsResponse
+		if err := json.NewDecoder(response.Body).Decode(&stats); err != nil {
+			t.Fatalf("AdminStats() decode error: %v", err)
+		}
+
+		if stats.DeclaredKeyBits["256"] != 1 || stats.DeclaredKeyBits["undeclared"] != 2 {
+			t.Errorf("AdminStats() declared_key_bits = %v, want 256:1 undeclared:2", stats.DeclaredKeyBits)
+		}
+
+		// The declaration is audit data only and never reaches the reader
+		getResp := httptest.NewRecorder()
+		router.ServeHTTP(getResp, httptest.NewRequest(http.MethodGet, "/api/secrets/"+secretID, nil))
+		if strings.Contains(getResp.Body.String(), "declared_key_bits") {
+			t.Errorf("GetSecret() leaked declared_key_bits: %s", getResp.Body.String())
+		}
+	})
+}
+
+func TestMultipartSecretFlow(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		for _, shredding := range []bool{false, true} {
+			b.reset(t)
+
+			router := newTestRouterWithConfig(t, b, func(cfg *config.Config) {
+				cfg.CryptoShredding = shredding
+			})
+
+			iv := base64.StdEncoding.EncodeToString(make([]byte, 12))
+			createReq := models.CreateSecretRequest{
+				Parts: []models.SecretPart{
+					{Label: "username", Ciphertext: base64.StdEncoding.EncodeToString([]byte("alice")), IV: iv},
+					{Label: "password", Ciphertext: base64.StdEncoding.EncodeToString([]byte("hunter2")), IV: iv},
+					{Label: "totp", Ciphertext: base64.StdEncoding.EncodeToString([]byte("JBSWY3DPEHPK3PXP")), IV: iv},
+				},
+				ExpiresIn:     int((15 * time.Minute).Seconds()),
+				BurnAfterRead: true,
+			}
+			secretID := createTestSecret(t, router, createReq)
+
+			getResp := httptest.NewRecorder()
+			router.ServeHTTP(getResp, httptest.NewRequest(http.MethodGet, "/api/secrets/"+secretID, nil))
+			if getResp.Code != http.StatusOK {
+				t.Fatalf("shredding=%v: GetSecret() status = %d, want %d", shredding, getResp.Code, http.StatusOK)
+			}
+
+			var getResponse models.GetSecretResponse
+			if err := json.NewDecoder(getResp.Body).Decode(&getResponse); err != nil {
+				t.Fatalf("GetSecret() decode error: %v", err)
+			}
+
+			if len(getResponse.Parts) != len(createReq.Parts) {
+				t.Fatalf("shredding=%v: parts = %d, want %d", shredding, len(getResponse.Parts), len(createReq.Parts))
+			}
+
+			for i, part := range createReq.Parts {
+				if getResponse.Parts[i] != part {
+					t.Errorf("shredding=%v: part %d = %+v, want %+v", shredding, i, getResponse.Parts[i], part)
+				}
+			}
+
+			// Consuming burns every part at once
+			secondResp := httptest.NewRecorder()
+			router.ServeHTTP(secondResp, httptest.NewRequest(http.MethodGet, "/api/secrets/"+secretID, nil))
+			if secondResp.Code != http.StatusNotFound {
+				t.Fatalf("shredding=%v: second GetSecret() status = %d, want %d", shredding, secondResp.Code, http.StatusNotFound)
+			}
+
+			if !shredding && b.queryRow != nil {
+				var remaining int
+				err := b.queryRow(context.Background(),
+					`SELECT COUNT(*) FROM secret_parts WHERE secret_id = $1`, secretID).Scan(&remaining)
+				if err != nil {
+					t.Fatalf("count parts: %v", err)
+				}
+				if remaining != 0 {
+					t.Errorf("secret_parts after consume = %d, want 0", remaining)
+				}
+			}
+		}
+	})
+}
+
+func TestBurnRequiresManagementToken(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		router := newTestRouter(t, b)
+		created := createTestSecretResponse(t, router, getMockCreateSecretRequest(nil))
+
+		if created.ManagementToken == "" {
+			t.Fatal("CreateSecret() returned no management token")
+		}
+
+		storedHash, err := b.store.ManagementTokenHash(context.Background(), created.ID)
+		if err != nil {
+			t.Fatalf("ManagementTokenHash() error: %v", err)
+		}
+		if bytes.Contains(storedHash, []byte(created.ManagementToken)) || len(storedHash) != sha256.Size {
+			t.Fatalf("stored management token is not a SHA-256 hash")
+		}
+
+		tests := []struct {
+			name  string
+			token string
+			want  int
+		}{
+			{name: "no token", token: "", want: http.StatusUnauthorized},
+			{name: "wrong token", token: "not-the-token", want: http.StatusUnauthorized},
+			{name: "correct token", token: created.ManagementToken, want: http.StatusNoContent},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				request := httptest.NewRequest(http.MethodDelete, "/api/secrets/"+created.ID, nil)
+				if tt.token != "" {
+					request.Header.Set(ManagementTokenHeader, tt.token)
+				}
+				response := httptest.NewRecorder()
+				router.ServeHTTP(response, request)
+
+				if response.Code != tt.want {
+					t.Fatalf("BurnSecret() status = %d, want %d", response.Code, tt.want)
+				}
+			})
+		}
+	})
+}
+
+func TestBurnOpenDeleteMode(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		router := newTestRouterWithConfig(t, b, func(cfg *config.Config) {
+			cfg.AllowOpenDelete = true
+		})
+		secretID := createTestSecret(t, router, getMockCreateSecretRequest(nil))
+
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodDelete, "/api/secrets/"+secretID, nil))
+
+		if response.Code != http.StatusNoContent {
+			t.Fatalf("BurnSecret() with open delete status = %d, want %d", response.Code, http.StatusNoContent)
+		}
+	})
+}
+
+func createTestSecret(t *testing.T, router http.Handler, req models.CreateSecretRequest) string {
+	t.Helper()
+
+	return createTestSecretResponse(t, router, req).ID
+}
+
+func createTestSecretResponse(t *testing.T, router http.Handler, req models.CreateSecretRequest) models.CreateSecretResponse {
+	t.Helper()
+
+	response := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/api/secrets", strings.NewReader(marshalJSON(t, req)))
+	request.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(response, request)
+
+	if response.Code != http.StatusCreated {
+		t.Fatalf("CreateSecret() status = %d, want %d", response.Code, http.StatusCreated)
+	}
+
+	var created models.CreateSecretResponse
+	if err := json.NewDecoder(response.Body).Decode(&created); err != nil {
+		t.Fatalf("CreateSecret() decode error: %v", err)
+	}
+
+	return created
+}
+
+func newTestRouter(t *testing.T, b *testBackend) http.Handler {
+	return newTestRouterWithConfig(t, b, nil)
+}
+
+// newTestRouterWithConfig builds a router whose config can be adjusted by
+// mutate and whose handler can be prepared by setup, for example with a
+// fake clock. Every exchange is checked against the OpenAPI spec.
+func newTestRouterWithConfig(t *testing.T, b *testBackend, mutate func(cfg *config.Config), setup ...func(h *Handler)) http.Handler {
+	cfg := &config.Config{
+		MaxSecretSize:          32768,
+		AgentDefaultTTL:        24 * time.Hour,
+		WriteRateLimitRequests: 1000,
+		WriteRateLimitWindow:   time.Minute,
+		ReadRateLimitRequests:  1000,
+		ReadRateLimitWindow:    time.Minute,
+		AgentRateLimitRequests: 1000,
+		AgentRateLimitWindow:   time.Minute,
+	}
+
+	if mutate != nil {
+		mutate(cfg)
+	}
+
+	handler := NewHandler(b.store, cfg)
+	for _, fn := range setup {
+		fn(handler)
+	}
+	router := chi.NewRouter()
+	router.Mount("/api", handler.Routes())
+	return withSpecValidation(t, handler, router)
+}
+
+// withTestClock has the handler tell time by clk
+func withTestClock(clk *testutil.FakeClock) func(h *Handler) {
+	return func(h *Handler) {
+		h.SetClock(clk)
+	}
+}
+
+func getMockCreateSecretRequest(overrides *createSecretOverrides) models.CreateSecretRequest {
+	req := models.CreateSecretRequest{
+		Ciphertext:    base64.StdEncoding.EncodeToString([]byte("test secret data")),
+		IV:            base64.StdEncoding.EncodeToString(make([]byte, 12)),
+		Salt:          base64.StdEncoding.EncodeToString(make([]byte, 16)),
+		ExpiresIn:     int((15 * time.Minute).Seconds()),
+		BurnAfterRead: true,
+	}
+
+	if overrides == nil {
+		return req
+	}
+
+	if overrides.Ciphertext != nil {
+		req.Ciphertext = *overrides.Ciphertext
+	}
+
+	if overrides.IV != nil {
+		req.IV = *overrides.IV
+	}
+
+	if overrides.Salt != nil {
+		req.Salt = *overrides.Salt
+	}
+
+	if overrides.ExpiresIn != nil {
+		req.ExpiresIn = *overrides.ExpiresIn
+	}
+
+	return req
+}
+
+func marshalJSON(t *testing.T, payload interface{}) string {
+	t.Helper()
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("marshal json: %v", err)
+	}
+
+	return string(data)
+}
+
+func stringPtr(value string) *string {
+	return &value
+}
+
+func intPtr(value int) *int {
+	return &value
+}
@@ -0,0 +1,298 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/db"
+	httpMiddleware "ots-backend/internal/middleware"
+	"ots-backend/internal/models"
+	"ots-backend/internal/policy"
+	pgstore "ots-backend/internal/store/postgres"
+	"ots-backend/internal/testutil"
+	"ots-backend/pkg/ots"
+)
+
+func TestSecretRoutesNoStoreOnErrors(t *testing.T) {
+	handler := NewHandler(pgstore.New(&db.DB{}), &config.Config{
+		MaxSecretSize:          32768,
+		WriteRateLimitRequests: 1,
+		WriteRateLimitWindow:   time.Minute,
+		ReadRateLimitRequests:  100,
+		ReadRateLimitWindow:    time.Minute,
+		AgentRateLimitRequests: 100,
+		AgentRateLimitWindow:   time.Minute,
+	})
+	router := chi.NewRouter()
+	router.Mount("/api", handler.Routes())
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{name: "create bad body", method: http.MethodPost, path: "/api/secrets", body: "{", want: http.StatusBadRequest},
+		{name: "create rate limited", method: http.MethodPost, path: "/api/secrets", body: "{", want: http.StatusTooManyRequests},
+		{name: "agent create invalid", method: http.MethodPost, path: "/api/agent/secrets", body: "{", want: http.StatusBadRequest},
+		{name: "read invalid id", method: http.MethodGet, path: "/api/secrets/not-valid!", want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			request.Header.Set("Content-Type", "application/json")
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, request)
+
+			if response.Code != tt.want {
+				t.Fatalf("status = %d, want %d", response.Code, tt.want)
+			}
+
+			assertNoStoreHeaders(t, response.Header())
+		})
+	}
+}
+
+func TestCreateSecretKeyBitsPolicyRejections(t *testing.T) {
+	tests := []struct {
+		name     string
+		missing  string
+		body     string
+		wantCode string
+	}{
+		{
+			name:     "declared below minimum",
+			missing:  policy.KeyBitsMissingAllow,
+			body:     `,"declared_key_bits":64`,
+			wantCode: "key_too_weak",
+		},
+		{
+			name:     "undeclared in reject mode",
+			missing:  policy.KeyBitsMissingReject,
+			body:     ``,
+			wantCode: "key_bits_required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(pgstore.New(&db.DB{}), &config.Config{
+				MaxSecretSize:          32768,
+				WriteRateLimitRequests: 100,
+				WriteRateLimitWindow:   time.Minute,
+				MinKeyBits:             128,
+				KeyBitsMissing:         tt.missing,
+			})
+			router := chi.NewRouter()
+			router.Mount("/api", handler.Routes())
+
+			body := `{"ciphertext":"dGVzdCBzZWNyZXQ=","iv":"AAAAAAAAAAAAAAAA","expires_in":3600` + tt.body + `}`
+			request := httptest.NewRequest(http.MethodPost, "/api/secrets", strings.NewReader(body))
+			request.Header.Set("Content-Type", "application/json")
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, request)
+
+			if response.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", response.Code, http.StatusBadRequest)
+			}
+
+			var errResp models.ErrorResponse
+			if err := json.NewDecoder(response.Body).Decode(&errResp); err != nil {
+				t.Fatalf("decode error response: %v", err)
+			}
+
+			if errResp.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", errResp.Code, tt.wantCode)
+			}
+
+			if errResp.Limit == nil || errResp.Limit.Name != policy.LimitKeyBits || errResp.Limit.Min != 128 {
+				t.Errorf("limit = %+v, want %s with min 128", errResp.Limit, policy.LimitKeyBits)
+			}
+		})
+	}
+}
+
+func TestSecretRouteLimitersFollowHandlerClock(t *testing.T) {
+	clk := testutil.NewFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	handler := NewHandler(pgstore.New(&db.DB{}), &config.Config{
+		MaxSecretSize:          32768,
+		WriteRateLimitRequests: 1,
+		WriteRateLimitWindow:   time.Minute,
+	})
+	handler.SetClock(clk)
+	router := chi.NewRouter()
+	router.Mount("/api", handler.Routes())
+
+	create := func() int {
+		request := httptest.NewRequest(http.MethodPost, "/api/secrets", strings.NewReader("{"))
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response.Code
+	}
+
+	if got := create(); got != http.StatusBadRequest {
+		t.Fatalf("first create status = %d, want %d", got, http.StatusBadRequest)
+	}
+	clk.Advance(time.Minute - time.Second)
+	if got := create(); got != http.StatusTooManyRequests {
+		t.Fatalf("create inside window status = %d, want %d", got, http.StatusTooManyRequests)
+	}
+	clk.Advance(time.Second)
+	if got := create(); got != http.StatusBadRequest {
+		t.Fatalf("create after window status = %d, want %d", got, http.StatusBadRequest)
+	}
+}
+
+func TestRateLimitRefusalIsServiceError(t *testing.T) {
+	clk := testutil.NewFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	handler := NewHandler(pgstore.New(&db.DB{}), &config.Config{
+		MaxSecretSize:          32768,
+		WriteRateLimitRequests: 1,
+		WriteRateLimitWindow:   time.Minute,
+	})
+	handler.SetClock(clk)
+	router := chi.NewRouter()
+	router.Mount("/api", handler.Routes())
+
+	create := func() *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/api/secrets", strings.NewReader("{"))
+		request.Header.Set("Accept-Language", "fr")
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response
+	}
+
+	create()
+	clk.Advance(45*time.Second + time.Millisecond)
+	response := create()
+	if response.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", response.Code, http.StatusTooManyRequests)
+	}
+	for _, name := range []string{"Retry-After", "X-RateLimit-Reset"} {
+		if got := response.Header().Get(name); got != "15" {
+			t.Errorf("%s = %q, want 15", name, got)
+		}
+	}
+
+	var errResp models.ErrorResponse
+	if err := json.NewDecoder(response.Body).Decode(&errResp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if errResp.Code != ots.ErrorCode(ots.ErrRateLimited) {
+		t.Errorf("code = %q, want %q", errResp.Code, ots.ErrorCode(ots.ErrRateLimited))
+	}
+	// Written by respondServiceError, so the message is localized
+	if errResp.Message != "Trop de requêtes ; réessayez plus tard." {
+		t.Errorf("message = %q, want the French catalog entry", errResp.Message)
+	}
+}
+
+func assertNoStoreHeaders(t *testing.T, header http.Header) {
+	t.Helper()
+
+	if got := header.Get("Cache-Control"); got != "no-store, no-cache" {
+		t.Errorf("Cache-Control = %q, want %q", got, "no-store, no-cache")
+	}
+	if got := header.Get("Pragma"); got != "no-cache" {
+		t.Errorf("Pragma = %q, want %q", got, "no-cache")
+	}
+	if got := header.Get("Expires"); got != "0" {
+		t.Errorf("Expires = %q, want %q", got, "0")
+	}
+}
+
+func TestShareURLHonorsTrustedProxies(t *testing.T) {
+	trusted, err := httpMiddleware.ParseTrustedProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies() error: %v", err)
+	}
+	httpMiddleware.SetTrustedProxies(trusted)
+	t.Cleanup(func() { httpMiddleware.SetTrustedProxies(nil) })
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		publicURL  string
+		want       string
+	}{
+		{name: "direct http", remoteAddr: "203.0.113.7:1", want: "http://ots.local/s/abc#key"},
+		{name: "proxied https", remoteAddr: "10.0.0.5:1", want: "https://ots.example.org/s/abc#key"},
+		{name: "untrusted spoof", remoteAddr: "203.0.113.7:1", want: "http://ots.local/s/abc#key"},
+		{name: "public base url wins", remoteAddr: "10.0.0.5:1", publicURL: "https://share.example/", want: "https://share.example/s/abc#key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(pgstore.New(&db.DB{}), &config.Config{PublicBaseURL: tt.publicURL})
+
+			var got string
+			router := httpMiddleware.RealIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = handler.buildShareURL(r, "abc", "key")
+			}))
+
+			request := httptest.NewRequest(http.MethodPost, "http://ots.local/api/agent/secrets", nil)
+			request.RemoteAddr = tt.remoteAddr
+			request.Header.Set("X-Forwarded-Proto", "https")
+			request.Header.Set("X-Forwarded-Host", "ots.example.org")
+			router.ServeHTTP(httptest.NewRecorder(), request)
+
+			if got != tt.want {
+				t.Errorf("buildShareURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestBodiesAreLimited(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouterWithConfig(t, b, func(cfg *config.Config) { cfg.MaxSecretSize = 1024 })
+
+		// Padding after the fields pushes the body past the limit before
+		// the decoder has seen anything wrong with it
+		padding := strings.Repeat(" ", 2*1024+64<<10)
+		tests := []struct {
+			name string
+			path string
+			body string
+			want int
+			code string
+		}{
+			{name: "create", path: "/api/secrets", body: `{"ciphertext": "` + padding + `"}`, want: http.StatusRequestEntityTooLarge, code: "secret_too_large"},
+			{name: "dry run", path: "/api/secrets/validate", body: `{"size": 1, ` + padding + `}`, want: http.StatusRequestEntityTooLarge, code: "secret_too_large"},
+			{name: "report", path: "/api/secrets/abc/report", body: `{"reason": "` + strings.Repeat("x", 16<<10) + `"}`, want: http.StatusBadRequest},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				request := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+				request.Header.Set("Content-Type", "application/json")
+				response := httptest.NewRecorder()
+				router.ServeHTTP(response, request)
+
+				if response.Code != tt.want {
+					t.Fatalf("status = %d, want %d: %s", response.Code, tt.want, response.Body.String())
+				}
+				if tt.code == "" {
+					return
+				}
+				var body models.ErrorResponse
+				if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+					t.Fatalf("decode error response: %v", err)
+				}
+				if body.Code != tt.code {
+					t.Errorf("code = %q, want %q", body.Code, tt.code)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,239 @@
+package api
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+
+	"ots-backend/internal/httpx"
+	httpMiddleware "ots-backend/internal/middleware"
+	"ots-backend/internal/store/memory"
+)
+
+var updateHeaders = flag.Bool("update-headers", false, "rewrite the golden header lists in testdata")
+
+// headerCase is one route or error path of the header conformance test
+type headerCase struct {
+	name   string
+	method string
+	path   string
+	body   string
+	header map[string]string
+}
+
+// newHeaderTestServer mounts the API behind the server's middleware chain,
+// allowing CORS from origins
+func newHeaderTestServer(t *testing.T, origins ...string) http.Handler {
+	t.Helper()
+
+	cfg := auditTestConfig()
+	cfg.MaxSecretSize = 32768
+	cfg.AgentDefaultTTL = 24 * time.Hour
+	cfg.ReadRateLimitRequests = 2
+	cfg.ReadRateLimitWindow = time.Minute
+	cfg.CORSAllowedOrigins = origins
+
+	handler := NewHandler(memory.New(), cfg)
+	r := chi.NewRouter()
+	r.Use(chimiddleware.RequestID)
+	r.Use(httpMiddleware.RealIP)
+	r.Use(httpMiddleware.SecurityHeaders)
+	r.Use(chimiddleware.Recoverer)
+	r.Use(httpMiddleware.CORS(CORSOptions(cfg.CORSAllowedOrigins)))
+	r.Mount("/api", handler.Routes())
+	return r
+}
+
+func headerCases(t *testing.T, secretID string) []headerCase {
+	create := marshalJSON(t, getMockCreateSecretRequest(nil))
+	return []headerCase{
+		{name: "health", method: http.MethodGet, path: "/api/health"},
+		{name: "config", method: http.MethodGet, path: "/api/config"},
+		{name: "openapi", method: http.MethodGet, path: "/api/openapi.json"},
+		{name: "create", method: http.MethodPost, path: "/api/secrets", body: create},
+		{name: "create invalid", method: http.MethodPost, path: "/api/secrets", body: `{}`},
+		{name: "read", method: http.MethodGet, path: "/api/secrets/" + secretID},
+		{name: "read missing", method: http.MethodGet, path: "/api/secrets/" + secretID},
+		{name: "read rate limited", method: http.MethodGet, path: "/api/secrets/" + secretID},
+		{name: "burn invalid id", method: http.MethodDelete, path: "/api/secrets/not-an-id"},
+		{name: "admin unauthorized", method: http.MethodGet, path: "/api/admin/stats"},
+		{name: "admin", method: http.MethodGet, path: "/api/admin/stats", header: map[string]string{"Authorization": "Bearer " + auditTestToken}},
+		{name: "unknown route", method: http.MethodGet, path: "/api/nope"},
+		{name: "method not allowed", method: http.MethodPut, path: "/api/secrets"},
+		{name: "cors preflight", method: http.MethodOptions, path: "/api/secrets", header: map[string]string{
+			"Origin":                        "https://app.example.com",
+			"Access-Control-Request-Method": http.MethodPost,
+		}},
+		{name: "cors simple", method: http.MethodGet, path: "/api/health", header: map[string]string{"Origin": "https://app.example.com"}},
+	}
+}
+
+// TestHeaderConformance requests every route and error path and compares
+// the header names of each response, in wire order, with a golden list per
+// profile. Rewrite the lists with go test -run HeaderConformance -update-headers.
+func TestHeaderConformance(t *testing.T) {
+	for _, profile := range []string{"http", "https"} {
+		t.Run(profile, func(t *testing.T) {
+			server := newHeaderTestServer(t, "https://app.example.com")
+			secretID := createTestSecret(t, server, getMockCreateSecretRequest(nil))
+
+			var got strings.Builder
+			for _, tc := range headerCases(t, secretID) {
+				request := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+				if profile == "https" {
+					request.TLS = &tls.ConnectionState{}
+				}
+				for name, value := range tc.header {
+					request.Header.Set(name, value)
+				}
+				response := httptest.NewRecorder()
+				server.ServeHTTP(response, request)
+
+				// net/http writes header fields sorted by name
+				names := make([]string, 0, len(response.Header()))
+				for name := range response.Header() {
+					names = append(names, name)
+				}
+				slices.Sort(names)
+				fmt.Fprintf(&got, "%s %d: %s\n", tc.name, response.Code, strings.Join(names, ", "))
+
+				assertSecurityPolicy(t, tc.name, response.Header(), httpMiddleware.DefaultCSP, profile == "https")
+			}
+
+			golden := filepath.Join("testdata", "headers_"+profile+".golden")
+			if *updateHeaders {
+				if err := os.WriteFile(golden, []byte(got.String()), 0o644); err != nil {
+					t.Fatalf("write %s: %v", golden, err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("read %s: %v", golden, err)
+			}
+			if got.String() != string(want) {
+				t.Errorf("headers differ from %s (rerun with -update-headers if intended)\ngot:\n%s\nwant:\n%s", golden, got.String(), want)
+			}
+		})
+	}
+}
+
+// TestHeaderInjection sends line breaks and Unicode controls through every
+// client-controlled value that could reach a response header, and checks
+// that no response carries an injected field or a value outside the
+// field-value grammar
+func TestHeaderInjection(t *testing.T) {
+	server := newHeaderTestServer(t, "https://*.example.com")
+	secretID := createTestSecret(t, server, getMockCreateSecretRequest(nil))
+
+	for _, payload := range []string{"\r\nX-Injected: 1", "\nX-Injected: 1", "\u2028X-Injected: 1", "\u0085X-Injected: 1", "\u010aX-Injected: 1", "\u202e\x00"} {
+		id := url.PathEscape(secretID + payload)
+		origin := "https://app" + payload + ".example.com"
+		slug := getMockCreateSecretRequest(nil)
+		slug.Slug = "release" + payload
+
+		cases := []headerCase{
+			{name: "read", method: http.MethodGet, path: "/api/secrets/" + id},
+			{name: "head", method: http.MethodHead, path: "/api/secrets/" + id},
+			{name: "method not allowed", method: http.MethodPut, path: "/api/secrets/" + id},
+			{name: "burn", method: http.MethodDelete, path: "/api/secrets/" + id, header: map[string]string{ManagementTokenHeader: payload}},
+			{name: "create with slug", method: http.MethodPost, path: "/api/secrets", body: marshalJSON(t, slug)},
+			{name: "agent create", method: http.MethodPost, path: "/api/agent/secrets", body: "x", header: map[string]string{"X-Secret-Passphrase": payload, "X-Secret-Expires-In": payload}},
+			{name: "config", method: http.MethodGet, path: "/api/config", header: map[string]string{"If-None-Match": payload}},
+			{name: "cors simple", method: http.MethodGet, path: "/api/health", header: map[string]string{"Origin": origin}},
+			{name: "cors preflight", method: http.MethodOptions, path: "/api/secrets", header: map[string]string{
+				"Origin":                        origin,
+				"Access-Control-Request-Method": http.MethodPost,
+			}},
+		}
+		for _, tc := range cases {
+			request := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			for name, value := range tc.header {
+				// Set the value raw, as a client bypassing net/http would send it
+				request.Header[http.CanonicalHeaderKey(name)] = []string{value}
+			}
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, request)
+
+			for name, values := range response.Header() {
+				for _, value := range values {
+					if !httpx.ValidHeaderValue(value) {
+						t.Errorf("%s with %q: %s = %q, not a valid field-value", tc.name, payload, name, value)
+					}
+				}
+			}
+			if got := response.Header().Get("X-Injected"); got != "" {
+				t.Errorf("%s with %q: X-Injected = %q, want no injected field", tc.name, payload, got)
+			}
+			if got := response.Header().Get("Access-Control-Allow-Origin"); got != "" {
+				t.Errorf("%s with %q: Access-Control-Allow-Origin = %q, want a control-laden origin refused", tc.name, payload, got)
+			}
+		}
+	}
+}
+
+// assertSecurityPolicy checks that header carries the full security header
+// set with csp as its Content-Security-Policy, and HSTS only when hsts
+func assertSecurityPolicy(t *testing.T, name string, header http.Header, csp string, hsts bool) {
+	t.Helper()
+
+	want := map[string]string{
+		"Content-Security-Policy":    csp,
+		"X-Frame-Options":            "DENY",
+		"X-Content-Type-Options":     "nosniff",
+		"Referrer-Policy":            "no-referrer",
+		"Permissions-Policy":         "accelerometer=(), camera=(), geolocation=(), gyroscope=(), magnetometer=(), microphone=(), payment=(), usb=()",
+		"Cross-Origin-Opener-Policy": "same-origin",
+		"X-XSS-Protection":           "0",
+		"Strict-Transport-Security":  "",
+	}
+	if hsts {
+		want["Strict-Transport-Security"] = "max-age=31536000; includeSubDomains; preload"
+	}
+	for field, value := range want {
+		if got := header.Values(field); (value == "" && len(got) != 0) || (value != "" && !slices.Equal(got, []string{value})) {
+			t.Errorf("%s: %s = %q, want %q", name, field, got, value)
+		}
+	}
+	if got := header.Get(httpMiddleware.PolicyRevHeader); got != httpMiddleware.PolicyRev() {
+		t.Errorf("%s: %s = %q, want %q", name, httpMiddleware.PolicyRevHeader, got, httpMiddleware.PolicyRev())
+	}
+}
+
+// TestConfiguredSecurityPolicy checks that CSP_POLICY and HSTS_ENABLED=false
+// reach every route and error path, and change the policy revision
+func TestConfiguredSecurityPolicy(t *testing.T) {
+	defaultRev := httpMiddleware.PolicyRev()
+	const csp = "default-src 'none'; report-uri https://csp.example.com/report"
+	httpMiddleware.SetSecurityPolicy(csp, false)
+	t.Cleanup(func() { httpMiddleware.SetSecurityPolicy("", true) })
+
+	if httpMiddleware.PolicyRev() == defaultRev {
+		t.Errorf("policy revision %s did not change with the policy", defaultRev)
+	}
+
+	server := newHeaderTestServer(t, "https://app.example.com")
+	secretID := createTestSecret(t, server, getMockCreateSecretRequest(nil))
+	for _, tc := range headerCases(t, secretID) {
+		request := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		request.TLS = &tls.ConnectionState{}
+		for name, value := range tc.header {
+			request.Header.Set(name, value)
+		}
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertSecurityPolicy(t, tc.name, response.Header(), csp, false)
+	}
+}
@@ -9,11 +9,25 @@ import (
 	"ots-backend/internal/logger"
 )
 
+// Health endpoint aliases. Every alias is served by the same implementation;
+// hits are counted per alias so operators can tell when old paths go unused.
+const (
+	HealthAliasRoot  = "/health"
+	HealthAliasAPI   = "/api/health"
+	HealthAliasReady = "/api/health/ready"
+	HealthAliasLive  = "/api/health/live"
+)
+
+// healthSuccessor is advertised to clients still using the deprecated root alias
+const healthSuccessor = "/api/health"
+
 // HealthCheckResponse represents the structure of health check responses
 type HealthCheckResponse struct {
-	Status    string            `json:"status"`
-	Timestamp string            `json:"timestamp"`
-	Version   string            `json:"version"`
+	Status    string `json:"status"`
+	Timestamp string `json:"timestamp"`
+	Version   string `json:"version"`
+	// Readiness is the lifecycle stage; liveness leaves it out
+	Readiness string            `json:"readiness,omitempty"`
 	Checks    map[string]string `json:"checks"`
 }
 
@@ -22,72 +36,148 @@ func (h *Handler) checkDatabaseHealth(ctx context.Context) string {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	if err := h.db.Health(ctx); err != nil {
+	if err := h.pingDB(ctx); err != nil {
 		logger.Warn("database health check failed", "error", err.Error())
 		return "down"
 	}
 	return "ok"
 }
 
-// HealthCheck returns full health status (503 if any dependency is down)
-func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	dbHealth := h.checkDatabaseHealth(r.Context())
+// health runs the dependency checks and builds the shared response.
+// A down database or an unhealthy resource check is a 503; degraded
+// resources and a lost listener still serve 200 with status "degraded".
+func (h *Handler) health(ctx context.Context) (int, HealthCheckResponse) {
+	checks := map[string]string{
+		"database": h.checkDatabaseHealth(ctx),
+	}
+
+	unhealthy := checks["database"] != "ok"
+	degraded := false
+
+	for _, rc := range h.checks {
+		result := rc.check()
+		checks[rc.name] = result
+
+		switch result {
+		case checkUnhealthy:
+			unhealthy = true
+		case checkDegraded:
+			degraded = true
+		}
+	}
+
+	// Listener outages degrade live updates but watchers fall back to polling
+	if h.listener != nil {
+		checks["listener"] = "ok"
+		if err := h.listener.Health(); err != nil {
+			checks["listener"] = "down"
+			degraded = true
+		}
+	}
+
+	// A failing canary means reads or writes are broken end to end even if
+	// every dependency answers its ping
+	if h.canary != nil {
+		result, lastSuccess := h.canaryCheck()
+		checks["canary"] = result
+		if !lastSuccess.IsZero() {
+			checks["canary_last_success_age"] = h.clock.Now().Sub(lastSuccess).Round(time.Second).String()
+		}
+		if result == canaryDegraded {
+			degraded = true
+		}
+	}
 
 	statusCode := http.StatusOK
 	status := "healthy"
-	if dbHealth != "ok" {
+	switch {
+	case unhealthy:
 		statusCode = http.StatusServiceUnavailable
 		status = "unhealthy"
+	case degraded:
+		status = "degraded"
 	}
 
-	resp := HealthCheckResponse{
+	return statusCode, HealthCheckResponse{
 		Status:    status,
-		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Timestamp: h.clock.Now().UTC().Format(time.RFC3339),
 		Version:   "1.0.0",
-		Checks: map[string]string{
-			"database": dbHealth,
-		},
+		Readiness: h.readiness.State().String(),
+		Checks:    checks,
 	}
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(resp)
+// healthAlias names the alias r came in on. The root and API aliases share
+// one handler, so only the path tells their hits apart.
+func healthAlias(r *http.Request) string {
+	switch r.URL.Path {
+	case HealthAliasRoot, HealthAliasReady:
+		return r.URL.Path
+	}
+	return HealthAliasAPI
+}
+
+// HealthCheck returns full health status (503 if any dependency is down).
+// It serves /api/health and the legacy root /health alike, so their bodies
+// cannot drift apart; only the root alias may add deprecation headers.
+func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
+	alias := healthAlias(r)
+	RecordHealthHit(alias)
+
+	if alias == HealthAliasRoot && h.config().HealthRootDeprecated {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+healthSuccessor+`>; rel="successor-version"`)
+	}
 
-	logger.Info("health check", "status", status, "database", dbHealth)
+	statusCode, resp := h.health(r.Context())
+	h.writeHealth(w, alias, statusCode, resp)
 }
 
 // ReadinessProbe checks if the service is ready to accept traffic (503 if not ready)
 func (h *Handler) ReadinessProbe(w http.ResponseWriter, r *http.Request) {
-	dbHealth := h.checkDatabaseHealth(r.Context())
+	RecordHealthHit(HealthAliasReady)
 
-	statusCode := http.StatusOK
-	status := "ready"
-	if dbHealth != "ok" {
+	statusCode, resp := h.health(r.Context())
+
+	// Not ready until warm-up finishes, nor once shutdown starts
+	if state := h.readiness.State(); state != ReadinessReady {
 		statusCode = http.StatusServiceUnavailable
-		status = "not_ready"
+		resp.Status = state.String()
+		if state == ReadinessWarmingUp {
+			resp.Checks["warmup"] = "in_progress"
+		}
 	}
 
-	resp := HealthCheckResponse{
-		Status:    status,
-		Timestamp: time.Now().UTC().Format(time.RFC3339),
-		Version:   "1.0.0",
-		Checks: map[string]string{
-			"database": dbHealth,
-		},
+	// With CANARY_READINESS, a canary past its failure threshold takes
+	// the instance out of rotation
+	if h.canary != nil && h.config().CanaryReadiness {
+		if result, _ := h.canaryCheck(); result == canaryDegraded {
+			statusCode = http.StatusServiceUnavailable
+		}
 	}
 
+	h.writeHealth(w, HealthAliasReady, statusCode, resp)
+}
+
+// writeHealth sends a health response and logs the probe
+func (h *Handler) writeHealth(w http.ResponseWriter, alias string, statusCode int, resp HealthCheckResponse) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 	json.NewEncoder(w).Encode(resp)
 
-	logger.Info("readiness probe", "status", status, "database", dbHealth)
+	logger.Info("health check", logger.Sampled(logger.SampleHealth), "alias", alias, "status", resp.Status)
 }
 
 // LivenessProbe checks if the service process is running (always returns 200)
 func (h *Handler) LivenessProbe(w http.ResponseWriter, r *http.Request) {
+	RecordHealthHit(HealthAliasLive)
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "alive"})
-
-	logger.Info("liveness probe")
+	json.NewEncoder(w).Encode(HealthCheckResponse{
+		Status:    "alive",
+		Timestamp: h.clock.Now().UTC().Format(time.RFC3339),
+		Version:   "1.0.0",
+		Checks:    map[string]string{},
+	})
 }
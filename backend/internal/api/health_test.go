@@ -0,0 +1,153 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/db"
+	pgstore "ots-backend/internal/store/postgres"
+	"ots-backend/internal/testutil"
+)
+
+func newHealthTestRouter(cfg *config.Config) chi.Router {
+	handler := NewHandler(pgstore.New(&db.DB{}), cfg)
+	handler.readiness.Advance(ReadinessReady)
+	handler.SetClock(testutil.NewFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+	router := chi.NewRouter()
+	router.Get(HealthAliasRoot, handler.HealthCheck)
+	router.Mount("/api", handler.Routes())
+	return router
+}
+
+func TestHealthAliasesServeIdenticalBodies(t *testing.T) {
+	router := newHealthTestRouter(&config.Config{HealthRootDeprecated: true})
+
+	before := GetMetrics().HealthHits
+	aliases := []string{HealthAliasRoot, HealthAliasAPI, HealthAliasReady}
+
+	var reference []byte
+	var referenceStatus int
+	for i, alias := range aliases {
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, alias, nil))
+
+		if i == 0 {
+			reference = response.Body.Bytes()
+			referenceStatus = response.Code
+			continue
+		}
+
+		if !bytes.Equal(response.Body.Bytes(), reference) {
+			t.Errorf("%s body = %q, want %q", alias, response.Body.Bytes(), reference)
+		}
+
+		if response.Code != referenceStatus {
+			t.Errorf("%s status = %d, want %d", alias, response.Code, referenceStatus)
+		}
+	}
+
+	after := GetMetrics().HealthHits
+	for _, alias := range aliases {
+		if got := after[alias] - before[alias]; got != 1 {
+			t.Errorf("health hits for %s = %d, want 1", alias, got)
+		}
+	}
+}
+
+func TestHealthRootDeprecationHeader(t *testing.T) {
+	tests := []struct {
+		name       string
+		deprecated bool
+		path       string
+		want       string
+	}{
+		{name: "root alias deprecated", deprecated: true, path: HealthAliasRoot, want: "true"},
+		{name: "root alias not deprecated", deprecated: false, path: HealthAliasRoot, want: ""},
+		{name: "api alias never deprecated", deprecated: true, path: HealthAliasAPI, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newHealthTestRouter(&config.Config{HealthRootDeprecated: tt.deprecated})
+
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if got := response.Header().Get("Deprecation"); got != tt.want {
+				t.Errorf("Deprecation header = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLivenessIgnoresDependencies(t *testing.T) {
+	router := newHealthTestRouter(&config.Config{})
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, HealthAliasLive, nil))
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("liveness status = %d, want %d", response.Code, http.StatusOK)
+	}
+}
+
+func TestHealthResourceCheckCombinations(t *testing.T) {
+	tests := []struct {
+		name       string
+		database   error
+		disk       string
+		memory     string
+		wantCode   int
+		wantStatus string
+	}{
+		{name: "all healthy", disk: checkHealthy, memory: checkHealthy, wantCode: http.StatusOK, wantStatus: "healthy"},
+		{name: "disk degraded", disk: checkDegraded, memory: checkHealthy, wantCode: http.StatusOK, wantStatus: "degraded"},
+		{name: "memory degraded", disk: checkHealthy, memory: checkDegraded, wantCode: http.StatusOK, wantStatus: "degraded"},
+		{name: "disk unhealthy", disk: checkUnhealthy, memory: checkHealthy, wantCode: http.StatusServiceUnavailable, wantStatus: "unhealthy"},
+		{name: "memory unhealthy", disk: checkDegraded, memory: checkUnhealthy, wantCode: http.StatusServiceUnavailable, wantStatus: "unhealthy"},
+		{name: "disk unknown", disk: checkUnknown, memory: checkHealthy, wantCode: http.StatusOK, wantStatus: "healthy"},
+		{name: "database down", database: errors.New("down"), disk: checkDegraded, memory: checkHealthy, wantCode: http.StatusServiceUnavailable, wantStatus: "unhealthy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(pgstore.New(&db.DB{}), &config.Config{})
+			handler.pingDB = func(context.Context) error { return tt.database }
+			handler.checks = []resourceCheck{
+				{name: "disk", check: func() string { return tt.disk }},
+				{name: "memory", check: func() string { return tt.memory }},
+			}
+
+			code, resp := handler.health(context.Background())
+
+			if code != tt.wantCode {
+				t.Errorf("status code = %d, want %d", code, tt.wantCode)
+			}
+
+			if resp.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", resp.Status, tt.wantStatus)
+			}
+
+			if resp.Checks["disk"] != tt.disk || resp.Checks["memory"] != tt.memory {
+				t.Errorf("checks = %v, want disk=%q memory=%q", resp.Checks, tt.disk, tt.memory)
+			}
+		})
+	}
+}
+
+func TestCheckDiskSpaceUsesConfiguredPath(t *testing.T) {
+	handler := NewHandler(pgstore.New(&db.DB{}), &config.Config{HealthDiskPath: "/nonexistent/ots-health-path"})
+
+	if got := handler.checkDiskSpace(); got != checkUnhealthy && got != checkUnknown {
+		t.Errorf("checkDiskSpace() on missing path = %q, want %q", got, checkUnhealthy)
+	}
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/config"
+	httpMiddleware "ots-backend/internal/middleware"
+	"ots-backend/internal/models"
+	"ots-backend/internal/policy"
+)
+
+// EnvDevelopment is the only ENV value error hints are served in
+const EnvDevelopment = "development"
+
+// hintsEnabled reports whether 4xx errors carry hints: only when asked for
+// with ERROR_HINTS and ENV is development. ENV defaults to development, so
+// it alone would expose them on a server started without it.
+func hintsEnabled(cfg *config.Config) bool {
+	return cfg.ErrorHints && cfg.Environment == EnvDevelopment
+}
+
+// errorHints explains each error code to a developer reading a failed call
+var errorHints = map[string]string{
+	"invalid_request_body":      "The body must be JSON shaped like docs.example, sent with the headers in docs.headers.",
+	"invalid_ciphertext":        "ciphertext must be base64 (standard or URL-safe, padding optional) of the AES-GCM output; encrypt in the client first.",
+	"invalid_iv":                "iv must be base64 (standard or URL-safe, padding optional) of the 12-byte AES-GCM nonce.",
+	"invalid_salt":              "salt is optional; when set it must be base64, standard or URL-safe, padding optional.",
+	"invalid_plaintext":         "content must be non-empty text within docs.constraints.",
+	"invalid_secret_id":         "Use the id returned by the create call unchanged.",
+	"invalid_ttl":               "expires_in is in seconds and must fall within docs.constraints.",
+	"secret_too_large":          "The decoded ciphertext is larger than docs.constraints allows.",
+	"invalid_parts":             "parts needs unique, non-empty labels and an entry count within docs.constraints.",
+	"invalid_key_bits":          "declared_key_bits must fall within docs.constraints.",
+	"key_too_weak":              "declared_key_bits is below the minimum in docs.constraints.",
+	"key_bits_required":         "This server requires declared_key_bits within docs.constraints.",
+	"not_found":                 "Secrets can be read once; the ID is unknown, already read, burned or expired.",
+	"management_token_required": "Send the management_token returned by the create call in X-Management-Token.",
+	"create_nonce_required":     "Fetch GET /api/secrets/nonce first and send its nonce in X-Create-Nonce with the cookie it set.",
+	"create_nonce_expired":      "Fetch a fresh nonce from GET /api/secrets/nonce and retry.",
+	"invalid_audit_query":       "Check the since, until, type, cursor and limit query parameters.",
+	"invalid_stats_query":       "from and to are dates like 2026-05-14, from no later than to, at most 366 days apart.",
+	"invalid_reputation_query":  "limit is a whole number from 1 to 500 and ip an IPv4 or IPv6 address.",
+	"policy_violation":          "This server's metadata policy rejected a part label or filename; violation names the rule.",
+	"wrong_region":              "Another regional deployment created this secret; resend the request to region.base_url.",
+	"invalid_slug":              "slug needs 8 to 64 lowercase letters, digits or hyphens, and this server must report slugs_supported in /api/config.",
+	"method_not_allowed":        "The Allow header lists the methods this path accepts.",
+	"slug_taken":                "Another secret holds this slug, or held it recently; choose another or omit slug for a generated ID.",
+	"invalid_available_after":   "available_after takes an RFC 3339 time or whole seconds from now, and must come before the secret expires.",
+	"invalid_hint":              "hint is plain text of at most the length in docs.constraints; it is shown to anyone holding the link.",
+	"invalid_notify_email":      "notify_email takes one bare address like alice@example.com, and this server must report notify_email_supported in /api/config.",
+	"invalid_content_type":      "content_type is one of text/plain, application/json or application/octet-stream, or omitted.",
+	"invalid_filename":          "filename is a bare name of at most 255 characters, without path separators or control characters.",
+	"not_yet_available":         "This secret is scheduled for later release; retry after the Retry-After header or available_after. It was not consumed.",
+	"tenant_deleted":            "An operator deleted this namespace and it takes no new secrets; create without namespace or use another.",
+	"rate_limited":              "This client sent too many requests; retry after the Retry-After header.",
+	"quota_exceeded":            "This client holds as many unread secrets as the server allows; retry after some are read, burned or expire.",
+	"revision_mismatch":         "The secret changed after the revision sent in If-Match; fetch its receipt for the current ETag and decide again.",
+}
+
+// defaultHint covers errors without a code of their own
+const defaultHint = "Compare the request with docs.example."
+
+// hintWriter carries the request to respondErrorBody, which only sees the
+// ResponseWriter
+type hintWriter struct {
+	http.ResponseWriter
+	r *http.Request
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *hintWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// withErrorHints lets respondErrorBody explain 4xx errors; Routes installs
+// it in development only
+func withErrorHints(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&hintWriter{ResponseWriter: w, r: r}, r)
+	})
+}
+
+// findWriter looks for a writer of type T under any writers middleware
+// wrapped around it, such as the adaptive rate limiter's
+func findWriter[T http.ResponseWriter](w http.ResponseWriter) (T, bool) {
+	for {
+		if found, ok := w.(T); ok {
+			return found, true
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			var zero T
+			return zero, false
+		}
+		w = unwrapper.Unwrap()
+	}
+}
+
+// addHints fills body's hint and docs for the request w answers. Writers
+// not wrapped by withErrorHints, as in production, are left alone.
+func (h *Handler) addHints(w http.ResponseWriter, status int, body *models.ErrorResponse) {
+	hw, ok := findWriter[*hintWriter](w)
+	if !ok || status < 400 || status >= 500 {
+		return
+	}
+
+	body.Hint = errorHints[body.Code]
+	if body.Hint == "" {
+		body.Hint = defaultHint
+	}
+
+	pattern := chi.RouteContext(hw.r.Context()).RoutePattern()
+	docs, limits := h.endpointDocs(hw.r.Method, strings.TrimPrefix(pattern, "/api"))
+	if docs == nil {
+		return
+	}
+	docs.Endpoint = hw.r.Method + " " + pattern
+
+	if body.Limit != nil {
+		docs.Constraints = []policy.LimitDetail{*body.Limit}
+	} else {
+		for _, name := range limits {
+			if detail := h.policy().Detail(name); detail != nil {
+				docs.Constraints = append(docs.Constraints, *detail)
+			}
+		}
+	}
+	body.Docs = docs
+}
+
+// endpointDocs returns a minimal valid call to an endpoint and the limits
+// that apply to it, or nil for endpoints without input to get wrong
+func (h *Handler) endpointDocs(method, pattern string) (*models.ErrorDocs, []string) {
+	jsonBody := map[string]string{"Content-Type": "application/json"}
+
+	switch method + " " + pattern {
+	case "POST /secrets":
+		headers := jsonBody
+		if h.config().RequireCreateNonce {
+			headers = map[string]string{"Content-Type": "application/json", httpMiddleware.CreateNonceHeader: "<nonce from GET /api/secrets/nonce>"}
+		}
+		return &models.ErrorDocs{
+			Headers: headers,
+			Example: models.CreateSecretRequest{
+				Ciphertext:    base64.StdEncoding.EncodeToString([]byte("<AES-GCM ciphertext>")),
+				IV:            base64.StdEncoding.EncodeToString(make([]byte, 12)),
+				ExpiresIn:     exampleTTL(h.policy(), h.policy().DefaultTTL),
+				BurnAfterRead: true,
+			},
+		}, []string{policy.LimitSecretSize, policy.LimitTTL, policy.LimitParts, policy.LimitPartLabel, policy.LimitHint, policy.LimitKeyBits}
+	case "POST /secrets/validate":
+		return &models.ErrorDocs{
+			Headers: jsonBody,
+			Example: models.ValidateSecretRequest{
+				CreateSecretRequest: models.CreateSecretRequest{ExpiresIn: exampleTTL(h.policy(), h.policy().DefaultTTL)},
+				Size:                h.policy().MinSecretSize,
+			},
+		}, []string{policy.LimitSecretSize, policy.LimitTTL}
+	case "POST /agent/secrets":
+		return &models.ErrorDocs{
+			Headers: jsonBody,
+			Example: models.AgentCreateSecretRequest{Content: "my secret", ExpiresIn: exampleTTL(h.policy(), h.policy().AgentDefaultTTL)},
+		}, []string{policy.LimitSecretSize, policy.LimitTTL}
+	case "GET /secrets/{id}":
+		return &models.ErrorDocs{}, nil
+	case "DELETE /secrets/{id}":
+		return &models.ErrorDocs{Headers: map[string]string{ManagementTokenHeader: "<management_token from the create response>"}}, nil
+	case "POST /secrets/{id}/ack":
+		return &models.ErrorDocs{
+			Headers: jsonBody,
+			Example: models.AckSecretRequest{AckToken: "<ack_token from the read response>"},
+		}, nil
+	case "POST /secrets/{id}/report":
+		return &models.ErrorDocs{
+			Headers: jsonBody,
+			Example: models.ReportSecretRequest{Reason: models.ReportSuspectedInterception},
+		}, nil
+	}
+	return nil, nil
+}
+
+// exampleTTL returns ttl in seconds, pulled inside the policy's bounds
+func exampleTTL(p *policy.Policy, ttl time.Duration) int {
+	return int(min(max(ttl, p.MinTTL), p.MaxTTL) / time.Second)
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/models"
+	"ots-backend/internal/policy"
+)
+
+// postErrorResponse sends body to path and decodes the 4xx error it expects
+func postErrorResponse(t *testing.T, router http.Handler, path, body string) models.ErrorResponse {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	if response.Code < 400 || response.Code >= 500 {
+		t.Fatalf("POST %s status = %d, want 4xx", path, response.Code)
+	}
+
+	var errResp models.ErrorResponse
+	if err := json.NewDecoder(response.Body).Decode(&errResp); err != nil {
+		t.Fatalf("failed to decode error response: %v", err)
+	}
+	return errResp
+}
+
+func TestErrorHintsByEnvironment(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		tooLong := 365 * 24 * 60 * 60
+		body := marshalJSON(t, getMockCreateSecretRequest(&createSecretOverrides{ExpiresIn: &tooLong}))
+
+		dev := newTestRouterWithConfig(t, b, func(cfg *config.Config) { cfg.Environment, cfg.ErrorHints = EnvDevelopment, true })
+		errResp := postErrorResponse(t, dev, "/api/secrets", body)
+		if errResp.Code != "invalid_ttl" {
+			t.Fatalf("code = %q, want invalid_ttl", errResp.Code)
+		}
+		if errResp.Hint == "" {
+			t.Error("development error has no hint")
+		}
+		if errResp.Docs == nil {
+			t.Fatal("development error has no docs")
+		}
+		if errResp.Docs.Endpoint != "POST /api/secrets" {
+			t.Errorf("docs.endpoint = %q, want POST /api/secrets", errResp.Docs.Endpoint)
+		}
+		if len(errResp.Docs.Constraints) != 1 || errResp.Docs.Constraints[0].Name != policy.LimitTTL {
+			t.Errorf("docs.constraints = %+v, want only the ttl limit", errResp.Docs.Constraints)
+		}
+
+		// The example must itself be a request the server accepts
+		example := marshalJSON(t, errResp.Docs.Example)
+		request := httptest.NewRequest(http.MethodPost, "/api/secrets", strings.NewReader(example))
+		request.Header.Set("Content-Type", "application/json")
+		response := httptest.NewRecorder()
+		dev.ServeHTTP(response, request)
+		if response.Code != http.StatusCreated {
+			t.Errorf("docs.example status = %d, want %d: %s", response.Code, http.StatusCreated, response.Body.String())
+		}
+
+		prod := newTestRouterWithConfig(t, b, func(cfg *config.Config) { cfg.Environment, cfg.ErrorHints = "production", true })
+		errResp = postErrorResponse(t, prod, "/api/secrets", body)
+		if errResp.Code != "invalid_ttl" {
+			t.Fatalf("code = %q, want invalid_ttl", errResp.Code)
+		}
+		if errResp.Hint != "" || errResp.Docs != nil {
+			t.Errorf("production error has hint %q and docs %+v, want neither", errResp.Hint, errResp.Docs)
+		}
+
+		// ENV defaults to development, so it takes ERROR_HINTS as well
+		quiet := newTestRouterWithConfig(t, b, func(cfg *config.Config) { cfg.Environment = EnvDevelopment })
+		errResp = postErrorResponse(t, quiet, "/api/secrets", body)
+		if errResp.Hint != "" || errResp.Docs != nil {
+			t.Errorf("development error without ERROR_HINTS has hint %q and docs %+v, want neither", errResp.Hint, errResp.Docs)
+		}
+	})
+}
+
+func TestErrorHintsListEndpointLimits(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouterWithConfig(t, b, func(cfg *config.Config) { cfg.Environment, cfg.ErrorHints = EnvDevelopment, true })
+
+		errResp := postErrorResponse(t, router, "/api/secrets", `{"ciphertext":`)
+		if errResp.Code != "invalid_request_body" {
+			t.Fatalf("code = %q, want invalid_request_body", errResp.Code)
+		}
+		if errResp.Docs == nil || len(errResp.Docs.Constraints) < 2 {
+			t.Fatalf("docs = %+v, want every create limit", errResp.Docs)
+		}
+		if !strings.Contains(errResp.Hint, "docs.example") {
+			t.Errorf("hint = %q, want a pointer to docs.example", errResp.Hint)
+		}
+	})
+}
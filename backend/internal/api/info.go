@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"ots-backend/internal/crypto"
+	"ots-backend/internal/models"
+)
+
+// CryptoAttestation returns the crypto mode report, computed once; the mode
+// cannot change while the process runs
+func (h *Handler) CryptoAttestation() crypto.Attestation {
+	h.attestationOnce.Do(func() {
+		h.attestation = crypto.Attest()
+	})
+	return h.attestation
+}
+
+// info builds the server info body. Capabilities that depend on a crypto job
+// are reported unavailable when that job failed its self-test.
+func (h *Handler) info() models.InfoResponse {
+	attestation := h.CryptoAttestation()
+	available := attestation.Available
+
+	return models.InfoResponse{
+		Crypto: attestation,
+		Capabilities: map[string]bool{
+			"agent_passphrase": available[crypto.JobPassphraseKDF],
+			"create_nonce":     h.config().RequireCreateNonce && available[crypto.JobNonceSigning],
+			"crypto_shredding": h.config().CryptoShredding && available[crypto.JobEnvelopeEncryption],
+			"management_token": available[crypto.JobTokenHashing],
+			"require_ack":      available[crypto.JobTokenHashing],
+		},
+	}
+}
+
+// Info reports the active crypto mode and which features it supports
+func (h *Handler) Info(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.info())
+}
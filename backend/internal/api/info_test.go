@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/crypto"
+	"ots-backend/internal/db"
+	"ots-backend/internal/models"
+	pgstore "ots-backend/internal/store/postgres"
+)
+
+func TestInfoAttestsCryptoMode(t *testing.T) {
+	for _, shredding := range []bool{false, true} {
+		t.Run("shredding="+strconv.FormatBool(shredding), func(t *testing.T) {
+			handler := NewHandler(pgstore.New(&db.DB{}), &config.Config{CryptoShredding: shredding})
+			mux := chi.NewRouter()
+			mux.Mount("/api", handler.Routes())
+			router := withSpecValidation(t, handler, mux)
+
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/v1/info", nil))
+			if response.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", response.Code, http.StatusOK)
+			}
+
+			var info models.InfoResponse
+			if err := json.NewDecoder(response.Body).Decode(&info); err != nil {
+				t.Fatalf("decode info: %v", err)
+			}
+
+			if info.Crypto.Mode != crypto.CurrentMode() {
+				t.Errorf("crypto mode = %q, want %q", info.Crypto.Mode, crypto.CurrentMode())
+			}
+			if info.Crypto.Algorithms[crypto.JobPassphraseKDF] != "PBKDF2-HMAC-SHA256" {
+				t.Errorf("passphrase kdf = %q, want PBKDF2-HMAC-SHA256", info.Crypto.Algorithms[crypto.JobPassphraseKDF])
+			}
+
+			want := map[string]bool{
+				"agent_passphrase": true,
+				"create_nonce":     false,
+				"crypto_shredding": shredding,
+				"management_token": true,
+				"require_ack":      true,
+			}
+			for name, enabled := range want {
+				if info.Capabilities[name] != enabled {
+					t.Errorf("capability %s = %v, want %v", name, info.Capabilities[name], enabled)
+				}
+			}
+		})
+	}
+}
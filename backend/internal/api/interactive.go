@@ -0,0 +1,37 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"ots-backend/internal/models"
+)
+
+// ClientHeader marks a request from a client a person is driving, such as
+// the web app or the CLI. With REQUIRE_CLIENT_HEADER on, reads and burns
+// without it get a metadata-only answer.
+const (
+	ClientHeader      = "X-OTS-Client"
+	ClientInteractive = "interactive"
+)
+
+// respondIfNotInteractive answers a read, redeem or burn that lacks the
+// client header with metadata only and reports whether it did. The store is
+// not touched, so link scanners in mail gateways can neither consume nor
+// burn the secret, nor learn whether it exists. ref is what the request
+// named: the secret ID, or a redeem's link token, which must not be traded
+// for the ID.
+func (h *Handler) respondIfNotInteractive(w http.ResponseWriter, r *http.Request, ref string) bool {
+	if !h.config().RequireClientHeader || r.Header.Get(ClientHeader) == ClientInteractive {
+		return false
+	}
+
+	RecordNonInteractive()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.SecretMetadataResponse{
+		ID:                   ref,
+		ClientHeaderRequired: ClientHeader + ": " + ClientInteractive,
+		Message:              "open the link in a browser to view the secret",
+	})
+	return true
+}
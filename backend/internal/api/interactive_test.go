@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ots-backend/internal/config"
+	httpMiddleware "ots-backend/internal/middleware"
+	"ots-backend/internal/models"
+)
+
+// sendSecretRequest sends method to a secret, with the interactive client
+// header when interactive is set
+func sendSecretRequest(router http.Handler, method, secretID, managementToken string, interactive bool) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, "/api/secrets/"+secretID, nil)
+	if managementToken != "" {
+		request.Header.Set(ManagementTokenHeader, managementToken)
+	}
+	if interactive {
+		request.Header.Set(ClientHeader, ClientInteractive)
+	}
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
+
+func TestClientHeaderOffByDefault(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouter(t, b)
+
+		created := createTestSecretResponse(t, router, getMockCreateSecretRequest(nil))
+		if response := sendSecretRequest(router, http.MethodGet, created.ID, "", false); response.Code != http.StatusOK || !strings.Contains(response.Body.String(), "ciphertext") {
+			t.Fatalf("GetSecret() without header = %d %s, want the secret", response.Code, response.Body.String())
+		}
+
+		created = createTestSecretResponse(t, router, getMockCreateSecretRequest(nil))
+		if response := sendSecretRequest(router, http.MethodDelete, created.ID, created.ManagementToken, false); response.Code != http.StatusNoContent {
+			t.Fatalf("BurnSecret() without header status = %d, want %d", response.Code, http.StatusNoContent)
+		}
+	})
+}
+
+func TestClientHeaderRequired(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouterWithConfig(t, b, func(cfg *config.Config) { cfg.RequireClientHeader = true })
+		created := createTestSecretResponse(t, router, getMockCreateSecretRequest(nil))
+		before := GetMetrics().NonInteractive
+
+		// A link scanner gets metadata only, and the secret survives it
+		for _, method := range []string{http.MethodGet, http.MethodDelete, http.MethodGet} {
+			response := sendSecretRequest(router, method, created.ID, created.ManagementToken, false)
+			if response.Code != http.StatusOK {
+				t.Fatalf("%s without header status = %d, want %d", method, response.Code, http.StatusOK)
+			}
+			var metadata models.SecretMetadataResponse
+			if err := json.NewDecoder(response.Body).Decode(&metadata); err != nil {
+				t.Fatalf("decode metadata response: %v", err)
+			}
+			if metadata.ID != created.ID || metadata.ClientHeaderRequired != "X-OTS-Client: interactive" {
+				t.Errorf("%s without header = %+v", method, metadata)
+			}
+		}
+		if got := GetMetrics().NonInteractive - before; got != 3 {
+			t.Errorf("non_interactive_total grew by %d, want 3", got)
+		}
+
+		// Unknown IDs look the same, so the answer reveals nothing
+		unknown := sendSecretRequest(router, http.MethodGet, strings.Repeat("A", 22), "", false)
+		if unknown.Code != http.StatusOK {
+			t.Errorf("GetSecret() unknown ID without header status = %d, want %d", unknown.Code, http.StatusOK)
+		}
+
+		if response := sendSecretRequest(router, http.MethodGet, created.ID, "", true); response.Code != http.StatusOK || !strings.Contains(response.Body.String(), "ciphertext") {
+			t.Fatalf("GetSecret() with header = %d %s, want the secret", response.Code, response.Body.String())
+		}
+
+		created = createTestSecretResponse(t, router, getMockCreateSecretRequest(nil))
+		if response := sendSecretRequest(router, http.MethodDelete, created.ID, created.ManagementToken, true); response.Code != http.StatusNoContent {
+			t.Fatalf("BurnSecret() with header status = %d, want %d", response.Code, http.StatusNoContent)
+		}
+	})
+}
+
+func TestClientHeaderPreflight(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := httpMiddleware.CORS(CORSOptions([]string{"https://app.example.com"}))(
+			newTestRouterWithConfig(t, b, func(cfg *config.Config) { cfg.RequireClientHeader = true }),
+		)
+
+		for _, method := range []string{http.MethodGet, http.MethodDelete} {
+			request := httptest.NewRequest(http.MethodOptions, "/api/secrets/"+strings.Repeat("A", 22), nil)
+			request.Header.Set("Origin", "https://app.example.com")
+			request.Header.Set("Access-Control-Request-Method", method)
+			request.Header.Set("Access-Control-Request-Headers", "x-ots-client")
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, request)
+
+			if got := response.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+				t.Fatalf("%s preflight Access-Control-Allow-Origin = %q", method, got)
+			}
+			if got := response.Header().Get("Access-Control-Allow-Headers"); !strings.EqualFold(got, ClientHeader) {
+				t.Errorf("%s preflight Access-Control-Allow-Headers = %q, want %s", method, got, ClientHeader)
+			}
+		}
+
+		created := createTestSecretResponse(t, router, getMockCreateSecretRequest(nil))
+		request := httptest.NewRequest(http.MethodGet, "/api/secrets/"+created.ID, nil)
+		request.Header.Set("Origin", "https://app.example.com")
+		request.Header.Set(ClientHeader, ClientInteractive)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		if response.Code != http.StatusOK || !strings.Contains(response.Body.String(), "ciphertext") {
+			t.Fatalf("cross-origin GetSecret() with header = %d %s, want the secret", response.Code, response.Body.String())
+		}
+	})
+}
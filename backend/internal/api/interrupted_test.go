@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/models"
+)
+
+// TestInterruptedCreateStoresNothing cuts create bodies off at several
+// offsets, as a client disconnecting mid-upload does. Creates are decoded
+// in full before anything is written, so no cut leaves a secret, whole or
+// truncated, behind.
+func TestInterruptedCreateStoresNothing(t *testing.T) {
+	iv := base64.StdEncoding.EncodeToString(make([]byte, 12))
+	partsBody, err := json.Marshal(models.CreateSecretRequest{
+		Parts: []models.SecretPart{
+			{Label: "username", Ciphertext: base64.StdEncoding.EncodeToString([]byte("alice")), IV: iv},
+			{Label: "password", Ciphertext: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("p"), 4096)), IV: iv},
+		},
+		ExpiresIn:     int((15 * time.Minute).Seconds()),
+		BurnAfterRead: true,
+	})
+	if err != nil {
+		t.Fatalf("marshal create request: %v", err)
+	}
+
+	var fileBody bytes.Buffer
+	writer := multipart.NewWriter(&fileBody)
+	fileWriter, err := writer.CreateFormFile("file", ".env")
+	if err != nil {
+		t.Fatalf("CreateFormFile() error: %v", err)
+	}
+	fileWriter.Write(bytes.Repeat([]byte("DATABASE_URL=postgres://secret\n"), 100))
+	writer.Close()
+
+	uploads := []struct {
+		name        string
+		path        string
+		contentType string
+		body        []byte
+	}{
+		{"parts", "/api/secrets", "application/json", partsBody},
+		{"agent file", "/api/agent/secrets", writer.FormDataContentType(), fileBody.Bytes()},
+	}
+
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		for _, shredding := range []bool{false, true} {
+			b.reset(t)
+			cfg := auditTestConfig()
+			cfg.CryptoShredding = shredding
+			cfg.AgentRateLimitRequests = 1000
+			cfg.AgentRateLimitWindow = time.Minute
+			cfg.AgentDefaultTTL = time.Hour
+			router := chi.NewRouter()
+			router.Mount("/api", NewHandler(b.store, cfg).Routes())
+
+			for i, upload := range uploads {
+				// Each earlier upload finished with one whole secret
+				stored := int64(i)
+				n := len(upload.body)
+				for _, offset := range []int{0, 1, n / 4, n / 2, n - 2, n - 1} {
+					body := io.MultiReader(bytes.NewReader(upload.body[:offset]), iotest.ErrReader(io.ErrUnexpectedEOF))
+					request := httptest.NewRequest(http.MethodPost, upload.path, body)
+					request.Header.Set("Content-Type", upload.contentType)
+					response := httptest.NewRecorder()
+					router.ServeHTTP(response, request)
+
+					if response.Code != http.StatusBadRequest {
+						t.Errorf("shredding=%v: %s cut at %d/%d status = %d, want %d", shredding, upload.name, offset, n, response.Code, http.StatusBadRequest)
+					}
+					if count, err := b.store.CountActive(context.Background()); err != nil || count != stored {
+						t.Fatalf("shredding=%v: %s cut at %d/%d left %d secrets, %v; want %d", shredding, upload.name, offset, n, count, err, stored)
+					}
+				}
+
+				request := httptest.NewRequest(http.MethodPost, upload.path, bytes.NewReader(upload.body))
+				request.Header.Set("Content-Type", upload.contentType)
+				response := httptest.NewRecorder()
+				router.ServeHTTP(response, request)
+				if response.Code != http.StatusCreated {
+					t.Fatalf("shredding=%v: whole %s status = %d, want %d", shredding, upload.name, response.Code, http.StatusCreated)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/nacl/secretbox"
+
+	"ots-backend/internal/models"
+)
+
+// sealEmbedded encrypts plaintext the way nonce-prefixing clients do and
+// returns the blob plus a function that opens it again
+func sealEmbedded(t *testing.T, algorithm string, plaintext []byte) ([]byte, func([]byte) ([]byte, error)) {
+	t.Helper()
+
+	key := make([]byte, 32)
+	rand.Read(key)
+
+	switch algorithm {
+	case "aes-256-gcm", "xchacha20-poly1305":
+		var aead cipher.AEAD
+		var err error
+		if algorithm == "aes-256-gcm" {
+			block, _ := aes.NewCipher(key)
+			aead, err = cipher.NewGCM(block)
+		} else {
+			aead, err = chacha20poly1305.NewX(key)
+		}
+		if err != nil {
+			t.Fatalf("new %s: %v", algorithm, err)
+		}
+		nonce := make([]byte, aead.NonceSize())
+		rand.Read(nonce)
+		open := func(blob []byte) ([]byte, error) {
+			return aead.Open(nil, blob[:aead.NonceSize()], blob[aead.NonceSize():], nil)
+		}
+		return aead.Seal(nonce, nonce, plaintext, nil), open
+	case "xsalsa20-poly1305":
+		var secretKey [32]byte
+		var nonce [24]byte
+		copy(secretKey[:], key)
+		rand.Read(nonce[:])
+		open := func(blob []byte) ([]byte, error) {
+			copy(nonce[:], blob[:24])
+			opened, ok := secretbox.Open(nil, blob[24:], &nonce, &secretKey)
+			if !ok {
+				return nil, errors.New("secretbox: decryption failed")
+			}
+			return opened, nil
+		}
+		return secretbox.Seal(nonce[:], plaintext, &nonce, &secretKey), open
+	}
+	t.Fatalf("unknown algorithm %q", algorithm)
+	return nil, nil
+}
+
+func TestIVEmbeddedRoundTrip(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouter(t, b)
+
+		for _, algorithm := range []string{"aes-256-gcm", "xchacha20-poly1305", "xsalsa20-poly1305"} {
+			t.Run(algorithm, func(t *testing.T) {
+				blob, open := sealEmbedded(t, algorithm, []byte("hunter2"))
+				secretID := createTestSecret(t, router, models.CreateSecretRequest{
+					Ciphertext:    base64.StdEncoding.EncodeToString(blob),
+					IVEmbedded:    true,
+					Algorithm:     algorithm,
+					ExpiresIn:     3600,
+					BurnAfterRead: true,
+				})
+
+				response := httptest.NewRecorder()
+				router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/"+secretID, nil))
+				if response.Code != http.StatusOK {
+					t.Fatalf("GetSecret() status = %d, want %d", response.Code, http.StatusOK)
+				}
+
+				var got models.GetSecretResponse
+				if err := json.NewDecoder(response.Body).Decode(&got); err != nil {
+					t.Fatalf("decode response: %v", err)
+				}
+				if !got.IVEmbedded || got.IV != "" {
+					t.Errorf("GetSecret() iv_embedded = %v, iv = %q; want true and no iv", got.IVEmbedded, got.IV)
+				}
+				returned, _ := base64.StdEncoding.DecodeString(got.Ciphertext)
+				plaintext, err := open(returned)
+				if err != nil || string(plaintext) != "hunter2" {
+					t.Errorf("open returned blob = %q, %v; want hunter2", plaintext, err)
+				}
+			})
+		}
+	})
+}
+
+func TestIVEmbeddedRejects(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouter(t, b)
+		blob := base64.StdEncoding.EncodeToString(make([]byte, 64))
+		iv := base64.StdEncoding.EncodeToString(make([]byte, 12))
+
+		tests := []struct {
+			name string
+			req  models.CreateSecretRequest
+			code string
+		}{
+			{"iv and iv_embedded", models.CreateSecretRequest{Ciphertext: blob, IV: iv, IVEmbedded: true, ExpiresIn: 3600}, "invalid_iv"},
+			{"too short for xchacha", models.CreateSecretRequest{Ciphertext: base64.StdEncoding.EncodeToString(make([]byte, 40)), IVEmbedded: true, Algorithm: "xchacha20-poly1305", ExpiresIn: 3600}, "invalid_ciphertext"},
+			{"algorithm without iv_embedded", models.CreateSecretRequest{Ciphertext: blob, IV: iv, Algorithm: "aes-256-gcm", ExpiresIn: 3600}, "invalid_algorithm"},
+			{"parts", models.CreateSecretRequest{IVEmbedded: true, Parts: []models.SecretPart{{Label: "a", Ciphertext: blob, IV: iv}}, ExpiresIn: 3600}, "invalid_parts"},
+		}
+		for _, tt := range tests {
+			if errResp := postErrorResponse(t, router, "/api/secrets", marshalJSON(t, tt.req)); errResp.Code != tt.code {
+				t.Errorf("%s: code = %q, want %q", tt.name, errResp.Code, tt.code)
+			}
+		}
+	})
+}
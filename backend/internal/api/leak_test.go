@@ -0,0 +1,283 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"ots-backend/internal/logger"
+	"ots-backend/internal/models"
+	"ots-backend/internal/policy"
+	"ots-backend/internal/store"
+	"ots-backend/internal/tracing"
+	"ots-backend/internal/validation"
+)
+
+// Canary payloads the leak tests send; each begins with "LEAK" so its Go
+// byte-slice form starts with leakCanaryBytes
+const (
+	leakCiphertext = "LEAKCANARY-ciphertext-0123456789abcdef"
+	leakIV         = "LEAKCANARYiv"
+	leakSalt       = "LEAKCANARY-salt-0123"
+	leakPlaintext  = "LEAKCANARY-agent-plaintext"
+	leakPassphrase = "LEAKCANARY-passphrase"
+	// leakCanaryBytes is "LEAK" as %#v prints a []byte
+	leakCanaryBytes = "0x4c, 0x45, 0x41, 0x4b"
+)
+
+// leakSink collects everything the server writes outside response bodies
+type leakSink struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (s *leakSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Write(p)
+}
+
+func (s *leakSink) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.String()
+}
+
+// leakStore records audit events as JSON and, while failCreate is set,
+// fails creates with the error pgx returns for an argument it cannot
+// encode, which quotes the argument
+type leakStore struct {
+	store.Store
+	events     io.Writer
+	failCreate bool
+}
+
+func (s *leakStore) Create(ctx context.Context, secret *store.Secret) error {
+	if s.failCreate {
+		_, err := pgtype.NewMap().Encode(pgtype.Int4OID, pgtype.BinaryFormatCode, secret.Ciphertext, nil)
+		return fmt.Errorf("insert secret: failed to encode args[1]: %w", err)
+	}
+	return s.Store.Create(ctx, secret)
+}
+
+func (s *leakStore) RecordAudit(ctx context.Context, event *store.AuditEvent) error {
+	if err := json.NewEncoder(s.events).Encode(event); err != nil {
+		return err
+	}
+	return s.Store.RecordAudit(ctx, event)
+}
+
+func TestSensitiveValuesNeverLeak(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		for _, shredding := range []bool{false, true} {
+			t.Run(fmt.Sprintf("shredding=%v", shredding), func(t *testing.T) {
+				b.reset(t)
+				runLeakWorkload(t, b, shredding)
+			})
+		}
+	})
+}
+
+// runLeakWorkload creates, peeks, reads, acknowledges and burns secrets,
+// dry-runs creates, fails a store write, sends bad input and panics with
+// request bodies, then scans the log, audit events, spans and error bodies
+// for the payloads and tokens involved
+func runLeakWorkload(t *testing.T, b *testBackend, shredding bool) {
+	logs, events, errorBodies := &leakSink{}, &leakSink{}, &leakSink{}
+	logger.SetOutput(logs)
+	t.Cleanup(func() { logger.SetOutput(os.Stdout) })
+
+	exporter := tracetest.NewInMemoryExporter()
+	tracing.SetProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { tracing.SetProvider(nil) })
+
+	cfg := auditTestConfig()
+	cfg.AllowOpenDelete = false
+	cfg.AckWindow = time.Minute
+	cfg.AgentDefaultTTL = time.Hour
+	cfg.AgentRateLimitRequests = 1000
+	cfg.AgentRateLimitWindow = time.Minute
+	cfg.CryptoShredding = shredding
+	leaky := &leakStore{Store: b.store, events: events}
+	handler := NewHandler(leaky, cfg)
+	api := chi.NewRouter()
+	api.Use(RecoveryMiddleware)
+	api.Mount("/api", handler.Routes())
+	api.Post("/panic/{kind}", panicWithRequest)
+	router := tracing.Middleware(LoggingMiddleware(api))
+
+	secrets := []string{leakCiphertext, leakIV, leakSalt, leakPlaintext, leakPassphrase}
+	send := func(method, path, body string, header http.Header) *httptest.ResponseRecorder {
+		t.Helper()
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		for name, values := range header {
+			request.Header[name] = values
+		}
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		if response.Code >= http.StatusBadRequest {
+			errorBodies.Write(response.Body.Bytes())
+		}
+		return response
+	}
+
+	req := getMockCreateSecretRequest(nil)
+	req.Ciphertext = base64.StdEncoding.EncodeToString([]byte(leakCiphertext))
+	req.IV = base64.StdEncoding.EncodeToString([]byte(leakIV))
+	req.Salt = base64.StdEncoding.EncodeToString([]byte(leakSalt))
+	req.RequireAck = true
+	body := marshalJSON(t, req)
+
+	// Create, peek, read and acknowledge
+	var created models.CreateSecretResponse
+	response := send(http.MethodPost, "/api/secrets", body, nil)
+	if response.Code != http.StatusCreated || json.NewDecoder(response.Body).Decode(&created) != nil {
+		t.Fatalf("create status = %d, want %d", response.Code, http.StatusCreated)
+	}
+	secrets = append(secrets, created.ManagementToken)
+	send(http.MethodHead, "/api/secrets/"+created.ID, "", nil)
+	var read models.GetSecretResponse
+	response = send(http.MethodGet, "/api/secrets/"+created.ID, "", nil)
+	if response.Code != http.StatusOK || json.NewDecoder(response.Body).Decode(&read) != nil || read.AckToken == "" {
+		t.Fatalf("read status = %d with ack token %q, want %d with one", response.Code, read.AckToken, http.StatusOK)
+	}
+	secrets = append(secrets, read.AckToken)
+	send(http.MethodPost, "/api/secrets/"+created.ID+"/ack", `{"ack_token":"`+read.AckToken+`x"}`, nil)
+	send(http.MethodPost, "/api/secrets/"+created.ID+"/ack", `{"ack_token":"`+read.AckToken+`"}`, nil)
+
+	// Burn, first with the wrong token
+	response = send(http.MethodPost, "/api/secrets", body, nil)
+	if response.Code != http.StatusCreated || json.NewDecoder(response.Body).Decode(&created) != nil {
+		t.Fatalf("second create status = %d, want %d", response.Code, http.StatusCreated)
+	}
+	secrets = append(secrets, created.ManagementToken)
+	send(http.MethodDelete, "/api/secrets/"+created.ID, "", http.Header{ManagementTokenHeader: {created.ManagementToken + "x"}})
+	if response = send(http.MethodDelete, "/api/secrets/"+created.ID, "", http.Header{ManagementTokenHeader: {created.ManagementToken}}); response.Code != http.StatusNoContent && response.Code != http.StatusOK {
+		t.Fatalf("burn status = %d, want success", response.Code)
+	}
+
+	// Server-side encryption of agent plaintext
+	var agent models.AgentCreateSecretResponse
+	response = send(http.MethodPost, "/api/agent/secrets", marshalJSON(t, models.AgentCreateSecretRequest{Content: leakPlaintext, Passphrase: leakPassphrase}), nil)
+	if response.Code != http.StatusCreated || json.NewDecoder(response.Body).Decode(&agent) != nil {
+		t.Fatalf("agent create status = %d, want %d", response.Code, http.StatusCreated)
+	}
+	response = send(http.MethodPost, "/api/agent/secrets", marshalJSON(t, models.AgentCreateSecretRequest{Content: leakPlaintext}), nil)
+	if response.Code != http.StatusCreated || json.NewDecoder(response.Body).Decode(&agent) != nil {
+		t.Fatalf("agent create without passphrase status = %d, want %d", response.Code, http.StatusCreated)
+	}
+	_, shareKey, _ := strings.Cut(agent.URL, "#")
+	secrets = append(secrets, shareKey, agent.ManagementToken)
+
+	// A failed write, bad input and panics carrying the request
+	leaky.failCreate = true
+	if response = send(http.MethodPost, "/api/secrets", body, nil); response.Code != http.StatusInternalServerError {
+		t.Errorf("failed create status = %d, want %d", response.Code, http.StatusInternalServerError)
+	}
+	leaky.failCreate = false
+	badIV := req
+	badIV.IV = base64.StdEncoding.EncodeToString([]byte(leakIV + "-too-long"))
+	send(http.MethodPost, "/api/secrets", marshalJSON(t, badIV), nil)
+	send(http.MethodPost, "/api/secrets", `{"ciphertext":`+leakCiphertext+`}`, nil)
+
+	// Dry runs, passing and failing
+	if response = send(http.MethodPost, "/api/secrets/validate", body, nil); response.Code != http.StatusOK {
+		t.Errorf("dry run status = %d, want %d", response.Code, http.StatusOK)
+	}
+	send(http.MethodPost, "/api/secrets/validate", marshalJSON(t, badIV), nil)
+	send(http.MethodPost, "/api/secrets/validate", `{"ciphertext":`+leakCiphertext+`}`, nil)
+	for _, kind := range []string{"request", "validated", "wrapped"} {
+		if response = send(http.MethodPost, "/panic/"+kind, body, nil); response.Code != http.StatusInternalServerError {
+			t.Errorf("panic %s status = %d, want %d", kind, response.Code, http.StatusInternalServerError)
+		}
+	}
+
+	spans := spanText(exporter.GetSpans())
+	if !strings.Contains(logs.String(), "panic recovered") || !strings.Contains(logs.String(), "failed to store secret") {
+		t.Fatalf("captured log lacks the failure paths:\n%s", logs)
+	}
+	for sink, output := range map[string]string{"log": logs.String(), "audit events": events.String(), "spans": spans, "error bodies": errorBodies.String()} {
+		if strings.Contains(output, leakCanaryBytes) {
+			t.Errorf("%s quote a payload as a byte slice", sink)
+		}
+		for _, secret := range secrets {
+			if form := leakedForm(output, secret); form != "" {
+				t.Errorf("%s contain %s", sink, form)
+			}
+		}
+	}
+}
+
+// panicWithRequest panics with the decoded create request, its validated
+// form or an error that formats it, as chi.URLParam kind selects
+func panicWithRequest(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateSecretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch chi.URLParam(r, "kind") {
+	case "request":
+		panic(req)
+	case "validated":
+		validated, err := validation.ValidateCreateRequest(req.Ciphertext, req.IV, req.Salt, req.ExpiresIn, policy.Default())
+		if err != nil {
+			panic(err)
+		}
+		panic(validated)
+	default:
+		panic(fmt.Errorf("create %+v: %#v", req, req))
+	}
+}
+
+// leakedForm names the encoding in which output contains secret, or
+// returns "" when it contains none
+func leakedForm(output, secret string) string {
+	forms := map[string]string{
+		"raw":        secret,
+		"base64":     base64.StdEncoding.EncodeToString([]byte(secret)),
+		"base64 url": base64.RawURLEncoding.EncodeToString([]byte(secret)),
+		"hex":        hex.EncodeToString([]byte(secret)),
+	}
+	for name, form := range forms {
+		if strings.Contains(strings.ToLower(output), strings.ToLower(form)) {
+			return fmt.Sprintf("the %s form of %q", name, secret)
+		}
+	}
+	return ""
+}
+
+// spanText renders every name, attribute, event and status in spans
+func spanText(spans tracetest.SpanStubs) string {
+	var text strings.Builder
+	for _, span := range spans {
+		fmt.Fprintf(&text, "%s %s\n", span.Name, span.Status.Description)
+		for _, attr := range span.Attributes {
+			fmt.Fprintf(&text, "  %s=%s\n", attr.Key, attr.Value.Emit())
+		}
+		for _, event := range span.Events {
+			fmt.Fprintf(&text, "  event %s\n", event.Name)
+			for _, attr := range event.Attributes {
+				fmt.Fprintf(&text, "    %s=%s\n", attr.Key, attr.Value.Emit())
+			}
+		}
+	}
+	return text.String()
+}
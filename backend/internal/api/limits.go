@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+
+	"ots-backend/internal/config"
+	httpMiddleware "ots-backend/internal/middleware"
+	"ots-backend/pkg/ots"
+)
+
+// RateLimitsResponse is the calling client's standing against every rate
+// limit, one entry per route class
+type RateLimitsResponse struct {
+	Limits []RateLimitClass `json:"limits"`
+}
+
+// RateLimitClass is one route class's limit as the X-RateLimit headers of
+// its responses report it. ResetSeconds is zero while nothing is counted.
+type RateLimitClass struct {
+	Class         string `json:"class"`
+	Limit         int    `json:"limit"`
+	Remaining     int    `json:"remaining"`
+	WindowSeconds int    `json:"window_seconds"`
+	ResetSeconds  int    `json:"reset_seconds"`
+}
+
+// fallbackRateLimitClass is the class whose standing unlimited routes report
+const fallbackRateLimitClass = "read"
+
+// rateLimits are the limiters of one router. Each route class counts its
+// requests apart from the others, so a class is one limiter.
+type rateLimits struct {
+	h       *Handler
+	classes []rateLimitClass
+}
+
+type rateLimitClass struct {
+	name    string
+	budget  func(*config.Config) (int, time.Duration)
+	limiter *httpMiddleware.RateLimiter
+}
+
+// limit limits requests per IP to a budget picked from the active
+// configuration, so a reload applies from the next request. With
+// RATE_LIMIT_ADAPTIVE on, each client's reputation scales the budget.
+// Refusals are written like any other service error.
+func (l *rateLimits) limit(class string, budget func(*config.Config) (int, time.Duration)) func(http.Handler) http.Handler {
+	h := l.h
+	limiter := httpMiddleware.NewRateLimiter(httpMiddleware.RateLimiterOptions{
+		Limit: func() (int, time.Duration) {
+			return budget(h.config())
+		},
+		Clock:    h.clock,
+		Adaptive: h.reputation,
+		Reject: func(w http.ResponseWriter, r *http.Request) {
+			h.respondServiceError(w, ots.ErrRateLimited)
+		},
+		PruneEvery: time.Minute,
+	})
+	l.classes = append(l.classes, rateLimitClass{name: class, budget: budget, limiter: limiter})
+	return limiter.Handler
+}
+
+// headers gives every response the X-RateLimit headers, including those of
+// routes no limiter covers and of unknown paths. They start as the caller's
+// standing in the read class, counting against nothing; a limited route's
+// own limiter then replaces them with its class's.
+func (l *rateLimits) headers(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, class := range l.classes {
+			if class.name == fallbackRateLimitClass {
+				httpMiddleware.SetRateLimitHeaders(w.Header(), class.limiter.Status(httpMiddleware.ClientIP(r)))
+				break
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RateLimits reports the caller's standing in every route class without
+// counting against any. Limits are kept per client IP, so a client only
+// ever sees its own counters.
+func (l *rateLimits) RateLimits(w http.ResponseWriter, r *http.Request) {
+	client := httpMiddleware.ClientIP(r)
+	resp := RateLimitsResponse{Limits: make([]RateLimitClass, 0, len(l.classes))}
+	for _, class := range l.classes {
+		_, window := class.budget(l.h.config())
+		status := class.limiter.Status(client)
+		resp.Limits = append(resp.Limits, RateLimitClass{
+			Class:         class.name,
+			Limit:         status.Limit,
+			Remaining:     status.Remaining,
+			WindowSeconds: int(math.Ceil(window.Seconds())),
+			ResetSeconds:  int(math.Ceil(status.Reset.Seconds())),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
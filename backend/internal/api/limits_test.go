@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/store/memory"
+	"ots-backend/internal/testutil"
+)
+
+func TestRateLimitHeadersAndLimitsEndpoint(t *testing.T) {
+	clk := testutil.NewFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	handler := NewHandler(memory.New(), &config.Config{
+		MaxSecretSize:          32768,
+		WriteRateLimitRequests: 5,
+		WriteRateLimitWindow:   time.Minute,
+		ReadRateLimitRequests:  3,
+		ReadRateLimitWindow:    time.Minute,
+		CreateNonceTTL:         time.Minute,
+	})
+	handler.SetClock(clk)
+	mux := chi.NewRouter()
+	mux.Mount("/api", handler.Routes())
+	router := withSpecValidation(t, handler, mux)
+
+	const client = "203.0.113.9:1234"
+	get := func(path string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		request.RemoteAddr = client
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response
+	}
+	limits := func() map[string]RateLimitClass {
+		t.Helper()
+		response := get("/api/limits")
+		if response.Code != http.StatusOK {
+			t.Fatalf("GET /api/limits status = %d, want %d", response.Code, http.StatusOK)
+		}
+		var body RateLimitsResponse
+		if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+			t.Fatalf("decode limits: %v", err)
+		}
+		classes := map[string]RateLimitClass{}
+		for _, class := range body.Limits {
+			classes[class.Class] = class
+		}
+		return classes
+	}
+
+	// Every response counts down, and reset tracks the oldest request
+	for i, want := range []struct{ remaining, reset string }{{"2", "60"}, {"1", "50"}, {"0", "40"}} {
+		if i > 0 {
+			clk.Advance(10 * time.Second)
+		}
+		response := get("/api/secrets/nonce")
+		if response.Code != http.StatusOK {
+			t.Fatalf("nonce %d status = %d, want %d", i, response.Code, http.StatusOK)
+		}
+		header := response.Header()
+		if header.Get("X-RateLimit-Limit") != "3" || header.Get("X-RateLimit-Remaining") != want.remaining || header.Get("X-RateLimit-Reset") != want.reset {
+			t.Errorf("nonce %d headers = limit %q, remaining %q, reset %q; want 3, %s, %s", i,
+				header.Get("X-RateLimit-Limit"), header.Get("X-RateLimit-Remaining"), header.Get("X-RateLimit-Reset"), want.remaining, want.reset)
+		}
+	}
+
+	// The endpoint reports the same standing and counts against nothing
+	for range 2 {
+		classes := limits()
+		if got := classes["nonce"]; got != (RateLimitClass{Class: "nonce", Limit: 3, Remaining: 0, WindowSeconds: 60, ResetSeconds: 40}) {
+			t.Errorf("nonce class = %+v, want spent with 40s to reset", got)
+		}
+		if got := classes["read"]; got != (RateLimitClass{Class: "read", Limit: 3, Remaining: 3, WindowSeconds: 60}) {
+			t.Errorf("read class = %+v, want untouched", got)
+		}
+		if got := classes["create"]; got.Limit != 5 || got.Remaining != 5 {
+			t.Errorf("create class = %+v, want 5 of 5", got)
+		}
+	}
+	if response := get("/api/secrets/nonce"); response.Code != http.StatusTooManyRequests {
+		t.Fatalf("nonce over the limit status = %d, want %d", response.Code, http.StatusTooManyRequests)
+	}
+
+	// Once the window passes the budget is whole again
+	clk.Advance(time.Minute)
+	if got := limits()["nonce"]; got.Remaining != 3 || got.ResetSeconds != 0 {
+		t.Errorf("nonce class after the window = %+v, want 3 remaining", got)
+	}
+	if got := get("/api/secrets/nonce").Header().Get("X-RateLimit-Remaining"); got != "2" {
+		t.Errorf("X-RateLimit-Remaining after the window = %q, want 2", got)
+	}
+}
+
+func TestCORSExposesRateLimitHeaders(t *testing.T) {
+	exposed := CORSOptions([]string{"https://example.com"}).ExposedHeaders
+	for _, name := range []string{"Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"} {
+		if !slices.Contains(exposed, name) {
+			t.Errorf("ExposedHeaders = %v, missing %s", exposed, name)
+		}
+	}
+}
+
+func TestRateLimitHeadersOnUnlimitedRoutes(t *testing.T) {
+	clk := testutil.NewFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	handler := NewHandler(memory.New(), &config.Config{
+		MaxSecretSize:          32768,
+		WriteRateLimitRequests: 5,
+		WriteRateLimitWindow:   time.Minute,
+		ReadRateLimitRequests:  3,
+		ReadRateLimitWindow:    time.Minute,
+		AdminToken:             "admin-token",
+	})
+	handler.SetClock(clk)
+	mux := chi.NewRouter()
+	mux.Mount("/api", handler.Routes())
+	router := withSpecValidation(t, handler, mux)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		request.RemoteAddr = "203.0.113.9:1234"
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response
+	}
+
+	// One read counts against the read class, which unlimited routes report
+	get("/api/secrets/00000000000000000000000000")
+	clk.Advance(10 * time.Second)
+	for range 2 {
+		for _, path := range []string{"/api/health/live", "/api/limits", "/api/admin/stats"} {
+			header := get(path).Header()
+			if header.Get("X-RateLimit-Limit") != "3" || header.Get("X-RateLimit-Remaining") != "2" || header.Get("X-RateLimit-Reset") != "50" {
+				t.Errorf("GET %s headers = limit %q, remaining %q, reset %q; want 3, 2, 50", path,
+					header.Get("X-RateLimit-Limit"), header.Get("X-RateLimit-Remaining"), header.Get("X-RateLimit-Reset"))
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/crypto"
+	"ots-backend/internal/logger"
+	"ots-backend/internal/models"
+	"ots-backend/pkg/ots"
+)
+
+// LinkTTL is how long a retrieval link token can be redeemed
+const LinkTTL = 5 * time.Minute
+
+// linkClockSkew is how much longer than a fresh token one may claim to
+// live, allowing for clock skew between instances
+const linkClockSkew = time.Minute
+
+// linkContext separates link MACs from anything else signed with the same
+// keys
+const linkContext = "ots-link\x00"
+
+// A link token is the base64url of an 8-byte big-endian expiry in Unix
+// seconds, the secret ID, then the keyring MAC over both
+const linkExpiryLen = 8
+
+// SetLinkKeyring replaces the per-process keyring that signs retrieval
+// links, so links issued by one replica redeem on another and survive a
+// restart; call it before Routes
+func (h *Handler) SetLinkKeyring(k *crypto.Keyring) {
+	h.linkKeys = k
+}
+
+// signLink returns a token redeeming secretID until expiresAt
+func (h *Handler) signLink(secretID string, expiresAt time.Time) string {
+	payload := binary.BigEndian.AppendUint64(make([]byte, 0, linkExpiryLen+len(secretID)+sha256.Size), uint64(expiresAt.Unix()))
+	payload = append(payload, secretID...)
+	return base64.RawURLEncoding.EncodeToString(append(payload, h.linkKeys.Sign(linkMessage(payload))...))
+}
+
+// openLink returns the secret ID of a token signed by signLink, and false
+// for a token that is garbled, tampered with or expired
+func (h *Handler) openLink(token string) (string, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) <= linkExpiryLen+sha256.Size {
+		return "", false
+	}
+	payload, mac := raw[:len(raw)-sha256.Size], raw[len(raw)-sha256.Size:]
+	if !h.linkKeys.Verify(linkMessage(payload), mac) {
+		return "", false
+	}
+
+	// The expiry is covered by the MAC, so it can be trusted once verified
+	now, expiresAt := h.clock.Now(), time.Unix(int64(binary.BigEndian.Uint64(payload)), 0)
+	if !now.Before(expiresAt) {
+		return "", false
+	}
+	// A token set to outlive one issued now predates a backward step of the
+	// wall clock; honouring it would stretch its life by the step
+	if expiresAt.Sub(now) > LinkTTL+linkClockSkew {
+		return "", false
+	}
+	return string(payload[linkExpiryLen:]), true
+}
+
+func linkMessage(payload []byte) []byte {
+	return append([]byte(linkContext), payload...)
+}
+
+// SecretLink issues a retrieval link token for a secret to its holder of
+// the management token. Redeeming it reads the secret like GET
+// /api/secrets/{id}, so it works once; it also stops working after LinkTTL.
+// The token is signed, not encrypted: it keeps the ID out of the URL, not
+// from someone who decodes it.
+func (h *Handler) SecretLink(w http.ResponseWriter, r *http.Request) {
+	secretID := chi.URLParam(r, "id")
+	if err := h.validateSecretID(secretID); err != nil {
+		h.respondServiceError(w, ots.ErrNotFound)
+		return
+	}
+	if h.respondIfForeign(w, secretID) {
+		return
+	}
+
+	authorized, err := h.checkManagementToken(r.Context(), secretID, r.Header.Get(ManagementTokenHeader))
+	if err != nil {
+		logger.Error("failed to check management token", "error", err, "secret_id", secretID)
+		h.respondStoreError(w, err, "database error")
+		return
+	}
+	if !authorized {
+		logger.Warn("link rejected without valid management token", "secret_id", secretID, "ip", r.RemoteAddr)
+		h.respondServiceError(w, ots.ErrManagementTokenRequired)
+		return
+	}
+
+	expiresAt := h.clock.Now().Add(LinkTTL).Truncate(time.Second).UTC()
+	token := h.signLink(secretID, expiresAt)
+
+	logger.Info("retrieval link issued", "secret_id", secretID, "expires_at", expiresAt)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.SecretLinkResponse{
+		Token:     token,
+		Path:      "/api/redeem/" + token,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// RedeemLink reads and destroys the secret a link token names. Garbled,
+// tampered, expired and spent tokens all get the 404 of an unknown secret.
+// A require_ack secret is destroyed on delivery rather than held: the
+// reader has no ID to acknowledge it with.
+func (h *Handler) RedeemLink(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	token := chi.URLParam(r, "token")
+
+	secretID, ok := h.openLink(token)
+	if !ok || h.validateSecretID(secretID) != nil {
+		logger.Warn("invalid retrieval link", "ip", r.RemoteAddr)
+		h.respondLookupMiss(w, r)
+		return
+	}
+	if h.respondIfForeign(w, secretID) || h.respondIfNotInteractive(w, r, token) {
+		return
+	}
+
+	h.consumeSecret(w, r, secretID, false, start)
+}
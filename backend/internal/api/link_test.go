@@ -0,0 +1,278 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/crypto"
+	"ots-backend/internal/models"
+	"ots-backend/internal/testutil"
+)
+
+// issueLink asks for a link to secretID under managementToken
+func issueLink(t *testing.T, router http.Handler, secretID, managementToken string) models.SecretLinkResponse {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodGet, "/api/secrets/"+secretID+"/link", nil)
+	request.Header.Set(ManagementTokenHeader, managementToken)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Fatalf("SecretLink() status = %d, want %d: %s", response.Code, http.StatusOK, response.Body)
+	}
+
+	var link models.SecretLinkResponse
+	if err := json.NewDecoder(response.Body).Decode(&link); err != nil {
+		t.Fatalf("SecretLink() decode error: %v", err)
+	}
+	return link
+}
+
+func redeem(router http.Handler, token string) *httptest.ResponseRecorder {
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/redeem/"+token, nil))
+	return response
+}
+
+func TestRedeemLinkReadsOnce(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouterWithConfig(t, b, withAckWindow, withTestClock(testutil.NewFakeClock(time.Now())))
+		req := getMockCreateSecretRequest(nil)
+		created := createTestSecretResponse(t, router, req)
+
+		link := issueLink(t, router, created.ID, created.ManagementToken)
+		if link.Path != "/api/redeem/"+link.Token {
+			t.Errorf("path = %q, want /api/redeem/ and the token", link.Path)
+		}
+
+		response := redeem(router, link.Token)
+		if response.Code != http.StatusOK {
+			t.Fatalf("RedeemLink() status = %d, want %d: %s", response.Code, http.StatusOK, response.Body)
+		}
+		var secret models.GetSecretResponse
+		if err := json.NewDecoder(response.Body).Decode(&secret); err != nil {
+			t.Fatalf("RedeemLink() decode error: %v", err)
+		}
+		if secret.Ciphertext != req.Ciphertext {
+			t.Errorf("ciphertext = %q, want %q", secret.Ciphertext, req.Ciphertext)
+		}
+
+		if response := redeem(router, link.Token); response.Code != http.StatusNotFound {
+			t.Errorf("second RedeemLink() status = %d, want %d", response.Code, http.StatusNotFound)
+		}
+		response = httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/"+created.ID, nil))
+		if response.Code != http.StatusNotFound {
+			t.Errorf("GetSecret() after redeem status = %d, want %d", response.Code, http.StatusNotFound)
+		}
+	})
+}
+
+func TestRedeemLinkOnceAcrossLinks(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouterWithConfig(t, b, withAckWindow, withTestClock(testutil.NewFakeClock(time.Now())))
+		created := createTestSecretResponse(t, router, getMockCreateSecretRequest(nil))
+
+		first := issueLink(t, router, created.ID, created.ManagementToken)
+		second := issueLink(t, router, created.ID, created.ManagementToken)
+
+		if response := redeem(router, first.Token); response.Code != http.StatusOK {
+			t.Fatalf("RedeemLink() status = %d, want %d", response.Code, http.StatusOK)
+		}
+		if response := redeem(router, second.Token); response.Code != http.StatusNotFound {
+			t.Errorf("RedeemLink() of another link status = %d, want %d", response.Code, http.StatusNotFound)
+		}
+	})
+}
+
+func TestRedeemLinkExpires(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		clk := testutil.NewFakeClock(time.Now())
+		router := newTestRouterWithConfig(t, b, withAckWindow, withTestClock(clk))
+		created := createTestSecretResponse(t, router, getMockCreateSecretRequest(nil))
+
+		link := issueLink(t, router, created.ID, created.ManagementToken)
+		if !link.ExpiresAt.After(clk.Now().Add(LinkTTL - time.Second)) {
+			t.Errorf("expires_at = %v, want about %v from now", link.ExpiresAt, LinkTTL)
+		}
+
+		clk.Step(LinkTTL)
+		if response := redeem(router, link.Token); response.Code != http.StatusNotFound {
+			t.Fatalf("RedeemLink() after expiry status = %d, want %d", response.Code, http.StatusNotFound)
+		}
+
+		// The expired link left the secret in place
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/"+created.ID, nil))
+		if response.Code != http.StatusOK {
+			t.Errorf("GetSecret() status = %d, want %d", response.Code, http.StatusOK)
+		}
+	})
+}
+
+func TestRedeemLinkRejectsFutureExpiry(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		clk := testutil.NewFakeClock(time.Now())
+		var handler *Handler
+		router := newTestRouterWithConfig(t, b, withAckWindow, withTestClock(clk), func(h *Handler) { handler = h })
+		created := createTestSecretResponse(t, router, getMockCreateSecretRequest(nil))
+
+		// A validly signed token living longer than any issued now, as
+		// after the wall clock stepped back
+		token := handler.signLink(created.ID, clk.Now().Add(LinkTTL+linkClockSkew+time.Minute))
+		if response := redeem(router, token); response.Code != http.StatusNotFound {
+			t.Errorf("RedeemLink() status = %d, want %d", response.Code, http.StatusNotFound)
+		}
+	})
+}
+
+func TestRedeemLinkRejectsTampering(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		clk := testutil.NewFakeClock(time.Now())
+		router := newTestRouterWithConfig(t, b, withAckWindow, withTestClock(clk))
+		created := createTestSecretResponse(t, router, getMockCreateSecretRequest(nil))
+		other := createTestSecretResponse(t, router, getMockCreateSecretRequest(nil))
+		link := issueLink(t, router, created.ID, created.ManagementToken)
+
+		raw, err := base64.RawURLEncoding.DecodeString(link.Token)
+		if err != nil {
+			t.Fatalf("token is not base64url: %v", err)
+		}
+		flip := func(i int) string {
+			tampered := append([]byte(nil), raw...)
+			tampered[i] ^= 1
+			return base64.RawURLEncoding.EncodeToString(tampered)
+		}
+		// The other secret's ID under this token's MAC
+		swapped := append(append(append([]byte(nil), raw[:linkExpiryLen]...), other.ID...), raw[len(raw)-32:]...)
+
+		foreignKeys, err := crypto.EphemeralKeyring()
+		if err != nil {
+			t.Fatalf("EphemeralKeyring() error: %v", err)
+		}
+		foreign := NewHandler(b.store, &config.Config{})
+		foreign.SetClock(clk)
+		foreign.SetLinkKeyring(foreignKeys)
+
+		unknown := redeem(router, base64.RawURLEncoding.EncodeToString([]byte("nothing")))
+		if unknown.Code != http.StatusNotFound {
+			t.Fatalf("RedeemLink() of garbage status = %d, want %d", unknown.Code, http.StatusNotFound)
+		}
+
+		tokens := map[string]string{
+			"expiry flipped":    flip(linkExpiryLen - 1),
+			"id flipped":        flip(linkExpiryLen),
+			"mac flipped":       flip(len(raw) - 1),
+			"id swapped":        base64.RawURLEncoding.EncodeToString(swapped),
+			"truncated":         link.Token[:len(link.Token)-4],
+			"not base64":        link.Token + "!",
+			"other server keys": foreign.signLink(created.ID, clk.Now().Add(LinkTTL)),
+		}
+		for name, token := range tokens {
+			response := redeem(router, token)
+			if response.Code != http.StatusNotFound {
+				t.Errorf("%s: RedeemLink() status = %d, want %d", name, response.Code, http.StatusNotFound)
+				continue
+			}
+			if response.Body.String() != unknown.Body.String() {
+				t.Errorf("%s: body = %s, want the same as garbage: %s", name, response.Body, unknown.Body)
+			}
+		}
+
+		// Nothing was consumed along the way
+		if response := redeem(router, link.Token); response.Code != http.StatusOK {
+			t.Errorf("RedeemLink() of the real token status = %d, want %d", response.Code, http.StatusOK)
+		}
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/"+other.ID, nil))
+		if response.Code != http.StatusOK {
+			t.Errorf("GetSecret() of the other secret status = %d, want %d", response.Code, http.StatusOK)
+		}
+	})
+}
+
+func TestSecretLinkRequiresManagementToken(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouterWithConfig(t, b, withAckWindow, withTestClock(testutil.NewFakeClock(time.Now())))
+		created := createTestSecretResponse(t, router, getMockCreateSecretRequest(nil))
+
+		for name, token := range map[string]string{"missing": "", "wrong": "not-the-token"} {
+			request := httptest.NewRequest(http.MethodGet, "/api/secrets/"+created.ID+"/link", nil)
+			if token != "" {
+				request.Header.Set(ManagementTokenHeader, token)
+			}
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, request)
+			if response.Code != http.StatusUnauthorized {
+				t.Errorf("%s token: SecretLink() status = %d, want %d", name, response.Code, http.StatusUnauthorized)
+			}
+		}
+	})
+}
+
+func TestRedeemLinkDestroysRequireAck(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouterWithConfig(t, b, withAckWindow, withTestClock(testutil.NewFakeClock(time.Now())))
+		req := getMockCreateSecretRequest(nil)
+		req.RequireAck = true
+		created := createTestSecretResponse(t, router, req)
+
+		response := redeem(router, issueLink(t, router, created.ID, created.ManagementToken).Token)
+		if response.Code != http.StatusOK {
+			t.Fatalf("RedeemLink() status = %d, want %d", response.Code, http.StatusOK)
+		}
+		var secret models.GetSecretResponse
+		if err := json.NewDecoder(response.Body).Decode(&secret); err != nil {
+			t.Fatalf("RedeemLink() decode error: %v", err)
+		}
+		if secret.AckToken != "" || secret.AckExpiresAt != nil {
+			t.Errorf("ack fields = %q, %v; want none", secret.AckToken, secret.AckExpiresAt)
+		}
+
+		// Not held: an ack has nothing to find
+		if response := postAck(router, created.ID, "anything"); response.Code != http.StatusNotFound {
+			t.Errorf("AcknowledgeSecret() status = %d, want %d", response.Code, http.StatusNotFound)
+		}
+	})
+}
+
+func TestRedeemLinkNotInteractive(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouterWithConfig(t, b, func(cfg *config.Config) {
+			withAckWindow(cfg)
+			cfg.RequireClientHeader = true
+		}, withTestClock(testutil.NewFakeClock(time.Now())))
+		created := createTestSecretResponse(t, router, getMockCreateSecretRequest(nil))
+		link := issueLink(t, router, created.ID, created.ManagementToken)
+
+		response := redeem(router, link.Token)
+		var metadata models.SecretMetadataResponse
+		if err := json.NewDecoder(response.Body).Decode(&metadata); err != nil {
+			t.Fatalf("RedeemLink() decode error: %v", err)
+		}
+		if metadata.ID != link.Token || metadata.ClientHeaderRequired == "" {
+			t.Errorf("metadata = %+v, want the token as id and the header named", metadata)
+		}
+
+		request := httptest.NewRequest(http.MethodGet, link.Path, nil)
+		request.Header.Set(ClientHeader, ClientInteractive)
+		response = httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		if response.Code != http.StatusOK {
+			t.Errorf("interactive RedeemLink() status = %d, want %d", response.Code, http.StatusOK)
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+
+	"ots-backend/internal/httpx"
+	"ots-backend/internal/i18n"
+	"ots-backend/internal/models"
+)
+
+// localeWriter carries the locale negotiated from Accept-Language to
+// respondErrorBody, which only sees the ResponseWriter
+type localeWriter struct {
+	http.ResponseWriter
+	locale string
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *localeWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// withLocale lets respondErrorBody translate error messages for requests
+// that prefer a language other than English
+func withLocale(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if locale := i18n.Negotiate(r.Header.Get("Accept-Language")); locale != i18n.Default {
+			w = &localeWriter{ResponseWriter: w, locale: locale}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// localize replaces the message of a coded error with its translation for
+// the request w answers. English keeps the server's own message, which
+// says more than the catalog's, as does any locale missing the code. The
+// code is never changed.
+func localize(w http.ResponseWriter, body *models.ErrorResponse) {
+	if body.Code == "" {
+		return
+	}
+	w.Header().Add("Vary", "Accept-Language")
+
+	lw, ok := findWriter[*localeWriter](w)
+	if !ok {
+		return
+	}
+	var params i18n.Params
+	if body.Limit != nil {
+		params = i18n.Params{Min: body.Limit.Min, Max: body.Limit.Max, Unit: body.Limit.Unit}
+	}
+	if message, ok := i18n.Message(lw.locale, body.Code, params); ok {
+		body.Message = message
+		httpx.SetHeader(w.Header(), "Content-Language", lw.locale)
+	}
+}
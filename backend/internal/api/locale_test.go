@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/i18n"
+	"ots-backend/internal/models"
+	"ots-backend/pkg/ots"
+)
+
+// localizedError sends req with an Accept-Language header and decodes the
+// error it gets back
+func localizedError(t *testing.T, router http.Handler, req *http.Request, acceptLanguage string) (*httptest.ResponseRecorder, models.ErrorResponse) {
+	t.Helper()
+
+	if acceptLanguage != "" {
+		req.Header.Set("Accept-Language", acceptLanguage)
+	}
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+
+	var body models.ErrorResponse
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		t.Fatalf("decode error response: %v", err)
+	}
+	return response, body
+}
+
+func TestLocalizedErrors(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouterWithConfig(t, b, func(cfg *config.Config) {
+			cfg.MaxSecretSize = 1024
+		})
+
+		tooLarge := getMockCreateSecretRequest(nil)
+		tooLarge.Ciphertext = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("x", 2048)))
+		create := func() *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/api/secrets", strings.NewReader(marshalJSON(t, tooLarge)))
+			req.Header.Set("Content-Type", "application/json")
+			return req
+		}
+
+		_, english := localizedError(t, router, create(), "")
+		if english.Code != "secret_too_large" {
+			t.Fatalf("code = %q, want secret_too_large", english.Code)
+		}
+
+		tests := []struct {
+			acceptLanguage string
+			wantMessage    string
+			wantLanguage   string
+		}{
+			{"en-US,en;q=0.9", english.Message, ""},
+			{"fr", "Le secret dépasse 1024 octets.", "fr"},
+			{"fr-CA,fr;q=0.9,en;q=0.8", "Le secret dépasse 1024 octets.", "fr"},
+			{"en;q=0.4, fr;q=0.7", "Le secret dépasse 1024 octets.", "fr"},
+			{"fr;q=0, en", english.Message, ""},
+			{"ar, de;q=0.9", english.Message, ""},
+		}
+		for _, tt := range tests {
+			response, body := localizedError(t, router, create(), tt.acceptLanguage)
+			if body.Code != "secret_too_large" {
+				t.Errorf("Accept-Language %q: code = %q, want secret_too_large whatever the locale", tt.acceptLanguage, body.Code)
+			}
+			if body.Message != tt.wantMessage {
+				t.Errorf("Accept-Language %q: message = %q, want %q", tt.acceptLanguage, body.Message, tt.wantMessage)
+			}
+			if got := response.Header().Get("Content-Language"); got != tt.wantLanguage {
+				t.Errorf("Accept-Language %q: Content-Language = %q, want %q", tt.acceptLanguage, got, tt.wantLanguage)
+			}
+			if got := response.Header().Get("Vary"); !strings.Contains(got, "Accept-Language") {
+				t.Errorf("Accept-Language %q: Vary = %q, want Accept-Language", tt.acceptLanguage, got)
+			}
+		}
+
+		// TTL bounds come from the active policy
+		badTTL := getMockCreateSecretRequest(nil)
+		badTTL.ExpiresIn = 1
+		req := httptest.NewRequest(http.MethodPost, "/api/secrets", strings.NewReader(marshalJSON(t, badTTL)))
+		req.Header.Set("Content-Type", "application/json")
+		_, body := localizedError(t, router, req, "fr-FR")
+		if body.Code != "invalid_ttl" || body.Limit == nil {
+			t.Fatalf("short TTL = %q limit %+v, want invalid_ttl with a limit", body.Code, body.Limit)
+		}
+		want := fmt.Sprintf("La durée de validité doit être comprise entre %d et %d secondes.", body.Limit.Min, body.Limit.Max)
+		if body.Message != want {
+			t.Errorf("short TTL message = %q, want %q", body.Message, want)
+		}
+
+		// Errors without parameters translate too
+		_, body = localizedError(t, router, httptest.NewRequest(http.MethodGet, "/api/secrets/"+strings.Repeat("A", 22), nil), "fr")
+		if body.Code != "not_found" || body.Message != "Ce secret n'existe pas, a expiré ou a déjà été lu." {
+			t.Errorf("unknown secret = %q %q, want not_found in French", body.Code, body.Message)
+		}
+	})
+}
+
+func TestCatalogCoversErrorCodes(t *testing.T) {
+	params := i18n.Params{Min: 1, Max: 2, Unit: "bytes"}
+	for _, m := range append(ots.Mappings, ots.Mapping{Code: ots.CodeInternal}) {
+		if _, ok := i18n.Message(i18n.Default, m.Code, params); !ok {
+			t.Errorf("%s catalog has no message for %s", i18n.Default, m.Code)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/models"
+	"ots-backend/internal/testutil"
+)
+
+func TestLookupMissFloodIsThrottled(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		const delay = 100 * time.Millisecond
+		clk := testutil.NewFakeClock(time.Now())
+		router := newTestRouterWithConfig(t, b, func(cfg *config.Config) {
+			cfg.LookupMissWindow = time.Minute
+			cfg.LookupMissDelayAfter = 5
+			cfg.LookupMissRejectAfter = 10
+			cfg.LookupMissDelay = delay
+		}, withTestClock(clk))
+
+		id := createTestSecret(t, router, getMockCreateSecretRequest(nil))
+		before := GetMetrics()
+
+		read := func(secretID string) (*httptest.ResponseRecorder, time.Duration) {
+			response := httptest.NewRecorder()
+			start := time.Now()
+			router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/"+secretID, nil))
+			return response, time.Since(start)
+		}
+		unknown := "AAAAAAAAAAAAAAAAAAAAAA"
+
+		// Below the delay threshold misses are answered immediately
+		for i := 0; i < 5; i++ {
+			response, took := read(unknown)
+			if response.Code != http.StatusNotFound {
+				t.Fatalf("miss %d status = %d, want 404", i+1, response.Code)
+			}
+			if took >= delay {
+				t.Fatalf("miss %d took %v, want no delay below the threshold", i+1, took)
+			}
+		}
+
+		// Past it misses are slowed down
+		for i := 5; i < 10; i++ {
+			response, took := read(unknown)
+			if response.Code != http.StatusNotFound {
+				t.Fatalf("miss %d status = %d, want 404", i+1, response.Code)
+			}
+			if took < delay {
+				t.Fatalf("miss %d took %v, want at least %v", i+1, took, delay)
+			}
+		}
+
+		if !GetMetrics().EnumerationDefenseActive {
+			t.Error("EnumerationDefenseActive = false after the flood, want true")
+		}
+
+		// Then refused
+		response, _ := read(unknown)
+		if response.Code != http.StatusTooManyRequests {
+			t.Fatalf("miss 11 status = %d, want 429", response.Code)
+		}
+		if response.Header().Get("Retry-After") == "" {
+			t.Error("Retry-After missing on throttled lookup")
+		}
+		var errResp models.ErrorResponse
+		if err := json.NewDecoder(response.Body).Decode(&errResp); err != nil {
+			t.Fatalf("decode error response: %v", err)
+		}
+		if errResp.Code != "lookup_throttled" {
+			t.Errorf("code = %q, want lookup_throttled", errResp.Code)
+		}
+
+		// A valid read is neither delayed nor refused while the defense is active
+		response, took := read(id)
+		if response.Code != http.StatusOK {
+			t.Fatalf("valid read during flood status = %d, want 200", response.Code)
+		}
+		if took >= delay {
+			t.Errorf("valid read during flood took %v, want no delay", took)
+		}
+
+		after := GetMetrics()
+		if got := after.LookupMissesDelayed - before.LookupMissesDelayed; got != 5 {
+			t.Errorf("delayed misses = %d, want 5", got)
+		}
+		if got := after.LookupMissesRejected - before.LookupMissesRejected; got != 1 {
+			t.Errorf("rejected misses = %d, want 1", got)
+		}
+		if got := after.EnumerationDefenseActivations - before.EnumerationDefenseActivations; got != 1 {
+			t.Errorf("activations = %d, want 1", got)
+		}
+
+		// Once the flood leaves the window misses are fast again
+		clk.Advance(2 * time.Minute)
+		response, took = read(unknown)
+		if response.Code != http.StatusNotFound || took >= delay {
+			t.Fatalf("miss after window = %d in %v, want fast 404", response.Code, took)
+		}
+		if GetMetrics().EnumerationDefenseActive {
+			t.Error("EnumerationDefenseActive = true after the window passed, want false")
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/httpx"
+	"ots-backend/internal/logger"
+	"ots-backend/internal/store"
+	"ots-backend/pkg/ots"
+)
+
+// routeMethods are the methods an Allow header may list besides OPTIONS
+var routeMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+// readBodyOverhead is what a read response adds to the base64 ciphertext:
+// field names, the IV and a salt
+const readBodyOverhead = 96
+
+// methodNotAllowed answers a method a path does not serve with 405 and an
+// Allow header naming those it does. Plain OPTIONS requests get the Allow
+// header alone; CORS preflights are answered before routing.
+func (h *Handler) methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	httpx.SetHeader(w.Header(), "Allow", strings.Join(allowedMethods(r), ", "))
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	h.respondServiceError(w, ots.ErrMethodNotAllowed)
+}
+
+// allowedMethods lists the methods the request's path is routed for
+func allowedMethods(r *http.Request) []string {
+	path := r.URL.RawPath
+	if path == "" {
+		path = r.URL.Path
+	}
+
+	var allowed []string
+	routes := chi.RouteContext(r.Context()).Routes
+	for _, method := range routeMethods {
+		if routes.Match(chi.NewRouteContext(), method, path) {
+			allowed = append(allowed, method)
+		}
+	}
+	return append(allowed, http.MethodOptions)
+}
+
+// HeadSecret answers HEAD on a secret with the status a read would get, an
+// estimate of its length and its hint, without consuming it. Link checkers
+// and chat previews probe links with HEAD.
+func (h *Handler) HeadSecret(w http.ResponseWriter, r *http.Request) {
+	secretID := chi.URLParam(r, "id")
+
+	if err := h.validateSecretID(secretID); err != nil {
+		h.respondLookupMiss(w, r)
+		return
+	}
+	if h.respondIfForeign(w, secretID) {
+		return
+	}
+
+	preview, err := h.store.Peek(r.Context(), secretID, h.clock.Now())
+	if err != nil {
+		var notYet *store.NotYetAvailableError
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondLookupMiss(w, r)
+		} else if errors.As(err, &notYet) {
+			h.respondNotYetAvailable(w, notYet)
+		} else {
+			logger.Error("failed to peek secret", "error", err, "secret_id", secretID)
+			h.respondStoreError(w, err, "database error")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(base64.StdEncoding.EncodedLen(int(preview.Size))+readBodyOverhead))
+	if preview.Hint != "" {
+		httpx.SetHeader(w.Header(), HintHeader, httpx.ExtValue(preview.Hint))
+	}
+	if preview.ContentType != "" {
+		httpx.SetHeader(w.Header(), ContentTypeHeader, preview.ContentType)
+	}
+	if preview.Filename != "" {
+		httpx.SetHeader(w.Header(), FilenameHeader, httpx.ExtValue(preview.Filename))
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/models"
+)
+
+func TestHeadSecretDoesNotConsume(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouter(t, b)
+		id := createTestSecret(t, router, getMockCreateSecretRequest(nil))
+
+		for range 2 {
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, httptest.NewRequest(http.MethodHead, "/api/secrets/"+id, nil))
+			if response.Code != http.StatusOK || response.Body.Len() != 0 {
+				t.Fatalf("HEAD status = %d with %d body bytes, want %d and none", response.Code, response.Body.Len(), http.StatusOK)
+			}
+			if length, err := strconv.Atoi(response.Header().Get("Content-Length")); err != nil || length <= 0 {
+				t.Errorf("HEAD Content-Length = %q, want an estimate", response.Header().Get("Content-Length"))
+			}
+		}
+
+		// The secret is still delivered, exactly once
+		if status := getSecretStatus(router, id); status != http.StatusOK {
+			t.Fatalf("GET after HEAD status = %d, want %d", status, http.StatusOK)
+		}
+		if status := getSecretStatus(router, id); status != http.StatusNotFound {
+			t.Errorf("second GET status = %d, want %d", status, http.StatusNotFound)
+		}
+
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodHead, "/api/secrets/"+id, nil))
+		if response.Code != http.StatusNotFound {
+			t.Errorf("HEAD after GET status = %d, want %d", response.Code, http.StatusNotFound)
+		}
+	})
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		// Methods a route does not have are not in the spec, so this router
+		// skips spec validation
+		router := chi.NewRouter()
+		router.Mount("/api", NewHandler(b.store, auditTestConfig()).Routes())
+
+		tests := []struct {
+			method, path string
+			status       int
+			allow        string
+		}{
+			{http.MethodPut, "/api/secrets/01ARZ3NDEKTSV4RRFFQ69G5FAV", http.StatusMethodNotAllowed, "GET, HEAD, DELETE, OPTIONS"},
+			{http.MethodPatch, "/api/secrets", http.StatusMethodNotAllowed, "POST, OPTIONS"},
+			{http.MethodDelete, "/api/config", http.StatusMethodNotAllowed, "GET, OPTIONS"},
+			{http.MethodOptions, "/api/secrets/01ARZ3NDEKTSV4RRFFQ69G5FAV", http.StatusNoContent, "GET, HEAD, DELETE, OPTIONS"},
+		}
+		for _, tt := range tests {
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if response.Code != tt.status || response.Header().Get("Allow") != tt.allow {
+				t.Errorf("%s %s = %d with Allow %q, want %d with %q", tt.method, tt.path, response.Code, response.Header().Get("Allow"), tt.status, tt.allow)
+				continue
+			}
+			if tt.status != http.StatusMethodNotAllowed {
+				continue
+			}
+			var body models.ErrorResponse
+			if err := json.NewDecoder(response.Body).Decode(&body); err != nil || body.Code != "method_not_allowed" {
+				t.Errorf("%s %s body = %+v, %v; want code method_not_allowed", tt.method, tt.path, body, err)
+			}
+		}
+	})
+}
@@ -1,109 +1,375 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"maps"
 	"net/http"
 	"runtime"
+	"slices"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"ots-backend/internal/dropped"
 	"ots-backend/internal/logger"
+	httpMiddleware "ots-backend/internal/middleware"
+	"ots-backend/internal/notify"
+	"ots-backend/internal/sizestats"
+	"ots-backend/internal/store"
+	"ots-backend/internal/terminate"
 )
 
-// MetricsCollector holds application metrics
+// MetricsCollector holds application metrics. Counters are atomic so that
+// recording never waits on a lock; mu guards only the maps and the values
+// recorded together, and GetMetrics takes it for its snapshot.
 type MetricsCollector struct {
 	mu sync.RWMutex
 
 	// Request metrics
-	RequestCount     int64
-	RequestErrors    int64
-	RequestDurations []time.Duration
+	RequestCount     atomic.Int64
+	RequestErrors    atomic.Int64
+	RequestDurations durationHistogram
 
 	// Secret metrics
-	SecretsCreated   int64
-	SecretsRetrieved int64
-	SecretsBurned    int64
-	SecretsActive    int64
+	SecretsCreated  atomic.Int64
+	SecretsReported atomic.Int64
+	SecretsActive   atomic.Int64
+	// Lookups of secrets created by another region
+	WrongRegion atomic.Int64
+	// Reads and burns answered with metadata only for lack of the client
+	// header
+	NonInteractive atomic.Int64
+
+	// Creates that did not declare their key length
+	UndeclaredKeyBits atomic.Int64
+
+	// Creates refused because the client held its quota of live secrets
+	QuotaRejections atomic.Int64
+
+	// Creates by size bucket upper bound; exact sizes are never kept
+	SizeBuckets map[int64]int64
+
+	// Health endpoint hits by alias path
+	HealthHits map[string]int64
+
+	// Failed secret lookups and the enumeration defense's response to them
+	LookupMisses                  atomic.Int64
+	LookupMissesDelayed           atomic.Int64
+	LookupMissesRejected          atomic.Int64
+	EnumerationDefenseActive      atomic.Bool
+	EnumerationDefenseActivations atomic.Int64
+
+	// Startup warm-up duration and whether it hit its deadline
+	WarmupDuration time.Duration
+	WarmupTimedOut bool
+
+	// Self-test canary runs and the step timings of the latest one
+	CanaryRuns     int64
+	CanaryFailures int64
+	CanaryCreate   time.Duration
+	CanaryConsume  time.Duration
+	CanaryVerify   time.Duration
 
 	// Start time for uptime calculation
 	startTime time.Time
 }
 
+// requestDurationBounds are the upper bounds of the request duration
+// buckets, those of a default Prometheus histogram
+var requestDurationBounds = [...]time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// requestDurationKeys names the buckets of requestDurationBounds in
+// milliseconds, as GetMetrics reports them
+var requestDurationKeys = func() []string {
+	keys := make([]string, 0, len(requestDurationBounds)+1)
+	for _, bound := range requestDurationBounds {
+		keys = append(keys, strconv.FormatFloat(durationMs(bound), 'f', -1, 64))
+	}
+	return append(keys, "+Inf")
+}()
+
+// durationHistogram counts durations per bucket, with their total for the
+// average. Each observation is a few atomic adds, so a snapshot taken
+// during one may be off by that observation.
+type durationHistogram struct {
+	// buckets[i] counts durations up to requestDurationBounds[i]; the last
+	// counts the longer ones
+	buckets [len(requestDurationBounds) + 1]atomic.Int64
+	count   atomic.Int64
+	sum     atomic.Int64
+}
+
+// observe counts d in its bucket
+func (h *durationHistogram) observe(d time.Duration) {
+	i, _ := slices.BinarySearch(requestDurationBounds[:], d)
+	h.buckets[i].Add(1)
+	h.sum.Add(int64(d))
+	h.count.Add(1)
+}
+
+// average returns the mean of the observed durations
+func (h *durationHistogram) average() time.Duration {
+	count := h.count.Load()
+	if count == 0 {
+		return 0
+	}
+	return time.Duration(h.sum.Load() / count)
+}
+
+// cumulative returns the counts cumulatively per upper bound in
+// milliseconds, with a "+Inf" total
+func (h *durationHistogram) cumulative() map[string]int64 {
+	histogram := make(map[string]int64, len(h.buckets))
+	var total int64
+	for i, key := range requestDurationKeys {
+		total += h.buckets[i].Load()
+		histogram[key] = total
+	}
+	return histogram
+}
+
 // Global metrics instance
 var metrics = &MetricsCollector{
-	startTime: time.Now(),
+	HealthHits:  make(map[string]int64),
+	SizeBuckets: make(map[int64]int64),
+	startTime:   time.Now(),
 }
 
-// MetricsResponse represents the Prometheus-compatible metrics response
+// MetricsResponse represents the Prometheus-compatible metrics response.
+// The prom tag of a map names the labels its keys become in the text
+// exposition.
 type MetricsResponse struct {
-	Uptime             string `json:"uptime"`
-	RequestCount       int64  `json:"request_count_total"`
-	RequestErrors      int64  `json:"request_errors_total"`
+	Uptime        string `json:"uptime"`
+	RequestCount  int64  `json:"request_count_total"`
+	RequestErrors int64  `json:"request_errors_total"`
+	// AvgRequestDuration averages every request since start
 	AvgRequestDuration string `json:"avg_request_duration_ms"`
-	SecretsCreated     int64  `json:"secrets_created_total"`
-	SecretsRetrieved   int64  `json:"secrets_retrieved_total"`
-	SecretsBurned      int64  `json:"secrets_burned_total"`
-	ActiveSecrets      int64  `json:"active_secrets"`
-	GoRoutines         int    `json:"go_routines"`
-	MemoryMB           uint64 `json:"memory_mb"`
+	// RequestDurations counts requests cumulatively per duration upper
+	// bound in milliseconds, with a "+Inf" total
+	RequestDurations map[string]int64 `json:"request_duration_ms_bucket" prom:"le"`
+	SecretsCreated   int64            `json:"secrets_created_total"`
+	SecretsRetrieved int64            `json:"secrets_retrieved_total"`
+	SecretsBurned    int64            `json:"secrets_burned_total"`
+	// SecretsExpired counts expiries swept by a cleanup worker in this
+	// process
+	SecretsExpired  int64  `json:"secrets_expired_total"`
+	SecretsReported int64  `json:"secrets_reported_total"`
+	WrongRegion     int64  `json:"wrong_region_total"`
+	NonInteractive  int64  `json:"non_interactive_total"`
+	ActiveSecrets   int64  `json:"active_secrets"`
+	GoRoutines      int    `json:"go_routines"`
+	MemoryMB        uint64 `json:"memory_mb"`
+
+	UndeclaredKeyBits int64 `json:"undeclared_key_bits_total"`
+	QuotaRejections   int64 `json:"quota_rejections_total"`
+
+	CORSRequests map[string]int64 `json:"cors_requests_total" prom:"outcome"`
+	HealthHits   map[string]int64 `json:"health_requests_total" prom:"alias"`
+	// DroppedWork counts async work given up on, keyed "kind/reason"
+	DroppedWork map[string]int64 `json:"dropped_work_total" prom:"kind,reason"`
+	// Notifications counts creator notices by channel and outcome, keyed
+	// "channel/result"
+	Notifications map[string]int64 `json:"notifications_total" prom:"channel,result"`
+	// SecretSizes counts creates cumulatively by size bucket upper bound,
+	// like the le series of a Prometheus histogram
+	SecretSizes map[string]int64 `json:"secret_size_bytes_bucket" prom:"le"`
+
+	LookupMisses                  int64 `json:"lookup_misses_total"`
+	LookupMissesDelayed           int64 `json:"lookup_misses_delayed_total"`
+	LookupMissesRejected          int64 `json:"lookup_misses_rejected_total"`
+	EnumerationDefenseActive      bool  `json:"enumeration_defense_active"`
+	EnumerationDefenseActivations int64 `json:"enumeration_defense_activations_total"`
+
+	WarmupDurationMs int64 `json:"warmup_duration_ms"`
+	WarmupTimedOut   bool  `json:"warmup_timed_out"`
+
+	CanaryRuns      int64   `json:"canary_runs_total"`
+	CanaryFailures  int64   `json:"canary_failures_total"`
+	CanaryCreateMs  float64 `json:"canary_create_ms"`
+	CanaryConsumeMs float64 `json:"canary_consume_ms"`
+	CanaryVerifyMs  float64 `json:"canary_verify_ms"`
+
+	// DBPool is the store's connection pool, absent for stores without one
+	DBPool *DBPoolMetrics `json:"db_pool,omitempty"`
+}
+
+// DBPoolMetrics is the store's connection pool as /api/metrics reports it.
+// A backend that does not track a value reports it as zero.
+type DBPoolMetrics struct {
+	AcquiredConns int64 `json:"acquired_conns"`
+	IdleConns     int64 `json:"idle_conns"`
+	TotalConns    int64 `json:"total_conns"`
+	MaxConns      int64 `json:"max_conns"`
+	// AcquireCount counts successful acquires and AcquireDurationMs their
+	// total wait
+	AcquireCount      int64   `json:"acquire_count_total"`
+	AcquireDurationMs float64 `json:"acquire_duration_ms_total"`
+	// CanceledAcquires counts acquires given up by their context, which is
+	// how an exhausted pool shows
+	CanceledAcquires int64 `json:"canceled_acquires_total"`
+	EmptyAcquires    int64 `json:"empty_acquires_total"`
+	NewConns         int64 `json:"new_conns_total"`
+}
+
+// dbPoolMetrics returns the pool statistics of s, nil when it has no pool
+func dbPoolMetrics(s store.Store) *DBPoolMetrics {
+	reporter, ok := s.(store.PoolReporter)
+	if !ok {
+		return nil
+	}
+	stats := reporter.PoolStats()
+	return &DBPoolMetrics{
+		AcquiredConns:     stats.AcquiredConns,
+		IdleConns:         stats.IdleConns,
+		TotalConns:        stats.TotalConns,
+		MaxConns:          stats.MaxConns,
+		AcquireCount:      stats.AcquireCount,
+		AcquireDurationMs: durationMs(stats.AcquireDuration),
+		CanceledAcquires:  stats.CanceledAcquireCount,
+		EmptyAcquires:     stats.EmptyAcquireCount,
+		NewConns:          stats.NewConnsCount,
+	}
 }
 
 // RecordRequest records a request
 func RecordRequest() {
-	metrics.mu.Lock()
-	defer metrics.mu.Unlock()
-	metrics.RequestCount++
+	metrics.RequestCount.Add(1)
 }
 
 // RecordRequestDuration records request duration
 func RecordRequestDuration(d time.Duration) {
-	metrics.mu.Lock()
-	defer metrics.mu.Unlock()
-	metrics.RequestDurations = append(metrics.RequestDurations, d)
-
-	// Keep only last 1000 measurements to prevent memory growth
-	if len(metrics.RequestDurations) > 1000 {
-		metrics.RequestDurations = metrics.RequestDurations[len(metrics.RequestDurations)-1000:]
-	}
+	metrics.RequestDurations.observe(d)
 }
 
 // RecordError records an error
 func RecordError() {
-	metrics.mu.Lock()
-	defer metrics.mu.Unlock()
-	metrics.RequestErrors++
+	metrics.RequestErrors.Add(1)
 }
 
 // RecordSecretCreated records a secret creation
 func RecordSecretCreated() {
+	metrics.SecretsCreated.Add(1)
+	metrics.SecretsActive.Add(1)
+}
+
+// RecordSecretReported records a compromise report for a known secret
+func RecordSecretReported() {
+	metrics.SecretsReported.Add(1)
+}
+
+// RecordWrongRegion records a request for another region's secret
+func RecordWrongRegion() {
+	metrics.WrongRegion.Add(1)
+}
+
+// RecordNonInteractive records a read or burn refused for lack of the
+// client header
+func RecordNonInteractive() {
+	metrics.NonInteractive.Add(1)
+}
+
+// RecordUndeclaredKeyBits records a create without declared_key_bits
+func RecordUndeclaredKeyBits() {
+	metrics.UndeclaredKeyBits.Add(1)
+}
+
+// RecordQuotaRejection records a create refused by the active-secret quota
+func RecordQuotaRejection() {
+	metrics.QuotaRejections.Add(1)
+}
+
+// RecordSecretSize records a create in the size bucket ending at upperBound
+func RecordSecretSize(upperBound int64) {
 	metrics.mu.Lock()
 	defer metrics.mu.Unlock()
-	metrics.SecretsCreated++
-	metrics.SecretsActive++
+	metrics.SizeBuckets[upperBound]++
+}
+
+// SecretSizeBuckets returns this process's create counts per size bucket,
+// smallest first
+func SecretSizeBuckets() []store.SizeBucket {
+	metrics.mu.RLock()
+	defer metrics.mu.RUnlock()
+
+	buckets := make([]store.SizeBucket, 0, len(metrics.SizeBuckets))
+	for _, bound := range slices.Sorted(maps.Keys(metrics.SizeBuckets)) {
+		buckets = append(buckets, store.SizeBucket{UpperBound: bound, Count: metrics.SizeBuckets[bound]})
+	}
+	return buckets
 }
 
-// RecordSecretRetrieved records a secret retrieval
-func RecordSecretRetrieved() {
+// RecordHealthHit records a request to a health endpoint alias
+func RecordHealthHit(alias string) {
 	metrics.mu.Lock()
 	defer metrics.mu.Unlock()
-	metrics.SecretsRetrieved++
+	metrics.HealthHits[alias]++
+}
+
+// RecordLookupMiss records a failed secret lookup and how it was answered
+func RecordLookupMiss(verdict httpMiddleware.MissVerdict) {
+	metrics.LookupMisses.Add(1)
+	switch verdict {
+	case httpMiddleware.MissDelay:
+		metrics.LookupMissesDelayed.Add(1)
+	case httpMiddleware.MissReject:
+		metrics.LookupMissesRejected.Add(1)
+	}
 }
 
-// RecordSecretBurned records a secret burn
-func RecordSecretBurned() {
+// SetEnumerationDefense records whether the enumeration defense is engaged
+// and reports whether that changed
+func SetEnumerationDefense(active bool) bool {
+	if !metrics.EnumerationDefenseActive.CompareAndSwap(!active, active) {
+		return false
+	}
+	if active {
+		metrics.EnumerationDefenseActivations.Add(1)
+	}
+	return true
+}
+
+// RecordWarmup records how long startup warm-up took
+func RecordWarmup(d time.Duration, timedOut bool) {
 	metrics.mu.Lock()
 	defer metrics.mu.Unlock()
-	metrics.SecretsBurned++
+	metrics.WarmupDuration = d
+	metrics.WarmupTimedOut = timedOut
 }
 
-// SetActiveSecrets sets the current number of active secrets
-func SetActiveSecrets(count int64) {
+// RecordCanaryRun records one canary run and its step timings
+func RecordCanaryRun(create, consume, verify time.Duration, ok bool) {
 	metrics.mu.Lock()
 	defer metrics.mu.Unlock()
-	metrics.SecretsActive = count
+	metrics.CanaryRuns++
+	if !ok {
+		metrics.CanaryFailures++
+	}
+	metrics.CanaryCreate = create
+	metrics.CanaryConsume = consume
+	metrics.CanaryVerify = verify
+}
+
+// SetActiveSecrets sets the current number of active secrets
+func SetActiveSecrets(count int64) {
+	metrics.SecretsActive.Store(count)
 }
 
-// GetMetrics returns current metrics snapshot
+// GetMetrics returns current metrics snapshot. Counters are read one by one
+// while requests go on, so they may disagree by the requests in flight.
 func GetMetrics() MetricsResponse {
 	metrics.mu.RLock()
 	defer metrics.mu.RUnlock()
@@ -111,44 +377,87 @@ func GetMetrics() MetricsResponse {
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
 
-	// Calculate average request duration
-	var avgDuration time.Duration
-	if len(metrics.RequestDurations) > 0 {
-		var total time.Duration
-		for _, d := range metrics.RequestDurations {
-			total += d
-		}
-		avgDuration = total / time.Duration(len(metrics.RequestDurations))
+	healthHits := make(map[string]int64, len(metrics.HealthHits))
+	for alias, count := range metrics.HealthHits {
+		healthHits[alias] = count
 	}
 
 	return MetricsResponse{
-		Uptime:             time.Since(metrics.startTime).String(),
-		RequestCount:       metrics.RequestCount,
-		RequestErrors:      metrics.RequestErrors,
-		AvgRequestDuration: avgDuration.String(),
-		SecretsCreated:     metrics.SecretsCreated,
-		SecretsRetrieved:   metrics.SecretsRetrieved,
-		SecretsBurned:      metrics.SecretsBurned,
-		ActiveSecrets:      metrics.SecretsActive,
-		GoRoutines:         runtime.NumGoroutine(),
-		MemoryMB:           m.Alloc / 1024 / 1024,
+		Uptime:                        time.Since(metrics.startTime).String(),
+		RequestCount:                  metrics.RequestCount.Load(),
+		RequestErrors:                 metrics.RequestErrors.Load(),
+		AvgRequestDuration:            metrics.RequestDurations.average().String(),
+		SecretsCreated:                metrics.SecretsCreated.Load(),
+		SecretsRetrieved:              terminate.Count(store.TerminationConsumed),
+		SecretsBurned:                 terminate.Count(store.TerminationBurned),
+		SecretsExpired:                terminate.Count(store.TerminationExpired),
+		SecretsReported:               metrics.SecretsReported.Load(),
+		WrongRegion:                   metrics.WrongRegion.Load(),
+		NonInteractive:                metrics.NonInteractive.Load(),
+		ActiveSecrets:                 metrics.SecretsActive.Load(),
+		GoRoutines:                    runtime.NumGoroutine(),
+		MemoryMB:                      m.Alloc / 1024 / 1024,
+		UndeclaredKeyBits:             metrics.UndeclaredKeyBits.Load(),
+		QuotaRejections:               metrics.QuotaRejections.Load(),
+		CORSRequests:                  httpMiddleware.CORSOutcomes(),
+		RequestDurations:              metrics.RequestDurations.cumulative(),
+		HealthHits:                    healthHits,
+		DroppedWork:                   dropped.Counts(),
+		Notifications:                 notify.Counts(),
+		LookupMisses:                  metrics.LookupMisses.Load(),
+		LookupMissesDelayed:           metrics.LookupMissesDelayed.Load(),
+		LookupMissesRejected:          metrics.LookupMissesRejected.Load(),
+		EnumerationDefenseActive:      metrics.EnumerationDefenseActive.Load(),
+		EnumerationDefenseActivations: metrics.EnumerationDefenseActivations.Load(),
+		WarmupDurationMs:              metrics.WarmupDuration.Milliseconds(),
+		WarmupTimedOut:                metrics.WarmupTimedOut,
+		CanaryRuns:                    metrics.CanaryRuns,
+		CanaryFailures:                metrics.CanaryFailures,
+		CanaryCreateMs:                durationMs(metrics.CanaryCreate),
+		CanaryConsumeMs:               durationMs(metrics.CanaryConsume),
+		CanaryVerifyMs:                durationMs(metrics.CanaryVerify),
 	}
 }
 
-// MetricsHandler handles metrics requests
-func (h *Handler) MetricsHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+// activeCountTTL is how long a counted active secrets total is served
+// before the metrics endpoint counts again
+const activeCountTTL = 30 * time.Second
+
+// refreshActiveCount recounts active secrets at most once per
+// activeCountTTL. The count scans the whole table, so scrapes must not run
+// it on every request and compete with user traffic for connections.
+func (h *Handler) refreshActiveCount(ctx context.Context) {
+	h.activeCountMu.Lock()
+	defer h.activeCountMu.Unlock()
+
+	now := h.clock.Now()
+	if !h.activeCountAt.IsZero() && now.Sub(h.activeCountAt) < activeCountTTL {
+		return
+	}
 
-	// Update active secrets count from database
-	var activeCount int64
-	err := h.db.QueryRow(ctx, "SELECT COUNT(*) FROM secrets").Scan(&activeCount)
+	activeCount, err := h.store.CountActive(ctx)
 	if err != nil {
 		logger.Error("metrics: failed to get active secrets count", "error", err)
-	} else {
-		SetActiveSecrets(activeCount)
+		return
 	}
+	SetActiveSecrets(activeCount)
+	h.activeCountAt = now
+}
+
+// MetricsHandler handles metrics requests
+func (h *Handler) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	h.refreshActiveCount(r.Context())
 
 	resp := GetMetrics()
+	resp.SecretSizes = sizestats.Histogram(h.sizeNoise().Buckets(SecretSizeBuckets()))
+	resp.DBPool = dbPoolMetrics(h.store)
+
+	if wantsPrometheus(r) {
+		w.Header().Set("Content-Type", prometheusContentType)
+		w.WriteHeader(http.StatusOK)
+		writePrometheus(w, resp)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -184,3 +493,14 @@ func (rr *responseRecorder) WriteHeader(code int) {
 	rr.statusCode = code
 	rr.ResponseWriter.WriteHeader(code)
 }
+
+// Unwrap exposes the underlying writer to http.ResponseController, which
+// event streams use to flush
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
+
+// durationMs renders d in fractional milliseconds
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,234 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/store"
+	"ots-backend/internal/store/memory"
+	"ots-backend/internal/testutil"
+)
+
+// countingStore counts CountActive calls
+type countingStore struct {
+	store.Store
+	counts atomic.Int64
+}
+
+func (s *countingStore) CountActive(ctx context.Context) (int64, error) {
+	s.counts.Add(1)
+	return s.Store.CountActive(ctx)
+}
+
+func TestMetricsCachesActiveCount(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		st := &countingStore{Store: b.store}
+		clk := testutil.NewFakeClock(time.Now())
+
+		handler := NewHandler(st, &config.Config{})
+		handler.SetClock(clk)
+		router := chi.NewRouter()
+		router.Mount("/api", handler.Routes())
+
+		scrape := func() {
+			t.Helper()
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/metrics", nil))
+			if response.Code != http.StatusOK {
+				t.Fatalf("metrics status = %d, want %d", response.Code, http.StatusOK)
+			}
+		}
+
+		scrape()
+		scrape()
+		clk.Advance(activeCountTTL - time.Second)
+		scrape()
+		if n := st.counts.Load(); n != 1 {
+			t.Errorf("CountActive() calls within the cache window = %d, want 1", n)
+		}
+
+		clk.Advance(time.Second)
+		scrape()
+		if n := st.counts.Load(); n != 2 {
+			t.Errorf("CountActive() calls after the cache window = %d, want 2", n)
+		}
+	})
+}
+
+func TestMetricsReportPoolAndPrometheus(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		handler := NewHandler(b.store, &config.Config{})
+		mux := chi.NewRouter()
+		mux.Mount("/api", handler.Routes())
+		router := withSpecValidation(t, handler, mux)
+		_, pooled := b.store.(store.PoolReporter)
+
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/metrics", nil))
+		var metrics MetricsResponse
+		if err := json.NewDecoder(response.Body).Decode(&metrics); err != nil {
+			t.Fatalf("decode metrics: %v", err)
+		}
+		if (metrics.DBPool != nil) != pooled {
+			t.Fatalf("db_pool = %+v, want it only for pooled stores (%v)", metrics.DBPool, pooled)
+		}
+
+		request := httptest.NewRequest(http.MethodGet, "/api/metrics", nil)
+		request.Header.Set("Accept", "application/openmetrics-text;version=1.0.0;q=0.75,text/plain;version=0.0.4;q=0.5,*/*;q=0.1")
+		response = httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		if got := response.Header().Get("Content-Type"); got != prometheusContentType {
+			t.Fatalf("Content-Type = %q, want %q", got, prometheusContentType)
+		}
+		body := response.Body.String()
+		for _, want := range []string{
+			"# TYPE ots_request_count_total counter\n",
+			"# TYPE ots_active_secrets gauge\nots_active_secrets 0\n",
+			"ots_enumeration_defense_active 0\n",
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("exposition lacks %q:\n%s", want, body)
+			}
+		}
+		if strings.Contains(body, "uptime") {
+			t.Errorf("exposition holds the uptime string:\n%s", body)
+		}
+		if got := strings.Contains(body, "\nots_db_pool_max_conns "); got != pooled {
+			t.Errorf("exposition has pool series = %v, want %v", got, pooled)
+		}
+	})
+}
+
+// TestMetricsCountRequestsThroughStack serves requests through the
+// server's middleware chain and checks the request counters and duration
+// histogram move, in JSON and in the text exposition
+func TestMetricsCountRequestsThroughStack(t *testing.T) {
+	stack := testutil.NewStack(testutil.NewFakeClock(time.Now()), testutil.Limit{Requests: 1000, Window: time.Minute}, MetricsMiddleware)
+	handler := NewHandler(memory.New(), &config.Config{ReadRateLimitRequests: 1000, ReadRateLimitWindow: time.Minute})
+	stack.Router.Mount("/api", handler.Routes())
+
+	scrape := func() MetricsResponse {
+		t.Helper()
+		response := stack.Do(http.MethodGet, "/api/metrics", "192.0.2.1:1234")
+		defer response.Body.Close()
+		var m MetricsResponse
+		if err := json.NewDecoder(response.Body).Decode(&m); err != nil {
+			t.Fatalf("decode metrics: %v", err)
+		}
+		return m
+	}
+
+	before := scrape()
+	for range 3 {
+		response := stack.Do(http.MethodGet, "/api/secrets/AAAAAAAAAAAAAAAAAAAAAA", "192.0.2.1:1234")
+		response.Body.Close()
+		if response.StatusCode != http.StatusNotFound {
+			t.Fatalf("GET unknown secret status = %d, want %d", response.StatusCode, http.StatusNotFound)
+		}
+	}
+	after := scrape()
+
+	// The first scrape counts too, once it has been served
+	if n := after.RequestCount - before.RequestCount; n < 4 {
+		t.Errorf("request_count_total grew by %d, want at least 4", n)
+	}
+	if n := after.RequestErrors - before.RequestErrors; n < 3 {
+		t.Errorf("request_errors_total grew by %d, want at least 3", n)
+	}
+	if n := after.RequestDurations["+Inf"] - before.RequestDurations["+Inf"]; n < 4 {
+		t.Errorf("request_duration_ms_bucket{+Inf} grew by %d, want at least 4", n)
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/api/metrics", nil)
+	request.Header.Set("Accept", "text/plain")
+	response := httptest.NewRecorder()
+	stack.Router.ServeHTTP(response, request)
+	body := response.Body.String()
+	for _, series := range []string{"ots_request_count_total ", `ots_request_duration_ms_bucket{le="+Inf"} `} {
+		_, value, ok := strings.Cut(body, "\n"+series)
+		value, _, _ = strings.Cut(value, "\n")
+		if !ok || value == "0" {
+			t.Errorf("exposition %s= %q, want a non-zero count:\n%s", series, value, body)
+		}
+	}
+}
+
+func TestPrometheusLabels(t *testing.T) {
+	for _, tt := range []struct {
+		names []string
+		key   string
+		want  string
+	}{
+		{names: []string{"le"}, key: "+Inf", want: `{le="+Inf"}`},
+		{names: []string{"kind", "reason"}, key: "notification/queue_full", want: `{kind="notification",reason="queue_full"}`},
+		{names: []string{"kind", "reason"}, key: "bare", want: `{kind="bare",reason=""}`},
+		{names: []string{"alias"}, key: "a\"b/c", want: `{alias="a\"b/c"}`},
+	} {
+		if got := prometheusLabels(tt.names, tt.key); got != tt.want {
+			t.Errorf("prometheusLabels(%q, %q) = %s, want %s", tt.names, tt.key, got, tt.want)
+		}
+	}
+}
+
+// metricsOK answers every request the metrics middleware wraps in the
+// benchmarks below
+var metricsOK = MetricsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}))
+
+// BenchmarkMetricsMiddleware measures the per-request metrics cost from one
+// goroutine
+func BenchmarkMetricsMiddleware(b *testing.B) {
+	req := httptest.NewRequest(http.MethodGet, "/api/health", nil)
+	rec := httptest.NewRecorder()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		metricsOK.ServeHTTP(rec, req)
+	}
+}
+
+// BenchmarkMetricsMiddlewareParallel records requests from every P at once;
+// run with -cpu to see how the cost grows with contention
+func BenchmarkMetricsMiddlewareParallel(b *testing.B) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		req := httptest.NewRequest(http.MethodGet, "/api/health", nil)
+		rec := httptest.NewRecorder()
+		for pb.Next() {
+			metricsOK.ServeHTTP(rec, req)
+		}
+	})
+}
+
+func TestDurationHistogram(t *testing.T) {
+	var h durationHistogram
+	if got := h.average(); got != 0 {
+		t.Errorf("average() of no durations = %v, want 0", got)
+	}
+
+	for _, d := range []time.Duration{time.Millisecond, 5 * time.Millisecond, 40 * time.Millisecond, 30 * time.Second} {
+		h.observe(d)
+	}
+
+	got := h.cumulative()
+	want := map[string]int64{"5": 2, "10": 2, "25": 2, "50": 3, "100": 3, "250": 3, "500": 3, "1000": 3, "2500": 3, "5000": 3, "10000": 3, "+Inf": 4}
+	if !maps.Equal(got, want) {
+		t.Errorf("cumulative() = %v, want %v", got, want)
+	}
+	if got, want := h.average(), (46*time.Millisecond+30*time.Second)/4; got != want {
+		t.Errorf("average() = %v, want %v", got, want)
+	}
+}
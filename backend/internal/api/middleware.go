@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"ots-backend/internal/logger"
+	httpMiddleware "ots-backend/internal/middleware"
 )
 
 // LoggingMiddleware logs all HTTP requests
@@ -27,7 +28,7 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 			"path", r.URL.Path,
 			"status", recorder.statusCode,
 			"duration_ms", duration.Milliseconds(),
-			"ip", getClientIP(r),
+			"ip", httpMiddleware.ClientIP(r),
 			"user_agent", r.UserAgent(),
 		)
 	})
@@ -46,7 +47,7 @@ func RecoveryMiddleware(next http.Handler) http.Handler {
 
 				w.Header().Set("Content-Type", "application/json")
 				w.WriteHeader(http.StatusInternalServerError)
-				w.Write([]byte(`{"error":"Internal Server Error","message":"an unexpected error occurred"}`))
+				w.Write([]byte(`{"error":"Internal Server Error","message":"an unexpected error occurred","retryable":false}`))
 			}
 		}()
 
@@ -54,32 +55,6 @@ func RecoveryMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// SecurityHeadersMiddleware adds security headers to all responses
-func SecurityHeadersMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Add security headers
-		w.Header().Set("X-Content-Type-Options", "nosniff")
-		w.Header().Set("X-Frame-Options", "DENY")
-		w.Header().Set("X-XSS-Protection", "1; mode=block")
-		w.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
-
-		next.ServeHTTP(w, r)
-	})
-}
-
-// getClientIP extracts the client IP from the request
-func getClientIP(r *http.Request) string {
-	// Check for forwarded IP (if behind proxy)
-	ip := r.Header.Get("X-Forwarded-For")
-	if ip == "" {
-		ip = r.Header.Get("X-Real-Ip")
-	}
-	if ip == "" {
-		ip = r.RemoteAddr
-	}
-	return ip
-}
-
 // ChainMiddleware chains multiple middleware functions
 func ChainMiddleware(middleware ...func(http.Handler) http.Handler) func(http.Handler) http.Handler {
 	return func(final http.Handler) http.Handler {
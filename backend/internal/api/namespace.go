@@ -0,0 +1,189 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/logger"
+	"ots-backend/internal/store"
+	"ots-backend/internal/validation"
+	"ots-backend/pkg/ots"
+)
+
+// NamespaceStatsResponse summarizes one namespace's live secrets
+type NamespaceStatsResponse struct {
+	Namespace  string `json:"namespace"`
+	Count      int64  `json:"count"`
+	TotalBytes int64  `json:"total_bytes"`
+	// OldestExpiry is the soonest expiry; nil when the namespace is empty
+	OldestExpiry *time.Time `json:"oldest_expiry"`
+}
+
+// NamespacePurgeResponse reports how many secrets a purge removed
+type NamespacePurgeResponse struct {
+	Namespace string `json:"namespace"`
+	Deleted   int64  `json:"deleted"`
+}
+
+// NamespaceStats reports the count, stored bytes and soonest expiry of a
+// namespace's live secrets
+func (h *Handler) NamespaceStats(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "ns")
+	if err := validation.ValidateNamespace(namespace); err != nil {
+		h.respondServiceError(w, err)
+		return
+	}
+
+	stats, err := h.store.NamespaceStats(r.Context(), namespace, h.clock.Now())
+	if err != nil {
+		logger.Error("namespace stats: query failed", "error", err, "namespace", namespace)
+		h.respondStoreError(w, err, "database error")
+		return
+	}
+
+	resp := NamespaceStatsResponse{
+		Namespace:  namespace,
+		Count:      stats.Count,
+		TotalBytes: stats.TotalBytes,
+	}
+	if !stats.OldestExpiry.IsZero() {
+		oldest := stats.OldestExpiry.UTC()
+		resp.OldestExpiry = &oldest
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// PurgeNamespace destroys every stored secret in a namespace. Links to them
+// read as not found afterwards, like burned secrets.
+func (h *Handler) PurgeNamespace(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "ns")
+	if err := validation.ValidateNamespace(namespace); err != nil {
+		h.respondServiceError(w, err)
+		return
+	}
+
+	deleted, err := h.store.PurgeNamespace(r.Context(), namespace)
+	if err != nil {
+		logger.Error("namespace purge failed", "error", err, "namespace", namespace)
+		h.respondStoreError(w, err, "database error")
+		return
+	}
+
+	logger.Info("namespace purged", "namespace", namespace, "deleted", deleted, "actor", h.config().AdminTokenLabel)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(NamespacePurgeResponse{Namespace: namespace, Deleted: deleted})
+}
+
+// NamespaceDeletionResponse is the progress of a namespace deletion
+type NamespaceDeletionResponse struct {
+	Namespace string `json:"namespace"`
+	// State is "deleting" until nothing references the namespace, then
+	// "deleted"
+	State                 string     `json:"state"`
+	StartedAt             time.Time  `json:"started_at"`
+	FinishedAt            *time.Time `json:"finished_at"`
+	SecretsDeleted        int64      `json:"secrets_deleted"`
+	LiveSecretsDeleted    int64      `json:"live_secrets_deleted"`
+	ReceiptsDeleted       int64      `json:"receipts_deleted"`
+	AuditEventsAnonymized int64      `json:"audit_events_anonymized"`
+}
+
+// newNamespaceDeletionResponse renders a store's deletion progress
+func newNamespaceDeletionResponse(deletion *store.NamespaceDeletion) NamespaceDeletionResponse {
+	resp := NamespaceDeletionResponse{
+		Namespace:             deletion.Namespace,
+		State:                 "deleting",
+		StartedAt:             deletion.StartedAt.UTC(),
+		SecretsDeleted:        deletion.SecretsDeleted,
+		LiveSecretsDeleted:    deletion.LiveSecretsDeleted,
+		ReceiptsDeleted:       deletion.ReceiptsDeleted,
+		AuditEventsAnonymized: deletion.AuditEventsAnonymized,
+	}
+	if deletion.Finished() {
+		finished := deletion.FinishedAt.UTC()
+		resp.State = "deleted"
+		resp.FinishedAt = &finished
+	}
+	return resp
+}
+
+// DeleteNamespace marks a namespace deleted, refusing creates into it, and
+// starts removing what references it in the background. Repeating it
+// resumes an unfinished deletion.
+func (h *Handler) DeleteNamespace(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "ns")
+	if err := validation.ValidateNamespace(namespace); err != nil {
+		h.respondServiceError(w, err)
+		return
+	}
+
+	deletion, err := h.store.StartNamespaceDeletion(r.Context(), namespace, h.clock.Now())
+	if err != nil {
+		logger.Error("namespace deletion: start failed", "error", err, "namespace", namespace)
+		h.respondStoreError(w, err, "database error")
+		return
+	}
+
+	status := http.StatusOK
+	if !deletion.Finished() {
+		status = http.StatusAccepted
+		logger.Info("namespace deletion started", "namespace", namespace, "actor", h.config().AdminTokenLabel)
+		h.runNamespaceDeletion(namespace)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(newNamespaceDeletionResponse(deletion))
+}
+
+// NamespaceDeletion reports how far a namespace deletion has got
+func (h *Handler) NamespaceDeletion(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "ns")
+	if err := validation.ValidateNamespace(namespace); err != nil {
+		h.respondServiceError(w, err)
+		return
+	}
+
+	deletion, err := h.store.NamespaceDeletion(r.Context(), namespace)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondServiceError(w, ots.ErrNotFound)
+		return
+	}
+	if err != nil {
+		logger.Error("namespace deletion: query failed", "error", err, "namespace", namespace)
+		h.respondStoreError(w, err, "database error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newNamespaceDeletionResponse(deletion))
+}
+
+// runNamespaceDeletion runs the deletion job for namespace unless this
+// process already does. A job cut short by a restart is finished by the
+// cleanup worker or the next DELETE.
+func (h *Handler) runNamespaceDeletion(namespace string) {
+	if _, running := h.deleting.LoadOrStore(namespace, struct{}{}); running {
+		return
+	}
+
+	go func() {
+		defer h.deleting.Delete(namespace)
+
+		deletion, err := store.DeleteNamespace(context.Background(), h.store, namespace, store.NamespaceDeletionBatch, h.clock.Now)
+		if err != nil {
+			logger.Error("namespace deletion failed", "error", err, "namespace", namespace)
+			return
+		}
+		logger.Info("namespace deleted", "namespace", namespace,
+			"secrets", deletion.SecretsDeleted, "receipts", deletion.ReceiptsDeleted, "audit_events", deletion.AuditEventsAnonymized)
+	}()
+}
@@ -0,0 +1,293 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/models"
+	"ots-backend/internal/store"
+)
+
+// newNamespaceTestRouter audits events and serves the admin routes
+func newNamespaceTestRouter(t *testing.T, b *testBackend) http.Handler {
+	t.Helper()
+
+	handler := NewHandler(b.store, auditTestConfig())
+	router := chi.NewRouter()
+	router.Mount("/api", handler.Routes())
+	return withSpecValidation(t, handler, router)
+}
+
+func adminRequest(router http.Handler, method, path string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, nil)
+	request.Header.Set("Authorization", "Bearer "+auditTestToken)
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
+
+func createInNamespace(t *testing.T, router http.Handler, namespace string) string {
+	t.Helper()
+
+	req := getMockCreateSecretRequest(nil)
+	req.Namespace = namespace
+	return createTestSecret(t, router, req)
+}
+
+func getNamespaceStats(t *testing.T, router http.Handler, namespace string) NamespaceStatsResponse {
+	t.Helper()
+
+	response := adminRequest(router, http.MethodGet, "/api/admin/namespaces/"+namespace+"/stats")
+	if response.Code != http.StatusOK {
+		t.Fatalf("namespace stats status = %d, want %d: %s", response.Code, http.StatusOK, response.Body)
+	}
+	var stats NamespaceStatsResponse
+	if err := json.NewDecoder(response.Body).Decode(&stats); err != nil {
+		t.Fatalf("decode namespace stats: %v", err)
+	}
+	return stats
+}
+
+func TestNamespaceStatsAndAudit(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newNamespaceTestRouter(t, b)
+
+		first := createInNamespace(t, router, "team-a")
+		createInNamespace(t, router, "team-a")
+		createInNamespace(t, router, "team-b")
+		createTestSecret(t, router, getMockCreateSecretRequest(nil))
+
+		stats := getNamespaceStats(t, router, "team-a")
+		size := int64(len("test secret data"))
+		if stats.Count != 2 || stats.TotalBytes != 2*size || stats.OldestExpiry == nil {
+			t.Errorf("namespace stats = %+v, want 2 secrets of %d bytes with an expiry", stats, 2*size)
+		}
+
+		// Readers never learn the namespace
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/"+first, nil))
+		if response.Code != http.StatusOK {
+			t.Fatalf("GetSecret() status = %d, want %d", response.Code, http.StatusOK)
+		}
+		if body := response.Body.String(); strings.Contains(body, "team-a") || strings.Contains(body, "namespace") {
+			t.Errorf("GetSecret() body exposes the namespace: %s", body)
+		}
+
+		// Create and read events are filed under the namespace
+		page := getAuditPage(t, router, url.Values{"namespace": {"team-a"}})
+		var types []string
+		for _, event := range page.Events {
+			types = append(types, event.Type)
+		}
+		want := []string{store.AuditSecretCreated, store.AuditSecretCreated, store.AuditSecretConsumed}
+		if strings.Join(types, ",") != strings.Join(want, ",") {
+			t.Errorf("team-a audit events = %v, want %v", types, want)
+		}
+
+		if stats := getNamespaceStats(t, router, "unused"); stats.Count != 0 || stats.OldestExpiry != nil {
+			t.Errorf("empty namespace stats = %+v, want zero with no expiry", stats)
+		}
+	})
+}
+
+func TestPurgeNamespace(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newNamespaceTestRouter(t, b)
+
+		purged := []string{createInNamespace(t, router, "team-a"), createInNamespace(t, router, "team-a")}
+		kept := createInNamespace(t, router, "team-b")
+
+		response := adminRequest(router, http.MethodDelete, "/api/admin/namespaces/team-a/secrets")
+		if response.Code != http.StatusOK {
+			t.Fatalf("purge status = %d, want %d", response.Code, http.StatusOK)
+		}
+		var result NamespacePurgeResponse
+		if err := json.NewDecoder(response.Body).Decode(&result); err != nil || result.Deleted != 2 {
+			t.Fatalf("purge = %+v, %v; want 2 deleted", result, err)
+		}
+
+		for _, id := range purged {
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/"+id, nil))
+			if response.Code != http.StatusNotFound {
+				t.Errorf("GetSecret() purged status = %d, want %d", response.Code, http.StatusNotFound)
+			}
+		}
+		response = httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/"+kept, nil))
+		if response.Code != http.StatusOK {
+			t.Errorf("GetSecret() other tenant status = %d, want %d", response.Code, http.StatusOK)
+		}
+	})
+}
+
+// TestPurgeRacesReadAndBurn fires a read, an owner burn and a namespace purge
+// at one secret together: exactly one of them reports it and none fails
+func TestPurgeRacesReadAndBurn(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		for _, shredding := range []bool{false, true} {
+			b.reset(t)
+			cfg := auditTestConfig()
+			cfg.CryptoShredding = shredding
+			handler := NewHandler(b.store, cfg)
+			router := chi.NewRouter()
+			router.Mount("/api", handler.Routes())
+
+			for range 5 {
+				req := getMockCreateSecretRequest(nil)
+				req.Namespace = "race"
+				created := createTestSecretResponse(t, router, req)
+
+				requests := []*http.Request{
+					httptest.NewRequest(http.MethodGet, "/api/secrets/"+created.ID, nil),
+					httptest.NewRequest(http.MethodDelete, "/api/secrets/"+created.ID, nil),
+					httptest.NewRequest(http.MethodDelete, "/api/admin/namespaces/race/secrets", nil),
+				}
+				requests[1].Header.Set(ManagementTokenHeader, created.ManagementToken)
+				requests[2].Header.Set("Authorization", "Bearer "+auditTestToken)
+
+				responses := make([]*httptest.ResponseRecorder, len(requests))
+				start := make(chan struct{})
+				var wg sync.WaitGroup
+				for i, request := range requests {
+					responses[i] = httptest.NewRecorder()
+					wg.Go(func() {
+						<-start
+						router.ServeHTTP(responses[i], request)
+					})
+				}
+				close(start)
+				wg.Wait()
+
+				var purge NamespacePurgeResponse
+				json.NewDecoder(responses[2].Body).Decode(&purge)
+				read, burned := responses[0].Code == http.StatusOK, responses[1].Code == http.StatusNoContent
+				winners := 0
+				for _, won := range []bool{read, burned, purge.Deleted == 1} {
+					if won {
+						winners++
+					}
+				}
+				if winners != 1 || responses[2].Code != http.StatusOK {
+					t.Fatalf("shredding=%v: read %d, burn %d, purge %d deleting %d; want exactly one to win",
+						shredding, responses[0].Code, responses[1].Code, responses[2].Code, purge.Deleted)
+				}
+				for i, response := range responses[:2] {
+					if response.Code >= http.StatusInternalServerError {
+						t.Errorf("shredding=%v: %s status = %d", shredding, requests[i].Method, response.Code)
+					}
+				}
+			}
+		}
+	})
+}
+
+func TestNamespaceValidation(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newNamespaceTestRouter(t, b)
+
+		for _, namespace := range []string{"Team", "-team", strings.Repeat("x", 65)} {
+			req := models.CreateSecretRequest{
+				Ciphertext:    base64.StdEncoding.EncodeToString([]byte("test secret data")),
+				IV:            base64.StdEncoding.EncodeToString(make([]byte, 12)),
+				ExpiresIn:     3600,
+				BurnAfterRead: true,
+				Namespace:     namespace,
+			}
+			if errResp := postErrorResponse(t, router, "/api/secrets", marshalJSON(t, req)); errResp.Code != "invalid_namespace" {
+				t.Errorf("create in %q code = %q, want invalid_namespace", namespace, errResp.Code)
+			}
+		}
+
+		if response := adminRequest(router, http.MethodGet, "/api/admin/namespaces/Team/stats"); response.Code != http.StatusBadRequest {
+			t.Errorf("stats for invalid namespace status = %d, want %d", response.Code, http.StatusBadRequest)
+		}
+
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodDelete, "/api/admin/namespaces/team-a/secrets", nil))
+		if response.Code != http.StatusUnauthorized {
+			t.Errorf("purge without token status = %d, want %d", response.Code, http.StatusUnauthorized)
+		}
+	})
+}
+
+func getNamespaceDeletion(t *testing.T, router http.Handler, namespace string) NamespaceDeletionResponse {
+	t.Helper()
+
+	response := adminRequest(router, http.MethodGet, "/api/admin/namespaces/"+namespace+"/deletion")
+	if response.Code != http.StatusOK {
+		t.Fatalf("namespace deletion status = %d, want %d: %s", response.Code, http.StatusOK, response.Body)
+	}
+	var deletion NamespaceDeletionResponse
+	if err := json.NewDecoder(response.Body).Decode(&deletion); err != nil {
+		t.Fatalf("decode namespace deletion: %v", err)
+	}
+	return deletion
+}
+
+func TestDeleteNamespace(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newNamespaceTestRouter(t, b)
+
+		deleted := []string{createInNamespace(t, router, "team-a"), createInNamespace(t, router, "team-a")}
+		kept := createInNamespace(t, router, "team-b")
+
+		if response := adminRequest(router, http.MethodGet, "/api/admin/namespaces/team-a/deletion"); response.Code != http.StatusNotFound {
+			t.Errorf("deletion before DELETE status = %d, want %d", response.Code, http.StatusNotFound)
+		}
+
+		response := adminRequest(router, http.MethodDelete, "/api/admin/namespaces/team-a")
+		if response.Code != http.StatusAccepted {
+			t.Fatalf("DELETE namespace status = %d, want %d: %s", response.Code, http.StatusAccepted, response.Body)
+		}
+
+		deadline := time.Now().Add(5 * time.Second)
+		deletion := getNamespaceDeletion(t, router, "team-a")
+		for deletion.State != "deleted" && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+			deletion = getNamespaceDeletion(t, router, "team-a")
+		}
+		// Each create audited one event
+		if deletion.State != "deleted" || deletion.FinishedAt == nil || deletion.SecretsDeleted != 2 ||
+			deletion.LiveSecretsDeleted != 2 || deletion.AuditEventsAnonymized != 2 {
+			t.Fatalf("deletion = %+v, want 2 secrets and 2 audit events removed", deletion)
+		}
+
+		for _, id := range deleted {
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/"+id, nil))
+			if response.Code != http.StatusNotFound {
+				t.Errorf("GetSecret() deleted status = %d, want %d", response.Code, http.StatusNotFound)
+			}
+		}
+		response = httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/"+kept, nil))
+		if response.Code != http.StatusOK {
+			t.Errorf("GetSecret() other tenant status = %d, want %d", response.Code, http.StatusOK)
+		}
+
+		req := getMockCreateSecretRequest(nil)
+		req.Namespace = "team-a"
+		if errResp := postErrorResponse(t, router, "/api/secrets", marshalJSON(t, req)); errResp.Code != "tenant_deleted" {
+			t.Errorf("create in deleted namespace code = %q, want tenant_deleted", errResp.Code)
+		}
+
+		if response := adminRequest(router, http.MethodDelete, "/api/admin/namespaces/team-a"); response.Code != http.StatusOK {
+			t.Errorf("repeated DELETE namespace status = %d, want %d", response.Code, http.StatusOK)
+		}
+	})
+}
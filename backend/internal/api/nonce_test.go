@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/models"
+	"ots-backend/internal/testutil"
+)
+
+// withCreateNonces requires create nonces that last a minute
+func withCreateNonces(cfg *config.Config) {
+	cfg.RequireCreateNonce = true
+	cfg.CreateNonceTTL = time.Minute
+}
+
+// fetchCreateNonce returns the nonce cookie and token for one create
+func fetchCreateNonce(t *testing.T, router http.Handler) (*http.Cookie, string) {
+	t.Helper()
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/nonce", nil))
+	if response.Code != http.StatusOK {
+		t.Fatalf("CreateNonce() status = %d, want %d", response.Code, http.StatusOK)
+	}
+
+	var nonce models.CreateNonceResponse
+	if err := json.NewDecoder(response.Body).Decode(&nonce); err != nil {
+		t.Fatalf("CreateNonce() decode error: %v", err)
+	}
+	cookies := response.Result().Cookies()
+	if len(cookies) != 1 || nonce.Nonce == "" {
+		t.Fatalf("CreateNonce() = %d cookies, nonce %q; want one cookie and a nonce", len(cookies), nonce.Nonce)
+	}
+	return cookies[0], nonce.Nonce
+}
+
+func postWithNonce(t *testing.T, router http.Handler, cookie *http.Cookie, token string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodPost, "/api/secrets", strings.NewReader(marshalJSON(t, getMockCreateSecretRequest(nil))))
+	request.Header.Set("Content-Type", "application/json")
+	if cookie != nil {
+		request.AddCookie(cookie)
+	}
+	if token != "" {
+		request.Header.Set("X-Create-Nonce", token)
+	}
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
+
+func TestCreateNonce(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		clk := testutil.NewFakeClock(time.Now())
+		router := newTestRouterWithConfig(t, b, withCreateNonces, withTestClock(clk))
+
+		cookie, token := fetchCreateNonce(t, router)
+		if response := postWithNonce(t, router, cookie, token); response.Code != http.StatusCreated {
+			t.Errorf("create with nonce status = %d, want %d", response.Code, http.StatusCreated)
+		}
+
+		_, otherToken := fetchCreateNonce(t, router)
+		for name, response := range map[string]*httptest.ResponseRecorder{
+			"no nonce":         postWithNonce(t, router, nil, ""),
+			"cookie only":      postWithNonce(t, router, cookie, ""),
+			"header only":      postWithNonce(t, router, nil, token),
+			"mismatched token": postWithNonce(t, router, cookie, otherToken),
+		} {
+			assertErrorCode(t, name, response, http.StatusForbidden, "create_nonce_required")
+		}
+
+		clk.Advance(time.Minute)
+		assertErrorCode(t, "expired", postWithNonce(t, router, cookie, token), http.StatusForbidden, "create_nonce_expired")
+	})
+}
+
+func TestCreateNonceExemptions(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		// API clients authenticate with a token instead of a browser session
+		router := newTestRouterWithConfig(t, b, withCreateNonces, withTestClock(testutil.NewFakeClock(time.Now())))
+		request := httptest.NewRequest(http.MethodPost, "/api/secrets", strings.NewReader(marshalJSON(t, getMockCreateSecretRequest(nil))))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("Authorization", "Bearer api-client")
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		if response.Code != http.StatusCreated {
+			t.Errorf("create with Authorization status = %d, want %d", response.Code, http.StatusCreated)
+		}
+
+		// Without REQUIRE_CREATE_NONCE creates are unchanged
+		createTestSecret(t, newTestRouter(t, b), getMockCreateSecretRequest(nil))
+	})
+}
+
+func assertErrorCode(t *testing.T, name string, response *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+
+	var body models.ErrorResponse
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		t.Fatalf("%s: decode error: %v", name, err)
+	}
+	if response.Code != status || body.Code != code {
+		t.Errorf("%s: response = %d %q, want %d %q", name, response.Code, body.Code, status, code)
+	}
+}
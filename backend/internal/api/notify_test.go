@@ -0,0 +1,138 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/dropped"
+	"ots-backend/internal/notify"
+	"ots-backend/internal/testutil"
+)
+
+const notifyTestKey = "QkJCQkJCQkJCQkJCQkJCQkJCQkJCQkJCQkJCQkJCQkI="
+
+// newNotifyTestRouter serves a handler that mails notices through the SMTP
+// server at smtpAddr
+func newNotifyTestRouter(t *testing.T, b *testBackend, smtpAddr string) http.Handler {
+	cfg := auditTestConfig()
+	cfg.NotifyEmailKey = notifyTestKey
+	cfg.SMTPAddr = smtpAddr
+	cfg.SMTPFrom = "ots@example.net"
+	cfg.NotifyQueueSize = 10
+
+	notifier, err := notify.FromConfig(cfg)
+	if err != nil {
+		t.Fatalf("notify.FromConfig() error: %v", err)
+	}
+	notifier.Start(context.Background())
+	t.Cleanup(notifier.Stop)
+
+	handler := NewHandler(b.store, cfg)
+	handler.SetNotifier(notifier)
+	router := chi.NewRouter()
+	router.Mount("/api", handler.Routes())
+	return withSpecValidation(t, handler, router)
+}
+
+func TestNotifyEmailOnRead(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		server := testutil.NewSMTPServer(t)
+		router := newNotifyTestRouter(t, b, server.Addr)
+
+		req := getMockCreateSecretRequest(nil)
+		req.NotifyEmail = "alice@example.com"
+		secretID := createTestSecret(t, router, req)
+
+		// The address is stored sealed, never in the clear
+		if b.queryRow != nil {
+			var sealed []byte
+			var hash string
+			if err := b.queryRow(context.Background(), `SELECT notify_email, notify_email_hash FROM secrets WHERE id = $1`, secretID).Scan(&sealed, &hash); err != nil {
+				t.Fatalf("read stored address: %v", err)
+			}
+			if len(sealed) == 0 || strings.Contains(string(sealed), "alice") || hash == "" {
+				t.Errorf("stored notify_email = %q, hash %q; want it sealed and hashed", sealed, hash)
+			}
+		}
+
+		if status := getSecretStatus(router, secretID); status != http.StatusOK {
+			t.Fatalf("GET status = %d, want %d", status, http.StatusOK)
+		}
+		mail, ok := server.Next(5 * time.Second)
+		if !ok {
+			t.Fatal("no notice mailed after the read")
+		}
+		if len(mail.To) != 1 || mail.To[0] != "alice@example.com" || !strings.Contains(mail.Data, "Your secret was read") {
+			t.Errorf("notice to %v:\n%s\nwant a read notice to alice@example.com", mail.To, mail.Data)
+		}
+		if strings.Contains(mail.Data, secretID) {
+			t.Errorf("notice names the secret ID:\n%s", mail.Data)
+		}
+
+		// Secrets without an address send nothing
+		if status := getSecretStatus(router, createTestSecret(t, router, getMockCreateSecretRequest(nil))); status != http.StatusOK {
+			t.Fatalf("GET status = %d, want %d", status, http.StatusOK)
+		}
+		if mail, ok := server.Next(200 * time.Millisecond); ok {
+			t.Errorf("notice mailed for a secret without an address:\n%s", mail.Data)
+		}
+	})
+}
+
+func TestNotifyEmailFailureLeavesReadAlone(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		server := testutil.NewSMTPServer(t)
+		server.RejectRcpt(550)
+		router := newNotifyTestRouter(t, b, server.Addr)
+
+		req := getMockCreateSecretRequest(nil)
+		req.NotifyEmail = "alice@example.com"
+		secretID := createTestSecret(t, router, req)
+
+		before := dropped.Counts()["notification/failed"]
+		if status := getSecretStatus(router, secretID); status != http.StatusOK {
+			t.Fatalf("GET status with a failing mail server = %d, want %d", status, http.StatusOK)
+		}
+
+		// The failure is counted off the request path
+		deadline := time.Now().Add(5 * time.Second)
+		for dropped.Counts()["notification/failed"] == before {
+			if time.Now().After(deadline) {
+				t.Fatal("failed notice never recorded as dropped")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
+
+func TestNotifyEmailValidation(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		server := testutil.NewSMTPServer(t)
+		router := newNotifyTestRouter(t, b, server.Addr)
+
+		for _, address := range []string{"alice", "Alice <alice@example.com>", "alice@example.com\r\nBcc: eve@example.com"} {
+			req := getMockCreateSecretRequest(nil)
+			req.NotifyEmail = address
+			if errResp := postErrorResponse(t, router, "/api/secrets", marshalJSON(t, req)); errResp.Code != "invalid_notify_email" {
+				t.Errorf("create with notify_email %q = %+v, want invalid_notify_email", address, errResp)
+			}
+		}
+
+		// Without notifications configured the field is refused, not ignored
+		plain := newTestRouterWithConfig(t, b, func(cfg *config.Config) {})
+		req := getMockCreateSecretRequest(nil)
+		req.NotifyEmail = "alice@example.com"
+		if errResp := postErrorResponse(t, plain, "/api/secrets", marshalJSON(t, req)); errResp.Code != "invalid_notify_email" {
+			t.Errorf("create with notify_email on a server without notifications = %+v, want invalid_notify_email", errResp)
+		}
+	})
+}
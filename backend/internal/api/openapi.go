@@ -0,0 +1,74 @@
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+
+	"ots-backend/internal/logger"
+	"ots-backend/internal/policy"
+)
+
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// OpenAPIDocument returns the API description with the limit-bearing
+// schemas rendered from the handler's policy, so the served document
+// always matches what the validators enforce
+func (h *Handler) OpenAPIDocument() (*openapi3.T, error) {
+	return openAPIDocument(h.policy())
+}
+
+// openAPIDocument renders the API description for p
+func openAPIDocument(p *policy.Policy) (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(openAPISpec)
+	if err != nil {
+		return nil, fmt.Errorf("load openapi spec: %w", err)
+	}
+
+	for name, component := range p.OpenAPIComponents() {
+		raw, err := json.Marshal(component)
+		if err != nil {
+			return nil, fmt.Errorf("marshal %s schema: %w", name, err)
+		}
+
+		schema := openapi3.NewSchemaRef("", nil)
+		if err := schema.UnmarshalJSON(raw); err != nil {
+			return nil, fmt.Errorf("decode %s schema: %w", name, err)
+		}
+		doc.Components.Schemas[name] = schema
+	}
+
+	return doc, nil
+}
+
+// renderOpenAPI renders the JSON document once per configuration
+func (h *Handler) renderOpenAPI() ([]byte, error) {
+	s := h.current()
+	s.openAPIOnce.Do(func() {
+		doc, err := openAPIDocument(s.policy)
+		if err != nil {
+			s.openAPIErr = err
+			return
+		}
+		s.openAPIJSON, s.openAPIErr = json.Marshal(doc)
+	})
+	return s.openAPIJSON, s.openAPIErr
+}
+
+// OpenAPI serves the API description as JSON
+func (h *Handler) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	body, err := h.renderOpenAPI()
+	if err != nil {
+		logger.Error("failed to build openapi document", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to build schema")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Write(body)
+}
@@ -0,0 +1,255 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/db"
+	"ots-backend/internal/policy"
+	pgstore "ots-backend/internal/store/postgres"
+	"ots-backend/pkg/ots"
+)
+
+// servedOpenAPIDocument fetches the spec the way a client would and loads it
+func servedOpenAPIDocument(t *testing.T, h *Handler) *openapi3.T {
+	t.Helper()
+
+	router := chi.NewRouter()
+	router.Mount("/api", h.Routes())
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	if response.Code != http.StatusOK {
+		t.Fatalf("GET /api/openapi.json status = %d, want %d", response.Code, http.StatusOK)
+	}
+
+	doc, err := openapi3.NewLoader().LoadFromData(response.Body.Bytes())
+	if err != nil {
+		t.Fatalf("load served openapi document: %v", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		t.Fatalf("served openapi document is invalid: %v", err)
+	}
+	return doc
+}
+
+// withSpecValidation checks every exchange with next against the served spec.
+// Responses must be documented for their route and status. Requests are
+// checked only when they succeed, since tests send malformed requests on
+// purpose to exercise error paths.
+func withSpecValidation(t *testing.T, h *Handler, next http.Handler) http.Handler {
+	t.Helper()
+
+	doc := servedOpenAPIDocument(t, h)
+	specRouter, err := legacy.NewRouter(doc)
+	if err != nil {
+		t.Fatalf("build openapi router: %v", err)
+	}
+
+	options := &openapi3filter.Options{
+		AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+		IncludeResponseStatus: true,
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Streams are flushed as they are written, and an event stream lasts
+		// until the client leaves, so they are passed through unbuffered;
+		// only their route, request and status are checked
+		if route, pathParams, err := specRouter.FindRoute(r); err == nil && streams(route, r) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+			if route.Operation.Responses.Status(ww.Status()) == nil {
+				t.Errorf("%s %s response %d is not described by the OpenAPI spec", r.Method, r.URL.Path, ww.Status())
+			}
+			if ww.Status() == http.StatusOK {
+				requestInput := &openapi3filter.RequestValidationInput{Request: r, PathParams: pathParams, Route: route, Options: options}
+				if err := openapi3filter.ValidateRequest(context.Background(), requestInput); err != nil {
+					t.Errorf("%s %s request does not match the OpenAPI spec: %v", r.Method, r.URL.Path, err)
+				}
+			}
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("read request body: %v", err)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		recorder := httptest.NewRecorder()
+		next.ServeHTTP(recorder, r)
+
+		for key, values := range recorder.Header() {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(recorder.Code)
+		w.Write(recorder.Body.Bytes())
+
+		route, pathParams, err := specRouter.FindRoute(r)
+		if err != nil {
+			t.Errorf("%s %s is not described by the OpenAPI spec: %v", r.Method, r.URL.Path, err)
+			return
+		}
+
+		requestInput := &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    options,
+		}
+
+		if recorder.Code >= 200 && recorder.Code < 300 {
+			specRequest := r.Clone(r.Context())
+			specRequest.Body = io.NopCloser(bytes.NewReader(body))
+			requestInput.Request = specRequest
+			if err := openapi3filter.ValidateRequest(context.Background(), requestInput); err != nil {
+				t.Errorf("%s %s request does not match the OpenAPI spec: %v", r.Method, r.URL.Path, err)
+			}
+		}
+
+		responseInput := &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: requestInput,
+			Status:                 recorder.Code,
+			Header:                 recorder.Header(),
+			Body:                   io.NopCloser(bytes.NewReader(recorder.Body.Bytes())),
+			Options:                options,
+		}
+		if err := openapi3filter.ValidateResponse(context.Background(), responseInput); err != nil {
+			t.Errorf("%s %s response %d does not match the OpenAPI spec: %v", r.Method, r.URL.Path, recorder.Code, err)
+		}
+	})
+}
+
+// streams reports whether route answers r with a stream: Server-Sent
+// Events, or NDJSON the client asked for
+func streams(route *routers.Route, r *http.Request) bool {
+	ok := route.Operation.Responses.Status(http.StatusOK)
+	if ok == nil || ok.Value == nil {
+		return false
+	}
+	content := ok.Value.Content
+	return content.Get(eventStreamContentType) != nil ||
+		(r.Header.Get("Accept") == ndjsonContentType && content.Get(ndjsonContentType) != nil)
+}
+
+func TestOpenAPIPathsMatchRouter(t *testing.T) {
+	handler := NewHandler(pgstore.New(&db.DB{}), &config.Config{})
+	doc := servedOpenAPIDocument(t, handler)
+
+	var routed []string
+	err := chi.Walk(handler.Routes(), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routed = append(routed, method+" /api"+strings.TrimSuffix(route, "/"))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk routes: %v", err)
+	}
+
+	var documented []string
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			documented = append(documented, method+" "+path)
+		}
+	}
+
+	sort.Strings(routed)
+	sort.Strings(documented)
+	if strings.Join(routed, "\n") != strings.Join(documented, "\n") {
+		t.Errorf("routes and spec differ\nrouted:\n  %s\ndocumented:\n  %s",
+			strings.Join(routed, "\n  "), strings.Join(documented, "\n  "))
+	}
+}
+
+func TestOpenAPIDocumentsErrorStatuses(t *testing.T) {
+	doc := servedOpenAPIDocument(t, NewHandler(pgstore.New(&db.DB{}), &config.Config{}))
+	create := doc.Paths.Find("/api/secrets").Post
+
+	// Every status a create can fail with must be documented
+	for _, mapping := range ots.Mappings {
+		if mapping.Err == ots.ErrNotFound || mapping.Err == ots.ErrManagementTokenRequired || mapping.Err == ots.ErrWrongRegion || mapping.Err == ots.ErrMethodNotAllowed ||
+			mapping.Err == ots.ErrRevisionMismatch {
+			continue
+		}
+		if create.Responses.Status(mapping.Status) == nil {
+			t.Errorf("POST /api/secrets does not document %d (%s)", mapping.Status, mapping.Code)
+		}
+	}
+
+	for _, status := range []int{http.StatusCreated, http.StatusTooManyRequests} {
+		if create.Responses.Status(status) == nil {
+			t.Errorf("POST /api/secrets does not document %d", status)
+		}
+	}
+
+	burn := doc.Paths.Find("/api/secrets/{id}").Delete
+	for _, err := range []error{ots.ErrNotFound, ots.ErrManagementTokenRequired, ots.ErrWrongRegion, ots.ErrRevisionMismatch} {
+		if burn.Responses.Status(ots.StatusCode(err)) == nil {
+			t.Errorf("DELETE /api/secrets/{id} does not document %d (%s)", ots.StatusCode(err), ots.ErrorCode(err))
+		}
+	}
+}
+
+func TestOpenAPILimitsFollowPolicy(t *testing.T) {
+	for _, maxSize := range []int{1024, 65536} {
+		t.Run(strconv.Itoa(maxSize), func(t *testing.T) {
+			cfg := &config.Config{MaxSecretSize: maxSize, MinKeyBits: 128}
+			doc := servedOpenAPIDocument(t, NewHandler(pgstore.New(&db.DB{}), cfg))
+			p := policy.FromConfig(cfg)
+
+			request := doc.Components.Schemas["CreateSecretRequest"].Value
+			ciphertext := request.Properties["ciphertext"].Value
+			if ciphertext.MaxLength == nil {
+				t.Fatal("ciphertext has no maxLength")
+			}
+
+			// The served schemas are exactly the ones the policy renders
+			served, err := json.Marshal(doc.Components.Schemas["CreateSecretRequest"])
+			if err != nil {
+				t.Fatalf("marshal served schema: %v", err)
+			}
+			rendered, err := json.Marshal(p.OpenAPIComponents()["CreateSecretRequest"])
+			if err != nil {
+				t.Fatalf("marshal policy schema: %v", err)
+			}
+			if !jsonEqual(t, served, rendered) {
+				t.Errorf("served CreateSecretRequest = %s, want %s", served, rendered)
+			}
+
+			bits := request.Properties["declared_key_bits"].Value
+			if bits.Min == nil || *bits.Min != 128 {
+				t.Errorf("declared_key_bits minimum = %v, want 128", bits.Min)
+			}
+		})
+	}
+}
+
+func jsonEqual(t *testing.T, a, b []byte) bool {
+	t.Helper()
+
+	var va, vb any
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	ja, _ := json.Marshal(va)
+	jb, _ := json.Marshal(vb)
+	return bytes.Equal(ja, jb)
+}
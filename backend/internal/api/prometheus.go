@@ -0,0 +1,122 @@
+package api
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// prometheusContentType is the Prometheus text exposition format
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// prometheusPrefix namespaces every exposed series
+const prometheusPrefix = "ots_"
+
+// wantsPrometheus reports whether a metrics request asked for the text
+// exposition, as Prometheus scrapers do in Accept, rather than JSON
+func wantsPrometheus(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "text/plain") || strings.Contains(accept, "application/openmetrics-text")
+}
+
+// writePrometheus writes m in the Prometheus text exposition format. Each
+// number or boolean of the JSON response becomes a series named after its
+// JSON key, a counter when the key ends in _total or _bucket and a gauge
+// otherwise. A map becomes one series per key, labeled by its prom tag; a
+// tag of several labels splits the key at "/". A nested struct prefixes
+// its fields with its own key. Strings are left out.
+func writePrometheus(w io.Writer, m MetricsResponse) error {
+	out := bufio.NewWriter(w)
+	writePrometheusStruct(out, prometheusPrefix, reflect.ValueOf(m))
+	return out.Flush()
+}
+
+func writePrometheusStruct(out *bufio.Writer, prefix string, v reflect.Value) {
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		key, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+		name := prefix + key
+		value := v.Field(i)
+
+		switch value.Kind() {
+		case reflect.Pointer:
+			if !value.IsNil() && value.Elem().Kind() == reflect.Struct {
+				writePrometheusStruct(out, name+"_", value.Elem())
+			}
+		case reflect.Map:
+			if value.Len() == 0 {
+				continue
+			}
+			labels := strings.Split(field.Tag.Get("prom"), ",")
+			writePrometheusType(out, name)
+			keys := make([]string, 0, value.Len())
+			for _, k := range value.MapKeys() {
+				keys = append(keys, k.String())
+			}
+			slices.Sort(keys)
+			for _, k := range keys {
+				writePrometheusSample(out, name, prometheusLabels(labels, k), value.MapIndex(reflect.ValueOf(k)))
+			}
+		case reflect.Bool, reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint64, reflect.Float64:
+			writePrometheusType(out, name)
+			writePrometheusSample(out, name, "", value)
+		}
+	}
+}
+
+func writePrometheusType(out *bufio.Writer, name string) {
+	kind := "gauge"
+	if strings.HasSuffix(name, "_total") || strings.HasSuffix(name, "_bucket") {
+		kind = "counter"
+	}
+	out.WriteString("# TYPE " + name + " " + kind + "\n")
+}
+
+func writePrometheusSample(out *bufio.Writer, name, labels string, value reflect.Value) {
+	out.WriteString(name + labels + " ")
+	switch value.Kind() {
+	case reflect.Bool:
+		if value.Bool() {
+			out.WriteString("1")
+		} else {
+			out.WriteString("0")
+		}
+	case reflect.Float64:
+		out.WriteString(strconv.FormatFloat(value.Float(), 'g', -1, 64))
+	case reflect.Uint64:
+		out.WriteString(strconv.FormatUint(value.Uint(), 10))
+	default:
+		out.WriteString(strconv.FormatInt(value.Int(), 10))
+	}
+	out.WriteString("\n")
+}
+
+// prometheusLabels renders a map key as a label set. The key is split at
+// "/" into as many values as there are names, the last taking the rest.
+func prometheusLabels(names []string, key string) string {
+	values := strings.SplitN(key, "/", len(names))
+	var b strings.Builder
+	b.WriteString("{")
+	for i, name := range names {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		b.WriteString(name + `="` + prometheusEscaper.Replace(value) + `"`)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// prometheusEscaper escapes a label value
+var prometheusEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
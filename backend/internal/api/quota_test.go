@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/testutil"
+)
+
+func TestActiveSecretQuota(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		clk := testutil.NewFakeClock(time.Now().Truncate(time.Second))
+		cfg := auditTestConfig()
+		cfg.MaxActiveSecretsPerIP = 2
+		handler := NewHandler(b.store, cfg)
+		handler.SetClock(clk)
+		r := chi.NewRouter()
+		r.Mount("/api", handler.Routes())
+		router := withSpecValidation(t, handler, r)
+
+		body := marshalJSON(t, getMockCreateSecretRequest(nil))
+		createFrom := func(ip string) *httptest.ResponseRecorder {
+			request := httptest.NewRequest(http.MethodPost, "/api/secrets", strings.NewReader(body))
+			request.Header.Set("Content-Type", "application/json")
+			request.RemoteAddr = ip + ":1234"
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, request)
+			return response
+		}
+
+		first := createTestSecret(t, router, getMockCreateSecretRequest(nil))
+		createTestSecret(t, router, getMockCreateSecretRequest(nil))
+
+		before := GetMetrics().QuotaRejections
+		if errResp := postErrorResponse(t, router, "/api/secrets", body); errResp.Code != "quota_exceeded" {
+			t.Fatalf("create over quota code = %q, want quota_exceeded", errResp.Code)
+		}
+		if got := GetMetrics().QuotaRejections - before; got != 1 {
+			t.Errorf("quota_rejections_total grew by %d, want 1", got)
+		}
+		if response := createFrom("198.51.100.7"); response.Code != http.StatusCreated {
+			t.Errorf("create from another IP status = %d, want %d", response.Code, http.StatusCreated)
+		}
+
+		response := adminRequest(router, http.MethodGet, "/api/admin/stats")
+		var stats AdminStatsResponse
+		if err := json.NewDecoder(response.Body).Decode(&stats); err != nil {
+			t.Fatalf("decode admin stats: %v", err)
+		}
+		quota := stats.ActiveSecretQuota
+		if quota.Limit != 2 || quota.Creators != 2 || quota.CreatorsAtLimit != 1 || quota.MaxActive != 2 || quota.Rejections < 1 {
+			t.Errorf("active_secret_quota = %+v, want limit 2, 2 creators, 1 at the limit", quota)
+		}
+
+		// Reading a secret frees its slot
+		response = httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/"+first, nil))
+		if response.Code != http.StatusOK {
+			t.Fatalf("GetSecret() status = %d, want %d", response.Code, http.StatusOK)
+		}
+		createTestSecret(t, router, getMockCreateSecretRequest(nil))
+		if errResp := postErrorResponse(t, router, "/api/secrets", body); errResp.Code != "quota_exceeded" {
+			t.Fatalf("create over quota after a read code = %q, want quota_exceeded", errResp.Code)
+		}
+
+		// So does expiry, before the cleanup worker has removed anything
+		clk.Advance(16 * time.Minute)
+		createTestSecret(t, router, getMockCreateSecretRequest(nil))
+		createTestSecret(t, router, getMockCreateSecretRequest(nil))
+	})
+}
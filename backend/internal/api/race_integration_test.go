@@ -0,0 +1,26 @@
+//go:build integration
+
+package api
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestDoubleReadRaceUnderLoad fires many concurrent reads at many secrets
+// on every backend, Postgres included, so readers queue on the row lock
+// that Consume takes with FOR UPDATE rather than missing it. Run with -v to
+// see the per-secret lock waits.
+func TestDoubleReadRaceUnderLoad(t *testing.T) {
+	if testing.Short() {
+		t.Skip("stress test")
+	}
+	// The point is the Postgres row lock; the file's init adds it to the
+	// matrix, so a run without it proves nothing
+	if !slices.ContainsFunc(testBackends, func(b *testBackend) bool { return b.name == "postgres" }) {
+		t.Fatal("the postgres backend is not in the test matrix")
+	}
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		runDoubleReadRace(t, b, 50, 32)
+	})
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"ots-backend/internal/config"
+)
+
+// raceResult is what the concurrent reads of one secret got back
+type raceResult struct {
+	statuses map[int]int
+	// waits holds each read's duration, sorted; the spread between the
+	// fastest and slowest is mostly time spent waiting on the row lock
+	waits []time.Duration
+}
+
+// runDoubleReadRace creates secrets secrets and fires readers concurrent
+// GETs at each through the router, then checks every secret was read
+// exactly once: one 200, the rest 404 or 410 and never a 5xx. Per-secret
+// timings are logged so lock waits can be watched with -v.
+func runDoubleReadRace(t *testing.T, b *testBackend, secrets, readers int) {
+	for _, shredding := range []bool{false, true} {
+		name := "deleted"
+		if shredding {
+			name = "shredded"
+		}
+		t.Run(name, func(t *testing.T) {
+			b.reset(t)
+			router := newTestRouterWithConfig(t, b, func(cfg *config.Config) {
+				cfg.CryptoShredding = shredding
+				cfg.ReadRateLimitRequests = secrets * readers * 2
+			})
+
+			ids := make([]string, secrets)
+			for i := range ids {
+				ids[i] = createTestSecret(t, router, getMockCreateSecretRequest(nil))
+			}
+
+			results := make([]raceResult, secrets)
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			start := make(chan struct{})
+			for i, id := range ids {
+				results[i].statuses = make(map[int]int)
+				for range readers {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						<-start
+
+						began := time.Now()
+						response := httptest.NewRecorder()
+						router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/"+id, nil))
+						took := time.Since(began)
+
+						mu.Lock()
+						results[i].statuses[response.Code]++
+						results[i].waits = append(results[i].waits, took)
+						mu.Unlock()
+					}()
+				}
+			}
+			close(start)
+			wg.Wait()
+
+			for i, result := range results {
+				slices.Sort(result.waits)
+				t.Logf("secret %d: statuses %v, fastest %v, median %v, slowest %v", i, result.statuses,
+					result.waits[0], result.waits[len(result.waits)/2], result.waits[len(result.waits)-1])
+
+				if result.statuses[http.StatusOK] != 1 {
+					t.Errorf("secret %d read %d times, want exactly once: %v", i, result.statuses[http.StatusOK], result.statuses)
+				}
+				if misses := result.statuses[http.StatusNotFound] + result.statuses[http.StatusGone]; misses != readers-1 {
+					t.Errorf("secret %d got %d 404s and 410s, want %d: %v", i, misses, readers-1, result.statuses)
+				}
+			}
+		})
+	}
+}
+
+// TestDoubleReadRace is the small version of the Postgres stress test in
+// race_integration_test.go, run on every backend in the normal suite
+func TestDoubleReadRace(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		runDoubleReadRace(t, b, 5, 8)
+	})
+}
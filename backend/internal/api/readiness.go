@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/logger"
+	"ots-backend/internal/models"
+)
+
+// ReadinessState is a stage of the instance lifecycle. Stages only move
+// forward, in the order declared; only ReadinessReady takes traffic.
+type ReadinessState int32
+
+const (
+	// ReadinessStarting is before the store is opened
+	ReadinessStarting ReadinessState = iota
+	// ReadinessMigrating is while migrations run, or wait for another
+	// instance's
+	ReadinessMigrating
+	// ReadinessWarmingUp is from mounting the API until warm-up ends and
+	// the store has answered a query
+	ReadinessWarmingUp
+	// ReadinessReady is serving
+	ReadinessReady
+	// ReadinessDraining is from the start of shutdown
+	ReadinessDraining
+)
+
+var readinessStateNames = [...]string{"starting", "migrating", "warming_up", "ready", "draining"}
+
+func (s ReadinessState) String() string {
+	return readinessStateNames[s]
+}
+
+// startupRetryAfter is the Retry-After sent while the API is not mounted
+const startupRetryAfter = 5 * time.Second
+
+// Readiness tracks the lifecycle stage. It is created before the store is
+// opened, so probes can be answered while migrations run, and handed to
+// the Handler once the API is mounted.
+type Readiness struct {
+	state atomic.Int32
+}
+
+// NewReadiness returns a lifecycle at ReadinessStarting
+func NewReadiness() *Readiness {
+	return &Readiness{}
+}
+
+// State returns the current stage
+func (r *Readiness) State() ReadinessState {
+	return ReadinessState(r.state.Load())
+}
+
+// Advance moves to state and reports whether it did. A stage at or before
+// the current one is refused, so a warm-up finishing after shutdown began
+// cannot put a draining instance back in rotation.
+func (r *Readiness) Advance(state ReadinessState) bool {
+	for {
+		current := r.state.Load()
+		if current >= int32(state) {
+			return false
+		}
+		if r.state.CompareAndSwap(current, int32(state)) {
+			logger.Info("readiness changed", "from", ReadinessState(current).String(), "to", state.String())
+			return true
+		}
+	}
+}
+
+// SetReadiness shares a lifecycle created before the handler, replacing the
+// one NewHandler starts with
+func (h *Handler) SetReadiness(readiness *Readiness) {
+	h.readiness = readiness
+}
+
+// StartupHandler answers requests before the API is mounted. Liveness
+// passes, the other health aliases report the stage with a 503, and
+// everything else is a retryable 503.
+func StartupHandler(readiness *Readiness) http.Handler {
+	notReady := func(w http.ResponseWriter, r *http.Request) {
+		RecordHealthHit(healthAlias(r))
+		state := readiness.State().String()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(HealthCheckResponse{
+			Status:    state,
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Version:   "1.0.0",
+			Readiness: state,
+			Checks:    map[string]string{},
+		})
+	}
+
+	r := chi.NewRouter()
+	r.Get(HealthAliasRoot, notReady)
+	r.Get(HealthAliasAPI, notReady)
+	r.Get(HealthAliasReady, notReady)
+	r.Get(HealthAliasLive, func(w http.ResponseWriter, r *http.Request) {
+		RecordHealthHit(HealthAliasLive)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(HealthCheckResponse{
+			Status:    "alive",
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Version:   "1.0.0",
+			Checks:    map[string]string{},
+		})
+	})
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		retryable := true
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", strconv.Itoa(int(startupRetryAfter/time.Second)))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:     http.StatusText(http.StatusServiceUnavailable),
+			Message:   "Server is not ready: " + readiness.State().String(),
+			Code:      "not_ready",
+			Retryable: &retryable,
+		})
+	})
+	r.MethodNotAllowed(r.NotFoundHandler())
+	return r
+}
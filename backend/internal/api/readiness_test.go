@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ots-backend/internal/models"
+)
+
+func TestReadinessOnlyAdvances(t *testing.T) {
+	r := NewReadiness()
+	if got := r.State(); got != ReadinessStarting {
+		t.Fatalf("new State() = %s, want starting", got)
+	}
+
+	steps := []struct {
+		to   ReadinessState
+		want bool
+	}{
+		{ReadinessMigrating, true},
+		{ReadinessMigrating, false},
+		{ReadinessStarting, false},
+		// Memory stores have no migrations; skipping a stage is fine
+		{ReadinessReady, true},
+		{ReadinessWarmingUp, false},
+		{ReadinessDraining, true},
+		{ReadinessReady, false},
+	}
+	for _, step := range steps {
+		if got := r.Advance(step.to); got != step.want {
+			t.Errorf("Advance(%s) from %s = %v, want %v", step.to, r.State(), got, step.want)
+		}
+	}
+	if got := r.State(); got != ReadinessDraining {
+		t.Errorf("State() = %s, want draining", got)
+	}
+}
+
+func TestReadinessProbeFollowsLifecycle(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		st := &warmingStore{Store: b.store, release: make(chan struct{})}
+		handler, router := newWarmupRouter(st)
+		lifecycle := NewReadiness()
+		handler.SetReadiness(lifecycle)
+
+		expect := func(stage string, wantCode int, wantStatus string) {
+			t.Helper()
+			code, body := getHealth(t, router, HealthAliasReady)
+			if code != wantCode || body.Status != wantStatus || body.Readiness != stage {
+				t.Fatalf("%s: ready = %d status=%q readiness=%q, want %d status=%q readiness=%q",
+					stage, code, body.Status, body.Readiness, wantCode, wantStatus, stage)
+			}
+			// The plain alias reports the stage without failing on it
+			if _, body := getHealth(t, router, HealthAliasAPI); body.Readiness != stage || body.Status != "healthy" {
+				t.Errorf("%s: /api/health status=%q readiness=%q, want healthy %q", stage, body.Status, body.Readiness, stage)
+			}
+		}
+
+		expect("starting", http.StatusServiceUnavailable, "starting")
+		lifecycle.Advance(ReadinessMigrating)
+		expect("migrating", http.StatusServiceUnavailable, "migrating")
+
+		done := handler.StartWarmUp(context.Background(), time.Minute)
+		expect("warming_up", http.StatusServiceUnavailable, "warming_up")
+
+		close(st.release)
+		<-done
+		expect("ready", http.StatusOK, "healthy")
+
+		lifecycle.Advance(ReadinessDraining)
+		expect("draining", http.StatusServiceUnavailable, "draining")
+
+		// Liveness passes throughout
+		if code, body := getHealth(t, router, HealthAliasLive); code != http.StatusOK || body.Readiness != "" {
+			t.Errorf("live while draining = %d readiness=%q, want 200 without readiness", code, body.Readiness)
+		}
+	})
+}
+
+func TestWarmUpAfterShutdownStaysDraining(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		st := &warmingStore{Store: b.store, release: make(chan struct{})}
+		handler, router := newWarmupRouter(st)
+
+		done := handler.StartWarmUp(context.Background(), time.Minute)
+		handler.readiness.Advance(ReadinessDraining)
+		close(st.release)
+		<-done
+
+		if code, body := getHealth(t, router, HealthAliasReady); code != http.StatusServiceUnavailable || body.Readiness != "draining" {
+			t.Fatalf("ready after a late warm-up = %d %q, want 503 draining", code, body.Readiness)
+		}
+	})
+}
+
+func TestStartupHandler(t *testing.T) {
+	lifecycle := NewReadiness()
+	lifecycle.Advance(ReadinessMigrating)
+	router := StartupHandler(lifecycle)
+
+	if code, body := getHealth(t, router, HealthAliasLive); code != http.StatusOK || body.Status != "alive" {
+		t.Errorf("live = %d %q, want 200 alive", code, body.Status)
+	}
+	before := GetMetrics().HealthHits
+	aliases := []string{HealthAliasRoot, HealthAliasAPI, HealthAliasReady}
+	for _, alias := range aliases {
+		if code, body := getHealth(t, router, alias); code != http.StatusServiceUnavailable || body.Status != "migrating" || body.Readiness != "migrating" {
+			t.Errorf("%s = %d status=%q readiness=%q, want 503 migrating", alias, code, body.Status, body.Readiness)
+		}
+	}
+	after := GetMetrics().HealthHits
+	for _, alias := range aliases {
+		if got := after[alias] - before[alias]; got != 1 {
+			t.Errorf("health hits for %s = %d, want 1", alias, got)
+		}
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(method, "/api/secrets/abc", nil))
+		var body models.ErrorResponse
+		if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+			t.Fatalf("decode %s error: %v", method, err)
+		}
+		if response.Code != http.StatusServiceUnavailable || body.Code != "not_ready" || body.Retryable == nil || !*body.Retryable {
+			t.Errorf("%s before mount = %d code=%q retryable=%v, want retryable 503 not_ready", method, response.Code, body.Code, body.Retryable)
+		}
+		if response.Header().Get("Retry-After") == "" {
+			t.Errorf("%s before mount has no Retry-After", method)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/crypto"
+	"ots-backend/internal/httpx"
+	"ots-backend/internal/logger"
+	"ots-backend/internal/models"
+	"ots-backend/internal/store"
+)
+
+// receiptStates maps how a secret ended to the state its receipt reports
+var receiptStates = map[store.TerminationReason]string{
+	store.TerminationConsumed: models.ReceiptConsumed,
+	store.TerminationBurned:   models.ReceiptBurned,
+	store.TerminationExpired:  models.ReceiptExpired,
+}
+
+// SecretReceipt tells the holder of a secret's management token what became
+// of it: still active, or read, burned or expired and when. Ended secrets
+// are answered from their tombstone, which keeps the token hash, until the
+// tombstone passes RECEIPT_RETENTION. The secret's revision comes in the
+// body and as the ETag a burn can send back in If-Match. Unknown IDs, wrong
+// tokens and tombstones past retention all get the 404 of an unknown
+// secret.
+func (h *Handler) SecretReceipt(w http.ResponseWriter, r *http.Request) {
+	secretID := chi.URLParam(r, "id")
+	if err := h.validateSecretID(secretID); err != nil {
+		h.respondLookupMiss(w, r)
+		return
+	}
+	if h.respondIfForeign(w, secretID) {
+		return
+	}
+
+	receipt, err := h.secretReceipt(r.Context(), secretID, r.Header.Get(ManagementTokenHeader))
+	if errors.Is(err, store.ErrNotFound) {
+		logger.Warn("receipt refused", "secret_id", secretID, "ip", r.RemoteAddr)
+		h.respondLookupMiss(w, r)
+		return
+	}
+	if err != nil {
+		logger.Error("failed to look up secret receipt", "error", err, "secret_id", secretID)
+		h.respondStoreError(w, err, "database error")
+		return
+	}
+
+	httpx.SetHeader(w.Header(), "ETag", revisionTag(receipt.Revision))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(receipt)
+}
+
+// secretReceipt builds the receipt token may see for secretID, or returns
+// store.ErrNotFound when there is none or the token does not match
+func (h *Handler) secretReceipt(ctx context.Context, secretID, token string) (*models.SecretReceiptResponse, error) {
+	if token == "" {
+		return nil, store.ErrNotFound
+	}
+	now := h.clock.Now()
+
+	tombstone, err := h.store.Receipt(ctx, secretID)
+	if err == nil {
+		// The cleanup worker prunes on its own schedule; a tombstone it has
+		// yet to reach is past retention all the same
+		retention := h.config().ReceiptRetention
+		if retention > 0 && tombstone.ConsumedAt.Before(now.Add(-retention)) {
+			return nil, store.ErrNotFound
+		}
+		if !crypto.VerifyManagementToken(token, tombstone.ManagementTokenHash) {
+			return nil, store.ErrNotFound
+		}
+		endedAt := tombstone.ConsumedAt.UTC()
+		receipt := &models.SecretReceiptResponse{State: receiptStates[tombstone.Reason], EndedAt: &endedAt, Revision: tombstone.Revision}
+		if tombstone.Reason == store.TerminationConsumed {
+			receipt.ConsumedAt = &endedAt
+			receipt.ReaderCountry = tombstone.Country
+		}
+		return receipt, nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+
+	authorized, err := h.checkManagementToken(ctx, secretID, token)
+	if err != nil {
+		return nil, err
+	}
+	if !authorized {
+		return nil, store.ErrNotFound
+	}
+
+	// A stored secret without a tombstone is live, scheduled or past its
+	// expiry and waiting for the cleanup worker
+	revision, err := h.store.Revision(ctx, secretID)
+	if err != nil {
+		return nil, err
+	}
+	var notYet *store.NotYetAvailableError
+	_, err = h.store.Peek(ctx, secretID, now)
+	switch {
+	case err == nil, errors.As(err, &notYet):
+		return &models.SecretReceiptResponse{State: models.ReceiptActive, Revision: revision}, nil
+	case errors.Is(err, store.ErrNotFound):
+		return &models.SecretReceiptResponse{State: models.ReceiptExpired, Revision: revision}, nil
+	default:
+		return nil, err
+	}
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/models"
+	"ots-backend/internal/store"
+	"ots-backend/internal/terminate"
+	"ots-backend/internal/testutil"
+)
+
+// countryLocator places every reader in one country
+type countryLocator string
+
+func (c countryLocator) Country(netip.Addr) string {
+	return string(c)
+}
+
+const receiptTestRetention = 24 * time.Hour
+
+// readersInNL places every reader in nl
+func readersInNL(h *Handler) {
+	h.SetLocator(countryLocator("nl"))
+}
+
+// withReceiptRetention keeps receipts for receiptTestRetention
+func withReceiptRetention(cfg *config.Config) {
+	cfg.ReceiptRetention = receiptTestRetention
+}
+
+// getReceipt asks for id's receipt with token, decoding a 200 answer
+func getReceipt(t *testing.T, router http.Handler, id, token string) (int, models.SecretReceiptResponse) {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodGet, "/api/secrets/"+id+"/receipt", nil)
+	if token != "" {
+		request.Header.Set(ManagementTokenHeader, token)
+	}
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	var receipt models.SecretReceiptResponse
+	if response.Code == http.StatusOK {
+		if err := json.NewDecoder(response.Body).Decode(&receipt); err != nil {
+			t.Fatalf("decode receipt: %v", err)
+		}
+	}
+	return response.Code, receipt
+}
+
+func TestSecretReceiptStates(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		clk := testutil.NewFakeClock(time.Now().Truncate(time.Second))
+		router := newTestRouterWithConfig(t, b, withReceiptRetention, withTestClock(clk), readersInNL)
+
+		t.Run("active", func(t *testing.T) {
+			created := createTestSecretResponse(t, router, getMockCreateSecretRequest(nil))
+			status, receipt := getReceipt(t, router, created.ID, created.ManagementToken)
+			if status != http.StatusOK || receipt != (models.SecretReceiptResponse{State: models.ReceiptActive, Revision: 1}) {
+				t.Errorf("receipt = %d %+v, want 200 active at revision 1 with no times", status, receipt)
+			}
+			// Asking does not consume the secret
+			if status := getSecretStatus(router, created.ID); status != http.StatusOK {
+				t.Errorf("GET after receipt status = %d, want %d", status, http.StatusOK)
+			}
+		})
+
+		t.Run("consumed", func(t *testing.T) {
+			created := createTestSecretResponse(t, router, getMockCreateSecretRequest(nil))
+			readAt := clk.Now().UTC()
+			getSecretStatus(router, created.ID)
+
+			clk.Advance(time.Hour)
+			status, receipt := getReceipt(t, router, created.ID, created.ManagementToken)
+			if status != http.StatusOK || receipt.State != models.ReceiptConsumed || receipt.ConsumedAt == nil ||
+				!receipt.ConsumedAt.Equal(readAt) || receipt.ReaderCountry != "NL" {
+				t.Errorf("receipt = %d %+v, want consumed at %v from NL", status, receipt, readAt)
+			}
+		})
+
+		t.Run("burned", func(t *testing.T) {
+			created := createTestSecretResponse(t, router, getMockCreateSecretRequest(nil))
+			request := httptest.NewRequest(http.MethodDelete, "/api/secrets/"+created.ID, nil)
+			request.Header.Set(ManagementTokenHeader, created.ManagementToken)
+			router.ServeHTTP(httptest.NewRecorder(), request)
+
+			status, receipt := getReceipt(t, router, created.ID, created.ManagementToken)
+			if status != http.StatusOK || receipt.State != models.ReceiptBurned || receipt.EndedAt == nil ||
+				receipt.ConsumedAt != nil || receipt.ReaderCountry != "" {
+				t.Errorf("receipt = %d %+v, want burned with an end time and no reader", status, receipt)
+			}
+		})
+
+		t.Run("expired", func(t *testing.T) {
+			created := createTestSecretResponse(t, router, getMockCreateSecretRequest(nil))
+			clk.Advance(16 * time.Minute)
+
+			// Past its expiry but not yet swept, the secret has no end time
+			status, receipt := getReceipt(t, router, created.ID, created.ManagementToken)
+			if status != http.StatusOK || receipt != (models.SecretReceiptResponse{State: models.ReceiptExpired, Revision: 1}) {
+				t.Errorf("receipt before the sweep = %d %+v, want expired at revision 1 with no times", status, receipt)
+			}
+
+			sweeper := &terminate.Terminator{Store: b.store, Clock: clk, SkipAudit: true}
+			if _, err := sweeper.Terminate(context.Background(), created.ID, store.TerminationExpired, terminate.Options{}); err != nil {
+				t.Fatalf("expire secret: %v", err)
+			}
+			status, receipt = getReceipt(t, router, created.ID, created.ManagementToken)
+			if status != http.StatusOK || receipt.State != models.ReceiptExpired || receipt.EndedAt == nil || !receipt.EndedAt.Equal(clk.Now()) {
+				t.Errorf("receipt after the sweep = %d %+v, want expired at %v", status, receipt, clk.Now())
+			}
+		})
+	})
+}
+
+func TestSecretReceiptUnauthorized(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		clk := testutil.NewFakeClock(time.Now().Truncate(time.Second))
+		router := newTestRouterWithConfig(t, b, withReceiptRetention, withTestClock(clk), readersInNL)
+
+		live := createTestSecretResponse(t, router, getMockCreateSecretRequest(nil))
+		read := createTestSecretResponse(t, router, getMockCreateSecretRequest(nil))
+		getSecretStatus(router, read.ID)
+		// Another secret's token proves nothing about this one
+		other := createTestSecretResponse(t, router, getMockCreateSecretRequest(nil))
+
+		for _, tt := range []struct {
+			name, id, token string
+		}{
+			{name: "live without token", id: live.ID},
+			{name: "live with wrong token", id: live.ID, token: other.ManagementToken},
+			{name: "read without token", id: read.ID},
+			{name: "read with wrong token", id: read.ID, token: other.ManagementToken},
+			{name: "unknown", id: "00000000000000000000000000", token: live.ManagementToken},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				if status, _ := getReceipt(t, router, tt.id, tt.token); status != http.StatusNotFound {
+					t.Errorf("receipt status = %d, want %d", status, http.StatusNotFound)
+				}
+			})
+		}
+
+		// Tombstones are refused past retention even before they are pruned
+		clk.Advance(receiptTestRetention + time.Second)
+		if status, _ := getReceipt(t, router, read.ID, read.ManagementToken); status != http.StatusNotFound {
+			t.Errorf("receipt past retention status = %d, want %d", status, http.StatusNotFound)
+		}
+	})
+}
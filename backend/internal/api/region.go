@@ -0,0 +1,61 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"ots-backend/internal/models"
+	"ots-backend/internal/validation"
+	"ots-backend/pkg/ots"
+)
+
+// ParseRegionPeers parses REGION_PEERS entries of the form code=base-url
+// into a map from region code to base URL
+func ParseRegionPeers(values []string) (map[string]string, error) {
+	peers := make(map[string]string, len(values))
+	for _, value := range values {
+		code, baseURL, ok := strings.Cut(value, "=")
+		if !ok {
+			return nil, fmt.Errorf("region peer %q: want code=base-url", value)
+		}
+		code = strings.TrimSpace(code)
+		if err := validation.ValidateRegionCode(code); err != nil {
+			return nil, fmt.Errorf("region peer %q: %w", value, err)
+		}
+
+		parsed, err := url.Parse(strings.TrimSpace(baseURL))
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("region peer %q: base URL must be an absolute http(s) URL", value)
+		}
+		peers[code] = strings.TrimRight(parsed.String(), "/")
+	}
+	return peers, nil
+}
+
+// SetRegion prefixes new secret IDs with code and answers requests for
+// other regions' secrets with 421, naming their base URL from peers. An
+// empty code turns regions off: IDs are unprefixed and nothing is foreign.
+func (h *Handler) SetRegion(code string, peers map[string]string) {
+	h.region = code
+	h.regionPeers = peers
+}
+
+// respondIfForeign answers 421 for a secret created by another region and
+// reports whether it did. Nothing is proxied; the client follows the base
+// URL itself.
+func (h *Handler) respondIfForeign(w http.ResponseWriter, secretID string) bool {
+	region := validation.SecretIDRegion(secretID)
+	if h.region == "" || region == "" || region == h.region {
+		return false
+	}
+
+	RecordWrongRegion()
+	h.respondErrorBody(w, ots.StatusCode(ots.ErrWrongRegion), models.ErrorResponse{
+		Message: ots.ErrWrongRegion.Error(),
+		Code:    ots.ErrorCode(ots.ErrWrongRegion),
+		Region:  &models.RegionRedirect{Code: region, BaseURL: h.regionPeers[region]},
+	})
+	return true
+}
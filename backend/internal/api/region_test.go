@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ots-backend/internal/crypto"
+	"ots-backend/internal/models"
+)
+
+// inRegionEU serves region eu with us as its only known peer
+func inRegionEU(h *Handler) {
+	h.SetRegion("eu", map[string]string{"us": "https://us.ots.example"})
+}
+
+func TestRegionLocalSecret(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouterWithConfig(t, b, nil, inRegionEU)
+
+		secretID := createTestSecret(t, router, getMockCreateSecretRequest(nil))
+		if !strings.HasPrefix(secretID, "eu") || len(secretID) != 24 {
+			t.Fatalf("secret ID = %q, want 24 characters prefixed with eu", secretID)
+		}
+
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/"+secretID, nil))
+		if response.Code != http.StatusOK {
+			t.Fatalf("GetSecret() status = %d, want %d", response.Code, http.StatusOK)
+		}
+	})
+}
+
+func TestRegionLegacySecret(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		// Created before the deployment had a region
+		legacyID := createTestSecret(t, newTestRouter(t, b), getMockCreateSecretRequest(nil))
+		if len(legacyID) != 22 {
+			t.Fatalf("legacy secret ID = %q, want 22 characters", legacyID)
+		}
+
+		response := httptest.NewRecorder()
+		newTestRouterWithConfig(t, b, nil, inRegionEU).ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/"+legacyID, nil))
+		if response.Code != http.StatusOK {
+			t.Fatalf("GetSecret() legacy status = %d, want %d", response.Code, http.StatusOK)
+		}
+	})
+}
+
+func TestRegionForeignSecret(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouterWithConfig(t, b, nil, inRegionEU)
+
+		tests := []struct {
+			name    string
+			region  string
+			method  string
+			baseURL string
+		}{
+			{name: "consume from peer", region: "us", method: http.MethodGet, baseURL: "https://us.ots.example"},
+			{name: "burn from peer", region: "us", method: http.MethodDelete, baseURL: "https://us.ots.example"},
+			{name: "consume from unknown region", region: "ap", method: http.MethodGet},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				foreignID, err := crypto.GenerateRegionalSecretID(tt.region)
+				if err != nil {
+					t.Fatalf("GenerateRegionalSecretID() error: %v", err)
+				}
+
+				before := GetMetrics().WrongRegion
+				response := httptest.NewRecorder()
+				router.ServeHTTP(response, httptest.NewRequest(tt.method, "/api/secrets/"+foreignID, nil))
+				if response.Code != http.StatusMisdirectedRequest {
+					t.Fatalf("status = %d, want %d", response.Code, http.StatusMisdirectedRequest)
+				}
+
+				var errResp models.ErrorResponse
+				if err := json.NewDecoder(response.Body).Decode(&errResp); err != nil {
+					t.Fatalf("decode error response: %v", err)
+				}
+				if errResp.Code != "wrong_region" {
+					t.Errorf("code = %q, want wrong_region", errResp.Code)
+				}
+				if errResp.Region == nil || errResp.Region.Code != tt.region || errResp.Region.BaseURL != tt.baseURL {
+					t.Errorf("region = %+v, want code %q and base URL %q", errResp.Region, tt.region, tt.baseURL)
+				}
+				if got := GetMetrics().WrongRegion - before; got != 1 {
+					t.Errorf("wrong_region_total grew by %d, want 1", got)
+				}
+			})
+		}
+	})
+}
+
+func TestParseRegionPeers(t *testing.T) {
+	peers, err := ParseRegionPeers([]string{"us=https://us.ots.example/", " ap = http://ap.ots.example:8080"})
+	if err != nil {
+		t.Fatalf("ParseRegionPeers() error: %v", err)
+	}
+	if peers["us"] != "https://us.ots.example" || peers["ap"] != "http://ap.ots.example:8080" {
+		t.Errorf("ParseRegionPeers() = %v", peers)
+	}
+
+	for _, bad := range []string{"us", "US=https://us.ots.example", "us=us.ots.example", "us=ftp://us.ots.example"} {
+		if _, err := ParseRegionPeers([]string{bad}); err == nil {
+			t.Errorf("ParseRegionPeers(%q) = nil error, want error", bad)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/config"
+)
+
+// newReloadTestRouter serves a handler that follows configs
+func newReloadTestRouter(t *testing.T, b *testBackend, configs *config.Manager) (*Handler, http.Handler) {
+	t.Helper()
+
+	handler := NewHandler(b.store, configs.Current())
+	handler.SetConfigManager(configs)
+	router := chi.NewRouter()
+	router.Mount("/api", handler.Routes())
+	return handler, withSpecValidation(t, handler, router)
+}
+
+func TestReloadMaxSecretSize(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		configs := config.NewManager(auditTestConfig())
+		_, router := newReloadTestRouter(t, b, configs)
+
+		req := getMockCreateSecretRequest(nil)
+		req.Ciphertext = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("x", 2048)))
+		createTestSecret(t, router, req)
+
+		lowered := *configs.Current()
+		lowered.MaxSecretSize = 1024
+		configs.Set(&lowered)
+
+		if errResp := postErrorResponse(t, router, "/api/secrets", marshalJSON(t, req)); errResp.Code != "secret_too_large" {
+			t.Errorf("create after lowering MAX_SECRET_SIZE code = %q, want secret_too_large", errResp.Code)
+		}
+		if errResp := postErrorResponse(t, router, "/api/secrets", marshalJSON(t, req)); errResp.Limit == nil || errResp.Limit.Max != 1024 {
+			t.Errorf("create after lowering MAX_SECRET_SIZE limit = %+v, want max 1024", errResp.Limit)
+		}
+	})
+}
+
+func TestReloadRateLimitAndClientConfig(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		configs := config.NewManager(auditTestConfig())
+		_, router := newReloadTestRouter(t, b, configs)
+
+		getConfig := func() *httptest.ResponseRecorder {
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/config", nil))
+			return response
+		}
+		before := getConfig()
+
+		lowered := *configs.Current()
+		lowered.ReadRateLimitRequests = 1
+		lowered.MaxSecretSize = 1024
+		configs.Set(&lowered)
+
+		// The discovery document and its ETag follow the reload
+		after := getConfig()
+		if after.Header().Get("ETag") == before.Header().Get("ETag") || !strings.Contains(after.Body.String(), "1024") {
+			t.Errorf("client config after reload = %s (ETag %s), want the new limit and ETag", after.Body, after.Header().Get("ETag"))
+		}
+
+		// The read limiter applies the lowered budget to the next request
+		for i, want := range []int{http.StatusNotFound, http.StatusTooManyRequests} {
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/01ARZ3NDEKTSV4RRFFQ69G5FAV", nil))
+			if response.Code != want {
+				t.Errorf("read %d after reload status = %d, want %d", i+1, response.Code, want)
+			}
+		}
+	})
+}
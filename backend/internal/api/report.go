@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/logger"
+	"ots-backend/internal/models"
+	"ots-backend/internal/store"
+	"ots-backend/pkg/ots"
+	"ots-backend/pkg/webhook"
+)
+
+// reportReasons are the reason codes a report may carry; free text is
+// never accepted, so reports cannot smuggle secret content into the log
+var reportReasons = map[string]bool{
+	models.ReportUnexpectedContent:     true,
+	models.ReportSuspectedInterception: true,
+	models.ReportOther:                 true,
+}
+
+// ReportSecret lets a recipient flag a secret as possibly compromised, most
+// usefully after reading it. The answer is 202 whether or not the ID ever
+// existed; only reports for secrets the server has a trace of are recorded.
+func (h *Handler) ReportSecret(w http.ResponseWriter, r *http.Request) {
+	secretID := chi.URLParam(r, "id")
+
+	var req models.ReportSecretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !reportReasons[req.Reason] {
+		h.respondServiceError(w, ots.ErrInvalidRequestBody)
+		return
+	}
+
+	// A malformed ID is accepted like an unknown one
+	if h.validateSecretID(secretID) == nil {
+		h.recordReport(r.Context(), secretID, req.Reason)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// recordReport counts, audits and announces a report for a secret the
+// server knows. Failures are logged only; the reporter gets the same
+// answer either way.
+func (h *Handler) recordReport(ctx context.Context, secretID, reason string) {
+	known, err := h.secretKnown(ctx, secretID)
+	if err != nil {
+		logger.Error("failed to look up reported secret", "error", err, "secret_id", secretID)
+		return
+	}
+	if !known {
+		return
+	}
+
+	RecordSecretReported()
+	h.appendAudit(ctx, &store.AuditEvent{
+		Type:         store.AuditSecretReported,
+		SecretIDHash: store.HashSecretID(secretID),
+		Reason:       reason,
+	})
+	// The creator's address is usually gone with the secret, so the report
+	// goes to the webhook only
+	if h.notifier != nil {
+		h.notifier.Announce(webhook.EventReported, secretID, h.clock.Now().UTC())
+	}
+
+	logger.Warn("secret reported as compromised", "secret_id", secretID, "reason", reason)
+}
+
+// secretKnown reports whether secretID is stored, was consumed with a read
+// receipt, or appears in the audit log
+func (h *Handler) secretKnown(ctx context.Context, secretID string) (bool, error) {
+	if _, err := h.store.ManagementTokenHash(ctx, secretID); err == nil {
+		return true, nil
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return false, err
+	}
+
+	if _, err := h.store.Receipt(ctx, secretID); err == nil {
+		return true, nil
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return false, err
+	}
+
+	var found bool
+	err := h.store.ScanAudit(ctx, store.AuditFilter{SecretIDHashPrefix: store.HashSecretID(secretID), Limit: 1}, func(*store.AuditEvent) error {
+		found = true
+		return nil
+	})
+	return found, err
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/crypto"
+	"ots-backend/internal/notify"
+	"ots-backend/internal/store"
+	"ots-backend/internal/testutil"
+	"ots-backend/pkg/webhook"
+)
+
+// newReportTestRouter audits events and allows limit reports per hour
+func newReportTestRouter(t *testing.T, b *testBackend, limit int) http.Handler {
+	t.Helper()
+
+	cfg := auditTestConfig()
+	cfg.ReportRateLimitRequests = limit
+	cfg.ReportRateLimitWindow = time.Hour
+	handler := NewHandler(b.store, cfg)
+	handler.SetClock(testutil.NewFakeClock(time.Now()))
+
+	router := chi.NewRouter()
+	router.Mount("/api", handler.Routes())
+	return withSpecValidation(t, handler, router)
+}
+
+func postReport(router http.Handler, secretID, reason string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/api/secrets/"+secretID+"/report", strings.NewReader(`{"reason":"`+reason+`"}`))
+	request.Header.Set("Content-Type", "application/json")
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
+
+func TestReportConsumedSecret(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newReportTestRouter(t, b, 10)
+
+		secretID := createTestSecret(t, router, getMockCreateSecretRequest(nil))
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/"+secretID, nil))
+		if response.Code != http.StatusOK {
+			t.Fatalf("GetSecret() status = %d, want %d", response.Code, http.StatusOK)
+		}
+
+		before := GetMetrics().SecretsReported
+		if response := postReport(router, secretID, "suspected_interception"); response.Code != http.StatusAccepted {
+			t.Fatalf("report status = %d, want %d", response.Code, http.StatusAccepted)
+		}
+
+		page := getAuditPage(t, router, url.Values{"type": {store.AuditSecretReported}})
+		if len(page.Events) != 1 {
+			t.Fatalf("reported events = %d, want 1", len(page.Events))
+		}
+		if event := page.Events[0]; event.SecretIDHash != store.HashSecretID(secretID) || event.Reason != "suspected_interception" {
+			t.Errorf("reported event = %+v, want the secret's hash and reason", event)
+		}
+		if got := GetMetrics().SecretsReported - before; got != 1 {
+			t.Errorf("secrets_reported_total grew by %d, want 1", got)
+		}
+	})
+}
+
+func TestReportUnknownSecretIsIndistinguishable(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newReportTestRouter(t, b, 10)
+
+		unknownID, err := crypto.GenerateSecretID()
+		if err != nil {
+			t.Fatalf("GenerateSecretID() error: %v", err)
+		}
+
+		before := GetMetrics().SecretsReported
+		response := postReport(router, unknownID, "unexpected_content")
+		if response.Code != http.StatusAccepted || response.Body.Len() != 0 {
+			t.Fatalf("report of unknown secret = %d %q, want a bare 202", response.Code, response.Body)
+		}
+
+		if page := getAuditPage(t, router, url.Values{"type": {store.AuditSecretReported}}); len(page.Events) != 0 {
+			t.Errorf("reported events for an unknown secret = %d, want 0", len(page.Events))
+		}
+		if got := GetMetrics().SecretsReported - before; got != 0 {
+			t.Errorf("secrets_reported_total grew by %d, want 0", got)
+		}
+
+		if response := postReport(router, unknownID, "looked odd to me"); response.Code != http.StatusBadRequest {
+			t.Errorf("report with free-text reason status = %d, want %d", response.Code, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestReportRateLimit(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newReportTestRouter(t, b, 2)
+
+		unknownID, err := crypto.GenerateSecretID()
+		if err != nil {
+			t.Fatalf("GenerateSecretID() error: %v", err)
+		}
+
+		for i := 0; i < 2; i++ {
+			if response := postReport(router, unknownID, "other"); response.Code != http.StatusAccepted {
+				t.Fatalf("report %d status = %d, want %d", i+1, response.Code, http.StatusAccepted)
+			}
+		}
+		if response := postReport(router, unknownID, "other"); response.Code != http.StatusTooManyRequests {
+			t.Errorf("report over the limit status = %d, want %d", response.Code, http.StatusTooManyRequests)
+		}
+	})
+}
+
+func TestReportAnnouncedToWebhook(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		key := []byte("webhook-test-key")
+		deliveries := make(chan []byte, 10)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			if err := webhook.Verify(body, r.Header.Get(webhook.SignatureHeader), key); err != nil {
+				t.Errorf("Verify() error: %v", err)
+			}
+			deliveries <- body
+		}))
+		defer server.Close()
+
+		notifyKey, err := notify.ParseKey(notifyTestKey)
+		if err != nil {
+			t.Fatalf("ParseKey() error: %v", err)
+		}
+		d := notify.NewDispatcher(10)
+		d.Add("webhook", &notify.Webhook{Target: webhook.Target{URL: server.URL, Key: key}})
+		notifier := notify.NewService(notifyKey, d)
+		notifier.Start(context.Background())
+
+		router := newTestRouterWithConfig(t, b, withAudit, func(h *Handler) { h.SetNotifier(notifier) })
+
+		unknownID, err := crypto.GenerateSecretID()
+		if err != nil {
+			t.Fatalf("GenerateSecretID() error: %v", err)
+		}
+		postReport(router, unknownID, "other")
+
+		secretID := createTestSecret(t, router, getMockCreateSecretRequest(nil))
+		if response := postReport(router, secretID, "unexpected_content"); response.Code != http.StatusAccepted {
+			t.Fatalf("report status = %d, want %d", response.Code, http.StatusAccepted)
+		}
+		notifier.Stop()
+		close(deliveries)
+
+		// Only the known secret is announced, though its creator asked for no
+		// notice
+		var reported []string
+		for body := range deliveries {
+			payload, err := webhook.Decode(body)
+			if err != nil {
+				t.Fatalf("Decode() error: %v", err)
+			}
+			if payload.Event == webhook.EventReported {
+				reported = append(reported, payload.SecretID)
+			}
+		}
+		if len(reported) != 1 || reported[0] != secretID {
+			t.Errorf("reported deliveries = %v, want one for %s only", reported, secretID)
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"ots-backend/internal/logger"
+	"ots-backend/internal/reputation"
+	"ots-backend/internal/store"
+	"ots-backend/pkg/ots"
+)
+
+// reputationSyncInterval is how often client reputations are exchanged
+// with the store
+const reputationSyncInterval = 30 * time.Second
+
+const (
+	reputationDefaultLimit = 50
+	reputationMaxLimit     = 500
+)
+
+// ReputationResponse lists stored client reputations, lowest first
+type ReputationResponse struct {
+	Adaptive        bool               `json:"adaptive"`
+	HalfLifeSeconds int64              `json:"half_life_seconds"`
+	Ceiling         float64            `json:"ceiling"`
+	Floor           float64            `json:"floor"`
+	Clients         []ClientReputation `json:"clients"`
+}
+
+// ClientReputation is one client's score, decayed to now, and the factor it
+// applies to every rate limit budget
+type ClientReputation struct {
+	ClientKey   string    `json:"client_key"`
+	Score       float64   `json:"score"`
+	LimitFactor float64   `json:"limit_factor"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// reputationConfig returns the adaptive rate limit settings of the active
+// configuration
+func (h *Handler) reputationConfig() reputation.Config {
+	cfg := h.config()
+	return reputation.Config{
+		Enabled:  cfg.RateLimitAdaptive,
+		HalfLife: cfg.RateLimitHalfLife,
+		Limits:   reputation.Limits{Ceiling: cfg.RateLimitCeiling, Floor: cfg.RateLimitFloor},
+	}
+}
+
+// StartReputation shares client reputations with other replicas through
+// the store until ctx is done. Syncs are skipped while RATE_LIMIT_ADAPTIVE
+// is off. Call it after Routes.
+func (h *Handler) StartReputation(ctx context.Context) {
+	go reputation.Run(ctx, h.reputation, reputationSyncInterval)
+}
+
+// Reputation handles GET /api/admin/reputation, listing the stored client
+// reputations with the lowest scores, or with ip the one for that client.
+// Stored scores trail live traffic by up to one sync interval.
+func (h *Handler) Reputation(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := reputationDefaultLimit
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > reputationMaxLimit {
+			h.respondServiceError(w, ots.ErrInvalidReputationQuery)
+			return
+		}
+		limit = n
+	}
+
+	var scores []store.ClientScore
+	var err error
+	if value := query.Get("ip"); value != "" {
+		addr, parseErr := netip.ParseAddr(value)
+		if parseErr != nil {
+			h.respondServiceError(w, ots.ErrInvalidReputationQuery)
+			return
+		}
+		scores, err = h.store.ClientScores(r.Context(), []string{store.HashClientKey(addr.String())})
+	} else {
+		scores, err = h.store.LowestClientScores(r.Context(), limit)
+	}
+	if err != nil {
+		logger.Error("reputation: query failed", "error", err)
+		h.respondStoreError(w, err, "database error")
+		return
+	}
+
+	cfg := h.reputationConfig()
+	now := h.clock.Now()
+	clients := make([]ClientReputation, 0, len(scores))
+	for _, s := range scores {
+		score := reputation.Decay(s.Score, now.Sub(s.UpdatedAt), cfg.HalfLife)
+		clients = append(clients, ClientReputation{
+			ClientKey:   s.Key,
+			Score:       score,
+			LimitFactor: reputation.Factor(score, cfg.Limits),
+			UpdatedAt:   s.UpdatedAt.UTC(),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ReputationResponse{
+		Adaptive:        cfg.Enabled,
+		HalfLifeSeconds: int64(cfg.HalfLife.Seconds()),
+		Ceiling:         cfg.Ceiling,
+		Floor:           cfg.Floor,
+		Clients:         clients,
+	})
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/reputation"
+	"ots-backend/internal/store"
+	"ots-backend/internal/testutil"
+)
+
+func TestAdaptiveRateLimitTightensOnMisses(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		clk := testutil.NewFakeClock(time.Date(2026, 5, 14, 6, 0, 0, 0, time.UTC))
+		cfg := auditTestConfig()
+		cfg.ReadRateLimitRequests = 20
+		cfg.ReadRateLimitWindow = time.Minute
+		cfg.RateLimitAdaptive = true
+		cfg.RateLimitCeiling = 4
+		cfg.RateLimitFloor = 0.25
+		cfg.RateLimitHalfLife = 24 * time.Hour
+		handler := NewHandler(b.store, cfg)
+		handler.SetClock(clk)
+		router := chi.NewRouter()
+		router.Mount("/api", handler.Routes())
+		validated := withSpecValidation(t, handler, router)
+
+		// Probing unknown IDs shrinks the budget below the base 20
+		var limited *httptest.ResponseRecorder
+		for range 20 {
+			request := httptest.NewRequest(http.MethodGet, "/api/secrets/01ARZ3NDEKTSV4RRFFQ69G5FAV", nil)
+			request.RemoteAddr = "203.0.113.5:4000"
+			response := httptest.NewRecorder()
+			validated.ServeHTTP(response, request)
+			if response.Code == http.StatusTooManyRequests {
+				limited = response
+				break
+			}
+		}
+		if limited == nil {
+			t.Fatal("twenty misses were never rate limited")
+		}
+		if got, _ := strconv.Atoi(limited.Header().Get("X-RateLimit-Limit")); got >= 20 {
+			t.Errorf("X-RateLimit-Limit after misses = %d, want below 20", got)
+		}
+
+		if err := handler.reputation.Sync(context.Background()); err != nil {
+			t.Fatalf("Sync() error: %v", err)
+		}
+		response := adminRequest(validated, http.MethodGet, "/api/admin/reputation?ip=203.0.113.5")
+		if response.Code != http.StatusOK {
+			t.Fatalf("reputation status = %d, want %d: %s", response.Code, http.StatusOK, response.Body)
+		}
+		var listing ReputationResponse
+		if err := json.NewDecoder(response.Body).Decode(&listing); err != nil {
+			t.Fatalf("decode reputation: %v", err)
+		}
+		if !listing.Adaptive || len(listing.Clients) != 1 {
+			t.Fatalf("reputation = %+v, want adaptive limits and one client", listing)
+		}
+		client := listing.Clients[0]
+		if client.ClientKey != store.HashClientKey("203.0.113.5") || client.Score >= reputation.Neutral || client.LimitFactor >= 1 {
+			t.Errorf("client = %+v, want a score below neutral and a factor below 1", client)
+		}
+
+		for _, query := range []string{"limit=0", "limit=501", "limit=x", "ip=203.0.113"} {
+			if response := adminRequest(validated, http.MethodGet, "/api/admin/reputation?"+query); response.Code != http.StatusBadRequest {
+				t.Errorf("reputation?%s status = %d, want %d", query, response.Code, http.StatusBadRequest)
+			}
+		}
+	})
+}
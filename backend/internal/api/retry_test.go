@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"ots-backend/internal/models"
+	"ots-backend/internal/store"
+)
+
+// faultStore fails reads and burns with err, classifying it as the wrapped
+// backend would
+type faultStore struct {
+	store.Store
+	err error
+}
+
+func (f *faultStore) Consume(context.Context, string, store.ConsumeOptions) (*store.Secret, error) {
+	return nil, f.err
+}
+
+func (f *faultStore) Terminate(context.Context, string, store.TerminationReason, time.Time, int64, *store.Receipt) (*store.Secret, error) {
+	return nil, f.err
+}
+
+func (f *faultStore) Transient(err error) bool {
+	classifier, ok := f.Store.(store.Classifier)
+	return ok && classifier.Transient(err)
+}
+
+// sqliteError stands in for the SQLite driver's error, whose fields are
+// unexported
+type sqliteError int
+
+func (e sqliteError) Error() string { return fmt.Sprintf("sqlite error %d", int(e)) }
+func (e sqliteError) Code() int     { return int(e) }
+
+// backendFaults are the driver errors each backend raises for a lock held
+// by another writer, a constraint violation and a missing column
+var backendFaults = map[string]struct{ lock, integrity, schema error }{
+	"sqlite": {sqliteError(5), sqliteError(19), errors.New("SQL logic error: no such column: creator (1)")},
+	"postgres": {
+		&pgconn.PgError{Code: "40P01"},
+		&pgconn.PgError{Code: "23502"},
+		&pgconn.PgError{Code: "42703"},
+	},
+}
+
+func TestStoreFailureRetryHints(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		faults := backendFaults[b.name]
+		tests := []struct {
+			name      string
+			err       error
+			retryable bool
+		}{
+			{"timeout", fmt.Errorf("consume secret: %w", context.DeadlineExceeded), true},
+			{"pool exhausted", fmt.Errorf("failed to connect to `user=ots`: %w", context.DeadlineExceeded), true},
+			{"lock", faults.lock, true},
+			{"integrity", faults.integrity, false},
+			{"schema", faults.schema, false},
+		}
+
+		for _, tt := range tests {
+			if tt.err == nil {
+				continue
+			}
+			t.Run(tt.name, func(t *testing.T) {
+				handler := NewHandler(&faultStore{Store: b.store, err: fmt.Errorf("wrapped: %w", tt.err)}, auditTestConfig())
+				router := chi.NewRouter()
+				router.Mount("/api", handler.Routes())
+				validated := withSpecValidation(t, handler, router)
+				secretID := createTestSecret(t, validated, getMockCreateSecretRequest(nil))
+
+				for _, method := range []string{http.MethodGet, http.MethodDelete} {
+					response := httptest.NewRecorder()
+					validated.ServeHTTP(response, httptest.NewRequest(method, "/api/secrets/"+secretID, nil))
+					assertRetryHints(t, method, response, tt.retryable)
+				}
+			})
+		}
+	})
+}
+
+// assertRetryHints checks a 5xx response's status, envelope and Retry-After
+// against whether its failure is retryable
+func assertRetryHints(t *testing.T, method string, response *httptest.ResponseRecorder, retryable bool) {
+	t.Helper()
+
+	wantStatus, wantRetryAfter, wantMs := http.StatusInternalServerError, "", int64(0)
+	if retryable {
+		wantStatus, wantRetryAfter, wantMs = http.StatusServiceUnavailable, "2", storeRetryAfter.Milliseconds()
+	}
+	if response.Code != wantStatus {
+		t.Fatalf("%s status = %d, want %d: %s", method, response.Code, wantStatus, response.Body)
+	}
+	if got := response.Header().Get("Retry-After"); got != wantRetryAfter {
+		t.Errorf("%s Retry-After = %q, want %q", method, got, wantRetryAfter)
+	}
+
+	var body models.ErrorResponse
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		t.Fatalf("decode %s error: %v", method, err)
+	}
+	if body.Retryable == nil || *body.Retryable != retryable || body.RetryAfterMs != wantMs {
+		t.Errorf("%s envelope retryable = %v, retry_after_ms = %d; want %v, %d", method, body.Retryable, body.RetryAfterMs, retryable, wantMs)
+	}
+}
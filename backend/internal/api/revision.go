@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"ots-backend/internal/httpx"
+	"ots-backend/internal/models"
+	"ots-backend/internal/store"
+	"ots-backend/pkg/ots"
+)
+
+// revisionTag renders a secret's revision as the strong entity tag sent in
+// ETag and expected back in If-Match
+func revisionTag(revision int64) string {
+	return `"` + strconv.FormatInt(revision, 10) + `"`
+}
+
+// ifMatchRevision returns the revision r's If-Match makes a change
+// conditional on: zero, for none, without the header or with *. Anything
+// but a single revision tag, such as a weak tag or a list, is -1, which no
+// secret is at, so the change is refused.
+func ifMatchRevision(r *http.Request) int64 {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0
+	}
+	digits, ok := strings.CutPrefix(header, `"`)
+	if digits, ok = strings.CutSuffix(digits, `"`); !ok {
+		return -1
+	}
+	revision, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || revision < store.FirstRevision {
+		return -1
+	}
+	return revision
+}
+
+// respondRevisionMismatch refuses a change made conditional on a revision
+// the secret has moved past, naming the current one in ETag and the body
+func (h *Handler) respondRevisionMismatch(w http.ResponseWriter, mismatch *store.RevisionMismatchError) {
+	httpx.SetHeader(w.Header(), "ETag", revisionTag(mismatch.Current))
+	h.respondErrorBody(w, ots.StatusCode(ots.ErrRevisionMismatch), models.ErrorResponse{
+		Message:  ots.ErrRevisionMismatch.Error(),
+		Code:     ots.ErrorCode(ots.ErrRevisionMismatch),
+		Revision: mismatch.Current,
+	})
+}
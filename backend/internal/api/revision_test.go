@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ots-backend/internal/models"
+	"ots-backend/internal/testutil"
+)
+
+func burnIfMatch(router http.Handler, created models.CreateSecretResponse, ifMatch string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodDelete, "/api/secrets/"+created.ID, nil)
+	request.Header.Set(ManagementTokenHeader, created.ManagementToken)
+	request.Header.Set("If-Match", ifMatch)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
+
+func assertRevisionMismatch(t *testing.T, response *httptest.ResponseRecorder, current int64) {
+	t.Helper()
+
+	if response.Code != http.StatusPreconditionFailed {
+		t.Fatalf("status = %d, want %d", response.Code, http.StatusPreconditionFailed)
+	}
+	if got, want := response.Header().Get("ETag"), revisionTag(current); got != want {
+		t.Errorf("ETag = %q, want %q", got, want)
+	}
+	var body models.ErrorResponse
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		t.Fatalf("decode error body: %v", err)
+	}
+	if body.Code != "revision_mismatch" || body.Revision != current {
+		t.Errorf("error body = %+v, want revision_mismatch at %d", body, current)
+	}
+}
+
+func TestBurnIfMatch(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		router := newTestRouter(t, b)
+		created := createTestSecretResponse(t, router, getMockCreateSecretRequest(nil))
+
+		request := httptest.NewRequest(http.MethodGet, "/api/secrets/"+created.ID+"/receipt", nil)
+		request.Header.Set(ManagementTokenHeader, created.ManagementToken)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		if got := response.Header().Get("ETag"); got != `"1"` {
+			t.Fatalf("receipt ETag = %q, want %q", got, `"1"`)
+		}
+
+		for _, stale := range []string{`"7"`, `W/"1"`, `"1", "2"`} {
+			assertRevisionMismatch(t, burnIfMatch(router, created, stale), 1)
+		}
+		if status, receipt := getReceipt(t, router, created.ID, created.ManagementToken); status != http.StatusOK || receipt.State != "active" {
+			t.Fatalf("receipt after refused burns = %d %+v, want active", status, receipt)
+		}
+
+		if response := burnIfMatch(router, created, `"1"`); response.Code != http.StatusNoContent {
+			t.Fatalf("BurnSecret() at the current revision status = %d, want %d", response.Code, http.StatusNoContent)
+		}
+		status, receipt := getReceipt(t, router, created.ID, created.ManagementToken)
+		if status != http.StatusOK || receipt.Revision != 2 {
+			t.Fatalf("receipt after burn = %d %+v, want revision 2", status, receipt)
+		}
+	})
+}
+
+func TestAcknowledgeIfMatch(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		router := newTestRouterWithConfig(t, b, withAckWindow, withTestClock(testutil.NewFakeClock(time.Now())))
+		req := getMockCreateSecretRequest(nil)
+		req.RequireAck = true
+		secretID := createTestSecret(t, router, req)
+
+		secret := readAckSecret(t, router, secretID)
+		if secret.Revision != 2 {
+			t.Fatalf("GetSecret() revision = %d, want 2", secret.Revision)
+		}
+
+		ack := func(ifMatch string) *httptest.ResponseRecorder {
+			request := ackRequest(secretID, secret.AckToken)
+			request.Header.Set("If-Match", ifMatch)
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, request)
+			return response
+		}
+
+		assertRevisionMismatch(t, ack(`"1"`), 2)
+		assertReceiptAcknowledged(t, b, secretID, false)
+
+		if response := ack(revisionTag(secret.Revision)); response.Code != http.StatusNoContent {
+			t.Fatalf("AcknowledgeSecret() at the current revision status = %d, want %d", response.Code, http.StatusNoContent)
+		}
+		assertReceiptAcknowledged(t, b, secretID, true)
+	})
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ots-backend/internal/crypto"
+	"ots-backend/internal/models"
+	"ots-backend/internal/store"
+)
+
+// The web app tells passphrase secrets by the presence of salt, so a read
+// must carry salt exactly when one was stored, and never as ""
+
+const (
+	saltTestCiphertext = "dGVzdCBzZWNyZXQgZGF0YQ=="
+	saltTestIV         = "AAAAAAAAAAAAAAAA"
+	saltTestSalt       = "AAAAAAAAAAAAAAAAAAAAAA=="
+)
+
+func readSecretBody(t *testing.T, router http.Handler, secretID string) string {
+	t.Helper()
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/"+secretID, nil))
+	if response.Code != http.StatusOK {
+		t.Fatalf("GetSecret() status = %d, want %d", response.Code, http.StatusOK)
+	}
+	return response.Body.String()
+}
+
+func TestGetSecretSaltContract(t *testing.T) {
+	const (
+		withSalt    = `{"ciphertext":"` + saltTestCiphertext + `","iv":"` + saltTestIV + `","salt":"` + saltTestSalt + `"}` + "\n"
+		withoutSalt = `{"ciphertext":"` + saltTestCiphertext + `","iv":"` + saltTestIV + `"}` + "\n"
+	)
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "salt",
+			body: `{"ciphertext":"` + saltTestCiphertext + `","iv":"` + saltTestIV + `","salt":"` + saltTestSalt + `","expires_in":900,"burn_after_read":true}`,
+			want: withSalt,
+		},
+		{
+			name: "no salt key",
+			body: `{"ciphertext":"` + saltTestCiphertext + `","iv":"` + saltTestIV + `","expires_in":900,"burn_after_read":true}`,
+			want: withoutSalt,
+		},
+		{
+			name: "empty salt",
+			body: `{"ciphertext":"` + saltTestCiphertext + `","iv":"` + saltTestIV + `","salt":"","expires_in":900,"burn_after_read":true}`,
+			want: withoutSalt,
+		},
+	}
+
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				b.reset(t)
+				router := newTestRouter(t, b)
+
+				request := httptest.NewRequest(http.MethodPost, "/api/secrets", strings.NewReader(tt.body))
+				request.Header.Set("Content-Type", "application/json")
+				response := httptest.NewRecorder()
+				router.ServeHTTP(response, request)
+				if response.Code != http.StatusCreated {
+					t.Fatalf("CreateSecret() status = %d, want %d: %s", response.Code, http.StatusCreated, response.Body)
+				}
+				var created models.CreateSecretResponse
+				if err := json.Unmarshal(response.Body.Bytes(), &created); err != nil {
+					t.Fatalf("CreateSecret() decode error: %v", err)
+				}
+
+				if got := readSecretBody(t, router, created.ID); got != tt.want {
+					t.Errorf("GetSecret() body = %s, want %s", got, tt.want)
+				}
+			})
+		}
+	})
+}
+
+func TestGetSecretOmitsStoredEmptySalt(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouter(t, b)
+
+		// A salt stored as an empty value rather than NULL reads as absent
+		for _, salt := range [][]byte{nil, {}} {
+			id, err := crypto.GenerateSecretID()
+			if err != nil {
+				t.Fatalf("GenerateSecretID() error: %v", err)
+			}
+			now := time.Now()
+			err = b.store.Create(context.Background(), &store.Secret{
+				ID:            id,
+				Ciphertext:    []byte("test secret data"),
+				IV:            make([]byte, 12),
+				Salt:          salt,
+				ExpiresAt:     now.Add(time.Hour),
+				CreatedAt:     now,
+				BurnAfterRead: true,
+			})
+			if err != nil {
+				t.Fatalf("Create() error: %v", err)
+			}
+
+			if got := readSecretBody(t, router, id); strings.Contains(got, `"salt"`) {
+				t.Errorf("GetSecret() with stored salt %#v = %s, want no salt key", salt, got)
+			}
+		}
+	})
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ots-backend/internal/models"
+	"ots-backend/internal/scan"
+)
+
+// setScanRules installs rules for one test and clears them afterwards
+func setScanRules(t *testing.T, doc string) {
+	t.Helper()
+
+	rules, err := scan.ParseRules([]byte(doc))
+	if err != nil {
+		t.Fatalf("ParseRules() error: %v", err)
+	}
+	scan.SetRules(rules)
+	t.Cleanup(func() { scan.SetRules(nil) })
+}
+
+// decodeViolation decodes a 422 policy_violation response
+func decodeViolation(t *testing.T, response *httptest.ResponseRecorder) *scan.Violation {
+	t.Helper()
+
+	if response.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d: %s", response.Code, http.StatusUnprocessableEntity, response.Body.String())
+	}
+	var errResp models.ErrorResponse
+	if err := json.NewDecoder(response.Body).Decode(&errResp); err != nil {
+		t.Fatalf("decode error response: %v", err)
+	}
+	if errResp.Code != "policy_violation" {
+		t.Fatalf("code = %q, want policy_violation", errResp.Code)
+	}
+	if errResp.Violation == nil {
+		t.Fatal("policy_violation response has no violation")
+	}
+	return errResp.Violation
+}
+
+func TestPolicyViolationOnPartLabel(t *testing.T) {
+	setScanRules(t, `{"rules": [
+		{"name": "no-tickets", "type": "deny_regex", "fields": ["part_label"], "pattern": "(?i)INC-\\d+"},
+		{"name": "corp-links", "type": "allow_domains", "domains": ["example.com"]}
+	]}`)
+
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouter(t, b)
+
+		iv := base64.StdEncoding.EncodeToString(make([]byte, 12))
+		withLabel := func(label string) models.CreateSecretRequest {
+			return models.CreateSecretRequest{
+				Parts: []models.SecretPart{
+					{Label: "username", Ciphertext: base64.StdEncoding.EncodeToString([]byte("alice")), IV: iv},
+					{Label: label, Ciphertext: base64.StdEncoding.EncodeToString([]byte("hunter2")), IV: iv},
+				},
+				ExpiresIn:     int((15 * time.Minute).Seconds()),
+				BurnAfterRead: true,
+			}
+		}
+
+		createTestSecret(t, router, withLabel("password from https://wiki.example.com"))
+
+		tests := []struct {
+			label string
+			rule  string
+		}{
+			{label: "password for INC-4821", rule: "no-tickets"},
+			{label: "see https://pastebin.com/raw/x", rule: "corp-links"},
+		}
+		for _, tt := range tests {
+			response := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, "/api/secrets", strings.NewReader(marshalJSON(t, withLabel(tt.label))))
+			request.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(response, request)
+
+			v := decodeViolation(t, response)
+			if v.Rule != tt.rule || v.Field != scan.FieldPartLabel {
+				t.Errorf("label %q: violation = %+v, want rule %s on part_label", tt.label, v, tt.rule)
+			}
+		}
+	})
+}
+
+func TestPolicyViolationOnAgentFilename(t *testing.T) {
+	setScanRules(t, `{"rules": [{"name": "no-executables", "type": "deny_regex", "fields": ["filename"], "pattern": "(?i)\\.(exe|bat)$"}]}`)
+
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouter(t, b)
+
+		upload := func(filename string) *httptest.ResponseRecorder {
+			var body bytes.Buffer
+			writer := multipart.NewWriter(&body)
+			fileWriter, err := writer.CreateFormFile("file", filename)
+			if err != nil {
+				t.Fatalf("CreateFormFile() error: %v", err)
+			}
+			if _, err := fileWriter.Write([]byte("MZ")); err != nil {
+				t.Fatalf("fileWriter.Write() error: %v", err)
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatalf("writer.Close() error: %v", err)
+			}
+
+			response := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, "/api/agent/secrets", &body)
+			request.Header.Set("Content-Type", writer.FormDataContentType())
+			router.ServeHTTP(response, request)
+			return response
+		}
+
+		if response := upload("notes.txt"); response.Code != http.StatusCreated {
+			t.Fatalf("allowed upload status = %d, want %d", response.Code, http.StatusCreated)
+		}
+
+		v := decodeViolation(t, upload("Setup.EXE"))
+		if v.Rule != "no-executables" || v.Field != scan.FieldFilename {
+			t.Errorf("violation = %+v, want rule no-executables on filename", v)
+		}
+	})
+}
+
+func TestScannersNeverSeeCiphertext(t *testing.T) {
+	var mu sync.Mutex
+	var seen []scan.Field
+	unregister := scan.Register(scan.ScannerFunc(func(field scan.Field) *scan.Violation {
+		mu.Lock()
+		defer mu.Unlock()
+		seen = append(seen, field)
+		return nil
+	}))
+	t.Cleanup(unregister)
+
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouter(t, b)
+
+		iv := base64.StdEncoding.EncodeToString(make([]byte, 12))
+		ciphertext := base64.StdEncoding.EncodeToString([]byte("opaque-ciphertext"))
+		createTestSecret(t, router, models.CreateSecretRequest{
+			Parts:         []models.SecretPart{{Label: "api key", Ciphertext: ciphertext, IV: iv}},
+			ExpiresIn:     int((15 * time.Minute).Seconds()),
+			BurnAfterRead: true,
+		})
+		createTestSecret(t, router, getMockCreateSecretRequest(nil))
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(seen) == 0 {
+			t.Fatal("custom scanner saw no fields")
+		}
+		for _, field := range seen {
+			if field.Name != scan.FieldPartLabel && field.Name != scan.FieldFilename {
+				t.Errorf("scanner saw field %q", field.Name)
+			}
+			if strings.Contains(field.Value, ciphertext) || strings.Contains(field.Value, iv) {
+				t.Errorf("scanner saw encrypted material in %q", field.Name)
+			}
+		}
+		seen = nil
+	})
+}
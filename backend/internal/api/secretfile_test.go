@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"ots-backend/internal/models"
+)
+
+func TestSecretContentTypeAndFilename(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouter(t, b)
+
+		req := getMockCreateSecretRequest(nil)
+		req.ContentType = " Application/JSON "
+		req.Filename = " vpn staging é.json "
+		secretID := createTestSecret(t, router, req)
+
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodHead, "/api/secrets/"+secretID, nil))
+		encoded, ok := strings.CutPrefix(response.Header().Get(FilenameHeader), "UTF-8''")
+		filename, err := url.PathUnescape(encoded)
+		if response.Code != http.StatusOK || !ok || err != nil || filename != "vpn staging é.json" {
+			t.Errorf("HEAD = %d with filename %q, want %d with the trimmed name", response.Code, response.Header().Get(FilenameHeader), http.StatusOK)
+		}
+		if got := response.Header().Get(ContentTypeHeader); got != "application/json" {
+			t.Errorf("HEAD content type = %q, want application/json", got)
+		}
+
+		response = httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/"+secretID, nil))
+		var secret models.GetSecretResponse
+		if err := json.NewDecoder(response.Body).Decode(&secret); err != nil {
+			t.Fatalf("GET body: %v", err)
+		}
+		if secret.ContentType != "application/json" || secret.Filename != "vpn staging é.json" {
+			t.Errorf("GET = content type %q, filename %q; want application/json and the trimmed name", secret.ContentType, secret.Filename)
+		}
+
+		// Secrets without either send neither
+		plainID := createTestSecret(t, router, getMockCreateSecretRequest(nil))
+		response = httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodHead, "/api/secrets/"+plainID, nil))
+		for _, header := range []string{ContentTypeHeader, FilenameHeader} {
+			if _, ok := response.Header()[header]; ok {
+				t.Errorf("HEAD of a plain secret sent %s %q", header, response.Header().Get(header))
+			}
+		}
+	})
+}
+
+func TestSecretContentTypeAndFilenameRejected(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouter(t, b)
+
+		for _, tt := range []struct {
+			name        string
+			contentType string
+			filename    string
+			wantCode    string
+		}{
+			{name: "html", contentType: "text/html", wantCode: "invalid_content_type"},
+			{name: "parameters", contentType: "text/plain; charset=utf-8", wantCode: "invalid_content_type"},
+			{name: "image", contentType: "image/png", wantCode: "invalid_content_type"},
+			{name: "path", filename: "../etc/passwd", wantCode: "invalid_filename"},
+			{name: "windows path", filename: `C:\keys\id_rsa`, wantCode: "invalid_filename"},
+			{name: "control character", filename: "key\n.pem", wantCode: "invalid_filename"},
+			{name: "too long", filename: strings.Repeat("a", 256), wantCode: "invalid_filename"},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				req := getMockCreateSecretRequest(nil)
+				req.ContentType = tt.contentType
+				req.Filename = tt.filename
+				errResp := postErrorResponse(t, router, "/api/secrets", marshalJSON(t, req))
+				if errResp.Code != tt.wantCode {
+					t.Errorf("create = %+v, want %s", errResp, tt.wantCode)
+				}
+			})
+		}
+	})
+}
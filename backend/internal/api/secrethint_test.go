@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/models"
+	"ots-backend/internal/policy"
+)
+
+func TestSecretHint(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouter(t, b)
+
+		req := getMockCreateSecretRequest(nil)
+		req.Hint = " VPN password for <ACME> staging\r\n"
+		const want = "VPN password for &lt;ACME&gt; staging"
+		secretID := createTestSecret(t, router, req)
+
+		// HEAD shows the hint and leaves the secret readable
+		for range 2 {
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, httptest.NewRequest(http.MethodHead, "/api/secrets/"+secretID, nil))
+			encoded, ok := strings.CutPrefix(response.Header().Get(HintHeader), "UTF-8''")
+			hint, err := url.PathUnescape(encoded)
+			if response.Code != http.StatusOK || !ok || err != nil || hint != want {
+				t.Fatalf("HEAD = %d with hint %q, want %d with %q", response.Code, response.Header().Get(HintHeader), http.StatusOK, want)
+			}
+		}
+
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/"+secretID, nil))
+		var secret models.GetSecretResponse
+		if err := json.NewDecoder(response.Body).Decode(&secret); err != nil || secret.Hint != want {
+			t.Fatalf("GET hint = %q, %v; want %q", secret.Hint, err, want)
+		}
+
+		// Secrets without a hint send none
+		plainID := createTestSecret(t, router, getMockCreateSecretRequest(nil))
+		response = httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodHead, "/api/secrets/"+plainID, nil))
+		if _, ok := response.Header()[HintHeader]; ok {
+			t.Errorf("HEAD of a secret without a hint sent %s %q", HintHeader, response.Header().Get(HintHeader))
+		}
+	})
+}
+
+func TestSecretHintTooLong(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouter(t, b)
+
+		req := getMockCreateSecretRequest(nil)
+		req.Hint = strings.Repeat("é", policy.DefaultMaxHint+1)
+		errResp := postErrorResponse(t, router, "/api/secrets", marshalJSON(t, req))
+		if errResp.Code != "invalid_hint" || errResp.Limit == nil || errResp.Limit.Name != policy.LimitHint {
+			t.Errorf("create with a %d-character hint = %+v, want invalid_hint with the hint limit", policy.DefaultMaxHint+1, errResp)
+		}
+	})
+}
+
+func TestSecretHintRemovedOnRead(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		if b.queryRow == nil {
+			t.Skip("the memory backend has no rows to inspect")
+		}
+		b.reset(t)
+
+		// A shredded secret's row outlives its read; the hint must not
+		for _, shredding := range []bool{false, true} {
+			router := newTestRouterWithConfig(t, b, func(cfg *config.Config) {
+				cfg.CryptoShredding = shredding
+			})
+			req := getMockCreateSecretRequest(nil)
+			req.Hint = "deploy key"
+			secretID := createTestSecret(t, router, req)
+			if status := getSecretStatus(router, secretID); status != http.StatusOK {
+				t.Fatalf("GET status = %d, want %d", status, http.StatusOK)
+			}
+
+			var hints int
+			if err := b.queryRow(context.Background(), `SELECT COUNT(*) FROM secrets WHERE hint IS NOT NULL`).Scan(&hints); err != nil {
+				t.Fatalf("count hints: %v", err)
+			}
+			if hints != 0 {
+				t.Errorf("crypto shredding %v: %d stored hints after the read, want 0", shredding, hints)
+			}
+		}
+	})
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"encoding/base64"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"ots-backend/internal/store"
+)
+
+// maxPooledBuffer is the largest response buffer returned to the pool, so
+// one oversized secret does not stay pinned in memory
+const maxPooledBuffer = 1 << 20
+
+// secretBuffers holds GetSecret response buffers. Ciphertexts are base64
+// encoded straight into them, so a read allocates no intermediate strings.
+// They are zeroed before they are put back.
+var secretBuffers = sync.Pool{
+	New: func() any { return new([]byte) },
+}
+
+// writeSecretResponse writes secret as a GetSecretResponse body, with the
+// ack fields when ackExpiresAt is set. The bytes match what json.Encoder
+// writes for the equivalent models.GetSecretResponse.
+func writeSecretResponse(w io.Writer, secret *store.Secret, ackToken string, ackExpiresAt *time.Time) error {
+	bufp := secretBuffers.Get().(*[]byte)
+	buf := (*bufp)[:0]
+	if size := secretResponseSize(secret, ackToken); cap(buf) < size {
+		buf = make([]byte, 0, size)
+	}
+
+	buf = appendSecretResponse(buf, secret, ackToken, ackExpiresAt)
+	_, err := w.Write(buf)
+
+	// The body holds the ciphertext and ack token; neither may outlive the
+	// response in a buffer another request is handed
+	clear(buf[:cap(buf)])
+	if cap(buf) <= maxPooledBuffer {
+		*bufp = buf
+		secretBuffers.Put(bufp)
+	}
+	return err
+}
+
+// secretResponseSize is an upper bound on the body length for secret, so
+// the buffer is sized once
+func secretResponseSize(secret *store.Secret, ackToken string) int {
+	enc := base64.StdEncoding
+	// Keys, punctuation, the ack deadline, the revision and the trailing
+	// newline
+	size := 200 + enc.EncodedLen(len(secret.Ciphertext)) + enc.EncodedLen(len(secret.IV)) +
+		enc.EncodedLen(len(secret.Salt)) + 6*len(ackToken) + 6*len(secret.Hint) +
+		6*len(secret.ContentType) + 6*len(secret.Filename)
+	for _, part := range secret.Parts {
+		// An escaped label is at most six bytes per input byte
+		size += 48 + 6*len(part.Label) + enc.EncodedLen(len(part.Ciphertext)) + enc.EncodedLen(len(part.IV))
+	}
+	return size
+}
+
+// appendSecretResponse appends the JSON body for secret to dst, following
+// the field order and omitempty rules of models.GetSecretResponse
+func appendSecretResponse(dst []byte, secret *store.Secret, ackToken string, ackExpiresAt *time.Time) []byte {
+	enc := base64.StdEncoding
+	dst = append(dst, '{')
+	first := true
+	key := func(name string) {
+		if !first {
+			dst = append(dst, ',')
+		}
+		first = false
+		dst = append(dst, '"')
+		dst = append(dst, name...)
+		dst = append(dst, `":`...)
+	}
+	base64Field := func(name string, value []byte) {
+		if len(value) == 0 {
+			return
+		}
+		key(name)
+		dst = append(dst, '"')
+		dst = enc.AppendEncode(dst, value)
+		dst = append(dst, '"')
+	}
+
+	base64Field("ciphertext", secret.Ciphertext)
+	base64Field("iv", secret.IV)
+	base64Field("salt", secret.Salt)
+
+	if len(secret.Parts) > 0 {
+		key("parts")
+		dst = append(dst, '[')
+		for i, part := range secret.Parts {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = append(dst, `{"label":`...)
+			dst = appendJSONString(dst, part.Label)
+			dst = append(dst, `,"ciphertext":"`...)
+			dst = enc.AppendEncode(dst, part.Ciphertext)
+			dst = append(dst, `","iv":"`...)
+			dst = enc.AppendEncode(dst, part.IV)
+			dst = append(dst, `"}`...)
+		}
+		dst = append(dst, ']')
+	}
+
+	if secret.IVEmbedded {
+		key("iv_embedded")
+		dst = append(dst, "true"...)
+	}
+	if ackToken != "" {
+		key("ack_token")
+		dst = appendJSONString(dst, ackToken)
+	}
+	if ackExpiresAt != nil {
+		key("ack_expires_at")
+		dst = append(dst, '"')
+		dst = ackExpiresAt.AppendFormat(dst, time.RFC3339Nano)
+		dst = append(dst, '"')
+	}
+	if ackToken != "" && secret.Revision != 0 {
+		key("revision")
+		dst = strconv.AppendInt(dst, secret.Revision, 10)
+	}
+	if secret.Hint != "" {
+		key("hint")
+		dst = appendJSONString(dst, secret.Hint)
+	}
+	if secret.ContentType != "" {
+		key("content_type")
+		dst = appendJSONString(dst, secret.ContentType)
+	}
+	if secret.Filename != "" {
+		key("filename")
+		dst = appendJSONString(dst, secret.Filename)
+	}
+
+	// json.Encoder ends every value with a newline
+	return append(dst, "}\n"...)
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends s as a quoted JSON string, escaped the way
+// json.Encoder does by default: HTML characters, U+2028, U+2029 and
+// control characters are escaped and invalid UTF-8 is replaced by U+FFFD
+func appendJSONString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				dst = append(dst, '\\', b)
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
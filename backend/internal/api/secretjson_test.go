@@ -0,0 +1,169 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"ots-backend/internal/models"
+	"ots-backend/internal/store"
+)
+
+// encodeSecretResponse is the encoding/json path writeSecretResponse
+// replaced, kept as the reference its output must match
+func encodeSecretResponse(w io.Writer, secret *store.Secret, ackToken string, ackExpiresAt *time.Time) error {
+	resp := models.GetSecretResponse{
+		Ciphertext:   base64.StdEncoding.EncodeToString(secret.Ciphertext),
+		IV:           base64.StdEncoding.EncodeToString(secret.IV),
+		Salt:         base64.StdEncoding.EncodeToString(secret.Salt),
+		IVEmbedded:   secret.IVEmbedded,
+		AckToken:     ackToken,
+		AckExpiresAt: ackExpiresAt,
+		Hint:         secret.Hint,
+		ContentType:  secret.ContentType,
+		Filename:     secret.Filename,
+	}
+	if ackToken != "" {
+		resp.Revision = secret.Revision
+	}
+	for _, part := range secret.Parts {
+		resp.Parts = append(resp.Parts, models.SecretPart{
+			Label:      part.Label,
+			Ciphertext: base64.StdEncoding.EncodeToString(part.Ciphertext),
+			IV:         base64.StdEncoding.EncodeToString(part.IV),
+		})
+	}
+	return json.NewEncoder(w).Encode(resp)
+}
+
+func assertSameEncoding(t *testing.T, secret *store.Secret, ackToken string, ackExpiresAt *time.Time) {
+	t.Helper()
+
+	var got, want bytes.Buffer
+	if err := writeSecretResponse(&got, secret, ackToken, ackExpiresAt); err != nil {
+		t.Fatalf("writeSecretResponse() error: %v", err)
+	}
+	if err := encodeSecretResponse(&want, secret, ackToken, ackExpiresAt); err != nil {
+		t.Fatalf("json encoding error: %v", err)
+	}
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Errorf("writeSecretResponse() = %s, want %s", got.Bytes(), want.Bytes())
+	}
+}
+
+func TestSecretResponseMatchesEncoder(t *testing.T) {
+	deadline := time.Date(2026, 5, 14, 9, 30, 0, 123456789, time.UTC)
+	tests := []struct {
+		name         string
+		secret       *store.Secret
+		ackToken     string
+		ackExpiresAt *time.Time
+	}{
+		{name: "empty", secret: &store.Secret{}},
+		{name: "plain", secret: &store.Secret{Ciphertext: []byte("ciphertext"), IV: []byte("twelve bytes")}},
+		{name: "salted", secret: &store.Secret{Ciphertext: []byte{0, 1, 2}, IV: []byte{3}, Salt: []byte("salt")}},
+		{name: "empty salt", secret: &store.Secret{Ciphertext: []byte{0, 1, 2}, IV: []byte{3}, Salt: []byte{}}},
+		{name: "embedded IV", secret: &store.Secret{Ciphertext: []byte("nonce+ciphertext"), IVEmbedded: true}},
+		{name: "ack", secret: &store.Secret{Ciphertext: []byte("x"), IV: []byte("y"), Revision: 2}, ackToken: "tok_abc-123", ackExpiresAt: &deadline},
+		{name: "hint", secret: &store.Secret{Ciphertext: []byte("x"), IV: []byte("y"), Hint: "VPN for ACME &amp; \u00e9 \u2028"}},
+		{name: "file", secret: &store.Secret{Ciphertext: []byte("x"), IV: []byte("y"), ContentType: "application/json", Filename: "vpn <staging> é.json"}},
+		{name: "parts", secret: &store.Secret{Parts: []store.Part{
+			{Label: "username", Ciphertext: []byte("a"), IV: []byte("b")},
+			{Label: "<html> & \"quotes\" \\ \u00e9 \u2603 \u2028\t\x00\xff", Ciphertext: []byte("cc"), IV: []byte("dd")},
+			{Label: "", Ciphertext: nil, IV: nil},
+		}}},
+		{name: "large", secret: &store.Secret{Ciphertext: bytes.Repeat([]byte{0xfe}, 1<<16), IV: []byte("iv")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertSameEncoding(t, tt.secret, tt.ackToken, tt.ackExpiresAt)
+		})
+	}
+}
+
+func FuzzSecretResponse(f *testing.F) {
+	f.Add([]byte("ciphertext"), []byte("iv"), []byte(""), "label", "", false, int64(0))
+	f.Add([]byte{}, []byte{}, []byte("salt"), "<&>\"\\\u2029\x1f\xc3", "token", true, int64(1778751000123456789))
+
+	f.Fuzz(func(t *testing.T, ciphertext, iv, salt []byte, label, ackToken string, embedded bool, deadlineNanos int64) {
+		secret := &store.Secret{Ciphertext: ciphertext, IV: iv, Salt: salt, IVEmbedded: embedded}
+		if label != "" {
+			secret.Parts = []store.Part{{Label: label, Ciphertext: ciphertext, IV: iv}}
+		}
+		var ackExpiresAt *time.Time
+		if deadlineNanos > 0 {
+			deadline := time.Unix(0, deadlineNanos).UTC()
+			ackExpiresAt = &deadline
+		}
+
+		assertSameEncoding(t, secret, ackToken, ackExpiresAt)
+
+		// The output is JSON that decodes back to the secret
+		var buf bytes.Buffer
+		if err := writeSecretResponse(&buf, secret, ackToken, ackExpiresAt); err != nil {
+			t.Fatalf("writeSecretResponse() error: %v", err)
+		}
+		var resp models.GetSecretResponse
+		if err := json.Unmarshal(buf.Bytes(), &resp); err != nil {
+			t.Fatalf("decode %s: %v", buf.Bytes(), err)
+		}
+		if resp.Ciphertext != base64.StdEncoding.EncodeToString(ciphertext) || resp.IVEmbedded != embedded {
+			t.Errorf("decoded %+v, want the fuzzed secret", resp)
+		}
+	})
+}
+
+func BenchmarkGetSecretResponse(b *testing.B) {
+	deadline := time.Now().UTC()
+	secret := &store.Secret{
+		Ciphertext: []byte(strings.Repeat("x", 4096)),
+		IV:         []byte("twelve bytes"),
+		Salt:       []byte("sixteen byte salt"),
+		RequireAck: true,
+	}
+
+	b.Run("encoding/json", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			encodeSecretResponse(io.Discard, secret, "ack-token", &deadline)
+		}
+	})
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			writeSecretResponse(io.Discard, secret, "ack-token", &deadline)
+		}
+	})
+}
+
+// TestSecretBuffersAreScrubbed checks a buffer back in the pool holds
+// nothing of the response it served
+func TestSecretBuffersAreScrubbed(t *testing.T) {
+	secret := &store.Secret{
+		Ciphertext: bytes.Repeat([]byte("secret material "), 64),
+		IV:         make([]byte, 12),
+	}
+
+	// The pool may drop a buffer, as it does at random under the race
+	// detector, so try a few times to get one back
+	for range 20 {
+		if err := writeSecretResponse(io.Discard, secret, "ack-token", nil); err != nil {
+			t.Fatalf("writeSecretResponse() error: %v", err)
+		}
+		bufp := secretBuffers.Get().(*[]byte)
+		buf := (*bufp)[:cap(*bufp)]
+		if len(buf) == 0 {
+			continue
+		}
+		if i := bytes.IndexFunc(buf, func(r rune) bool { return r != 0 }); i >= 0 {
+			t.Fatalf("pooled buffer holds %q at %d, want only zeros", buf[i:min(i+32, len(buf))], i)
+		}
+		return
+	}
+	t.Skip("the pool kept no buffer")
+}
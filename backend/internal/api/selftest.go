@@ -0,0 +1,150 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"ots-backend/internal/logger"
+	"ots-backend/internal/store"
+)
+
+// SelfTestTimeout bounds a whole self-test run
+const SelfTestTimeout = 10 * time.Second
+
+// selfTestCleanupTimeout bounds removing the secret of a failed run, which
+// may have failed by running out of time
+const selfTestCleanupTimeout = 2 * time.Second
+
+// Self-test step and report status values
+const (
+	selfTestOK      = "ok"
+	selfTestFailed  = "failed"
+	selfTestSkipped = "skipped"
+	selfTestPassed  = "passed"
+)
+
+// SelfTestStep is the outcome of one self-test step
+type SelfTestStep struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	DurationMs float64 `json:"duration_ms"`
+	// Error says why the step failed; GET /api/health/deep leaves it out
+	// and logs it instead
+	Error string `json:"error,omitempty"`
+}
+
+// SelfTestReport is the outcome of a self-test run
+type SelfTestReport struct {
+	Status     string         `json:"status"`
+	Backend    string         `json:"backend"`
+	DurationMs float64        `json:"duration_ms"`
+	Steps      []SelfTestStep `json:"steps"`
+}
+
+// Passed reports whether every step succeeded
+func (r SelfTestReport) Passed() bool {
+	return r.Status == selfTestPassed
+}
+
+// SelfTest writes a throwaway secret to the configured store, reads it
+// back through the same wrapping and unwrapping as the secret handlers,
+// checks the bytes, then burns what is left and checks a second read finds
+// nothing. Like the canary it stays out of user stats under
+// store.CanaryIDPrefix. A store whose migrations have not run fails the
+// create step. The run stops at SelfTestTimeout; steps after a failure are
+// skipped.
+func (h *Handler) SelfTest(ctx context.Context) SelfTestReport {
+	ctx, cancel := context.WithTimeout(ctx, SelfTestTimeout)
+	defer cancel()
+
+	report := SelfTestReport{Status: selfTestPassed, Backend: h.config().StorageBackend}
+	started := time.Now()
+
+	secret, payload, iv, buildErr := h.newCanarySecret()
+	var got *store.Secret
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"create", func() error {
+			if buildErr != nil {
+				return buildErr
+			}
+			return h.store.Create(ctx, secret)
+		}},
+		{"read", func() error {
+			var err error
+			got, err = h.store.Consume(ctx, secret.ID, store.ConsumeOptions{Now: h.clock.Now(), Open: h.unwrapSecret})
+			return err
+		}},
+		{"verify", func() error {
+			if !bytes.Equal(got.Ciphertext, payload) || !bytes.Equal(got.IV, iv) {
+				return errors.New("read back different bytes")
+			}
+			return nil
+		}},
+		{"burn", func() error {
+			if _, err := h.store.Burn(ctx, secret.ID); err != nil {
+				return err
+			}
+			if _, err := h.store.Consume(ctx, secret.ID, store.ConsumeOptions{Now: h.clock.Now()}); !errors.Is(err, store.ErrNotFound) {
+				return fmt.Errorf("second read returned %v, want not found", err)
+			}
+			return nil
+		}},
+	}
+
+	for _, step := range steps {
+		result := SelfTestStep{Name: step.name, Status: selfTestSkipped}
+		if report.Status == selfTestPassed {
+			stepStarted := time.Now()
+			stepErr := step.run()
+			result.DurationMs = durationMs(time.Since(stepStarted))
+			result.Status = selfTestOK
+			if stepErr != nil {
+				result.Status = selfTestFailed
+				result.Error = stepErr.Error()
+				report.Status = selfTestFailed
+			}
+		}
+		report.Steps = append(report.Steps, result)
+	}
+
+	// A secret stranded by a failed step must not stay readable until it
+	// expires
+	if report.Status != selfTestPassed && secret != nil {
+		cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), selfTestCleanupTimeout)
+		h.store.Burn(cleanupCtx, secret.ID)
+		cancel()
+	}
+	report.DurationMs = durationMs(time.Since(started))
+	return report
+}
+
+// DeepHealth runs SelfTest for GET /api/health/deep: 200 when it passes,
+// 503 when it does not. Step errors are logged, not returned, as in the
+// other health checks. It suits a Kubernetes startup probe, which holds
+// traffic back until the store takes a write.
+func (h *Handler) DeepHealth(w http.ResponseWriter, r *http.Request) {
+	report := h.SelfTest(r.Context())
+
+	statusCode := http.StatusOK
+	if !report.Passed() {
+		statusCode = http.StatusServiceUnavailable
+	}
+	for i, step := range report.Steps {
+		if step.Error != "" {
+			logger.Warn("self-test step failed", "step", step.Name, "error", step.Error)
+			report.Steps[i].Error = ""
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/config"
+)
+
+// deepHealth fetches GET /api/health/deep and decodes its report
+func deepHealth(t *testing.T, router http.Handler) (int, SelfTestReport) {
+	t.Helper()
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/health/deep", nil))
+	var report SelfTestReport
+	if err := json.NewDecoder(response.Body).Decode(&report); err != nil {
+		t.Fatalf("decode self-test report: %v", err)
+	}
+	return response.Code, report
+}
+
+func TestDeepHealthRunsSelfTest(t *testing.T) {
+	for _, shredding := range []bool{false, true} {
+		forEachBackend(t, func(t *testing.T, b *testBackend) {
+			b.reset(t)
+			faulty := &faultyStore{Store: b.store}
+			cfg := auditTestConfig()
+			cfg.CryptoShredding = shredding
+			handler := NewHandler(faulty, cfg)
+			router := chi.NewRouter()
+			router.Mount("/api", handler.Routes())
+			checked := withSpecValidation(t, handler, router)
+
+			code, report := deepHealth(t, checked)
+			if code != http.StatusOK || !report.Passed() || len(report.Steps) != 4 {
+				t.Fatalf("self-test = %d %+v, want 200 with four passing steps", code, report)
+			}
+			for _, step := range report.Steps {
+				if step.Status != selfTestOK {
+					t.Errorf("step %s = %s, want ok", step.Name, step.Status)
+				}
+			}
+			if n, err := b.store.CountActive(t.Context()); err != nil || n != 0 {
+				t.Errorf("CountActive() after self-test = %d, %v; want nothing left", n, err)
+			}
+
+			// A store refusing writes fails the create step and skips the
+			// rest, without its error reaching the response
+			faulty.set(true, false)
+			code, report = deepHealth(t, checked)
+			if code != http.StatusServiceUnavailable || report.Passed() {
+				t.Fatalf("self-test with failing creates = %d %+v, want 503", code, report)
+			}
+			want := []string{selfTestFailed, selfTestSkipped, selfTestSkipped, selfTestSkipped}
+			for i, step := range report.Steps {
+				if step.Status != want[i] || step.Error != "" {
+					t.Errorf("step %s = %s %q, want %s without an error", step.Name, step.Status, step.Error, want[i])
+				}
+			}
+
+			// Bytes read back wrong fail verify; the report keeps the reason
+			faulty.set(false, true)
+			report = handler.SelfTest(t.Context())
+			if report.Passed() || report.Steps[2].Status != selfTestFailed || report.Steps[2].Error == "" {
+				t.Errorf("self-test reading corrupt bytes = %+v, want verify failed with its reason", report)
+			}
+		})
+	}
+}
+
+func TestSelfTestReportsBackend(t *testing.T) {
+	handler := NewHandler(testBackends[0].store, &config.Config{StorageBackend: config.StorageMemory})
+	if report := handler.SelfTest(t.Context()); report.Backend != config.StorageMemory || !report.Passed() {
+		t.Errorf("SelfTest() = %+v, want a passing run on %s", report, config.StorageMemory)
+	}
+}
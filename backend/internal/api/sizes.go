@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+
+	"ots-backend/internal/logger"
+	"ots-backend/internal/sizestats"
+	"ots-backend/internal/store"
+)
+
+// SizeBucketSummary is the number of secrets created in one size class
+type SizeBucketSummary struct {
+	UpperBoundBytes int64 `json:"upper_bound_bytes"`
+	Count           int64 `json:"count"`
+}
+
+// recordSize counts a create in its size bucket, in process for metrics and
+// in the store for admin stats. The exact size stays in the secret's row.
+func (h *Handler) recordSize(ctx context.Context, size int) {
+	bound := sizestats.Bucket(size)
+	RecordSecretSize(bound)
+	if err := h.store.RecordSizeBucket(ctx, bound); err != nil {
+		logger.Warn("failed to record size bucket", "error", err, "upper_bound", bound)
+	}
+}
+
+// sizeNoise is the noise published size counts carry: none unless the
+// active configuration is in strict privacy mode
+func (h *Handler) sizeNoise() *sizestats.Noise {
+	if !h.config().StrictPrivacy {
+		return nil
+	}
+	return sizestats.NewNoise(h.config().SizeStatsEpsilon)
+}
+
+// loggedSize is the size a create logs: exact, or only its bucket in strict
+// privacy mode, where logs must not keep sizes the aggregates hide
+func (h *Handler) loggedSize(size int) int64 {
+	if h.config().StrictPrivacy {
+		return sizestats.Bucket(size)
+	}
+	return int64(size)
+}
+
+// sizeSummaries renders buckets for the admin stats body
+func sizeSummaries(buckets []store.SizeBucket) []SizeBucketSummary {
+	summaries := make([]SizeBucketSummary, 0, len(buckets))
+	for _, bucket := range buckets {
+		summaries = append(summaries, SizeBucketSummary{UpperBoundBytes: bucket.UpperBound, Count: bucket.Count})
+	}
+	return summaries
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/config"
+)
+
+func newSizeTestRouter(t *testing.T, b *testBackend, cfg *config.Config) http.Handler {
+	t.Helper()
+
+	handler := NewHandler(b.store, cfg)
+	router := chi.NewRouter()
+	router.Mount("/api", handler.Routes())
+	return withSpecValidation(t, handler, router)
+}
+
+// createSized creates a secret with a ciphertext of size bytes
+func createSized(t *testing.T, router http.Handler, size int) {
+	t.Helper()
+
+	req := getMockCreateSecretRequest(nil)
+	req.Ciphertext = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("x", size)))
+	createTestSecret(t, router, req)
+}
+
+func getSizeHistogram(t *testing.T, router http.Handler) map[string]int64 {
+	t.Helper()
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/metrics", nil))
+	var metrics MetricsResponse
+	if err := json.NewDecoder(response.Body).Decode(&metrics); err != nil {
+		t.Fatalf("decode metrics: %v", err)
+	}
+	return metrics.SecretSizes
+}
+
+// cumulativeAt reads a cumulative histogram at le, which need not be one of
+// its bounds
+func cumulativeAt(histogram map[string]int64, le int64) int64 {
+	var at, count int64
+	for key, n := range histogram {
+		bound, err := strconv.ParseInt(key, 10, 64)
+		if err == nil && bound <= le && bound >= at {
+			at, count = bound, n
+		}
+	}
+	return count
+}
+
+func TestSizeBucketsInStatsAndMetrics(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newSizeTestRouter(t, b, auditTestConfig())
+
+		before := getSizeHistogram(t, router)
+		for _, size := range []int{16, 100, 128, 129} {
+			createSized(t, router, size)
+		}
+
+		stats := getAdminStats(t, router)
+		want := []SizeBucketSummary{{UpperBoundBytes: 16, Count: 1}, {UpperBoundBytes: 128, Count: 2}, {UpperBoundBytes: 256, Count: 1}}
+		if !slices.Equal(stats.SecretSizes, want) || stats.SizeNoiseBound != 0 {
+			t.Errorf("secret_sizes = %+v with noise bound %d, want %+v exactly", stats.SecretSizes, stats.SizeNoiseBound, want)
+		}
+
+		// The histogram is cumulative and counts this process's creates
+		after := getSizeHistogram(t, router)
+		for le, grew := range map[int64]int64{16: 1, 128: 3, 256: 4} {
+			if got := cumulativeAt(after, le) - cumulativeAt(before, le); got != grew {
+				t.Errorf("secret_size_bytes_bucket at %d grew by %d, want %d", le, got, grew)
+			}
+		}
+		if got := after["+Inf"] - before["+Inf"]; got != 4 {
+			t.Errorf("secret_size_bytes_bucket[+Inf] grew by %d, want 4", got)
+		}
+	})
+}
+
+func TestSizeStatsStrictPrivacy(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		cfg := auditTestConfig()
+		cfg.StrictPrivacy = true
+		cfg.SizeStatsEpsilon = 1
+		router := newSizeTestRouter(t, b, cfg)
+
+		for range 3 {
+			createSized(t, router, 500)
+		}
+
+		stats := getAdminStats(t, router)
+		if stats.SizeNoiseBound != 5 {
+			t.Fatalf("secret_sizes_noise_bound = %d, want 5 for epsilon 1", stats.SizeNoiseBound)
+		}
+		if len(stats.SecretSizes) != 1 || stats.SecretSizes[0].UpperBoundBytes != 512 {
+			t.Fatalf("secret_sizes = %+v, want one 512-byte bucket", stats.SecretSizes)
+		}
+		if got := stats.SecretSizes[0].Count; got < 0 || got > 3+stats.SizeNoiseBound {
+			t.Errorf("noisy count = %d, want within 0..%d", got, 3+stats.SizeNoiseBound)
+		}
+
+		// Asking again must not draw fresh noise to average out
+		if again := getAdminStats(t, router); !slices.Equal(again.SecretSizes, stats.SecretSizes) {
+			t.Errorf("second secret_sizes = %+v, want %+v", again.SecretSizes, stats.SecretSizes)
+		}
+	})
+}
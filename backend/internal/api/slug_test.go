@@ -0,0 +1,100 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/netclass"
+)
+
+// newSlugTestRouter allows slugs and keeps read receipts
+func newSlugTestRouter(t *testing.T, b *testBackend) http.Handler {
+	t.Helper()
+
+	cfg := auditTestConfig()
+	cfg.AllowSlugs = true
+	handler := NewHandler(b.store, cfg)
+	handler.SetClassifier(netclass.New(nil, ""))
+
+	router := chi.NewRouter()
+	router.Mount("/api", handler.Routes())
+	return withSpecValidation(t, handler, router)
+}
+
+func getSecretStatus(router http.Handler, id string) int {
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/"+id, nil))
+	return response.Code
+}
+
+func TestSlugCreateAndRead(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newSlugTestRouter(t, b)
+
+		req := getMockCreateSecretRequest(nil)
+		req.Slug = "release-signing-key"
+		if id := createTestSecret(t, router, req); id != req.Slug {
+			t.Fatalf("created ID = %q, want the slug %q", id, req.Slug)
+		}
+
+		// A stored slug is taken
+		if errResp := postErrorResponse(t, router, "/api/secrets", marshalJSON(t, req)); errResp.Code != "slug_taken" {
+			t.Errorf("create with a stored slug code = %q, want slug_taken", errResp.Code)
+		}
+
+		if status := getSecretStatus(router, req.Slug); status != http.StatusOK {
+			t.Fatalf("GetSecret() by slug status = %d, want %d", status, http.StatusOK)
+		}
+		if status := getSecretStatus(router, req.Slug); status != http.StatusNotFound {
+			t.Errorf("second GetSecret() by slug status = %d, want %d", status, http.StatusNotFound)
+		}
+
+		// So is a read one while its receipt is kept
+		if errResp := postErrorResponse(t, router, "/api/secrets", marshalJSON(t, req)); errResp.Code != "slug_taken" {
+			t.Errorf("create with a receipted slug code = %q, want slug_taken", errResp.Code)
+		}
+
+		// Generated IDs still work alongside slugs
+		id := createTestSecret(t, router, getMockCreateSecretRequest(nil))
+		if status := getSecretStatus(router, id); status != http.StatusOK {
+			t.Errorf("GetSecret() by generated ID status = %d, want %d", status, http.StatusOK)
+		}
+	})
+}
+
+func TestSlugValidation(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newSlugTestRouter(t, b)
+
+		for _, slug := range []string{"short", "Release-Key", "release_key", "abcdefghijklmnopqrstuv"} {
+			req := getMockCreateSecretRequest(nil)
+			req.Slug = slug
+			if errResp := postErrorResponse(t, router, "/api/secrets", marshalJSON(t, req)); errResp.Code != "invalid_slug" {
+				t.Errorf("create with slug %q code = %q, want invalid_slug", slug, errResp.Code)
+			}
+		}
+	})
+}
+
+func TestSlugsDisabled(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouter(t, b)
+
+		req := getMockCreateSecretRequest(nil)
+		req.Slug = "release-signing-key"
+		if errResp := postErrorResponse(t, router, "/api/secrets", marshalJSON(t, req)); errResp.Code != "invalid_slug" {
+			t.Errorf("create with slugs disabled code = %q, want invalid_slug", errResp.Code)
+		}
+
+		// Slug-shaped IDs read as unknown rather than reaching the store
+		if status := getSecretStatus(router, "release-signing-key"); status != http.StatusNotFound {
+			t.Errorf("GetSecret() by slug with slugs disabled status = %d, want %d", status, http.StatusNotFound)
+		}
+	})
+}
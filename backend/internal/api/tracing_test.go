@@ -0,0 +1,103 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"ots-backend/internal/tracing"
+)
+
+// storeSpans is the span tree each backend adds under a create and a read
+var storeSpans = map[string][2]string{
+	"memory": {"", ""},
+	"sqlite": {
+		"  sqlite.create\n    sqlite.commit\n",
+		"  sqlite.consume\n    sqlite.commit\n",
+	},
+	// The integration pool is opened without statement tracing
+	"postgres": {"  postgres.create\n", "  postgres.consume\n"},
+}
+
+func TestTracingSpanTree(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+
+		exporter := tracetest.NewInMemoryExporter()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		tracing.SetProvider(provider)
+		t.Cleanup(func() { tracing.SetProvider(nil) })
+
+		handler := NewHandler(b.store, auditTestConfig())
+		router := chi.NewRouter()
+		router.Use(chimiddleware.RequestID)
+		router.Use(tracing.Middleware)
+		router.Mount("/api", handler.Routes())
+
+		secretID := createTestSecret(t, router, getMockCreateSecretRequest(nil))
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/"+secretID, nil))
+		if response.Code != http.StatusOK {
+			t.Fatalf("read status = %d, want %d", response.Code, http.StatusOK)
+		}
+
+		spans := exporter.GetSpans()
+		want := "POST /api/secrets\n  create.decode\n  create.validate\n" + storeSpans[b.name][0] +
+			"GET /api/secrets/{id}\n" + storeSpans[b.name][1]
+		if got := spanTree(spans); got != want {
+			t.Errorf("span tree:\n%s\nwant:\n%s", got, want)
+		}
+
+		for _, span := range spans {
+			attrs := map[string]string{}
+			for _, attr := range span.Attributes {
+				attrs[string(attr.Key)] = attr.Value.Emit()
+				if strings.Contains(attr.Value.Emit(), secretID) {
+					t.Errorf("%s: %s = %q carries the full secret ID", span.Name, attr.Key, attr.Value.Emit())
+				}
+			}
+			if span.Parent.IsValid() {
+				if prefix, ok := attrs[string(tracing.SecretIDPrefixKey)]; ok && prefix != secretID[:tracing.SecretIDPrefixLen] {
+					t.Errorf("%s: secret ID prefix = %q, want %q", span.Name, prefix, secretID[:tracing.SecretIDPrefixLen])
+				}
+				continue
+			}
+			if attrs[string(tracing.RequestIDKey)] == "" {
+				t.Errorf("%s: no request ID attribute in %v", span.Name, attrs)
+			}
+		}
+	})
+}
+
+// spanTree renders spans as one line per span, children indented under
+// their parent in start order
+func spanTree(spans tracetest.SpanStubs) string {
+	slices.SortFunc(spans, func(a, b tracetest.SpanStub) int { return a.StartTime.Compare(b.StartTime) })
+	children := map[string][]tracetest.SpanStub{}
+	for _, span := range spans {
+		parent := ""
+		if span.Parent.IsValid() {
+			parent = span.Parent.SpanID().String()
+		}
+		children[parent] = append(children[parent], span)
+	}
+
+	var tree strings.Builder
+	var walk func(parent string, depth int)
+	walk = func(parent string, depth int) {
+		for _, span := range children[parent] {
+			fmt.Fprintf(&tree, "%s%s\n", strings.Repeat("  ", depth), span.Name)
+			walk(span.SpanContext.SpanID().String(), depth+1)
+		}
+	}
+	walk("", 0)
+	return tree.String()
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"ots-backend/internal/logger"
+	"ots-backend/internal/models"
+	"ots-backend/internal/validation"
+	"ots-backend/pkg/ots"
+)
+
+// ValidateSecret runs a create's size and TTL checks without generating an
+// ID or touching the store, so a client can learn a large file would be
+// refused before encrypting it. It takes a full create or only size and
+// expires_in. Only error codes are logged, never the body.
+func (h *Handler) ValidateSecret(w http.ResponseWriter, r *http.Request) {
+	var req models.ValidateSecretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		err = bodyError(err)
+		logger.Debug("dry-run validation rejected", "code", ots.ErrorCode(err), "ip", r.RemoteAddr)
+		h.respondServiceError(w, err)
+		return
+	}
+
+	resp, err := h.validateDryRun(&req)
+	if err != nil {
+		logger.Debug("dry-run validation rejected", "code", ots.ErrorCode(err), "ip", r.RemoteAddr)
+		h.respondServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// validateDryRun checks req as CreateSecret would and returns the TTL and
+// size it resolves to
+func (h *Handler) validateDryRun(req *models.ValidateSecretRequest) (*models.ValidateSecretResponse, error) {
+	var ttl time.Duration
+	var size int
+	if req.Ciphertext != "" || len(req.Parts) > 0 {
+		validated, err := h.validatePayload(&req.CreateSecretRequest)
+		if err != nil {
+			return nil, err
+		}
+		ttl, size = validated.ExpiresIn, validated.Size()
+	} else {
+		if req.Size == 0 {
+			return nil, fmt.Errorf("%w: ciphertext or size is required", validation.ErrInvalidCiphertext)
+		}
+		if err := validation.ValidateSize(req.Size, h.policy()); err != nil {
+			return nil, err
+		}
+		var err error
+		ttl, err = validation.ValidateTTL(req.ExpiresIn, h.policy())
+		if err != nil {
+			return nil, err
+		}
+		size = req.Size
+	}
+
+	return &models.ValidateSecretResponse{
+		ExpiresIn: int(ttl / time.Second),
+		ExpiresAt: h.clock.Now().Add(ttl).Truncate(time.Second).UTC(),
+		Size:      size,
+	}, nil
+}
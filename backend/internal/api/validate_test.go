@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/models"
+	"ots-backend/pkg/ots"
+)
+
+func TestValidateSecretEndpoint(t *testing.T) {
+	validCiphertext := base64.StdEncoding.EncodeToString([]byte("test secret data"))
+	validIV := base64.StdEncoding.EncodeToString(make([]byte, 12))
+	validSalt := base64.StdEncoding.EncodeToString(make([]byte, 16))
+
+	tests := []struct {
+		name       string
+		ciphertext string
+		iv         string
+		salt       string
+		size       int
+		expiresIn  int
+		maxSize    int
+		wantSize   int
+		wantErr    error
+	}{
+		{name: "valid request", ciphertext: validCiphertext, iv: validIV, salt: validSalt, expiresIn: 3600, maxSize: 32768, wantSize: 16},
+		{name: "valid request without salt", ciphertext: validCiphertext, iv: validIV, expiresIn: 3600, maxSize: 32768, wantSize: 16},
+		{name: "empty ciphertext", iv: validIV, expiresIn: 3600, maxSize: 32768, wantErr: ots.ErrInvalidCiphertext},
+		{name: "invalid ciphertext base64", ciphertext: "!!!not-valid-base64!!!", iv: validIV, expiresIn: 3600, maxSize: 32768, wantErr: ots.ErrInvalidCiphertext},
+		{name: "empty IV", ciphertext: validCiphertext, expiresIn: 3600, maxSize: 32768, wantErr: ots.ErrInvalidIV},
+		{name: "invalid IV size", ciphertext: validCiphertext, iv: base64.StdEncoding.EncodeToString(make([]byte, 8)), expiresIn: 3600, maxSize: 32768, wantErr: ots.ErrInvalidIV},
+		{name: "secret too large", ciphertext: base64.StdEncoding.EncodeToString(make([]byte, 100)), iv: validIV, expiresIn: 3600, maxSize: 50, wantErr: ots.ErrSecretTooLarge},
+		{name: "TTL too short", ciphertext: validCiphertext, iv: validIV, expiresIn: 60, maxSize: 32768, wantErr: ots.ErrInvalidTTL},
+		{name: "TTL too long", ciphertext: validCiphertext, iv: validIV, expiresIn: int(25 * time.Hour.Seconds()), maxSize: 32768, wantErr: ots.ErrInvalidTTL},
+		{name: "valid size only", size: 20000, expiresIn: 3600, maxSize: 32768, wantSize: 20000},
+		{name: "size too large", size: 100, expiresIn: 3600, maxSize: 50, wantErr: ots.ErrSecretTooLarge},
+		{name: "size with TTL too long", size: 100, expiresIn: int(25 * time.Hour.Seconds()), maxSize: 32768, wantErr: ots.ErrInvalidTTL},
+	}
+
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				b.reset(t)
+				router := newTestRouterWithConfig(t, b, func(cfg *config.Config) { cfg.MaxSecretSize = tt.maxSize })
+
+				body := marshalJSON(t, models.ValidateSecretRequest{
+					CreateSecretRequest: models.CreateSecretRequest{Ciphertext: tt.ciphertext, IV: tt.iv, Salt: tt.salt, ExpiresIn: tt.expiresIn},
+					Size:                tt.size,
+				})
+				request := httptest.NewRequest(http.MethodPost, "/api/secrets/validate", strings.NewReader(body))
+				request.Header.Set("Content-Type", "application/json")
+				response := httptest.NewRecorder()
+				router.ServeHTTP(response, request)
+
+				if tt.wantErr != nil {
+					if response.Code != ots.StatusCode(tt.wantErr) {
+						t.Fatalf("status = %d, want %d: %s", response.Code, ots.StatusCode(tt.wantErr), response.Body.String())
+					}
+					var errResp models.ErrorResponse
+					if err := json.NewDecoder(response.Body).Decode(&errResp); err != nil {
+						t.Fatalf("decode error response: %v", err)
+					}
+					if errResp.Code != ots.ErrorCode(tt.wantErr) {
+						t.Errorf("code = %q, want %q", errResp.Code, ots.ErrorCode(tt.wantErr))
+					}
+					return
+				}
+
+				if response.Code != http.StatusOK {
+					t.Fatalf("status = %d, want %d: %s", response.Code, http.StatusOK, response.Body.String())
+				}
+				var got models.ValidateSecretResponse
+				if err := json.NewDecoder(response.Body).Decode(&got); err != nil {
+					t.Fatalf("decode response: %v", err)
+				}
+				if got.ExpiresIn != tt.expiresIn || got.Size != tt.wantSize || got.ExpiresAt.IsZero() {
+					t.Errorf("response = %+v, want expires_in %d and size %d", got, tt.expiresIn, tt.wantSize)
+				}
+
+				// A dry run stores nothing
+				if n, err := b.store.CountActive(request.Context()); err != nil || n != 0 {
+					t.Errorf("CountActive() = %d, %v; want 0", n, err)
+				}
+			})
+		}
+	})
+}
+
+func TestValidateSecretRateLimitIsHalfWeight(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		router := newTestRouterWithConfig(t, b, func(cfg *config.Config) { cfg.WriteRateLimitRequests = 2 })
+
+		body := marshalJSON(t, models.ValidateSecretRequest{CreateSecretRequest: models.CreateSecretRequest{ExpiresIn: 3600}, Size: 100})
+		for i := range 5 {
+			request := httptest.NewRequest(http.MethodPost, "/api/secrets/validate", strings.NewReader(body))
+			request.Header.Set("Content-Type", "application/json")
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, request)
+
+			want := http.StatusOK
+			if i == 4 {
+				want = http.StatusTooManyRequests
+			}
+			if response.Code != want {
+				t.Fatalf("dry run %d status = %d, want %d", i+1, response.Code, want)
+			}
+		}
+	})
+}
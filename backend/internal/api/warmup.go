@@ -0,0 +1,150 @@
+package api
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"ots-backend/internal/logger"
+	"ots-backend/internal/store"
+)
+
+// warmupStep is one unit of startup warm-up. Steps run in priority order:
+// the ones that matter most for first-request latency come first, so a
+// deadline cuts off the least useful work. The first step is a store round
+// trip, and readiness waits for it to succeed.
+type warmupStep struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// warmupRetryInterval is how often the first warm-up step is tried again
+// after it failed or was cut off by the deadline
+const warmupRetryInterval = time.Second
+
+// warmupSteps lists the warm-up work for this handler
+func (h *Handler) warmupSteps() []warmupStep {
+	var steps []warmupStep
+
+	if warmer, ok := h.store.(store.Warmer); ok {
+		steps = append(steps, warmupStep{name: "connections", run: warmer.Warm})
+	}
+
+	steps = append(steps,
+		warmupStep{name: "active_count", run: func(ctx context.Context) error {
+			count, err := h.store.CountActive(ctx)
+			if err != nil {
+				return err
+			}
+			SetActiveSecrets(count)
+			return nil
+		}},
+		warmupStep{name: "config", run: func(ctx context.Context) error {
+			h.renderClientConfig()
+			return nil
+		}},
+		warmupStep{name: "crypto", run: func(ctx context.Context) error {
+			h.CryptoAttestation()
+			return nil
+		}},
+		warmupStep{name: "openapi", run: func(ctx context.Context) error {
+			_, err := h.renderOpenAPI()
+			return err
+		}},
+	)
+
+	return steps
+}
+
+// StartWarmUp moves the handler to ReadinessWarmingUp, so readiness reports
+// 503, and primes connections and caches in the background. Warm-up ends
+// when every step has run or timeout passes, whichever is first. The
+// handler then goes ready once the first step, a store round trip, has
+// succeeded, retrying it every warmupRetryInterval until it does. The
+// returned channel is closed when the handler goes ready or ctx is done.
+func (h *Handler) StartWarmUp(ctx context.Context, timeout time.Duration) <-chan struct{} {
+	h.readiness.Advance(ReadinessWarmingUp)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		start := time.Now()
+		timedOut, queried := h.warmUp(ctx, timeout)
+		duration := time.Since(start)
+		RecordWarmup(duration, timedOut)
+
+		switch {
+		case !queried:
+			logger.Warn("store did not answer warm-up, not ready until it does", "timeout", timeout, "duration", duration)
+			if !h.retryFirstWarmupStep(ctx, timeout) {
+				return
+			}
+		case timedOut:
+			logger.Warn("warm-up deadline exceeded, serving anyway", "timeout", timeout, "duration", duration)
+		default:
+			logger.Info("warm-up complete", "duration", duration)
+		}
+		h.readiness.Advance(ReadinessReady)
+	}()
+
+	return done
+}
+
+// warmUp runs the steps until done or the deadline passes. It reports
+// whether the deadline cut it short and whether the first step succeeded.
+func (h *Handler) warmUp(ctx context.Context, timeout time.Duration) (timedOut, queried bool) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var firstOK atomic.Bool
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		for i, step := range h.warmupSteps() {
+			if ctx.Err() != nil {
+				return
+			}
+			stepStart := time.Now()
+			if err := step.run(ctx); err != nil {
+				logger.Warn("warm-up step failed", "step", step.name, "error", err)
+				continue
+			}
+			if i == 0 {
+				firstOK.Store(true)
+			}
+			logger.Debug("warm-up step done", "step", step.name, "duration", time.Since(stepStart))
+		}
+	}()
+
+	// A step blocked on a dead dependency must not hold warm-up hostage
+	select {
+	case <-finished:
+		return false, firstOK.Load()
+	case <-ctx.Done():
+		return true, firstOK.Load()
+	}
+}
+
+// retryFirstWarmupStep repeats the first warm-up step, each try bounded by
+// timeout, until it succeeds or ctx is done, and reports whether it
+// succeeded
+func (h *Handler) retryFirstWarmupStep(ctx context.Context, timeout time.Duration) bool {
+	step := h.warmupSteps()[0]
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(warmupRetryInterval):
+		}
+
+		stepCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := step.run(stepCtx)
+		cancel()
+		if err == nil {
+			logger.Info("store answered warm-up", "step", step.name)
+			return true
+		}
+		logger.Debug("warm-up step failed again", "step", step.name, "error", err)
+	}
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/store"
+)
+
+// warmingStore blocks Warm until release is closed
+type warmingStore struct {
+	store.Store
+	release chan struct{}
+}
+
+func (s *warmingStore) Warm(ctx context.Context) error {
+	select {
+	case <-s.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func newWarmupRouter(st store.Store) (*Handler, chi.Router) {
+	handler := NewHandler(st, &config.Config{})
+	router := chi.NewRouter()
+	router.Mount("/api", handler.Routes())
+	return handler, router
+}
+
+func getHealth(t *testing.T, router http.Handler, path string) (int, HealthCheckResponse) {
+	t.Helper()
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var body HealthCheckResponse
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		t.Fatalf("decode %s: %v", path, err)
+	}
+	return response.Code, body
+}
+
+func TestReadinessWaitsForWarmUp(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		st := &warmingStore{Store: b.store, release: make(chan struct{})}
+		handler, router := newWarmupRouter(st)
+
+		done := handler.StartWarmUp(context.Background(), time.Minute)
+
+		code, body := getHealth(t, router, HealthAliasReady)
+		if code != http.StatusServiceUnavailable || body.Status != "warming_up" {
+			t.Fatalf("ready during warm-up = %d %q, want 503 warming_up", code, body.Status)
+		}
+		if body.Checks["warmup"] != "in_progress" {
+			t.Errorf("warmup check = %q, want in_progress", body.Checks["warmup"])
+		}
+
+		// Liveness and the plain health alias do not wait for warm-up
+		if code, _ := getHealth(t, router, HealthAliasLive); code != http.StatusOK {
+			t.Errorf("live during warm-up = %d, want 200", code)
+		}
+		if _, body := getHealth(t, router, HealthAliasAPI); body.Status == "warming_up" {
+			t.Error("/api/health reports warming_up, want only readiness to")
+		}
+
+		close(st.release)
+		<-done
+
+		wantCode, _ := getHealth(t, router, HealthAliasAPI)
+		code, body = getHealth(t, router, HealthAliasReady)
+		if code != wantCode || body.Status == "warming_up" {
+			t.Fatalf("ready after warm-up = %d %q, want %d like /api/health", code, body.Status, wantCode)
+		}
+		if GetMetrics().WarmupTimedOut {
+			t.Error("WarmupTimedOut = true, want false")
+		}
+	})
+}
+
+func TestWarmUpDeadlineWaitsForStore(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		st := &warmingStore{Store: b.store, release: make(chan struct{})}
+		handler, router := newWarmupRouter(st)
+
+		done := handler.StartWarmUp(context.Background(), 20*time.Millisecond)
+
+		// Past the deadline, the store has still not answered
+		time.Sleep(100 * time.Millisecond)
+		if code, body := getHealth(t, router, HealthAliasReady); code != http.StatusServiceUnavailable || body.Status != "warming_up" {
+			t.Fatalf("ready after the deadline without a store answer = %d %q, want 503 warming_up", code, body.Status)
+		}
+
+		close(st.release)
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("not ready after the store answered")
+		}
+
+		if _, body := getHealth(t, router, HealthAliasReady); body.Readiness != "ready" {
+			t.Fatalf("readiness after the store answered = %q, want ready", body.Readiness)
+		}
+
+		metrics := GetMetrics()
+		if !metrics.WarmupTimedOut {
+			t.Error("WarmupTimedOut = false, want true")
+		}
+		if metrics.WarmupDurationMs < 20 {
+			t.Errorf("WarmupDurationMs = %d, want at least the 20ms deadline", metrics.WarmupDurationMs)
+		}
+	})
+}
+
+func TestWarmUpPrimesCaches(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b *testBackend) {
+		b.reset(t)
+		createTestSecret(t, newTestRouter(t, b), getMockCreateSecretRequest(nil))
+		SetActiveSecrets(0)
+
+		handler, _ := newWarmupRouter(b.store)
+		<-handler.StartWarmUp(context.Background(), time.Minute)
+
+		if got := GetMetrics().ActiveSecrets; got != 1 {
+			t.Errorf("ActiveSecrets after warm-up = %d, want 1", got)
+		}
+		if handler.current().openAPIJSON == nil {
+			t.Error("OpenAPI document not rendered during warm-up")
+		}
+	})
+}
@@ -0,0 +1,23 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"ots-backend/internal/logger"
+	"ots-backend/pkg/webhook"
+)
+
+// WebhookSchema returns the webhook payload JSON Schemas and signed examples
+func (h *Handler) WebhookSchema(w http.ResponseWriter, r *http.Request) {
+	doc, err := webhook.BuildDocument()
+	if err != nil {
+		logger.Error("failed to build webhook schema document", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to build schema")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	json.NewEncoder(w).Encode(doc)
+}
@@ -0,0 +1,108 @@
+// Package bench holds the golden-path benchmarks: create, consume, burn,
+// the cleanup sweep and a metrics scrape, each through the API handler or
+// store as the server runs them. They run against the memory store, and
+// against a Postgres testcontainer when built with -tags integration. Seed
+// data is deterministic, so runs are comparable with benchstat.
+//
+// testdata/baseline.txt holds the memory-store results the regression
+// check compares against; see the bench targets in the Makefile.
+package bench
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Result is one benchmark's cost per operation
+type Result struct {
+	Name        string
+	NsPerOp     float64
+	AllocsPerOp float64
+}
+
+// ParseResults reads go test -bench -benchmem output. The GOMAXPROCS
+// suffix is dropped from names, and of repeated runs of a benchmark, as
+// with -count, the lowest figures are kept: the fastest run is the one
+// least disturbed by other load. Lines that are not results are skipped.
+func ParseResults(r io.Reader) (map[string]Result, error) {
+	results := make(map[string]Result)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		name := fields[0]
+		if i := strings.LastIndexByte(name, '-'); i > 0 {
+			if _, err := strconv.Atoi(name[i+1:]); err == nil {
+				name = name[:i]
+			}
+		}
+
+		// After the iteration count come value-unit pairs
+		run := Result{Name: name}
+		for i := 2; i+1 < len(fields); i += 2 {
+			value, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s %s: %w", name, fields[i], fields[i+1], err)
+			}
+			switch fields[i+1] {
+			case "ns/op":
+				run.NsPerOp = value
+			case "allocs/op":
+				run.AllocsPerOp = value
+			}
+		}
+		results[name] = Best(results[name], run)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Best combines two runs of one benchmark, keeping the lower of each
+// figure; a zero Result counts as no run
+func Best(a, b Result) Result {
+	if a.Name == "" {
+		return b
+	}
+	a.NsPerOp = min(a.NsPerOp, b.NsPerOp)
+	a.AllocsPerOp = min(a.AllocsPerOp, b.AllocsPerOp)
+	return a
+}
+
+// Regression is a metric that got worse than its baseline allows
+type Regression struct {
+	Name     string
+	Metric   string
+	Baseline float64
+	Current  float64
+}
+
+func (r Regression) String() string {
+	return fmt.Sprintf("%s: %s went from %.0f to %.0f (%+.0f%%)", r.Name, r.Metric, r.Baseline, r.Current, (r.Current/r.Baseline-1)*100)
+}
+
+// Compare returns the metrics of current that exceed their baseline by more
+// than tolerance, a fraction. Benchmarks missing on either side are skipped.
+func Compare(baseline, current map[string]Result, tolerance float64) []Regression {
+	var regressions []Regression
+	for name, cur := range current {
+		base, ok := baseline[name]
+		if !ok {
+			continue
+		}
+		if cur.NsPerOp > base.NsPerOp*(1+tolerance) {
+			regressions = append(regressions, Regression{Name: name, Metric: "ns/op", Baseline: base.NsPerOp, Current: cur.NsPerOp})
+		}
+		if cur.AllocsPerOp > base.AllocsPerOp*(1+tolerance) {
+			regressions = append(regressions, Regression{Name: name, Metric: "allocs/op", Baseline: base.AllocsPerOp, Current: cur.AllocsPerOp})
+		}
+	}
+	return regressions
+}
@@ -0,0 +1,66 @@
+//go:build integration
+
+package bench
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	tcpostgres "github.com/testcontainers/testcontainers-go/modules/postgres"
+
+	"ots-backend/internal/db"
+	"ots-backend/internal/store"
+	"ots-backend/internal/store/postgres"
+)
+
+func init() {
+	stores = append(stores, benchStore{"postgres", openPostgres})
+}
+
+var (
+	// postgresOnce starts one container for the whole run; the
+	// testcontainers reaper removes it when the run ends
+	postgresOnce sync.Once
+	postgresDB   *db.DB
+	postgresErr  error
+)
+
+// openPostgres returns the shared migrated Postgres store, emptied
+func openPostgres(tb testing.TB) store.Store {
+	tb.Helper()
+	ctx := context.Background()
+
+	postgresOnce.Do(func() {
+		var container *tcpostgres.PostgresContainer
+		container, postgresErr = tcpostgres.Run(
+			ctx,
+			"postgres:16-alpine",
+			tcpostgres.WithDatabase("ots_bench"),
+			tcpostgres.WithUsername("ots"),
+			tcpostgres.WithPassword("ots"),
+			tcpostgres.BasicWaitStrategies(),
+		)
+		if postgresErr != nil {
+			return
+		}
+		var connString string
+		connString, postgresErr = container.ConnectionString(ctx, "sslmode=disable")
+		if postgresErr != nil {
+			return
+		}
+		postgresDB, postgresErr = db.New(connString)
+		if postgresErr != nil {
+			return
+		}
+		postgresErr = postgresDB.Migrate("../../migrations")
+	})
+	if postgresErr != nil {
+		tb.Fatalf("start postgres: %v", postgresErr)
+	}
+
+	if _, err := postgresDB.Pool().Exec(ctx, "TRUNCATE TABLE secrets, secret_receipts CASCADE"); err != nil {
+		tb.Fatalf("truncate: %v", err)
+	}
+	return postgres.New(postgresDB)
+}
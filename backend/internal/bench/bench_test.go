@@ -0,0 +1,285 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ots-backend/internal/api"
+	"ots-backend/internal/config"
+	"ots-backend/internal/crypto"
+	"ots-backend/internal/logger"
+	"ots-backend/internal/models"
+	"ots-backend/internal/store"
+	"ots-backend/internal/store/memory"
+)
+
+const (
+	// smallSize and largeSize are the ciphertext bytes of the create
+	// benchmarks: a password and a certificate bundle
+	smallSize = 256
+	largeSize = 32 << 10
+	// sweepRows is the table size of the cleanup benchmark, half expired
+	sweepRows = 100_000
+	// scrapeRows is how many live secrets the metrics scrape counts
+	scrapeRows = 1000
+	// benchToken manages every seeded secret
+	benchToken = "bench-management-token"
+)
+
+// opener returns an empty store
+type opener func(tb testing.TB) store.Store
+
+// benchStore is a store the benchmarks run against
+type benchStore struct {
+	name string
+	open opener
+}
+
+// stores lists the benchmarked stores; the integration build adds Postgres
+var stores = []benchStore{
+	{"memory", func(testing.TB) store.Store { return memory.New() }},
+}
+
+func TestMain(m *testing.M) {
+	// Request logs would drown the results
+	logger.SetOutput(io.Discard)
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// forEachStore runs fn as a sub-benchmark per store
+func forEachStore(b *testing.B, fn func(b *testing.B, open opener)) {
+	for _, s := range stores {
+		b.Run(s.name, func(b *testing.B) {
+			fn(b, s.open)
+		})
+	}
+}
+
+// newRouter serves the API over st with limits no benchmark reaches
+func newRouter(st store.Store) http.Handler {
+	cfg := &config.Config{
+		MaxSecretSize:          64 << 10,
+		WriteRateLimitRequests: 1 << 30,
+		WriteRateLimitWindow:   time.Minute,
+		ReadRateLimitRequests:  1 << 30,
+		ReadRateLimitWindow:    time.Minute,
+	}
+	router := chi.NewRouter()
+	router.Mount("/api", api.NewHandler(st, cfg).Routes())
+	return router
+}
+
+// clients numbers the simulated clients
+var clients atomic.Uint32
+
+// newRequest returns a request from the next of many clients. The rate
+// limiter keeps a window per client, and one client sending everything
+// would make each request pay for all the ones before it.
+func newRequest(method, path string, body io.Reader) *http.Request {
+	n := clients.Add(1) % (1 << 17)
+	req := httptest.NewRequest(method, path, body)
+	// 198.18.0.0/15 is set aside for benchmarking
+	req.RemoteAddr = fmt.Sprintf("198.%d.%d.%d:443", 18+n>>16, n>>8&0xff, n&0xff)
+	return req
+}
+
+// serve sends one request and fails the benchmark on an unexpected status
+func serve(b *testing.B, router http.Handler, req *http.Request, want ...int) int {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	for _, code := range want {
+		if rec.Code == code {
+			return code
+		}
+	}
+	b.Fatalf("%s %s status = %d, want %v: %s", req.Method, req.URL.Path, rec.Code, want, rec.Body)
+	return 0
+}
+
+// seedID returns the i-th seeded secret ID, shaped like a generated one
+func seedID(i int) string {
+	buf := make([]byte, crypto.SecretIDLength)
+	binary.BigEndian.PutUint64(buf[len(buf)-8:], uint64(i)+1)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// seed stores n secrets of size bytes expiring at expiresAt, managed by
+// benchToken, and returns their IDs
+func seed(b *testing.B, st store.Store, n, size int, expiresAt time.Time) []string {
+	b.Helper()
+	ctx := context.Background()
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = seedID(i)
+		err := st.Create(ctx, &store.Secret{
+			ID:                  ids[i],
+			Ciphertext:          bytes.Repeat([]byte{byte(i)}, size),
+			IV:                  make([]byte, 12),
+			Salt:                make([]byte, 16),
+			ExpiresAt:           expiresAt,
+			CreatedAt:           expiresAt.Add(-time.Hour),
+			BurnAfterRead:       true,
+			ManagementTokenHash: crypto.HashManagementToken(benchToken),
+		})
+		if err != nil {
+			b.Fatalf("Create() error: %v", err)
+		}
+	}
+	return ids
+}
+
+func BenchmarkCreateSmall(b *testing.B) {
+	forEachStore(b, func(b *testing.B, open opener) { benchCreate(b, open, smallSize) })
+}
+
+func BenchmarkCreateLarge(b *testing.B) {
+	forEachStore(b, func(b *testing.B, open opener) { benchCreate(b, open, largeSize) })
+}
+
+// benchCreate posts secrets of size ciphertext bytes
+func benchCreate(b *testing.B, open opener, size int) {
+	router := newRouter(open(b))
+	body, err := json.Marshal(models.CreateSecretRequest{
+		Ciphertext:    base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0x5a}, size)),
+		IV:            base64.StdEncoding.EncodeToString(make([]byte, 12)),
+		Salt:          base64.StdEncoding.EncodeToString(make([]byte, 16)),
+		ExpiresIn:     3600,
+		BurnAfterRead: true,
+	})
+	if err != nil {
+		b.Fatalf("marshal create request: %v", err)
+	}
+
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	for b.Loop() {
+		req := newRequest(http.MethodPost, "/api/secrets", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		serve(b, router, req, http.StatusCreated)
+	}
+}
+
+func BenchmarkConsume(b *testing.B) {
+	forEachStore(b, benchConsume)
+}
+
+// benchConsume reads one seeded secret per iteration
+func benchConsume(b *testing.B, open opener) {
+	st := open(b)
+	router := newRouter(st)
+	ids := seed(b, st, b.N, smallSize, time.Now().Add(time.Hour))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		serve(b, router, newRequest(http.MethodGet, "/api/secrets/"+ids[i], nil), http.StatusOK)
+	}
+}
+
+func BenchmarkConsumeContended(b *testing.B) {
+	forEachStore(b, benchConsumeContended)
+}
+
+// benchConsumeContended has every goroutine read the same secret until one
+// wins it, then move on to the next; each iteration is one attempt
+func benchConsumeContended(b *testing.B, open opener) {
+	st := open(b)
+	router := newRouter(st)
+	ids := seed(b, st, b.N, smallSize, time.Now().Add(time.Hour))
+	var current atomic.Int64
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := current.Load()
+			if i >= int64(len(ids)) {
+				// Every secret is read; the rest of the attempts miss
+				i = int64(len(ids)) - 1
+			}
+			req := newRequest(http.MethodGet, "/api/secrets/"+ids[i], nil)
+			if serve(b, router, req, http.StatusOK, http.StatusNotFound) == http.StatusOK {
+				current.CompareAndSwap(i, i+1)
+			}
+		}
+	})
+}
+
+func BenchmarkBurn(b *testing.B) {
+	forEachStore(b, benchBurn)
+}
+
+// benchBurn burns one seeded secret per iteration with its management token
+func benchBurn(b *testing.B, open opener) {
+	st := open(b)
+	router := newRouter(st)
+	ids := seed(b, st, b.N, smallSize, time.Now().Add(time.Hour))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req := newRequest(http.MethodDelete, "/api/secrets/"+ids[i], nil)
+		req.Header.Set(api.ManagementTokenHeader, benchToken)
+		serve(b, router, req, http.StatusNoContent)
+	}
+}
+
+func BenchmarkCleanupSweep(b *testing.B) {
+	forEachStore(b, benchCleanupSweep)
+}
+
+// benchCleanupSweep deletes the expired half of a sweepRows table. The
+// table is refilled outside the timer, so each iteration opens a new store.
+func benchCleanupSweep(b *testing.B, open opener) {
+	ctx := context.Background()
+	now := time.Now()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		st := open(b)
+		seed(b, st, sweepRows/2, smallSize, now.Add(-time.Minute))
+		for j := sweepRows / 2; j < sweepRows; j++ {
+			if err := st.Create(ctx, &store.Secret{ID: seedID(j), Ciphertext: []byte{0}, ExpiresAt: now.Add(time.Hour), CreatedAt: now}); err != nil {
+				b.Fatalf("Create() error: %v", err)
+			}
+		}
+		b.StartTimer()
+
+		rows, err := st.DeleteExpired(ctx, now)
+		if err != nil || rows != sweepRows/2 {
+			b.Fatalf("DeleteExpired() = %d, %v; want %d", rows, err, sweepRows/2)
+		}
+	}
+}
+
+func BenchmarkMetricsScrape(b *testing.B) {
+	forEachStore(b, benchMetricsScrape)
+}
+
+// benchMetricsScrape serves GET /metrics over scrapeRows live secrets
+func benchMetricsScrape(b *testing.B, open opener) {
+	st := open(b)
+	router := newRouter(st)
+	seed(b, st, scrapeRows, smallSize, time.Now().Add(time.Hour))
+
+	b.ReportAllocs()
+	for b.Loop() {
+		serve(b, router, newRequest(http.MethodGet, "/api/metrics", nil), http.StatusOK)
+	}
+}
@@ -0,0 +1,118 @@
+package bench
+
+import (
+	"flag"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"ots-backend/internal/store"
+	"ots-backend/internal/store/memory"
+)
+
+var compare = flag.Bool("compare", false, "compare the memory-store benchmarks against testdata/baseline.txt")
+
+// regressionTolerance is how much worse than the baseline a benchmark may get
+const regressionTolerance = 0.20
+
+// regressionRuns is how often each benchmark runs; the best run counts
+const regressionRuns = 3
+
+// memoryBenchmarks are the benchmarks the baseline covers, by result name
+var memoryBenchmarks = map[string]func(*testing.B){
+	"BenchmarkCreateSmall/memory":      func(b *testing.B) { benchCreate(b, openMemory, smallSize) },
+	"BenchmarkCreateLarge/memory":      func(b *testing.B) { benchCreate(b, openMemory, largeSize) },
+	"BenchmarkConsume/memory":          func(b *testing.B) { benchConsume(b, openMemory) },
+	"BenchmarkConsumeContended/memory": func(b *testing.B) { benchConsumeContended(b, openMemory) },
+	"BenchmarkBurn/memory":             func(b *testing.B) { benchBurn(b, openMemory) },
+	"BenchmarkCleanupSweep/memory":     func(b *testing.B) { benchCleanupSweep(b, openMemory) },
+	"BenchmarkMetricsScrape/memory":    func(b *testing.B) { benchMetricsScrape(b, openMemory) },
+}
+
+func openMemory(testing.TB) store.Store {
+	return memory.New()
+}
+
+// readBaseline parses testdata/baseline.txt
+func readBaseline(t *testing.T) map[string]Result {
+	t.Helper()
+	f, err := os.Open("testdata/baseline.txt")
+	if err != nil {
+		t.Fatalf("open baseline: %v", err)
+	}
+	defer f.Close()
+
+	baseline, err := ParseResults(f)
+	if err != nil {
+		t.Fatalf("ParseResults() error: %v", err)
+	}
+	return baseline
+}
+
+// TestBaselineMatchesBenchmarks keeps the baseline in step with the suite,
+// so a renamed or new benchmark is not silently left unchecked
+func TestBaselineMatchesBenchmarks(t *testing.T) {
+	baseline := readBaseline(t)
+	for name := range memoryBenchmarks {
+		if _, ok := baseline[name]; !ok {
+			t.Errorf("%s has no baseline; run make bench-baseline", name)
+		}
+	}
+	for name := range baseline {
+		if _, ok := memoryBenchmarks[name]; !ok {
+			t.Errorf("baseline has %s, which is not a memory-store benchmark", name)
+		}
+	}
+}
+
+// TestBenchmarkRegression reruns the memory-store benchmarks and fails on
+// any whose best run is more than 20% slower or allocates 20% more than the
+// baseline's. Timings depend on the machine, so it only runs with -compare.
+func TestBenchmarkRegression(t *testing.T) {
+	if !*compare {
+		t.Skip("run with -compare to check the benchmarks against testdata/baseline.txt")
+	}
+
+	baseline := readBaseline(t)
+	current := make(map[string]Result, len(memoryBenchmarks))
+	for name, fn := range memoryBenchmarks {
+		for range regressionRuns {
+			result := testing.Benchmark(fn)
+			t.Logf("%s\t%s\t%s", name, result, result.MemString())
+			current[name] = Best(current[name], Result{Name: name, NsPerOp: float64(result.NsPerOp()), AllocsPerOp: float64(result.AllocsPerOp())})
+		}
+	}
+
+	regressions := Compare(baseline, current, regressionTolerance)
+	sort.Slice(regressions, func(i, j int) bool { return regressions[i].String() < regressions[j].String() })
+	for _, regression := range regressions {
+		t.Error(regression)
+	}
+}
+
+func TestParseResultsKeepsBestRun(t *testing.T) {
+	output := `goos: linux
+pkg: ots-backend/internal/bench
+BenchmarkConsume/memory-8   	  100000	     12000 ns/op	   11008 B/op	      60 allocs/op
+BenchmarkConsume/memory-8   	  100000	     14000 ns/op	   11008 B/op	      58 allocs/op
+BenchmarkCreateLarge/memory-8 	   5000	    255886 ns/op	 128.06 MB/s	  258425 B/op	      90 allocs/op
+PASS
+`
+	results, err := ParseResults(strings.NewReader(output))
+	if err != nil {
+		t.Fatalf("ParseResults() error: %v", err)
+	}
+	if got := results["BenchmarkConsume/memory"]; got.NsPerOp != 12000 || got.AllocsPerOp != 58 {
+		t.Errorf("BenchmarkConsume/memory = %+v, want the lower figures of both runs", got)
+	}
+	if got := results["BenchmarkCreateLarge/memory"]; got.NsPerOp != 255886 || got.AllocsPerOp != 90 {
+		t.Errorf("BenchmarkCreateLarge/memory = %+v, want 255886 ns/op and 90 allocs/op", got)
+	}
+
+	baseline := map[string]Result{"BenchmarkConsume/memory": {NsPerOp: 9000, AllocsPerOp: 58}}
+	regressions := Compare(baseline, results, regressionTolerance)
+	if len(regressions) != 1 || regressions[0].Metric != "ns/op" {
+		t.Errorf("Compare() = %v, want only the 33%% slower ns/op", regressions)
+	}
+}
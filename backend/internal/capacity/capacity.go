@@ -0,0 +1,176 @@
+// Package capacity projects how large the secrets table grows, and how much
+// the cleanup worker deletes, if traffic carries on as observed. It works
+// from aggregate stats only, the daily totals and size buckets the admin
+// stats endpoint serves, so the same math runs in the server and against an
+// exported stats file.
+//
+// The model is Little's law: a table that secrets enter at rate λ and stay
+// in for W on average holds λW of them once it reaches steady state. A
+// secret that expires unread stays for its TTL plus, on average, half a
+// sweep interval until the worker deletes it. A secret that is read or
+// burned leaves at once, except that with crypto shredding its row waits
+// for the next sweep to be collected. How soon after creation secrets are
+// read is not recorded, so the projection gives a range: reads at once
+// for the expected value, reads at the last moment for the upper bound.
+package capacity
+
+import (
+	"fmt"
+	"time"
+
+	"ots-backend/internal/store"
+)
+
+// Caveats are added when the window is shorter than this or saw fewer
+// creates than minCreates
+const (
+	minWindow  = 24 * time.Hour
+	minCreates = 100
+)
+
+// Observation is aggregate traffic over one window
+type Observation struct {
+	Window    time.Duration
+	Created   int64
+	Retrieved int64
+	Burned    int64
+	// Bytes is the ciphertext creates stored; zero when it was not
+	// recorded, as in strict privacy mode
+	Bytes int64
+	// TTLSeconds sums the TTLs of the TTLCreated creates that recorded one
+	TTLSeconds int64
+	TTLCreated int64
+	// SizeBuckets are all-time create counts by size, used for the mean
+	// size when Bytes was not recorded
+	SizeBuckets []store.SizeBucket
+}
+
+// Settings are the deployment's parameters that shape the projection
+type Settings struct {
+	// SweepInterval is how often the cleanup worker runs
+	SweepInterval time.Duration
+	// CryptoShredding leaves read secrets' rows for the sweep to collect
+	CryptoShredding bool
+	// DefaultTTL stands in for the mean TTL when none was recorded
+	DefaultTTL time.Duration
+}
+
+// Projection is the steady state traffic like the observation leads to
+type Projection struct {
+	CreatesPerHour float64
+	// ConsumedFraction is the share of creates read or burned before expiry
+	ConsumedFraction float64
+	MeanTTL          time.Duration
+	MeanSizeBytes    float64
+	// SteadyStateRows and StorageBytes assume reads come right after
+	// creation; the upper bounds assume they come just before expiry.
+	// Bytes count ciphertext only, not row or index overhead.
+	SteadyStateRows           float64
+	SteadyStateRowsUpperBound float64
+	StorageBytes              float64
+	StorageBytesUpperBound    float64
+	// DeletionsPerSweep and DeletionsPerHour count the rows the cleanup
+	// worker removes: expired secrets and, with crypto shredding, the
+	// collected rows of read ones
+	DeletionsPerSweep float64
+	DeletionsPerHour  float64
+	// Caveats explain why the projection may be off
+	Caveats []string
+}
+
+// Observe sums the days of stats that overlap the window ending at now.
+// Days are whole-day totals, so the observation starts at midnight of the
+// first day counted, not at now minus window.
+func Observe(days []store.DailyStats, sizes []store.SizeBucket, now time.Time, window time.Duration) Observation {
+	start := store.StatsDay(now.Add(-window))
+	obs := Observation{Window: now.Sub(start), SizeBuckets: sizes}
+	for _, day := range days {
+		if day.Day.Before(start) || day.Day.After(now) {
+			continue
+		}
+		obs.Created += day.Created
+		obs.Retrieved += day.Retrieved
+		obs.Burned += day.Burned
+		obs.Bytes += day.Bytes
+		if day.TTLSeconds > 0 {
+			obs.TTLSeconds += day.TTLSeconds
+			obs.TTLCreated += day.Created
+		}
+	}
+	return obs
+}
+
+// Project computes the steady state obs leads to under settings
+func Project(obs Observation, settings Settings) Projection {
+	var p Projection
+	if obs.Window < minWindow {
+		p.Caveats = append(p.Caveats, fmt.Sprintf("only %s of traffic observed; rates from less than a day miss the daily cycle", obs.Window.Round(time.Minute)))
+	}
+	if obs.Created < minCreates {
+		p.Caveats = append(p.Caveats, fmt.Sprintf("only %d creates observed; the projection is rough", obs.Created))
+	}
+	if obs.Window <= 0 || obs.Created == 0 {
+		return p
+	}
+
+	p.CreatesPerHour = float64(obs.Created) / obs.Window.Hours()
+	p.ConsumedFraction = min(float64(obs.Retrieved+obs.Burned)/float64(obs.Created), 1)
+
+	switch {
+	case obs.TTLCreated > 0:
+		p.MeanTTL = time.Duration(float64(obs.TTLSeconds) / float64(obs.TTLCreated) * float64(time.Second))
+		if obs.TTLCreated < obs.Created {
+			p.Caveats = append(p.Caveats, fmt.Sprintf("TTLs recorded for %d of %d creates; the rest are assumed to match", obs.TTLCreated, obs.Created))
+		}
+	default:
+		p.MeanTTL = settings.DefaultTTL
+		p.Caveats = append(p.Caveats, "no TTLs recorded; assuming every secret uses the default TTL")
+	}
+	if p.MeanTTL > obs.Window {
+		p.Caveats = append(p.Caveats, "secrets live longer on average than the window observed, so few expiries were seen")
+	}
+
+	switch {
+	case obs.Bytes > 0:
+		p.MeanSizeBytes = float64(obs.Bytes) / float64(obs.Created)
+	default:
+		p.MeanSizeBytes = bucketMean(obs.SizeBuckets)
+		p.Caveats = append(p.Caveats, "sizes not recorded in the window; using the all-time size buckets")
+	}
+
+	// Residence in hours of an unread secret, and of a read one's row
+	sweep := settings.SweepInterval.Hours()
+	unread := p.MeanTTL.Hours() + sweep/2
+	read := 0.0
+	if settings.CryptoShredding {
+		read = sweep / 2
+	}
+
+	expiring := p.CreatesPerHour * (1 - p.ConsumedFraction)
+	consumed := p.CreatesPerHour * p.ConsumedFraction
+	p.SteadyStateRows = expiring*unread + consumed*read
+	p.SteadyStateRowsUpperBound = p.CreatesPerHour * unread
+	p.StorageBytes = p.SteadyStateRows * p.MeanSizeBytes
+	p.StorageBytesUpperBound = p.SteadyStateRowsUpperBound * p.MeanSizeBytes
+
+	p.DeletionsPerHour = expiring
+	if settings.CryptoShredding {
+		p.DeletionsPerHour += consumed
+	}
+	p.DeletionsPerSweep = p.DeletionsPerHour * sweep
+	return p
+}
+
+// bucketMean estimates the mean size from power-of-two buckets, taking
+// each bucket's sizes to average three quarters of its upper bound
+func bucketMean(buckets []store.SizeBucket) float64 {
+	var count, total float64
+	for _, bucket := range buckets {
+		count += float64(bucket.Count)
+		total += float64(bucket.Count) * float64(bucket.UpperBound) * 3 / 4
+	}
+	if count == 0 {
+		return 0
+	}
+	return total / count
+}
@@ -0,0 +1,164 @@
+package capacity
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"ots-backend/internal/store"
+)
+
+func approx(t *testing.T, name string, got, want float64) {
+	t.Helper()
+	if math.Abs(got-want) > 1e-6*math.Max(1, math.Abs(want)) {
+		t.Errorf("%s = %v, want %v", name, got, want)
+	}
+}
+
+func TestProject(t *testing.T) {
+	const hour = float64(time.Hour)
+	sweep := 5 * time.Minute
+	halfSweep := float64(sweep) / 2 / hour
+
+	tests := []struct {
+		name       string
+		obs        Observation
+		shredding  bool
+		rows       float64
+		upperRows  float64
+		size       float64
+		perHour    float64
+		perSweep   float64
+		ttl        time.Duration
+		noCaveats  bool
+		consumeFra float64
+	}{
+		{
+			// 100 creates an hour, a quarter left to expire after an hour
+			name: "one TTL",
+			obs: Observation{
+				Window: 24 * time.Hour, Created: 2400, Retrieved: 1500, Burned: 300,
+				Bytes: 2400 * 1000, TTLSeconds: 2400 * 3600, TTLCreated: 2400,
+			},
+			rows:       25 * (1 + halfSweep),
+			upperRows:  100 * (1 + halfSweep),
+			size:       1000,
+			perHour:    25,
+			perSweep:   25 * float64(sweep) / hour,
+			ttl:        time.Hour,
+			noCaveats:  true,
+			consumeFra: 0.75,
+		},
+		{
+			// Read rows wait half a sweep on average to be collected
+			name: "crypto shredding",
+			obs: Observation{
+				Window: 24 * time.Hour, Created: 2400, Retrieved: 1500, Burned: 300,
+				Bytes: 2400 * 1000, TTLSeconds: 2400 * 3600, TTLCreated: 2400,
+			},
+			shredding:  true,
+			rows:       25*(1+halfSweep) + 75*halfSweep,
+			upperRows:  100 * (1 + halfSweep),
+			size:       1000,
+			perHour:    100,
+			perSweep:   100 * float64(sweep) / hour,
+			ttl:        time.Hour,
+			noCaveats:  true,
+			consumeFra: 0.75,
+		},
+		{
+			// Half five-minute and half day-long TTLs, none read: each class
+			// holds its own rate times its own residence, and the sum is what
+			// the mean TTL gives
+			name: "TTL mix",
+			obs: Observation{
+				Window: 48 * time.Hour, Created: 4800,
+				Bytes: 4800 * 200, TTLSeconds: 2400*300 + 2400*86400, TTLCreated: 4800,
+			},
+			rows:      50*(300.0/3600+halfSweep) + 50*(24+halfSweep),
+			upperRows: 50*(300.0/3600+halfSweep) + 50*(24+halfSweep),
+			size:      200,
+			perHour:   100,
+			perSweep:  100 * float64(sweep) / hour,
+			ttl:       time.Duration(2400*300+2400*86400) * time.Second / 4800,
+			noCaveats: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Project(tt.obs, Settings{SweepInterval: sweep, CryptoShredding: tt.shredding, DefaultTTL: time.Hour})
+
+			approx(t, "ConsumedFraction", p.ConsumedFraction, tt.consumeFra)
+			approx(t, "SteadyStateRows", p.SteadyStateRows, tt.rows)
+			approx(t, "SteadyStateRowsUpperBound", p.SteadyStateRowsUpperBound, tt.upperRows)
+			approx(t, "MeanSizeBytes", p.MeanSizeBytes, tt.size)
+			approx(t, "StorageBytes", p.StorageBytes, tt.rows*tt.size)
+			approx(t, "StorageBytesUpperBound", p.StorageBytesUpperBound, tt.upperRows*tt.size)
+			approx(t, "DeletionsPerHour", p.DeletionsPerHour, tt.perHour)
+			approx(t, "DeletionsPerSweep", p.DeletionsPerSweep, tt.perSweep)
+			if p.MeanTTL != tt.ttl {
+				t.Errorf("MeanTTL = %v, want %v", p.MeanTTL, tt.ttl)
+			}
+			if tt.noCaveats && len(p.Caveats) > 0 {
+				t.Errorf("Caveats = %q, want none", p.Caveats)
+			}
+		})
+	}
+}
+
+func TestProjectCaveats(t *testing.T) {
+	// Two hours of a few creates, in strict privacy mode, from before TTLs
+	// were recorded
+	obs := Observation{
+		Window:      2 * time.Hour,
+		Created:     4,
+		Retrieved:   2,
+		SizeBuckets: []store.SizeBucket{{UpperBound: 128, Count: 2}, {UpperBound: 1024, Count: 2}},
+	}
+	p := Project(obs, Settings{SweepInterval: 5 * time.Minute, DefaultTTL: 6 * time.Hour})
+
+	if p.MeanTTL != 6*time.Hour {
+		t.Errorf("MeanTTL = %v, want the default TTL", p.MeanTTL)
+	}
+	approx(t, "MeanSizeBytes", p.MeanSizeBytes, (96*2+768*2)/4.0)
+	approx(t, "SteadyStateRows", p.SteadyStateRows, 1*(6+2.5/60))
+
+	caveats := strings.Join(p.Caveats, "\n")
+	for _, want := range []string{"2h0m0s of traffic", "only 4 creates", "no TTLs recorded", "longer on average than the window", "size buckets"} {
+		if !strings.Contains(caveats, want) {
+			t.Errorf("Caveats = %q, want one mentioning %q", p.Caveats, want)
+		}
+	}
+
+	// Nothing observed projects nothing, with the reason
+	empty := Project(Observation{Window: time.Hour}, Settings{SweepInterval: 5 * time.Minute})
+	if empty.SteadyStateRows != 0 || len(empty.Caveats) == 0 {
+		t.Errorf("Project() of nothing = %+v, want zero rows and caveats", empty)
+	}
+}
+
+func TestObserve(t *testing.T) {
+	today := time.Date(2026, 5, 14, 0, 0, 0, 0, time.UTC)
+	days := []store.DailyStats{
+		{Day: today.AddDate(0, 0, -2), Created: 1000},
+		{Day: today.AddDate(0, 0, -1), Created: 240, Retrieved: 100, Bytes: 24000},
+		// Today's creates came before TTLs were recorded
+		{Day: today, Created: 60, Burned: 5, Bytes: 6000, TTLSeconds: 0},
+	}
+	days[1].TTLSeconds = 240 * 600
+
+	now := today.Add(6 * time.Hour)
+	obs := Observe(days, nil, now, 24*time.Hour)
+
+	if obs.Window != 30*time.Hour {
+		t.Errorf("Window = %v, want yesterday and today so far", obs.Window)
+	}
+	if obs.Created != 300 || obs.Retrieved != 100 || obs.Burned != 5 || obs.Bytes != 30000 {
+		t.Errorf("Observe() = %+v, want the last two days summed", obs)
+	}
+	if obs.TTLSeconds != 240*600 || obs.TTLCreated != 240 {
+		t.Errorf("TTLs = %d over %d creates, want %d over 240", obs.TTLSeconds, obs.TTLCreated, 240*600)
+	}
+}
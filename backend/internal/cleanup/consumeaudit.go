@@ -0,0 +1,163 @@
+package cleanup
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"ots-backend/internal/dropped"
+	"ots-backend/internal/store"
+	"ots-backend/internal/ulid"
+)
+
+// receiptSlack covers the gap between a receipt's timestamp, taken before
+// the consume, and its audit event, recorded after the commit
+const receiptSlack = time.Minute
+
+var (
+	stragglersRemoved atomic.Int64
+	missingReceipts   atomic.Int64
+)
+
+// ConsumeAuditMetrics reports discrepancies found by the consume auditor
+type ConsumeAuditMetrics struct {
+	StragglersRemoved int64 `json:"stragglers_removed_total"`
+	MissingReceipts   int64 `json:"missing_receipts_total"`
+}
+
+// GetConsumeAuditMetrics returns the current consume auditor counters
+func GetConsumeAuditMetrics() ConsumeAuditMetrics {
+	return ConsumeAuditMetrics{
+		StragglersRemoved: stragglersRemoved.Load(),
+		MissingReceipts:   missingReceipts.Load(),
+	}
+}
+
+// SetConsumeAudit turns on the consume auditor, which treats read receipts
+// as tombstones. Each cycle it checks the sample most recent receipts
+// within window for secrets that can still be read and destroys them. With
+// crossCheck it also walks secret.consumed audit events, sample per cycle,
+// and reports those without a receipt; enable it only when the server
+// records both. Every ending leaves a receipt, so that is whenever
+// AUDIT_LOG_ENABLED is set.
+func (w *Worker) SetConsumeAudit(sample int, window time.Duration, crossCheck bool) {
+	w.auditSample = sample
+	w.auditWindow = window
+	w.auditCrossCheck = crossCheck
+}
+
+// auditConsumes runs both checks. now must predate the cycle's sweeps, so
+// an ack hold lapsing mid-cycle is left to the next sweep, not reported.
+func (w *Worker) auditConsumes(ctx context.Context, now time.Time) {
+	if w.auditSample <= 0 || w.auditWindow <= 0 {
+		return
+	}
+	since := now.Add(-w.auditWindow)
+
+	ids, err := w.store.Stragglers(ctx, since, now, w.auditSample)
+	if err != nil {
+		log.Printf("Failed to audit consumed secrets: %v", err)
+		return
+	}
+	for _, id := range ids {
+		w.removeStraggler(ctx, id, now)
+	}
+
+	missing := 0
+	if w.auditCrossCheck {
+		missing = w.checkReceipts(ctx, since)
+	}
+	if len(ids) > 0 || missing > 0 {
+		w.reportLosses(ctx, since)
+	}
+}
+
+// reportLosses logs the async work dropped since the audit window opened.
+// A discrepancy in a window where audit events were lost may be a gap in
+// the log rather than in the store, and consumes whose events were lost
+// were never cross-checked at all.
+func (w *Worker) reportLosses(ctx context.Context, since time.Time) {
+	losses, err := w.store.DroppedWorkSince(ctx, since)
+	if err != nil {
+		log.Printf("Failed to look up dropped work: %v", err)
+		return
+	}
+	if len(losses) == 0 {
+		log.Printf("No async work was recorded as dropped since %s", since.Format(time.RFC3339))
+		return
+	}
+	for _, loss := range losses {
+		log.Printf("Dropped since %s: %d %s (%s); the audit log may be incomplete for this window", since.Format(time.RFC3339), loss.Count, loss.Kind, loss.Reason)
+	}
+}
+
+// removeStraggler destroys a consumed secret that is still readable. Only
+// the ID hash is logged, as in the audit log.
+func (w *Worker) removeStraggler(ctx context.Context, id string, now time.Time) {
+	hash := store.HashSecretID(id)
+	log.Printf("CRITICAL: consumed secret %s is still readable; destroying it", hash)
+
+	if _, err := w.store.Burn(ctx, id); err != nil {
+		log.Printf("Failed to destroy straggling secret %s: %v", hash, err)
+		return
+	}
+	stragglersRemoved.Add(1)
+
+	// The event type matches the webhook contract's secret.straggler_removed
+	err := w.store.RecordAudit(ctx, &store.AuditEvent{
+		ID:           w.auditIDs.New(now),
+		Type:         store.AuditSecretStragglerRemoved,
+		OccurredAt:   now.UTC(),
+		SecretIDHash: hash,
+	})
+	if err != nil {
+		dropped.Record(dropped.KindAuditEvent, dropped.Reason(ctx, err))
+		log.Printf("Failed to record straggler audit event: %v", err)
+	}
+}
+
+// checkReceipts verifies the next sample of consume events, continuing
+// after the last one checked, each has a receipt, and returns how many did
+// not
+func (w *Worker) checkReceipts(ctx context.Context, since time.Time) int {
+	var events []store.AuditEvent
+	err := w.store.ScanAudit(ctx, store.AuditFilter{
+		Types:   []string{store.AuditSecretConsumed},
+		FromID:  ulid.Floor(since),
+		AfterID: w.auditCursor,
+		Limit:   w.auditSample,
+	}, func(event *store.AuditEvent) error {
+		events = append(events, *event)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to scan consume events: %v", err)
+		return 0
+	}
+	if len(events) == 0 {
+		return 0
+	}
+
+	from := events[0].OccurredAt.Add(-receiptSlack)
+	ids, err := w.store.ReceiptIDs(ctx, from, events[len(events)-1].OccurredAt)
+	if err != nil {
+		log.Printf("Failed to list read receipts: %v", err)
+		return 0
+	}
+	receipted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		receipted[store.HashSecretID(id)] = true
+	}
+
+	missing := 0
+	for _, event := range events {
+		if !receipted[event.SecretIDHash] {
+			log.Printf("CRITICAL: consume event %s for secret %s has no read receipt", event.ID, event.SecretIDHash)
+			missing++
+		}
+	}
+	missingReceipts.Add(int64(missing))
+	w.auditCursor = events[len(events)-1].ID
+	return missing
+}
@@ -0,0 +1,61 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"ots-backend/internal/events"
+	"ots-backend/internal/notify"
+	"ots-backend/internal/store"
+	"ots-backend/internal/terminate"
+)
+
+// expireBatch bounds how many expired secrets one listing takes
+const expireBatch = 500
+
+// SetNotifier makes the worker tell creators that set notify_email when
+// their secret expires unread. The caller starts and stops n.
+func (w *Worker) SetNotifier(n *notify.Service) {
+	w.notifier = n
+}
+
+// SetEvents publishes expiries to hub, for a worker that shares its process
+// with the API
+func (w *Worker) SetEvents(hub *events.Hub) {
+	w.events = hub
+}
+
+// terminateExpired ends each live secret that expired unread before now
+// through the terminator, so expiries leave the tombstone, audit event,
+// daily count and notice a read or burn does. It returns how many ended;
+// whatever it leaves is swept by DeleteExpired without a trail.
+func (w *Worker) terminateExpired(ctx context.Context, now time.Time) int64 {
+	t := &terminate.Terminator{Store: w.store, Clock: w.clock, Notifier: w.notifier, AuditIDs: &w.auditIDs, Events: w.events}
+
+	var total int64
+	for {
+		ids, err := w.store.ExpiredIDs(ctx, now, expireBatch)
+		if err != nil {
+			log.Printf("Failed to list expired secrets: %v", err)
+			return total
+		}
+
+		for _, id := range ids {
+			_, err := t.Terminate(ctx, id, store.TerminationExpired, terminate.Options{})
+			if errors.Is(err, store.ErrNotFound) {
+				// Read or burned since it was listed
+				continue
+			}
+			if err != nil {
+				log.Printf("Failed to terminate expired secret: %v", err)
+				return total
+			}
+			total++
+		}
+		if len(ids) < expireBatch {
+			return total
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package cleanup
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"ots-backend/internal/logger"
+	"ots-backend/internal/policy"
+)
+
+// logForecast logs one debug line on the live secrets expiring before the
+// TTL ceiling: how many expire this hour, in total, and in the busiest
+// hour. The query is skipped unless debug logging is on.
+func (w *Worker) logForecast(ctx context.Context, now time.Time) {
+	if !logger.DebugEnabled() {
+		return
+	}
+
+	maxTTL := time.Duration(w.maxTTL.Load())
+	if maxTTL <= 0 {
+		maxTTL = policy.DefaultMaxTTL
+	}
+	buckets, err := w.store.ExpiryForecast(ctx, "", now, now.Add(maxTTL))
+	if err != nil {
+		log.Printf("Failed to forecast secret expiries: %v", err)
+		return
+	}
+
+	thisHour := now.UTC().Truncate(time.Hour)
+	var total, totalBytes, current, peakCount int64
+	var peakHour time.Time
+	for _, b := range buckets {
+		total += b.Count
+		totalBytes += b.Bytes
+		if b.Hour.Equal(thisHour) {
+			current = b.Count
+		}
+		if b.Count > peakCount {
+			peakCount, peakHour = b.Count, b.Hour
+		}
+	}
+
+	logger.Debug("expiry forecast", "secrets", total, "bytes", totalBytes, "this_hour", current,
+		"peak_hour", peakHour, "peak_count", peakCount, "until", now.Add(maxTTL).UTC())
+}
@@ -0,0 +1,185 @@
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// The cleanup advisory lock elects one leader among cleanup replicas
+const (
+	cleanupLockName = "cleanup"
+	cleanupLockKey  = 0x0715c1ea
+)
+
+// staleAfter is how many missed heartbeats make a holder stale
+const staleAfter = 3
+
+var (
+	lockWaits  atomic.Int64
+	lockBreaks atomic.Int64
+)
+
+// LockMetrics reports how often this process waited on or broke the lock
+type LockMetrics struct {
+	Waits  int64 `json:"lock_waits_total"`
+	Breaks int64 `json:"lock_breaks_total"`
+}
+
+// GetLockMetrics returns the current lock counters
+func GetLockMetrics() LockMetrics {
+	return LockMetrics{Waits: lockWaits.Load(), Breaks: lockBreaks.Load()}
+}
+
+// ensureLeader makes sure this worker holds the cleanup lock on a dedicated
+// connection, acquiring it if free and breaking a stale holder if allowed.
+func (w *Worker) ensureLeader(ctx context.Context) bool {
+	if w.conn != nil {
+		if err := w.conn.Ping(ctx); err == nil {
+			return true
+		}
+
+		log.Printf("Lost cleanup lock connection, re-electing")
+		w.releaseConn()
+	}
+
+	acquired, err := w.tryAcquire(ctx)
+	if err != nil {
+		log.Printf("Failed to acquire cleanup lock: %v", err)
+		return false
+	}
+	if acquired {
+		return true
+	}
+
+	waits := lockWaits.Add(1)
+	log.Printf("Cleanup lock held by another instance, standing by (waits=%d breaks=%d)", waits, lockBreaks.Load())
+
+	if w.allowLockBreak {
+		if err := w.breakStaleHolder(ctx); err != nil {
+			log.Printf("Failed to break stale cleanup lock: %v", err)
+		}
+	}
+
+	return false
+}
+
+func (w *Worker) tryAcquire(ctx context.Context) (bool, error) {
+	conn, err := w.db.Pool().Acquire(ctx)
+	if err != nil {
+		return false, fmt.Errorf("acquire connection: %w", err)
+	}
+
+	var acquired bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, cleanupLockKey).Scan(&acquired); err != nil {
+		conn.Release()
+		return false, fmt.Errorf("try advisory lock: %w", err)
+	}
+
+	if !acquired {
+		conn.Release()
+		return false, nil
+	}
+
+	_, err = conn.Exec(ctx, `
+		INSERT INTO lock_ledger (lock_name, instance_id, backend_pid, acquired_at, heartbeat_at)
+		VALUES ($1, $2, pg_backend_pid(), NOW(), NOW())
+		ON CONFLICT (lock_name) DO UPDATE
+		SET instance_id = EXCLUDED.instance_id,
+		    backend_pid = EXCLUDED.backend_pid,
+		    acquired_at = EXCLUDED.acquired_at,
+		    heartbeat_at = EXCLUDED.heartbeat_at
+	`, cleanupLockName, w.instanceID)
+	if err != nil {
+		// Don't lead without a ledger entry; others could never detect us going stale
+		conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, cleanupLockKey)
+		conn.Release()
+		return false, fmt.Errorf("record lock ledger: %w", err)
+	}
+
+	w.conn = conn
+	log.Printf("Instance %s acquired cleanup lock", w.instanceID)
+	return true, nil
+}
+
+// heartbeat refreshes the ledger entry through the lock-holding connection
+func (w *Worker) heartbeat(ctx context.Context) error {
+	_, err := w.conn.Exec(ctx, `
+		UPDATE lock_ledger SET heartbeat_at = NOW()
+		WHERE lock_name = $1 AND instance_id = $2
+	`, cleanupLockName, w.instanceID)
+	return err
+}
+
+// breakStaleHolder terminates the holder's backend when its heartbeat is
+// older than staleAfter intervals and it still holds the advisory lock.
+func (w *Worker) breakStaleHolder(ctx context.Context) error {
+	var holder string
+	var pid int32
+	var heartbeatAt time.Time
+	var stale bool
+
+	err := w.db.Pool().QueryRow(ctx, `
+		SELECT instance_id, backend_pid, heartbeat_at, heartbeat_at < NOW() - make_interval(secs => $2)
+		FROM lock_ledger
+		WHERE lock_name = $1
+	`, cleanupLockName, (staleAfter*w.interval).Seconds()).Scan(&holder, &pid, &heartbeatAt, &stale)
+	if err != nil {
+		return fmt.Errorf("read lock ledger: %w", err)
+	}
+
+	if !stale {
+		return nil
+	}
+
+	// Only terminate the PID if it really holds our lock; PIDs get reused
+	var terminated bool
+	err = w.db.Pool().QueryRow(ctx, `
+		SELECT COALESCE(bool_or(pg_terminate_backend(l.pid)), false)
+		FROM pg_locks l
+		WHERE l.locktype = 'advisory'
+		  AND l.granted
+		  AND l.classid = 0
+		  AND l.objid::bigint = $1
+		  AND l.objsubid = 1
+		  AND l.pid = $2
+	`, cleanupLockKey, pid).Scan(&terminated)
+	if err != nil {
+		return fmt.Errorf("terminate stale holder: %w", err)
+	}
+
+	if !terminated {
+		return nil
+	}
+
+	lockBreaks.Add(1)
+	log.Printf("Broke stale cleanup lock held by instance %s (pid %d, last heartbeat %s)",
+		holder, pid, heartbeatAt.Format(time.RFC3339))
+
+	_, err = w.db.Pool().Exec(ctx, `
+		INSERT INTO lock_breaks (lock_name, holder_instance_id, holder_backend_pid, holder_heartbeat_at, broken_by)
+		VALUES ($1, $2, $3, $4, $5)
+	`, cleanupLockName, holder, pid, heartbeatAt, w.instanceID)
+	if err != nil {
+		return fmt.Errorf("audit lock break: %w", err)
+	}
+
+	return nil
+}
+
+// releaseConn gives up the lock connection. Unlocking is best effort; the
+// lock is released by the server anyway when the session ends.
+func (w *Worker) releaseConn() {
+	if w.conn == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	w.conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, cleanupLockKey)
+	w.conn.Release()
+	w.conn = nil
+}
@@ -0,0 +1,44 @@
+package cleanup
+
+import (
+	"context"
+	"log"
+
+	"ots-backend/internal/store"
+)
+
+// deleteNamespaces resumes namespace deletions a server did not finish and
+// checks finished ones: a deleted namespace must not be named by any
+// secret or audit event. Rows that turn up anyway, such as the audit event
+// of a create that raced the deletion's start, are reported and swept.
+func (w *Worker) deleteNamespaces(ctx context.Context) {
+	deletions, err := w.store.NamespaceDeletions(ctx)
+	if err != nil {
+		log.Printf("Failed to list namespace deletions: %v", err)
+		return
+	}
+
+	for _, deletion := range deletions {
+		if deletion.Finished() {
+			refs, err := w.store.NamespaceReferences(ctx, deletion.Namespace)
+			if err != nil {
+				log.Printf("Failed to check deleted namespace %q: %v", deletion.Namespace, err)
+				continue
+			}
+			if refs == 0 {
+				continue
+			}
+			log.Printf("WARNING: %d rows still reference deleted namespace %q; removing them", refs, deletion.Namespace)
+		}
+
+		done, err := store.DeleteNamespace(ctx, w.store, deletion.Namespace, store.NamespaceDeletionBatch, w.clock.Now)
+		if err != nil {
+			log.Printf("Failed to delete namespace %q: %v", deletion.Namespace, err)
+			continue
+		}
+		if !deletion.Finished() {
+			log.Printf("Deleted namespace %q: %d secrets, %d receipts, %d audit events anonymized",
+				deletion.Namespace, done.SecretsDeleted, done.ReceiptsDeleted, done.AuditEventsAnonymized)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package cleanup
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"ots-backend/internal/dropped"
+	"ots-backend/internal/store"
+)
+
+// reconcileBatch bounds how many rows one clamp statement touches
+const reconcileBatch = 500
+
+var expiryReduced atomic.Int64
+
+// ReconcileMetrics reports how many secrets had their expiry moved earlier
+// to fit a tightened TTL ceiling
+type ReconcileMetrics struct {
+	ExpiryReduced int64 `json:"expiry_reduced_total"`
+}
+
+// GetReconcileMetrics returns the current reconciliation counters
+func GetReconcileMetrics() ReconcileMetrics {
+	return ReconcileMetrics{ExpiryReduced: expiryReduced.Load()}
+}
+
+// SetMaxTTL tells the worker the TTL ceiling in force. When it is lower than
+// the previous one, or on first use, the next cycle clamps secrets that
+// would outlive it; call it again after a configuration reload.
+func (w *Worker) SetMaxTTL(maxTTL time.Duration) {
+	if maxTTL <= 0 {
+		return
+	}
+	previous := w.maxTTL.Swap(int64(maxTTL))
+	if previous == 0 || maxTTL < time.Duration(previous) {
+		w.reconcilePending.Store(true)
+	}
+}
+
+// reconcileTTL clamps every live secret expiring after now+maxTTL down to
+// that ceiling, in batches. Expiries are only ever lowered, so reruns are
+// harmless; a failed run stays pending for the next cycle.
+func (w *Worker) reconcileTTL(ctx context.Context, now time.Time) {
+	if !w.reconcilePending.Load() {
+		return
+	}
+
+	ceiling := now.Add(time.Duration(w.maxTTL.Load()))
+	var total int
+	for {
+		ids, err := w.store.ClampExpiry(ctx, ceiling, reconcileBatch)
+		if err != nil {
+			log.Printf("Failed to reconcile secret expiries: %v", err)
+			return
+		}
+
+		for _, id := range ids {
+			w.recordExpiryReduced(ctx, id, now)
+		}
+		total += len(ids)
+		expiryReduced.Add(int64(len(ids)))
+
+		if len(ids) < reconcileBatch {
+			break
+		}
+	}
+
+	w.reconcilePending.Store(false)
+	if total > 0 {
+		log.Printf("Reduced expiry of %d secrets to the TTL ceiling %s", total, ceiling.Format(time.RFC3339))
+	}
+}
+
+// recordExpiryReduced writes the audit event for one clamped secret. The
+// event type matches the webhook contract's secret.expiry_reduced.
+func (w *Worker) recordExpiryReduced(ctx context.Context, id string, now time.Time) {
+	err := w.store.RecordAudit(ctx, &store.AuditEvent{
+		ID:           w.auditIDs.New(now),
+		Type:         store.AuditSecretExpiryReduced,
+		OccurredAt:   now.UTC(),
+		SecretIDHash: store.HashSecretID(id),
+	})
+	if err != nil {
+		dropped.Record(dropped.KindAuditEvent, dropped.Reason(ctx, err))
+		log.Printf("Failed to record expiry reduction audit event: %v", err)
+	}
+}
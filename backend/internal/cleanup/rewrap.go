@@ -0,0 +1,130 @@
+package cleanup
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"ots-backend/internal/crypto"
+	"ots-backend/internal/store"
+)
+
+const (
+	// rewrapMinBatch and rewrapMaxBatch bound how many data keys one
+	// rewrap batch reads
+	rewrapMinBatch = 10
+	rewrapMaxBatch = 1000
+	// rewrapTargetLatency is how long a batch should take; slower batches
+	// halve the next one and much faster ones double it
+	rewrapTargetLatency = 250 * time.Millisecond
+	// rewrapBudget bounds the time one cycle spends rewrapping, so the
+	// sweeps after it are not held up by a large rotation
+	rewrapBudget = 30 * time.Second
+)
+
+// SetEnvelopeKeys turns on rewrapping: each cycle moves data keys stored
+// under an older envelope key, or stored unwrapped, to the current one
+func (w *Worker) SetEnvelopeKeys(keys *crypto.EnvelopeKeys) {
+	w.envelope = keys
+}
+
+// rewrapDataKeys walks the stale data keys in ID order from where the last
+// cycle stopped. Between batches it sleeps as long as the batch took, so
+// the store spends at most half its time on the rotation. A key is only
+// replaced if it is still the one read, so a consume or burn that got there
+// first wins and the key is left alone.
+func (w *Worker) rewrapDataKeys(ctx context.Context) {
+	if w.envelope == nil {
+		return
+	}
+	current := w.envelope.Current()
+	if w.rewrapBatch == 0 {
+		w.rewrapBatch = rewrapMinBatch
+	}
+
+	var rewrapped, skipped int
+	defer func() {
+		if rewrapped > 0 || skipped > 0 {
+			w.reportRewrap(ctx, current, rewrapped, skipped)
+		}
+	}()
+
+	started := time.Now()
+	for time.Since(started) < rewrapBudget {
+		batchStarted := time.Now()
+		keys, err := w.store.StaleDataKeys(ctx, current, w.rewrapCursor, w.rewrapBatch)
+		if err != nil {
+			log.Printf("Failed to list data keys to rewrap: %v", err)
+			return
+		}
+
+		for _, key := range keys {
+			ok, err := w.rewrapDataKey(ctx, key)
+			if err != nil {
+				// Unknown versions stay until their key is configured
+				// again; the pass moves on
+				log.Printf("Failed to rewrap data key of secret %s: %v", store.HashSecretID(key.SecretID), err)
+				skipped++
+				continue
+			}
+			if ok {
+				rewrapped++
+			}
+		}
+
+		// A short page ends the pass; the next one starts over to pick up
+		// keys skipped in this one
+		if len(keys) < w.rewrapBatch {
+			w.rewrapCursor = ""
+			return
+		}
+		w.rewrapCursor = keys[len(keys)-1].SecretID
+
+		took := time.Since(batchStarted)
+		switch {
+		case took > rewrapTargetLatency:
+			w.rewrapBatch = max(w.rewrapBatch/2, rewrapMinBatch)
+		case took < rewrapTargetLatency/2:
+			w.rewrapBatch = min(w.rewrapBatch*2, rewrapMaxBatch)
+		}
+
+		select {
+		case <-time.After(took):
+		case <-w.stop:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// rewrapDataKey moves one data key under the current envelope key and
+// reports whether it was still stored to be replaced
+func (w *Worker) rewrapDataKey(ctx context.Context, key store.StoredDataKey) (bool, error) {
+	dataKey, err := w.envelope.Unwrap(key.SecretID, key.Key, key.Version)
+	if err != nil {
+		return false, err
+	}
+	wrapped, version, err := w.envelope.Wrap(key.SecretID, dataKey)
+	if err != nil {
+		return false, err
+	}
+	return w.store.ReplaceDataKey(ctx, key, wrapped, version)
+}
+
+// reportRewrap logs a cycle's progress and how many data keys are left
+// under other versions
+func (w *Worker) reportRewrap(ctx context.Context, current string, rewrapped, skipped int) {
+	counts, err := w.store.DataKeyVersions(ctx)
+	if err != nil {
+		log.Printf("Rewrapped %d data keys under envelope key %s; failed to count the rest: %v", rewrapped, current, err)
+		return
+	}
+	var remaining int64
+	for _, count := range counts {
+		if count.Version != current {
+			remaining += count.DataKeys
+		}
+	}
+	log.Printf("Rewrapped %d data keys under envelope key %s, skipped %d; %d remain under other versions", rewrapped, current, skipped, remaining)
+}
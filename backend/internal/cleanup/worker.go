@@ -2,32 +2,137 @@ package cleanup
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"os"
+	"sync/atomic"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+
+	"ots-backend/internal/clock"
+	"ots-backend/internal/crypto"
 	"ots-backend/internal/db"
+	"ots-backend/internal/dropped"
+	"ots-backend/internal/events"
+	"ots-backend/internal/notify"
+	"ots-backend/internal/store"
+	"ots-backend/internal/store/postgres"
+	"ots-backend/internal/tracing"
+	"ots-backend/internal/ulid"
 )
 
-// Worker periodically cleans up expired secrets
+// defaultReceiptRetention bounds how long read receipts are kept unless
+// SetReceiptRetention says otherwise
+const defaultReceiptRetention = 30 * 24 * time.Hour
+
+// droppedWorkRetention bounds how long loss records of dropped work are kept
+const droppedWorkRetention = 7 * 24 * time.Hour
+
+// Worker periodically cleans up expired secrets. On Postgres, replicas
+// elect a single leader through an advisory lock; only the leader runs
+// cleanup. Single-node backends have no db and skip the election.
 type Worker struct {
+	store    store.Store
 	db       *db.DB
 	interval time.Duration
 	stop     chan struct{}
+	clock    clock.Clock
+
+	instanceID     string
+	allowLockBreak bool
+
+	// maxTTL is the TTL ceiling in nanoseconds; reconcilePending is set
+	// when it was lowered and existing secrets must be clamped to it
+	maxTTL           atomic.Int64
+	reconcilePending atomic.Bool
+	auditIDs         ulid.Generator
+
+	// auditSample and auditWindow configure the consume auditor;
+	// auditCursor is the last consume event it cross-checked
+	auditSample     int
+	auditWindow     time.Duration
+	auditCrossCheck bool
+	auditCursor     string
+
+	// receiptRetention is how long read receipts and tombstones are kept
+	receiptRetention time.Duration
+
+	// notifier sends expiry notices; nil when notifications are off
+	notifier *notify.Service
+
+	// events tells watchers in this process of expiries; nil tells no one
+	events *events.Hub
+
+	// envelope rewraps data keys onto the current envelope key; nil when
+	// ENVELOPE_KEYS is unset. rewrapCursor is the last secret ID the
+	// current pass reached and rewrapBatch the size of its next batch.
+	envelope     *crypto.EnvelopeKeys
+	rewrapCursor string
+	rewrapBatch  int
+
+	// conn holds the advisory lock while this worker is leader
+	conn *pgxpool.Conn
+	// hung simulates a holder that stops heartbeating (tests only)
+	hung atomic.Bool
 }
 
-// NewWorker creates a new cleanup worker
+// NewWorker creates a cleanup worker for Postgres with leader election
 func NewWorker(database *db.DB, interval time.Duration) *Worker {
+	w := NewStoreWorker(postgres.New(database), interval)
+	w.db = database
+	return w
+}
+
+// NewStoreWorker creates a cleanup worker for a single-node store such as
+// SQLite, where there are no replicas to elect a leader among
+func NewStoreWorker(st store.Store, interval time.Duration) *Worker {
+	hostname, _ := os.Hostname()
+
 	return &Worker{
-		db:       database,
-		interval: interval,
-		stop:     make(chan struct{}),
+		store:      st,
+		interval:   interval,
+		stop:       make(chan struct{}),
+		clock:      clock.System,
+		instanceID: fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+
+		receiptRetention: defaultReceiptRetention,
 	}
 }
 
+// SetInstanceID overrides the identity recorded in the lock ledger
+func (w *Worker) SetInstanceID(id string) {
+	if id != "" {
+		w.instanceID = id
+	}
+}
+
+// SetClock replaces the clock that decides expiry, ack windows and
+// retention; call it before Start
+func (w *Worker) SetClock(c clock.Clock) {
+	w.clock = c
+}
+
+// SetReceiptRetention sets how long read receipts and tombstones are kept;
+// zero keeps the default
+func (w *Worker) SetReceiptRetention(d time.Duration) {
+	if d > 0 {
+		w.receiptRetention = d
+	}
+}
+
+// SetAllowLockBreak lets this worker terminate a holder whose heartbeat is stale
+func (w *Worker) SetAllowLockBreak(allow bool) {
+	w.allowLockBreak = allow
+}
+
 // Start begins the cleanup loop
 func (w *Worker) Start() {
+	defer w.releaseConn()
+
 	// Run immediate cleanup
-	w.cleanup()
+	w.tick()
 
 	ticker := time.NewTicker(w.interval)
 	defer ticker.Stop()
@@ -35,7 +140,7 @@ func (w *Worker) Start() {
 	for {
 		select {
 		case <-ticker.C:
-			w.cleanup()
+			w.tick()
 		case <-w.stop:
 			log.Println("Cleanup worker stopped")
 			return
@@ -48,21 +153,120 @@ func (w *Worker) Stop() {
 	close(w.stop)
 }
 
+// tick runs one cycle: confirm leadership, heartbeat, then clean up
+func (w *Worker) tick() {
+	if w.hung.Load() {
+		return
+	}
+
+	if w.db != nil {
+		ctx := context.Background()
+		if !w.ensureLeader(ctx) {
+			return
+		}
+
+		if err := w.heartbeat(ctx); err != nil {
+			log.Printf("Failed to heartbeat cleanup lock: %v", err)
+			w.releaseConn()
+			return
+		}
+	}
+
+	w.cleanup()
+}
+
 func (w *Worker) cleanup() {
-	ctx := context.Background()
+	// The pass is one trace, with the store's statements under it
+	ctx, span := tracing.Start(context.Background(), "cleanup.pass")
+	var err error
+	defer func() { tracing.End(span, err) }()
+
+	// One reading for the whole cycle, taken before any sweep
+	now := w.clock.Now()
 
-	result, err := w.db.Pool().Exec(ctx, `
-		DELETE FROM secrets 
-		WHERE expires_at < NOW()
-	`)
+	// Pull expiries under a tightened TTL ceiling before anything else runs
+	w.reconcileTTL(ctx, now)
 
+	// End expired secrets one by one, as reads and burns end them
+	ended := w.terminateExpired(ctx, now)
+	span.SetAttributes(attribute.Int64("ots.cleanup.expired", ended))
+	if ended > 0 {
+		log.Printf("Cleaned up %d expired secrets", ended)
+	}
+
+	// Sweep what the terminator could not end, still counting it for the day
+	rows, err := w.store.DeleteExpired(ctx, now)
 	if err != nil {
 		log.Printf("Failed to cleanup expired secrets: %v", err)
 		return
 	}
 
-	rows := result.RowsAffected()
 	if rows > 0 {
-		log.Printf("Cleaned up %d expired secrets", rows)
+		log.Printf("Swept %d expired secrets without a tombstone", rows)
+		if err := w.store.AddDailyStats(ctx, store.DailyStats{Day: now, Expired: rows}); err != nil {
+			log.Printf("Failed to record expired secrets in daily stats: %v", err)
+		}
+	}
+
+	// Burn delivered require_ack secrets whose reader never acknowledged
+	// them, and read secrets whose CONSUME_GRACE window ended
+	rows, err = w.store.BurnUnacknowledged(ctx, now)
+	if err != nil {
+		log.Printf("Failed to burn unacknowledged secrets: %v", err)
+		return
+	}
+	span.SetAttributes(attribute.Int64("ots.cleanup.unacknowledged", rows))
+
+	if rows > 0 {
+		log.Printf("Burned %d unacknowledged or grace-held secrets", rows)
+	}
+
+	// Collect ciphertext rows whose data key was shredded on consume or burn
+	rows, err = w.store.CollectShredded(ctx)
+	if err != nil {
+		log.Printf("Failed to collect shredded secrets: %v", err)
+		return
+	}
+	span.SetAttributes(attribute.Int64("ots.cleanup.shredded", rows))
+
+	if rows > 0 {
+		log.Printf("Collected %d shredded secrets", rows)
+	}
+
+	// Verify recently consumed secrets are really gone
+	w.auditConsumes(ctx, now)
+
+	// Finish namespace deletions and check finished ones left nothing behind
+	w.deleteNamespaces(ctx)
+
+	// Move data keys under older envelope keys onto the current one
+	w.rewrapDataKeys(ctx)
+
+	// Summarize what is due to expire, for operators watching debug logs
+	w.logForecast(ctx, now)
+
+	// Drop read receipts past retention
+	rows, err = w.store.PruneReceipts(ctx, now.Add(-w.receiptRetention))
+	if err != nil {
+		log.Printf("Failed to prune read receipts: %v", err)
+		return
+	}
+
+	if rows > 0 {
+		log.Printf("Pruned %d read receipts", rows)
+	}
+
+	// Record losses counted this cycle and drop records past retention
+	if err := dropped.Flush(ctx, w.store, now); err != nil {
+		log.Printf("Failed to record dropped work: %v", err)
+	}
+	rows, err = w.store.PruneDroppedWork(ctx, now.Add(-droppedWorkRetention))
+	if err != nil {
+		log.Printf("Failed to prune dropped work records: %v", err)
+		return
+	}
+
+	if rows > 0 {
+		log.Printf("Pruned %d dropped work records", rows)
 	}
 }
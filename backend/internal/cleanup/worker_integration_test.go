@@ -0,0 +1,113 @@
+//go:build integration
+
+package cleanup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+
+	"ots-backend/internal/db"
+)
+
+func TestStaleLockHolderIsBroken(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := postgres.Run(
+		ctx,
+		"postgres:16-alpine",
+		postgres.WithDatabase("ots_test"),
+		postgres.WithUsername("ots"),
+		postgres.WithPassword("ots"),
+		postgres.BasicWaitStrategies(),
+	)
+	if err != nil {
+		t.Fatalf("start postgres container: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	connString, err := container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatalf("connection string: %v", err)
+	}
+
+	database, err := db.New(connString)
+	if err != nil {
+		t.Fatalf("db.New() error: %v", err)
+	}
+	defer database.Close()
+
+	if err := database.Migrate("../../migrations"); err != nil {
+		t.Fatalf("Migrate() error: %v", err)
+	}
+
+	const interval = 250 * time.Millisecond
+
+	holder := NewWorker(database, interval)
+	holder.SetInstanceID("holder")
+	go holder.Start()
+	defer holder.Stop()
+
+	waitForLedger(t, database, "holder", 5*time.Second)
+
+	waiter := NewWorker(database, interval)
+	waiter.SetInstanceID("waiter")
+	waiter.SetAllowLockBreak(true)
+	go waiter.Start()
+	defer waiter.Stop()
+
+	// A heartbeating holder must never be broken
+	time.Sleep(5 * interval)
+	if got := ledgerHolder(t, database); got != "holder" {
+		t.Fatalf("ledger holder with healthy heartbeat = %q, want %q", got, "holder")
+	}
+
+	// Pause the holder's heartbeat while it keeps the lock and connection
+	before := GetLockMetrics().Breaks
+	holder.hung.Store(true)
+
+	// Stale after 3 intervals, broken on the next tick, acquired on the one after
+	waitForLedger(t, database, "waiter", staleAfter*interval+8*interval)
+
+	if got := GetLockMetrics().Breaks - before; got != 1 {
+		t.Errorf("lock breaks = %d, want 1", got)
+	}
+
+	var audited int
+	err = database.Pool().QueryRow(ctx,
+		`SELECT COUNT(*) FROM lock_breaks WHERE holder_instance_id = 'holder' AND broken_by = 'waiter'`).Scan(&audited)
+	if err != nil {
+		t.Fatalf("query lock_breaks: %v", err)
+	}
+	if audited != 1 {
+		t.Errorf("lock_breaks rows = %d, want 1", audited)
+	}
+}
+
+func ledgerHolder(t *testing.T, database *db.DB) string {
+	t.Helper()
+
+	var holder string
+	err := database.Pool().QueryRow(context.Background(),
+		`SELECT instance_id FROM lock_ledger WHERE lock_name = $1`, cleanupLockName).Scan(&holder)
+	if err != nil {
+		return ""
+	}
+	return holder
+}
+
+func waitForLedger(t *testing.T, database *db.DB, want string, timeout time.Duration) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if ledgerHolder(t, database) == want {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	t.Fatalf("lock ledger holder did not become %q within %s", want, timeout)
+}
@@ -0,0 +1,569 @@
+package cleanup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ots-backend/internal/crypto"
+	"ots-backend/internal/dropped"
+	"ots-backend/internal/logger"
+	"ots-backend/internal/notify"
+	"ots-backend/internal/store"
+	"ots-backend/internal/store/memory"
+	"ots-backend/internal/store/sqlite"
+	"ots-backend/internal/testutil"
+	"ots-backend/internal/ulid"
+)
+
+func TestStoreWorkerCleansSQLite(t *testing.T) {
+	ctx := context.Background()
+
+	secrets, err := sqlite.Open(filepath.Join(t.TempDir(), "ots.db"))
+	if err != nil {
+		t.Fatalf("sqlite.Open() error: %v", err)
+	}
+	defer secrets.Close()
+
+	clk := testutil.NewFakeClock(time.Now())
+	now := clk.Now()
+	for _, secret := range []*store.Secret{
+		{ID: "expiring", Ciphertext: []byte("x"), IV: []byte("iv"), ExpiresAt: now.Add(time.Minute), CreatedAt: now},
+		{ID: "live", Ciphertext: []byte("x"), IV: []byte("iv"), ExpiresAt: now.Add(time.Hour), CreatedAt: now},
+		{ID: "unacked", Ciphertext: []byte("x"), IV: []byte("iv"), ExpiresAt: now.Add(time.Hour), CreatedAt: now, RequireAck: true},
+	} {
+		if err := secrets.Create(ctx, secret); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+
+	// Delivered and held for a 30 second ack window
+	_, err = secrets.Consume(ctx, "unacked", store.ConsumeOptions{
+		Now: now,
+		Ack: &store.AckHold{TokenHash: make([]byte, 32), Deadline: now.Add(30 * time.Second)},
+	})
+	if err != nil {
+		t.Fatalf("Consume() of require_ack secret error: %v", err)
+	}
+
+	// Single-node workers never touch the Postgres election
+	worker := NewStoreWorker(secrets, time.Hour)
+	worker.SetClock(clk)
+	worker.tick()
+	if n, err := secrets.CountActive(ctx); err != nil || n != 3 {
+		t.Fatalf("CountActive() before anything lapsed = %d, %v; want 3, nil", n, err)
+	}
+
+	// The ack window runs out first, then the short TTL
+	clk.Advance(31 * time.Second)
+	worker.tick()
+	if n, err := secrets.CountActive(ctx); err != nil || n != 2 {
+		t.Fatalf("CountActive() after the ack window = %d, %v; want 2, nil", n, err)
+	}
+
+	clk.Advance(30 * time.Second)
+	worker.tick()
+	if n, err := secrets.CountActive(ctx); err != nil || n != 1 {
+		t.Fatalf("CountActive() after expiry = %d, %v; want 1, nil", n, err)
+	}
+
+	days, err := secrets.DailyStats(ctx, now, clk.Now())
+	if err != nil || len(days) != 1 || days[0].Expired != 1 {
+		t.Errorf("DailyStats() after cleanup = %+v, %v; want only the unread expired secret counted", days, err)
+	}
+
+	if _, err := secrets.Consume(ctx, "live", store.ConsumeOptions{Now: clk.Now()}); err != nil {
+		t.Fatalf("Consume() of live secret error: %v", err)
+	}
+}
+
+func TestExpiredReadLeavesRowForWorker(t *testing.T) {
+	ctx := context.Background()
+
+	secrets, err := sqlite.Open(filepath.Join(t.TempDir(), "ots.db"))
+	if err != nil {
+		t.Fatalf("sqlite.Open() error: %v", err)
+	}
+	defer secrets.Close()
+
+	clk := testutil.NewFakeClock(time.Now())
+	expiring := &store.Secret{ID: "expiring", Ciphertext: []byte("x"), IV: []byte("iv"), ExpiresAt: clk.Now().Add(time.Minute), CreatedAt: clk.Now()}
+	if err := secrets.Create(ctx, expiring); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	clk.Advance(time.Minute + time.Second)
+
+	// The read answers not found without deleting on the hot path
+	start := time.Now()
+	if _, err := secrets.Consume(ctx, expiring.ID, store.ConsumeOptions{Now: clk.Now()}); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Consume() of expired secret error = %v, want ErrNotFound", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Consume() of expired secret took %s, want it to return at once", elapsed)
+	}
+
+	// The next cycle removes the row
+	worker := NewStoreWorker(secrets, time.Hour)
+	worker.SetClock(clk)
+	worker.tick()
+	if n, err := secrets.DeleteExpired(ctx, clk.Now()); err != nil || n != 0 {
+		t.Errorf("DeleteExpired() after cleanup = %d, %v; want 0, nil", n, err)
+	}
+}
+
+func TestWorkerClampsExpiryAfterTTLCeilingDrops(t *testing.T) {
+	ctx := context.Background()
+
+	secrets, err := sqlite.Open(filepath.Join(t.TempDir(), "ots.db"))
+	if err != nil {
+		t.Fatalf("sqlite.Open() error: %v", err)
+	}
+	defer secrets.Close()
+
+	// More long-lived rows than one clamp batch, plus one within the new ceiling
+	now := time.Now()
+	long := reconcileBatch + 5
+	for i := 0; i < long; i++ {
+		secret := &store.Secret{ID: fmt.Sprintf("long-%d", i), Ciphertext: []byte("x"), IV: []byte("iv"), ExpiresAt: now.Add(20 * time.Hour), CreatedAt: now}
+		if err := secrets.Create(ctx, secret); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+	short := &store.Secret{ID: "short", Ciphertext: []byte("x"), IV: []byte("iv"), ExpiresAt: now.Add(30 * time.Minute), CreatedAt: now}
+	if err := secrets.Create(ctx, short); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	countAudit := func() int {
+		t.Helper()
+		n := 0
+		err := secrets.ScanAudit(ctx, store.AuditFilter{Types: []string{store.AuditSecretExpiryReduced}}, func(*store.AuditEvent) error {
+			n++
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("ScanAudit() error: %v", err)
+		}
+		return n
+	}
+
+	worker := NewStoreWorker(secrets, time.Hour)
+	worker.SetMaxTTL(24 * time.Hour)
+	worker.tick()
+	if n := countAudit(); n != 0 {
+		t.Fatalf("expiry_reduced events under the original ceiling = %d, want 0", n)
+	}
+
+	before := GetReconcileMetrics().ExpiryReduced
+
+	// Reload with a tighter ceiling
+	worker.SetMaxTTL(time.Hour)
+	worker.tick()
+
+	if n := countAudit(); n != long {
+		t.Errorf("expiry_reduced events = %d, want %d", n, long)
+	}
+	if got := GetReconcileMetrics().ExpiryReduced - before; got != int64(long) {
+		t.Errorf("expiry_reduced_total grew by %d, want %d", got, long)
+	}
+	if n, err := secrets.DeleteExpired(ctx, now.Add(2*time.Hour)); err != nil || n != int64(long+1) {
+		t.Fatalf("DeleteExpired() past the new ceiling = %d, %v; want %d", n, err, long+1)
+	}
+
+	// Rerunning, or loosening the ceiling again, changes nothing
+	worker.reconcilePending.Store(true)
+	worker.tick()
+	worker.SetMaxTTL(48 * time.Hour)
+	worker.tick()
+	if n := countAudit(); n != long {
+		t.Errorf("expiry_reduced events after rerun = %d, want %d", n, long)
+	}
+}
+
+// resurrectingStore injects a destroy that silently fails: secrets listed
+// in resurrect are written back after a successful consume
+type resurrectingStore struct {
+	store.Store
+	resurrect map[string]bool
+}
+
+func (s *resurrectingStore) Consume(ctx context.Context, id string, opts store.ConsumeOptions) (*store.Secret, error) {
+	secret, err := s.Store.Consume(ctx, id, opts)
+	if err == nil && s.resurrect[id] {
+		if err := s.Store.Create(ctx, secret); err != nil {
+			return nil, err
+		}
+	}
+	return secret, err
+}
+
+func TestWorkerRemovesConsumedStragglers(t *testing.T) {
+	ctx := context.Background()
+
+	secrets, err := sqlite.Open(filepath.Join(t.TempDir(), "ots.db"))
+	if err != nil {
+		t.Fatalf("sqlite.Open() error: %v", err)
+	}
+	defer secrets.Close()
+	faulty := &resurrectingStore{Store: secrets, resurrect: map[string]bool{"straggler": true}}
+
+	now := time.Now()
+	var ids ulid.Generator
+	for _, id := range []string{"consumed", "straggler"} {
+		secret := &store.Secret{ID: id, Ciphertext: []byte("x"), IV: []byte("iv"), ExpiresAt: now.Add(time.Hour), CreatedAt: now}
+		if err := faulty.Create(ctx, secret); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		opts := store.ConsumeOptions{Now: now, Receipt: &store.Receipt{ConsumedAt: now}}
+		if _, err := faulty.Consume(ctx, id, opts); err != nil {
+			t.Fatalf("Consume() error: %v", err)
+		}
+	}
+
+	// "lost" has a consume event but no receipt
+	for _, id := range []string{"consumed", "straggler", "lost"} {
+		event := &store.AuditEvent{ID: ids.New(now), Type: store.AuditSecretConsumed, OccurredAt: now, SecretIDHash: store.HashSecretID(id)}
+		if err := secrets.RecordAudit(ctx, event); err != nil {
+			t.Fatalf("RecordAudit() error: %v", err)
+		}
+	}
+
+	before := GetConsumeAuditMetrics()
+	worker := NewStoreWorker(faulty, time.Hour)
+	worker.SetConsumeAudit(10, time.Hour, true)
+	worker.tick()
+
+	after := GetConsumeAuditMetrics()
+	if got := after.StragglersRemoved - before.StragglersRemoved; got != 1 {
+		t.Errorf("stragglers_removed_total grew by %d, want 1", got)
+	}
+	if got := after.MissingReceipts - before.MissingReceipts; got != 1 {
+		t.Errorf("missing_receipts_total grew by %d, want 1", got)
+	}
+	if _, err := secrets.Consume(ctx, "straggler", store.ConsumeOptions{Now: now}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Consume() of removed straggler error = %v, want ErrNotFound", err)
+	}
+
+	var removed []string
+	err = secrets.ScanAudit(ctx, store.AuditFilter{Types: []string{store.AuditSecretStragglerRemoved}}, func(event *store.AuditEvent) error {
+		removed = append(removed, event.SecretIDHash)
+		return nil
+	})
+	if err != nil || len(removed) != 1 || removed[0] != store.HashSecretID("straggler") {
+		t.Errorf("straggler_removed events = %v, %v; want the straggler's hash", removed, err)
+	}
+
+	// Checked events are not reported again and the straggler stays gone
+	worker.tick()
+	if again := GetConsumeAuditMetrics(); again != after {
+		t.Errorf("metrics after a clean cycle = %+v, want %+v", again, after)
+	}
+}
+
+func TestWorkerRecordsAndPrunesDroppedWork(t *testing.T) {
+	ctx := context.Background()
+
+	secrets, err := sqlite.Open(filepath.Join(t.TempDir(), "ots.db"))
+	if err != nil {
+		t.Fatalf("sqlite.Open() error: %v", err)
+	}
+	defer secrets.Close()
+
+	// Drain what other tests counted so only this test's losses are written
+	if err := dropped.Flush(ctx, memory.New(), time.Now()); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+
+	clk := testutil.NewFakeClock(time.Now())
+	stale := store.DroppedWork{Kind: dropped.KindAuditEvent, Reason: dropped.ReasonFailed, Count: 9, RecordedAt: clk.Now()}
+	if err := secrets.RecordDroppedWork(ctx, []store.DroppedWork{stale}); err != nil {
+		t.Fatalf("RecordDroppedWork() error: %v", err)
+	}
+
+	// A week and more later, a new loss is counted
+	clk.Advance(droppedWorkRetention + time.Hour)
+	dropped.Record(dropped.KindListenerEvent, dropped.ReasonOverflow)
+
+	worker := NewStoreWorker(secrets, time.Hour)
+	worker.SetClock(clk)
+	worker.tick()
+
+	losses, err := secrets.DroppedWorkSince(ctx, time.Time{})
+	if err != nil {
+		t.Fatalf("DroppedWorkSince() error: %v", err)
+	}
+	if len(losses) != 1 || losses[0].Kind != dropped.KindListenerEvent || losses[0].Count != 1 {
+		t.Errorf("dropped work after a cycle = %+v, want only the new listener overflow", losses)
+	}
+}
+
+func TestWorkerFinishesNamespaceDeletions(t *testing.T) {
+	ctx := context.Background()
+
+	secrets, err := sqlite.Open(filepath.Join(t.TempDir(), "ots.db"))
+	if err != nil {
+		t.Fatalf("sqlite.Open() error: %v", err)
+	}
+	defer secrets.Close()
+
+	clk := testutil.NewFakeClock(time.Now())
+	now := clk.Now()
+	var ids ulid.Generator
+	for i := range 3 {
+		secret := &store.Secret{ID: fmt.Sprintf("tenant-%d", i), Namespace: "tenant", Ciphertext: []byte("x"), IV: []byte("iv"), ExpiresAt: now.Add(time.Hour), CreatedAt: now}
+		if err := secrets.Create(ctx, secret); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+
+	// The server marked the namespace deleted and went down before its job ran
+	if _, err := secrets.StartNamespaceDeletion(ctx, "tenant", now); err != nil {
+		t.Fatalf("StartNamespaceDeletion() error: %v", err)
+	}
+
+	worker := NewStoreWorker(secrets, time.Hour)
+	worker.SetClock(clk)
+	worker.tick()
+
+	deletion, err := secrets.NamespaceDeletion(ctx, "tenant")
+	if err != nil || !deletion.Finished() || deletion.SecretsDeleted != 3 {
+		t.Fatalf("NamespaceDeletion() after a cycle = %+v, %v; want finished with 3 secrets", deletion, err)
+	}
+
+	// An event naming the namespace turns up late and is swept next cycle
+	event := &store.AuditEvent{ID: ids.New(now), Type: store.AuditSecretCreated, OccurredAt: now, Namespace: "tenant"}
+	if err := secrets.RecordAudit(ctx, event); err != nil {
+		t.Fatalf("RecordAudit() error: %v", err)
+	}
+	worker.tick()
+	if n, err := secrets.NamespaceReferences(ctx, "tenant"); err != nil || n != 0 {
+		t.Errorf("NamespaceReferences() after the check = %d, %v; want 0", n, err)
+	}
+}
+
+func TestWorkerMailsExpiryNotices(t *testing.T) {
+	ctx := context.Background()
+	secrets := memory.New()
+	server := testutil.NewSMTPServer(t)
+
+	d := notify.NewDispatcher(10)
+	d.Add("smtp", &notify.SMTP{Addr: server.Addr, From: "ots@example.net"})
+	notifier := notify.NewService(bytes.Repeat([]byte{0x42}, 32), d)
+	notifier.Start(ctx)
+	defer notifier.Stop()
+
+	clk := testutil.NewFakeClock(time.Now())
+	now := clk.Now()
+	for _, id := range []string{"expiring", "read"} {
+		sealed, hash, err := notifier.Seal("alice@example.com")
+		if err != nil {
+			t.Fatalf("Seal() error: %v", err)
+		}
+		secret := &store.Secret{ID: id, Ciphertext: []byte("x"), IV: []byte("iv"), ExpiresAt: now.Add(time.Minute), CreatedAt: now,
+			NotifyEmail: sealed, NotifyEmailHash: hash}
+		if err := secrets.Create(ctx, secret); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+	if _, err := secrets.Consume(ctx, "read", store.ConsumeOptions{Now: now}); err != nil {
+		t.Fatalf("Consume() error: %v", err)
+	}
+
+	worker := NewStoreWorker(secrets, time.Hour)
+	worker.SetClock(clk)
+	worker.SetNotifier(notifier)
+	clk.Advance(2 * time.Minute)
+	worker.tick()
+
+	mail, ok := server.Next(5 * time.Second)
+	if !ok {
+		t.Fatal("no notice mailed for the secret that expired unread")
+	}
+	if len(mail.To) != 1 || mail.To[0] != "alice@example.com" || !strings.Contains(mail.Data, "expired unread") {
+		t.Errorf("notice to %v:\n%s\nwant an expiry notice to alice@example.com", mail.To, mail.Data)
+	}
+
+	// Neither the read secret nor a second cycle mails again
+	worker.tick()
+	if mail, ok := server.Next(200 * time.Millisecond); ok {
+		t.Errorf("second notice mailed:\n%s", mail.Data)
+	}
+}
+
+func TestWorkerRewrapsDataKeysDuringConsumes(t *testing.T) {
+	ctx := context.Background()
+
+	secrets, err := sqlite.Open(filepath.Join(t.TempDir(), "ots.db"))
+	if err != nil {
+		t.Fatalf("sqlite.Open() error: %v", err)
+	}
+	defer secrets.Close()
+
+	oldKey := bytes.Repeat([]byte{0x01}, 32)
+	newKey := bytes.Repeat([]byte{0x02}, 32)
+	old, err := crypto.NewEnvelopeKeys(oldKey)
+	if err != nil {
+		t.Fatalf("NewEnvelopeKeys() error: %v", err)
+	}
+	rotated, err := crypto.NewEnvelopeKeys(newKey, oldKey)
+	if err != nil {
+		t.Fatalf("NewEnvelopeKeys() error: %v", err)
+	}
+
+	// A quarter of the data keys predate envelope keys and are stored as
+	// they are; the rest are wrapped under the key being rotated out
+	const count = 200
+	now := time.Now()
+	dataKeys := make(map[string][]byte, count)
+	ids := make([]string, 0, count)
+	for i := range count {
+		id := fmt.Sprintf("secret-%03d", i)
+		dataKey := bytes.Repeat([]byte{byte(i)}, crypto.DataKeySize)
+		secret := &store.Secret{ID: id, Ciphertext: []byte("x"), IV: []byte("iv"), ExpiresAt: now.Add(time.Hour), CreatedAt: now, DataKey: dataKey}
+		if i%4 != 0 {
+			secret.DataKey, secret.KeyVersion, err = old.Wrap(id, dataKey)
+			if err != nil {
+				t.Fatalf("Wrap() error: %v", err)
+			}
+		}
+		if err := secrets.Create(ctx, secret); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		dataKeys[id] = dataKey
+		ids = append(ids, id)
+	}
+
+	worker := NewStoreWorker(secrets, time.Hour)
+	worker.SetEnvelopeKeys(rotated)
+
+	// Every third secret is read while the worker rewraps; each read must
+	// open whichever version it finds, and a wrapped key never goes back
+	// to being stored as it is
+	var failures []error
+	var consumed int
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < count; i += 3 {
+			id := ids[i]
+			_, err := secrets.Consume(ctx, id, store.ConsumeOptions{Now: time.Now(), Open: func(secret *store.Secret) error {
+				if secret.KeyVersion == "" && i%4 != 0 {
+					return errors.New("wrapped data key was stored unwrapped")
+				}
+				dataKey, err := rotated.Unwrap(secret.ID, secret.DataKey, secret.KeyVersion)
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(dataKey, dataKeys[id]) {
+					return errors.New("data key changed")
+				}
+				return nil
+			}})
+			if err != nil {
+				failures = append(failures, fmt.Errorf("Consume(%s): %w", id, err))
+				continue
+			}
+			consumed++
+		}
+	}()
+
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		worker.rewrapDataKeys(ctx)
+	}
+	for _, err := range failures {
+		t.Error(err)
+	}
+
+	// Once the readers stop, one more pass finishes whatever they held up
+	worker.rewrapDataKeys(ctx)
+	versions, err := secrets.DataKeyVersions(ctx)
+	if err != nil {
+		t.Fatalf("DataKeyVersions() error: %v", err)
+	}
+	want := []store.KeyVersionCount{{Version: rotated.Current(), DataKeys: int64(count - consumed)}}
+	if len(versions) != 1 || versions[0] != want[0] {
+		t.Fatalf("DataKeyVersions() after rotation = %+v, want %+v", versions, want)
+	}
+
+	// The old key can go; every remaining secret still opens
+	successor, err := crypto.NewEnvelopeKeys(newKey)
+	if err != nil {
+		t.Fatalf("NewEnvelopeKeys() error: %v", err)
+	}
+	for i, id := range ids {
+		if i%3 == 0 {
+			continue
+		}
+		_, err := secrets.Consume(ctx, id, store.ConsumeOptions{Now: time.Now(), Open: func(secret *store.Secret) error {
+			dataKey, err := successor.Unwrap(secret.ID, secret.DataKey, secret.KeyVersion)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(dataKey, dataKeys[id]) {
+				return errors.New("data key changed")
+			}
+			return nil
+		}})
+		if err != nil {
+			t.Errorf("Consume(%s) after dropping the old key: %v", id, err)
+		}
+	}
+}
+
+func TestWorkerLogsExpiryForecastAtDebug(t *testing.T) {
+	ctx := context.Background()
+	secrets := memory.New()
+
+	clk := testutil.NewFakeClock(time.Now().UTC().Truncate(time.Hour).Add(10 * time.Minute))
+	now := clk.Now()
+	for i, ttl := range []time.Duration{20 * time.Minute, 2 * time.Hour, 2*time.Hour + 10*time.Minute} {
+		secret := &store.Secret{ID: fmt.Sprintf("s%d", i), Ciphertext: []byte("xyz"), IV: []byte("iv"), ExpiresAt: now.Add(ttl), CreatedAt: now}
+		if err := secrets.Create(ctx, secret); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+
+	worker := NewStoreWorker(secrets, time.Hour)
+	worker.SetClock(clk)
+
+	var logs bytes.Buffer
+	logger.SetOutput(&logs)
+	worker.tick()
+	if strings.Contains(logs.String(), "expiry forecast") {
+		t.Errorf("forecast logged at info level:\n%s", logs.String())
+	}
+
+	t.Setenv("LOG_LEVEL", "debug")
+	logs.Reset()
+	logger.SetOutput(&logs)
+	t.Cleanup(func() { logger.SetOutput(os.Stdout) })
+	worker.tick()
+
+	var line map[string]any
+	for raw := range strings.Lines(logs.String()) {
+		if strings.Contains(raw, `"msg":"expiry forecast"`) {
+			if err := json.Unmarshal([]byte(raw), &line); err != nil {
+				t.Fatalf("decode log line: %v", err)
+			}
+		}
+	}
+	if line == nil {
+		t.Fatalf("no forecast line at debug level:\n%s", logs.String())
+	}
+	peak := now.Truncate(time.Hour).Add(2 * time.Hour).Format(time.RFC3339)
+	if line["secrets"] != 3.0 || line["bytes"] != 9.0 || line["this_hour"] != 1.0 || line["peak_count"] != 2.0 || line["peak_hour"] != peak {
+		t.Errorf("forecast line = %v, want 3 secrets of 9 bytes, 1 this hour, peak of 2 at %s", line, peak)
+	}
+}
@@ -0,0 +1,66 @@
+// Package clock abstracts the wall clock so time-dependent components can be
+// driven deterministically in tests
+package clock
+
+import "time"
+
+// Clock reports the current time
+type Clock interface {
+	Now() time.Time
+	// Monotonic returns the time since a fixed point on a clock that never
+	// steps. Windows and penalties are measured on it, so an NTP step or a
+	// VM migration neither expires them early nor stretches them.
+	Monotonic() time.Duration
+}
+
+// System is the real clock
+var System Clock = systemClock{}
+
+// origin anchors the system clock's monotonic readings
+var origin = time.Now()
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) Monotonic() time.Duration {
+	return time.Since(origin)
+}
+
+// StepDetector notices the wall clock being stepped: between two checks
+// the wall clock and the monotonic clock should advance alike, and a gap
+// between them is a step
+type StepDetector struct {
+	clock     Clock
+	threshold time.Duration
+	wall      time.Time
+	mono      time.Duration
+}
+
+// NewStepDetector creates a detector reporting steps of at least threshold
+// on clk, measured from now
+func NewStepDetector(clk Clock, threshold time.Duration) *StepDetector {
+	return &StepDetector{
+		clock:     clk,
+		threshold: threshold,
+		wall:      clk.Now().Round(0),
+		mono:      clk.Monotonic(),
+	}
+}
+
+// Check returns how far the wall clock was stepped since the last check,
+// positive for forward, or 0 for a step smaller than the threshold. Each
+// check re-anchors the detector, so a step is reported once.
+func (d *StepDetector) Check() time.Duration {
+	// Round(0) drops the monotonic reading so Sub compares wall times
+	wall, mono := d.clock.Now().Round(0), d.clock.Monotonic()
+	step := wall.Sub(d.wall) - (mono - d.mono)
+	d.wall, d.mono = wall, mono
+
+	if step.Abs() < d.threshold {
+		return 0
+	}
+	return step
+}
@@ -0,0 +1,46 @@
+package clock_test
+
+import (
+	"testing"
+	"time"
+
+	"ots-backend/internal/clock"
+	"ots-backend/internal/testutil"
+)
+
+func TestStepDetector(t *testing.T) {
+	clk := testutil.NewFakeClock(time.Date(2026, 5, 14, 9, 0, 0, 0, time.UTC))
+	detector := clock.NewStepDetector(clk, time.Second)
+
+	clk.Advance(time.Hour)
+	if step := detector.Check(); step != 0 {
+		t.Fatalf("Check() after time passed = %v, want 0", step)
+	}
+
+	clk.Step(-90 * time.Second)
+	clk.Advance(10 * time.Second)
+	if step := detector.Check(); step != -90*time.Second {
+		t.Fatalf("Check() after a backward step = %v, want -1m30s", step)
+	}
+	// The detector re-anchors, so a step is reported once
+	if step := detector.Check(); step != 0 {
+		t.Fatalf("second Check() = %v, want 0", step)
+	}
+
+	clk.Step(500 * time.Millisecond)
+	if step := detector.Check(); step != 0 {
+		t.Errorf("Check() after a step under the threshold = %v, want 0", step)
+	}
+	clk.Step(2 * time.Hour)
+	if step := detector.Check(); step != 2*time.Hour {
+		t.Errorf("Check() after a forward step = %v, want 2h", step)
+	}
+}
+
+func TestSystemMonotonicAdvances(t *testing.T) {
+	first := clock.System.Monotonic()
+	time.Sleep(time.Millisecond)
+	if second := clock.System.Monotonic(); second <= first {
+		t.Errorf("Monotonic() = %v after %v, want it to advance", second, first)
+	}
+}
@@ -0,0 +1,337 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileKind is how a config file value is checked and rendered
+type fileKind int
+
+const (
+	kindString fileKind = iota
+	kindBool
+	kindList
+	// kindCount is a non-negative integer: a size, count or limit
+	kindCount
+	// kindSeconds is a duration read as whole seconds
+	kindSeconds
+	// kindMillis is a duration read as whole milliseconds
+	kindMillis
+	// kindPositive is a number above zero, fractions allowed
+	kindPositive
+)
+
+// fileKeys lists the variables a config file may set. Keys are matched
+// case-insensitively, so database_url and DATABASE_URL are the same key.
+var fileKeys = map[string]fileKind{
+	"DATABASE_URL":                kindString,
+	"STORAGE_BACKEND":             kindString,
+	"ENV":                         kindString,
+	"PUBLIC_BASE_URL":             kindString,
+	"ADMIN_TOKEN":                 kindString,
+	"ADMIN_TOKEN_LABEL":           kindString,
+	"HEALTH_DISK_PATH":            kindString,
+	"REGION_CODE":                 kindString,
+	"SCAN_RULES":                  kindString,
+	"SCAN_RULES_FILE":             kindString,
+	"TLS_CERT_FILE":               kindString,
+	"TLS_KEY_FILE":                kindString,
+	"ACME_CACHE_DIR":              kindString,
+	"HTTP_REDIRECT_PORT":          kindString,
+	"KEY_BITS_MISSING":            kindString,
+	"INSTANCE_ID":                 kindString,
+	"CSP_POLICY":                  kindString,
+	"OTEL_EXPORTER_OTLP_ENDPOINT": kindString,
+	"NOTIFY_EMAIL_KEY":            kindString,
+	"SMTP_ADDR":                   kindString,
+	"SMTP_FROM":                   kindString,
+	"SMTP_USERNAME":               kindString,
+	"SMTP_PASSWORD":               kindString,
+	"NOTIFY_WEBHOOK_URL":          kindString,
+	"NOTIFY_WEBHOOK_KEY":          kindString,
+	"LOG_FORMAT":                  kindString,
+	"GEOIP_DATABASE":              kindString,
+	"CLIENT_HASH_KEY":             kindString,
+
+	"HEALTH_ROOT_DEPRECATED":   kindBool,
+	"DB_LISTEN_ENABLED":        kindBool,
+	"MIGRATE_ON_START":         kindBool,
+	"CRYPTO_SHREDDING_ENABLED": kindBool,
+	"ALLOW_LOCK_BREAK":         kindBool,
+	"ALLOW_OPEN_DELETE":        kindBool,
+	"ALLOW_SLUGS":              kindBool,
+	"STRICT_PRIVACY":           kindBool,
+	"REQUIRE_CLIENT_HEADER":    kindBool,
+	"CANARY_READINESS":         kindBool,
+	"AUDIT_LOG_ENABLED":        kindBool,
+	"REQUIRE_CREATE_NONCE":     kindBool,
+	"HSTS_ENABLED":             kindBool,
+	"RATE_LIMIT_ADAPTIVE":      kindBool,
+	"SMTP_REQUIRE_TLS":         kindBool,
+	"COMPRESS_BREACH_PARANOID": kindBool,
+	"DEBUG_ENDPOINTS_ENABLED":  kindBool,
+	"ERROR_HINTS":              kindBool,
+	"CONSUME_GRACE":            kindBool,
+
+	"CORS_ALLOWED_ORIGINS": kindList,
+	"TRUSTED_PROXIES":      kindList,
+	"NETWORK_LABELS":       kindList,
+	"REGION_PEERS":         kindList,
+	"ACME_DOMAINS":         kindList,
+	"NONCE_KEYS":           kindList,
+	"DOSSIER_KEYS":         kindList,
+	"LINK_KEYS":            kindList,
+	"ENVELOPE_KEYS":        kindList,
+	"GEO_ALLOW":            kindList,
+	"GEO_DENY":             kindList,
+
+	"MAX_SECRET_SIZE":            kindCount,
+	"MIN_KEY_BITS":               kindCount,
+	"RATE_LIMIT_REQUESTS":        kindCount,
+	"RATE_LIMIT_WRITE_REQUESTS":  kindCount,
+	"RATE_LIMIT_READ_REQUESTS":   kindCount,
+	"RATE_LIMIT_AGENT_REQUESTS":  kindCount,
+	"RATE_LIMIT_AUDIT_REQUESTS":  kindCount,
+	"RATE_LIMIT_REPORT_REQUESTS": kindCount,
+	"MAX_ACTIVE_SECRETS_PER_IP":  kindCount,
+	"LOOKUP_MISS_DELAY_AFTER":    kindCount,
+	"LOOKUP_MISS_REJECT_AFTER":   kindCount,
+	"CANARY_FAILURE_THRESHOLD":   kindCount,
+	"CONSUME_AUDIT_SAMPLE":       kindCount,
+	"DB_MAX_CONNS":               kindCount,
+	"DB_MIN_CONNS":               kindCount,
+	"NOTIFY_QUEUE_SIZE":          kindCount,
+	"COMPRESS_MIN_SIZE":          kindCount,
+	"LOG_SAMPLE_HEALTH":          kindCount,
+	"HTTP_MAX_HEADER_BYTES":      kindCount,
+
+	"DEFAULT_TTL":              kindSeconds,
+	"HTTP_READ_HEADER_TIMEOUT": kindSeconds,
+	"HTTP_READ_TIMEOUT":        kindSeconds,
+	"HTTP_WRITE_TIMEOUT":       kindSeconds,
+	"HTTP_IDLE_TIMEOUT":        kindSeconds,
+	"MAX_TTL":                  kindSeconds,
+	"AGENT_DEFAULT_TTL":        kindSeconds,
+	"CLEANUP_INTERVAL":         kindSeconds,
+	"RATE_LIMIT_WINDOW":        kindSeconds,
+	"RATE_LIMIT_WRITE_WINDOW":  kindSeconds,
+	"RATE_LIMIT_READ_WINDOW":   kindSeconds,
+	"RATE_LIMIT_AGENT_WINDOW":  kindSeconds,
+	"RATE_LIMIT_AUDIT_WINDOW":  kindSeconds,
+	"RATE_LIMIT_REPORT_WINDOW": kindSeconds,
+	"WARMUP_TIMEOUT":           kindSeconds,
+	"SHUTDOWN_DRAIN_DELAY":     kindSeconds,
+	"LOOKUP_MISS_WINDOW":       kindSeconds,
+	"ACK_WINDOW":               kindSeconds,
+	"CREATE_NONCE_TTL":         kindSeconds,
+	"CONSUME_AUDIT_WINDOW":     kindSeconds,
+	"RECEIPT_RETENTION":        kindSeconds,
+	"CANARY_INTERVAL":          kindSeconds,
+	"RATE_LIMIT_HALF_LIFE":     kindSeconds,
+	"CONSUME_GRACE_WINDOW":     kindSeconds,
+
+	"LOOKUP_MISS_DELAY_MS":    kindMillis,
+	"DB_STATEMENT_TIMEOUT_MS": kindMillis,
+
+	"SIZE_STATS_EPSILON": kindPositive,
+	"RATE_LIMIT_CEILING": kindPositive,
+	"RATE_LIMIT_FLOOR":   kindPositive,
+}
+
+// fileKeyAliases are friendlier file names for variables
+var fileKeyAliases = map[string]string{
+	"ENVIRONMENT": "ENV",
+}
+
+// readConfigFile reads a YAML or JSON object of settings and returns them
+// as environment strings keyed by variable name. Files ending in .json are
+// parsed as JSON, anything else as YAML. Every key must be a known
+// variable and every value must suit it: durations take whole seconds (or
+// milliseconds for the _MS keys) or a string like "90s", sizes and counts
+// must not be negative, and lists may be written as sequences. Every
+// problem is reported, each naming its key.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read CONFIG_FILE: %w", err)
+	}
+
+	var raw map[string]any
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&raw)
+	} else {
+		err = yaml.Unmarshal(data, &raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse CONFIG_FILE %s: %w", path, err)
+	}
+
+	var problems []error
+	values := make(map[string]string, len(raw))
+	for _, key := range slices.Sorted(maps.Keys(raw)) {
+		name := strings.ToUpper(key)
+		if alias, ok := fileKeyAliases[name]; ok {
+			name = alias
+		}
+		kind, ok := fileKeys[name]
+		if !ok {
+			problems = append(problems, fmt.Errorf("%s: unknown setting", key))
+			continue
+		}
+		if _, dup := values[name]; dup {
+			problems = append(problems, fmt.Errorf("%s: %s is set twice", key, name))
+			continue
+		}
+
+		text, err := fileValue(kind, raw[key])
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		values[name] = text
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("CONFIG_FILE %s: %w", path, errors.Join(problems...))
+	}
+	return values, nil
+}
+
+// fileValue checks one config file value against kind and renders it as
+// its environment string
+func fileValue(kind fileKind, value any) (string, error) {
+	if value == nil {
+		return "", nil
+	}
+
+	switch kind {
+	case kindBool:
+		switch v := value.(type) {
+		case bool:
+			return strconv.FormatBool(v), nil
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return strconv.FormatBool(b), nil
+			}
+		}
+		return "", fmt.Errorf("want true or false, got %v", value)
+	case kindList:
+		if items, ok := value.([]any); ok {
+			texts := make([]string, 0, len(items))
+			for _, item := range items {
+				text, err := scalarValue(item)
+				if err != nil {
+					return "", fmt.Errorf("list item: %w", err)
+				}
+				texts = append(texts, text)
+			}
+			return strings.Join(texts, ","), nil
+		}
+		return scalarValue(value)
+	case kindCount:
+		n, err := integerValue(value)
+		if err != nil {
+			return "", fmt.Errorf("want a whole number, got %v", value)
+		}
+		if n < 0 {
+			return "", fmt.Errorf("must not be negative, got %d", n)
+		}
+		return strconv.FormatInt(n, 10), nil
+	case kindSeconds:
+		return durationValue(value, time.Second, "90s")
+	case kindMillis:
+		return durationValue(value, time.Millisecond, "250ms")
+	case kindPositive:
+		text, err := scalarValue(value)
+		if err != nil {
+			return "", err
+		}
+		n, err := strconv.ParseFloat(text, 64)
+		if err != nil || !(n > 0) || math.IsInf(n, 1) {
+			return "", fmt.Errorf("want a number above zero, got %v", value)
+		}
+		return strconv.FormatFloat(n, 'g', -1, 64), nil
+	}
+	return scalarValue(value)
+}
+
+// durationValue reads a duration written as a whole number of units or as
+// a Go duration string, and renders it in units
+func durationValue(value any, unit time.Duration, example string) (string, error) {
+	n, err := integerValue(value)
+	if err != nil {
+		text, ok := value.(string)
+		if !ok {
+			return "", fmt.Errorf("want a whole number of %s or a duration like %q, got %v", unitName(unit), example, value)
+		}
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return "", fmt.Errorf("want a whole number of %s or a duration like %q, got %q", unitName(unit), example, text)
+		}
+		if d%unit != 0 {
+			return "", fmt.Errorf("%q is not a whole number of %s", text, unitName(unit))
+		}
+		n = int64(d / unit)
+	}
+	if n < 0 {
+		return "", fmt.Errorf("must not be negative, got %v", value)
+	}
+	return strconv.FormatInt(n, 10), nil
+}
+
+func unitName(unit time.Duration) string {
+	if unit == time.Millisecond {
+		return "milliseconds"
+	}
+	return "seconds"
+}
+
+// integerValue accepts integers from either decoder and numeric strings
+func integerValue(value any) (int64, error) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint64:
+		if v <= math.MaxInt64 {
+			return int64(v), nil
+		}
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v), nil
+		}
+	case json.Number:
+		return strconv.ParseInt(v.String(), 10, 64)
+	case string:
+		return strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	}
+	return 0, fmt.Errorf("not an integer: %v", value)
+}
+
+// scalarValue renders a string, number or boolean as written
+func scalarValue(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case int, int64, uint64, float64, bool:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("want a string, number or boolean, got %T", value)
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeYAMLConfig(t *testing.T, body string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatalf("write config file: %v", err)
+	}
+	return path
+}
+
+func TestConfigFilePrecedence(t *testing.T) {
+	const file = `
+max_secret_size: 65536
+default_ttl: 15m
+rate_limit_read_window: 90
+db_statement_timeout_ms: 2s
+environment: staging
+audit_log_enabled: true
+cors_allowed_origins:
+  - https://a.example.com
+  - https://b.example.com
+`
+	tests := []struct {
+		name  string
+		env   map[string]string
+		check func(t *testing.T, cfg *Config)
+	}{
+		{
+			name: "file over defaults",
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.MaxSecretSize != 65536 || cfg.DefaultTTL != 15*time.Minute || cfg.ReadRateLimitWindow != 90*time.Second {
+					t.Errorf("size, ttl, window = %d, %s, %s; want the file's", cfg.MaxSecretSize, cfg.DefaultTTL, cfg.ReadRateLimitWindow)
+				}
+				if cfg.DBStatementTimeout != 2*time.Second || cfg.Environment != "staging" || !cfg.AuditLogEnabled {
+					t.Errorf("timeout, env, audit = %s, %q, %v; want the file's", cfg.DBStatementTimeout, cfg.Environment, cfg.AuditLogEnabled)
+				}
+				if strings.Join(cfg.CORSAllowedOrigins, ",") != "https://a.example.com,https://b.example.com" {
+					t.Errorf("CORSAllowedOrigins = %q, want the file's list", cfg.CORSAllowedOrigins)
+				}
+			},
+		},
+		{
+			name: "environment over file",
+			env:  map[string]string{"DEFAULT_TTL": "900", "ENV": "production", "AUDIT_LOG_ENABLED": "false"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.DefaultTTL != 15*time.Minute || cfg.Environment != "production" || cfg.AuditLogEnabled {
+					t.Errorf("ttl, env, audit = %s, %q, %v; want the environment's", cfg.DefaultTTL, cfg.Environment, cfg.AuditLogEnabled)
+				}
+				if cfg.MaxSecretSize != 65536 {
+					t.Errorf("MaxSecretSize = %d, want the file's 65536", cfg.MaxSecretSize)
+				}
+			},
+		},
+		{
+			name: "defaults under both",
+			env:  map[string]string{"RATE_LIMIT_WRITE_REQUESTS": "7"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.WriteRateLimitRequests != 7 || cfg.AgentDefaultTTL != 24*time.Hour || cfg.AckWindow != 5*time.Minute {
+					t.Errorf("write requests, agent ttl, ack window = %d, %s, %s; want 7 and the defaults", cfg.WriteRateLimitRequests, cfg.AgentDefaultTTL, cfg.AckWindow)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", writeYAMLConfig(t, file))
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestConfigFileValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		// want are fragments the error must contain, starting with the key
+		want []string
+	}{
+		{name: "not yaml", body: "max_secret_size: [1", want: []string{"parse CONFIG_FILE"}},
+		{name: "not a mapping", body: "- max_secret_size", want: []string{"parse CONFIG_FILE"}},
+		{name: "bad duration", body: "default_ttl: 1 hour", want: []string{"default_ttl:", `"1 hour"`}},
+		{name: "fractional seconds", body: "ack_window: 1500ms", want: []string{"ack_window:", "whole number of seconds"}},
+		{name: "negative duration", body: "max_ttl: -1h", want: []string{"max_ttl:", "negative"}},
+		{name: "negative size", body: "max_secret_size: -1", want: []string{"max_secret_size:", "negative"}},
+		{name: "fractional count", body: "db_max_conns: 2.5", want: []string{"db_max_conns:", "whole number"}},
+		{name: "bad bool", body: "audit_log_enabled: sometimes", want: []string{"audit_log_enabled:", "true or false"}},
+		{name: "zero epsilon", body: "size_stats_epsilon: 0", want: []string{"size_stats_epsilon:", "above zero"}},
+		{name: "nested value", body: "database_url:\n  host: db", want: []string{"database_url:"}},
+		{name: "nested list item", body: "nonce_keys:\n  - [a]", want: []string{"nonce_keys:", "list item"}},
+		{name: "unknown key", body: "max_secrets_size: 1", want: []string{"max_secrets_size: unknown setting"}},
+		{name: "set twice", body: "env: staging\nenvironment: production", want: []string{"set twice"}},
+		{
+			name: "every problem reported",
+			body: "default_ttl: soon\nmax_secret_size: -5",
+			want: []string{"default_ttl:", "max_secret_size:"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", writeYAMLConfig(t, tt.body))
+
+			_, err := Load()
+			if err == nil {
+				t.Fatal("Load() error = nil, want a validation error")
+			}
+			for _, fragment := range tt.want {
+				if !strings.Contains(err.Error(), fragment) {
+					t.Errorf("Load() error = %q, want it to mention %q", err, fragment)
+				}
+			}
+		})
+	}
+}
+
+// TestConfigFileKnowsEveryVariable keeps fileKeys in step with load, so a
+// new variable cannot be added without the file accepting it
+func TestConfigFileKnowsEveryVariable(t *testing.T) {
+	load(func(key string) string {
+		if _, ok := fileKeys[key]; !ok {
+			t.Errorf("load reads %s, which config files cannot set", key)
+		}
+		return ""
+	})
+}
+
+func TestExampleConfigLoads(t *testing.T) {
+	t.Setenv("CONFIG_FILE", filepath.Join("..", "..", "config.example.yaml"))
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() of the example config error: %v", err)
+	}
+	if cfg.MaxTTL != 168*time.Hour || cfg.DBStatementTimeout != 5*time.Second || cfg.Environment != "production" {
+		t.Errorf("example MaxTTL, DBStatementTimeout, Environment = %s, %s, %q", cfg.MaxTTL, cfg.DBStatementTimeout, cfg.Environment)
+	}
+}
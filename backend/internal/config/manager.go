@@ -0,0 +1,58 @@
+package config
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Manager holds the live configuration. Request paths read it with Current
+// on every request; Reload swaps in a freshly loaded Config whole, so a
+// reader never sees a mix of old and new values.
+type Manager struct {
+	current atomic.Pointer[Config]
+	mu      sync.Mutex
+}
+
+// NewManager starts a manager at cfg
+func NewManager(cfg *Config) *Manager {
+	m := &Manager{}
+	m.current.Store(cfg)
+	return m
+}
+
+// Current returns the active configuration. Callers must not modify it.
+func (m *Manager) Current() *Config {
+	return m.current.Load()
+}
+
+// Set replaces the active configuration with cfg, keeping the storage
+// settings of the current one since the open connection cannot follow a
+// change. It returns the names of the settings it kept back.
+func (m *Manager) Set(cfg *Config) (ignored []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old := m.current.Load()
+	next := *cfg
+	if next.DatabaseURL != old.DatabaseURL {
+		next.DatabaseURL = old.DatabaseURL
+		ignored = append(ignored, "DATABASE_URL")
+	}
+	if next.StorageBackend != old.StorageBackend {
+		next.StorageBackend = old.StorageBackend
+		ignored = append(ignored, "STORAGE_BACKEND")
+	}
+	m.current.Store(&next)
+	return ignored
+}
+
+// Reload re-reads the environment and CONFIG_FILE and makes the result the
+// active configuration; see Set for what is kept back. When the file cannot
+// be read the active configuration stays in force.
+func (m *Manager) Reload() (ignored []string, err error) {
+	cfg, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	return m.Set(cfg), nil
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, body string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatalf("write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	path := writeConfigFile(t, `{
+		"MAX_SECRET_SIZE": 65536,
+		"DEFAULT_TTL": "600",
+		"AUDIT_LOG_ENABLED": true,
+		"CORS_ALLOWED_ORIGINS": ["https://a.example.com", "https://b.example.com"]
+	}`)
+	t.Setenv("CONFIG_FILE", path)
+	// The environment wins over the file
+	t.Setenv("DEFAULT_TTL", "900")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.MaxSecretSize != 65536 || !cfg.AuditLogEnabled {
+		t.Errorf("Load() MaxSecretSize = %d, AuditLogEnabled = %v; want the file's values", cfg.MaxSecretSize, cfg.AuditLogEnabled)
+	}
+	if cfg.DefaultTTL.Seconds() != 900 {
+		t.Errorf("Load() DefaultTTL = %s, want the environment's 15m", cfg.DefaultTTL)
+	}
+	if want := []string{"https://a.example.com", "https://b.example.com"}; !reflect.DeepEqual(cfg.CORSAllowedOrigins, want) {
+		t.Errorf("Load() CORSAllowedOrigins = %q, want %q", cfg.CORSAllowedOrigins, want)
+	}
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"not json", `MAX_SECRET_SIZE=1`},
+		{"nested object", `{"MAX_SECRET_SIZE": {"value": 1}}`},
+		{"nested list", `{"CORS_ALLOWED_ORIGINS": [["a"]]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", writeConfigFile(t, tt.body))
+			if _, err := Load(); err == nil {
+				t.Error("Load() error = nil, want a parse error")
+			}
+		})
+	}
+
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "missing.json"))
+	if _, err := Load(); err == nil {
+		t.Error("Load() with a missing file error = nil, want an error")
+	}
+}
+
+func TestManagerReload(t *testing.T) {
+	path := writeConfigFile(t, `{"MAX_SECRET_SIZE": 1024}`)
+	t.Setenv("CONFIG_FILE", path)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	m := NewManager(cfg)
+
+	// Edit the file and point the database elsewhere
+	os.WriteFile(path, []byte(`{"MAX_SECRET_SIZE": 2048, "DATABASE_URL": "postgres://elsewhere/ots"}`), 0o600)
+	ignored, err := m.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+
+	current := m.Current()
+	if current.MaxSecretSize != 2048 {
+		t.Errorf("MaxSecretSize after reload = %d, want 2048", current.MaxSecretSize)
+	}
+	if current.DatabaseURL != cfg.DatabaseURL || !reflect.DeepEqual(ignored, []string{"DATABASE_URL"}) {
+		t.Errorf("reload DatabaseURL = %q, ignored = %v; want %q kept and reported", current.DatabaseURL, ignored, cfg.DatabaseURL)
+	}
+	if cfg.MaxSecretSize != 1024 {
+		t.Errorf("Reload() modified the previous Config: MaxSecretSize = %d", cfg.MaxSecretSize)
+	}
+
+	// A broken file leaves the running configuration alone
+	os.WriteFile(path, []byte(`{`), 0o600)
+	if _, err := m.Reload(); err == nil {
+		t.Error("Reload() of a broken file error = nil, want an error")
+	}
+	if m.Current() != current {
+		t.Error("Reload() of a broken file replaced the configuration")
+	}
+}
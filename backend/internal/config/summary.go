@@ -0,0 +1,51 @@
+package config
+
+import (
+	"reflect"
+	"time"
+)
+
+// Redacted stands in for a sensitive value that is set
+const Redacted = "[redacted]"
+
+// sensitiveFields are Config fields never shown in a summary: tokens, keys,
+// passwords and URLs that may carry credentials
+var sensitiveFields = map[string]bool{
+	"DatabaseURL":      true,
+	"AdminToken":       true,
+	"NonceKeys":        true,
+	"DossierKeys":      true,
+	"LinkKeys":         true,
+	"EnvelopeKeys":     true,
+	"ClientHashKey":    true,
+	"NotifyEmailKey":   true,
+	"SMTPUsername":     true,
+	"SMTPPassword":     true,
+	"NotifyWebhookURL": true,
+	"NotifyWebhookKey": true,
+}
+
+// Summary returns every setting keyed by field name, for operators
+// debugging a running server. Sensitive values read Redacted when set and
+// "" when not, so whether they are configured still shows; durations are
+// rendered like "15s".
+func (c *Config) Summary() map[string]any {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	summary := make(map[string]any, t.NumField())
+	for i := range t.NumField() {
+		name, value := t.Field(i).Name, v.Field(i)
+		switch {
+		case sensitiveFields[name]:
+			summary[name] = ""
+			if value.Len() > 0 {
+				summary[name] = Redacted
+			}
+		case value.Type() == reflect.TypeFor[time.Duration]():
+			summary[name] = time.Duration(value.Int()).String()
+		default:
+			summary[name] = value.Interface()
+		}
+	}
+	return summary
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// credentialLike matches field names that usually hold a credential
+var credentialLike = regexp.MustCompile(`Token$|Keys?$|Password|Username|URL$`)
+
+// publicFields look like credentials but are safe to show
+var publicFields = map[string]bool{
+	"PublicBaseURL": true,
+}
+
+// TestSummaryKnowsEverySensitiveField makes each new credential-like field
+// be classified, so none reaches a summary by omission
+func TestSummaryKnowsEverySensitiveField(t *testing.T) {
+	typ := reflect.TypeFor[Config]()
+	for i := range typ.NumField() {
+		name := typ.Field(i).Name
+		if credentialLike.MatchString(name) && !sensitiveFields[name] && !publicFields[name] {
+			t.Errorf("Config.%s looks like a credential; add it to sensitiveFields or publicFields", name)
+		}
+	}
+	for name := range sensitiveFields {
+		if _, ok := typ.FieldByName(name); !ok {
+			t.Errorf("sensitiveFields names %s, which Config does not have", name)
+		}
+	}
+}
+
+func TestSummaryRedactsSecrets(t *testing.T) {
+	cfg := &Config{
+		DatabaseURL:     "postgres://ots:hunter2@db/ots",
+		AdminToken:      "admin-secret",
+		AdminTokenLabel: "ops",
+		LinkKeys:        []string{"a2V5"},
+		MaxSecretSize:   65536,
+		DefaultTTL:      15 * time.Minute,
+		AllowSlugs:      true,
+	}
+
+	summary := cfg.Summary()
+	for name, want := range map[string]any{
+		"DatabaseURL":     Redacted,
+		"AdminToken":      Redacted,
+		"LinkKeys":        Redacted,
+		"NonceKeys":       "",
+		"SMTPPassword":    "",
+		"AdminTokenLabel": "ops",
+		"MaxSecretSize":   65536,
+		"DefaultTTL":      "15m0s",
+		"AllowSlugs":      true,
+	} {
+		if got := summary[name]; !reflect.DeepEqual(got, want) {
+			t.Errorf("Summary()[%s] = %#v, want %#v", name, got, want)
+		}
+	}
+	if len(summary) != reflect.TypeFor[Config]().NumField() {
+		t.Errorf("Summary() has %d settings, want one per Config field", len(summary))
+	}
+}
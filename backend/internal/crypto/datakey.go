@@ -0,0 +1,56 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// DataKeySize is the byte length of per-secret data keys
+const DataKeySize = aesKeySize
+
+// WrapWithDataKey encrypts data under a freshly generated random data key.
+// The returned blob is nonce || AES-256-GCM ciphertext. Destroying the data
+// key makes the blob unrecoverable, which is how secrets are crypto-shredded.
+func WrapWithDataKey(data []byte) (wrapped, dataKey []byte, err error) {
+	dataKey = make([]byte, DataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, fmt.Errorf("generate data key: %w", err)
+	}
+
+	wrapped, err = WrapWithKey(data, dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return wrapped, dataKey, nil
+}
+
+// WrapWithKey encrypts data under an existing data key, so several blobs
+// belonging to one secret are shredded together
+func WrapWithKey(data, dataKey []byte) ([]byte, error) {
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nil, nil, data, nil), nil
+}
+
+// UnwrapWithDataKey reverses WrapWithDataKey
+func UnwrapWithDataKey(wrapped, dataKey []byte) ([]byte, error) {
+	if len(wrapped) < gcmNonceSize {
+		return nil, fmt.Errorf("wrapped data too short")
+	}
+
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := aead.Open(nil, nil, wrapped, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap: %w", err)
+	}
+
+	return data, nil
+}
@@ -3,12 +3,13 @@ package crypto
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/pbkdf2"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
 
-	"golang.org/x/crypto/pbkdf2"
+	"ots-backend/internal/sensitive"
 )
 
 const (
@@ -18,11 +19,14 @@ const (
 	saltSize         = 16
 )
 
+// ShareKeyBits is the length of the keys generated by EncryptPlaintext
+const ShareKeyBits = aesKeySize * 8
+
 type EncryptedSecret struct {
-	Ciphertext []byte
-	IV         []byte
-	Salt       []byte
-	ShareKey   string
+	Ciphertext sensitive.Bytes
+	IV         sensitive.Bytes
+	Salt       sensitive.Bytes
+	ShareKey   sensitive.String
 }
 
 func EncryptPlaintext(plaintext []byte) (*EncryptedSecret, error) {
@@ -39,7 +43,7 @@ func EncryptPlaintext(plaintext []byte) (*EncryptedSecret, error) {
 	return &EncryptedSecret{
 		Ciphertext: ciphertext,
 		IV:         iv,
-		ShareKey:   base64.StdEncoding.EncodeToString(key),
+		ShareKey:   sensitive.String(base64.StdEncoding.EncodeToString(key)),
 	}, nil
 }
 
@@ -53,7 +57,11 @@ func EncryptPlaintextWithPassphrase(plaintext []byte, passphrase string) (*Encry
 		return nil, fmt.Errorf("generate salt: %w", err)
 	}
 
-	key := pbkdf2.Key([]byte(passphrase), salt, pbkdf2Iterations, aesKeySize, sha256.New)
+	key, err := derivePassphraseKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
 	ciphertext, iv, err := encrypt(plaintext, key)
 	if err != nil {
 		return nil, err
@@ -66,22 +74,41 @@ func EncryptPlaintextWithPassphrase(plaintext []byte, passphrase string) (*Encry
 	}, nil
 }
 
-func encrypt(plaintext, key []byte) ([]byte, []byte, error) {
-	block, err := aes.NewCipher(key)
+// derivePassphraseKey stretches a passphrase with PBKDF2-HMAC-SHA256. The
+// standard library implementation sits inside the Go Cryptographic Module, so
+// it stays approved in FIPS 140-3 mode.
+func derivePassphraseKey(passphrase string, salt []byte) ([]byte, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, pbkdf2Iterations, aesKeySize)
 	if err != nil {
-		return nil, nil, fmt.Errorf("create cipher: %w", err)
+		return nil, fmt.Errorf("derive key: %w", err)
 	}
+	return key, nil
+}
 
-	aead, err := cipher.NewGCM(block)
+// encrypt seals plaintext with AES-GCM under a nonce the module generates
+// itself. FIPS-only mode refuses caller-chosen GCM nonces, so the IV is split
+// off the sealed output rather than passed in.
+func encrypt(plaintext, key []byte) ([]byte, []byte, error) {
+	aead, err := newGCM(key)
 	if err != nil {
-		return nil, nil, fmt.Errorf("create gcm: %w", err)
+		return nil, nil, err
 	}
 
-	iv := make([]byte, gcmNonceSize)
-	if _, err := rand.Read(iv); err != nil {
-		return nil, nil, fmt.Errorf("generate iv: %w", err)
+	sealed := aead.Seal(nil, nil, plaintext, nil)
+	return sealed[gcmNonceSize:], sealed[:gcmNonceSize], nil
+}
+
+// newGCM returns AES-GCM that prepends a random nonce on Seal and reads it
+// back on Open
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
 	}
 
-	ciphertext := aead.Seal(nil, iv, plaintext, nil)
-	return ciphertext, iv, nil
+	aead, err := cipher.NewGCMWithRandomNonce(block)
+	if err != nil {
+		return nil, fmt.Errorf("create gcm: %w", err)
+	}
+	return aead, nil
 }
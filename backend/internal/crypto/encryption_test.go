@@ -23,7 +23,7 @@ func TestEncryptPlaintext(t *testing.T) {
 		t.Fatal("EncryptPlaintext() returned empty share key")
 	}
 
-	key, err := base64.StdEncoding.DecodeString(result.ShareKey)
+	key, err := base64.StdEncoding.DecodeString(string(result.ShareKey))
 	if err != nil {
 		t.Fatalf("DecodeString() error = %v", err)
 	}
@@ -55,3 +55,30 @@ func TestEncryptPlaintextWithPassphrase(t *testing.T) {
 		t.Fatal("EncryptPlaintextWithPassphrase() should not return a share key")
 	}
 }
+
+func TestWrapWithDataKeyRoundTrip(t *testing.T) {
+	payload := []byte("client ciphertext bytes")
+
+	wrapped, dataKey, err := WrapWithDataKey(payload)
+	if err != nil {
+		t.Fatalf("WrapWithDataKey() error = %v", err)
+	}
+
+	if len(dataKey) != DataKeySize {
+		t.Fatalf("data key length = %d, want %d", len(dataKey), DataKeySize)
+	}
+
+	unwrapped, err := UnwrapWithDataKey(wrapped, dataKey)
+	if err != nil {
+		t.Fatalf("UnwrapWithDataKey() error = %v", err)
+	}
+
+	if string(unwrapped) != string(payload) {
+		t.Fatalf("UnwrapWithDataKey() = %q, want %q", unwrapped, payload)
+	}
+
+	// A zeroed (shredded) key must not decrypt the blob
+	if _, err := UnwrapWithDataKey(wrapped, make([]byte, DataKeySize)); err == nil {
+		t.Fatal("UnwrapWithDataKey() succeeded with a shredded key")
+	}
+}
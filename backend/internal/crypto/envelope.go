@@ -0,0 +1,114 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnknownKeyVersion indicates a data key wrapped under an envelope key
+// that is no longer configured
+var ErrUnknownKeyVersion = errors.New("unknown envelope key version")
+
+// EnvelopeKeys holds the AES-256 keys that wrap per-secret data keys at
+// rest. The first key wraps and every key unwraps, so a key is rotated by
+// prepending its successor and dropping it once no data key is left under
+// it. Each key is known by its version, a short hash of the key, which is
+// stored next to every data key it wraps.
+type EnvelopeKeys struct {
+	keys     [][]byte
+	versions []string
+}
+
+// NewEnvelopeKeys creates envelope keys wrapping with the first key
+func NewEnvelopeKeys(keys ...[]byte) (*EnvelopeKeys, error) {
+	if len(keys) == 0 {
+		return nil, ErrEmptyKeyring
+	}
+	e := &EnvelopeKeys{}
+	for i, key := range keys {
+		if len(key) != aesKeySize {
+			return nil, fmt.Errorf("envelope key %d is %d bytes, want %d", i, len(key), aesKeySize)
+		}
+		version := KeyVersion(key)
+		for _, seen := range e.versions {
+			if seen == version {
+				return nil, fmt.Errorf("envelope key %d repeats an earlier key", i)
+			}
+		}
+		e.keys = append(e.keys, key)
+		e.versions = append(e.versions, version)
+	}
+	return e, nil
+}
+
+// ParseEnvelopeKeys decodes standard base64 keys, wrapping key first
+func ParseEnvelopeKeys(values []string) (*EnvelopeKeys, error) {
+	keys := make([][]byte, 0, len(values))
+	for i, value := range values {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("envelope key %d: %w", i, err)
+		}
+		keys = append(keys, key)
+	}
+	return NewEnvelopeKeys(keys...)
+}
+
+// KeyVersion names an envelope key by the first 8 bytes of its SHA-256, so
+// the name does not depend on the key's place in ENVELOPE_KEYS
+func KeyVersion(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Current returns the version of the wrapping key
+func (e *EnvelopeKeys) Current() string {
+	return e.versions[0]
+}
+
+// Versions returns every configured version, wrapping key first
+func (e *EnvelopeKeys) Versions() []string {
+	return append([]string(nil), e.versions...)
+}
+
+// Wrap encrypts the data key of secret id under the wrapping key. The ID is
+// authenticated with it, so a wrapped key copied to another row will not
+// open.
+func (e *EnvelopeKeys) Wrap(id string, dataKey []byte) (wrapped []byte, version string, err error) {
+	aead, err := newGCM(e.keys[0])
+	if err != nil {
+		return nil, "", err
+	}
+	return aead.Seal(nil, nil, dataKey, []byte(id)), e.versions[0], nil
+}
+
+// Unwrap reverses Wrap for a data key wrapped under version. An empty
+// version marks a data key stored before envelope keys were configured,
+// which is returned as it is.
+func (e *EnvelopeKeys) Unwrap(id string, wrapped []byte, version string) ([]byte, error) {
+	if version == "" {
+		return wrapped, nil
+	}
+	for i, v := range e.versions {
+		if v != version {
+			continue
+		}
+		if len(wrapped) < gcmNonceSize {
+			return nil, fmt.Errorf("wrapped data key too short")
+		}
+		aead, err := newGCM(e.keys[i])
+		if err != nil {
+			return nil, err
+		}
+		dataKey, err := aead.Open(nil, nil, wrapped, []byte(id))
+		if err != nil {
+			return nil, fmt.Errorf("unwrap data key: %w", err)
+		}
+		return dataKey, nil
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownKeyVersion, version)
+}
@@ -0,0 +1,68 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestEnvelopeKeysRotation(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{0x01}, aesKeySize)
+	newKey := bytes.Repeat([]byte{0x02}, aesKeySize)
+	dataKey := bytes.Repeat([]byte{0x0d}, DataKeySize)
+
+	old, err := NewEnvelopeKeys(oldKey)
+	if err != nil {
+		t.Fatalf("NewEnvelopeKeys() error = %v", err)
+	}
+	wrapped, version, err := old.Wrap("secret-1", dataKey)
+	if err != nil {
+		t.Fatalf("Wrap() error = %v", err)
+	}
+	if version != KeyVersion(oldKey) || bytes.Contains(wrapped, dataKey) {
+		t.Fatalf("Wrap() = %x, %q; want the key sealed under %q", wrapped, version, KeyVersion(oldKey))
+	}
+
+	// The successor wraps, but keys under the old one still open
+	rotated, err := NewEnvelopeKeys(newKey, oldKey)
+	if err != nil {
+		t.Fatalf("NewEnvelopeKeys() error = %v", err)
+	}
+	if got, err := rotated.Unwrap("secret-1", wrapped, version); err != nil || !bytes.Equal(got, dataKey) {
+		t.Errorf("Unwrap() = %x, %v; want the data key", got, err)
+	}
+	if rotated.Current() != KeyVersion(newKey) {
+		t.Errorf("Current() = %q, want the new key's version", rotated.Current())
+	}
+
+	// A wrapped key is bound to its secret
+	if _, err := rotated.Unwrap("secret-2", wrapped, version); err == nil {
+		t.Error("Unwrap() opened a data key for another secret")
+	}
+
+	// Once the old key is dropped its data keys are unknown
+	dropped, _ := NewEnvelopeKeys(newKey)
+	if _, err := dropped.Unwrap("secret-1", wrapped, version); !errors.Is(err, ErrUnknownKeyVersion) {
+		t.Errorf("Unwrap() under a dropped key error = %v, want ErrUnknownKeyVersion", err)
+	}
+
+	// Data keys stored before envelope keys pass through
+	if got, err := dropped.Unwrap("secret-1", dataKey, ""); err != nil || !bytes.Equal(got, dataKey) {
+		t.Errorf("Unwrap() of an unversioned key = %x, %v; want it unchanged", got, err)
+	}
+}
+
+func TestParseEnvelopeKeys(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0x03}, aesKeySize))
+	if _, err := ParseEnvelopeKeys([]string{key}); err != nil {
+		t.Errorf("ParseEnvelopeKeys() error = %v", err)
+	}
+
+	long := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0x04}, aesKeySize+1))
+	for _, values := range [][]string{nil, {"not base64!"}, {long}, {key, key}} {
+		if _, err := ParseEnvelopeKeys(values); err == nil {
+			t.Errorf("ParseEnvelopeKeys(%q) error = nil, want error", values)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+)
+
+// ExportSaltSize is the byte length of the salt an export key is derived with
+const ExportSaltSize = saltSize
+
+// ExportCipher seals the records of a secret export under a key stretched
+// from an operator's passphrase with PBKDF2-HMAC-SHA256. Each record is
+// bound to its position, so records cannot be reordered or dropped without
+// Open failing; another export has another salt and so another key.
+type ExportCipher struct {
+	aead cipher.AEAD
+}
+
+// NewExportSalt returns a random salt for NewExportCipher
+func NewExportSalt() ([]byte, error) {
+	salt := make([]byte, ExportSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("generate salt: %w", err)
+	}
+	return salt, nil
+}
+
+// NewExportCipher derives the export key from passphrase and salt
+func NewExportCipher(passphrase string, salt []byte) (*ExportCipher, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase is required")
+	}
+	if len(salt) != ExportSaltSize {
+		return nil, fmt.Errorf("export salt must be %d bytes", ExportSaltSize)
+	}
+
+	key, err := derivePassphraseKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return &ExportCipher{aead: aead}, nil
+}
+
+// Seal encrypts record number seq. The result is nonce || AES-256-GCM
+// ciphertext.
+func (c *ExportCipher) Seal(seq uint64, record []byte) []byte {
+	return c.aead.Seal(nil, nil, record, exportAAD(seq))
+}
+
+// Open reverses Seal for record number seq. A wrong passphrase and a
+// damaged or misplaced record fail alike.
+func (c *ExportCipher) Open(seq uint64, sealed []byte) ([]byte, error) {
+	if len(sealed) < gcmNonceSize {
+		return nil, fmt.Errorf("sealed record too short")
+	}
+	record, err := c.aead.Open(nil, nil, sealed, exportAAD(seq))
+	if err != nil {
+		return nil, fmt.Errorf("open record: %w", err)
+	}
+	return record, nil
+}
+
+func exportAAD(seq uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, seq)
+}
@@ -0,0 +1,53 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestExportCipherRoundTrip(t *testing.T) {
+	salt, err := NewExportSalt()
+	if err != nil {
+		t.Fatalf("NewExportSalt() error = %v", err)
+	}
+	sealer, err := NewExportCipher("correct horse", salt)
+	if err != nil {
+		t.Fatalf("NewExportCipher() error = %v", err)
+	}
+	record := []byte(`{"id":"abc"}`)
+	sealed := sealer.Seal(1, record)
+	if bytes.Contains(sealed, record) {
+		t.Fatal("Seal() left the record readable")
+	}
+
+	opener, _ := NewExportCipher("correct horse", salt)
+	if got, err := opener.Open(1, sealed); err != nil || !bytes.Equal(got, record) {
+		t.Errorf("Open() = %q, %v; want the record", got, err)
+	}
+
+	// A record only opens at its own position
+	if _, err := opener.Open(2, sealed); err == nil {
+		t.Error("Open() accepted a record moved to another position")
+	}
+
+	// Another passphrase or salt derives another key
+	wrong, _ := NewExportCipher("wrong horse", salt)
+	if _, err := wrong.Open(1, sealed); err == nil {
+		t.Error("Open() accepted the wrong passphrase")
+	}
+	otherSalt, _ := NewExportSalt()
+	other, _ := NewExportCipher("correct horse", otherSalt)
+	if _, err := other.Open(1, sealed); err == nil {
+		t.Error("Open() accepted a record from an export with another salt")
+	}
+}
+
+func TestNewExportCipherRequiresPassphraseAndSalt(t *testing.T) {
+	salt := bytes.Repeat([]byte{0x01}, ExportSaltSize)
+	if _, err := NewExportCipher("", salt); err == nil {
+		t.Error("NewExportCipher() accepted an empty passphrase")
+	}
+	if _, err := NewExportCipher("pass", salt[:4]); err == nil {
+		t.Error("NewExportCipher() accepted a short salt")
+	}
+}
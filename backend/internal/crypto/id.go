@@ -2,6 +2,8 @@ package crypto
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"fmt"
 )
@@ -20,3 +22,57 @@ func GenerateSecretID() (string, error) {
 	// Use URL-safe base64 encoding
 	return base64.RawURLEncoding.EncodeToString(bytes), nil
 }
+
+// GenerateRegionalSecretID generates a secret ID prefixed with region, so
+// other deployments can tell where it was created. An empty region gives an
+// unprefixed ID.
+func GenerateRegionalSecretID(region string) (string, error) {
+	id, err := GenerateSecretID()
+	if err != nil {
+		return "", err
+	}
+	return region + id, nil
+}
+
+// ManagementTokenLength is the byte length of management tokens (256 bits)
+const ManagementTokenLength = 32
+
+// GenerateManagementToken returns a random management token and its SHA-256
+// hash. Only the hash is stored; the token is shown to the creator once.
+func GenerateManagementToken() (string, []byte, error) {
+	bytes := make([]byte, ManagementTokenLength)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", nil, fmt.Errorf("failed to generate management token: %w", err)
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(bytes)
+	return token, HashManagementToken(token), nil
+}
+
+// HashManagementToken returns the stored form of a management token
+func HashManagementToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// VerifyManagementToken compares token against a stored hash in constant time
+func VerifyManagementToken(token string, hash []byte) bool {
+	if token == "" || len(hash) != sha256.Size {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare(HashManagementToken(token), hash) == 1
+}
+
+// GenerateAckToken returns a random ack token and its SHA-256 hash. It is
+// handed to the reader of a require_ack secret and hashed like a management
+// token.
+func GenerateAckToken() (string, []byte, error) {
+	bytes := make([]byte, ManagementTokenLength)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", nil, fmt.Errorf("failed to generate ack token: %w", err)
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(bytes)
+	return token, HashManagementToken(token), nil
+}
@@ -37,3 +37,26 @@ func TestGenerateSecretIDUniqueness(t *testing.T) {
 		ids[id] = true
 	}
 }
+
+func TestManagementTokenVerify(t *testing.T) {
+	token, hash, err := GenerateManagementToken()
+	if err != nil {
+		t.Fatalf("GenerateManagementToken() error = %v", err)
+	}
+
+	if !VerifyManagementToken(token, hash) {
+		t.Error("VerifyManagementToken() rejected the issued token")
+	}
+
+	if VerifyManagementToken(token+"x", hash) {
+		t.Error("VerifyManagementToken() accepted a wrong token")
+	}
+
+	if VerifyManagementToken("", hash) {
+		t.Error("VerifyManagementToken() accepted an empty token")
+	}
+
+	if VerifyManagementToken(token, nil) {
+		t.Error("VerifyManagementToken() accepted a token for a secret without a hash")
+	}
+}
@@ -0,0 +1,83 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MinKeyringKeySize is the shortest HMAC key a Keyring accepts
+const MinKeyringKeySize = 32
+
+// ErrEmptyKeyring indicates a keyring was built without keys
+var ErrEmptyKeyring = errors.New("keyring has no keys")
+
+// Keyring holds the HMAC-SHA256 keys for tokens the server issues and later
+// checks statelessly. The first key signs and every key verifies, so a key
+// is rotated by prepending its successor and dropping it once the longest
+// token lifetime has passed.
+type Keyring struct {
+	keys [][]byte
+}
+
+// NewKeyring creates a keyring signing with the first key
+func NewKeyring(keys ...[]byte) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, ErrEmptyKeyring
+	}
+	for i, key := range keys {
+		if len(key) < MinKeyringKeySize {
+			return nil, fmt.Errorf("keyring key %d is %d bytes, want at least %d", i, len(key), MinKeyringKeySize)
+		}
+	}
+	return &Keyring{keys: keys}, nil
+}
+
+// ParseKeyring decodes standard base64 keys, signing key first
+func ParseKeyring(values []string) (*Keyring, error) {
+	keys := make([][]byte, 0, len(values))
+	for i, value := range values {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("keyring key %d: %w", i, err)
+		}
+		keys = append(keys, key)
+	}
+	return NewKeyring(keys...)
+}
+
+// EphemeralKeyring creates a keyring with one random key. Its tokens do not
+// survive a restart and are not accepted by other replicas.
+func EphemeralKeyring() (*Keyring, error) {
+	key := make([]byte, MinKeyringKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generate keyring key: %w", err)
+	}
+	return NewKeyring(key)
+}
+
+// Sign returns the MAC of msg under the signing key
+func (k *Keyring) Sign(msg []byte) []byte {
+	return keyringMAC(k.keys[0], msg)
+}
+
+// Verify reports whether mac is the MAC of msg under any key
+func (k *Keyring) Verify(msg, mac []byte) bool {
+	valid := false
+	for _, key := range k.keys {
+		if hmac.Equal(keyringMAC(key, msg), mac) {
+			valid = true
+		}
+	}
+	return valid
+}
+
+func keyringMAC(key, msg []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(msg)
+	return h.Sum(nil)
+}
@@ -0,0 +1,58 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestKeyringRotation(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{0x01}, MinKeyringKeySize)
+	newKey := bytes.Repeat([]byte{0x02}, MinKeyringKeySize)
+
+	old, err := NewKeyring(oldKey)
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+	mac := old.Sign([]byte("value"))
+
+	// The successor signs, but tokens from the old key still verify
+	rotated, err := NewKeyring(newKey, oldKey)
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+	if !rotated.Verify([]byte("value"), mac) {
+		t.Error("Verify() rejected a MAC from the previous key")
+	}
+	if bytes.Equal(rotated.Sign([]byte("value")), mac) {
+		t.Error("Sign() still uses the previous key")
+	}
+
+	// Once the old key is dropped its tokens are refused
+	dropped, _ := NewKeyring(newKey)
+	if dropped.Verify([]byte("value"), mac) {
+		t.Error("Verify() accepted a MAC from a dropped key")
+	}
+	if rotated.Verify([]byte("other"), mac) {
+		t.Error("Verify() accepted a MAC for a different message")
+	}
+}
+
+func TestParseKeyring(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0x03}, MinKeyringKeySize))
+	if _, err := ParseKeyring([]string{key}); err != nil {
+		t.Errorf("ParseKeyring() error = %v", err)
+	}
+
+	short := base64.StdEncoding.EncodeToString([]byte("too short"))
+	for _, values := range [][]string{nil, {"not base64!"}, {short}, {key, short}} {
+		if _, err := ParseKeyring(values); err == nil {
+			t.Errorf("ParseKeyring(%q) error = nil, want error", values)
+		}
+	}
+
+	if _, err := NewKeyring(); !errors.Is(err, ErrEmptyKeyring) {
+		t.Errorf("NewKeyring() error = %v, want ErrEmptyKeyring", err)
+	}
+}
@@ -0,0 +1,132 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/fips140"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+)
+
+// errSelfTest reports a round trip that returned the wrong bytes
+var errSelfTest = errors.New("crypto self-test mismatch")
+
+// Mode names the cryptographic module backing this process
+type Mode string
+
+// Crypto modes reported by CurrentMode
+const (
+	// ModeStandard is the default Go cryptography
+	ModeStandard Mode = "standard"
+	// ModeFIPS is the Go Cryptographic Module in FIPS 140-3 mode, selected at
+	// build time with GOFIPS140 or at startup with GODEBUG=fips140=on
+	ModeFIPS Mode = "fips140-3"
+	// ModeBoring is BoringCrypto, selected by building with
+	// GOEXPERIMENT=boringcrypto
+	ModeBoring Mode = "boringcrypto"
+)
+
+// Server-side jobs that use cryptography, as reported in an Attestation
+const (
+	JobEnvelopeEncryption = "envelope_encryption"
+	JobPassphraseKDF      = "passphrase_kdf"
+	JobTokenHashing       = "token_hashing"
+	JobWebhookSigning     = "webhook_signing"
+	JobNonceSigning       = "nonce_signing"
+)
+
+// algorithms is the primitive each job uses. Every one is FIPS-approved, so
+// the selection is the same in all modes; only the module providing them
+// changes.
+var algorithms = map[string]string{
+	JobEnvelopeEncryption: "AES-256-GCM",
+	JobPassphraseKDF:      "PBKDF2-HMAC-SHA256",
+	JobTokenHashing:       "SHA-256",
+	JobWebhookSigning:     "HMAC-SHA256",
+	JobNonceSigning:       "HMAC-SHA256",
+}
+
+// CurrentMode reports the active crypto mode; it cannot change after startup
+func CurrentMode() Mode {
+	switch {
+	case boringEnabled():
+		return ModeBoring
+	case fips140.Enabled():
+		return ModeFIPS
+	default:
+		return ModeStandard
+	}
+}
+
+// Attestation describes the active crypto mode and whether each job works
+// under it
+type Attestation struct {
+	Mode Mode `json:"mode"`
+	// ModuleVersion is the Go Cryptographic Module version in FIPS mode
+	ModuleVersion string            `json:"module_version,omitempty"`
+	Algorithms    map[string]string `json:"algorithms"`
+	// Available is false for jobs whose self-test failed, for example
+	// because the module refuses a parameter in FIPS-only mode
+	Available map[string]bool `json:"available"`
+}
+
+// Attest runs a round trip through each job and reports the results
+func Attest() Attestation {
+	mode := CurrentMode()
+	a := Attestation{
+		Mode:       mode,
+		Algorithms: algorithms,
+		Available: map[string]bool{
+			JobEnvelopeEncryption: selfTestEnvelope() == nil,
+			JobPassphraseKDF:      selfTestPassphrase() == nil,
+			JobTokenHashing:       VerifyManagementToken("self-test", HashManagementToken("self-test")),
+			JobWebhookSigning:     selfTestHMAC() == nil,
+			JobNonceSigning:       selfTestHMAC() == nil,
+		},
+	}
+	if mode == ModeFIPS {
+		a.ModuleVersion = fips140.Version()
+	}
+	return a
+}
+
+func selfTestEnvelope() error {
+	payload := []byte("self-test")
+	wrapped, dataKey, err := WrapWithDataKey(payload)
+	if err != nil {
+		return err
+	}
+
+	unwrapped, err := UnwrapWithDataKey(wrapped, dataKey)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(unwrapped, payload) {
+		return errSelfTest
+	}
+	return nil
+}
+
+func selfTestPassphrase() error {
+	key, err := derivePassphraseKey("self-test", bytes.Repeat([]byte{0x5A}, saltSize))
+	if err != nil {
+		return err
+	}
+	if len(key) != aesKeySize {
+		return errSelfTest
+	}
+	return nil
+}
+
+// selfTestHMAC checks RFC 4231 test case 1
+func selfTestHMAC() error {
+	want, _ := hex.DecodeString("b0344c61d8db38535ca8afceaf0bf12b881dc200c9833da726e9376c2e32cff7")
+
+	mac := hmac.New(sha256.New, bytes.Repeat([]byte{0x0b}, 20))
+	mac.Write([]byte("Hi There"))
+	if !hmac.Equal(mac.Sum(nil), want) {
+		return errSelfTest
+	}
+	return nil
+}
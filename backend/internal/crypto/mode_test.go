@@ -0,0 +1,68 @@
+package crypto
+
+import (
+	"crypto/fips140"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+// wantModeEnv tells a re-executed test binary which mode it must attest
+const wantModeEnv = "OTS_TEST_CRYPTO_MODE"
+
+func TestCurrentMode(t *testing.T) {
+	want := ModeStandard
+	if fips140.Enabled() {
+		want = ModeFIPS
+	}
+	if env := os.Getenv(wantModeEnv); env != "" {
+		want = Mode(env)
+	}
+
+	if got := CurrentMode(); got != want {
+		t.Fatalf("CurrentMode() = %q, want %q", got, want)
+	}
+}
+
+func TestAttest(t *testing.T) {
+	a := Attest()
+
+	if a.Mode != CurrentMode() {
+		t.Errorf("Attest() mode = %q, want %q", a.Mode, CurrentMode())
+	}
+	if (a.Mode == ModeFIPS) != (a.ModuleVersion != "") {
+		t.Errorf("Attest() module version = %q in mode %q", a.ModuleVersion, a.Mode)
+	}
+
+	for _, job := range []string{JobEnvelopeEncryption, JobPassphraseKDF, JobTokenHashing, JobWebhookSigning, JobNonceSigning} {
+		if a.Algorithms[job] == "" {
+			t.Errorf("Attest() has no algorithm for %s", job)
+		}
+		if !a.Available[job] {
+			t.Errorf("Attest() %s (%s) unavailable in mode %q", job, a.Algorithms[job], a.Mode)
+		}
+	}
+}
+
+// TestPackageUnderFIPS reruns this package's tests with the Go Cryptographic
+// Module in FIPS 140-3 mode, once approved-only and once enforcing
+func TestPackageUnderFIPS(t *testing.T) {
+	if os.Getenv(wantModeEnv) != "" {
+		t.Skip("already running under a forced mode")
+	}
+	if CurrentMode() == ModeBoring {
+		t.Skip("BoringCrypto builds cannot switch to the Go module's FIPS mode")
+	}
+
+	for _, setting := range []string{"on", "only"} {
+		t.Run(setting, func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], "-test.v")
+			cmd.Env = append(os.Environ(), "GODEBUG=fips140="+setting, wantModeEnv+"="+string(ModeFIPS))
+
+			output, err := cmd.CombinedOutput()
+			if err != nil {
+				t.Fatalf("tests with GODEBUG=fips140=%s failed: %v\n%s", setting, err, output)
+			}
+		})
+	}
+}
@@ -2,7 +2,10 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -14,23 +17,36 @@ type DB struct {
 	pool *pgxpool.Pool
 }
 
+// PoolOptions sizes the connection pool and bounds how long any statement
+// may run
+type PoolOptions struct {
+	MaxConns int32
+	MinConns int32
+	// StatementTimeout becomes each session's statement_timeout; 0 leaves
+	// the server default
+	StatementTimeout time.Duration
+	// Trace records a span per statement, including commits; set it only
+	// while tracing is on
+	Trace bool
+}
+
+// DefaultPoolOptions returns the settings New uses
+func DefaultPoolOptions() PoolOptions {
+	return PoolOptions{MaxConns: 25, MinConns: 5, StatementTimeout: 5 * time.Second}
+}
+
 // New creates a new database connection pool with retry logic
 func New(databaseURL string) (*DB, error) {
-	config, err := pgxpool.ParseConfig(databaseURL)
+	return NewWithOptions(databaseURL, DefaultPoolOptions())
+}
+
+// NewWithOptions creates a connection pool sized and bounded by opts
+func NewWithOptions(databaseURL string, opts PoolOptions) (*DB, error) {
+	config, err := poolConfig(databaseURL, opts)
 	if err != nil {
-		return nil, fmt.Errorf("parse config: %w", err)
+		return nil, err
 	}
 
-	// Configure connection pool for production
-	config.MaxConns = 25
-	config.MinConns = 5
-	config.MaxConnLifetime = time.Hour
-	config.MaxConnIdleTime = 30 * time.Minute
-	config.HealthCheckPeriod = 5 * time.Minute
-
-	// Connection retry configuration
-	config.ConnConfig.ConnectTimeout = 10 * time.Second
-
 	// Retry connection up to 5 times
 	var pool *pgxpool.Pool
 	maxRetries := 5
@@ -55,6 +71,80 @@ func New(databaseURL string) (*DB, error) {
 	return &DB{pool: pool}, nil
 }
 
+// poolConfig parses databaseURL and applies opts. The statement timeout is
+// a startup parameter, so it holds on every connection the pool opens,
+// including ones it replaces later.
+func poolConfig(databaseURL string, opts PoolOptions) (*pgxpool.Config, error) {
+	config, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+
+	// Configure connection pool for production
+	defaults := DefaultPoolOptions()
+	config.MaxConns = defaults.MaxConns
+	if opts.MaxConns > 0 {
+		config.MaxConns = opts.MaxConns
+	}
+	config.MinConns = min(max(opts.MinConns, 0), config.MaxConns)
+	config.MaxConnLifetime = time.Hour
+	config.MaxConnIdleTime = 30 * time.Minute
+	config.HealthCheckPeriod = 5 * time.Minute
+
+	if opts.StatementTimeout > 0 {
+		config.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(opts.StatementTimeout.Milliseconds(), 10)
+	}
+
+	if opts.Trace {
+		config.ConnConfig.Tracer = queryTracer{}
+	}
+
+	// Connection retry configuration
+	config.ConnConfig.ConnectTimeout = 10 * time.Second
+
+	return config, nil
+}
+
+// Warm opens the pool's minimum connections in parallel and runs one query on
+// each, so the first requests after startup do not pay for the handshakes
+func (db *DB) Warm(ctx context.Context) error {
+	if db.pool == nil {
+		return fmt.Errorf("database not connected")
+	}
+
+	n := int(db.pool.Config().MinConns)
+	if n < 1 {
+		n = 1
+	}
+
+	// Hold every connection until all are primed so each lands on its own
+	conns := make([]*pgxpool.Conn, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range conns {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn, err := db.pool.Acquire(ctx)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			conns[i] = conn
+			_, errs[i] = conn.Exec(ctx, "SELECT 1")
+		}(i)
+	}
+	wg.Wait()
+
+	for _, conn := range conns {
+		if conn != nil {
+			conn.Release()
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 // Close closes the database connection pool
 func (db *DB) Close() {
 	if db.pool != nil {
@@ -67,6 +157,46 @@ func (db *DB) Pool() *pgxpool.Pool {
 	return db.pool
 }
 
+// Stats is a snapshot of the connection pool. Counts and durations are
+// totals since the pool opened.
+type Stats struct {
+	// AcquiredConns are checked out to callers, IdleConns wait in the
+	// pool; TotalConns counts both and those still connecting
+	AcquiredConns int32
+	IdleConns     int32
+	TotalConns    int32
+	MaxConns      int32
+	// AcquireCount counts successful acquires and AcquireDuration their
+	// total wait
+	AcquireCount    int64
+	AcquireDuration time.Duration
+	// CanceledAcquireCount counts acquires given up by their context,
+	// which is how an exhausted pool shows
+	CanceledAcquireCount int64
+	// EmptyAcquireCount counts acquires that found no idle connection
+	EmptyAcquireCount int64
+	NewConnsCount     int64
+}
+
+// Stats returns the pool's statistics, zero when not connected
+func (db *DB) Stats() Stats {
+	if db.pool == nil {
+		return Stats{}
+	}
+	stat := db.pool.Stat()
+	return Stats{
+		AcquiredConns:        stat.AcquiredConns(),
+		IdleConns:            stat.IdleConns(),
+		TotalConns:           stat.TotalConns(),
+		MaxConns:             stat.MaxConns(),
+		AcquireCount:         stat.AcquireCount(),
+		AcquireDuration:      stat.AcquireDuration(),
+		CanceledAcquireCount: stat.CanceledAcquireCount(),
+		EmptyAcquireCount:    stat.EmptyAcquireCount(),
+		NewConnsCount:        stat.NewConnsCount(),
+	}
+}
+
 // Health checks the database connection
 func (db *DB) Health(ctx context.Context) error {
 	if db.pool == nil {
@@ -0,0 +1,186 @@
+//go:build integration
+
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+)
+
+func TestStatementTimeoutAbortsSlowQuery(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := postgres.Run(
+		ctx,
+		"postgres:16-alpine",
+		postgres.WithDatabase("ots_test"),
+		postgres.WithUsername("ots"),
+		postgres.WithPassword("ots"),
+		postgres.BasicWaitStrategies(),
+	)
+	if err != nil {
+		t.Fatalf("start postgres container: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	connString, err := container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatalf("connection string: %v", err)
+	}
+
+	database, err := NewWithOptions(connString, PoolOptions{MaxConns: 2, MinConns: 1, StatementTimeout: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewWithOptions() error: %v", err)
+	}
+	defer database.Close()
+
+	start := time.Now()
+	err = database.Exec(ctx, "SELECT pg_sleep(5)")
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "57014" {
+		t.Fatalf("slow query error = %v, want query_canceled (57014)", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("slow query ran for %s, want it cut off near the 200ms timeout", elapsed)
+	}
+
+	// The connection stays usable for the next statement
+	if err := database.Exec(ctx, "SELECT 1"); err != nil {
+		t.Errorf("query after timeout error: %v", err)
+	}
+
+	// Migrations are exempt, so they run to completion
+	if err := database.Migrate("../../migrations"); err != nil {
+		t.Errorf("Migrate() error: %v", err)
+	}
+}
+
+func TestStatsAtSaturation(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := postgres.Run(
+		ctx,
+		"postgres:16-alpine",
+		postgres.WithDatabase("ots_test"),
+		postgres.WithUsername("ots"),
+		postgres.WithPassword("ots"),
+		postgres.BasicWaitStrategies(),
+	)
+	if err != nil {
+		t.Fatalf("start postgres container: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	connString, err := container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatalf("connection string: %v", err)
+	}
+
+	database, err := NewWithOptions(connString, PoolOptions{MaxConns: 2, MinConns: 1})
+	if err != nil {
+		t.Fatalf("NewWithOptions() error: %v", err)
+	}
+	defer database.Close()
+
+	var conns []*pgxpool.Conn
+	for range 2 {
+		conn, err := database.Pool().Acquire(ctx)
+		if err != nil {
+			t.Fatalf("Acquire() error: %v", err)
+		}
+		conns = append(conns, conn)
+	}
+
+	// A third caller waits for a connection until it gives up
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := database.Pool().Acquire(waitCtx); err == nil {
+		t.Fatal("Acquire() on a saturated pool succeeded, want it to time out")
+	}
+
+	stats := database.Stats()
+	if stats.AcquiredConns != 2 || stats.IdleConns != 0 || stats.TotalConns != 2 || stats.MaxConns != 2 {
+		t.Errorf("Stats() saturated = %+v, want 2 of 2 acquired, none idle", stats)
+	}
+	if stats.CanceledAcquireCount != 1 {
+		t.Errorf("Stats().CanceledAcquireCount = %d, want 1", stats.CanceledAcquireCount)
+	}
+	if stats.AcquireCount < 2 || stats.NewConnsCount < 2 {
+		t.Errorf("Stats() = %d acquires, %d new connections; want at least 2 each", stats.AcquireCount, stats.NewConnsCount)
+	}
+
+	for _, conn := range conns {
+		conn.Release()
+	}
+	if stats := database.Stats(); stats.AcquiredConns != 0 || stats.IdleConns != 2 {
+		t.Errorf("Stats() released = %+v, want 2 idle", stats)
+	}
+}
+
+func TestConcurrentMigrateTakesTurns(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := postgres.Run(
+		ctx,
+		"postgres:16-alpine",
+		postgres.WithDatabase("ots_test"),
+		postgres.WithUsername("ots"),
+		postgres.WithPassword("ots"),
+		postgres.BasicWaitStrategies(),
+	)
+	if err != nil {
+		t.Fatalf("start postgres container: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	connString, err := container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatalf("connection string: %v", err)
+	}
+
+	// Replicas starting together each open their own pool
+	const replicas = 4
+	errs := make(chan error, replicas)
+	for range replicas {
+		go func() {
+			database, err := New(connString)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer database.Close()
+			errs <- database.Migrate("../../migrations")
+		}()
+	}
+	for range replicas {
+		if err := <-errs; err != nil {
+			t.Errorf("Migrate() error: %v", err)
+		}
+	}
+
+	database, err := New(connString)
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer database.Close()
+
+	version, dirty, err := database.MigrateStatus()
+	if err != nil || dirty {
+		t.Errorf("MigrateStatus() dirty = %v, err = %v; want clean", dirty, err)
+	}
+
+	// A failed migration leaves the schema dirty; Migrate explains the repair
+	if err := database.Exec(ctx, "UPDATE schema_migrations SET dirty = true"); err != nil {
+		t.Fatalf("mark dirty: %v", err)
+	}
+	var dirtyErr *DirtyError
+	if err := database.Migrate("../../migrations"); !errors.As(err, &dirtyErr) || dirtyErr.Version != version {
+		t.Errorf("Migrate() on dirty schema error = %v, want DirtyError at version %d", err, version)
+	}
+}
@@ -0,0 +1,258 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"ots-backend/internal/dropped"
+	"ots-backend/internal/logger"
+)
+
+// ListenerEventType classifies events delivered to listener subscribers
+type ListenerEventType int
+
+const (
+	// ListenerNotification carries a NOTIFY payload
+	ListenerNotification ListenerEventType = iota
+	// ListenerGap signals the connection dropped; subscribers should poll
+	// until they see ListenerResync
+	ListenerGap
+	// ListenerResync signals notifications flow again; subscribers should
+	// re-poll once to pick up anything that happened during the gap
+	ListenerResync
+)
+
+// EventsChannel is the NOTIFY channel used for secret lifecycle events
+const EventsChannel = "ots_events"
+
+const (
+	listenerBufferSize = 64
+	listenerMaxBackoff = 30 * time.Second
+)
+
+// ListenerEvent is delivered to subscribers
+type ListenerEvent struct {
+	Type    ListenerEventType
+	Channel string
+	Payload string
+}
+
+type subscription struct {
+	ch     chan ListenerEvent
+	missed bool
+}
+
+// Listener owns one dedicated connection outside the pool for LISTEN. It
+// reconnects with backoff, re-subscribes its channels, and tells subscribers
+// about gaps so they never silently miss events.
+type Listener struct {
+	connString string
+	channels   []string
+
+	mu     sync.Mutex
+	subs   map[int]*subscription
+	nextID int
+
+	healthy    atomic.Bool
+	pid        atomic.Uint32
+	reconnects atomic.Int64
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewListener creates a listener for the given channels. Call Start to connect.
+func NewListener(connString string, channels ...string) *Listener {
+	return &Listener{
+		connString: connString,
+		channels:   channels,
+		subs:       make(map[int]*subscription),
+	}
+}
+
+// Start connects lazily in the background and keeps the connection alive
+// until Close is called or ctx is cancelled.
+func (l *Listener) Start(ctx context.Context) {
+	ctx, l.cancel = context.WithCancel(ctx)
+	l.done = make(chan struct{})
+
+	go func() {
+		defer close(l.done)
+		l.run(ctx)
+	}()
+}
+
+// Close stops the listener and waits for the connection to be released
+func (l *Listener) Close() {
+	if l.cancel == nil {
+		return
+	}
+
+	l.cancel()
+	<-l.done
+}
+
+// Subscribe registers a subscriber. The returned func unsubscribes.
+func (l *Listener) Subscribe() (<-chan ListenerEvent, func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	id := l.nextID
+	l.nextID++
+	sub := &subscription{ch: make(chan ListenerEvent, listenerBufferSize)}
+	l.subs[id] = sub
+
+	// A subscriber joining while disconnected must start in polling mode
+	if !l.healthy.Load() {
+		sub.ch <- ListenerEvent{Type: ListenerGap}
+	}
+
+	return sub.ch, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if _, ok := l.subs[id]; ok {
+			delete(l.subs, id)
+			close(sub.ch)
+		}
+	}
+}
+
+// Health reports whether the dedicated connection is currently listening
+func (l *Listener) Health() error {
+	if !l.healthy.Load() {
+		return errors.New("listener not connected")
+	}
+	return nil
+}
+
+// Reconnects returns how many times the connection was re-established
+func (l *Listener) Reconnects() int64 {
+	return l.reconnects.Load()
+}
+
+// BackendPID returns the server process ID of the current connection, or 0
+func (l *Listener) BackendPID() uint32 {
+	return l.pid.Load()
+}
+
+func (l *Listener) run(ctx context.Context) {
+	backoff := 100 * time.Millisecond
+	connectedBefore := false
+
+	for ctx.Err() == nil {
+		conn, err := l.connect(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			logger.Warn("listener connect failed", "error", err, "retry_in", backoff)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+
+			backoff = min(backoff*2, listenerMaxBackoff)
+			continue
+		}
+
+		backoff = 100 * time.Millisecond
+		l.pid.Store(conn.PgConn().PID())
+		l.healthy.Store(true)
+		if connectedBefore {
+			l.reconnects.Add(1)
+			logger.Info("listener reconnected", "channels", l.channels)
+		}
+		connectedBefore = true
+		l.broadcast(ListenerEvent{Type: ListenerResync})
+
+		err = l.receive(ctx, conn)
+
+		l.healthy.Store(false)
+		l.pid.Store(0)
+		closeCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		conn.Close(closeCtx)
+		cancel()
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		logger.Warn("listener connection lost", "error", err)
+		l.broadcast(ListenerEvent{Type: ListenerGap})
+	}
+}
+
+func (l *Listener) connect(ctx context.Context) (*pgx.Conn, error) {
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	conn, err := pgx.Connect(connectCtx, l.connString)
+	if err != nil {
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+
+	for _, channel := range l.channels {
+		if _, err := conn.Exec(connectCtx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			conn.Close(context.Background())
+			return nil, fmt.Errorf("listen %s: %w", channel, err)
+		}
+	}
+
+	return conn, nil
+}
+
+func (l *Listener) receive(ctx context.Context, conn *pgx.Conn) error {
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		l.broadcast(ListenerEvent{
+			Type:    ListenerNotification,
+			Channel: notification.Channel,
+			Payload: notification.Payload,
+		})
+	}
+}
+
+// broadcast delivers without blocking. A subscriber whose buffer is full
+// loses the event, counted as dropped work, but is sent a resync as soon as
+// it has room again.
+func (l *Listener) broadcast(event ListenerEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, sub := range l.subs {
+		if sub.missed {
+			select {
+			case sub.ch <- ListenerEvent{Type: ListenerResync}:
+				sub.missed = false
+			default:
+				dropped.Record(dropped.KindListenerEvent, dropped.ReasonOverflow)
+				continue
+			}
+		}
+
+		select {
+		case sub.ch <- event:
+		default:
+			sub.missed = true
+			dropped.Record(dropped.KindListenerEvent, dropped.ReasonOverflow)
+		}
+	}
+}
+
+// Notify publishes payload on channel through the pool
+func (db *DB) Notify(ctx context.Context, channel, payload string) error {
+	_, err := db.pool.Exec(ctx, "SELECT pg_notify($1, $2)", channel, payload)
+	return err
+}
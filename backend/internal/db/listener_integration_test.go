@@ -0,0 +1,99 @@
+//go:build integration
+
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+)
+
+func TestListenerReconnectsAfterBackendTermination(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := postgres.Run(
+		ctx,
+		"postgres:16-alpine",
+		postgres.WithDatabase("ots_test"),
+		postgres.WithUsername("ots"),
+		postgres.WithPassword("ots"),
+		postgres.BasicWaitStrategies(),
+	)
+	if err != nil {
+		t.Fatalf("start postgres container: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	connString, err := container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatalf("connection string: %v", err)
+	}
+
+	database, err := New(connString)
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer database.Close()
+
+	listener := NewListener(connString, EventsChannel)
+	events, unsubscribe := listener.Subscribe()
+	defer unsubscribe()
+
+	listener.Start(ctx)
+	defer listener.Close()
+
+	expectEvent(t, events, ListenerGap)
+	expectEvent(t, events, ListenerResync)
+
+	if err := database.Notify(ctx, EventsChannel, "before"); err != nil {
+		t.Fatalf("Notify() error: %v", err)
+	}
+	if got := expectEvent(t, events, ListenerNotification); got.Payload != "before" {
+		t.Fatalf("payload = %q, want %q", got.Payload, "before")
+	}
+
+	pid := listener.BackendPID()
+	if pid == 0 {
+		t.Fatal("BackendPID() = 0 while connected")
+	}
+
+	if _, err := database.Pool().Exec(ctx, "SELECT pg_terminate_backend($1)", int32(pid)); err != nil {
+		t.Fatalf("terminate listener backend: %v", err)
+	}
+
+	// Watchers must learn about the gap, then be told to re-poll on reconnect
+	expectEvent(t, events, ListenerGap)
+	expectEvent(t, events, ListenerResync)
+
+	if listener.Reconnects() != 1 {
+		t.Fatalf("Reconnects() = %d, want 1", listener.Reconnects())
+	}
+
+	if err := listener.Health(); err != nil {
+		t.Fatalf("Health() after reconnect = %v", err)
+	}
+
+	if err := database.Notify(ctx, EventsChannel, "after"); err != nil {
+		t.Fatalf("Notify() error: %v", err)
+	}
+	if got := expectEvent(t, events, ListenerNotification); got.Payload != "after" {
+		t.Fatalf("payload = %q, want %q", got.Payload, "after")
+	}
+}
+
+func expectEvent(t *testing.T, events <-chan ListenerEvent, want ListenerEventType) ListenerEvent {
+	t.Helper()
+
+	select {
+	case event := <-events:
+		if event.Type != want {
+			t.Fatalf("event type = %d, want %d", event.Type, want)
+		}
+		return event
+	case <-time.After(15 * time.Second):
+		t.Fatalf("timed out waiting for event type %d", want)
+		return ListenerEvent{}
+	}
+}
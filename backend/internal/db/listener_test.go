@@ -0,0 +1,28 @@
+package db
+
+import (
+	"testing"
+
+	"ots-backend/internal/dropped"
+)
+
+func TestBroadcastCountsOverflow(t *testing.T) {
+	l := NewListener("postgres://unused")
+	events, unsubscribe := l.Subscribe()
+	defer unsubscribe()
+
+	before := dropped.Counts()["listener_event/overflow"]
+
+	// The subscriber starts with a gap queued, so extra events overflow
+	const extra = 5
+	for range listenerBufferSize + extra {
+		l.broadcast(ListenerEvent{Type: ListenerNotification, Payload: "x"})
+	}
+
+	if got := dropped.Counts()["listener_event/overflow"] - before; got != extra+1 {
+		t.Errorf("overflow drops = %d, want %d", got, extra+1)
+	}
+	if first := <-events; first.Type != ListenerGap {
+		t.Errorf("first event = %v, want the initial gap", first.Type)
+	}
+}
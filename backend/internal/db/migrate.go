@@ -1,16 +1,66 @@
 package db
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/stdlib"
 )
 
+// migrationLockID is the advisory lock key held while migrations run. It
+// differs from golang-migrate's own per-statement lock, so holding it does
+// not block the migrator.
+const migrationLockID int64 = 0x6f74732d6d696772 // "ots-migr"
+
+// DirtyError reports a schema left dirty by a migration that failed partway
+type DirtyError struct {
+	Version int
+}
+
+func (e *DirtyError) Error() string {
+	return fmt.Sprintf("database schema is dirty at migration version %d: a previous run of migration %06d failed partway. "+
+		"Check which of its statements were applied, finish or revert them by hand, then mark the version clean with "+
+		"`migrate -path migrations -database $DATABASE_URL force %d` (or force %d if you reverted it) and restart",
+		e.Version, e.Version, e.Version, e.Version-1)
+}
+
+// Migrate applies pending migrations. They run on a pool of their own
+// without the pool's statement timeout, since building an index on a large
+// table may legitimately take longer than any request should. An advisory
+// lock serializes instances starting together: the first applies the
+// migrations and the rest wait, then find nothing left to do.
 func (db *DB) Migrate(migrationsPath string) error {
-	driver, err := postgres.WithInstance(stdlib.OpenDBFromPool(db.pool), &postgres.Config{})
+	ctx := context.Background()
+	config := db.pool.Config()
+	delete(config.ConnConfig.RuntimeParams, "statement_timeout")
+	config.MinConns = 0
+	if config.MaxConns < 2 {
+		config.MaxConns = 2
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return fmt.Errorf("open migration pool: %w", err)
+	}
+	defer pool.Close()
+
+	lockConn, err := pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire migration lock connection: %w", err)
+	}
+	defer lockConn.Release()
+
+	if _, err := lockConn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("take migration lock: %w", err)
+	}
+	defer lockConn.Exec(ctx, "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	driver, err := postgres.WithInstance(stdlib.OpenDBFromPool(pool), &postgres.Config{})
 	if err != nil {
 		return fmt.Errorf("create migration driver: %w", err)
 	}
@@ -25,17 +75,24 @@ func (db *DB) Migrate(migrationsPath string) error {
 	}
 
 	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+		var dirty migrate.ErrDirty
+		if errors.As(err, &dirty) {
+			return &DirtyError{Version: dirty.Version}
+		}
 		return fmt.Errorf("run migrations: %w", err)
 	}
 
 	return nil
 }
 
+// MigrateStatus returns the applied migration version and whether the last
+// migration failed partway
 func (db *DB) MigrateStatus() (version int, dirty bool, err error) {
 	driver, err := postgres.WithInstance(stdlib.OpenDBFromPool(db.pool), &postgres.Config{})
 	if err != nil {
 		return 0, false, fmt.Errorf("create migration driver: %w", err)
 	}
+	defer driver.Close()
 
 	version, dirty, err = driver.Version()
 	if err != nil {
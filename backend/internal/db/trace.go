@@ -0,0 +1,39 @@
+package db
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"ots-backend/internal/tracing"
+)
+
+// queryTracer records a span per statement named after its operation.
+// Statements are parameterized, so neither the text nor the arguments are
+// recorded: the operation is enough to tell an insert from its commit.
+type queryTracer struct{}
+
+func (queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	op := operation(data.SQL)
+	ctx, span := tracing.Start(ctx, "postgres "+op)
+	if span.IsRecording() {
+		span.SetAttributes(attribute.String("db.system", "postgresql"), attribute.String("db.operation", op))
+	}
+	return ctx
+}
+
+func (queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	tracing.End(trace.SpanFromContext(ctx), data.Err, pgx.ErrNoRows)
+}
+
+// operation returns the first keyword of sql, upper-cased
+func operation(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "QUERY"
+	}
+	return strings.ToUpper(fields[0])
+}
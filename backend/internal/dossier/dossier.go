@@ -0,0 +1,104 @@
+// Package dossier builds and signs per-secret support dossiers: everything
+// the server recorded about one secret's lifecycle, keyed by the SHA-256 of
+// its ID and free of ciphertext, IVs, salts and key material, so it can be
+// attached to a support ticket.
+package dossier
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"ots-backend/internal/crypto"
+)
+
+// Version is the dossier format version
+const Version = 1
+
+// Algorithm names the signature scheme of a signed dossier
+const Algorithm = "HMAC-SHA256"
+
+// ErrBadSignature indicates a signed dossier that does not verify
+var ErrBadSignature = errors.New("dossier signature does not verify")
+
+// Dossier is one secret's recorded lifecycle
+type Dossier struct {
+	Version      int       `json:"version"`
+	SecretIDHash string    `json:"secret_id_hash"`
+	GeneratedAt  time.Time `json:"generated_at"`
+	// Stored reports whether the secret was still stored when the dossier
+	// was generated
+	Stored bool `json:"stored"`
+	// Receipt is nil when no read receipt was recorded
+	Receipt *Receipt `json:"receipt"`
+	// Events are the audit log entries for the secret, oldest first; empty
+	// when the audit log is disabled
+	Events []Event `json:"events"`
+}
+
+// Receipt is a secret's read receipt
+type Receipt struct {
+	ConsumedAt   time.Time `json:"consumed_at"`
+	NetworkClass string    `json:"network_class,omitempty"`
+	Acknowledged *bool     `json:"acknowledged,omitempty"`
+}
+
+// Event is one audit log entry
+type Event struct {
+	ID           string    `json:"id"`
+	Type         string    `json:"type"`
+	OccurredAt   time.Time `json:"occurred_at"`
+	Namespace    string    `json:"namespace,omitempty"`
+	NetworkClass string    `json:"network_class,omitempty"`
+	Reason       string    `json:"reason,omitempty"`
+	Actor        string    `json:"actor,omitempty"`
+}
+
+// Signed is a dossier with its signature. The signature covers the compact
+// JSON encoding of Dossier, so reformatting the document keeps it valid.
+type Signed struct {
+	Dossier   json.RawMessage `json:"dossier"`
+	Algorithm string          `json:"algorithm"`
+	Signature string          `json:"signature"`
+}
+
+// Sign encodes d and signs it with keys' signing key
+func Sign(d *Dossier, keys *crypto.Keyring) (*Signed, error) {
+	body, err := json.Marshal(d)
+	if err != nil {
+		return nil, fmt.Errorf("encode dossier: %w", err)
+	}
+	return &Signed{
+		Dossier:   body,
+		Algorithm: Algorithm,
+		Signature: base64.StdEncoding.EncodeToString(keys.Sign(body)),
+	}, nil
+}
+
+// Verify checks s against every key in keys and decodes its dossier
+func Verify(s *Signed, keys *crypto.Keyring) (*Dossier, error) {
+	if s.Algorithm != Algorithm {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrBadSignature, s.Algorithm)
+	}
+	mac, err := base64.StdEncoding.DecodeString(s.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+
+	var body bytes.Buffer
+	if err := json.Compact(&body, s.Dossier); err != nil {
+		return nil, fmt.Errorf("decode dossier: %w", err)
+	}
+	if !keys.Verify(body.Bytes(), mac) {
+		return nil, ErrBadSignature
+	}
+
+	var d Dossier
+	if err := json.Unmarshal(body.Bytes(), &d); err != nil {
+		return nil, fmt.Errorf("decode dossier: %w", err)
+	}
+	return &d, nil
+}
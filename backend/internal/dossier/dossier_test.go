@@ -0,0 +1,88 @@
+package dossier
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"ots-backend/internal/crypto"
+)
+
+func testDossier() *Dossier {
+	acknowledged := true
+	return &Dossier{
+		Version:      Version,
+		SecretIDHash: strings.Repeat("ab", 32),
+		GeneratedAt:  time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Receipt:      &Receipt{ConsumedAt: time.Date(2026, 3, 1, 11, 0, 0, 0, time.UTC), NetworkClass: "corp", Acknowledged: &acknowledged},
+		Events: []Event{
+			{ID: "01", Type: "secret.created", OccurredAt: time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)},
+			{ID: "02", Type: "secret.reported", OccurredAt: time.Date(2026, 3, 1, 11, 30, 0, 0, time.UTC), Reason: "unexpected"},
+		},
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	keys, _ := crypto.NewKeyring(bytes.Repeat([]byte{0x01}, crypto.MinKeyringKeySize))
+
+	signed, err := Sign(testDossier(), keys)
+	if err != nil {
+		t.Fatalf("Sign() error: %v", err)
+	}
+	got, err := Verify(signed, keys)
+	if err != nil {
+		t.Fatalf("Verify() error: %v", err)
+	}
+	if got.SecretIDHash != testDossier().SecretIDHash || len(got.Events) != 2 || got.Events[1].Reason != "unexpected" {
+		t.Errorf("Verify() = %+v", got)
+	}
+
+	// A pretty-printed copy, as written by the CLI, still verifies
+	encoded, _ := json.MarshalIndent(signed, "", "  ")
+	var reread Signed
+	if err := json.Unmarshal(encoded, &reread); err != nil {
+		t.Fatalf("decode signed dossier: %v", err)
+	}
+	if _, err := Verify(&reread, keys); err != nil {
+		t.Errorf("Verify() indented error: %v", err)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	keys, _ := crypto.NewKeyring(bytes.Repeat([]byte{0x01}, crypto.MinKeyringKeySize))
+	other, _ := crypto.NewKeyring(bytes.Repeat([]byte{0x02}, crypto.MinKeyringKeySize))
+
+	signed, err := Sign(testDossier(), keys)
+	if err != nil {
+		t.Fatalf("Sign() error: %v", err)
+	}
+
+	tampered := *signed
+	tampered.Dossier = bytes.Replace(signed.Dossier, []byte(`"stored":false`), []byte(`"stored":true`), 1)
+	wrongAlgorithm := *signed
+	wrongAlgorithm.Algorithm = "none"
+
+	tests := []struct {
+		name   string
+		signed *Signed
+		keys   *crypto.Keyring
+	}{
+		{"tampered", &tampered, keys},
+		{"other key", signed, other},
+		{"algorithm", &wrongAlgorithm, keys},
+	}
+	for _, tt := range tests {
+		if _, err := Verify(tt.signed, tt.keys); !errors.Is(err, ErrBadSignature) {
+			t.Errorf("%s: Verify() error = %v, want ErrBadSignature", tt.name, err)
+		}
+	}
+
+	// Dossiers signed before a rotation still verify
+	rotated, _ := crypto.NewKeyring(bytes.Repeat([]byte{0x02}, crypto.MinKeyringKeySize), bytes.Repeat([]byte{0x01}, crypto.MinKeyringKeySize))
+	if _, err := Verify(signed, rotated); err != nil {
+		t.Errorf("Verify() after rotation error: %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+// Package dropped accounts for async work the server gives up on: audit
+// writes that fail or are abandoned, listener events a full subscriber
+// buffer cannot take, and creator notifications that could not be sent. Every loss is counted in process for metrics and
+// queued for a best-effort loss record in the store, so operators can tell
+// whether an incident left gaps in the audit log.
+package dropped
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync"
+	"time"
+
+	"ots-backend/internal/logger"
+	"ots-backend/internal/store"
+)
+
+// Kinds of async work that can be dropped
+const (
+	KindAuditEvent    = "audit_event"
+	KindListenerEvent = "listener_event"
+	// KindNotification is a read or expiry notice for a secret's creator
+	KindNotification = "notification"
+)
+
+// Reasons work is dropped
+const (
+	// ReasonOverflow means a bounded buffer or queue was full
+	ReasonOverflow = "overflow"
+	// ReasonShutdown means the work's context was cancelled, by shutdown or
+	// a departed client, before it finished
+	ReasonShutdown = "shutdown"
+	// ReasonExpired means the work ran out of its deadline
+	ReasonExpired = "expired"
+	// ReasonFailed means the backend refused the work
+	ReasonFailed = "failed"
+)
+
+// drainTimeout bounds the flush Drain makes on the way out
+const drainTimeout = 2 * time.Second
+
+type key struct {
+	kind, reason string
+}
+
+var (
+	mu      sync.Mutex
+	totals  = make(map[key]int64)
+	pending = make(map[key]int64)
+)
+
+// Record counts one dropped item of kind for reason
+func Record(kind, reason string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	k := key{kind, reason}
+	totals[k]++
+	pending[k]++
+}
+
+// Reason classifies the error a write failed with, consulting ctx for a
+// cancellation the error does not carry
+func Reason(ctx context.Context, err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return ReasonExpired
+	case errors.Is(err, context.Canceled), ctx.Err() != nil:
+		return ReasonShutdown
+	default:
+		return ReasonFailed
+	}
+}
+
+// Counts returns the items dropped since start, keyed "kind/reason"
+func Counts() map[string]int64 {
+	mu.Lock()
+	defer mu.Unlock()
+
+	counts := make(map[string]int64, len(totals))
+	for k, n := range totals {
+		counts[k.kind+"/"+k.reason] = n
+	}
+	return counts
+}
+
+// Flush writes the losses counted since the last flush to st as one record
+// per kind and reason, stamped now. Losses that cannot be written stay
+// queued for the next flush.
+func Flush(ctx context.Context, st store.Store, now time.Time) error {
+	mu.Lock()
+	batch := pending
+	pending = make(map[key]int64)
+	mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	losses := make([]store.DroppedWork, 0, len(batch))
+	for k, n := range batch {
+		losses = append(losses, store.DroppedWork{Kind: k.kind, Reason: k.reason, Count: n, RecordedAt: now.UTC()})
+	}
+	if err := st.RecordDroppedWork(ctx, losses); err != nil {
+		requeue(batch)
+		return err
+	}
+	return nil
+}
+
+// requeue returns an unwritten batch to the pending counts
+func requeue(batch map[key]int64) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for k, n := range batch {
+		pending[k] += n
+	}
+}
+
+// Run flushes every interval until ctx is done. Call Drain once the work
+// that could still drop has stopped.
+func Run(ctx context.Context, st store.Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := Flush(ctx, st, time.Now()); err != nil {
+				logger.Warn("failed to record dropped work, retrying next flush", "error", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Drain makes a last, briefly bounded flush on shutdown. Losses that never
+// reach the store are logged so they survive in the process output at
+// least.
+func Drain(st store.Store) {
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	err := Flush(ctx, st, time.Now())
+	if err == nil {
+		return
+	}
+
+	mu.Lock()
+	unwritten := maps.Clone(pending)
+	mu.Unlock()
+	for k, n := range unwritten {
+		logger.Error("dropped work not recorded", "error", err, "kind", k.kind, "reason", k.reason, "count", n)
+	}
+}
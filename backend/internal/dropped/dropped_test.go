@@ -0,0 +1,113 @@
+package dropped
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"ots-backend/internal/store"
+	"ots-backend/internal/store/memory"
+)
+
+// unreachableStore fails every loss record write
+type unreachableStore struct {
+	store.Store
+}
+
+func (unreachableStore) RecordDroppedWork(context.Context, []store.DroppedWork) error {
+	return errors.New("connection refused")
+}
+
+// drain empties the pending counts other tests left behind
+func drain(t *testing.T) {
+	t.Helper()
+	if err := Flush(context.Background(), memory.New(), time.Now()); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+}
+
+func TestReason(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want string
+	}{
+		{name: "backend error", ctx: context.Background(), err: errors.New("disk full"), want: ReasonFailed},
+		{name: "cancelled", ctx: cancelled, err: errors.New("conn closed"), want: ReasonShutdown},
+		{name: "wrapped cancel", ctx: context.Background(), err: fmt.Errorf("insert: %w", context.Canceled), want: ReasonShutdown},
+		{name: "deadline", ctx: expired, err: errors.New("conn closed"), want: ReasonExpired},
+		{name: "wrapped deadline", ctx: context.Background(), err: fmt.Errorf("insert: %w", context.DeadlineExceeded), want: ReasonExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Reason(tt.ctx, tt.err); got != tt.want {
+				t.Errorf("Reason() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlushRecordsAndRequeues(t *testing.T) {
+	ctx := context.Background()
+	drain(t)
+	before := Counts()
+
+	Record(KindAuditEvent, ReasonShutdown)
+	Record(KindAuditEvent, ReasonShutdown)
+	Record(KindListenerEvent, ReasonOverflow)
+
+	counts := Counts()
+	if got := counts["audit_event/shutdown"] - before["audit_event/shutdown"]; got != 2 {
+		t.Errorf("audit_event/shutdown counted %d, want 2", got)
+	}
+	if got := counts["listener_event/overflow"] - before["listener_event/overflow"]; got != 1 {
+		t.Errorf("listener_event/overflow counted %d, want 1", got)
+	}
+
+	// A failed write keeps the losses for the next flush
+	if err := Flush(ctx, unreachableStore{}, time.Now()); err == nil {
+		t.Fatal("Flush() to an unreachable store succeeded")
+	}
+
+	st := memory.New()
+	now := time.Now()
+	if err := Flush(ctx, st, now); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+	losses, err := st.DroppedWorkSince(ctx, now.Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("DroppedWorkSince() error: %v", err)
+	}
+	want := []store.DroppedWork{
+		{Kind: KindAuditEvent, Reason: ReasonShutdown, Count: 2},
+		{Kind: KindListenerEvent, Reason: ReasonOverflow, Count: 1},
+	}
+	if len(losses) != len(want) {
+		t.Fatalf("recorded losses = %+v, want %+v", losses, want)
+	}
+	for i := range want {
+		if losses[i].Kind != want[i].Kind || losses[i].Reason != want[i].Reason || losses[i].Count != want[i].Count {
+			t.Errorf("recorded loss %d = %+v, want %+v", i, losses[i], want[i])
+		}
+	}
+
+	// Flushed losses are not written twice, but stay in the totals
+	if err := Flush(ctx, st, now.Add(time.Second)); err != nil {
+		t.Fatalf("second Flush() error: %v", err)
+	}
+	if losses, _ := st.DroppedWorkSince(ctx, time.Time{}); len(losses) != 2 || losses[0].Count != 2 {
+		t.Errorf("losses after second flush = %+v, want unchanged", losses)
+	}
+	if got := Counts()["audit_event/shutdown"] - before["audit_event/shutdown"]; got != 2 {
+		t.Errorf("audit_event/shutdown after flush = %d, want 2", got)
+	}
+}
@@ -0,0 +1,109 @@
+// Package events tells watchers in this process what becomes of a secret.
+// Terminator publishes each ending to a Hub keyed by secret ID, and the
+// events endpoint streams them to the secret's creator. Nothing crosses
+// processes: a watcher on one replica does not hear endings on another.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Type names what happened to a secret
+type Type string
+
+const (
+	Created Type = "created"
+	Read    Type = "read"
+	Burned  Type = "burned"
+	Expired Type = "expired"
+)
+
+// Terminal reports whether nothing can follow an event of type t
+func (t Type) Terminal() bool {
+	return t == Read || t == Burned || t == Expired
+}
+
+// Event is one thing that happened to a secret
+type Event struct {
+	Type Type `json:"type"`
+	// At is when it happened; unset for a created event replayed to a
+	// late watcher
+	At *time.Time `json:"at,omitempty"`
+	// Revision is the secret's revision after it. A watcher that sees it
+	// jump by more than one missed a change in between.
+	Revision int64 `json:"revision,omitempty"`
+}
+
+// watchBuffer is how many events a watcher may fall behind by before
+// Publish drops them. A secret ends once, so a few is plenty.
+const watchBuffer = 4
+
+// Hub fans events out to the watchers of each secret. A nil Hub publishes
+// nothing and its watchers hear nothing.
+type Hub struct {
+	mu       sync.Mutex
+	watchers map[string]map[chan Event]struct{}
+}
+
+// NewHub returns a hub with no watchers
+func NewHub() *Hub {
+	return &Hub{watchers: make(map[string]map[chan Event]struct{})}
+}
+
+// Watch returns a channel of id's events from now on and a function that
+// stops them. The channel is never closed; call stop when done with it.
+func (h *Hub) Watch(id string) (<-chan Event, func()) {
+	ch := make(chan Event, watchBuffer)
+	if h == nil {
+		return ch, func() {}
+	}
+
+	h.mu.Lock()
+	set := h.watchers[id]
+	if set == nil {
+		set = make(map[chan Event]struct{})
+		h.watchers[id] = set
+	}
+	set[ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			delete(h.watchers[id], ch)
+			if len(h.watchers[id]) == 0 {
+				delete(h.watchers, id)
+			}
+		})
+	}
+}
+
+// Publish sends e to id's watchers without waiting for them; a watcher
+// whose buffer is full misses it
+func (h *Hub) Publish(id string, e Event) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.watchers[id] {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Watching returns how many watchers id has
+func (h *Hub) Watching(id string) int {
+	if h == nil {
+		return 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.watchers[id])
+}
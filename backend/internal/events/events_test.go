@@ -0,0 +1,71 @@
+package events
+
+import "testing"
+
+func TestHubPublishesToWatchersOfTheSecret(t *testing.T) {
+	hub := NewHub()
+	first, stopFirst := hub.Watch("a")
+	second, stopSecond := hub.Watch("a")
+	other, stopOther := hub.Watch("b")
+	defer stopOther()
+
+	hub.Publish("a", Event{Type: Read})
+	for i, ch := range []<-chan Event{first, second} {
+		select {
+		case e := <-ch:
+			if e.Type != Read {
+				t.Errorf("watcher %d got %s, want read", i, e.Type)
+			}
+		default:
+			t.Errorf("watcher %d got nothing", i)
+		}
+	}
+	if len(other) != 0 {
+		t.Errorf("watcher of another secret got %d events, want 0", len(other))
+	}
+
+	stopFirst()
+	stopFirst()
+	if n := hub.Watching("a"); n != 1 {
+		t.Errorf("Watching() after one stop = %d, want 1", n)
+	}
+	stopSecond()
+	if n := hub.Watching("a"); n != 0 {
+		t.Errorf("Watching() after both stopped = %d, want 0", n)
+	}
+	hub.Publish("a", Event{Type: Burned})
+	if len(first) != 0 {
+		t.Errorf("stopped watcher got %d events, want 0", len(first))
+	}
+}
+
+func TestHubDropsForSlowWatchers(t *testing.T) {
+	hub := NewHub()
+	watch, stop := hub.Watch("a")
+	defer stop()
+
+	for range watchBuffer + 2 {
+		hub.Publish("a", Event{Type: Expired})
+	}
+	if len(watch) != watchBuffer {
+		t.Errorf("buffered %d events, want %d", len(watch), watchBuffer)
+	}
+}
+
+func TestNilHub(t *testing.T) {
+	var hub *Hub
+	watch, stop := hub.Watch("a")
+	hub.Publish("a", Event{Type: Read})
+	stop()
+	if len(watch) != 0 || hub.Watching("a") != 0 {
+		t.Error("nil hub delivered an event")
+	}
+}
+
+func TestTerminal(t *testing.T) {
+	for typ, want := range map[Type]bool{Created: false, Read: true, Burned: true, Expired: true} {
+		if got := typ.Terminal(); got != want {
+			t.Errorf("%s.Terminal() = %v, want %v", typ, got, want)
+		}
+	}
+}
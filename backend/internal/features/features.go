@@ -0,0 +1,325 @@
+// Package features lists the server's optional features with what each one
+// needs to work: config it depends on, keyrings that must parse, and the
+// tables and columns it reads and writes. Resolve checks every enabled
+// feature at startup, so a half-configured flag stops the server with one
+// report instead of failing requests later.
+package features
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	"ots-backend/internal/api"
+	"ots-backend/internal/config"
+	"ots-backend/internal/crypto"
+	"ots-backend/internal/middleware"
+	"ots-backend/internal/netclass"
+	"ots-backend/internal/notify"
+	"ots-backend/internal/scan"
+	"ots-backend/internal/store"
+	"ots-backend/internal/store/sqlite"
+	"ots-backend/internal/validation"
+)
+
+// Feature is one capability and its prerequisites
+type Feature struct {
+	// Name matches the capability name in /api/info where there is one
+	Name string
+	// Enabled reports whether cfg turns the feature on; nil means always
+	Enabled func(cfg *config.Config) bool
+	// Check returns every config prerequisite cfg leaves unmet
+	Check func(cfg *config.Config) []string
+	// Schema lists the tables, or table.column, the feature needs
+	Schema []string
+}
+
+// Problem is one unmet prerequisite of an enabled feature
+type Problem struct {
+	Feature string
+	Detail  string
+}
+
+// Report is every unmet prerequisite Resolve found. It is an error so
+// startup can fail with it whole.
+type Report []Problem
+
+func (r Report) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d unmet feature prerequisites:", len(r))
+	for _, p := range r {
+		fmt.Fprintf(&b, "\n  %s: %s", p.Feature, p.Detail)
+	}
+	return b.String()
+}
+
+// Registry is every feature Resolve checks
+var Registry = []Feature{
+	{
+		Name:   "secrets",
+		Schema: []string{"secrets", "secret_parts", "secrets.iv_embedded", "secrets.declared_key_bits", "secrets.available_after", "secrets.creator", "secrets.hint"},
+		Check: func(cfg *config.Config) []string {
+			if cfg.StorageBackend == config.StorageSQLite {
+				if _, ok := sqlite.PathFromURL(cfg.DatabaseURL); !ok {
+					return []string{"STORAGE_BACKEND=sqlite requires DATABASE_URL=sqlite://<path>"}
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Name:   "management_token",
+		Schema: []string{"secrets.management_token_hash", "secrets.version", "secret_receipts.version"},
+	},
+	{
+		Name:   "require_ack",
+		Schema: []string{"secret_receipts", "secrets.require_ack", "secrets.ack_token_hash", "secrets.ack_deadline", "secret_receipts.acknowledged"},
+	},
+	{
+		Name:   "namespaces",
+		Schema: []string{"secrets.namespace", "namespace_deletions"},
+	},
+	{
+		Name:   "usage_stats",
+		Schema: []string{"secret_size_buckets", "daily_stats", "daily_stats.ttl_seconds", "dropped_work"},
+	},
+	{
+		Name:    "crypto_shredding",
+		Enabled: func(cfg *config.Config) bool { return cfg.CryptoShredding },
+		Schema:  []string{"secret_keys", "secrets.key_wrapped"},
+	},
+	{
+		Name:    "envelope_keys",
+		Enabled: func(cfg *config.Config) bool { return len(cfg.EnvelopeKeys) > 0 },
+		Check: func(cfg *config.Config) []string {
+			var problems []string
+			if _, err := crypto.ParseEnvelopeKeys(cfg.EnvelopeKeys); err != nil {
+				problems = append(problems, "invalid ENVELOPE_KEYS: "+err.Error())
+			}
+			if !cfg.CryptoShredding {
+				problems = append(problems, "ENVELOPE_KEYS requires CRYPTO_SHREDDING_ENABLED; only data keys are wrapped")
+			}
+			return problems
+		},
+		Schema: []string{"secret_keys.key_version"},
+	},
+	{
+		Name:    "audit_log",
+		Enabled: func(cfg *config.Config) bool { return cfg.AuditLogEnabled },
+		Schema:  []string{"audit_events", "audit_events.reason", "audit_events.actor"},
+	},
+	{
+		Name:    "lock_break",
+		Enabled: func(cfg *config.Config) bool { return cfg.AllowLockBreak },
+		Schema:  []string{"lock_ledger", "lock_breaks"},
+	},
+	{
+		Name:    "adaptive_rate_limit",
+		Enabled: func(cfg *config.Config) bool { return cfg.RateLimitAdaptive },
+		Schema:  []string{"client_reputation"},
+	},
+	{
+		Name:    "notify_email",
+		Enabled: func(cfg *config.Config) bool { return cfg.NotifyEmailKey != "" },
+		Check:   notify.CheckConfig,
+		Schema:  []string{"secrets.notify_email", "secrets.notify_email_hash"},
+	},
+	{
+		Name:    "db_listen",
+		Enabled: func(cfg *config.Config) bool { return cfg.DBListenEnabled },
+		Check: func(cfg *config.Config) []string {
+			if cfg.StorageBackend != config.StoragePostgres {
+				return []string{"DB_LISTEN_ENABLED requires STORAGE_BACKEND=postgres"}
+			}
+			return nil
+		},
+	},
+	{
+		Name:    "create_nonce",
+		Enabled: func(cfg *config.Config) bool { return cfg.RequireCreateNonce || len(cfg.NonceKeys) > 0 },
+		Check: func(cfg *config.Config) []string {
+			return checkKeyring("NONCE_KEYS", cfg.NonceKeys)
+		},
+	},
+	{
+		Name:    "support_dossiers",
+		Enabled: func(cfg *config.Config) bool { return len(cfg.DossierKeys) > 0 },
+		Check: func(cfg *config.Config) []string {
+			problems := checkKeyring("DOSSIER_KEYS", cfg.DossierKeys)
+			if cfg.AdminToken == "" {
+				problems = append(problems, "DOSSIER_KEYS requires ADMIN_TOKEN; dossiers are only served on the admin API")
+			}
+			return problems
+		},
+	},
+	{
+		Name:    "debug_endpoints",
+		Enabled: func(cfg *config.Config) bool { return cfg.DebugEndpointsEnabled },
+		Check: func(cfg *config.Config) []string {
+			if cfg.AdminToken == "" {
+				return []string{"DEBUG_ENDPOINTS_ENABLED requires ADMIN_TOKEN; the debug endpoints are only served behind it"}
+			}
+			return nil
+		},
+	},
+	{
+		Name:    "retrieval_links",
+		Enabled: func(cfg *config.Config) bool { return len(cfg.LinkKeys) > 0 },
+		Check: func(cfg *config.Config) []string {
+			return checkKeyring("LINK_KEYS", cfg.LinkKeys)
+		},
+	},
+	{
+		Name:    "client_hash_key",
+		Enabled: func(cfg *config.Config) bool { return cfg.ClientHashKey != "" },
+		Check: func(cfg *config.Config) []string {
+			return checkKeyring("CLIENT_HASH_KEY", []string{cfg.ClientHashKey})
+		},
+	},
+	{
+		Name:    "network_labels",
+		Enabled: func(cfg *config.Config) bool { return len(cfg.NetworkLabels) > 0 },
+		Check: func(cfg *config.Config) []string {
+			if _, err := netclass.ParseRanges(cfg.NetworkLabels); err != nil {
+				return []string{"invalid NETWORK_LABELS: " + err.Error()}
+			}
+			return nil
+		},
+	},
+	{
+		Name:    "trusted_proxies",
+		Enabled: func(cfg *config.Config) bool { return len(cfg.TrustedProxies) > 0 },
+		Check: func(cfg *config.Config) []string {
+			if _, err := middleware.ParseTrustedProxies(cfg.TrustedProxies); err != nil {
+				return []string{"invalid TRUSTED_PROXIES: " + err.Error()}
+			}
+			return nil
+		},
+	},
+	{
+		Name:    "regions",
+		Enabled: func(cfg *config.Config) bool { return cfg.RegionCode != "" || len(cfg.RegionPeers) > 0 },
+		Check: func(cfg *config.Config) []string {
+			var problems []string
+			if cfg.RegionCode == "" {
+				problems = append(problems, "REGION_PEERS requires REGION_CODE")
+			} else if err := validation.ValidateRegionCode(cfg.RegionCode); err != nil {
+				problems = append(problems, "invalid REGION_CODE: "+err.Error())
+			}
+			if _, err := api.ParseRegionPeers(cfg.RegionPeers); err != nil {
+				problems = append(problems, "invalid REGION_PEERS: "+err.Error())
+			}
+			return problems
+		},
+	},
+	{
+		Name:    "scan_rules",
+		Enabled: func(cfg *config.Config) bool { return cfg.ScanRules != "" || cfg.ScanRulesFile != "" },
+		Check: func(cfg *config.Config) []string {
+			var err error
+			if cfg.ScanRulesFile != "" {
+				_, err = scan.LoadRulesFile(cfg.ScanRulesFile)
+			} else {
+				_, err = scan.ParseRules([]byte(cfg.ScanRules))
+			}
+			if err != nil {
+				return []string{err.Error()}
+			}
+			return nil
+		},
+	},
+	{
+		Name:    "canary_readiness",
+		Enabled: func(cfg *config.Config) bool { return cfg.CanaryReadiness },
+		Check: func(cfg *config.Config) []string {
+			if cfg.CanaryInterval <= 0 {
+				return []string{"CANARY_READINESS requires CANARY_INTERVAL"}
+			}
+			return nil
+		},
+	},
+	{
+		Name:    "tls",
+		Enabled: func(cfg *config.Config) bool { return cfg.TLSCertFile != "" || cfg.TLSKeyFile != "" },
+		Check: func(cfg *config.Config) []string {
+			switch {
+			case len(cfg.ACMEDomains) > 0:
+				return []string{"set either TLS_CERT_FILE/TLS_KEY_FILE or ACME_DOMAINS, not both"}
+			case cfg.TLSCertFile == "" || cfg.TLSKeyFile == "":
+				return []string{"TLS_CERT_FILE and TLS_KEY_FILE must be set together"}
+			}
+			if _, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile); err != nil {
+				return []string{"load certificate: " + err.Error()}
+			}
+			return nil
+		},
+	},
+	{
+		Name:    "http_redirect",
+		Enabled: func(cfg *config.Config) bool { return cfg.HTTPRedirectPort != "" },
+		Check: func(cfg *config.Config) []string {
+			if cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" && len(cfg.ACMEDomains) == 0 {
+				return []string{"HTTP_REDIRECT_PORT requires TLS_CERT_FILE/TLS_KEY_FILE or ACME_DOMAINS"}
+			}
+			return nil
+		},
+	},
+}
+
+// Resolve checks every enabled feature in Registry against cfg and, when
+// schema is not nil, the database. It returns a Report listing every unmet
+// prerequisite, nil when there are none; the error is for a schema that
+// could not be read.
+func Resolve(ctx context.Context, cfg *config.Config, schema store.SchemaInspector) (Report, error) {
+	return resolve(ctx, Registry, cfg, schema)
+}
+
+func resolve(ctx context.Context, registry []Feature, cfg *config.Config, schema store.SchemaInspector) (Report, error) {
+	var report Report
+	for _, feature := range registry {
+		if feature.Enabled != nil && !feature.Enabled(cfg) {
+			continue
+		}
+
+		if feature.Check != nil {
+			for _, detail := range feature.Check(cfg) {
+				report = append(report, Problem{feature.Name, detail})
+			}
+		}
+
+		if schema == nil {
+			continue
+		}
+		for _, element := range feature.Schema {
+			table, column, _ := strings.Cut(element, ".")
+			ok, err := schema.HasColumn(ctx, table, column)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				report = append(report, Problem{feature.Name, missingSchema(table, column)})
+			}
+		}
+	}
+	return report, nil
+}
+
+// missingSchema describes a table or column the database lacks
+func missingSchema(table, column string) string {
+	if column == "" {
+		return "table " + table + " is missing; run `server migrate`"
+	}
+	return "column " + table + "." + column + " is missing; run `server migrate`"
+}
+
+// checkKeyring reports a keyring variable whose keys do not parse
+func checkKeyring(name string, values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	if _, err := crypto.ParseKeyring(values); err != nil {
+		return []string{"invalid " + name + ": " + err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package features
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"ots-backend/internal/config"
+)
+
+// fakeSchema has every table and column except those in missing
+type fakeSchema struct {
+	missing []string
+	err     error
+}
+
+func (f fakeSchema) HasColumn(ctx context.Context, table, column string) (bool, error) {
+	element := table
+	if column != "" {
+		element += "." + column
+	}
+	return !slices.Contains(f.missing, element), f.err
+}
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		mutate  func(cfg *config.Config)
+		missing []string
+		want    Report
+	}{
+		{
+			name: "defaults",
+		},
+		{
+			name:    "disabled features are not checked",
+			missing: []string{"audit_events", "secret_keys", "lock_ledger"},
+		},
+		{
+			name:    "schema behind the binary",
+			missing: []string{"secret_keys", "secrets.key_wrapped", "audit_events.actor", "secrets.available_after"},
+			mutate: func(cfg *config.Config) {
+				cfg.CryptoShredding = true
+				cfg.AuditLogEnabled = true
+			},
+			want: Report{
+				{"secrets", "column secrets.available_after is missing; run `server migrate`"},
+				{"crypto_shredding", "table secret_keys is missing; run `server migrate`"},
+				{"crypto_shredding", "column secrets.key_wrapped is missing; run `server migrate`"},
+				{"audit_log", "column audit_events.actor is missing; run `server migrate`"},
+			},
+		},
+		{
+			name: "flags enabled piecemeal",
+			mutate: func(cfg *config.Config) {
+				cfg.StorageBackend = config.StorageSQLite
+				cfg.DatabaseURL = "sqlite://ots.db"
+				cfg.DBListenEnabled = true
+				cfg.DossierKeys = []string{"not base64!"}
+				cfg.DebugEndpointsEnabled = true
+				cfg.RegionPeers = []string{"eu=https://eu.example.com"}
+				cfg.CanaryReadiness = true
+				cfg.HTTPRedirectPort = "80"
+				cfg.TLSKeyFile = "/etc/ots/key.pem"
+			},
+			want: Report{
+				{"db_listen", "DB_LISTEN_ENABLED requires STORAGE_BACKEND=postgres"},
+				{"support_dossiers", "invalid DOSSIER_KEYS"},
+				{"support_dossiers", "DOSSIER_KEYS requires ADMIN_TOKEN; dossiers are only served on the admin API"},
+				{"debug_endpoints", "DEBUG_ENDPOINTS_ENABLED requires ADMIN_TOKEN; the debug endpoints are only served behind it"},
+				{"regions", "REGION_PEERS requires REGION_CODE"},
+				{"canary_readiness", "CANARY_READINESS requires CANARY_INTERVAL"},
+				{"tls", "TLS_CERT_FILE and TLS_KEY_FILE must be set together"},
+			},
+		},
+		{
+			name: "unparseable values",
+			mutate: func(cfg *config.Config) {
+				cfg.StorageBackend = config.StorageSQLite
+				cfg.DatabaseURL = "postgres://localhost/ots"
+				cfg.NonceKeys = []string{"c2hvcnQ="}
+				cfg.NetworkLabels = []string{"office=not-a-cidr"}
+				cfg.TrustedProxies = []string{"proxy.internal"}
+				cfg.RegionCode = "EU!"
+				cfg.ScanRules = `{"rules": [{"name": ""}]}`
+			},
+			want: Report{
+				{"secrets", "STORAGE_BACKEND=sqlite requires DATABASE_URL=sqlite://<path>"},
+				{"create_nonce", "invalid NONCE_KEYS"},
+				{"network_labels", "invalid NETWORK_LABELS"},
+				{"trusted_proxies", "invalid TRUSTED_PROXIES"},
+				{"regions", "invalid REGION_CODE"},
+				{"scan_rules", "scan rule 0: name is required"},
+			},
+		},
+		{
+			name: "fully configured",
+			mutate: func(cfg *config.Config) {
+				cfg.CryptoShredding = true
+				cfg.AuditLogEnabled = true
+				cfg.DBListenEnabled = true
+				cfg.AdminToken = "admin"
+				cfg.DossierKeys = []string{"MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="}
+				cfg.DebugEndpointsEnabled = true
+				cfg.RegionCode = "us"
+				cfg.CanaryInterval = time.Minute
+				cfg.CanaryReadiness = true
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{StorageBackend: config.StoragePostgres}
+			if tt.mutate != nil {
+				tt.mutate(cfg)
+			}
+
+			report, err := Resolve(ctx, cfg, fakeSchema{missing: tt.missing})
+			if err != nil {
+				t.Fatalf("Resolve() error: %v", err)
+			}
+			if len(report) != len(tt.want) {
+				t.Fatalf("Resolve() = %v, want %v", report, tt.want)
+			}
+			// Parse errors are matched by prefix; their wording is the parser's
+			for i, problem := range report {
+				if problem.Feature != tt.want[i].Feature || !strings.HasPrefix(problem.Detail, tt.want[i].Detail) {
+					t.Errorf("Resolve()[%d] = %+v, want %+v", i, problem, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestResolveWithoutSchema(t *testing.T) {
+	cfg := &config.Config{StorageBackend: config.StorageMemory, CryptoShredding: true, DBListenEnabled: true}
+
+	report, err := Resolve(context.Background(), cfg, nil)
+	if err != nil {
+		t.Fatalf("Resolve() error: %v", err)
+	}
+	if len(report) != 1 || report[0].Feature != "db_listen" {
+		t.Errorf("Resolve() without schema = %v, want only the db_listen config problem", report)
+	}
+}
+
+func TestResolveSchemaError(t *testing.T) {
+	errSchema := errors.New("connection refused")
+
+	_, err := Resolve(context.Background(), &config.Config{}, fakeSchema{err: errSchema})
+	if !errors.Is(err, errSchema) {
+		t.Errorf("Resolve() error = %v, want %v", err, errSchema)
+	}
+}
+
+func TestReportError(t *testing.T) {
+	report := Report{
+		{"db_listen", "DB_LISTEN_ENABLED requires STORAGE_BACKEND=postgres"},
+		{"crypto_shredding", "table secret_keys is missing; run `server migrate`"},
+	}
+
+	want := "2 unmet feature prerequisites:\n" +
+		"  db_listen: DB_LISTEN_ENABLED requires STORAGE_BACKEND=postgres\n" +
+		"  crypto_shredding: table secret_keys is missing; run `server migrate`"
+	if got := report.Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
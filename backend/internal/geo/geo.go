@@ -0,0 +1,68 @@
+// Package geo looks up the country of a client address, so a secret's
+// creator can learn roughly where it was read without the server keeping
+// the reader's IP, and refuse clients by country. The server looks
+// countries up in a MaxMind DB file read with OpenMMDB.
+package geo
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Locator maps an address to its ISO 3166-1 alpha-2 country code, or ""
+// when unknown
+type Locator interface {
+	Country(addr netip.Addr) string
+}
+
+// None locates nothing; it is the default
+var None Locator = none{}
+
+type none struct{}
+
+func (none) Country(netip.Addr) string {
+	return ""
+}
+
+// Lookup parses ip and returns its country from l, normalized to two
+// upper-case letters. Anything else a Locator returns, such as a region or
+// city, is dropped so only coarse locations are stored.
+func Lookup(l Locator, ip string) string {
+	if l == nil {
+		return ""
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	return Coarse(l.Country(addr.Unmap().WithZone("")))
+}
+
+// Coarse returns code upper-cased if it is two ASCII letters, else ""
+func Coarse(code string) string {
+	if len(code) != 2 {
+		return ""
+	}
+	for i := range len(code) {
+		c := code[i] | 0x20
+		if c < 'a' || c > 'z' {
+			return ""
+		}
+	}
+	return strings.ToUpper(code)
+}
+
+// ParseCountries normalizes a list of country codes, as GEO_ALLOW and
+// GEO_DENY take them. Anything but two letters is an error.
+func ParseCountries(codes []string) ([]string, error) {
+	out := make([]string, 0, len(codes))
+	for _, code := range codes {
+		country := Coarse(strings.TrimSpace(code))
+		if country == "" {
+			return nil, fmt.Errorf("invalid country code %q: want two letters, such as NL", code)
+		}
+		out = append(out, country)
+	}
+	return out, nil
+}
@@ -0,0 +1,50 @@
+package geo
+
+import (
+	"net/netip"
+	"testing"
+)
+
+// fixed answers with one country for every address, recording the last
+type fixed struct {
+	country string
+	asked   netip.Addr
+}
+
+func (f *fixed) Country(addr netip.Addr) string {
+	f.asked = addr
+	return f.country
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name    string
+		locator Locator
+		ip      string
+		want    string
+	}{
+		{name: "none", locator: None, ip: "203.0.113.9", want: ""},
+		{name: "nil", locator: nil, ip: "203.0.113.9", want: ""},
+		{name: "country", locator: &fixed{country: "NL"}, ip: "203.0.113.9", want: "NL"},
+		{name: "lower case", locator: &fixed{country: "de"}, ip: "2001:db8::1", want: "DE"},
+		{name: "finer than a country", locator: &fixed{country: "US-CA"}, ip: "203.0.113.9", want: ""},
+		{name: "not letters", locator: &fixed{country: "1A"}, ip: "203.0.113.9", want: ""},
+		{name: "bad address", locator: &fixed{country: "NL"}, ip: "not-an-ip", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Lookup(tt.locator, tt.ip); got != tt.want {
+				t.Errorf("Lookup(%q) = %q, want %q", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupUnmapsAddresses(t *testing.T) {
+	locator := &fixed{country: "NL"}
+	Lookup(locator, "::ffff:203.0.113.9")
+	if want := netip.MustParseAddr("203.0.113.9"); locator.asked != want {
+		t.Errorf("Locator asked about %v, want %v", locator.asked, want)
+	}
+}
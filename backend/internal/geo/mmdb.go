@@ -0,0 +1,340 @@
+package geo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+	"os"
+)
+
+// Resolver maps an address to its ISO 3166-1 alpha-2 country code. Unlike
+// a Locator it reports failed lookups, so a caller can tell an address it
+// does not know from a database it cannot read. An unknown address is "",
+// nil.
+type Resolver interface {
+	Resolve(addr netip.Addr) (string, error)
+}
+
+// metadataMarker starts the metadata section at the end of a MaxMind DB
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// maxDepth bounds how deep data may nest, so a damaged file cannot recurse
+// without end through pointers
+const maxDepth = 32
+
+// MaxMind DB data types, numbered as in the format specification
+const (
+	mmdbExtended = iota
+	mmdbPointer
+	mmdbString
+	mmdbDouble
+	mmdbBytes
+	mmdbUint16
+	mmdbUint32
+	mmdbMap
+	mmdbInt32
+	mmdbUint64
+	mmdbUint128
+	mmdbArray
+	mmdbContainer
+	mmdbEndMarker
+	mmdbBool
+	mmdbFloat
+)
+
+// MMDB is a MaxMind DB file held in memory, such as GeoLite2 Country or
+// City. Only the country of a record is read: country.iso_code, falling
+// back to registered_country.iso_code. It is both a Resolver and a Locator.
+type MMDB struct {
+	tree       []byte
+	data       []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	ipv4Start  uint
+}
+
+// OpenMMDB reads the MaxMind DB file at path
+func OpenMMDB(path string) (*MMDB, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read GeoIP database: %w", err)
+	}
+	db, err := ParseMMDB(buf)
+	if err != nil {
+		return nil, fmt.Errorf("GeoIP database %s: %w", path, err)
+	}
+	return db, nil
+}
+
+// ParseMMDB reads a MaxMind DB file from buf, which it keeps
+func ParseMMDB(buf []byte) (*MMDB, error) {
+	start := bytes.LastIndex(buf, metadataMarker)
+	if start < 0 {
+		return nil, errors.New("not a MaxMind DB: no metadata")
+	}
+	value, _, err := decoder{buf: buf[start+len(metadataMarker):]}.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("metadata: %w", err)
+	}
+	meta, ok := value.(map[string]any)
+	if !ok {
+		return nil, errors.New("metadata is not a map")
+	}
+
+	db := &MMDB{
+		nodeCount:  metaUint(meta, "node_count"),
+		recordSize: metaUint(meta, "record_size"),
+		ipVersion:  metaUint(meta, "ip_version"),
+	}
+	if db.recordSize != 24 && db.recordSize != 28 && db.recordSize != 32 {
+		return nil, fmt.Errorf("unsupported record size %d", db.recordSize)
+	}
+	if db.ipVersion != 4 && db.ipVersion != 6 {
+		return nil, fmt.Errorf("unsupported IP version %d", db.ipVersion)
+	}
+	treeSize := db.nodeCount * db.recordSize / 4
+	if db.nodeCount == 0 || treeSize+16 > uint(start) {
+		return nil, errors.New("search tree is truncated")
+	}
+	db.tree = buf[:treeSize]
+	db.data = buf[treeSize+16 : start]
+
+	// IPv4 addresses live under ::/96 in an IPv6 tree
+	if db.ipVersion == 6 {
+		for i := 0; i < 96 && db.ipv4Start < db.nodeCount; i++ {
+			db.ipv4Start = db.record(db.ipv4Start, 0)
+		}
+	}
+	return db, nil
+}
+
+// Resolve returns the country of addr, or "" when the database has no
+// record for it
+func (db *MMDB) Resolve(addr netip.Addr) (string, error) {
+	addr = addr.Unmap()
+	var ip []byte
+	node := uint(0)
+	switch {
+	case addr.Is4():
+		b := addr.As4()
+		ip = b[:]
+		node = db.ipv4Start
+	case addr.Is6() && db.ipVersion == 6:
+		b := addr.As16()
+		ip = b[:]
+	default:
+		return "", nil
+	}
+
+	for i := 0; i < len(ip)*8 && node < db.nodeCount; i++ {
+		node = db.record(node, uint(ip[i/8]>>(7-i%8))&1)
+	}
+	switch {
+	case node == db.nodeCount:
+		return "", nil
+	case node < db.nodeCount:
+		return "", errors.New("search tree is deeper than an address")
+	}
+
+	offset := node - db.nodeCount - 16
+	if offset >= uint(len(db.data)) {
+		return "", fmt.Errorf("record points past the data section")
+	}
+	value, _, err := decoder{buf: db.data}.decode(offset, 0)
+	if err != nil {
+		return "", fmt.Errorf("record for %s: %w", addr, err)
+	}
+	record, _ := value.(map[string]any)
+	for _, key := range []string{"country", "registered_country"} {
+		if country, ok := record[key].(map[string]any); ok {
+			if code, ok := country["iso_code"].(string); ok {
+				return code, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// Country is Resolve with errors treated as unknown
+func (db *MMDB) Country(addr netip.Addr) string {
+	code, _ := db.Resolve(addr)
+	return code
+}
+
+// record returns the left (bit 0) or right (bit 1) record of node
+func (db *MMDB) record(node, bit uint) uint {
+	switch db.recordSize {
+	case 24:
+		b := db.tree[node*6+bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := db.tree[node*7:]
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(db.tree[node*8+bit*4:]))
+	}
+}
+
+func metaUint(meta map[string]any, key string) uint {
+	v, _ := meta[key].(uint64)
+	if v > math.MaxUint32 {
+		return 0
+	}
+	return uint(v)
+}
+
+// decoder reads values from a MaxMind DB data section. Pointers are
+// offsets into buf.
+type decoder struct {
+	buf []byte
+}
+
+var errTruncated = errors.New("data is truncated")
+
+// decode returns the value at offset and the offset just past it
+func (d decoder) decode(offset uint, depth int) (any, uint, error) {
+	if depth > maxDepth {
+		return nil, 0, errors.New("data nests too deep")
+	}
+	if offset >= uint(len(d.buf)) {
+		return nil, 0, errTruncated
+	}
+	ctrl := d.buf[offset]
+	offset++
+	typ := uint(ctrl >> 5)
+
+	if typ == mmdbPointer {
+		n := uint(ctrl>>3&3) + 1
+		b, next, err := d.take(offset, n)
+		if err != nil {
+			return nil, 0, err
+		}
+		v := uint(ctrl & 7)
+		var target uint
+		switch n {
+		case 1:
+			target = v<<8 | uint(b[0])
+		case 2:
+			target = (v<<16 | uint(b[0])<<8 | uint(b[1])) + 2048
+		case 3:
+			target = (v<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])) + 526336
+		default:
+			target = uint(binary.BigEndian.Uint32(b))
+		}
+		value, _, err := d.decode(target, depth+1)
+		return value, next, err
+	}
+
+	if typ == mmdbExtended {
+		if offset >= uint(len(d.buf)) {
+			return nil, 0, errTruncated
+		}
+		typ = 7 + uint(d.buf[offset])
+		offset++
+	}
+
+	size := uint(ctrl & 0x1F)
+	if size >= 29 {
+		n := size - 28
+		b, next, err := d.take(offset, n)
+		if err != nil {
+			return nil, 0, err
+		}
+		offset = next
+		switch n {
+		case 1:
+			size = 29 + uint(b[0])
+		case 2:
+			size = 285 + (uint(b[0])<<8 | uint(b[1]))
+		default:
+			size = 65821 + (uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]))
+		}
+	}
+
+	switch typ {
+	case mmdbMap:
+		m := make(map[string]any, min(size, 16))
+		for range size {
+			key, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, 0, errors.New("map key is not a string")
+			}
+			value, next, err := d.decode(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[name] = value
+			offset = next
+		}
+		return m, offset, nil
+	case mmdbArray:
+		a := make([]any, 0, min(size, 16))
+		for range size {
+			value, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+			offset = next
+		}
+		return a, offset, nil
+	case mmdbBool:
+		return size != 0, offset, nil
+	}
+
+	b, next, err := d.take(offset, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch typ {
+	case mmdbString:
+		return string(b), next, nil
+	case mmdbBytes:
+		return bytes.Clone(b), next, nil
+	case mmdbDouble:
+		if size != 8 {
+			return nil, 0, errors.New("double is not 8 bytes")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+	case mmdbFloat:
+		if size != 4 {
+			return nil, 0, errors.New("float is not 4 bytes")
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), next, nil
+	case mmdbUint16, mmdbUint32, mmdbUint64, mmdbInt32:
+		if size > 8 {
+			return nil, 0, errors.New("integer is too long")
+		}
+		var n uint64
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		if typ == mmdbInt32 {
+			return int64(int32(n)), next, nil
+		}
+		return n, next, nil
+	case mmdbUint128:
+		// Nothing read here is this wide; keep the position right
+		return nil, next, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported data type %d", typ)
+}
+
+// take returns the n bytes at offset and the offset after them
+func (d decoder) take(offset, n uint) ([]byte, uint, error) {
+	if offset+n > uint(len(d.buf)) {
+		return nil, 0, errTruncated
+	}
+	return d.buf[offset : offset+n], offset + n, nil
+}
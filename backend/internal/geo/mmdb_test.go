@@ -0,0 +1,186 @@
+package geo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// u16, u32 and pointer pick how buildMMDB encodes a value
+type (
+	u16     uint16
+	u32     uint32
+	pointer uint
+)
+
+// encodeMMDB writes v in the MaxMind DB data format
+func encodeMMDB(v any) []byte {
+	switch v := v.(type) {
+	case string:
+		return append([]byte{2<<5 | byte(len(v))}, v...)
+	case u16:
+		return []byte{5<<5 | 2, byte(v >> 8), byte(v)}
+	case u32:
+		return binary.BigEndian.AppendUint32([]byte{6<<5 | 4}, uint32(v))
+	case pointer:
+		return []byte{1<<5 | byte(v>>8&7), byte(v)}
+	case []any:
+		out := []byte{byte(len(v)), mmdbArray - 7}
+		for _, item := range v {
+			out = append(out, encodeMMDB(item)...)
+		}
+		return out
+	case map[string]any:
+		out := []byte{7<<5 | byte(len(v))}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			out = append(out, encodeMMDB(key)...)
+			out = append(out, encodeMMDB(v[key])...)
+		}
+		return out
+	}
+	panic("cannot encode")
+}
+
+// buildMMDB writes an IPv6 MaxMind DB holding networks. shared is put at
+// the start of the data section, where a pointer(0) finds it.
+func buildMMDB(t *testing.T, recordSize int, shared any, networks map[string]map[string]any) []byte {
+	t.Helper()
+
+	// A record is a node index, -1 for no data or -2-offset for data
+	nodes := [][2]int{{-1, -1}}
+	data := encodeMMDB(shared)
+	for prefix, record := range networks {
+		p := netip.MustParsePrefix(prefix)
+		ip, bits := p.Addr().As16(), p.Bits()
+		if p.Addr().Is4() {
+			ip = [16]byte{}
+			v4 := p.Addr().As4()
+			copy(ip[12:], v4[:])
+			bits += 96
+		}
+		offset := len(data)
+		data = append(data, encodeMMDB(record)...)
+
+		n := 0
+		for i := range bits {
+			bit := ip[i/8] >> (7 - i%8) & 1
+			if i == bits-1 {
+				nodes[n][bit] = -2 - offset
+				break
+			}
+			if nodes[n][bit] < 0 {
+				nodes = append(nodes, [2]int{-1, -1})
+				nodes[n][bit] = len(nodes) - 1
+			}
+			n = nodes[n][bit]
+		}
+	}
+
+	count := len(nodes)
+	value := func(r int) uint32 {
+		switch {
+		case r >= 0:
+			return uint32(r)
+		case r == -1:
+			return uint32(count)
+		}
+		return uint32(count + 16 + (-2 - r))
+	}
+	var out []byte
+	for _, node := range nodes {
+		left, right := value(node[0]), value(node[1])
+		switch recordSize {
+		case 24:
+			out = append(out, byte(left>>16), byte(left>>8), byte(left), byte(right>>16), byte(right>>8), byte(right))
+		case 28:
+			out = append(out, byte(left>>16), byte(left>>8), byte(left), byte(left>>20&0xF0|right>>24&0x0F), byte(right>>16), byte(right>>8), byte(right))
+		default:
+			out = binary.BigEndian.AppendUint32(out, left)
+			out = binary.BigEndian.AppendUint32(out, right)
+		}
+	}
+	out = append(out, make([]byte, 16)...)
+	out = append(out, data...)
+	out = append(out, metadataMarker...)
+	return append(out, encodeMMDB(map[string]any{
+		"binary_format_major_version": u16(2),
+		"database_type":               "Test-Country",
+		"ip_version":                  u16(6),
+		"languages":                   []any{"en"},
+		"node_count":                  u32(count),
+		"record_size":                 u16(recordSize),
+	})...)
+}
+
+func testMMDB(t *testing.T, recordSize int) []byte {
+	return buildMMDB(t, recordSize, map[string]any{"iso_code": "US"}, map[string]map[string]any{
+		"203.0.113.0/24":  {"country": map[string]any{"iso_code": "NL"}},
+		"198.51.100.0/24": {"registered_country": pointer(0)},
+		"2001:db8::/32":   {"country": map[string]any{"iso_code": "DE", "names": map[string]any{"en": "Germany"}}},
+	})
+}
+
+func TestMMDBResolve(t *testing.T) {
+	for _, recordSize := range []int{24, 28, 32} {
+		db, err := ParseMMDB(testMMDB(t, recordSize))
+		if err != nil {
+			t.Fatalf("ParseMMDB() with %d-bit records error: %v", recordSize, err)
+		}
+		for ip, want := range map[string]string{
+			"203.0.113.9":        "NL",
+			"::ffff:203.0.113.9": "NL",
+			"198.51.100.7":       "US",
+			"2001:db8::1":        "DE",
+			"192.0.2.1":          "",
+			"2001:db9::1":        "",
+		} {
+			got, err := db.Resolve(netip.MustParseAddr(ip))
+			if err != nil || got != want {
+				t.Errorf("%d-bit Resolve(%s) = %q, %v; want %q, nil", recordSize, ip, got, err, want)
+			}
+		}
+	}
+}
+
+func TestMMDBErrors(t *testing.T) {
+	if _, err := ParseMMDB([]byte("not a database")); err == nil {
+		t.Error("ParseMMDB() of garbage succeeded, want an error")
+	}
+	if _, err := OpenMMDB(filepath.Join(t.TempDir(), "missing.mmdb")); err == nil {
+		t.Error("OpenMMDB() of a missing file succeeded, want an error")
+	}
+
+	buf := testMMDB(t, 24)
+	cut := bytes.Index(buf, []byte("Germany"))
+	damaged := append(buf[:cut:cut], buf[bytes.LastIndex(buf, metadataMarker):]...)
+	db, err := ParseMMDB(damaged)
+	if err != nil {
+		t.Fatalf("ParseMMDB() of a cut data section error: %v", err)
+	}
+	if _, err := db.Resolve(netip.MustParseAddr("2001:db8::1")); err == nil {
+		t.Error("Resolve() of a cut record succeeded, want an error")
+	}
+	if got := db.Country(netip.MustParseAddr("2001:db8::1")); got != "" {
+		t.Errorf("Country() of a cut record = %q, want \"\"", got)
+	}
+}
+
+func TestParseCountries(t *testing.T) {
+	got, err := ParseCountries([]string{"nl", " DE "})
+	if err != nil || !slices.Equal(got, []string{"NL", "DE"}) {
+		t.Errorf("ParseCountries() = %q, %v; want [NL DE]", got, err)
+	}
+	for _, bad := range []string{"NLD", "U1", ""} {
+		if _, err := ParseCountries([]string{bad}); err == nil {
+			t.Errorf("ParseCountries(%q) succeeded, want an error", bad)
+		}
+	}
+}
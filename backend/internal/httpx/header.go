@@ -0,0 +1,142 @@
+// Package httpx keeps user-supplied text from breaking HTTP headers. Header
+// values that are not constants go through SetHeader, HeaderValue or
+// ContentDisposition, so CR, LF and other control characters can never split
+// a response or smuggle a field into an outbound request.
+package httpx
+
+import (
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ValidHeaderValue reports whether v is an RFC 9110 field-value made only of
+// ASCII: visible characters with spaces and tabs between them, and no
+// whitespace at either end
+func ValidHeaderValue(v string) bool {
+	for i := 0; i < len(v); i++ {
+		if !fieldByte(v[i]) {
+			return false
+		}
+	}
+	return v == strings.Trim(v, " \t")
+}
+
+// HeaderValue returns v with everything a field-value may not hold dropped:
+// control characters, line and paragraph separators, bidi overrides and all
+// other non-ASCII, then surrounding whitespace. Non-ASCII is dropped rather
+// than passed as obs-text because some proxies truncate runes to bytes,
+// which turns U+010A into a line feed.
+func HeaderValue(v string) string {
+	if ValidHeaderValue(v) {
+		return v
+	}
+
+	var b strings.Builder
+	b.Grow(len(v))
+	for i := 0; i < len(v); i++ {
+		if fieldByte(v[i]) {
+			b.WriteByte(v[i])
+		}
+	}
+	return strings.Trim(b.String(), " \t")
+}
+
+// SetHeader sets a header to HeaderValue(value)
+func SetHeader(h http.Header, name, value string) {
+	h.Set(name, HeaderValue(value))
+}
+
+// ContentDisposition renders a Content-Disposition value naming filename.
+// Control and formatting characters and any directory part are removed
+// first. The quoted filename is an ASCII fallback with everything else
+// replaced by '_'; names that needed replacing also get an RFC 5987
+// filename* with the full UTF-8 name percent-encoded. An empty name yields
+// the bare disposition.
+func ContentDisposition(disposition, filename string) string {
+	name := cleanFilename(filename)
+	if name == "" {
+		return disposition
+	}
+
+	var fallback []byte
+	for _, r := range name {
+		if r >= 0x20 && r < 0x7f && r != '"' && r != '\\' {
+			fallback = append(fallback, byte(r))
+		} else {
+			fallback = append(fallback, '_')
+		}
+	}
+
+	value := disposition + `; filename="` + string(fallback) + `"`
+	if string(fallback) != name {
+		value += "; filename*=" + ExtValue(name)
+	}
+	return value
+}
+
+// ExtValue renders s as an RFC 8187 ext-value, UTF-8 with every byte that
+// is not an attr-char percent-encoded, so any text fits a header field
+func ExtValue(s string) string {
+	return "UTF-8''" + encodeRFC5987(s)
+}
+
+// fieldByte reports whether b may appear in an ASCII field-value
+func fieldByte(b byte) bool {
+	return b == '\t' || (b >= 0x20 && b < 0x7f)
+}
+
+// cleanFilename drops invalid UTF-8, control, format and separator
+// characters and keeps only the last path element
+func cleanFilename(filename string) string {
+	var b strings.Builder
+	for len(filename) > 0 {
+		r, size := utf8.DecodeRuneInString(filename)
+		filename = filename[size:]
+		if r == utf8.RuneError && size == 1 {
+			continue
+		}
+		if unicode.IsControl(r) || unicode.In(r, unicode.Cf, unicode.Zl, unicode.Zp) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	name := b.String()
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSpace(name)
+	if name == "." || name == ".." {
+		return ""
+	}
+	return name
+}
+
+// encodeRFC5987 percent-encodes every byte of s that is not an attr-char
+func encodeRFC5987(s string) string {
+	const hex = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if attrChar(c) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0xf])
+	}
+	return b.String()
+}
+
+// attrChar reports whether c is an RFC 5987 attr-char
+func attrChar(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
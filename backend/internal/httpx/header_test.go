@@ -0,0 +1,105 @@
+package httpx
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// adversarial are values that try to end a header line or hide in one
+var adversarial = []string{
+	"a\r\nSet-Cookie: x=1",
+	"a\nSet-Cookie: x=1",
+	"a\rX-Injected: 1",
+	"a\x00b",
+	"a\x7fb",
+	"a\u0085b",
+	"a\u2028b\u2029c",
+	"a\u202eb",
+	"a\u200bb\ufeff",
+	"a\u010aSet-Cookie: x=1",
+	"a\xffb",
+	" \tpadded\t ",
+}
+
+func TestValidHeaderValue(t *testing.T) {
+	for _, v := range []string{"", "text/plain", `Bearer realm="admin"`, "a\tb c", "<https://example.com>; rel=next"} {
+		if !ValidHeaderValue(v) {
+			t.Errorf("ValidHeaderValue(%q) = false, want true", v)
+		}
+	}
+	for _, v := range adversarial {
+		if ValidHeaderValue(v) {
+			t.Errorf("ValidHeaderValue(%q) = true, want false", v)
+		}
+	}
+}
+
+func TestHeaderValue(t *testing.T) {
+	for _, v := range adversarial {
+		got := HeaderValue(v)
+		if !ValidHeaderValue(got) {
+			t.Errorf("HeaderValue(%q) = %q, not a valid field-value", v, got)
+		}
+		if strings.ContainsAny(got, "\r\n") {
+			t.Errorf("HeaderValue(%q) = %q, still holds a line break", v, got)
+		}
+	}
+
+	if got := HeaderValue("a\r\nSet-Cookie: x=1"); got != "aSet-Cookie: x=1" {
+		t.Errorf("HeaderValue() = %q, want the line break dropped", got)
+	}
+	if got := HeaderValue(`W/"abc"`); got != `W/"abc"` {
+		t.Errorf("HeaderValue() = %q, want a valid value unchanged", got)
+	}
+
+	h := http.Header{}
+	SetHeader(h, "X-Label", "team\r\nX-Injected: 1")
+	if len(h) != 1 || h.Get("X-Injected") != "" || h.Get("X-Label") != "teamX-Injected: 1" {
+		t.Errorf("SetHeader() header = %v, want one cleaned X-Label", h)
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"report.pdf", `attachment; filename="report.pdf"`},
+		{"", "attachment"},
+		{"\r\n", "attachment"},
+		{"../../etc/passwd", `attachment; filename="passwd"`},
+		{`C:\Users\me\key.pem`, `attachment; filename="key.pem"`},
+		{"..", "attachment"},
+		{`say "hi".txt`, `attachment; filename="say _hi_.txt"; filename*=UTF-8''say%20%22hi%22.txt`},
+		{"a\r\nContent-Type: text/html.txt", `attachment; filename="html.txt"`},
+		{"naïve résumé.txt", `attachment; filename="na_ve r_sum_.txt"; filename*=UTF-8''na%C3%AFve%20r%C3%A9sum%C3%A9.txt`},
+		{"evil\u202etxt.exe", `attachment; filename="eviltxt.exe"`},
+		{"a\u0085b\u2028c\xff.txt", `attachment; filename="abc.txt"`},
+		{"100%;x=1.txt", `attachment; filename="100%;x=1.txt"`},
+	}
+
+	for _, tt := range tests {
+		got := ContentDisposition("attachment", tt.filename)
+		if got != tt.want {
+			t.Errorf("ContentDisposition(%q) = %q, want %q", tt.filename, got, tt.want)
+		}
+		if !ValidHeaderValue(got) {
+			t.Errorf("ContentDisposition(%q) = %q, not a valid field-value", tt.filename, got)
+		}
+	}
+}
+
+func TestExtValue(t *testing.T) {
+	tests := map[string]string{
+		"VPN":                  "UTF-8''VPN",
+		"VPN for ACME &amp; é": "UTF-8''VPN%20for%20ACME%20&amp%3B%20%C3%A9",
+		"a\r\nX-Evil: 1":       "UTF-8''a%0D%0AX-Evil%3A%201",
+	}
+	for s, want := range tests {
+		got := ExtValue(s)
+		if got != want || !ValidHeaderValue(got) {
+			t.Errorf("ExtValue(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package httpx
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// moduleRoot is the backend module, relative to this package
+const moduleRoot = "../.."
+
+// headerWrite is a header set outside the helpers with a value that is not
+// a constant
+type headerWrite struct {
+	pos  token.Position
+	call string
+}
+
+// findHeaderWrites reports Set and Add calls on an http.Header, and direct
+// index assignments into one, whose value is not a constant. It works on
+// syntax alone: a header is a Header() call, a .Header field, or a name
+// assigned from either in the same file. Constants are string literals,
+// the package's own consts, concatenations of those, and strconv's number
+// and bool formatters.
+func findHeaderWrites(fset *token.FileSet, files []*ast.File) []headerWrite {
+	consts := make(map[string]bool)
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.CONST {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				for _, name := range spec.(*ast.ValueSpec).Names {
+					consts[name.Name] = true
+				}
+			}
+		}
+	}
+
+	var constant func(ast.Expr) bool
+	constant = func(expr ast.Expr) bool {
+		switch e := expr.(type) {
+		case *ast.BasicLit:
+			return e.Kind == token.STRING
+		case *ast.Ident:
+			return consts[e.Name]
+		case *ast.ParenExpr:
+			return constant(e.X)
+		case *ast.BinaryExpr:
+			return e.Op == token.ADD && constant(e.X) && constant(e.Y)
+		case *ast.CallExpr:
+			sel, ok := e.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return false
+			}
+			pkg, ok := sel.X.(*ast.Ident)
+			if !ok || pkg.Name != "strconv" {
+				return false
+			}
+			switch sel.Sel.Name {
+			case "Itoa", "FormatInt", "FormatUint", "FormatBool":
+				return true
+			}
+		}
+		return false
+	}
+
+	var writes []headerWrite
+	for _, file := range files {
+		headers := make(map[string]bool)
+		isHeader := func(expr ast.Expr) bool {
+			switch e := expr.(type) {
+			case *ast.CallExpr:
+				sel, ok := e.Fun.(*ast.SelectorExpr)
+				return ok && sel.Sel.Name == "Header" && len(e.Args) == 0
+			case *ast.SelectorExpr:
+				return e.Sel.Name == "Header"
+			case *ast.Ident:
+				return headers[e.Name]
+			}
+			return false
+		}
+
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.AssignStmt:
+				for i, lhs := range n.Lhs {
+					if index, ok := lhs.(*ast.IndexExpr); ok && isHeader(index.X) {
+						writes = append(writes, headerWrite{fset.Position(n.Pos()), "index assignment"})
+					}
+					if ident, ok := lhs.(*ast.Ident); ok && len(n.Rhs) == len(n.Lhs) && isHeader(n.Rhs[i]) {
+						headers[ident.Name] = true
+					}
+				}
+			case *ast.CallExpr:
+				sel, ok := n.Fun.(*ast.SelectorExpr)
+				if !ok || (sel.Sel.Name != "Set" && sel.Sel.Name != "Add") || len(n.Args) != 2 {
+					return true
+				}
+				if isHeader(sel.X) && !constant(n.Args[1]) {
+					writes = append(writes, headerWrite{fset.Position(n.Pos()), sel.Sel.Name})
+				}
+			}
+			return true
+		})
+	}
+	return writes
+}
+
+func TestHeaderWritesUseHelpers(t *testing.T) {
+	var dirs []string
+	err := filepath.WalkDir(moduleRoot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && (d.Name() == "testdata" || strings.HasPrefix(d.Name(), ".")) && path != moduleRoot {
+			return filepath.SkipDir
+		}
+		if d.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk module: %v", err)
+	}
+
+	self, _ := filepath.Abs(".")
+	checked := 0
+	for _, dir := range dirs {
+		if abs, _ := filepath.Abs(dir); abs == self {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("read %s: %v", dir, err)
+		}
+
+		fset := token.NewFileSet()
+		var files []*ast.File
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+				continue
+			}
+			file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+			if err != nil {
+				t.Fatalf("parse %s: %v", name, err)
+			}
+			files = append(files, file)
+		}
+		checked += len(files)
+
+		for _, write := range findHeaderWrites(fset, files) {
+			t.Errorf("%s: header %s with a non-constant value; use httpx.SetHeader or httpx.ContentDisposition", write.pos, write.call)
+		}
+	}
+	if checked == 0 {
+		t.Fatal("no source files checked")
+	}
+}
+
+func TestFindHeaderWrites(t *testing.T) {
+	const src = `package p
+
+const contentType = "application/json"
+
+func f(w http.ResponseWriter, r *http.Request, name string, n int) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Retry-After", strconv.Itoa(n))
+	w.Header().Set("Link", "<" + "/x" + ">")
+	w.Header().Set("X-Name", name)
+	header := w.Header()
+	header.Add("X-Name", "a" + name)
+	r.Header.Set("X-Name", name)
+	header["X-Name"] = []string{"a"}
+	values.Set("q", name)
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	var lines []int
+	for _, write := range findHeaderWrites(fset, []*ast.File{file}) {
+		lines = append(lines, write.pos.Line)
+	}
+	if want := []int{9, 11, 12, 13}; !equalInts(lines, want) {
+		t.Errorf("findHeaderWrites() lines = %v, want %v", lines, want)
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
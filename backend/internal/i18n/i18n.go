@@ -0,0 +1,158 @@
+// Package i18n translates the human message of API errors. Catalogs are
+// embedded JSON, one per locale, keyed by the stable error code; the code
+// itself is never translated. Limit errors take {min}, {max} and {unit}
+// parameters. English is the reference catalog every other one follows.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Default is the locale used when nothing in Accept-Language matches
+const Default = "en"
+
+//go:embed locales/*.json
+var files embed.FS
+
+// catalog is one locale's messages and unit names
+type catalog struct {
+	Units    map[string]string `json:"units"`
+	Messages map[string]string `json:"messages"`
+}
+
+// catalogs holds every embedded locale by lowercase tag
+var catalogs = mustLoad()
+
+func mustLoad() map[string]catalog {
+	loaded, err := load()
+	if err != nil {
+		panic(err)
+	}
+	return loaded
+}
+
+func load() (map[string]catalog, error) {
+	entries, err := files.ReadDir("locales")
+	if err != nil {
+		return nil, err
+	}
+	loaded := make(map[string]catalog, len(entries))
+	for _, entry := range entries {
+		data, err := files.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var c catalog
+		if err := json.Unmarshal(data, &c); err != nil {
+			return nil, fmt.Errorf("locale %s: %w", entry.Name(), err)
+		}
+		loaded[strings.ToLower(strings.TrimSuffix(entry.Name(), ".json"))] = c
+	}
+	if _, ok := loaded[Default]; !ok {
+		return nil, fmt.Errorf("no %s catalog", Default)
+	}
+	return loaded, nil
+}
+
+// Locales lists the available locales, sorted
+func Locales() []string {
+	locales := make([]string, 0, len(catalogs))
+	for locale := range catalogs {
+		locales = append(locales, locale)
+	}
+	slices.Sort(locales)
+	return locales
+}
+
+// Params fills a message's placeholders; a Unit is translated before use
+type Params struct {
+	Min, Max int64
+	Unit     string
+}
+
+// Message renders the message for code in locale. It reports false when
+// the locale has no message for code, or the message has placeholders and
+// params is empty, so the caller can keep its own.
+func Message(locale, code string, params Params) (string, bool) {
+	c, ok := catalogs[locale]
+	if !ok {
+		return "", false
+	}
+	template, ok := c.Messages[code]
+	if !ok || (params == Params{} && strings.Contains(template, "{")) {
+		return "", false
+	}
+	unit := params.Unit
+	if translated, ok := c.Units[unit]; ok {
+		unit = translated
+	}
+	return strings.NewReplacer(
+		"{min}", strconv.FormatInt(params.Min, 10),
+		"{max}", strconv.FormatInt(params.Max, 10),
+		"{unit}", unit,
+	).Replace(template), true
+}
+
+// Negotiate picks the locale that best matches an Accept-Language header.
+// Ranges are tried from the highest q-value down, ties in header order; a
+// range matches a locale exactly or by its primary subtag, so fr-CA picks
+// fr. Ranges with q=0, and a header naming nothing available, leave
+// Default.
+func Negotiate(header string) string {
+	type languageRange struct {
+		tag string
+		q   float64
+	}
+	var ranges []languageRange
+	for part := range strings.SplitSeq(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		for param := range strings.SplitSeq(params, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.TrimSpace(name) != "q" {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				parsed = 0
+			}
+			q = parsed
+		}
+		if q > 0 {
+			ranges = append(ranges, languageRange{tag, q})
+		}
+	}
+	slices.SortStableFunc(ranges, func(a, b languageRange) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+
+	for _, r := range ranges {
+		if r.tag == "*" {
+			return Default
+		}
+		if _, ok := catalogs[r.tag]; ok {
+			return r.tag
+		}
+		primary, _, _ := strings.Cut(r.tag, "-")
+		if _, ok := catalogs[primary]; ok {
+			return primary
+		}
+	}
+	return Default
+}
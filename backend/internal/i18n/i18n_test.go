@@ -0,0 +1,80 @@
+package i18n
+
+import (
+	"maps"
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "en"},
+		{"fr", "fr"},
+		{"FR", "fr"},
+		{"fr-CA", "fr"},
+		{"en-GB,en;q=0.9", "en"},
+		{"de, fr;q=0.8, en;q=0.5", "fr"},
+		{"en;q=0.5, fr;q=0.8", "fr"},
+		{"en;q=0.8, fr;q=0.8", "en"},
+		{"fr;q=0, en", "en"},
+		{"fr;q=0", "en"},
+		{"*", "en"},
+		{"de, *;q=0.5, fr;q=0.1", "en"},
+		{"fr;q=nonsense, en;q=0.1", "en"},
+		{"fr;q=2", "en"},
+		{"ar, de", "en"},
+		{" fr ; q = 0.3 , ar ; q=0.9", "fr"},
+	}
+	for _, tt := range tests {
+		if got := Negotiate(tt.header); got != tt.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	got, ok := Message("fr", "invalid_ttl", Params{Min: 60, Max: 604800, Unit: "seconds"})
+	if want := "La durée de validité doit être comprise entre 60 et 604800 secondes."; !ok || got != want {
+		t.Errorf("Message(fr, invalid_ttl) = %q, %v; want %q", got, ok, want)
+	}
+
+	// A message with placeholders is not rendered without values for them
+	if got, ok := Message("fr", "invalid_ttl", Params{}); ok {
+		t.Errorf("Message(fr, invalid_ttl) without params = %q, want none", got)
+	}
+	if got, ok := Message("fr", "not_found", Params{}); !ok || got == "" {
+		t.Errorf("Message(fr, not_found) = %q, %v; want a message", got, ok)
+	}
+	if got, ok := Message("fr", "no_such_code", Params{}); ok {
+		t.Errorf("Message(fr, no_such_code) = %q, want none", got)
+	}
+	if got, ok := Message("xx", "not_found", Params{}); ok {
+		t.Errorf("Message(xx, not_found) = %q, want none", got)
+	}
+}
+
+var placeholder = regexp.MustCompile(`\{[a-z]+\}`)
+
+func TestCatalogsFollowEnglish(t *testing.T) {
+	reference := catalogs[Default]
+	for _, locale := range Locales() {
+		c := catalogs[locale]
+		if got, want := slices.Sorted(maps.Keys(c.Messages)), slices.Sorted(maps.Keys(reference.Messages)); !slices.Equal(got, want) {
+			t.Errorf("%s messages cover %v, want the codes of %s: %v", locale, got, Default, want)
+		}
+		if got, want := slices.Sorted(maps.Keys(c.Units)), slices.Sorted(maps.Keys(reference.Units)); !slices.Equal(got, want) {
+			t.Errorf("%s units = %v, want %v", locale, got, want)
+		}
+		for code, message := range c.Messages {
+			got := slices.Sorted(slices.Values(placeholder.FindAllString(message, -1)))
+			want := slices.Sorted(slices.Values(placeholder.FindAllString(reference.Messages[code], -1)))
+			if !slices.Equal(got, want) {
+				t.Errorf("%s %s placeholders = %v, want %v", locale, code, got, want)
+			}
+		}
+	}
+}
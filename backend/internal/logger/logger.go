@@ -1,21 +1,101 @@
 package logger
 
 import (
+	"context"
+	"io"
 	"log/slog"
 	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ots-backend/internal/config"
+)
+
+// Log formats selectable with LOG_FORMAT
+const (
+	FormatJSON = "json"
+	// FormatText is colored key=value lines with source locations, for
+	// reading in a terminal; see prettyHandler
+	FormatText = "text"
 )
 
-var defaultLogger *slog.Logger
+// SampleHealth names the health probe lines, logged one in
+// LOG_SAMPLE_HEALTH
+const SampleHealth = "health"
+
+// sampleKey is the attribute that marks a line as sampled
+const sampleKey = "sample"
+
+var defaultLogger atomic.Pointer[slog.Logger]
+
+var (
+	settingsMu sync.Mutex
+	// output, format and rates are what the logger was last built from;
+	// until Setup runs that is JSON on stdout with nothing sampled
+	output io.Writer = os.Stdout
+	format           = FormatJSON
+	rates  map[string]int
+)
+
+// Setup builds the logger from cfg: LOG_FORMAT picks the format, falling
+// back to text in development and JSON anywhere else, and
+// LOG_SAMPLE_HEALTH sets how many health probe lines share one logged
+// line. Lines logged before Setup are JSON.
+func Setup(cfg *config.Config) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+
+	format = cfg.LogFormat
+	if format == "" {
+		format = FormatJSON
+		if cfg.Environment == "development" {
+			format = FormatText
+		}
+	}
+	rates = map[string]int{SampleHealth: cfg.LogSampleHealth}
+	build()
+}
+
+// SetOutput sends log lines to w at the LOG_LEVEL level, keeping the
+// format and sampling Setup chose. Tests use it to capture what the
+// server logs.
+func SetOutput(w io.Writer) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+
+	output = w
+	build()
+}
 
-func init() {
-	// Initialize structured JSON logger
+// build replaces the logger; settingsMu is held
+func build() {
 	opts := &slog.HandlerOptions{
 		Level: getLogLevel(),
 	}
 
-	handler := slog.NewJSONHandler(os.Stdout, opts)
-	defaultLogger = slog.New(handler)
-	slog.SetDefault(defaultLogger)
+	var handler slog.Handler
+	if format == FormatText {
+		handler = newPrettyHandler(output, opts)
+	} else {
+		handler = slog.NewJSONHandler(output, opts)
+	}
+	if len(rates) > 0 {
+		handler = newSampler(handler, rates)
+	}
+
+	log := slog.New(handler)
+	defaultLogger.Store(log)
+	slog.SetDefault(log)
+}
+
+func current() *slog.Logger {
+	if log := defaultLogger.Load(); log != nil {
+		return log
+	}
+	SetOutput(os.Stdout)
+	return defaultLogger.Load()
 }
 
 func getLogLevel() slog.Level {
@@ -34,27 +114,101 @@ func getLogLevel() slog.Level {
 	}
 }
 
+// Sampled marks a line as one of the named sample, of which only one in
+// the configured rate is logged. Warnings and errors are always logged.
+func Sampled(name string) slog.Attr {
+	return slog.String(sampleKey, name)
+}
+
+// sampler drops all but one in rate of the Info and Debug lines marked
+// with Sampled, keeping the first
+type sampler struct {
+	next   slog.Handler
+	rates  map[string]int
+	counts map[string]*atomic.Uint64
+}
+
+func newSampler(next slog.Handler, rates map[string]int) *sampler {
+	counts := make(map[string]*atomic.Uint64, len(rates))
+	for name := range rates {
+		counts[name] = new(atomic.Uint64)
+	}
+	return &sampler{next: next, rates: rates, counts: counts}
+}
+
+func (s *sampler) Enabled(ctx context.Context, level slog.Level) bool {
+	return s.next.Enabled(ctx, level)
+}
+
+func (s *sampler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelWarn {
+		return s.next.Handle(ctx, r)
+	}
+
+	var name string
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == sampleKey {
+			name = a.Value.String()
+			return false
+		}
+		return true
+	})
+	if rate := s.rates[name]; rate > 1 && (s.counts[name].Add(1)-1)%uint64(rate) != 0 {
+		return nil
+	}
+	return s.next.Handle(ctx, r)
+}
+
+func (s *sampler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &sampler{next: s.next.WithAttrs(attrs), rates: s.rates, counts: s.counts}
+}
+
+func (s *sampler) WithGroup(name string) slog.Handler {
+	return &sampler{next: s.next.WithGroup(name), rates: s.rates, counts: s.counts}
+}
+
+// log writes one line attributed to the caller of the exported function
+// that called it, so the text format points at the right source
+func log(level slog.Level, msg string, args ...any) {
+	l := current()
+	ctx := context.Background()
+	if !l.Enabled(ctx, level) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	r.Add(args...)
+	_ = l.Handler().Handle(ctx, r)
+}
+
 // Debug logs a debug message
 func Debug(msg string, args ...any) {
-	defaultLogger.Debug(msg, args...)
+	log(slog.LevelDebug, msg, args...)
+}
+
+// DebugEnabled reports whether debug messages are written, so work done
+// only to log one can be skipped
+func DebugEnabled() bool {
+	return current().Enabled(context.Background(), slog.LevelDebug)
 }
 
 // Info logs an info message
 func Info(msg string, args ...any) {
-	defaultLogger.Info(msg, args...)
+	log(slog.LevelInfo, msg, args...)
 }
 
 // Warn logs a warning message
 func Warn(msg string, args ...any) {
-	defaultLogger.Warn(msg, args...)
+	log(slog.LevelWarn, msg, args...)
 }
 
 // Error logs an error message
 func Error(msg string, args ...any) {
-	defaultLogger.Error(msg, args...)
+	log(slog.LevelError, msg, args...)
 }
 
 // With creates a logger with additional context
 func With(args ...any) *slog.Logger {
-	return defaultLogger.With(args...)
+	return current().With(args...)
 }
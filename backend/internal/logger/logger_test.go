@@ -0,0 +1,102 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"ots-backend/internal/config"
+)
+
+// capture sets the logger up from cfg, writing into a buffer until the
+// test ends
+func capture(t *testing.T, cfg *config.Config) *bytes.Buffer {
+	t.Helper()
+	var logs bytes.Buffer
+	SetOutput(&logs)
+	Setup(cfg)
+	t.Cleanup(func() {
+		Setup(&config.Config{Environment: "production"})
+		SetOutput(os.Stdout)
+	})
+	return &logs
+}
+
+func TestSetupPicksFormat(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		environment string
+		format      string
+		wantJSON    bool
+	}{
+		{name: "development", environment: "development", wantJSON: false},
+		{name: "production", environment: "production", wantJSON: true},
+		{name: "json in development", environment: "development", format: FormatJSON, wantJSON: true},
+		{name: "text in production", environment: "production", format: FormatText, wantJSON: false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := capture(t, &config.Config{Environment: tt.environment, LogFormat: tt.format})
+
+			Info("secret created", "size", 16)
+			line := logs.String()
+
+			var fields map[string]any
+			isJSON := json.Unmarshal([]byte(line), &fields) == nil
+			if isJSON != tt.wantJSON {
+				t.Fatalf("line %q is JSON = %v, want %v", line, isJSON, tt.wantJSON)
+			}
+			if isJSON {
+				if fields["msg"] != "secret created" || fields["size"] != float64(16) {
+					t.Errorf("JSON line = %v, want the message and its size", fields)
+				}
+				return
+			}
+			for _, want := range []string{"\033[32mINFO\033[0m secret created size=16", "logger/logger_test.go:"} {
+				if !strings.Contains(line, want) {
+					t.Errorf("text line %q does not contain %q", line, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSampledLinesAreDropped(t *testing.T) {
+	logs := capture(t, &config.Config{Environment: "production", LogSampleHealth: 3})
+
+	for range 7 {
+		Info("health check", Sampled(SampleHealth))
+	}
+	Info("secret created")
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	var health int
+	for _, line := range lines {
+		if strings.Contains(line, `"msg":"health check"`) {
+			health++
+		}
+	}
+	if health != 3 {
+		t.Errorf("logged %d of 7 health lines at one in 3, want 3 (the 1st, 4th and 7th)", health)
+	}
+	if !strings.Contains(logs.String(), `"msg":"secret created"`) {
+		t.Error("an unsampled line was dropped")
+	}
+}
+
+func TestSampledWarningsAndErrorsAreKept(t *testing.T) {
+	logs := capture(t, &config.Config{Environment: "production", LogSampleHealth: 100})
+
+	for range 5 {
+		Warn("health check failed", Sampled(SampleHealth))
+		Error("health check errored", Sampled(SampleHealth))
+	}
+
+	if n := strings.Count(logs.String(), `"msg":"health check failed"`); n != 5 {
+		t.Errorf("logged %d of 5 sampled warnings, want all", n)
+	}
+	if n := strings.Count(logs.String(), `"msg":"health check errored"`); n != 5 {
+		t.Errorf("logged %d of 5 sampled errors, want all", n)
+	}
+}
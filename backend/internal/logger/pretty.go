@@ -0,0 +1,109 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// levelColors are the ANSI colors of each level in the text format
+var levelColors = map[slog.Level]string{
+	slog.LevelDebug: "\033[90m",
+	slog.LevelInfo:  "\033[32m",
+	slog.LevelWarn:  "\033[33m",
+	slog.LevelError: "\033[31m",
+}
+
+const (
+	colorDim   = "\033[2m"
+	colorReset = "\033[0m"
+)
+
+// prettyHandler writes the text format: time, colored level, message,
+// key=value attributes and the caller's file and line, dimmed. Attributes
+// are rendered by a slog.TextHandler with the other parts removed, so
+// quoting and groups work as they do there.
+type prettyHandler struct {
+	w     io.Writer
+	mu    *sync.Mutex
+	buf   *bytes.Buffer
+	attrs slog.Handler
+}
+
+func newPrettyHandler(w io.Writer, opts *slog.HandlerOptions) *prettyHandler {
+	buf := new(bytes.Buffer)
+	return &prettyHandler{
+		w:   w,
+		mu:  new(sync.Mutex),
+		buf: buf,
+		attrs: slog.NewTextHandler(buf, &slog.HandlerOptions{
+			Level: opts.Level,
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if len(groups) == 0 && (a.Key == slog.LevelKey || a.Key == slog.MessageKey) {
+					return slog.Attr{}
+				}
+				return a
+			},
+		}),
+	}
+}
+
+func (h *prettyHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.attrs.Enabled(ctx, level)
+}
+
+func (h *prettyHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// A zero time is left out by the text handler, leaving only attributes
+	h.buf.Reset()
+	attrs := slog.NewRecord(time.Time{}, r.Level, "", 0)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs.AddAttrs(a)
+		return true
+	})
+	if err := h.attrs.Handle(ctx, attrs); err != nil {
+		return err
+	}
+
+	var line strings.Builder
+	line.WriteString(colorDim + r.Time.Format("15:04:05.000") + colorReset + " ")
+	line.WriteString(levelColors[r.Level] + r.Level.String() + colorReset + " ")
+	line.WriteString(r.Message)
+	if rendered := strings.TrimSpace(h.buf.String()); rendered != "" {
+		line.WriteString(" " + rendered)
+	}
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		line.WriteString(" " + colorDim + shortFile(frame.File) + ":" + strconv.Itoa(frame.Line) + colorReset)
+	}
+	line.WriteString("\n")
+
+	_, err := io.WriteString(h.w, line.String())
+	return err
+}
+
+func (h *prettyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &prettyHandler{w: h.w, mu: h.mu, buf: h.buf, attrs: h.attrs.WithAttrs(attrs)}
+}
+
+func (h *prettyHandler) WithGroup(name string) slog.Handler {
+	return &prettyHandler{w: h.w, mu: h.mu, buf: h.buf, attrs: h.attrs.WithGroup(name)}
+}
+
+// shortFile trims a source path to its directory and file name
+func shortFile(file string) string {
+	if i := strings.LastIndexByte(file, '/'); i >= 0 {
+		if j := strings.LastIndexByte(file[:i], '/'); j >= 0 {
+			return file[j+1:]
+		}
+	}
+	return file
+}
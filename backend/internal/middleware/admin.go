@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"ots-backend/internal/models"
+)
+
+// AdminAuth protects admin routes with a static bearer token. When token is
+// empty the admin surface is disabled and every request gets a 404.
+func AdminAuth(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				http.NotFound(w, r)
+				return
+			}
+
+			provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(models.ErrorResponse{
+					Error:   http.StatusText(http.StatusUnauthorized),
+					Message: "admin token required",
+					Code:    "unauthorized",
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+)
+
+// IPResolver extracts the client IP from a request. Forwarding headers are
+// only honored when the direct peer is a trusted proxy.
+type IPResolver struct {
+	trusted []netip.Prefix
+}
+
+var defaultResolver atomic.Pointer[IPResolver]
+
+func init() {
+	defaultResolver.Store(NewIPResolver(nil))
+}
+
+// NewIPResolver creates a resolver that trusts the given proxy ranges
+func NewIPResolver(trusted []netip.Prefix) *IPResolver {
+	return &IPResolver{trusted: trusted}
+}
+
+// ParseTrustedProxies parses CIDRs or bare IPs into prefixes
+func ParseTrustedProxies(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		if strings.Contains(value, "/") {
+			prefix, err := netip.ParsePrefix(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+		}
+		addr = addr.Unmap().WithZone("")
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+
+	return prefixes, nil
+}
+
+// SetTrustedProxies configures the resolver used by ClientIP
+func SetTrustedProxies(trusted []netip.Prefix) {
+	defaultResolver.Store(NewIPResolver(trusted))
+}
+
+// ClientIP returns the client IP for r using the configured trusted proxies
+func ClientIP(r *http.Request) string {
+	return defaultResolver.Load().ClientIP(r)
+}
+
+// RealIP rewrites r.RemoteAddr to the resolved client IP so downstream
+// handlers and the request logger see the same value the rate limiter uses.
+// The direct peer is kept on the context for RequestScheme and RequestHost.
+func RealIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = withPeer(r)
+		r.RemoteAddr = ClientIP(r)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ClientIP resolves the client IP. When the peer is trusted, X-Forwarded-For
+// is walked from the right, skipping trusted hops; the first untrusted hop is
+// the client, since everything left of it may have been forged.
+func (res *IPResolver) ClientIP(r *http.Request) string {
+	peer, ok := parseIP(r.RemoteAddr)
+	if !ok {
+		return strings.TrimSpace(r.RemoteAddr)
+	}
+
+	if !res.isTrusted(peer) {
+		return peer.String()
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		client := peer
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop, ok := parseIP(hops[i])
+			if !ok {
+				break
+			}
+
+			client = hop
+			if !res.isTrusted(hop) {
+				break
+			}
+		}
+		return client.String()
+	}
+
+	if realIP, ok := parseIP(r.Header.Get("X-Real-Ip")); ok {
+		return realIP.String()
+	}
+
+	return peer.String()
+}
+
+func (res *IPResolver) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range res.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIP accepts "ip", "ip:port", "[ipv6]:port", and "[ipv6]" forms
+func parseIP(value string) (netip.Addr, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return netip.Addr{}, false
+	}
+
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+
+	return addr.Unmap().WithZone(""), true
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIPResolverClientIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "fd00::/8", "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies() error: %v", err)
+	}
+
+	resolver := NewIPResolver(trusted)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		realIP     string
+		want       string
+	}{
+		{name: "ipv4 strips port", remoteAddr: "203.0.113.7:51234", want: "203.0.113.7"},
+		{name: "ipv6 strips port", remoteAddr: "[2001:db8::1]:443", want: "2001:db8::1"},
+		{name: "ipv6 different ports share key", remoteAddr: "[2001:db8::1]:9999", want: "2001:db8::1"},
+		{name: "ipv4-mapped ipv6 is unmapped", remoteAddr: "[::ffff:203.0.113.7]:80", want: "203.0.113.7"},
+		{name: "bare address without port", remoteAddr: "203.0.113.7", want: "203.0.113.7"},
+		{name: "untrusted peer ignores xff", remoteAddr: "203.0.113.7:1", xff: []string{"1.2.3.4"}, want: "203.0.113.7"},
+		{name: "untrusted peer ignores x-real-ip", remoteAddr: "203.0.113.7:1", realIP: "1.2.3.4", want: "203.0.113.7"},
+		{name: "trusted peer single hop", remoteAddr: "10.0.0.5:1", xff: []string{"198.51.100.9"}, want: "198.51.100.9"},
+		{name: "trusted peer multiple hops", remoteAddr: "10.0.0.5:1", xff: []string{"198.51.100.9, 10.1.1.1, 10.2.2.2"}, want: "198.51.100.9"},
+		{name: "spoofed left-most entry is skipped", remoteAddr: "10.0.0.5:1", xff: []string{"6.6.6.6, 198.51.100.9"}, want: "198.51.100.9"},
+		{name: "multiple xff headers are joined", remoteAddr: "10.0.0.5:1", xff: []string{"6.6.6.6", "198.51.100.9, 10.1.1.1"}, want: "198.51.100.9"},
+		{name: "trusted ipv6 proxy with ipv6 client", remoteAddr: "[fd00::1]:443", xff: []string{"2001:db8::42"}, want: "2001:db8::42"},
+		{name: "exact trusted ip", remoteAddr: "192.0.2.1:1", xff: []string{"198.51.100.9"}, want: "198.51.100.9"},
+		{name: "all hops trusted returns left-most", remoteAddr: "10.0.0.5:1", xff: []string{"10.9.9.9, 10.1.1.1"}, want: "10.9.9.9"},
+		{name: "garbage hop stops the walk", remoteAddr: "10.0.0.5:1", xff: []string{"not-an-ip, 10.1.1.1"}, want: "10.1.1.1"},
+		{name: "trusted peer falls back to x-real-ip", remoteAddr: "10.0.0.5:1", realIP: "198.51.100.9", want: "198.51.100.9"},
+		{name: "trusted peer with no headers", remoteAddr: "10.0.0.5:1", want: "10.0.0.5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.xff {
+				request.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.realIP != "" {
+				request.Header.Set("X-Real-Ip", tt.realIP)
+			}
+
+			if got := resolver.ClientIP(request); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxiesRejectsInvalid(t *testing.T) {
+	for _, value := range []string{"10.0.0.0/33", "not-an-ip", "10.0.0"} {
+		if _, err := ParseTrustedProxies([]string{value}); err == nil {
+			t.Errorf("ParseTrustedProxies(%q) error = nil, want error", value)
+		}
+	}
+}
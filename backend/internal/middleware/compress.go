@@ -0,0 +1,220 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// gzipWriters reuses compressors, which are costly to allocate
+var gzipWriters = sync.Pool{
+	New: func() any { return gzip.NewWriter(nil) },
+}
+
+// Compress gzips JSON responses of at least minSize() bytes for clients that
+// accept gzip. minSize is read once per request, so a reload applies from
+// the next one; zero or less turns compression off. Smaller responses,
+// other content types, HEAD and responses that already carry a
+// Content-Encoding or an ETag are sent as they are. A handler can opt a
+// response out with SkipCompression.
+func Compress(minSize func() int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			size := minSize()
+			if size <= 0 || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// The body depends on Accept-Encoding even when this client
+			// gets it uncompressed, so caches must key on it
+			w.Header().Add("Vary", "Accept-Encoding")
+			if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, minSize: size}
+			defer cw.close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// SkipCompression sends the response w writes uncompressed. It must be
+// called before the response is written; without Compress above it, it
+// does nothing.
+func SkipCompression(w http.ResponseWriter) {
+	for {
+		switch v := w.(type) {
+		case *compressWriter:
+			v.skip = true
+			return
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = v.Unwrap()
+		default:
+			return
+		}
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding value allows gzip, by
+// name or through "*", with a non-zero quality
+func acceptsGzip(header string) bool {
+	wildcard := false
+	for _, item := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(item, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		accepted := qualityAbove0(params)
+		if coding == "gzip" {
+			return accepted
+		}
+		wildcard = accepted
+	}
+	return wildcard
+}
+
+// qualityAbove0 reports whether the parameters of an Accept-Encoding item
+// leave its q above zero; a missing or unreadable q counts as 1
+func qualityAbove0(params string) bool {
+	for _, param := range strings.Split(params, ";") {
+		name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(name), "q") {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return err != nil || q > 0
+	}
+	return true
+}
+
+// compressible reports whether a response with header h may be gzipped:
+// JSON that is not already encoded and has no ETag, which would have to
+// name the compressed bytes instead
+func compressible(h http.Header) bool {
+	if h.Get("Content-Encoding") != "" || h.Get("ETag") != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// compressWriter holds back the status and the first minSize bytes of a
+// response until it knows whether the response is large enough to gzip
+type compressWriter struct {
+	http.ResponseWriter
+	minSize int
+	// skip is set by SkipCompression
+	skip bool
+
+	status  int
+	buf     []byte
+	decided bool
+	gz      *gzip.Writer
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.status != 0 || w.decided {
+		return
+	}
+	// Informational responses go out as they come
+	if code < http.StatusOK {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+	if code == http.StatusNoContent || code == http.StatusNotModified {
+		w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.decided {
+		if w.gz != nil {
+			return w.gz.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.minSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush sends what is buffered, compressed only if it already reached
+// minSize, and flushes the connection
+func (w *compressWriter) Flush() {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.decided {
+		w.decide(len(w.buf) >= w.minSize)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// decide writes the held status, choosing gzip when large is set and the
+// response qualifies, then the buffered body
+func (w *compressWriter) decide(large bool) error {
+	w.decided = true
+	header := w.ResponseWriter.Header()
+	if large && !w.skip && compressible(header) {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", "gzip")
+		w.gz = gzipWriters.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.gz != nil {
+		_, err := w.gz.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// close sends a response that stayed under minSize as it is and ends a
+// compressed one
+func (w *compressWriter) close() {
+	if w.status == 0 {
+		return
+	}
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.gz != nil {
+		w.gz.Close()
+		gzipWriters.Put(w.gz)
+		w.gz = nil
+	}
+}
@@ -0,0 +1,185 @@
+package middleware_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	httpMiddleware "ots-backend/internal/middleware"
+)
+
+// compressTestBody is JSON well over compressTestMinSize
+var compressTestBody = `{"ciphertext":"` + strings.Repeat("QUJDRA", 200) + `"}`
+
+const compressTestMinSize = 256
+
+// serveCompressed answers one request with body and contentType through
+// Compress; skip opts the response out first
+func serveCompressed(t *testing.T, acceptEncoding, contentType, body string, skip bool) *httptest.ResponseRecorder {
+	t.Helper()
+	handler := httpMiddleware.Compress(func() int { return compressTestMinSize })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if skip {
+			httpMiddleware.SkipCompression(w)
+		}
+		w.Header().Set("Content-Type", contentType)
+		// Written in pieces, so the threshold is crossed mid-response
+		for rest := body; rest != ""; {
+			n := min(100, len(rest))
+			io.WriteString(w, rest[:n])
+			rest = rest[n:]
+		}
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// decodeBody returns the response body, gunzipped if it is encoded
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		return rec.Body.String()
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("gzip.NewReader() error: %v", err)
+	}
+	plain, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("read gzip body: %v", err)
+	}
+	return string(plain)
+}
+
+func TestCompressNegotiatesGzip(t *testing.T) {
+	for _, tt := range []struct {
+		acceptEncoding string
+		compressed     bool
+	}{
+		{"gzip", true},
+		{"br, gzip;q=0.5", true},
+		{"*", true},
+		{"GZIP;Q=1.0", true},
+		{"", false},
+		{"identity", false},
+		{"br", false},
+		{"gzip;q=0", false},
+		{"*, gzip;q=0", false},
+		{"gzip;q=0.000, *", false},
+	} {
+		rec := serveCompressed(t, tt.acceptEncoding, "application/json", compressTestBody, false)
+		if got := rec.Header().Get("Content-Encoding") == "gzip"; got != tt.compressed {
+			t.Errorf("Accept-Encoding %q: compressed = %v, want %v", tt.acceptEncoding, got, tt.compressed)
+		}
+		if got := rec.Header().Values("Vary"); len(got) != 1 || got[0] != "Accept-Encoding" {
+			t.Errorf("Accept-Encoding %q: Vary = %v, want [Accept-Encoding]", tt.acceptEncoding, got)
+		}
+		if got := decodeBody(t, rec); got != compressTestBody {
+			t.Errorf("Accept-Encoding %q: body does not round-trip (%d bytes, want %d)", tt.acceptEncoding, len(got), len(compressTestBody))
+		}
+		if tt.compressed && rec.Body.Len() >= len(compressTestBody) {
+			t.Errorf("Accept-Encoding %q: compressed body is %d bytes, plain %d", tt.acceptEncoding, rec.Body.Len(), len(compressTestBody))
+		}
+	}
+}
+
+func TestCompressLeavesOtherResponsesAlone(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		contentType string
+		body        string
+		skip        bool
+	}{
+		{"below threshold", "application/json", `{"id":"QUJD"}`, false},
+		{"not json", "text/plain; charset=utf-8", compressTestBody, false},
+		{"skipped", "application/json", compressTestBody, true},
+	} {
+		rec := serveCompressed(t, "gzip", tt.contentType, tt.body, tt.skip)
+		if got := rec.Header().Get("Content-Encoding"); got != "" {
+			t.Errorf("%s: Content-Encoding = %q, want none", tt.name, got)
+		}
+		if rec.Body.String() != tt.body {
+			t.Errorf("%s: body = %q, want it unchanged", tt.name, rec.Body.String())
+		}
+	}
+}
+
+func TestCompressKeepsStatusAndValidators(t *testing.T) {
+	handler := httpMiddleware.Compress(func() int { return 1 })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/etag":
+			w.Header().Set("ETag", `"abc"`)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		io.WriteString(w, `{"error":"not found"}`)
+	}))
+
+	for _, tt := range []struct {
+		path       string
+		status     int
+		compressed bool
+	}{
+		{"/missing", http.StatusNotFound, true},
+		{"/etag", http.StatusOK, false},
+		{"/empty", http.StatusNoContent, false},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.path, rec.Code, tt.status)
+		}
+		if got := rec.Header().Get("Content-Encoding") == "gzip"; got != tt.compressed {
+			t.Errorf("%s: compressed = %v, want %v", tt.path, got, tt.compressed)
+		}
+	}
+}
+
+func TestCompressOffWithoutMinSize(t *testing.T) {
+	handler := httpMiddleware.Compress(func() int { return 0 })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, compressTestBody)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Encoding") != "" || rec.Header().Get("Vary") != "" || rec.Body.String() != compressTestBody {
+		t.Errorf("with compression off: headers %v, %d bytes; want the body untouched", rec.Header(), rec.Body.Len())
+	}
+}
+
+func TestCompressFlushesStreams(t *testing.T) {
+	handler := httpMiddleware.Compress(func() int { return compressTestMinSize })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"a":1}`)
+		http.NewResponseController(w).Flush()
+		io.WriteString(w, `{"b":2}`)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	// Flushed below the threshold, the response is sent as it is
+	if !rec.Flushed || rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != `{"a":1}{"b":2}` {
+		t.Errorf("flushed = %v, encoding %q, body %q; want a flushed plain body", rec.Flushed, rec.Header().Get("Content-Encoding"), rec.Body.String())
+	}
+}
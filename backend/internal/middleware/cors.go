@@ -0,0 +1,233 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/cors"
+
+	"ots-backend/internal/httpx"
+	"ots-backend/internal/logger"
+	"ots-backend/internal/models"
+)
+
+// CORS outcome labels used in metrics
+const (
+	CORSOutcomeAllowed           = "allowed"
+	CORSOutcomeRejectedPreflight = "rejected_preflight"
+	CORSOutcomeRejectedActual    = "rejected_actual"
+)
+
+const (
+	corsLogInterval       = time.Minute
+	corsMaxTrackedOrigins = 256
+)
+
+// RejectedOrigin summarizes rejections for one origin
+type RejectedOrigin struct {
+	Origin   string    `json:"origin"`
+	Count    int64     `json:"count"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+type corsTracker struct {
+	mu       sync.Mutex
+	outcomes map[string]int64
+	origins  map[string]*RejectedOrigin
+	lastLog  map[string]time.Time
+}
+
+var corsStats = &corsTracker{
+	outcomes: make(map[string]int64),
+	origins:  make(map[string]*RejectedOrigin),
+	lastLog:  make(map[string]time.Time),
+}
+
+// CORS wraps the go-chi cors handler so that requests from origins outside
+// opts.AllowedOrigins are logged, counted, and, for non-preflight requests,
+// answered with a diagnosable JSON error instead of silently passing through.
+func CORS(opts cors.Options) func(http.Handler) http.Handler {
+	matcher := newOriginMatcher(opts.AllowedOrigins)
+	if !matcher.all {
+		// The cors handler echoes an allowed origin back, so it decides with
+		// the same matcher, which refuses origins that are not plain header
+		// values
+		opts.AllowOriginFunc = func(r *http.Request, origin string) bool {
+			return matcher.allowed(origin)
+		}
+	}
+	corsHandler := cors.Handler(opts)
+
+	return func(next http.Handler) http.Handler {
+		inner := corsHandler(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				inner.ServeHTTP(w, r)
+				return
+			}
+
+			if matcher.allowed(origin) {
+				corsStats.recordOutcome(CORSOutcomeAllowed)
+				inner.ServeHTTP(w, r)
+				return
+			}
+
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if preflight {
+				corsStats.recordRejection(CORSOutcomeRejectedPreflight, origin, r)
+				// The cors handler answers the preflight without allow headers
+				inner.ServeHTTP(w, r)
+				return
+			}
+
+			corsStats.recordRejection(CORSOutcomeRejectedActual, origin, r)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(models.ErrorResponse{
+				Error:   http.StatusText(http.StatusForbidden),
+				Message: "origin not allowed by CORS policy",
+				Code:    "cors_rejected",
+			})
+		})
+	}
+}
+
+// CORSOutcomes returns request counts by CORS outcome
+func CORSOutcomes() map[string]int64 {
+	corsStats.mu.Lock()
+	defer corsStats.mu.Unlock()
+
+	out := make(map[string]int64, len(corsStats.outcomes))
+	for k, v := range corsStats.outcomes {
+		out[k] = v
+	}
+	return out
+}
+
+// RejectedOrigins returns recently rejected origins, most frequent first
+func RejectedOrigins() []RejectedOrigin {
+	corsStats.mu.Lock()
+	defer corsStats.mu.Unlock()
+
+	out := make([]RejectedOrigin, 0, len(corsStats.origins))
+	for _, o := range corsStats.origins {
+		out = append(out, *o)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Origin < out[j].Origin
+	})
+	return out
+}
+
+func (t *corsTracker) recordOutcome(outcome string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.outcomes[outcome]++
+}
+
+func (t *corsTracker) recordRejection(outcome, origin string, r *http.Request) {
+	now := time.Now()
+
+	t.mu.Lock()
+	t.outcomes[outcome]++
+
+	entry, ok := t.origins[origin]
+	if !ok {
+		// Bound memory: a flood of random origins resets the table rather than growing it
+		if len(t.origins) >= corsMaxTrackedOrigins {
+			t.origins = make(map[string]*RejectedOrigin)
+			t.lastLog = make(map[string]time.Time)
+		}
+		entry = &RejectedOrigin{Origin: origin}
+		t.origins[origin] = entry
+	}
+	entry.Count++
+	entry.LastSeen = now
+
+	shouldLog := now.Sub(t.lastLog[origin]) >= corsLogInterval
+	if shouldLog {
+		t.lastLog[origin] = now
+	}
+	count := entry.Count
+	t.mu.Unlock()
+
+	if shouldLog {
+		logger.Warn("cors request rejected",
+			"origin", origin,
+			"outcome", outcome,
+			"method", r.Method,
+			"path", r.URL.Path,
+			"rejections", count,
+		)
+	}
+}
+
+// originMatcher mirrors the matching rules of github.com/go-chi/cors:
+// "*" allows everything, entries are case-insensitive, and a single "*"
+// inside an entry acts as a wildcard. Unlike the library it never allows an
+// origin holding control characters or non-ASCII, which a wildcard would
+// otherwise match and the allow header would echo.
+type originMatcher struct {
+	all       bool
+	exact     map[string]bool
+	wildcards [][2]string
+}
+
+func newOriginMatcher(origins []string) *originMatcher {
+	m := &originMatcher{exact: make(map[string]bool)}
+	if len(origins) == 0 {
+		m.all = true
+		return m
+	}
+
+	for _, o := range origins {
+		o = strings.ToLower(strings.TrimSpace(o))
+		if o == "*" {
+			m.all = true
+			return m
+		}
+
+		if prefix, suffix, ok := strings.Cut(o, "*"); ok {
+			m.wildcards = append(m.wildcards, [2]string{prefix, suffix})
+			continue
+		}
+
+		m.exact[o] = true
+	}
+
+	return m
+}
+
+func (m *originMatcher) allowed(origin string) bool {
+	if m.all {
+		return true
+	}
+
+	if !httpx.ValidHeaderValue(origin) || strings.ContainsAny(origin, " \t") {
+		return false
+	}
+
+	origin = strings.ToLower(origin)
+	if m.exact[origin] {
+		return true
+	}
+
+	for _, w := range m.wildcards {
+		if len(origin) >= len(w[0])+len(w[1]) && strings.HasPrefix(origin, w[0]) && strings.HasSuffix(origin, w[1]) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/cors"
+
+	"ots-backend/internal/models"
+)
+
+func newCORSTestHandler() http.Handler {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	return CORS(cors.Options{
+		AllowedOrigins: []string{"https://app.example.com", "https://*.preview.example.com"},
+		AllowedMethods: []string{"GET", "POST", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{"Content-Type"},
+	})(next)
+}
+
+func TestCORSAllowed(t *testing.T) {
+	handler := newCORSTestHandler()
+	before := CORSOutcomes()[CORSOutcomeAllowed]
+
+	for _, origin := range []string{"https://app.example.com", "https://pr-12.preview.example.com"} {
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/api/health", nil)
+		request.Header.Set("Origin", origin)
+		handler.ServeHTTP(response, request)
+
+		if response.Code != http.StatusOK {
+			t.Fatalf("origin %s status = %d, want %d", origin, response.Code, http.StatusOK)
+		}
+
+		if got := response.Header().Get("Access-Control-Allow-Origin"); got != origin {
+			t.Errorf("origin %s Access-Control-Allow-Origin = %q", origin, got)
+		}
+	}
+
+	if got := CORSOutcomes()[CORSOutcomeAllowed] - before; got != 2 {
+		t.Errorf("allowed counter delta = %d, want 2", got)
+	}
+}
+
+func TestCORSRejectedPreflight(t *testing.T) {
+	handler := newCORSTestHandler()
+	before := CORSOutcomes()[CORSOutcomeRejectedPreflight]
+
+	response := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodOptions, "/api/secrets", nil)
+	request.Header.Set("Origin", "https://evil.example.net")
+	request.Header.Set("Access-Control-Request-Method", "POST")
+	handler.ServeHTTP(response, request)
+
+	if got := response.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Fatalf("rejected preflight Access-Control-Allow-Origin = %q, want empty", got)
+	}
+
+	if got := CORSOutcomes()[CORSOutcomeRejectedPreflight] - before; got != 1 {
+		t.Errorf("rejected_preflight counter delta = %d, want 1", got)
+	}
+
+	found := false
+	for _, o := range RejectedOrigins() {
+		if o.Origin == "https://evil.example.net" && o.Count > 0 {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("RejectedOrigins() does not list the rejected origin")
+	}
+}
+
+func TestCORSRejectedActual(t *testing.T) {
+	handler := newCORSTestHandler()
+	before := CORSOutcomes()[CORSOutcomeRejectedActual]
+
+	response := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/api/secrets", nil)
+	request.Header.Set("Origin", "https://other.example.org")
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusForbidden {
+		t.Fatalf("rejected request status = %d, want %d", response.Code, http.StatusForbidden)
+	}
+
+	var body models.ErrorResponse
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		t.Fatalf("decode error body: %v", err)
+	}
+
+	if body.Code != "cors_rejected" {
+		t.Errorf("error code = %q, want %q", body.Code, "cors_rejected")
+	}
+
+	if got := CORSOutcomes()[CORSOutcomeRejectedActual] - before; got != 1 {
+		t.Errorf("rejected_actual counter delta = %d, want 1", got)
+	}
+}
+
+func TestCORSWithoutOriginPassesThrough(t *testing.T) {
+	handler := newCORSTestHandler()
+
+	response := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/api/secrets", nil)
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("same-origin request status = %d, want %d", response.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// peerKey holds the direct peer address saved by RealIP before it rewrites
+// r.RemoteAddr to the client
+type peerKey struct{}
+
+// withPeer records r's direct peer on its context
+func withPeer(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), peerKey{}, r.RemoteAddr))
+}
+
+// peer returns the address r arrived from, even after RealIP
+func peer(r *http.Request) string {
+	if addr, ok := r.Context().Value(peerKey{}).(string); ok {
+		return addr
+	}
+	return r.RemoteAddr
+}
+
+// RequestScheme returns the scheme the client used, honoring
+// X-Forwarded-Proto only from trusted proxies
+func RequestScheme(r *http.Request) string {
+	return defaultResolver.Load().Scheme(r)
+}
+
+// RequestHost returns the host the client addressed, honoring
+// X-Forwarded-Host only from trusted proxies
+func RequestHost(r *http.Request) string {
+	return defaultResolver.Load().Host(r)
+}
+
+// IsHTTPS reports whether the client's connection was HTTPS
+func IsHTTPS(r *http.Request) bool {
+	return RequestScheme(r) == "https"
+}
+
+// Scheme returns "https" or "http" for the client's side of the connection.
+// Behind a chain of proxies the left-most X-Forwarded-Proto entry is the
+// one the client used; an untrusted peer's header is ignored, so a direct
+// client cannot claim HTTPS.
+func (res *IPResolver) Scheme(r *http.Request) string {
+	if res.fromTrustedProxy(r) {
+		switch proto := strings.ToLower(firstForwarded(r, "X-Forwarded-Proto")); proto {
+		case "http", "https":
+			return proto
+		}
+	}
+
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// Host returns the host the client addressed; see Scheme for which
+// forwarded value is used
+func (res *IPResolver) Host(r *http.Request) string {
+	if res.fromTrustedProxy(r) {
+		if host := firstForwarded(r, "X-Forwarded-Host"); host != "" {
+			return host
+		}
+	}
+	return strings.TrimSpace(r.Host)
+}
+
+func (res *IPResolver) fromTrustedProxy(r *http.Request) bool {
+	addr, ok := parseIP(peer(r))
+	return ok && res.isTrusted(addr)
+}
+
+// firstForwarded returns the left-most entry of a comma-separated
+// forwarding header
+func firstForwarded(r *http.Request, name string) string {
+	value, _, _ := strings.Cut(r.Header.Get(name), ",")
+	return strings.TrimSpace(value)
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestForwardedSchemeAndHost(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies() error: %v", err)
+	}
+	SetTrustedProxies(trusted)
+	t.Cleanup(func() { SetTrustedProxies(nil) })
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		tls        bool
+		proto      string
+		host       string
+		wantScheme string
+		wantHost   string
+	}{
+		{name: "direct http", remoteAddr: "203.0.113.7:1", wantScheme: "http", wantHost: "example.com"},
+		{name: "direct https", remoteAddr: "203.0.113.7:1", tls: true, wantScheme: "https", wantHost: "example.com"},
+		{name: "proxied https", remoteAddr: "10.0.0.5:1", proto: "https", host: "ots.example.org", wantScheme: "https", wantHost: "ots.example.org"},
+		{name: "proxied http", remoteAddr: "10.0.0.5:1", proto: "http", wantScheme: "http", wantHost: "example.com"},
+		{name: "proxy chain uses left-most", remoteAddr: "10.0.0.5:1", proto: "https, http", host: "ots.example.org, internal:8080", wantScheme: "https", wantHost: "ots.example.org"},
+		{name: "proxied unknown proto", remoteAddr: "10.0.0.5:1", proto: "gopher", wantScheme: "http", wantHost: "example.com"},
+		{name: "proxy over tls without header", remoteAddr: "10.0.0.5:1", tls: true, wantScheme: "https", wantHost: "example.com"},
+		{name: "untrusted spoof", remoteAddr: "203.0.113.7:1", proto: "https", host: "evil.example", wantScheme: "http", wantHost: "example.com"},
+		{name: "untrusted spoof over tls", remoteAddr: "203.0.113.7:1", tls: true, proto: "http", wantScheme: "https", wantHost: "example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotScheme, gotHost string
+			handler := RealIP(SecurityHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotScheme, gotHost = RequestScheme(r), RequestHost(r)
+			})))
+
+			request := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			request.RemoteAddr = tt.remoteAddr
+			request.Header.Set("X-Forwarded-For", "198.51.100.9")
+			if tt.tls {
+				request.TLS = &tls.ConnectionState{}
+			}
+			if tt.proto != "" {
+				request.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			if tt.host != "" {
+				request.Header.Set("X-Forwarded-Host", tt.host)
+			}
+
+			response := httptest.NewRecorder()
+			handler.ServeHTTP(response, request)
+
+			if gotScheme != tt.wantScheme || gotHost != tt.wantHost {
+				t.Errorf("RequestScheme, RequestHost = %q, %q; want %q, %q", gotScheme, gotHost, tt.wantScheme, tt.wantHost)
+			}
+
+			hsts := response.Header().Get("Strict-Transport-Security")
+			if wantHSTS := tt.wantScheme == "https"; (hsts != "") != wantHSTS {
+				t.Errorf("Strict-Transport-Security = %q, want sent = %v", hsts, wantHSTS)
+			}
+		})
+	}
+}
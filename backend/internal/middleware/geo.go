@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"ots-backend/internal/geo"
+	"ots-backend/internal/logger"
+	"ots-backend/internal/models"
+)
+
+// geoLogInterval spaces out lookup failure warnings, which a damaged
+// database would otherwise raise on every request
+const geoLogInterval = time.Minute
+
+// GeoPolicy is the client countries GeoBlock lets through, as upper-case
+// ISO 3166-1 alpha-2 codes. Deny wins over Allow; an empty Allow lets
+// through every country Deny does not name.
+type GeoPolicy struct {
+	Allow []string
+	Deny  []string
+}
+
+// Active reports whether the policy refuses any country
+func (p GeoPolicy) Active() bool {
+	return len(p.Allow) > 0 || len(p.Deny) > 0
+}
+
+func (p GeoPolicy) permits(country string) bool {
+	if slices.Contains(p.Deny, country) {
+		return false
+	}
+	return len(p.Allow) == 0 || slices.Contains(p.Allow, country)
+}
+
+// GeoBlock refuses requests from client countries the policy does not let
+// through with a 403 that does not say why. It fails open: with a nil
+// resolver, a lookup error or a client whose country is unknown the
+// request goes through, and lookup errors are logged at Warn. Health
+// endpoints are never refused, so probes keep working from anywhere.
+func GeoBlock(resolver geo.Resolver, policy GeoPolicy) func(http.Handler) http.Handler {
+	var lastWarn atomic.Int64
+
+	return func(next http.Handler) http.Handler {
+		if resolver == nil || !policy.Active() {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isHealthPath(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			addr, err := netip.ParseAddr(ClientIP(r))
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			country, err := resolver.Resolve(addr.Unmap().WithZone(""))
+			if err != nil {
+				now := time.Now().UnixNano()
+				if last := lastWarn.Load(); now-last >= int64(geoLogInterval) && lastWarn.CompareAndSwap(last, now) {
+					logger.Warn("geo lookup failed; request allowed", "error", err)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			country = geo.Coarse(country)
+			if country == "" || policy.permits(country) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			logger.Info("request refused by geo policy",
+				"country", country,
+				"method", r.Method,
+				"path", r.URL.Path,
+			)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(models.ErrorResponse{
+				Error:   http.StatusText(http.StatusForbidden),
+				Message: "access denied",
+				Code:    "forbidden",
+			})
+		})
+	}
+}
+
+// isHealthPath reports whether path is a health endpoint, at the root or
+// under /api
+func isHealthPath(path string) bool {
+	path = strings.TrimPrefix(path, "/api")
+	return path == "/health" || strings.HasPrefix(path, "/health/")
+}
@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"ots-backend/internal/geo"
+	"ots-backend/internal/models"
+)
+
+// stubResolver answers from a fixed table, or fails every lookup with err
+type stubResolver struct {
+	countries map[string]string
+	err       error
+	lookups   int
+}
+
+func (s *stubResolver) Resolve(addr netip.Addr) (string, error) {
+	s.lookups++
+	if s.err != nil {
+		return "", s.err
+	}
+	return s.countries[addr.String()], nil
+}
+
+var geoCountries = map[string]string{
+	"203.0.113.9":  "NL",
+	"198.51.100.7": "us",
+	"2001:db8::1":  "DE",
+}
+
+func geoRequest(t *testing.T, resolver geo.Resolver, policy GeoPolicy, remoteAddr, path string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	handler := GeoBlock(resolver, policy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	request.RemoteAddr = remoteAddr
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	return response
+}
+
+func TestGeoBlockAllowAndDeny(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy GeoPolicy
+		addr   string
+		want   int
+	}{
+		{name: "allowlisted", policy: GeoPolicy{Allow: []string{"NL"}}, addr: "203.0.113.9:1234", want: http.StatusNoContent},
+		{name: "not allowlisted", policy: GeoPolicy{Allow: []string{"NL"}}, addr: "[2001:db8::1]:1234", want: http.StatusForbidden},
+		{name: "denied", policy: GeoPolicy{Deny: []string{"US"}}, addr: "198.51.100.7:1234", want: http.StatusForbidden},
+		{name: "not denied", policy: GeoPolicy{Deny: []string{"US"}}, addr: "203.0.113.9:1234", want: http.StatusNoContent},
+		{name: "deny wins", policy: GeoPolicy{Allow: []string{"US"}, Deny: []string{"US"}}, addr: "198.51.100.7:1234", want: http.StatusForbidden},
+		{name: "unknown country", policy: GeoPolicy{Allow: []string{"NL"}}, addr: "192.0.2.1:1234", want: http.StatusNoContent},
+		{name: "mapped address", policy: GeoPolicy{Deny: []string{"NL"}}, addr: "[::ffff:203.0.113.9]:1234", want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := geoRequest(t, &stubResolver{countries: geoCountries}, tt.policy, tt.addr, "/api/secrets")
+			if response.Code != tt.want {
+				t.Fatalf("status = %d, want %d", response.Code, tt.want)
+			}
+			if tt.want != http.StatusForbidden {
+				return
+			}
+			var body models.ErrorResponse
+			if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+				t.Fatalf("decode error response: %v", err)
+			}
+			if body.Code != "forbidden" || body.Message != "access denied" {
+				t.Errorf("error = %+v, want a generic forbidden", body)
+			}
+		})
+	}
+}
+
+func TestGeoBlockFailsOpen(t *testing.T) {
+	policy := GeoPolicy{Allow: []string{"NL"}}
+
+	broken := &stubResolver{err: errors.New("database is damaged")}
+	for range 2 {
+		if response := geoRequest(t, broken, policy, "[2001:db8::1]:1234", "/api/secrets"); response.Code != http.StatusNoContent {
+			t.Errorf("status with a failing lookup = %d, want %d", response.Code, http.StatusNoContent)
+		}
+	}
+	if broken.lookups != 2 {
+		t.Errorf("lookups = %d, want 2", broken.lookups)
+	}
+
+	if response := geoRequest(t, nil, policy, "[2001:db8::1]:1234", "/api/secrets"); response.Code != http.StatusNoContent {
+		t.Errorf("status without a database = %d, want %d", response.Code, http.StatusNoContent)
+	}
+}
+
+func TestGeoBlockSkipsHealth(t *testing.T) {
+	resolver := &stubResolver{countries: geoCountries}
+	policy := GeoPolicy{Deny: []string{"NL"}}
+
+	for _, path := range []string{"/health", "/api/health", "/api/health/ready", "/api/health/deep"} {
+		if response := geoRequest(t, resolver, policy, "203.0.113.9:1234", path); response.Code != http.StatusNoContent {
+			t.Errorf("GET %s status = %d, want %d", path, response.Code, http.StatusNoContent)
+		}
+	}
+	if resolver.lookups != 0 {
+		t.Errorf("health requests made %d lookups, want 0", resolver.lookups)
+	}
+	if response := geoRequest(t, resolver, policy, "203.0.113.9:1234", "/api/healthy"); response.Code != http.StatusForbidden {
+		t.Errorf("GET /api/healthy status = %d, want %d", response.Code, http.StatusForbidden)
+	}
+}
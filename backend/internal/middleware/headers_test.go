@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNoStoreHeaders(t *testing.T) {
+	for _, status := range []int{http.StatusCreated, http.StatusOK, http.StatusNotFound, http.StatusTooManyRequests} {
+		handler := NoStore(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/secrets/abc", nil))
+
+		assertNoStore(t, response.Header())
+	}
+}
+
+func TestSecurityHeadersSingleValues(t *testing.T) {
+	handler := SecurityHeaders(SecurityHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	for _, name := range []string{"X-Frame-Options", "X-Content-Type-Options", "Referrer-Policy", "X-XSS-Protection"} {
+		if values := response.Header().Values(name); len(values) != 1 {
+			t.Errorf("%s values = %q, want exactly one", name, values)
+		}
+	}
+
+	if got := response.Header().Get("X-XSS-Protection"); got != "0" {
+		t.Errorf("X-XSS-Protection = %q, want %q", got, "0")
+	}
+	if got := response.Header().Get(PolicyRevHeader); got != PolicyRev() || len(got) != 16 {
+		t.Errorf("%s = %q, want the 16-character policy hash %q", PolicyRevHeader, got, PolicyRev())
+	}
+}
+
+func TestDefaultCSP(t *testing.T) {
+	for _, source := range []string{"'unsafe-eval'", "'unsafe-inline'", "*"} {
+		if strings.Contains(DefaultCSP, source) {
+			t.Errorf("DefaultCSP = %q, allows %s", DefaultCSP, source)
+		}
+	}
+}
+
+func TestSetSecurityPolicy(t *testing.T) {
+	t.Cleanup(func() { SetSecurityPolicy("", true) })
+
+	SetSecurityPolicy("", true)
+	if got := SecurityPolicy()[0]; got.Value != DefaultCSP {
+		t.Errorf("empty CSP policy = %q, want DefaultCSP", got.Value)
+	}
+	defaultRev := PolicyRev()
+
+	SetSecurityPolicy("default-src 'self'", false)
+	for _, h := range SecurityPolicy() {
+		if h.Name == "Strict-Transport-Security" {
+			t.Error("SecurityPolicy() includes HSTS with it disabled")
+		}
+	}
+	if PolicyRev() == defaultRev {
+		t.Error("PolicyRev() did not change with the policy")
+	}
+
+	handler := SecurityHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.TLS = &tls.ConnectionState{}
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if got := response.Header().Get("Content-Security-Policy"); got != "default-src 'self'" {
+		t.Errorf("Content-Security-Policy = %q, want the configured policy", got)
+	}
+	if got := response.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("Strict-Transport-Security = %q over HTTPS with HSTS disabled, want none", got)
+	}
+}
+
+func assertNoStore(t *testing.T, header http.Header) {
+	t.Helper()
+
+	want := map[string]string{
+		"Cache-Control": "no-store, no-cache",
+		"Pragma":        "no-cache",
+		"Expires":       "0",
+	}
+	for name, value := range want {
+		if got := header.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+
+	"ots-backend/internal/clock"
+)
+
+// missSlots is how many buckets the miss window is split into
+const missSlots = 10
+
+// MissVerdict is what to do with a failed lookup
+type MissVerdict int
+
+// Miss verdicts, from least to most severe
+const (
+	MissAllow MissVerdict = iota
+	MissDelay
+	MissReject
+)
+
+// MissLimiterConfig sets the thresholds of a MissLimiter. A zero threshold
+// disables that stage.
+type MissLimiterConfig struct {
+	Window time.Duration
+	// DelayAfter is the number of misses per window after which failed
+	// lookups are answered slowly
+	DelayAfter int
+	// RejectAfter is the number of misses per window after which failed
+	// lookups are refused with 429
+	RejectAfter int
+}
+
+// MissLimiter counts failed secret lookups across all clients. Per-IP limits
+// do not stop a distributed ID enumeration, but a flood of misses does stand
+// out service-wide; once it crosses the thresholds only further misses are
+// slowed down or refused, so successful reads are never penalized. Slots
+// are laid out on the monotonic clock, so wall clock steps leave the window
+// alone.
+type MissLimiter struct {
+	mu     sync.Mutex
+	clock  clock.Clock
+	cfg    MissLimiterConfig
+	slot   time.Duration
+	counts [missSlots]int
+	starts [missSlots]time.Duration
+}
+
+// NewMissLimiter creates a limiter measuring its window against clk
+func NewMissLimiter(cfg MissLimiterConfig, clk clock.Clock) *MissLimiter {
+	slot := cfg.Window / missSlots
+	if slot <= 0 {
+		slot = time.Nanosecond
+	}
+	return &MissLimiter{clock: clk, cfg: cfg, slot: slot}
+}
+
+// RecordMiss counts one failed lookup and returns how to answer it
+func (l *MissLimiter) RecordMiss() MissVerdict {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Monotonic()
+	start := now - now%l.slot
+	i := int(start/l.slot) % missSlots
+	if l.starts[i] != start {
+		l.starts[i] = start
+		l.counts[i] = 0
+	}
+	l.counts[i]++
+
+	misses := l.missesLocked(now)
+	switch {
+	case l.cfg.RejectAfter > 0 && misses > l.cfg.RejectAfter:
+		return MissReject
+	case l.cfg.DelayAfter > 0 && misses > l.cfg.DelayAfter:
+		return MissDelay
+	default:
+		return MissAllow
+	}
+}
+
+// Misses returns the number of failed lookups in the current window
+func (l *MissLimiter) Misses() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.missesLocked(l.clock.Monotonic())
+}
+
+// RetryAfter is how long a refused client should wait: the oldest bucket
+// leaves the window within one slot
+func (l *MissLimiter) RetryAfter() time.Duration {
+	return l.slot
+}
+
+func (l *MissLimiter) missesLocked(now time.Duration) int {
+	total := 0
+	for i, start := range l.starts {
+		if now-start < l.cfg.Window {
+			total += l.counts[i]
+		}
+	}
+	return total
+}
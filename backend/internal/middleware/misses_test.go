@@ -0,0 +1,106 @@
+package middleware_test
+
+import (
+	"testing"
+	"time"
+
+	"ots-backend/internal/middleware"
+	"ots-backend/internal/testutil"
+)
+
+func TestMissLimiterStages(t *testing.T) {
+	clk := testutil.NewFakeClock(epoch)
+	limiter := middleware.NewMissLimiter(middleware.MissLimiterConfig{
+		Window:      time.Minute,
+		DelayAfter:  3,
+		RejectAfter: 5,
+	}, clk)
+
+	want := []middleware.MissVerdict{
+		middleware.MissAllow, middleware.MissAllow, middleware.MissAllow,
+		middleware.MissDelay, middleware.MissDelay,
+		middleware.MissReject, middleware.MissReject,
+	}
+	for i, w := range want {
+		if got := limiter.RecordMiss(); got != w {
+			t.Fatalf("miss %d verdict = %d, want %d", i+1, got, w)
+		}
+	}
+	if got := limiter.Misses(); got != len(want) {
+		t.Errorf("Misses() = %d, want %d", got, len(want))
+	}
+}
+
+func TestMissLimiterWindowExpires(t *testing.T) {
+	clk := testutil.NewFakeClock(epoch)
+	limiter := middleware.NewMissLimiter(middleware.MissLimiterConfig{
+		Window:     time.Minute,
+		DelayAfter: 2,
+	}, clk)
+
+	limiter.RecordMiss()
+	clk.Advance(30 * time.Second)
+	limiter.RecordMiss()
+	if got := limiter.RecordMiss(); got != middleware.MissDelay {
+		t.Fatalf("third miss verdict = %d, want delay", got)
+	}
+
+	// The first miss leaves the window; the later two still count
+	clk.Advance(35 * time.Second)
+	if got := limiter.Misses(); got != 2 {
+		t.Fatalf("Misses() after first slot expired = %d, want 2", got)
+	}
+	if got := limiter.RecordMiss(); got != middleware.MissDelay {
+		t.Fatalf("miss with two in window verdict = %d, want delay", got)
+	}
+
+	clk.Advance(time.Minute)
+	if got := limiter.Misses(); got != 0 {
+		t.Fatalf("Misses() after full window = %d, want 0", got)
+	}
+	if got := limiter.RecordMiss(); got != middleware.MissAllow {
+		t.Fatalf("miss after quiet window verdict = %d, want allow", got)
+	}
+}
+
+func TestMissLimiterWindowSurvivesClockSteps(t *testing.T) {
+	clk := testutil.NewFakeClock(epoch)
+	limiter := middleware.NewMissLimiter(middleware.MissLimiterConfig{
+		Window:     time.Minute,
+		DelayAfter: 2,
+	}, clk)
+
+	limiter.RecordMiss()
+	limiter.RecordMiss()
+
+	// A forward step keeps the misses in the window
+	clk.Step(3 * time.Hour)
+	if got := limiter.RecordMiss(); got != middleware.MissDelay {
+		t.Fatalf("miss after forward step verdict = %d, want delay", got)
+	}
+
+	// A backward step does not keep them there past the window
+	clk.Step(-6 * time.Hour)
+	clk.Advance(time.Minute)
+	if got := limiter.Misses(); got != 0 {
+		t.Fatalf("Misses() a window after a backward step = %d, want 0", got)
+	}
+	if got := limiter.RecordMiss(); got != middleware.MissAllow {
+		t.Fatalf("miss a window after a backward step verdict = %d, want allow", got)
+	}
+}
+
+func TestMissLimiterZeroThresholdsDisableStages(t *testing.T) {
+	limiter := middleware.NewMissLimiter(middleware.MissLimiterConfig{
+		Window:      time.Minute,
+		RejectAfter: 1,
+	}, testutil.NewFakeClock(epoch))
+
+	if got := limiter.RecordMiss(); got != middleware.MissAllow {
+		t.Fatalf("first miss verdict = %d, want allow", got)
+	}
+	// No delay stage: straight from allow to reject
+	if got := limiter.RecordMiss(); got != middleware.MissReject {
+		t.Fatalf("second miss verdict = %d, want reject", got)
+	}
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ots-backend/internal/clock"
+	"ots-backend/internal/crypto"
+	"ots-backend/internal/models"
+	"ots-backend/pkg/ots"
+)
+
+// Create nonces travel as a cookie and a header carrying its MAC
+const (
+	CreateNonceCookie = "ots_create_nonce"
+	CreateNonceHeader = "X-Create-Nonce"
+)
+
+// DefaultCreateNonceTTL is used when no nonce lifetime is configured
+const DefaultCreateNonceTTL = 10 * time.Minute
+
+// nonceClockSkew is how much longer than a fresh nonce one may claim to
+// live, allowing for clock skew between instances
+const nonceClockSkew = time.Minute
+
+// CreateNonces issues and checks double-submit nonces for browser creates.
+// The cookie holds a random value and its expiry; the token handed to the
+// page is the keyring MAC of that value. A third-party page can neither read
+// the cookie nor, with SameSite=Strict, get it sent, so it cannot produce a
+// matching pair. Nothing is stored server-side.
+type CreateNonces struct {
+	keys  *crypto.Keyring
+	ttl   time.Duration
+	clock clock.Clock
+}
+
+// NewCreateNonces creates nonces valid for ttl, measured against clk
+func NewCreateNonces(keys *crypto.Keyring, ttl time.Duration, clk clock.Clock) *CreateNonces {
+	if ttl <= 0 {
+		ttl = DefaultCreateNonceTTL
+	}
+	return &CreateNonces{keys: keys, ttl: ttl, clock: clk}
+}
+
+// Issue sets a fresh nonce cookie on w and returns the matching token
+func (n *CreateNonces) Issue(w http.ResponseWriter, r *http.Request) (string, time.Time, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", time.Time{}, fmt.Errorf("generate nonce: %w", err)
+	}
+
+	expiresAt := n.clock.Now().Add(n.ttl).Truncate(time.Second)
+	value := base64.RawURLEncoding.EncodeToString(random) + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     CreateNonceCookie,
+		Value:    value,
+		Path:     "/api",
+		Expires:  expiresAt,
+		MaxAge:   int(n.ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   IsHTTPS(r),
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	return base64.RawURLEncoding.EncodeToString(n.keys.Sign([]byte(value))), expiresAt, nil
+}
+
+// Check verifies r carries a live nonce cookie and its token. Requests with
+// an Authorization header are API clients, not browser sessions, and are
+// exempt.
+func (n *CreateNonces) Check(r *http.Request) error {
+	if r.Header.Get("Authorization") != "" {
+		return nil
+	}
+
+	cookie, err := r.Cookie(CreateNonceCookie)
+	if err != nil {
+		return ots.ErrCreateNonceRequired
+	}
+	token, err := base64.RawURLEncoding.DecodeString(r.Header.Get(CreateNonceHeader))
+	if err != nil || len(token) == 0 {
+		return ots.ErrCreateNonceRequired
+	}
+	if !n.keys.Verify([]byte(cookie.Value), token) {
+		return ots.ErrCreateNonceRequired
+	}
+
+	// The expiry is covered by the MAC, so it can be trusted once verified
+	_, expiry, ok := strings.Cut(cookie.Value, ".")
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if !ok || err != nil {
+		return ots.ErrCreateNonceRequired
+	}
+	now, expiresAt := n.clock.Now(), time.Unix(unix, 0)
+	if !now.Before(expiresAt) {
+		return ots.ErrCreateNonceExpired
+	}
+	// A nonce set to outlive one issued now predates a backward step of the
+	// wall clock; honouring it would stretch its life by the step
+	if expiresAt.Sub(now) > n.ttl+nonceClockSkew {
+		return ots.ErrCreateNonceExpired
+	}
+
+	return nil
+}
+
+// Require rejects requests that fail Check with 403
+func (n *CreateNonces) Require(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := n.Check(r); err != nil {
+			status := ots.StatusCode(err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(models.ErrorResponse{
+				Error:   http.StatusText(status),
+				Message: err.Error(),
+				Code:    ots.ErrorCode(err),
+			})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
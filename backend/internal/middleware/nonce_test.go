@@ -0,0 +1,162 @@
+package middleware_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ots-backend/internal/crypto"
+	"ots-backend/internal/middleware"
+	"ots-backend/internal/testutil"
+	"ots-backend/pkg/ots"
+)
+
+func newTestNonces(t *testing.T, clk *testutil.FakeClock, keys ...byte) *middleware.CreateNonces {
+	t.Helper()
+
+	var raw [][]byte
+	for _, b := range keys {
+		raw = append(raw, bytes.Repeat([]byte{b}, crypto.MinKeyringKeySize))
+	}
+	keyring, err := crypto.NewKeyring(raw...)
+	if err != nil {
+		t.Fatalf("NewKeyring() error: %v", err)
+	}
+	return middleware.NewCreateNonces(keyring, time.Minute, clk)
+}
+
+// issueNonce returns the cookie and token a browser would hold
+func issueNonce(t *testing.T, nonces *middleware.CreateNonces) (*http.Cookie, string) {
+	t.Helper()
+
+	response := httptest.NewRecorder()
+	token, _, err := nonces.Issue(response, httptest.NewRequest(http.MethodGet, "/api/secrets/nonce", nil))
+	if err != nil {
+		t.Fatalf("Issue() error: %v", err)
+	}
+
+	cookies := response.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != middleware.CreateNonceCookie {
+		t.Fatalf("Issue() cookies = %v, want one %s", cookies, middleware.CreateNonceCookie)
+	}
+	cookie := cookies[0]
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteStrictMode || cookie.Path != "/api" {
+		t.Errorf("nonce cookie = %+v, want HttpOnly, SameSite=Strict, Path=/api", cookie)
+	}
+	return cookie, token
+}
+
+func createRequest(cookie *http.Cookie, token string) *http.Request {
+	request := httptest.NewRequest(http.MethodPost, "/api/secrets", strings.NewReader("{}"))
+	if cookie != nil {
+		request.AddCookie(cookie)
+	}
+	if token != "" {
+		request.Header.Set(middleware.CreateNonceHeader, token)
+	}
+	return request
+}
+
+func TestCreateNonceCheck(t *testing.T) {
+	clk := testutil.NewFakeClock(epoch)
+	nonces := newTestNonces(t, clk, 0x01)
+	cookie, token := issueNonce(t, nonces)
+	otherCookie, otherToken := issueNonce(t, nonces)
+
+	tampered := *cookie
+	tampered.Value = cookie.Value[:strings.LastIndex(cookie.Value, ".")+1] + "9999999999"
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		want    error
+	}{
+		{"matching pair", createRequest(cookie, token), nil},
+		{"missing cookie", createRequest(nil, token), ots.ErrCreateNonceRequired},
+		{"missing header", createRequest(cookie, ""), ots.ErrCreateNonceRequired},
+		{"mismatched token", createRequest(cookie, otherToken), ots.ErrCreateNonceRequired},
+		{"mismatched cookie", createRequest(otherCookie, token), ots.ErrCreateNonceRequired},
+		{"garbage token", createRequest(cookie, "!!!"), ots.ErrCreateNonceRequired},
+		{"extended expiry", createRequest(&tampered, token), ots.ErrCreateNonceRequired},
+	}
+	for _, tt := range tests {
+		if err := nonces.Check(tt.request); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Check() error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// A nonce signed by another server's key never matches
+	foreign := newTestNonces(t, clk, 0x02)
+	if err := foreign.Check(createRequest(cookie, token)); !errors.Is(err, ots.ErrCreateNonceRequired) {
+		t.Errorf("Check() with foreign key error = %v, want ErrCreateNonceRequired", err)
+	}
+
+	// After rotation the previous key still verifies
+	rotated := newTestNonces(t, clk, 0x02, 0x01)
+	if err := rotated.Check(createRequest(cookie, token)); err != nil {
+		t.Errorf("Check() after key rotation error = %v", err)
+	}
+
+	clk.Advance(time.Minute)
+	if err := nonces.Check(createRequest(cookie, token)); !errors.Is(err, ots.ErrCreateNonceExpired) {
+		t.Errorf("Check() of expired nonce error = %v, want ErrCreateNonceExpired", err)
+	}
+}
+
+func TestCreateNonceAcrossClockSteps(t *testing.T) {
+	clk := testutil.NewFakeClock(epoch)
+	nonces := newTestNonces(t, clk, 0x01)
+	cookie, token := issueNonce(t, nonces)
+
+	// Skew between instances is tolerated
+	clk.Step(-30 * time.Second)
+	if err := nonces.Check(createRequest(cookie, token)); err != nil {
+		t.Fatalf("Check() after a small backward step error = %v", err)
+	}
+
+	// A nonce that would now live an hour longer than a fresh one is refused
+	clk.Step(-time.Hour)
+	if err := nonces.Check(createRequest(cookie, token)); !errors.Is(err, ots.ErrCreateNonceExpired) {
+		t.Errorf("Check() after a backward step error = %v, want ErrCreateNonceExpired", err)
+	}
+
+	// Nonces issued after the step work as usual
+	fresh, freshToken := issueNonce(t, nonces)
+	if err := nonces.Check(createRequest(fresh, freshToken)); err != nil {
+		t.Errorf("Check() of a nonce issued after the step error = %v", err)
+	}
+}
+
+func TestCreateNonceTokenAuthExempt(t *testing.T) {
+	nonces := newTestNonces(t, testutil.NewFakeClock(epoch), 0x01)
+
+	request := createRequest(nil, "")
+	request.Header.Set("Authorization", "Bearer api-client")
+	if err := nonces.Check(request); err != nil {
+		t.Errorf("Check() with Authorization error = %v, want nil", err)
+	}
+}
+
+func TestCreateNonceRequire(t *testing.T) {
+	nonces := newTestNonces(t, testutil.NewFakeClock(epoch), 0x01)
+	handler := nonces.Require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, createRequest(nil, ""))
+	if response.Code != http.StatusForbidden || !strings.Contains(response.Body.String(), `"create_nonce_required"`) {
+		t.Errorf("Require() without nonce = %d %s, want 403 create_nonce_required", response.Code, response.Body)
+	}
+
+	cookie, token := issueNonce(t, nonces)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, createRequest(cookie, token))
+	if response.Code != http.StatusCreated {
+		t.Errorf("Require() with nonce status = %d, want %d", response.Code, http.StatusCreated)
+	}
+}
@@ -2,189 +2,265 @@ package middleware
 
 import (
 	"encoding/json"
-	"net"
+	"math"
 	"net/http"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5/middleware"
-)
 
-// SecurityHeaders adds security headers to all responses
-func SecurityHeaders(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'self' 'unsafe-inline' 'unsafe-eval'; style-src 'self' 'unsafe-inline'; img-src 'self' data: https:; font-src 'self' data:; connect-src 'self' https:; media-src 'self'; object-src 'none'; frame-ancestors 'none'; base-uri 'self'; form-action 'self';")
-		w.Header().Set("X-Frame-Options", "DENY")
-		w.Header().Set("X-Content-Type-Options", "nosniff")
-		w.Header().Set("Referrer-Policy", "no-referrer")
-		w.Header().Set("Permissions-Policy", "accelerometer=(), camera=(), geolocation=(), gyroscope=(), magnetometer=(), microphone=(), payment=(), usb=()")
-		w.Header().Set("Cross-Origin-Opener-Policy", "same-origin")
-		w.Header().Set("X-XSS-Protection", "0")
-		w.Header().Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains; preload")
-
-		next.ServeHTTP(w, r)
-	})
-}
+	"ots-backend/internal/clock"
+	"ots-backend/internal/models"
+)
 
 // Logger returns a middleware that logs HTTP requests
 func Logger(next http.Handler) http.Handler {
 	return middleware.Logger(next)
 }
 
-// rateLimitEntry tracks request timestamps for rate limiting
+// rateLimitEntry tracks request times for rate limiting, as monotonic
+// clock readings so a wall clock step cannot move the window
 type rateLimitEntry struct {
-	requests []time.Time
+	requests []time.Duration
 }
 
 // RateLimiter implements IP-based rate limiting
 type RateLimiter struct {
 	requests map[string]*rateLimitEntry
 	mu       sync.RWMutex
-	maxReq   int
-	window   time.Duration
+	limit    func() (int, time.Duration)
+	clock    clock.Clock
+	// adaptive scales each client's limit; nil keeps it fixed
+	adaptive Adaptive
+	// reject writes the 429; nil writes RejectRateLimited
+	reject Reject
+}
+
+// Reject writes the response to a request over the limit. Retry-After and
+// the X-RateLimit headers are already set when it runs.
+type Reject func(w http.ResponseWriter, r *http.Request)
+
+// RejectRateLimited is the default Reject: a 429 ErrorResponse with code
+// rate_limited
+func RejectRateLimited(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(models.ErrorResponse{
+		Error:   http.StatusText(http.StatusTooManyRequests),
+		Message: "rate limit exceeded",
+		Code:    "rate_limited",
+	})
+}
+
+// Adaptive adjusts each client's limit from the responses it gets
+type Adaptive interface {
+	// Allowance returns the limit for client in place of base
+	Allowance(client string, base int) int
+	// Observe records the status of a response sent to client
+	Observe(client string, status int)
 }
 
 type rateLimitResult struct {
-	Allowed    bool
-	Limit      int
-	Remaining  int
-	RetryAfter time.Duration
+	Allowed bool
+	RateLimitStatus
+}
+
+// RateLimitStatus is a client's standing against a limiter
+type RateLimitStatus struct {
+	Limit     int
+	Remaining int
+	// Reset is how long until the oldest request counted leaves the
+	// window, zero when none is counted
+	Reset time.Duration
+}
+
+// RateLimiterOptions configures a RateLimiter. Only Limit is required.
+type RateLimiterOptions struct {
+	// Limit returns the budget per client IP and its window. It is read on
+	// every request, so a configuration reload takes effect without a
+	// restart.
+	Limit func() (int, time.Duration)
+	// Clock measures windows; nil is the system clock
+	Clock clock.Clock
+	// Adaptive scales each client's budget and sees the status of every
+	// response the limiter let through; nil keeps budgets fixed
+	Adaptive Adaptive
+	// Reject answers requests over the limit; nil uses RejectRateLimited
+	Reject Reject
+	// PruneEvery is how often idle clients are forgotten in the
+	// background; zero leaves that to calls to Prune
+	PruneEvery time.Duration
 }
 
-// RateLimit creates a middleware that limits requests per IP
-func RateLimit(maxRequests int, window time.Duration) func(http.Handler) http.Handler {
+// NewRateLimiter creates a limiter from opts
+func NewRateLimiter(opts RateLimiterOptions) *RateLimiter {
+	clk := opts.Clock
+	if clk == nil {
+		clk = clock.System
+	}
 	limiter := &RateLimiter{
 		requests: make(map[string]*rateLimitEntry),
-		maxReq:   maxRequests,
-		window:   window,
+		limit:    opts.Limit,
+		clock:    clk,
+		adaptive: opts.Adaptive,
+		reject:   opts.Reject,
+	}
+	if opts.PruneEvery > 0 {
+		go limiter.cleanup(opts.PruneEvery)
 	}
+	return limiter
+}
+
+// Handler wraps next, rejecting requests over the limit with 429
+func (rl *RateLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := ClientIP(r)
+		result := rl.allow(ip)
+
+		SetRateLimitHeaders(w.Header(), result.RateLimitStatus)
 
-	// Cleanup old entries periodically
-	go limiter.cleanup()
-
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := getClientIP(r)
-			result := limiter.allow(ip)
-
-			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
-			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
-
-			if !result.Allowed {
-				retryAfterSeconds := int(result.RetryAfter.Seconds())
-				if retryAfterSeconds < 1 {
-					retryAfterSeconds = 1
-				}
-
-				w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusTooManyRequests)
-				json.NewEncoder(w).Encode(map[string]string{
-					"error":   "rate limit exceeded",
-					"message": "too many requests from this IP, please retry later",
-				})
-				return
+		if !result.Allowed {
+			// Whole seconds until the oldest request leaves the window,
+			// rounded up so a retry on time is never refused again
+			w.Header().Set("Retry-After", strconv.Itoa(resetSeconds(result.Reset)))
+
+			reject := rl.reject
+			if reject == nil {
+				reject = RejectRateLimited
 			}
+			reject(w, r)
+			return
+		}
 
+		if rl.adaptive == nil {
 			next.ServeHTTP(w, r)
-		})
+			return
+		}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		rl.adaptive.Observe(ip, status)
+	})
+}
+
+// resetSeconds rounds a reset up to whole seconds, at least one
+func resetSeconds(reset time.Duration) int {
+	return max(int(math.Ceil(reset.Seconds())), 1)
+}
+
+// SetRateLimitHeaders reports status in the X-RateLimit headers of h
+func SetRateLimitHeaders(h http.Header, status RateLimitStatus) {
+	h.Set("X-RateLimit-Limit", strconv.Itoa(status.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(status.Remaining))
+	h.Set("X-RateLimit-Reset", strconv.Itoa(resetSeconds(status.Reset)))
+}
+
+// budget returns the limit and window for ip
+func (rl *RateLimiter) budget(ip string) (int, time.Duration) {
+	maxReq, window := rl.limit()
+	if rl.adaptive != nil {
+		maxReq = rl.adaptive.Allowance(ip, maxReq)
+	}
+	return maxReq, window
+}
+
+// inWindow returns the requests that have not yet left the window at now,
+// oldest first
+func inWindow(requests []time.Duration, now, window time.Duration) []time.Duration {
+	valid := make([]time.Duration, 0, len(requests))
+	for _, req := range requests {
+		if now-req < window {
+			valid = append(valid, req)
+		}
 	}
+	return valid
+}
+
+// status is the standing of a client with valid requests in the window
+func status(maxReq int, window, now time.Duration, valid []time.Duration) RateLimitStatus {
+	st := RateLimitStatus{Limit: maxReq, Remaining: max(maxReq-len(valid), 0)}
+	if len(valid) > 0 {
+		st.Reset = window - (now - valid[0])
+	}
+	return st
 }
 
 func (rl *RateLimiter) allow(ip string) rateLimitResult {
+	maxReq, window := rl.budget(ip)
+
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
+	now := rl.clock.Monotonic()
 	entry, exists := rl.requests[ip]
-	now := time.Now()
-
 	if !exists {
-		rl.requests[ip] = &rateLimitEntry{
-			requests: []time.Time{now},
-		}
-		return rateLimitResult{
-			Allowed:   true,
-			Limit:     rl.maxReq,
-			Remaining: max(rl.maxReq-1, 0),
-		}
+		entry = &rateLimitEntry{}
+		rl.requests[ip] = entry
 	}
 
-	// Remove old requests outside the window
-	validRequests := make([]time.Time, 0)
-	for _, req := range entry.requests {
-		if now.Sub(req) < rl.window {
-			validRequests = append(validRequests, req)
-		}
+	// Remove old requests outside the window. A client with none left is
+	// let through whatever the budget, so each gets one request per window.
+	valid := inWindow(entry.requests, now, window)
+	if len(valid) > 0 && len(valid) >= maxReq {
+		entry.requests = valid
+		return rateLimitResult{Allowed: false, RateLimitStatus: status(maxReq, window, now, valid)}
 	}
 
-	if len(validRequests) >= rl.maxReq {
-		rl.requests[ip].requests = validRequests
-		retryAfter := rl.window
-		if len(validRequests) > 0 {
-			retryAfter = rl.window - now.Sub(validRequests[0])
-		}
+	entry.requests = append(valid, now)
+	return rateLimitResult{Allowed: true, RateLimitStatus: status(maxReq, window, now, entry.requests)}
+}
 
-		return rateLimitResult{
-			Allowed:    false,
-			Limit:      rl.maxReq,
-			Remaining:  0,
-			RetryAfter: retryAfter,
-		}
-	}
+// Status reports client's standing without counting a request against it
+func (rl *RateLimiter) Status(client string) RateLimitStatus {
+	maxReq, window := rl.budget(client)
+
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
 
-	validRequests = append(validRequests, now)
-	rl.requests[ip].requests = validRequests
-	return rateLimitResult{
-		Allowed:   true,
-		Limit:     rl.maxReq,
-		Remaining: max(rl.maxReq-len(validRequests), 0),
+	now := rl.clock.Monotonic()
+	var valid []time.Duration
+	if entry, ok := rl.requests[client]; ok {
+		valid = inWindow(entry.requests, now, window)
 	}
+	return status(maxReq, window, now, valid)
 }
 
-func (rl *RateLimiter) cleanup() {
-	ticker := time.NewTicker(time.Minute)
+func (rl *RateLimiter) cleanup(every time.Duration) {
+	ticker := time.NewTicker(every)
 	defer ticker.Stop()
 
 	for range ticker.C {
-		rl.mu.Lock()
-		now := time.Now()
-		for ip, entry := range rl.requests {
-			valid := make([]time.Time, 0)
-			for _, req := range entry.requests {
-				if now.Sub(req) < rl.window {
-					valid = append(valid, req)
-				}
-			}
-			if len(valid) == 0 {
-				delete(rl.requests, ip)
-			} else {
-				rl.requests[ip].requests = valid
-			}
-		}
-		rl.mu.Unlock()
+		rl.Prune()
 	}
 }
 
-func getClientIP(r *http.Request) string {
-	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
-		parts := strings.Split(forwarded, ",")
-		if len(parts) > 0 {
-			return strings.TrimSpace(parts[0])
-		}
-	}
+// Prune forgets requests that have left the window and clients with none left
+func (rl *RateLimiter) Prune() {
+	_, window := rl.limit()
 
-	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
-		return realIP
-	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
 
-	host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
-	if err == nil {
-		return host
+	now := rl.clock.Monotonic()
+	for ip, entry := range rl.requests {
+		valid := inWindow(entry.requests, now, window)
+		if len(valid) == 0 {
+			delete(rl.requests, ip)
+		} else {
+			rl.requests[ip].requests = valid
+		}
 	}
+}
 
-	return strings.TrimSpace(r.RemoteAddr)
+// Clients reports how many clients the limiter is tracking
+func (rl *RateLimiter) Clients() int {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	return len(rl.requests)
 }
 
 func max(a, b int) int {
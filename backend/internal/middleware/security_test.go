@@ -0,0 +1,286 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	httpMiddleware "ots-backend/internal/middleware"
+	"ots-backend/internal/models"
+	"ots-backend/internal/testutil"
+)
+
+var epoch = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newLimitedStack(requests int, window time.Duration) *testutil.Stack {
+	stack := testutil.NewStack(testutil.NewFakeClock(epoch), testutil.Limit{Requests: requests, Window: window})
+	stack.Router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return stack
+}
+
+func expectStatus(t *testing.T, resp *http.Response, want int) {
+	t.Helper()
+	if resp.StatusCode != want {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, want)
+	}
+}
+
+func TestRateLimitKeysOnResolvedIP(t *testing.T) {
+	stack := newLimitedStack(1, time.Minute)
+
+	// Same IPv6 client, different source ports, must share one bucket
+	expectStatus(t, stack.Do(http.MethodGet, "/", "[2001:db8::7]:1000"), http.StatusOK)
+	expectStatus(t, stack.Do(http.MethodGet, "/", "[2001:db8::7]:2000"), http.StatusTooManyRequests)
+}
+
+func TestRateLimitHeadersCountDown(t *testing.T) {
+	stack := newLimitedStack(3, time.Minute)
+
+	for _, want := range []string{"2", "1", "0"} {
+		resp := stack.Do(http.MethodGet, "/", "203.0.113.1:1")
+		expectStatus(t, resp, http.StatusOK)
+		if got := resp.Header.Get("X-RateLimit-Remaining"); got != want {
+			t.Fatalf("X-RateLimit-Remaining = %q, want %q", got, want)
+		}
+		if got := resp.Header.Get("X-RateLimit-Limit"); got != "3" {
+			t.Fatalf("X-RateLimit-Limit = %q, want 3", got)
+		}
+	}
+
+	expectStatus(t, stack.Do(http.MethodGet, "/", "203.0.113.1:1"), http.StatusTooManyRequests)
+	// Other clients have their own bucket
+	expectStatus(t, stack.Do(http.MethodGet, "/", "203.0.113.2:1"), http.StatusOK)
+}
+
+func TestRateLimitExactWindowRollover(t *testing.T) {
+	stack := newLimitedStack(1, time.Minute)
+	const client = "203.0.113.1:1"
+
+	expectStatus(t, stack.Do(http.MethodGet, "/", client), http.StatusOK)
+
+	// One nanosecond before the window closes the request still counts
+	stack.Clock.Advance(time.Minute - time.Nanosecond)
+	resp := stack.Do(http.MethodGet, "/", client)
+	expectStatus(t, resp, http.StatusTooManyRequests)
+	if got := resp.Header.Get("Retry-After"); got != "1" {
+		t.Fatalf("Retry-After = %q, want 1 (sub-second waits round up)", got)
+	}
+
+	// At exactly one window it has expired
+	stack.Clock.Advance(time.Nanosecond)
+	expectStatus(t, stack.Do(http.MethodGet, "/", client), http.StatusOK)
+}
+
+func TestRateLimitSlidingWindow(t *testing.T) {
+	stack := newLimitedStack(2, time.Minute)
+	const client = "203.0.113.1:1"
+
+	expectStatus(t, stack.Do(http.MethodGet, "/", client), http.StatusOK)
+	stack.Clock.Advance(40 * time.Second)
+	expectStatus(t, stack.Do(http.MethodGet, "/", client), http.StatusOK)
+
+	stack.Clock.Advance(5 * time.Second)
+	resp := stack.Do(http.MethodGet, "/", client)
+	expectStatus(t, resp, http.StatusTooManyRequests)
+	// The oldest request leaves the window 15s from now
+	if got := resp.Header.Get("Retry-After"); got != "15" {
+		t.Fatalf("Retry-After = %q, want 15", got)
+	}
+
+	// Only the oldest slot frees up; the second request still counts
+	stack.Clock.Advance(15 * time.Second)
+	expectStatus(t, stack.Do(http.MethodGet, "/", client), http.StatusOK)
+	expectStatus(t, stack.Do(http.MethodGet, "/", client), http.StatusTooManyRequests)
+}
+
+func TestRateLimitRefusalCountsDownToReset(t *testing.T) {
+	stack := newLimitedStack(2, time.Minute)
+	const client = "203.0.113.1:1"
+
+	expectStatus(t, stack.Do(http.MethodGet, "/", client), http.StatusOK)
+	stack.Clock.Advance(10 * time.Second)
+	expectStatus(t, stack.Do(http.MethodGet, "/", client), http.StatusOK)
+
+	// The oldest request ages out 30.5s from now, rounded up to 31
+	stack.Clock.Advance(19500 * time.Millisecond)
+	resp := stack.Do(http.MethodGet, "/", client)
+	expectStatus(t, resp, http.StatusTooManyRequests)
+	for _, name := range []string{"Retry-After", "X-RateLimit-Reset"} {
+		if got := resp.Header.Get(name); got != "31" {
+			t.Errorf("%s = %q, want 31", name, got)
+		}
+	}
+
+	var body models.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode 429 body: %v", err)
+	}
+	if body.Code != "rate_limited" || body.Error != http.StatusText(http.StatusTooManyRequests) {
+		t.Errorf("body = %+v, want code rate_limited", body)
+	}
+
+	// Retrying on time is allowed
+	stack.Clock.Advance(31 * time.Second)
+	expectStatus(t, stack.Do(http.MethodGet, "/", client), http.StatusOK)
+}
+
+func TestRateLimitStatusCountsNothing(t *testing.T) {
+	clk := testutil.NewFakeClock(epoch)
+	limiter := httpMiddleware.NewRateLimiter(httpMiddleware.RateLimiterOptions{
+		Limit: func() (int, time.Duration) { return 2, time.Minute },
+		Clock: clk,
+	})
+	const client = "203.0.113.1"
+
+	if got, want := limiter.Status(client), (httpMiddleware.RateLimitStatus{Limit: 2, Remaining: 2}); got != want {
+		t.Fatalf("Status() of a new client = %+v, want %+v", got, want)
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	request, _ := http.NewRequest(http.MethodGet, "/", nil)
+	request.RemoteAddr = client + ":1"
+	limiter.Handler(next).ServeHTTP(httptest.NewRecorder(), request)
+
+	clk.Advance(15 * time.Second)
+	for range 3 {
+		if got, want := limiter.Status(client), (httpMiddleware.RateLimitStatus{Limit: 2, Remaining: 1, Reset: 45 * time.Second}); got != want {
+			t.Fatalf("Status() = %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestRateLimitRejectedRequestsDoNotExtendWindow(t *testing.T) {
+	stack := newLimitedStack(1, time.Minute)
+	const client = "203.0.113.1:1"
+
+	expectStatus(t, stack.Do(http.MethodGet, "/", client), http.StatusOK)
+	for i := 0; i < 5; i++ {
+		stack.Clock.Advance(10 * time.Second)
+		expectStatus(t, stack.Do(http.MethodGet, "/", client), http.StatusTooManyRequests)
+	}
+
+	stack.Clock.Advance(10 * time.Second)
+	expectStatus(t, stack.Do(http.MethodGet, "/", client), http.StatusOK)
+}
+
+func TestRateLimitPruneForgetsIdleClients(t *testing.T) {
+	stack := newLimitedStack(5, time.Minute)
+
+	stack.Do(http.MethodGet, "/", "203.0.113.1:1")
+	stack.Clock.Advance(30 * time.Second)
+	stack.Do(http.MethodGet, "/", "203.0.113.2:1")
+
+	stack.Limiter.Prune()
+	if got := stack.Limiter.Clients(); got != 2 {
+		t.Fatalf("Clients() = %d, want 2", got)
+	}
+
+	stack.Clock.Advance(30 * time.Second)
+	stack.Limiter.Prune()
+	if got := stack.Limiter.Clients(); got != 1 {
+		t.Fatalf("Clients() after first window = %d, want 1", got)
+	}
+
+	stack.Clock.Advance(30 * time.Second)
+	stack.Limiter.Prune()
+	if got := stack.Limiter.Clients(); got != 0 {
+		t.Fatalf("Clients() after second window = %d, want 0", got)
+	}
+}
+
+func TestRateLimitWindowSurvivesClockSteps(t *testing.T) {
+	stack := newLimitedStack(1, time.Minute)
+	const client = "203.0.113.1:1"
+
+	expectStatus(t, stack.Do(http.MethodGet, "/", client), http.StatusOK)
+
+	// A forward step does not expire the window
+	stack.Clock.Step(2 * time.Hour)
+	expectStatus(t, stack.Do(http.MethodGet, "/", client), http.StatusTooManyRequests)
+
+	// Nor does a backward step hold it open past one minute of real time
+	stack.Clock.Step(-5 * time.Hour)
+	stack.Clock.Advance(30 * time.Second)
+	resp := stack.Do(http.MethodGet, "/", client)
+	expectStatus(t, resp, http.StatusTooManyRequests)
+	if got := resp.Header.Get("Retry-After"); got != "30" {
+		t.Fatalf("Retry-After after backward step = %q, want 30", got)
+	}
+
+	stack.Clock.Advance(30 * time.Second)
+	expectStatus(t, stack.Do(http.MethodGet, "/", client), http.StatusOK)
+}
+
+func TestStackAppliesSecurityHeaders(t *testing.T) {
+	resp := newLimitedStack(1, time.Minute).Do(http.MethodGet, "/", "203.0.113.1:1")
+
+	if got := resp.Header.Get("Cache-Control"); got != "no-store, no-cache" {
+		t.Fatalf("Cache-Control = %q, want no-store, no-cache", got)
+	}
+	if got := resp.Header.Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Fatalf("X-Content-Type-Options = %q, want nosniff", got)
+	}
+}
+
+// stubAdaptive grants each client a fixed limit and records what it sees
+type stubAdaptive struct {
+	limits   map[string]int
+	observed map[string][]int
+}
+
+func (a *stubAdaptive) Allowance(client string, base int) int {
+	if limit, ok := a.limits[client]; ok {
+		return limit
+	}
+	return base
+}
+
+func (a *stubAdaptive) Observe(client string, status int) {
+	a.observed[client] = append(a.observed[client], status)
+}
+
+func TestRateLimitAdaptiveScalesAndObserves(t *testing.T) {
+	adaptive := &stubAdaptive{
+		limits:   map[string]int{"203.0.113.1": 3, "203.0.113.2": 1},
+		observed: make(map[string][]int),
+	}
+	r := chi.NewRouter()
+	r.Use(httpMiddleware.RealIP)
+	r.Use(httpMiddleware.NewRateLimiter(httpMiddleware.RateLimiterOptions{
+		Limit:    func() (int, time.Duration) { return 2, time.Minute },
+		Clock:    testutil.NewFakeClock(epoch),
+		Adaptive: adaptive,
+	}).Handler)
+	r.Get("/ok", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) })
+	r.Get("/missing", http.NotFound)
+
+	trusted := "203.0.113.1:1"
+	for range 3 {
+		expectStatus(t, testutil.Do(r, http.MethodGet, "/ok", trusted), http.StatusOK)
+	}
+	resp := testutil.Do(r, http.MethodGet, "/ok", trusted)
+	expectStatus(t, resp, http.StatusTooManyRequests)
+	if got := resp.Header.Get("X-RateLimit-Limit"); got != "3" {
+		t.Errorf("X-RateLimit-Limit = %q, want the adapted 3", got)
+	}
+
+	distrusted := "203.0.113.2:1"
+	expectStatus(t, testutil.Do(r, http.MethodGet, "/missing", distrusted), http.StatusNotFound)
+	expectStatus(t, testutil.Do(r, http.MethodGet, "/missing", distrusted), http.StatusTooManyRequests)
+
+	// Rejected requests never reach the adaptive limiter
+	want := map[string][]int{
+		"203.0.113.1": {http.StatusOK, http.StatusOK, http.StatusOK},
+		"203.0.113.2": {http.StatusNotFound},
+	}
+	if !reflect.DeepEqual(adaptive.observed, want) {
+		t.Errorf("observed %v, want %v", adaptive.observed, want)
+	}
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+
+	"ots-backend/internal/sensitive"
+)
+
+// The request and response bodies below carry ciphertexts, keys and tokens
+// as plain strings because their JSON is the API. Logging or formatting
+// one, for example as a panic value, shows those fields as summaries.
+
+// formatRedacted writes name followed by the redacted fields in v
+func formatRedacted(f fmt.State, name string, v slog.Value) {
+	io.WriteString(f, name+v.String())
+}
+
+// LogValue logs the part with its ciphertext and IV redacted
+func (p SecretPart) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("label", p.Label),
+		slog.Any("ciphertext", sensitive.String(p.Ciphertext)),
+		slog.Any("iv", sensitive.String(p.IV)),
+	)
+}
+
+// Format writes the part with its ciphertext and IV redacted
+func (p SecretPart) Format(f fmt.State, _ rune) {
+	formatRedacted(f, "models.SecretPart", p.LogValue())
+}
+
+// LogValue logs the request with its ciphertext, IV, salt and parts redacted
+func (r CreateSecretRequest) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Any("ciphertext", sensitive.String(r.Ciphertext)),
+		slog.Any("iv", sensitive.String(r.IV)),
+		slog.Any("salt", sensitive.String(r.Salt)),
+		slog.Int("parts", len(r.Parts)),
+		slog.Int("expires_in", r.ExpiresIn),
+		slog.Bool("burn_after_read", r.BurnAfterRead),
+		slog.String("namespace", r.Namespace),
+		slog.Bool("notify_email", r.NotifyEmail != ""),
+	)
+}
+
+// Format writes the request with its ciphertext, IV, salt and parts redacted
+func (r CreateSecretRequest) Format(f fmt.State, _ rune) {
+	formatRedacted(f, "models.CreateSecretRequest", r.LogValue())
+}
+
+// LogValue logs the request with its plaintext and passphrase redacted
+func (r AgentCreateSecretRequest) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Any("content", sensitive.String(r.Content)),
+		slog.Any("passphrase", sensitive.String(r.Passphrase)),
+		slog.Int("expires_in", r.ExpiresIn),
+	)
+}
+
+// Format writes the request with its plaintext and passphrase redacted
+func (r AgentCreateSecretRequest) Format(f fmt.State, _ rune) {
+	formatRedacted(f, "models.AgentCreateSecretRequest", r.LogValue())
+}
+
+// LogValue logs the response with its management token redacted
+func (r CreateSecretResponse) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("id", r.ID),
+		slog.Any("management_token", sensitive.String(r.ManagementToken)),
+	)
+}
+
+// Format writes the response with its management token redacted
+func (r CreateSecretResponse) Format(f fmt.State, _ rune) {
+	formatRedacted(f, "models.CreateSecretResponse", r.LogValue())
+}
+
+// LogValue logs the response with its URL, whose fragment is the key, and
+// its management token redacted
+func (r AgentCreateSecretResponse) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("id", r.ID),
+		slog.Any("url", sensitive.String(r.URL)),
+		slog.Time("expires_at", r.ExpiresAt),
+		slog.Any("management_token", sensitive.String(r.ManagementToken)),
+	)
+}
+
+// Format writes the response with its URL and management token redacted
+func (r AgentCreateSecretResponse) Format(f fmt.State, _ rune) {
+	formatRedacted(f, "models.AgentCreateSecretResponse", r.LogValue())
+}
+
+// LogValue logs the response with its ciphertext, IV, salt, parts and ack
+// token redacted
+func (r GetSecretResponse) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Any("ciphertext", sensitive.String(r.Ciphertext)),
+		slog.Any("iv", sensitive.String(r.IV)),
+		slog.Any("salt", sensitive.String(r.Salt)),
+		slog.Int("parts", len(r.Parts)),
+		slog.Any("ack_token", sensitive.String(r.AckToken)),
+	)
+}
+
+// Format writes the response with its ciphertext, IV, salt, parts and ack
+// token redacted
+func (r GetSecretResponse) Format(f fmt.State, _ rune) {
+	formatRedacted(f, "models.GetSecretResponse", r.LogValue())
+}
+
+// LogValue logs the request with its ack token redacted
+func (r AckSecretRequest) LogValue() slog.Value {
+	return slog.GroupValue(slog.Any("ack_token", sensitive.String(r.AckToken)))
+}
+
+// Format writes the request with its ack token redacted
+func (r AckSecretRequest) Format(f fmt.State, _ rune) {
+	formatRedacted(f, "models.AckSecretRequest", r.LogValue())
+}
@@ -1,27 +1,86 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
+
+	"ots-backend/internal/crypto"
+	"ots-backend/internal/policy"
+	"ots-backend/internal/scan"
+	"ots-backend/internal/sensitive"
 )
 
 // Secret represents a stored encrypted secret
 type Secret struct {
-	ID            string    `json:"id"`
-	Ciphertext    []byte    `json:"-"`
-	IV            []byte    `json:"-"`
-	Salt          []byte    `json:"-"`
-	ExpiresAt     time.Time `json:"expires_at"`
-	BurnAfterRead bool      `json:"burn_after_read"`
-	CreatedAt     time.Time `json:"created_at"`
+	ID            string          `json:"id"`
+	Ciphertext    sensitive.Bytes `json:"-"`
+	IV            sensitive.Bytes `json:"-"`
+	Salt          sensitive.Bytes `json:"-"`
+	ExpiresAt     time.Time       `json:"expires_at"`
+	BurnAfterRead bool            `json:"burn_after_read"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// SecretPart is one independently encrypted, labelled part of a secret
+type SecretPart struct {
+	Label      string `json:"label"`
+	Ciphertext string `json:"ciphertext"`
+	IV         string `json:"iv"`
 }
 
-// CreateSecretRequest represents a request to create a new secret
+// CreateSecretRequest represents a request to create a new secret.
+// Either Ciphertext/IV or Parts is set, never both.
 type CreateSecretRequest struct {
-	Ciphertext    string `json:"ciphertext"`
-	IV            string `json:"iv"`
-	Salt          string `json:"salt,omitempty"`
-	ExpiresIn     int    `json:"expires_in"`
-	BurnAfterRead bool   `json:"burn_after_read"`
+	Ciphertext    string       `json:"ciphertext,omitempty"`
+	IV            string       `json:"iv,omitempty"`
+	Parts         []SecretPart `json:"parts,omitempty"`
+	Salt          string       `json:"salt,omitempty"`
+	ExpiresIn     int          `json:"expires_in"`
+	BurnAfterRead bool         `json:"burn_after_read"`
+	// DeclaredKeyBits is the client's link key length; stored, never returned
+	DeclaredKeyBits *int `json:"declared_key_bits,omitempty"`
+	// RequireAck keeps the secret until the reader acknowledges it
+	RequireAck bool `json:"require_ack,omitempty"`
+	// Namespace files the secret under a tenant for admin reporting; stored,
+	// never returned
+	Namespace string `json:"namespace,omitempty"`
+	// IVEmbedded replaces IV for ciphertexts with the nonce prepended;
+	// Algorithm names their cipher for the minimum length check
+	IVEmbedded bool   `json:"iv_embedded,omitempty"`
+	Algorithm  string `json:"algorithm,omitempty"`
+	// Slug is a custom ID to use in place of a generated one
+	Slug string `json:"slug,omitempty"`
+	// AvailableAfter refuses reads until then: an RFC 3339 time or a
+	// number of seconds from now
+	AvailableAfter json.RawMessage `json:"available_after,omitempty"`
+	// Hint is a short plaintext preview shown before the secret is read
+	Hint string `json:"hint,omitempty"`
+	// NotifyEmail is told when the secret is read or expires unread; stored
+	// encrypted, never returned
+	NotifyEmail string `json:"notify_email,omitempty"`
+	// ContentType and Filename describe the plaintext so the reader can
+	// save it; stored in the clear and returned with the secret
+	ContentType string `json:"content_type,omitempty"`
+	Filename    string `json:"filename,omitempty"`
+}
+
+// ValidateSecretRequest is a create to check without storing it: a full
+// CreateSecretRequest, or only Size and ExpiresIn for a ciphertext the
+// client has yet to produce
+type ValidateSecretRequest struct {
+	CreateSecretRequest
+	// Size is the decoded ciphertext length; ignored when ciphertext or
+	// parts are sent
+	Size int `json:"size,omitempty"`
+}
+
+// ValidateSecretResponse is what a create would store for a valid request
+type ValidateSecretResponse struct {
+	// ExpiresIn is the resolved TTL in seconds
+	ExpiresIn int       `json:"expires_in"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// Size is the decoded ciphertext length across the blob and all parts
+	Size int `json:"size"`
 }
 
 // AgentCreateSecretRequest represents a convenience request for plaintext uploads.
@@ -34,6 +93,10 @@ type AgentCreateSecretRequest struct {
 // CreateSecretResponse represents the response after creating a secret
 type CreateSecretResponse struct {
 	ID string `json:"id"`
+	// ManagementToken authorizes DELETE; it is returned only once
+	ManagementToken string `json:"management_token"`
+	// AvailableAfter is when reads start succeeding, absent for none
+	AvailableAfter *time.Time `json:"available_after,omitempty"`
 }
 
 // AgentCreateSecretResponse represents the response for agent plaintext uploads.
@@ -43,17 +106,137 @@ type AgentCreateSecretResponse struct {
 	ExpiresAt          time.Time `json:"expires_at"`
 	ExpiresIn          int       `json:"expires_in"`
 	PassphraseRequired bool      `json:"passphrase_required"`
+	ManagementToken    string    `json:"management_token"`
 }
 
 // GetSecretResponse represents the response when retrieving a secret
 type GetSecretResponse struct {
-	Ciphertext string `json:"ciphertext"`
-	IV         string `json:"iv"`
-	Salt       string `json:"salt,omitempty"`
+	Ciphertext string       `json:"ciphertext,omitempty"`
+	IV         string       `json:"iv,omitempty"`
+	Salt       string       `json:"salt,omitempty"`
+	Parts      []SecretPart `json:"parts,omitempty"`
+	// IVEmbedded means Ciphertext starts with its nonce and IV is absent
+	IVEmbedded bool `json:"iv_embedded,omitempty"`
+	// AckToken is set for require_ack secrets; posting it to the ack
+	// endpoint before AckExpiresAt confirms receipt
+	AckToken     string     `json:"ack_token,omitempty"`
+	AckExpiresAt *time.Time `json:"ack_expires_at,omitempty"`
+	// Revision is the held secret's revision, set with AckToken; sent back
+	// in If-Match it makes the ack conditional
+	Revision int64 `json:"revision,omitempty"`
+	// Hint is the sender's HTML-escaped preview, absent for none
+	Hint string `json:"hint,omitempty"`
+	// ContentType and Filename are as the sender gave them, absent for none
+	ContentType string `json:"content_type,omitempty"`
+	Filename    string `json:"filename,omitempty"`
+}
+
+// SecretMetadataResponse answers a read or burn from a client that did not
+// identify itself as interactive. Nothing was read or destroyed.
+type SecretMetadataResponse struct {
+	ID                   string `json:"id"`
+	ClientHeaderRequired string `json:"client_header_required"`
+	Message              string `json:"message"`
+}
+
+// AckSecretRequest confirms that a require_ack secret arrived
+type AckSecretRequest struct {
+	AckToken string `json:"ack_token"`
+}
+
+// Report reasons a recipient can give
+const (
+	ReportUnexpectedContent     = "unexpected_content"
+	ReportSuspectedInterception = "suspected_interception"
+	ReportOther                 = "other"
+)
+
+// ReportSecretRequest reports a secret as possibly compromised
+type ReportSecretRequest struct {
+	Reason string `json:"reason"`
+}
+
+// CreateNonceResponse carries the token matching the nonce cookie just set;
+// browsers send it back in X-Create-Nonce when creating a secret
+type CreateNonceResponse struct {
+	Nonce     string    `json:"nonce"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SecretLinkResponse is a single-use retrieval link token for a secret
+type SecretLinkResponse struct {
+	Token string `json:"token"`
+	// Path redeems the token
+	Path      string    `json:"path"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Receipt states a secret's creator can see
+const (
+	ReceiptActive   = "active"
+	ReceiptConsumed = "consumed"
+	ReceiptBurned   = "burned"
+	ReceiptExpired  = "expired"
+)
+
+// SecretReceiptResponse tells a secret's creator what became of it
+type SecretReceiptResponse struct {
+	State string `json:"state"`
+	// ConsumedAt is when the secret was read, absent unless consumed
+	ConsumedAt *time.Time `json:"consumed_at,omitempty"`
+	// EndedAt is when it was read, burned or found expired; absent while
+	// active, and for an expiry the cleanup worker has yet to record
+	EndedAt *time.Time `json:"ended_at,omitempty"`
+	// ReaderCountry is the reader's ISO 3166-1 alpha-2 country, absent
+	// unless consumed on a server with GeoIP lookups
+	ReaderCountry string `json:"reader_ip_country,omitempty"`
+	// Revision counts the secret's changes, as the ETag does
+	Revision int64 `json:"revision"`
+}
+
+// InfoResponse describes the running server's crypto mode and capabilities
+type InfoResponse struct {
+	Crypto       crypto.Attestation `json:"crypto"`
+	Capabilities map[string]bool    `json:"capabilities"`
 }
 
 // ErrorResponse represents an error response
 type ErrorResponse struct {
 	Error   string `json:"error"`
 	Message string `json:"message,omitempty"`
+	Code    string `json:"code,omitempty"`
+	// Limit describes the limit a validation error violated
+	Limit *policy.LimitDetail `json:"limit,omitempty"`
+	// Hint and Docs explain 4xx errors in development only
+	Hint string     `json:"hint,omitempty"`
+	Docs *ErrorDocs `json:"docs,omitempty"`
+	// Region names the deployment that owns a wrong_region secret
+	Region *RegionRedirect `json:"region,omitempty"`
+	// Violation names the scanner rule a policy_violation broke
+	Violation *scan.Violation `json:"violation,omitempty"`
+	// AvailableAfter is when a not_yet_available secret can be read
+	AvailableAfter *time.Time `json:"available_after,omitempty"`
+	// Revision is the secret's current revision on a revision_mismatch
+	Revision int64 `json:"revision,omitempty"`
+	// Retryable is set on every 5xx: true when repeating the request may
+	// succeed, after RetryAfterMs
+	Retryable    *bool `json:"retryable,omitempty"`
+	RetryAfterMs int64 `json:"retry_after_ms,omitempty"`
+}
+
+// RegionRedirect tells a client where to resend a misdirected request
+type RegionRedirect struct {
+	Code string `json:"code"`
+	// BaseURL is the region's public base URL, empty when no peer is configured
+	BaseURL string `json:"base_url,omitempty"`
+}
+
+// ErrorDocs shows what a correct call to the failing endpoint looks like
+type ErrorDocs struct {
+	Endpoint string            `json:"endpoint"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Example  any               `json:"example,omitempty"`
+	// Constraints are the limits that failed, or all the endpoint's limits
+	// when the error names none
+	Constraints []policy.LimitDetail `json:"constraints,omitempty"`
 }
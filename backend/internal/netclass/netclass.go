@@ -0,0 +1,87 @@
+// Package netclass maps client addresses to operator-defined network labels
+// so consumers can be described ("corp-vpn", "external") without keeping IPs.
+package netclass
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// DefaultLabel is reported for addresses outside every configured range
+const DefaultLabel = "external"
+
+// Range assigns a label to a CIDR prefix
+type Range struct {
+	Label  string
+	Prefix netip.Prefix
+}
+
+// Classifier labels addresses by the most specific matching range
+type Classifier struct {
+	ranges   []Range
+	fallback string
+}
+
+// New creates a classifier. An empty fallback uses DefaultLabel.
+func New(ranges []Range, fallback string) *Classifier {
+	if fallback == "" {
+		fallback = DefaultLabel
+	}
+
+	return &Classifier{ranges: ranges, fallback: fallback}
+}
+
+// ParseRanges parses "label=cidr" entries, e.g. "corp-vpn=10.8.0.0/16"
+func ParseRanges(values []string) ([]Range, error) {
+	ranges := make([]Range, 0, len(values))
+	for _, value := range values {
+		label, cidr, ok := strings.Cut(value, "=")
+		label = strings.TrimSpace(label)
+		if !ok || label == "" {
+			return nil, fmt.Errorf("network label %q: want label=cidr", value)
+		}
+
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, fmt.Errorf("network label %q: %w", value, err)
+		}
+
+		ranges = append(ranges, Range{Label: label, Prefix: prefix.Masked()})
+	}
+
+	return ranges, nil
+}
+
+// Classify returns the label of the longest matching prefix. Invalid
+// addresses get the fallback label.
+func (c *Classifier) Classify(addr netip.Addr) string {
+	if c == nil {
+		return DefaultLabel
+	}
+	if !addr.IsValid() {
+		return c.fallback
+	}
+
+	addr = addr.Unmap().WithZone("")
+	label := c.fallback
+	bits := -1
+	for _, r := range c.ranges {
+		if r.Prefix.Bits() > bits && r.Prefix.Contains(addr) {
+			label = r.Label
+			bits = r.Prefix.Bits()
+		}
+	}
+
+	return label
+}
+
+// ClassifyString parses ip and classifies it
+func (c *Classifier) ClassifyString(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return c.Classify(netip.Addr{})
+	}
+
+	return c.Classify(addr)
+}
@@ -0,0 +1,76 @@
+package netclass
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	ranges, err := ParseRanges([]string{
+		"corp-vpn=10.0.0.0/8",
+		"office-hq=10.20.0.0/16",
+		"lab=10.20.30.0/24",
+		"corp-v6=2001:db8::/32",
+		"office-v6=2001:db8:aa::/48",
+	})
+	if err != nil {
+		t.Fatalf("ParseRanges() error: %v", err)
+	}
+
+	classifier := New(ranges, "")
+
+	tests := []struct {
+		name string
+		ip   string
+		want string
+	}{
+		{name: "broad range", ip: "10.1.2.3", want: "corp-vpn"},
+		{name: "overlap picks more specific", ip: "10.20.1.1", want: "office-hq"},
+		{name: "overlap picks most specific", ip: "10.20.30.40", want: "lab"},
+		{name: "outside every range", ip: "203.0.113.9", want: DefaultLabel},
+		{name: "ipv6 broad", ip: "2001:db8:1::1", want: "corp-v6"},
+		{name: "ipv6 specific", ip: "2001:db8:aa::1", want: "office-v6"},
+		{name: "ipv6 zone stripped", ip: "2001:db8:aa::1%eth0", want: "office-v6"},
+		{name: "ipv6 outside", ip: "2001:db9::1", want: DefaultLabel},
+		{name: "ipv4-mapped ipv6", ip: "::ffff:10.20.30.1", want: "lab"},
+		{name: "garbage", ip: "not-an-ip", want: DefaultLabel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifier.ClassifyString(tt.ip); got != tt.want {
+				t.Errorf("ClassifyString(%q) = %q, want %q", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyOrderIndependent(t *testing.T) {
+	specificFirst := New([]Range{
+		{Label: "narrow", Prefix: netip.MustParsePrefix("192.168.1.0/24")},
+		{Label: "wide", Prefix: netip.MustParsePrefix("192.168.0.0/16")},
+	}, "outside")
+	wideFirst := New([]Range{
+		{Label: "wide", Prefix: netip.MustParsePrefix("192.168.0.0/16")},
+		{Label: "narrow", Prefix: netip.MustParsePrefix("192.168.1.0/24")},
+	}, "outside")
+
+	addr := netip.MustParseAddr("192.168.1.7")
+	if got := specificFirst.Classify(addr); got != "narrow" {
+		t.Errorf("specific-first Classify() = %q, want %q", got, "narrow")
+	}
+	if got := wideFirst.Classify(addr); got != "narrow" {
+		t.Errorf("wide-first Classify() = %q, want %q", got, "narrow")
+	}
+	if got := wideFirst.Classify(netip.MustParseAddr("8.8.8.8")); got != "outside" {
+		t.Errorf("Classify() fallback = %q, want %q", got, "outside")
+	}
+}
+
+func TestParseRangesRejectsInvalid(t *testing.T) {
+	for _, value := range []string{"10.0.0.0/8", "=10.0.0.0/8", "corp=10.0.0.0/33", "corp=nope"} {
+		if _, err := ParseRanges([]string{value}); err == nil {
+			t.Errorf("ParseRanges(%q) succeeded, want error", value)
+		}
+	}
+}
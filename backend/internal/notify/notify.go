@@ -0,0 +1,210 @@
+// Package notify tells a secret's creator what became of it: that it was
+// read, or that it expired unread. Each Notifier is one channel, email or a
+// webhook; a Dispatcher fans every event out to all of them through bounded
+// queues, so the request that caused an event never waits for delivery and
+// a slow or failing channel never holds up the others.
+//
+// Notices carry only the event and when it happened. The creator's address
+// is kept sealed under the server's notification key and opened only to
+// send.
+package notify
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ots-backend/internal/dropped"
+	"ots-backend/internal/logger"
+	"ots-backend/internal/sensitive"
+)
+
+// deliverTimeout bounds one delivery attempt on one channel
+const deliverTimeout = 10 * time.Second
+
+// drainTimeout bounds how long Stop waits for queued notices to go out
+const drainTimeout = 5 * time.Second
+
+// Event is one thing that happened to a secret with a notify address
+type Event struct {
+	// Type is webhook.EventConsumed, webhook.EventBurned,
+	// webhook.EventExpired or, announced without an address,
+	// webhook.EventReported
+	Type       string
+	SecretID   string
+	OccurredAt time.Time
+	// Email is the creator's address, empty for an announcement; channels
+	// that do not mail ignore it
+	Email sensitive.String
+}
+
+// Notifier delivers events over one channel
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// Results of one delivery, as counted by Counts
+const (
+	ResultSent   = "sent"
+	ResultFailed = "failed"
+)
+
+var (
+	countsMu sync.Mutex
+	counts   = make(map[string]int64)
+)
+
+// count records one delivery on channel with result
+func count(channel, result string) {
+	countsMu.Lock()
+	defer countsMu.Unlock()
+	counts[channel+"/"+result]++
+}
+
+// Counts returns deliveries since start, keyed "channel/result"
+func Counts() map[string]int64 {
+	countsMu.Lock()
+	defer countsMu.Unlock()
+	return maps.Clone(counts)
+}
+
+// lane is one channel's queue and the notifier that drains it
+type lane struct {
+	name     string
+	notifier Notifier
+	queue    chan Event
+}
+
+// Dispatcher queues every event for each of its notifiers
+type Dispatcher struct {
+	size  int
+	lanes []*lane
+
+	started atomic.Bool
+	wg      sync.WaitGroup
+
+	// mu keeps Enqueue from sending on a queue Stop closed
+	mu      sync.RWMutex
+	stopped bool
+}
+
+// NewDispatcher creates a dispatcher whose channels queue up to size
+// events each
+func NewDispatcher(size int) *Dispatcher {
+	return &Dispatcher{size: max(size, 1)}
+}
+
+// Add registers notifier under name, which labels its logs and counts.
+// Call it before Start.
+func (d *Dispatcher) Add(name string, n Notifier) {
+	d.lanes = append(d.lanes, &lane{name: name, notifier: n, queue: make(chan Event, d.size)})
+}
+
+// Channels returns the names of the registered notifiers
+func (d *Dispatcher) Channels() []string {
+	names := make([]string, len(d.lanes))
+	for i, l := range d.lanes {
+		names[i] = l.name
+	}
+	return names
+}
+
+// Start runs one delivery worker per channel. Deliveries in flight when ctx
+// ends are abandoned; Stop waits for the queues to empty instead.
+func (d *Dispatcher) Start(ctx context.Context) {
+	if !d.started.CompareAndSwap(false, true) {
+		return
+	}
+	for _, l := range d.lanes {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			for e := range l.queue {
+				deliver(ctx, l, e)
+			}
+		}()
+	}
+}
+
+// Enqueue queues e on every channel without blocking. A channel whose
+// queue is full drops the event and records the loss.
+func (d *Dispatcher) Enqueue(e Event) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.stopped {
+		dropped.Record(dropped.KindNotification, dropped.ReasonShutdown)
+		return
+	}
+	for _, l := range d.lanes {
+		select {
+		case l.queue <- e:
+		default:
+			dropped.Record(dropped.KindNotification, dropped.ReasonOverflow)
+			logger.Warn("notification queue full, dropping notice", "channel", l.name, "event", e.Type)
+		}
+	}
+}
+
+// Stop closes the queues and waits, briefly, for queued events to be
+// delivered. Events still queued after that are recorded as dropped.
+func (d *Dispatcher) Stop() {
+	d.mu.Lock()
+	if d.stopped {
+		d.mu.Unlock()
+		return
+	}
+	d.stopped = true
+	for _, l := range d.lanes {
+		close(l.queue)
+	}
+	d.mu.Unlock()
+
+	if !d.started.Load() {
+		d.discard()
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(drainTimeout):
+		d.discard()
+	}
+}
+
+// discard records every event left in the closed queues as dropped
+func (d *Dispatcher) discard() {
+	for _, l := range d.lanes {
+		for range l.queue {
+			dropped.Record(dropped.KindNotification, dropped.ReasonShutdown)
+		}
+	}
+}
+
+// deliver makes one bounded attempt to send e on l. Failures are logged
+// without the address and counted; they never reach the request that
+// caused the event.
+func deliver(ctx context.Context, l *lane, e Event) {
+	ctx, cancel := context.WithTimeout(ctx, deliverTimeout)
+	defer cancel()
+
+	err := l.notifier.Notify(ctx, e)
+	if err == nil {
+		count(l.name, ResultSent)
+		return
+	}
+	count(l.name, ResultFailed)
+	dropped.Record(dropped.KindNotification, dropped.Reason(ctx, err))
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	logger.Warn("failed to send notification", "channel", l.name, "event", e.Type, "secret_id", e.SecretID, "error", err)
+}
@@ -0,0 +1,235 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ots-backend/internal/dropped"
+	"ots-backend/internal/testutil"
+	"ots-backend/pkg/webhook"
+)
+
+const testAddress = "alice@example.com"
+
+var testTime = time.Date(2026, 5, 14, 9, 30, 0, 0, time.UTC)
+
+func TestSMTPSendsOnlyTheEventAndTime(t *testing.T) {
+	server := testutil.NewSMTPServer(t)
+	notifier := &SMTP{Addr: server.Addr, From: "ots@example.net"}
+
+	for _, tt := range []struct {
+		event   string
+		subject string
+		body    string
+	}{
+		{webhook.EventConsumed, "Subject: Your secret was read", "was read at Thu, 14 May 2026 09:30:00 UTC"},
+		{webhook.EventBurned, "Subject: Your secret was burned", "was burned at Thu, 14 May 2026 09:30:00 UTC without being read"},
+		{webhook.EventExpired, "Subject: Your secret expired unread", "expired at Thu, 14 May 2026 09:30:00 UTC without being read"},
+	} {
+		e := Event{Type: tt.event, SecretID: "secret-id-not-in-mail", OccurredAt: testTime, Email: testAddress}
+		if err := notifier.Notify(context.Background(), e); err != nil {
+			t.Fatalf("Notify(%s) error: %v", tt.event, err)
+		}
+
+		mail, ok := server.Next(time.Second)
+		if !ok {
+			t.Fatalf("no mail for %s", tt.event)
+		}
+		if mail.From != "ots@example.net" || len(mail.To) != 1 || mail.To[0] != testAddress {
+			t.Errorf("envelope = %s -> %v, want ots@example.net -> [%s]", mail.From, mail.To, testAddress)
+		}
+		for _, want := range []string{tt.subject, tt.body, "To: <" + testAddress + ">", "Content-Type: text/plain; charset=utf-8"} {
+			if !strings.Contains(mail.Data, want) {
+				t.Errorf("%s mail lacks %q:\n%s", tt.event, want, mail.Data)
+			}
+		}
+		if strings.Contains(mail.Data, e.SecretID) {
+			t.Errorf("%s mail names the secret:\n%s", tt.event, mail.Data)
+		}
+	}
+}
+
+func TestSMTPSkipsEventsWithoutAddress(t *testing.T) {
+	// Nothing listens here; a dial would fail
+	notifier := &SMTP{Addr: "127.0.0.1:1", From: "ots@example.net"}
+	if err := notifier.Notify(context.Background(), Event{Type: webhook.EventConsumed, OccurredAt: testTime}); err != nil {
+		t.Errorf("Notify() without address error: %v", err)
+	}
+}
+
+func TestSMTPRequireTLS(t *testing.T) {
+	server := testutil.NewSMTPServer(t)
+	notifier := &SMTP{Addr: server.Addr, From: "ots@example.net", RequireTLS: true}
+
+	err := notifier.Notify(context.Background(), Event{Type: webhook.EventConsumed, OccurredAt: testTime, Email: testAddress})
+	if !errors.Is(err, ErrNoTLS) {
+		t.Fatalf("Notify() error = %v, want ErrNoTLS", err)
+	}
+	if _, ok := server.Next(100 * time.Millisecond); ok {
+		t.Error("mail sent in plaintext despite RequireTLS")
+	}
+}
+
+func TestSMTPErrorsOmitTheAddress(t *testing.T) {
+	server := testutil.NewSMTPServer(t)
+	server.RejectRcpt(550)
+	notifier := &SMTP{Addr: server.Addr, From: "ots@example.net"}
+
+	err := notifier.Notify(context.Background(), Event{Type: webhook.EventConsumed, OccurredAt: testTime, Email: testAddress})
+	if err == nil {
+		t.Fatal("Notify() to a rejected recipient succeeded")
+	}
+	if !strings.Contains(err.Error(), "550") || strings.Contains(err.Error(), testAddress) {
+		t.Errorf("Notify() error = %q, want the status without the address", err)
+	}
+}
+
+func TestWebhookPostsSignedEvent(t *testing.T) {
+	key := []byte("webhook-test-key")
+	var got []byte
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(webhook.SignatureHeader)
+	}))
+	defer server.Close()
+
+	notifier := &Webhook{Target: webhook.Target{URL: server.URL, Key: key}}
+	e := Event{Type: webhook.EventExpired, SecretID: "abc123", OccurredAt: testTime, Email: testAddress}
+	if err := notifier.Notify(context.Background(), e); err != nil {
+		t.Fatalf("Notify() error: %v", err)
+	}
+
+	if err := webhook.Verify(got, signature, key); err != nil {
+		t.Fatalf("Verify() error: %v", err)
+	}
+	payload, err := webhook.Decode(got)
+	if err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+	if payload.Event != webhook.EventExpired || payload.SecretID != "abc123" || !payload.OccurredAt.Equal(testTime) {
+		t.Errorf("payload = %+v, want the expired event", payload)
+	}
+	if bytes.Contains(got, []byte(testAddress)) {
+		t.Errorf("webhook body carries the address: %s", got)
+	}
+}
+
+func TestWebhookReportsRefusal(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	notifier := &Webhook{Target: webhook.Target{URL: server.URL, Key: []byte("k")}}
+	if err := notifier.Notify(context.Background(), Event{Type: webhook.EventConsumed, OccurredAt: testTime}); err == nil {
+		t.Error("Notify() to a failing target succeeded")
+	}
+}
+
+// blockingNotifier holds every delivery until release is closed
+type blockingNotifier struct {
+	release chan struct{}
+	mu      sync.Mutex
+	events  []Event
+}
+
+func (n *blockingNotifier) Notify(ctx context.Context, e Event) error {
+	<-n.release
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, e)
+	return nil
+}
+
+func (n *blockingNotifier) delivered() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.events)
+}
+
+func TestDispatcherNeverBlocks(t *testing.T) {
+	slow := &blockingNotifier{release: make(chan struct{})}
+	fast := &blockingNotifier{release: make(chan struct{})}
+	close(fast.release)
+
+	d := NewDispatcher(2)
+	d.Add("slow", slow)
+	d.Add("fast", fast)
+	d.Start(context.Background())
+
+	before := dropped.Counts()["notification/overflow"]
+	start := time.Now()
+	for i := range 10 {
+		d.Enqueue(Event{Type: webhook.EventConsumed, OccurredAt: testTime})
+		// The healthy channel keeps up; the stuck one takes only the first
+		// event off its queue
+		for len(d.lanes[1].queue) > 0 || i == 0 && len(d.lanes[0].queue) > 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Enqueue() blocked for %v behind a stuck channel", elapsed)
+	}
+
+	// The stuck channel holds one delivery and queues two; the rest drop
+	if got := dropped.Counts()["notification/overflow"] - before; got != 7 {
+		t.Errorf("overflow drops = %d, want 7", got)
+	}
+
+	close(slow.release)
+	d.Stop()
+	if got := slow.delivered(); got != 3 {
+		t.Errorf("slow channel delivered %d, want 3", got)
+	}
+	if got := fast.delivered(); got != 10 {
+		t.Errorf("fast channel delivered %d, want all 10", got)
+	}
+
+	// Stopped, it refuses new events without panicking
+	d.Enqueue(Event{Type: webhook.EventConsumed, OccurredAt: testTime})
+}
+
+func TestServiceSealsAndSends(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, keySize)
+	recorder := &blockingNotifier{release: make(chan struct{})}
+	close(recorder.release)
+	d := NewDispatcher(10)
+	d.Add("record", recorder)
+	service := NewService(key, d)
+	service.Start(context.Background())
+
+	sealed, hash, err := service.Seal(testAddress)
+	if err != nil {
+		t.Fatalf("Seal() error: %v", err)
+	}
+	if bytes.Contains(sealed, []byte(testAddress)) || hash == "" {
+		t.Fatalf("Seal() = %x, %q; want the address encrypted and hashed", []byte(sealed), hash)
+	}
+
+	service.Send(webhook.EventConsumed, "abc123", testTime, sealed)
+	service.Stop()
+	if len(recorder.events) != 1 || recorder.events[0].Email != testAddress || recorder.events[0].SecretID != "abc123" {
+		t.Fatalf("delivered %+v, want one event for the opened address", recorder.events)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	if _, err := ParseKey("c2hvcnQ="); err == nil {
+		t.Error("ParseKey() accepted a short key")
+	}
+	if _, err := ParseKey("not base64!"); err == nil {
+		t.Error("ParseKey() accepted invalid base64")
+	}
+	if _, err := ParseKey("QkJCQkJCQkJCQkJCQkJCQkJCQkJCQkJCQkJCQkJCQkI="); err != nil {
+		t.Errorf("ParseKey() error: %v", err)
+	}
+}
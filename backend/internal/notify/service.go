@@ -0,0 +1,134 @@
+package notify
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/mail"
+	"time"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/crypto"
+	"ots-backend/internal/dropped"
+	"ots-backend/internal/logger"
+	"ots-backend/internal/sensitive"
+	"ots-backend/internal/store"
+	"ots-backend/pkg/webhook"
+)
+
+// keySize is the length of NOTIFY_EMAIL_KEY, an AES-256 key
+const keySize = 32
+
+// Service seals notify addresses for storage and sends notices for stored
+// secrets through a Dispatcher
+type Service struct {
+	key        sensitive.Bytes
+	dispatcher *Dispatcher
+}
+
+// NewService creates a service sealing addresses under key and sending
+// through d
+func NewService(key []byte, d *Dispatcher) *Service {
+	return &Service{key: key, dispatcher: d}
+}
+
+// ParseKey decodes a base64 NOTIFY_EMAIL_KEY, which must be 32 bytes
+func ParseKey(value string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("NOTIFY_EMAIL_KEY is not base64: %w", err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("NOTIFY_EMAIL_KEY is %d bytes, want %d", len(key), keySize)
+	}
+	return key, nil
+}
+
+// CheckConfig returns every problem with the notification settings in cfg
+func CheckConfig(cfg *config.Config) []string {
+	var problems []string
+	if _, err := ParseKey(cfg.NotifyEmailKey); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if cfg.SMTPAddr == "" && cfg.NotifyWebhookURL == "" {
+		problems = append(problems, "NOTIFY_EMAIL_KEY requires SMTP_ADDR or NOTIFY_WEBHOOK_URL")
+	}
+	if cfg.SMTPAddr != "" {
+		if _, err := mail.ParseAddress(cfg.SMTPFrom); err != nil {
+			problems = append(problems, "SMTP_ADDR requires SMTP_FROM, a sender address")
+		}
+	}
+	if cfg.NotifyWebhookURL != "" && cfg.NotifyWebhookKey == "" {
+		problems = append(problems, "NOTIFY_WEBHOOK_URL requires NOTIFY_WEBHOOK_KEY to sign deliveries")
+	}
+	return problems
+}
+
+// FromConfig builds the service cfg describes, with an SMTP channel when
+// SMTP_ADDR is set and a webhook channel when NOTIFY_WEBHOOK_URL is. It
+// returns nil without NOTIFY_EMAIL_KEY.
+func FromConfig(cfg *config.Config) (*Service, error) {
+	if cfg.NotifyEmailKey == "" {
+		return nil, nil
+	}
+	if problems := CheckConfig(cfg); len(problems) > 0 {
+		return nil, errors.New(problems[0])
+	}
+	key, _ := ParseKey(cfg.NotifyEmailKey)
+
+	d := NewDispatcher(cfg.NotifyQueueSize)
+	if cfg.SMTPAddr != "" {
+		d.Add("smtp", &SMTP{
+			Addr:       cfg.SMTPAddr,
+			From:       cfg.SMTPFrom,
+			Username:   cfg.SMTPUsername,
+			Password:   cfg.SMTPPassword,
+			RequireTLS: cfg.SMTPRequireTLS,
+		})
+	}
+	if cfg.NotifyWebhookURL != "" {
+		d.Add("webhook", &Webhook{Target: webhook.Target{URL: cfg.NotifyWebhookURL, Key: []byte(cfg.NotifyWebhookKey)}})
+	}
+	return NewService(key, d), nil
+}
+
+// Seal encrypts address for Secret.NotifyEmail and hashes it for
+// Secret.NotifyEmailHash
+func (s *Service) Seal(address sensitive.String) (sensitive.Bytes, string, error) {
+	sealed, err := crypto.WrapWithKey([]byte(address), s.key)
+	if err != nil {
+		return nil, "", fmt.Errorf("seal notify address: %w", err)
+	}
+	return sealed, store.HashNotifyEmail(string(address)), nil
+}
+
+// Send queues a notice of eventType for the secret id with the sealed
+// address. It never blocks; an address that will not open is logged and
+// dropped.
+func (s *Service) Send(eventType, id string, at time.Time, sealed sensitive.Bytes) {
+	address, err := crypto.UnwrapWithDataKey(sealed, s.key)
+	if err != nil {
+		dropped.Record(dropped.KindNotification, dropped.ReasonFailed)
+		logger.Warn("failed to open notify address", "event", eventType, "secret_id", id, "error", err)
+		return
+	}
+	s.dispatcher.Enqueue(Event{Type: eventType, SecretID: id, OccurredAt: at, Email: sensitive.String(address)})
+}
+
+// Announce queues an event of eventType for the secret id with no address,
+// for events the operator hears of whether or not the creator asked. Only
+// channels that do not mail, the webhook, deliver it. It never blocks.
+func (s *Service) Announce(eventType, id string, at time.Time) {
+	s.dispatcher.Enqueue(Event{Type: eventType, SecretID: id, OccurredAt: at})
+}
+
+// Start begins delivering queued notices until ctx is done
+func (s *Service) Start(ctx context.Context) {
+	s.dispatcher.Start(ctx)
+}
+
+// Stop delivers what is queued, waiting briefly, and refuses new notices
+func (s *Service) Stop() {
+	s.dispatcher.Stop()
+}
@@ -0,0 +1,165 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"text/template"
+	"time"
+
+	"ots-backend/pkg/webhook"
+)
+
+// ErrNoTLS is returned when RequireTLS is set and the server does not offer
+// STARTTLS
+var ErrNoTLS = errors.New("smtp server does not offer STARTTLS")
+
+// subjects are the email subject per event type
+var subjects = map[string]string{
+	webhook.EventConsumed: "Your secret was read",
+	webhook.EventBurned:   "Your secret was burned",
+	webhook.EventExpired:  "Your secret expired unread",
+}
+
+// emailBody is the whole text of a notice. It names the event and its time
+// and nothing else: not the secret, its link or who read it.
+var emailBody = template.Must(template.New("notice").Parse(`{{if eq .Type "secret.expired" -}}
+A secret you shared expired at {{.At}} without being read. It has been deleted.
+{{- else if eq .Type "secret.burned" -}}
+A secret you shared was burned at {{.At}} without being read. It has been deleted.
+{{- else -}}
+A secret you shared was read at {{.At}}. It has been deleted.
+{{- end}}
+
+You get this message because the secret was created with this address to
+notify. This mailbox is not monitored.
+`))
+
+// SMTP mails each event to its address through one SMTP server
+type SMTP struct {
+	// Addr is the server's host:port
+	Addr string
+	// From is the sender address
+	From string
+	// Username and Password authenticate with PLAIN when Username is set
+	Username string
+	Password string
+	// RequireTLS refuses to send over a connection STARTTLS did not secure
+	RequireTLS bool
+	// TLSConfig is used for STARTTLS; nil verifies the server's host name
+	TLSConfig *tls.Config
+}
+
+// Notify mails e to e.Email. Events without an address are skipped.
+func (s *SMTP) Notify(ctx context.Context, e Event) error {
+	if e.Email == "" {
+		return nil
+	}
+	msg, err := s.message(e)
+	if err != nil {
+		return err
+	}
+
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return fmt.Errorf("smtp address: %w", err)
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return fmt.Errorf("dial smtp: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return smtpError("greeting", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		config := s.TLSConfig
+		if config == nil {
+			config = &tls.Config{ServerName: host}
+		}
+		if err := client.StartTLS(config); err != nil {
+			return smtpError("starttls", err)
+		}
+	} else if s.RequireTLS {
+		return ErrNoTLS
+	}
+
+	if s.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return smtpError("auth", err)
+		}
+	}
+	if err := client.Mail(s.From); err != nil {
+		return smtpError("mail", err)
+	}
+	if err := client.Rcpt(string(e.Email)); err != nil {
+		return smtpError("rcpt", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return smtpError("data", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return smtpError("data", err)
+	}
+	if err := w.Close(); err != nil {
+		return smtpError("data", err)
+	}
+	if err := client.Quit(); err != nil {
+		return smtpError("quit", err)
+	}
+	return nil
+}
+
+// message renders the headers and body of the notice for e
+func (s *SMTP) message(e Event) ([]byte, error) {
+	subject, ok := subjects[e.Type]
+	if !ok {
+		return nil, fmt.Errorf("no email for event %q", e.Type)
+	}
+
+	var body bytes.Buffer
+	err := emailBody.Execute(&body, struct{ Type, At string }{
+		Type: e.Type,
+		At:   e.OccurredAt.UTC().Format(time.RFC1123),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("render email: %w", err)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", (&mail.Address{Address: s.From}).String())
+	fmt.Fprintf(&msg, "To: %s\r\n", (&mail.Address{Address: string(e.Email)}).String())
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().UTC().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Auto-Submitted: auto-generated\r\n")
+	msg.WriteString("\r\n")
+	msg.Write(bytes.ReplaceAll(body.Bytes(), []byte("\n"), []byte("\r\n")))
+	return msg.Bytes(), nil
+}
+
+// smtpError reports a failed step by its status code alone. Servers echo
+// the recipient in their replies, and the address must not reach the logs.
+func smtpError(step string, err error) error {
+	var reply *textproto.Error
+	if errors.As(err, &reply) {
+		return fmt.Errorf("smtp %s: status %d", step, reply.Code)
+	}
+	return fmt.Errorf("smtp %s: %w", step, err)
+}
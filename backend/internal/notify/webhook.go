@@ -0,0 +1,58 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"ots-backend/internal/httpx"
+	"ots-backend/internal/ulid"
+	"ots-backend/pkg/webhook"
+)
+
+// Webhook posts each event, signed, to one webhook target. The payload is
+// the usual webhook schema and never carries the address.
+type Webhook struct {
+	Target webhook.Target
+	// Client sends the requests; nil uses http.DefaultClient
+	Client *http.Client
+}
+
+// Notify posts e to the target once per schema version it subscribes to
+func (w *Webhook) Notify(ctx context.Context, e Event) error {
+	deliveries, err := w.Target.Deliveries(webhook.Event{
+		ID:         ulid.New(e.OccurredAt),
+		Type:       e.Type,
+		SecretID:   e.SecretID,
+		OccurredAt: e.OccurredAt,
+	}, time.Now())
+	if err != nil {
+		return err
+	}
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	for _, d := range deliveries {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.Target.URL, bytes.NewReader(d.Body))
+		if err != nil {
+			return fmt.Errorf("webhook request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		httpx.SetHeader(req.Header, webhook.SignatureHeader, d.Signature)
+		httpx.SetHeader(req.Header, webhook.SchemaHeader, d.Schema)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("post webhook: %w", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("post webhook: status %d", resp.StatusCode)
+		}
+	}
+	return nil
+}
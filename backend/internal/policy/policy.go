@@ -0,0 +1,125 @@
+// Package policy owns every externally visible limit. Validators, error
+// bodies, discovery endpoints and the API spec all render from one Policy
+// so a limit changes in exactly one place.
+package policy
+
+import (
+	"time"
+
+	"ots-backend/internal/config"
+)
+
+// Built-in limits used when config does not override them
+const (
+	DefaultMaxSecretSize      = 32768 // 32KB
+	DefaultMinSecretSize      = 1
+	DefaultMinTTL             = 5 * time.Minute
+	DefaultMaxTTL             = 24 * time.Hour
+	DefaultMaxParts           = 10
+	DefaultMaxPartLabel       = 64
+	DefaultMaxHint            = 140
+	DefaultMaxDeclaredKeyBits = 4096
+)
+
+// Policies for creates that do not declare their key length
+const (
+	KeyBitsMissingAllow  = "allow"
+	KeyBitsMissingWarn   = "warn"
+	KeyBitsMissingReject = "reject"
+)
+
+// Rate limit scopes
+const (
+	ScopeWrite = "write"
+	ScopeRead  = "read"
+	ScopeAgent = "agent"
+)
+
+// RateLimit is the request budget for one scope
+type RateLimit struct {
+	Scope    string
+	Requests int
+	Window   time.Duration
+}
+
+// Policy is the resolved set of limits for one configuration
+type Policy struct {
+	MaxSecretSize      int
+	MinSecretSize      int
+	MinTTL             time.Duration
+	MaxTTL             time.Duration
+	DefaultTTL         time.Duration
+	AgentDefaultTTL    time.Duration
+	MaxParts           int
+	MaxPartLabel       int
+	MaxHint            int
+	MinKeyBits         int
+	MaxDeclaredKeyBits int
+	KeyBitsMissing     string
+	// SlugsAllowed lets creates choose their own ID
+	SlugsAllowed bool
+	// NotifyEmailAllowed lets creates ask to be told of reads and expiries
+	NotifyEmailAllowed bool
+	// ActiveSecretQuota caps the live secrets one client IP may hold; zero
+	// for none
+	ActiveSecretQuota int
+	RateLimits        []RateLimit
+}
+
+// FromConfig resolves the policy for cfg, filling built-in defaults
+func FromConfig(cfg *config.Config) *Policy {
+	p := &Policy{
+		MaxSecretSize:      cfg.MaxSecretSize,
+		MinSecretSize:      DefaultMinSecretSize,
+		MinTTL:             DefaultMinTTL,
+		MaxTTL:             DefaultMaxTTL,
+		DefaultTTL:         cfg.DefaultTTL,
+		AgentDefaultTTL:    cfg.AgentDefaultTTL,
+		MaxParts:           DefaultMaxParts,
+		MaxPartLabel:       DefaultMaxPartLabel,
+		MaxHint:            DefaultMaxHint,
+		MinKeyBits:         max(cfg.MinKeyBits, 0),
+		MaxDeclaredKeyBits: DefaultMaxDeclaredKeyBits,
+		KeyBitsMissing:     cfg.KeyBitsMissing,
+		SlugsAllowed:       cfg.AllowSlugs,
+		NotifyEmailAllowed: cfg.NotifyEmailKey != "",
+		ActiveSecretQuota:  cfg.MaxActiveSecretsPerIP,
+		RateLimits: []RateLimit{
+			{Scope: ScopeWrite, Requests: cfg.WriteRateLimitRequests, Window: cfg.WriteRateLimitWindow},
+			{Scope: ScopeRead, Requests: cfg.ReadRateLimitRequests, Window: cfg.ReadRateLimitWindow},
+			{Scope: ScopeAgent, Requests: cfg.AgentRateLimitRequests, Window: cfg.AgentRateLimitWindow},
+		},
+	}
+
+	if p.MaxSecretSize <= 0 {
+		p.MaxSecretSize = DefaultMaxSecretSize
+	}
+
+	// The ceiling never drops below the floor
+	if cfg.MaxTTL > 0 {
+		p.MaxTTL = max(cfg.MaxTTL, p.MinTTL)
+	}
+
+	switch p.KeyBitsMissing {
+	case KeyBitsMissingAllow, KeyBitsMissingWarn, KeyBitsMissingReject:
+	default:
+		p.KeyBitsMissing = KeyBitsMissingAllow
+	}
+
+	return p
+}
+
+// Default returns the policy for an empty configuration
+func Default() *Policy {
+	return FromConfig(&config.Config{})
+}
+
+// RateLimit returns the budget for scope
+func (p *Policy) RateLimit(scope string) RateLimit {
+	for _, rl := range p.RateLimits {
+		if rl.Scope == scope {
+			return rl
+		}
+	}
+	return RateLimit{Scope: scope}
+}
@@ -0,0 +1,269 @@
+package policy_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"ots-backend/internal/config"
+	"ots-backend/internal/models"
+	"ots-backend/internal/policy"
+	"ots-backend/internal/validation"
+)
+
+// configMatrix covers defaults, overrides and out-of-range inputs
+func configMatrix() map[string]*config.Config {
+	return map[string]*config.Config{
+		"empty": {},
+		"defaults": {
+			MaxSecretSize:          32768,
+			DefaultTTL:             time.Hour,
+			AgentDefaultTTL:        24 * time.Hour,
+			WriteRateLimitRequests: 30,
+			WriteRateLimitWindow:   time.Minute,
+			ReadRateLimitRequests:  180,
+			ReadRateLimitWindow:    time.Minute,
+			AgentRateLimitRequests: 10,
+			AgentRateLimitWindow:   time.Minute,
+		},
+		"small secrets, strict keys": {
+			MaxSecretSize:  1024,
+			MinKeyBits:     256,
+			KeyBitsMissing: policy.KeyBitsMissingReject,
+		},
+		"large secrets, warn": {
+			MaxSecretSize:          1 << 20,
+			MinKeyBits:             128,
+			KeyBitsMissing:         policy.KeyBitsMissingWarn,
+			WriteRateLimitRequests: 5,
+			WriteRateLimitWindow:   10 * time.Second,
+		},
+		"short ttl ceiling": {
+			MaxTTL: 2 * time.Hour,
+		},
+		"invalid values": {
+			MaxSecretSize:  -1,
+			MinKeyBits:     -64,
+			KeyBitsMissing: "sometimes",
+		},
+	}
+}
+
+func TestFromConfigMaxTTL(t *testing.T) {
+	for _, tt := range []struct {
+		configured, want time.Duration
+	}{
+		{0, policy.DefaultMaxTTL},
+		{2 * time.Hour, 2 * time.Hour},
+		{7 * 24 * time.Hour, 7 * 24 * time.Hour},
+		{time.Minute, policy.DefaultMinTTL},
+	} {
+		if got := policy.FromConfig(&config.Config{MaxTTL: tt.configured}).MaxTTL; got != tt.want {
+			t.Errorf("MaxTTL for MAX_TTL=%v = %v, want %v", tt.configured, got, tt.want)
+		}
+	}
+}
+
+func TestFromConfigNormalizes(t *testing.T) {
+	p := policy.FromConfig(&config.Config{MaxSecretSize: -1, MinKeyBits: -64, KeyBitsMissing: "sometimes"})
+
+	if p.MaxSecretSize != policy.DefaultMaxSecretSize {
+		t.Errorf("MaxSecretSize = %d, want %d", p.MaxSecretSize, policy.DefaultMaxSecretSize)
+	}
+	if p.MinKeyBits != 0 {
+		t.Errorf("MinKeyBits = %d, want 0", p.MinKeyBits)
+	}
+	if p.KeyBitsMissing != policy.KeyBitsMissingAllow {
+		t.Errorf("KeyBitsMissing = %q, want %q", p.KeyBitsMissing, policy.KeyBitsMissingAllow)
+	}
+}
+
+// TestSurfacesDoNotDrift checks that every rendered surface reports the
+// same values as the policy it was rendered from
+func TestSurfacesDoNotDrift(t *testing.T) {
+	for name, cfg := range configMatrix() {
+		t.Run(name, func(t *testing.T) {
+			p := policy.FromConfig(cfg)
+
+			checkDetails(t, p)
+			checkConfigPayload(t, p)
+			checkLimitsPayload(t, p)
+			checkOpenAPI(t, p)
+			checkValidatorMessages(t, p)
+		})
+	}
+}
+
+func checkDetails(t *testing.T, p *policy.Policy) {
+	t.Helper()
+
+	want := map[string][2]int64{
+		policy.LimitSecretSize: {int64(p.MinSecretSize), int64(p.MaxSecretSize)},
+		policy.LimitTTL:        {int64(p.MinTTL / time.Second), int64(p.MaxTTL / time.Second)},
+		policy.LimitParts:      {1, int64(p.MaxParts)},
+		policy.LimitPartLabel:  {1, int64(p.MaxPartLabel)},
+		policy.LimitHint:       {0, int64(p.MaxHint)},
+		policy.LimitKeyBits:    {int64(max(p.MinKeyBits, 1)), int64(p.MaxDeclaredKeyBits)},
+	}
+
+	details := p.Details()
+	if len(details) != len(want) {
+		t.Fatalf("Details() returned %d limits, want %d", len(details), len(want))
+	}
+
+	for _, d := range details {
+		bounds, ok := want[d.Name]
+		if !ok {
+			t.Errorf("unexpected limit %q", d.Name)
+			continue
+		}
+		if d.Min != bounds[0] || d.Max != bounds[1] {
+			t.Errorf("%s = [%d, %d], want [%d, %d]", d.Name, d.Min, d.Max, bounds[0], bounds[1])
+		}
+		if got := p.Detail(d.Name); got == nil || *got != d {
+			t.Errorf("Detail(%q) = %+v, want %+v", d.Name, got, d)
+		}
+	}
+}
+
+func checkConfigPayload(t *testing.T, p *policy.Policy) {
+	t.Helper()
+
+	var got map[string]any
+	roundTrip(t, p.Config(), &got)
+
+	want := map[string]any{
+		"max_secret_size":           float64(p.MaxSecretSize),
+		"min_ttl_seconds":           p.MinTTL.Seconds(),
+		"max_ttl_seconds":           p.MaxTTL.Seconds(),
+		"default_ttl_seconds":       p.DefaultTTL.Seconds(),
+		"agent_default_ttl_seconds": p.AgentDefaultTTL.Seconds(),
+		"max_parts":                 float64(p.MaxParts),
+		"min_key_bits":              float64(p.MinKeyBits),
+		"key_bits_missing":          p.KeyBitsMissing,
+		"passphrase_supported":      true,
+		"max_views_supported":       false,
+		"slugs_supported":           false,
+		"notify_email_supported":    false,
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("config %s = %v, want %v", key, got[key], value)
+		}
+	}
+}
+
+func checkLimitsPayload(t *testing.T, p *policy.Policy) {
+	t.Helper()
+
+	var got policy.LimitsPayload
+	roundTrip(t, p.Limits(), &got)
+
+	for _, d := range got.Limits {
+		if want := p.Detail(d.Name); want == nil || *want != d {
+			t.Errorf("limits %s = %+v, want %+v", d.Name, d, want)
+		}
+	}
+
+	if len(got.RateLimits) != len(p.RateLimits) {
+		t.Fatalf("limits reported %d rate limits, want %d", len(got.RateLimits), len(p.RateLimits))
+	}
+	for _, rl := range got.RateLimits {
+		want := p.RateLimit(rl.Scope)
+		if rl.Requests != want.Requests || rl.WindowSeconds != int64(want.Window/time.Second) {
+			t.Errorf("rate limit %s = %d/%ds, want %d/%v", rl.Scope, rl.Requests, rl.WindowSeconds, want.Requests, want.Window)
+		}
+	}
+}
+
+func checkOpenAPI(t *testing.T, p *policy.Policy) {
+	t.Helper()
+
+	var schemas map[string]struct {
+		Properties map[string]map[string]any `json:"properties"`
+	}
+	roundTrip(t, p.OpenAPIComponents(), &schemas)
+
+	create := schemas["CreateSecretRequest"].Properties
+	part := schemas["SecretPart"].Properties
+
+	checks := []struct {
+		name string
+		got  any
+		want float64
+	}{
+		{"ciphertext.maxLength", create["ciphertext"]["maxLength"], float64(base64.StdEncoding.EncodedLen(p.MaxSecretSize))},
+		{"expires_in.minimum", create["expires_in"]["minimum"], p.MinTTL.Seconds()},
+		{"expires_in.maximum", create["expires_in"]["maximum"], p.MaxTTL.Seconds()},
+		{"parts.maxItems", create["parts"]["maxItems"], float64(p.MaxParts)},
+		{"declared_key_bits.minimum", create["declared_key_bits"]["minimum"], float64(p.Detail(policy.LimitKeyBits).Min)},
+		{"declared_key_bits.maximum", create["declared_key_bits"]["maximum"], float64(p.MaxDeclaredKeyBits)},
+		{"label.maxLength", part["label"]["maxLength"], float64(p.MaxPartLabel)},
+		{"hint.maxLength", create["hint"]["maxLength"], float64(p.MaxHint)},
+		{"part ciphertext.maxLength", part["ciphertext"]["maxLength"], float64(base64.StdEncoding.EncodedLen(p.MaxSecretSize))},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("openapi %s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+// checkValidatorMessages drives each validator just past its limit and
+// checks the message quotes the policy value
+func checkValidatorMessages(t *testing.T, p *policy.Policy) {
+	t.Helper()
+
+	tooLarge := base64.StdEncoding.EncodeToString(make([]byte, p.MaxSecretSize+1))
+	iv := base64.StdEncoding.EncodeToString(make([]byte, 12))
+	_, err := validation.ValidateCreateRequest(tooLarge, iv, "", 3600, p)
+	expectMessage(t, err, validation.ErrSecretTooLarge, fmt.Sprintf("(max %d)", p.MaxSecretSize))
+
+	_, err = validation.ValidateTTL(int((p.MaxTTL+time.Second)/time.Second), p)
+	expectMessage(t, err, validation.ErrInvalidTTL, fmt.Sprintf("between %v and %v", p.MinTTL, p.MaxTTL))
+
+	parts := make([]models.SecretPart, p.MaxParts+1)
+	_, err = validation.ValidateMultipartRequest("", "", "", parts, 3600, p)
+	expectMessage(t, err, validation.ErrInvalidParts, fmt.Sprintf("(max %d)", p.MaxParts))
+
+	_, err = validation.ValidateHint(strings.Repeat("x", p.MaxHint+1), p)
+	expectMessage(t, err, validation.ErrInvalidHint, fmt.Sprintf("(max %d)", p.MaxHint))
+
+	bits := p.MaxDeclaredKeyBits + 1
+	_, err = validation.ValidateDeclaredKeyBits(&bits, p)
+	expectMessage(t, err, validation.ErrInvalidKeyBits, fmt.Sprintf("and %d", p.MaxDeclaredKeyBits))
+
+	if p.MinKeyBits > 1 {
+		weak := p.MinKeyBits - 1
+		_, err = validation.ValidateDeclaredKeyBits(&weak, p)
+		expectMessage(t, err, validation.ErrKeyTooWeak, fmt.Sprintf("minimum is %d", p.MinKeyBits))
+	}
+}
+
+func expectMessage(t *testing.T, err, target error, fragment string) {
+	t.Helper()
+
+	if !errors.Is(err, target) {
+		t.Errorf("error = %v, want %v", err, target)
+		return
+	}
+	if !strings.Contains(err.Error(), fragment) {
+		t.Errorf("error %q does not mention %q", err, fragment)
+	}
+}
+
+func roundTrip(t *testing.T, in, out any) {
+	t.Helper()
+
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+}
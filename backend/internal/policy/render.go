@@ -0,0 +1,147 @@
+package policy
+
+import (
+	"encoding/base64"
+	"time"
+)
+
+// Limit names shared by every surface
+const (
+	LimitSecretSize = "secret_size"
+	LimitTTL        = "ttl"
+	LimitParts      = "parts"
+	LimitPartLabel  = "part_label"
+	LimitHint       = "hint"
+	LimitKeyBits    = "declared_key_bits"
+)
+
+// LimitDetail describes one limit. It is embedded in validation errors and
+// listed by the limits endpoint.
+type LimitDetail struct {
+	Name string `json:"name"`
+	Min  int64  `json:"min"`
+	Max  int64  `json:"max"`
+	Unit string `json:"unit"`
+}
+
+// Details lists every limit in a stable order
+func (p *Policy) Details() []LimitDetail {
+	return []LimitDetail{
+		{Name: LimitSecretSize, Min: int64(p.MinSecretSize), Max: int64(p.MaxSecretSize), Unit: "bytes"},
+		{Name: LimitTTL, Min: seconds(p.MinTTL), Max: seconds(p.MaxTTL), Unit: "seconds"},
+		{Name: LimitParts, Min: 1, Max: int64(p.MaxParts), Unit: "parts"},
+		{Name: LimitPartLabel, Min: 1, Max: int64(p.MaxPartLabel), Unit: "characters"},
+		{Name: LimitHint, Min: 0, Max: int64(p.MaxHint), Unit: "characters"},
+		{Name: LimitKeyBits, Min: int64(max(p.MinKeyBits, 1)), Max: int64(p.MaxDeclaredKeyBits), Unit: "bits"},
+	}
+}
+
+// Detail returns the named limit, or nil if there is no such limit
+func (p *Policy) Detail(name string) *LimitDetail {
+	for _, d := range p.Details() {
+		if d.Name == name {
+			return &d
+		}
+	}
+	return nil
+}
+
+// ConfigPayload is the client-facing view of the limits a create must respect
+type ConfigPayload struct {
+	MaxSecretSize          int    `json:"max_secret_size"`
+	MinTTLSeconds          int64  `json:"min_ttl_seconds"`
+	MaxTTLSeconds          int64  `json:"max_ttl_seconds"`
+	DefaultTTLSeconds      int64  `json:"default_ttl_seconds"`
+	AgentDefaultTTLSeconds int64  `json:"agent_default_ttl_seconds"`
+	MaxParts               int    `json:"max_parts"`
+	MinKeyBits             int    `json:"min_key_bits"`
+	KeyBitsMissing         string `json:"key_bits_missing"`
+	PassphraseSupported    bool   `json:"passphrase_supported"`
+	MaxViewsSupported      bool   `json:"max_views_supported"`
+	SlugsSupported         bool   `json:"slugs_supported"`
+	NotifyEmailSupported   bool   `json:"notify_email_supported"`
+}
+
+// Config renders the config endpoint payload
+func (p *Policy) Config() ConfigPayload {
+	return ConfigPayload{
+		MaxSecretSize:          p.MaxSecretSize,
+		MinTTLSeconds:          seconds(p.MinTTL),
+		MaxTTLSeconds:          seconds(p.MaxTTL),
+		DefaultTTLSeconds:      seconds(p.DefaultTTL),
+		AgentDefaultTTLSeconds: seconds(p.AgentDefaultTTL),
+		MaxParts:               p.MaxParts,
+		MinKeyBits:             p.MinKeyBits,
+		KeyBitsMissing:         p.KeyBitsMissing,
+		// Passphrases are always available: the browser derives the key from
+		// one with the salt, the agent endpoint wraps with one server-side
+		PassphraseSupported: true,
+		// Every secret is single-view
+		MaxViewsSupported:    false,
+		SlugsSupported:       p.SlugsAllowed,
+		NotifyEmailSupported: p.NotifyEmailAllowed,
+	}
+}
+
+// RateLimitPayload is one rate limit as reported to clients
+type RateLimitPayload struct {
+	Scope         string `json:"scope"`
+	Requests      int    `json:"requests"`
+	WindowSeconds int64  `json:"window_seconds"`
+}
+
+// LimitsPayload is the limits endpoint body
+type LimitsPayload struct {
+	Limits     []LimitDetail      `json:"limits"`
+	RateLimits []RateLimitPayload `json:"rate_limits"`
+}
+
+// Limits renders the limits endpoint payload
+func (p *Policy) Limits() LimitsPayload {
+	payload := LimitsPayload{Limits: p.Details()}
+	for _, rl := range p.RateLimits {
+		payload.RateLimits = append(payload.RateLimits, RateLimitPayload{
+			Scope:         rl.Scope,
+			Requests:      rl.Requests,
+			WindowSeconds: seconds(rl.Window),
+		})
+	}
+	return payload
+}
+
+// base64Input describes binary request fields; responses use standard
+// padded base64 whatever the client sent
+const base64Input = "Standard or URL-safe base64, padding optional"
+
+// OpenAPIComponents renders the limit-bearing schemas for the API spec
+func (p *Policy) OpenAPIComponents() map[string]any {
+	return map[string]any{
+		"SecretPart": map[string]any{
+			"type":     "object",
+			"required": []string{"label", "ciphertext", "iv"},
+			"properties": map[string]any{
+				"label":      map[string]any{"type": "string", "minLength": 1, "maxLength": p.MaxPartLabel},
+				"ciphertext": map[string]any{"type": "string", "format": "byte", "description": base64Input, "maxLength": base64.StdEncoding.EncodedLen(p.MaxSecretSize)},
+				"iv":         map[string]any{"type": "string", "format": "byte", "description": base64Input},
+			},
+		},
+		"CreateSecretRequest": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"ciphertext":        map[string]any{"type": "string", "format": "byte", "description": base64Input, "maxLength": base64.StdEncoding.EncodedLen(p.MaxSecretSize)},
+				"iv":                map[string]any{"type": "string", "format": "byte", "description": base64Input},
+				"salt":              map[string]any{"type": "string", "format": "byte", "description": base64Input},
+				"expires_in":        map[string]any{"type": "integer", "minimum": seconds(p.MinTTL), "maximum": seconds(p.MaxTTL)},
+				"burn_after_read":   map[string]any{"type": "boolean"},
+				"require_ack":       map[string]any{"type": "boolean"},
+				"parts":             map[string]any{"type": "array", "minItems": 1, "maxItems": p.MaxParts, "items": map[string]any{"$ref": "#/components/schemas/SecretPart"}},
+				"declared_key_bits": map[string]any{"type": "integer", "minimum": max(p.MinKeyBits, 1), "maximum": p.MaxDeclaredKeyBits},
+				"hint":              map[string]any{"type": "string", "maxLength": p.MaxHint},
+			},
+		},
+	}
+}
+
+func seconds(d time.Duration) int64 {
+	return int64(d / time.Second)
+}
@@ -0,0 +1,99 @@
+// Package reputation scores clients for adaptive rate limiting. A client
+// with a long record of successful requests earns a larger allowance, up to
+// a ceiling; one whose traffic is heavy with client errors, such as invalid
+// IDs or failed validation, sinks toward a floor. Without traffic a score
+// decays back to neutral, so neither lasts forever.
+//
+// The score math is pure and lives in this file. Tracker applies it to live
+// traffic and shares the scores between replicas through the store.
+package reputation
+
+import (
+	"math"
+	"net/http"
+	"time"
+)
+
+// Neutral is the score of a client without history; it keeps the base limit
+const Neutral = 0.5
+
+const (
+	// successGain is the share of its distance to 1 a success closes
+	successGain = 0.01
+	// errorPenalty is the share of its distance to 0 a client error closes.
+	// Errors outweigh successes five to one, so a client settles below
+	// Neutral once more than a sixth of its responses are client errors.
+	errorPenalty = 0.05
+)
+
+// Outcome is what one response says about the client that caused it
+type Outcome int
+
+const (
+	// Ignored responses say nothing about the client: server errors and the
+	// limiter's own 429s, which a bursty but honest client also gets
+	Ignored Outcome = iota
+	// Success is any 1xx, 2xx or 3xx response
+	Success
+	// ClientError is any other 4xx response
+	ClientError
+)
+
+// Classify returns the outcome of a response with status
+func Classify(status int) Outcome {
+	switch {
+	case status == http.StatusTooManyRequests || status >= 500:
+		return Ignored
+	case status >= 400:
+		return ClientError
+	default:
+		return Success
+	}
+}
+
+// Decay returns score after elapsed without requests: its distance from
+// Neutral halves every halfLife
+func Decay(score float64, elapsed, halfLife time.Duration) float64 {
+	if elapsed <= 0 || halfLife <= 0 {
+		return score
+	}
+	return Neutral + (score-Neutral)*math.Exp2(-elapsed.Seconds()/halfLife.Seconds())
+}
+
+// Update returns score after elapsed quiet time followed by one response
+// with outcome
+func Update(score float64, elapsed, halfLife time.Duration, outcome Outcome) float64 {
+	score = Decay(score, elapsed, halfLife)
+	switch outcome {
+	case Success:
+		score += successGain * (1 - score)
+	case ClientError:
+		score -= errorPenalty * score
+	}
+	return score
+}
+
+// Limits bound how far a score moves a client's limit
+type Limits struct {
+	// Ceiling multiplies the base limit for a score of 1
+	Ceiling float64
+	// Floor multiplies the base limit for a score of 0
+	Floor float64
+}
+
+// Factor returns the multiplier for score: Floor at 0, 1 at Neutral and
+// Ceiling at 1, linear in between
+func Factor(score float64, limits Limits) float64 {
+	if score >= Neutral {
+		return 1 + (limits.Ceiling-1)*(score-Neutral)/(1-Neutral)
+	}
+	return limits.Floor + (1-limits.Floor)*score/Neutral
+}
+
+// Allowance scales base by Factor, never below one request
+func Allowance(base int, score float64, limits Limits) int {
+	if base <= 0 {
+		return base
+	}
+	return max(int(math.Round(float64(base)*Factor(score, limits))), 1)
+}
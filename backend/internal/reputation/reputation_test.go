@@ -0,0 +1,299 @@
+package reputation
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"ots-backend/internal/store"
+	"ots-backend/internal/store/memory"
+	"ots-backend/internal/testutil"
+)
+
+const (
+	testBase     = 60
+	testHalfLife = 24 * time.Hour
+)
+
+var testLimits = Limits{Ceiling: 4, Floor: 0.25}
+
+func testConfig() Config {
+	return Config{Enabled: true, HalfLife: testHalfLife, Limits: testLimits}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		status int
+		want   Outcome
+	}{
+		{http.StatusOK, Success},
+		{http.StatusCreated, Success},
+		{http.StatusNotModified, Success},
+		{http.StatusBadRequest, ClientError},
+		{http.StatusNotFound, ClientError},
+		{http.StatusGone, ClientError},
+		{http.StatusTooManyRequests, Ignored},
+		{http.StatusInternalServerError, Ignored},
+		{http.StatusServiceUnavailable, Ignored},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.status); got != tt.want {
+			t.Errorf("Classify(%d) = %d, want %d", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestDecayHalvesTheDistanceToNeutral(t *testing.T) {
+	for _, score := range []float64{0, 0.1, 0.9, 1} {
+		got := Decay(score, testHalfLife, testHalfLife)
+		if want := Neutral + (score-Neutral)/2; math.Abs(got-want) > 1e-9 {
+			t.Errorf("Decay(%v, one half-life) = %v, want %v", score, got, want)
+		}
+		if got := Decay(score, -time.Hour, testHalfLife); got != score {
+			t.Errorf("Decay(%v, negative elapsed) = %v, want it unchanged", score, got)
+		}
+	}
+}
+
+func TestUpdateStaysInRange(t *testing.T) {
+	score := Neutral
+	for range 10000 {
+		score = Update(score, 0, testHalfLife, Success)
+	}
+	if score > 1 || score < 0.99 {
+		t.Errorf("score after many successes = %v, want just under 1", score)
+	}
+	for range 10000 {
+		score = Update(score, 0, testHalfLife, ClientError)
+	}
+	if score < 0 || score > 0.01 {
+		t.Errorf("score after many errors = %v, want just over 0", score)
+	}
+	if got := Update(0.7, 0, testHalfLife, Ignored); got != 0.7 {
+		t.Errorf("Update(ignored) = %v, want the score unchanged", got)
+	}
+}
+
+func TestAllowance(t *testing.T) {
+	tests := []struct {
+		base  int
+		score float64
+		want  int
+	}{
+		{testBase, Neutral, testBase},
+		{testBase, 1, testBase * 4},
+		{testBase, 0, testBase / 4},
+		{testBase, 0.75, 150},
+		{testBase, 0.25, 38},
+		{2, 0, 1},
+		{0, 1, 0},
+	}
+	for _, tt := range tests {
+		if got := Allowance(tt.base, tt.score, testLimits); got != tt.want {
+			t.Errorf("Allowance(%d, %v) = %d, want %d", tt.base, tt.score, got, tt.want)
+		}
+	}
+}
+
+// simulate feeds a tracker the statuses traffic returns for each hour of
+// days and returns the client's allowance at the start of every day, before
+// that day's traffic
+func simulate(tracker *Tracker, clk *testutil.FakeClock, ip string, days int, traffic func(day, hour int) []int) []int {
+	var allowances []int
+	for day := range days {
+		allowances = append(allowances, tracker.Allowance(ip, testBase))
+		for hour := range 24 {
+			for _, status := range traffic(day, hour) {
+				tracker.Observe(ip, status)
+			}
+			clk.Advance(time.Hour)
+		}
+	}
+	return allowances
+}
+
+func repeat(status, n int) []int {
+	statuses := make([]int, n)
+	for i := range statuses {
+		statuses[i] = status
+	}
+	return statuses
+}
+
+func TestSimulatedTraffic(t *testing.T) {
+	tests := []struct {
+		name    string
+		traffic func(day, hour int) []int
+		check   func(t *testing.T, allowances []int)
+	}{
+		{
+			// Ten successful requests an hour, every hour
+			name:    "good",
+			traffic: func(int, int) []int { return repeat(http.StatusOK, 10) },
+			check: func(t *testing.T, allowances []int) {
+				assertMonotonic(t, allowances, 1)
+				if last := allowances[len(allowances)-1]; last < 3*testBase {
+					t.Errorf("allowance after a week = %d, want at least %d", last, 3*testBase)
+				}
+			},
+		},
+		{
+			// Quiet all day, then an hour of a hundred reads, a third of
+			// them refused by the limiter
+			name: "bursty good",
+			traffic: func(_, hour int) []int {
+				if hour != 9 {
+					return nil
+				}
+				return append(repeat(http.StatusOK, 100), repeat(http.StatusTooManyRequests, 50)...)
+			},
+			check: func(t *testing.T, allowances []int) {
+				assertMonotonic(t, allowances, 1)
+				if allowances[1] <= 2*testBase {
+					t.Errorf("allowance the day after the first burst = %d, want over %d", allowances[1], 2*testBase)
+				}
+				if last := allowances[len(allowances)-1]; last < 2*testBase+testBase/2 {
+					t.Errorf("allowance before the last burst = %d, want at least %d", last, 2*testBase+testBase/2)
+				}
+			},
+		},
+		{
+			// A hundred requests an hour probing for IDs, nine in ten of
+			// them misses
+			name: "abusive",
+			traffic: func(int, int) []int {
+				return append(repeat(http.StatusNotFound, 90), repeat(http.StatusOK, 10)...)
+			},
+			check: func(t *testing.T, allowances []int) {
+				assertMonotonic(t, allowances, -1)
+				if allowances[1] > testBase/2 {
+					t.Errorf("allowance after a day of probing = %d, want at most %d", allowances[1], testBase/2)
+				}
+				// Decay between hours holds it a little above the floor
+				floor := int(testBase * testLimits.Floor)
+				if last := allowances[len(allowances)-1]; last < floor || last > floor+testBase/5 {
+					t.Errorf("allowance after a week = %d, want within %d of the floor %d", last, testBase/5, floor)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clk := testutil.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+			tracker := NewTracker(memory.New(), clk, testConfig)
+			allowances := simulate(tracker, clk, "203.0.113.7", 8, tt.traffic)
+			if allowances[0] != testBase {
+				t.Errorf("first allowance = %d, want the base %d", allowances[0], testBase)
+			}
+			tt.check(t, allowances)
+
+			// Gone quiet, any client drifts back to the base limit
+			clk.Advance(10 * testHalfLife)
+			if got := tracker.Allowance("203.0.113.7", testBase); got < testBase-1 || got > testBase+1 {
+				t.Errorf("allowance after ten quiet half-lives = %d, want about %d", got, testBase)
+			}
+		})
+	}
+}
+
+// assertMonotonic fails unless allowances move only in direction
+func assertMonotonic(t *testing.T, allowances []int, direction int) {
+	t.Helper()
+	for i := 1; i < len(allowances); i++ {
+		if (allowances[i]-allowances[i-1])*direction < 0 {
+			t.Fatalf("allowances %v do not move steadily in direction %d", allowances, direction)
+		}
+	}
+}
+
+func TestDisabledTrackerKeepsTheBase(t *testing.T) {
+	clk := testutil.NewFakeClock(time.Now())
+	st := memory.New()
+	tracker := NewTracker(st, clk, func() Config { return Config{HalfLife: testHalfLife, Limits: testLimits} })
+
+	for range 100 {
+		tracker.Observe("203.0.113.7", http.StatusNotFound)
+	}
+	if got := tracker.Allowance("203.0.113.7", testBase); got != testBase {
+		t.Errorf("Allowance() = %d, want the base %d", got, testBase)
+	}
+	if err := tracker.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync() error: %v", err)
+	}
+	if got := tracker.Clients(); got != 0 {
+		t.Errorf("tracked %d clients, want none", got)
+	}
+}
+
+func TestReplicasShareScores(t *testing.T) {
+	ctx := context.Background()
+	clk := testutil.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	st := memory.New()
+	first := NewTracker(st, clk, testConfig)
+	second := NewTracker(st, clk, testConfig)
+	const ip = "198.51.100.20"
+
+	for range 200 {
+		first.Observe(ip, http.StatusOK)
+	}
+	if err := first.Sync(ctx); err != nil {
+		t.Fatalf("first Sync() error: %v", err)
+	}
+	earned := first.Score(ip)
+
+	// The second replica sees the client fail once before it syncs; the
+	// failure applies on top of the shared score
+	clk.Advance(time.Minute)
+	second.Observe(ip, http.StatusNotFound)
+	if err := second.Sync(ctx); err != nil {
+		t.Fatalf("second Sync() error: %v", err)
+	}
+	merged := second.Score(ip)
+	if merged >= earned || merged < earned-0.1 {
+		t.Errorf("merged score = %v, want just under the earned %v", merged, earned)
+	}
+
+	// The first replica adopts the newer merged score
+	if err := first.Sync(ctx); err != nil {
+		t.Fatalf("first Sync() error: %v", err)
+	}
+	if got := first.Score(ip); math.Abs(got-merged) > 1e-9 {
+		t.Errorf("first replica's score = %v, want the merged %v", got, merged)
+	}
+	stored, err := st.ClientScores(ctx, []string{store.HashClientKey(ip)})
+	if err != nil || len(stored) != 1 {
+		t.Fatalf("ClientScores() = %v, %v; want the one score", stored, err)
+	}
+}
+
+func TestSyncForgetsAndPrunesIdleClients(t *testing.T) {
+	ctx := context.Background()
+	clk := testutil.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	st := memory.New()
+	tracker := NewTracker(st, clk, testConfig)
+
+	for range 50 {
+		tracker.Observe("203.0.113.7", http.StatusBadRequest)
+	}
+	if err := tracker.Sync(ctx); err != nil {
+		t.Fatalf("Sync() error: %v", err)
+	}
+	if got := tracker.Clients(); got != 1 {
+		t.Fatalf("tracked %d clients after sync, want 1", got)
+	}
+
+	clk.Advance((pruneAfterHalfLives + 1) * testHalfLife)
+	if err := tracker.Sync(ctx); err != nil {
+		t.Fatalf("Sync() error: %v", err)
+	}
+	if got := tracker.Clients(); got != 0 {
+		t.Errorf("tracked %d clients after they went quiet, want none", got)
+	}
+	lowest, err := st.LowestClientScores(ctx, 10)
+	if err != nil || len(lowest) != 0 {
+		t.Errorf("LowestClientScores() = %v, %v; want the idle score pruned", lowest, err)
+	}
+}
@@ -0,0 +1,215 @@
+package reputation
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"ots-backend/internal/clock"
+	"ots-backend/internal/logger"
+	"ots-backend/internal/store"
+)
+
+const (
+	// syncBatch is how many client keys one store lookup asks for
+	syncBatch = 500
+	// forgetWithin is how close to Neutral an idle client's score must
+	// decay before the tracker forgets it
+	forgetWithin = 0.01
+	// pruneAfterHalfLives is how long a stored score is kept without
+	// updates; by then it is within 0.4% of Neutral
+	pruneAfterHalfLives = 8
+)
+
+// Config turns adaptive limits on and sets their shape
+type Config struct {
+	Enabled  bool
+	HalfLife time.Duration
+	Limits
+}
+
+// Tracker scores clients by their responses and scales their rate limits by
+// the score. Scores live in memory and Sync exchanges them with the store,
+// so replicas converge on one score per client.
+type Tracker struct {
+	store  store.Store
+	clock  clock.Clock
+	config func() Config
+
+	mu sync.Mutex
+	// clients is keyed by store.HashClientKey
+	clients map[string]*client
+}
+
+// client is one tracked client's score as of at
+type client struct {
+	score float64
+	at    time.Time
+	// dirty marks a score not yet saved
+	dirty bool
+	// loaded marks a client whose stored score has been merged in
+	loaded bool
+}
+
+// NewTracker creates a tracker syncing with st. config is read on every
+// call, so a reload takes effect at once; while it is disabled the tracker
+// neither observes nor adjusts anything.
+func NewTracker(st store.Store, clk clock.Clock, config func() Config) *Tracker {
+	return &Tracker{
+		store:   st,
+		clock:   clk,
+		config:  config,
+		clients: make(map[string]*client),
+	}
+}
+
+// Score returns the current score of the client at ip
+func (t *Tracker) Score(ip string) float64 {
+	cfg := t.config()
+	now := t.clock.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c, ok := t.clients[store.HashClientKey(ip)]
+	if !ok {
+		return Neutral
+	}
+	return Decay(c.score, now.Sub(c.at), cfg.HalfLife)
+}
+
+// Allowance returns the limit for the client at ip in place of base
+func (t *Tracker) Allowance(ip string, base int) int {
+	cfg := t.config()
+	if !cfg.Enabled {
+		return base
+	}
+	return Allowance(base, t.Score(ip), cfg.Limits)
+}
+
+// Observe scores a response with status sent to the client at ip
+func (t *Tracker) Observe(ip string, status int) {
+	cfg := t.config()
+	outcome := Classify(status)
+	if !cfg.Enabled || outcome == Ignored {
+		return
+	}
+	now := t.clock.Now()
+	key := store.HashClientKey(ip)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c, ok := t.clients[key]
+	if !ok {
+		c = &client{score: Neutral, at: now}
+		t.clients[key] = c
+	}
+	c.score = Update(c.score, now.Sub(c.at), cfg.HalfLife, outcome)
+	c.at = now
+	c.dirty = true
+}
+
+// Sync merges in the scores other replicas stored for the clients this one
+// tracks, saves the scores it changed, forgets idle clients back at
+// neutral and prunes stored scores nobody has updated in a long time. A
+// client first seen here keeps what it did here on top of its stored score;
+// after that the newer of the two scores wins.
+func (t *Tracker) Sync(ctx context.Context) error {
+	cfg := t.config()
+	if !cfg.Enabled {
+		return nil
+	}
+	now := t.clock.Now()
+
+	t.mu.Lock()
+	keys := make([]string, 0, len(t.clients))
+	for key := range t.clients {
+		keys = append(keys, key)
+	}
+	t.mu.Unlock()
+
+	var stored []store.ClientScore
+	for start := 0; start < len(keys); start += syncBatch {
+		batch, err := t.store.ClientScores(ctx, keys[start:min(start+syncBatch, len(keys))])
+		if err != nil {
+			return err
+		}
+		stored = append(stored, batch...)
+	}
+
+	t.mu.Lock()
+	for _, s := range stored {
+		c, ok := t.clients[s.Key]
+		switch {
+		case !ok:
+		case !c.loaded:
+			c.score = math.Min(math.Max(Decay(s.Score, c.at.Sub(s.UpdatedAt), cfg.HalfLife)+c.score-Neutral, 0), 1)
+			if s.UpdatedAt.After(c.at) {
+				c.at = s.UpdatedAt
+			}
+			c.dirty = true
+		case !c.dirty && s.UpdatedAt.After(c.at):
+			c.score, c.at = s.Score, s.UpdatedAt
+		}
+	}
+	var dirty []store.ClientScore
+	for _, key := range keys {
+		if c, ok := t.clients[key]; ok {
+			c.loaded = true
+		}
+	}
+	for key, c := range t.clients {
+		if c.dirty {
+			dirty = append(dirty, store.ClientScore{Key: key, Score: c.score, UpdatedAt: c.at})
+			c.dirty = false
+		} else if c.loaded && math.Abs(Decay(c.score, now.Sub(c.at), cfg.HalfLife)-Neutral) < forgetWithin {
+			delete(t.clients, key)
+		}
+	}
+	t.mu.Unlock()
+
+	if err := t.store.SaveClientScores(ctx, dirty); err != nil {
+		t.redirty(dirty)
+		return err
+	}
+	_, err := t.store.PruneClientScores(ctx, now.Add(-pruneAfterHalfLives*cfg.HalfLife))
+	return err
+}
+
+// redirty marks scores a failed save left unsaved, unless they changed since
+func (t *Tracker) redirty(scores []store.ClientScore) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, s := range scores {
+		if c, ok := t.clients[s.Key]; ok && c.at.Equal(s.UpdatedAt) {
+			c.dirty = true
+		}
+	}
+}
+
+// Clients reports how many clients the tracker holds in memory
+func (t *Tracker) Clients() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.clients)
+}
+
+// Run syncs t every interval until ctx is done
+func Run(ctx context.Context, t *Tracker, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := t.Sync(ctx); err != nil {
+				logger.Warn("failed to sync client reputations, retrying next sync", "error", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
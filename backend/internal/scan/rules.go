@@ -0,0 +1,177 @@
+package scan
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Rule types in a rules document
+const (
+	RuleDenyRegex    = "deny_regex"
+	RuleAllowDomains = "allow_domains"
+)
+
+// RuleConfig is one rule of a rules document. Fields limits the rule to
+// those metadata fields; empty means every field.
+type RuleConfig struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Fields  []string `json:"fields,omitempty"`
+	Pattern string   `json:"pattern,omitempty"`
+	Domains []string `json:"domains,omitempty"`
+}
+
+// rulesDocument is the JSON format of SCAN_RULES and SCAN_RULES_FILE
+type rulesDocument struct {
+	Rules []RuleConfig `json:"rules"`
+}
+
+// ParseRules builds scanners from a JSON rules document
+func ParseRules(data []byte) ([]Scanner, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var doc rulesDocument
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parse scan rules: %w", err)
+	}
+
+	names := make(map[string]bool, len(doc.Rules))
+	scanners := make([]Scanner, 0, len(doc.Rules))
+	for i, rule := range doc.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("scan rule %d: name is required", i)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("scan rule %q: duplicate name", rule.Name)
+		}
+		names[rule.Name] = true
+
+		var s Scanner
+		var err error
+		switch rule.Type {
+		case RuleDenyRegex:
+			s, err = NewRegexDenylist(rule.Name, rule.Pattern, rule.Fields...)
+		case RuleAllowDomains:
+			s, err = NewDomainAllowlist(rule.Name, rule.Domains, rule.Fields...)
+		default:
+			err = fmt.Errorf("unknown type %q", rule.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("scan rule %q: %w", rule.Name, err)
+		}
+		scanners = append(scanners, s)
+	}
+	return scanners, nil
+}
+
+// LoadRulesFile reads and parses a rules document from path
+func LoadRulesFile(path string) ([]Scanner, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read scan rules: %w", err)
+	}
+	return ParseRules(data)
+}
+
+// ReloadFile replaces the configured rules with those in path and returns
+// how many were loaded. On error the current rules stay in force.
+func ReloadFile(path string) (int, error) {
+	rules, err := LoadRulesFile(path)
+	if err != nil {
+		return 0, err
+	}
+	SetRules(rules)
+	return len(rules), nil
+}
+
+// appliesTo reports whether a rule limited to fields covers name
+func appliesTo(fields []string, name string) bool {
+	return len(fields) == 0 || slices.Contains(fields, name)
+}
+
+// RegexDenylist rejects fields whose value matches a pattern
+type RegexDenylist struct {
+	name    string
+	pattern *regexp.Regexp
+	fields  []string
+}
+
+// NewRegexDenylist creates a denylist rule named name for the given
+// fields, or every field when none are given
+func NewRegexDenylist(name, pattern string, fields ...string) (*RegexDenylist, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("pattern is required")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("bad pattern: %w", err)
+	}
+	return &RegexDenylist{name: name, pattern: re, fields: fields}, nil
+}
+
+// Scan rejects a matching value
+func (d *RegexDenylist) Scan(field Field) *Violation {
+	if appliesTo(d.fields, field.Name) && d.pattern.MatchString(field.Value) {
+		return &Violation{Rule: d.name, Field: field.Name}
+	}
+	return nil
+}
+
+// urlPattern finds absolute URLs inside free-form metadata
+var urlPattern = regexp.MustCompile(`(?i)\b[a-z][a-z0-9+.-]*://[^\s/?#]+`)
+
+// DomainAllowlist rejects fields containing URLs whose host is outside the
+// allowed domains. A domain also allows its subdomains. Values without URLs
+// pass.
+type DomainAllowlist struct {
+	name    string
+	domains []string
+	fields  []string
+}
+
+// NewDomainAllowlist creates an allowlist rule named name for the given
+// fields, or every field when none are given
+func NewDomainAllowlist(name string, domains []string, fields ...string) (*DomainAllowlist, error) {
+	if len(domains) == 0 {
+		return nil, fmt.Errorf("at least one domain is required")
+	}
+	normalized := make([]string, len(domains))
+	for i, domain := range domains {
+		normalized[i] = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if normalized[i] == "" {
+			return nil, fmt.Errorf("empty domain")
+		}
+	}
+	return &DomainAllowlist{name: name, domains: normalized, fields: fields}, nil
+}
+
+// Scan rejects a value naming a host outside the allowlist
+func (a *DomainAllowlist) Scan(field Field) *Violation {
+	if !appliesTo(a.fields, field.Name) {
+		return nil
+	}
+	for _, match := range urlPattern.FindAllString(field.Value, -1) {
+		parsed, err := url.Parse(match)
+		if err != nil || !a.allowed(parsed.Hostname()) {
+			return &Violation{Rule: a.name, Field: field.Name}
+		}
+	}
+	return nil
+}
+
+func (a *DomainAllowlist) allowed(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, domain := range a.domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+// Package scan enforces deployment policy on the metadata of a create
+// request: the fields the server can read, such as part labels and upload
+// filenames. Scanners are never given ciphertext, IVs, salts or agent
+// plaintext; only Fields built by the validator reach them.
+package scan
+
+import (
+	"slices"
+	"sync"
+
+	"ots-backend/pkg/ots"
+)
+
+// Metadata field names passed to scanners
+const (
+	FieldPartLabel = ots.FieldPartLabel
+	FieldFilename  = ots.FieldFilename
+)
+
+// ErrPolicyViolation indicates metadata a scanner rejected
+var ErrPolicyViolation = ots.ErrPolicyViolation
+
+// Field is one metadata value a scanner inspects
+type Field = ots.Field
+
+// Violation names the rule a field broke. It matches ErrPolicyViolation
+// with errors.Is.
+type Violation = ots.Violation
+
+// Scanner inspects one metadata field and returns a violation, or nil to
+// let it pass. Scan is called concurrently.
+type Scanner = ots.Scanner
+
+// ScannerFunc adapts a function to Scanner
+type ScannerFunc = ots.ScannerFunc
+
+// rules come from configuration and are replaced on reload; custom
+// scanners are registered through ots.RegisterScanner and stay
+var registry struct {
+	mu    sync.RWMutex
+	rules []Scanner
+}
+
+// SetRules replaces the configured rules, for example after a reload
+func SetRules(rules []Scanner) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.rules = rules
+}
+
+// Register adds a custom scanner that runs after the configured rules. The
+// returned function removes it again.
+func Register(s Scanner) (unregister func()) {
+	return ots.RegisterScanner(s)
+}
+
+// Check runs every scanner over fields and returns the first violation
+func Check(fields ...Field) error {
+	registry.mu.RLock()
+	scanners := append(slices.Clone(registry.rules), ots.Scanners()...)
+	registry.mu.RUnlock()
+
+	for _, field := range fields {
+		for _, s := range scanners {
+			if v := s.Scan(field); v != nil {
+				return v
+			}
+		}
+	}
+	return nil
+}
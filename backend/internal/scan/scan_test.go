@@ -0,0 +1,150 @@
+package scan
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegexDenylist(t *testing.T) {
+	deny, err := NewRegexDenylist("no-jira", `(?i)\bJIRA-\d+\b`, FieldPartLabel)
+	if err != nil {
+		t.Fatalf("NewRegexDenylist() error: %v", err)
+	}
+
+	tests := []struct {
+		field Field
+		want  bool
+	}{
+		{Field{Name: FieldPartLabel, Value: "password for JIRA-1234"}, true},
+		{Field{Name: FieldPartLabel, Value: "password for jira-9"}, true},
+		{Field{Name: FieldPartLabel, Value: "password for INC-1234"}, false},
+		// Limited to part labels
+		{Field{Name: FieldFilename, Value: "JIRA-1234.txt"}, false},
+	}
+	for _, tt := range tests {
+		v := deny.Scan(tt.field)
+		if (v != nil) != tt.want {
+			t.Errorf("Scan(%+v) = %v, want violation %v", tt.field, v, tt.want)
+		}
+		if v != nil && (v.Rule != "no-jira" || v.Field != tt.field.Name) {
+			t.Errorf("Scan(%+v) = %+v, want rule no-jira on %s", tt.field, v, tt.field.Name)
+		}
+	}
+
+	if _, err := NewRegexDenylist("bad", `(`); err == nil {
+		t.Error("NewRegexDenylist() accepted an invalid pattern")
+	}
+}
+
+func TestDomainAllowlist(t *testing.T) {
+	allow, err := NewDomainAllowlist("corp-links", []string{"example.com", ".example.org"})
+	if err != nil {
+		t.Fatalf("NewDomainAllowlist() error: %v", err)
+	}
+
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"no links at all", false},
+		{"see https://example.com/wiki", false},
+		{"see https://vault.EXAMPLE.com:8443/x", false},
+		{"see https://example.org and https://sso.example.org", false},
+		{"see https://competitor.io/login", true},
+		{"see https://example.com.competitor.io", true},
+		{"https://example.com then ftp://files.competitor.io", true},
+	}
+	for _, tt := range tests {
+		if v := allow.Scan(Field{Name: FieldPartLabel, Value: tt.value}); (v != nil) != tt.want {
+			t.Errorf("Scan(%q) = %v, want violation %v", tt.value, v, tt.want)
+		}
+	}
+
+	if _, err := NewDomainAllowlist("empty", nil); err == nil {
+		t.Error("NewDomainAllowlist() accepted no domains")
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules([]byte(`{"rules": [
+		{"name": "no-exe", "type": "deny_regex", "fields": ["filename"], "pattern": "(?i)\\.exe$"},
+		{"name": "corp-links", "type": "allow_domains", "domains": ["example.com"]}
+	]}`))
+	if err != nil {
+		t.Fatalf("ParseRules() error: %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("ParseRules() = %d rules, want 2", len(rules))
+	}
+
+	for name, doc := range map[string]string{
+		"unknown type":    `{"rules": [{"name": "x", "type": "allow_regex", "pattern": "a"}]}`,
+		"missing name":    `{"rules": [{"type": "deny_regex", "pattern": "a"}]}`,
+		"duplicate name":  `{"rules": [{"name": "x", "type": "deny_regex", "pattern": "a"}, {"name": "x", "type": "deny_regex", "pattern": "b"}]}`,
+		"unknown field":   `{"rules": [{"name": "x", "type": "deny_regex", "regex": "a"}]}`,
+		"missing domains": `{"rules": [{"name": "x", "type": "allow_domains"}]}`,
+		"not json":        `rules: []`,
+	} {
+		if _, err := ParseRules([]byte(doc)); err == nil {
+			t.Errorf("ParseRules(%s) = nil error, want error", name)
+		}
+	}
+}
+
+func TestReloadFile(t *testing.T) {
+	t.Cleanup(func() { SetRules(nil) })
+	path := filepath.Join(t.TempDir(), "rules.json")
+
+	write := func(doc string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+			t.Fatalf("write rules: %v", err)
+		}
+	}
+
+	write(`{"rules": [{"name": "no-exe", "type": "deny_regex", "pattern": "\\.exe$"}]}`)
+	if n, err := ReloadFile(path); err != nil || n != 1 {
+		t.Fatalf("ReloadFile() = %d, %v; want 1, nil", n, err)
+	}
+	if err := Check(Field{Name: FieldFilename, Value: "setup.exe"}); !errors.Is(err, ErrPolicyViolation) {
+		t.Fatalf("Check() after load = %v, want a policy violation", err)
+	}
+
+	// A broken edit keeps the running rules
+	write(`{"rules": [`)
+	if _, err := ReloadFile(path); err == nil {
+		t.Fatal("ReloadFile() accepted a broken file")
+	}
+	if err := Check(Field{Name: FieldFilename, Value: "setup.exe"}); !errors.Is(err, ErrPolicyViolation) {
+		t.Fatalf("Check() after failed reload = %v, want the old rules in force", err)
+	}
+
+	write(`{"rules": []}`)
+	if _, err := ReloadFile(path); err != nil {
+		t.Fatalf("ReloadFile() error: %v", err)
+	}
+	if err := Check(Field{Name: FieldFilename, Value: "setup.exe"}); err != nil {
+		t.Fatalf("Check() after emptying the rules = %v, want nil", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	unregister := Register(ScannerFunc(func(field Field) *Violation {
+		if field.Value == "forbidden" {
+			return &Violation{Rule: "custom", Field: field.Name}
+		}
+		return nil
+	}))
+
+	var v *Violation
+	if err := Check(Field{Name: FieldPartLabel, Value: "fine"}, Field{Name: FieldPartLabel, Value: "forbidden"}); !errors.As(err, &v) || v.Rule != "custom" {
+		t.Fatalf("Check() = %v, want the custom scanner's violation", err)
+	}
+
+	unregister()
+	if err := Check(Field{Name: FieldPartLabel, Value: "forbidden"}); err != nil {
+		t.Fatalf("Check() after unregister = %v, want nil", err)
+	}
+}
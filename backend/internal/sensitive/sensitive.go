@@ -0,0 +1,109 @@
+// Package sensitive holds values that must never reach logs, error
+// messages, audit events or traces: ciphertexts, IVs, salts, keys and
+// tokens. Formatting one with fmt, logging it with slog or marshalling it
+// to JSON yields only its length and a short SHA-256 prefix, enough to tell
+// two values apart in a report and nothing more.
+//
+// The types convert to and from their plain forms without copying. Convert
+// explicitly, with []byte(b) or string(s), only where the value itself is
+// the output: a response body, a cipher input or a query argument.
+package sensitive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+)
+
+// HashPrefixLen is how many hex digits of the SHA-256 a summary shows
+const HashPrefixLen = 8
+
+// Bytes is a sensitive byte string. The Postgres and SQLite drivers store
+// and scan it as the bytes it holds; a driver error that quotes an
+// argument quotes the summary instead.
+type Bytes []byte
+
+// String returns the summary, for example "[redacted 32 bytes sha256:1a2b3c4d]"
+func (b Bytes) String() string {
+	return summary(len(b), b)
+}
+
+// Format writes the summary for every verb, including %x and %#v
+func (b Bytes) Format(f fmt.State, _ rune) {
+	io.WriteString(f, b.String())
+}
+
+// LogValue logs the length and hash prefix
+func (b Bytes) LogValue() slog.Value {
+	return logValue(len(b), b)
+}
+
+// MarshalJSON encodes the length and hash prefix
+func (b Bytes) MarshalJSON() ([]byte, error) {
+	return marshalSummary(len(b), b)
+}
+
+// Scan copies a scanned BLOB or bytea, which drivers may reuse
+func (b *Bytes) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*b = nil
+	case []byte:
+		*b = append(Bytes(nil), v...)
+	case string:
+		*b = Bytes(v)
+	default:
+		return fmt.Errorf("sensitive: cannot scan %T into Bytes", src)
+	}
+	return nil
+}
+
+// String is a sensitive text value such as a token or passphrase. Pass
+// string(s) to a driver: text encoders print a Stringer as its String.
+type String string
+
+// String returns the summary, for example "[redacted 43 bytes sha256:1a2b3c4d]"
+func (s String) String() string {
+	return summary(len(s), []byte(s))
+}
+
+// Format writes the summary for every verb, including %q and %#v
+func (s String) Format(f fmt.State, _ rune) {
+	io.WriteString(f, s.String())
+}
+
+// LogValue logs the length and hash prefix
+func (s String) LogValue() slog.Value {
+	return logValue(len(s), []byte(s))
+}
+
+// MarshalJSON encodes the length and hash prefix
+func (s String) MarshalJSON() ([]byte, error) {
+	return marshalSummary(len(s), []byte(s))
+}
+
+// hashPrefix returns the first HashPrefixLen hex digits of data's SHA-256
+func hashPrefix(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:HashPrefixLen]
+}
+
+func summary(n int, data []byte) string {
+	return "[redacted " + strconv.Itoa(n) + " bytes sha256:" + hashPrefix(data) + "]"
+}
+
+func logValue(n int, data []byte) slog.Value {
+	return slog.GroupValue(slog.Int("len", n), slog.String("sha256", hashPrefix(data)))
+}
+
+func marshalSummary(n int, data []byte) ([]byte, error) {
+	return json.Marshal(struct {
+		Redacted bool   `json:"redacted"`
+		Len      int    `json:"len"`
+		SHA256   string `json:"sha256"`
+	}{true, n, hashPrefix(data)})
+}
@@ -0,0 +1,94 @@
+package sensitive
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const canary = "LEAKCANARY-sensitive-value"
+
+func TestSummariesHideTheValue(t *testing.T) {
+	b := Bytes(canary)
+	s := String(canary)
+
+	var logged bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&logged, nil))
+	log.Info("values", "bytes", b, "string", s, "struct", struct{ B Bytes }{b})
+	encoded, err := json.Marshal(map[string]any{"bytes": b, "string": s})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	outputs := map[string]string{"log": logged.String(), "json": string(encoded)}
+	for _, verb := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x", "%X", "%d"} {
+		outputs[verb] = fmt.Sprintf(verb+" "+verb, b, s)
+		outputs[verb+" in a struct"] = fmt.Sprintf(verb, struct {
+			B Bytes
+			S String
+		}{b, s})
+	}
+	outputs["wrapped"] = fmt.Errorf("insert secret %v: %w", b, errors.New("failed")).Error()
+
+	forms := []string{canary, hex.EncodeToString([]byte(canary)), base64.StdEncoding.EncodeToString([]byte(canary))}
+	for name, out := range outputs {
+		for _, form := range forms {
+			if strings.Contains(strings.ToLower(out), strings.ToLower(form)) {
+				t.Errorf("%s output %q contains the value", name, out)
+			}
+		}
+	}
+
+	want := fmt.Sprintf("[redacted %d bytes sha256:%s]", len(canary), hashPrefix([]byte(canary)))
+	if got := fmt.Sprint(b); got != want {
+		t.Errorf("fmt.Sprint(Bytes) = %q, want %q", got, want)
+	}
+	if !strings.Contains(logged.String(), `"bytes":{"len":26,"sha256":"`+hashPrefix([]byte(canary))+`"}`) {
+		t.Errorf("logged %s, want the length and hash prefix", logged.String())
+	}
+}
+
+func TestBytesScanCopies(t *testing.T) {
+	src := []byte(canary)
+	var b Bytes
+	if err := b.Scan(src); err != nil || string(b) != canary {
+		t.Fatalf("Scan() = %q, %v; want %q", []byte(b), err, canary)
+	}
+	src[0] = 'X'
+	if string(b) != canary {
+		t.Error("Scan() kept the driver's buffer")
+	}
+	if err := b.Scan(nil); err != nil || b != nil {
+		t.Errorf("Scan(nil) = %q, %v; want nil", []byte(b), err)
+	}
+	if err := b.Scan(42); err == nil {
+		t.Error("Scan(42) error = nil, want one")
+	}
+}
+
+// TestDriverErrorsQuoteTheSummary encodes through pgx as a failed Exec
+// would: the error quotes the argument with %#v
+func TestDriverErrorsQuoteTheSummary(t *testing.T) {
+	m := pgtype.NewMap()
+
+	encoded, err := m.Encode(pgtype.ByteaOID, pgtype.BinaryFormatCode, Bytes(canary), nil)
+	if err != nil || string(encoded) != canary {
+		t.Fatalf("encode as bytea = %q, %v; want the value itself", encoded, err)
+	}
+
+	_, err = m.Encode(pgtype.Int4OID, pgtype.BinaryFormatCode, Bytes(canary), nil)
+	if err == nil {
+		t.Fatal("encode as int4 error = nil, want one")
+	}
+	if msg := err.Error(); strings.Contains(msg, "0x4c, 0x45") || strings.Contains(msg, canary) {
+		t.Errorf("encode error %q quotes the value", msg)
+	}
+}
@@ -0,0 +1,101 @@
+// Package sizestats aggregates the sizes of created secrets without keeping
+// them. Each create is counted only in a power-of-two bucket. In strict
+// privacy mode published counts also carry bounded random noise, so the
+// distribution of a low-traffic instance does not give away one create.
+package sizestats
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"math"
+	"math/bits"
+	mathrand "math/rand/v2"
+	"strconv"
+
+	"ots-backend/internal/store"
+)
+
+// noiseTail is how many noise scales a draw may reach before it is clamped.
+// Laplace draws fall beyond it about 0.7% of the time.
+const noiseTail = 5
+
+// seed keys every noise draw of this process
+var seed = newSeed()
+
+func newSeed() uint64 {
+	var b [8]byte
+	rand.Read(b[:])
+	return binary.LittleEndian.Uint64(b[:])
+}
+
+// Bucket returns the upper bound of the power-of-two bucket holding size
+func Bucket(size int) int64 {
+	if size <= 1 {
+		return 1
+	}
+	return 1 << bits.Len64(uint64(size-1))
+}
+
+// Noise fudges published counts with Laplace noise of scale 1/epsilon. A
+// draw depends only on the bucket, its true count and a per-process seed,
+// so repeating a query returns the same value rather than fresh draws that
+// average out. A nil Noise adds none.
+type Noise struct {
+	scale float64
+	seed  uint64
+}
+
+// NewNoise returns noise for epsilon; a smaller epsilon adds more. Epsilon
+// zero or below returns nil.
+func NewNoise(epsilon float64) *Noise {
+	if epsilon <= 0 {
+		return nil
+	}
+	return &Noise{scale: 1 / epsilon, seed: seed}
+}
+
+// Bound is the most a published count can differ from the true one
+func (n *Noise) Bound() int64 {
+	if n == nil {
+		return 0
+	}
+	return int64(math.Ceil(noiseTail * n.scale))
+}
+
+// Apply returns the count to publish for a bucket, never below zero
+func (n *Noise) Apply(upperBound, count int64) int64 {
+	if n == nil {
+		return count
+	}
+
+	rng := mathrand.New(mathrand.NewPCG(n.seed^uint64(upperBound), uint64(count)))
+	// Inverse CDF of the Laplace distribution; u is in (-0.5, 0.5]
+	u := 0.5 - rng.Float64()
+	noise := -n.scale * math.Copysign(math.Log(1-2*math.Abs(u)), u)
+
+	bound := float64(n.Bound())
+	noise = math.Max(-bound, math.Min(bound, noise))
+	return max(count+int64(math.Round(noise)), 0)
+}
+
+// Buckets returns a copy of buckets with their counts to publish
+func (n *Noise) Buckets(buckets []store.SizeBucket) []store.SizeBucket {
+	published := make([]store.SizeBucket, len(buckets))
+	for i, bucket := range buckets {
+		published[i] = store.SizeBucket{UpperBound: bucket.UpperBound, Count: n.Apply(bucket.UpperBound, bucket.Count)}
+	}
+	return published
+}
+
+// Histogram renders buckets, smallest first, as cumulative counts keyed by
+// upper bound with a "+Inf" total, like a Prometheus histogram's le series
+func Histogram(buckets []store.SizeBucket) map[string]int64 {
+	histogram := make(map[string]int64, len(buckets)+1)
+	var total int64
+	for _, bucket := range buckets {
+		total += bucket.Count
+		histogram[strconv.FormatInt(bucket.UpperBound, 10)] = total
+	}
+	histogram["+Inf"] = total
+	return histogram
+}
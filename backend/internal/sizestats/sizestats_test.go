@@ -0,0 +1,93 @@
+package sizestats
+
+import (
+	"math"
+	"testing"
+
+	"ots-backend/internal/store"
+)
+
+func TestBucket(t *testing.T) {
+	tests := []struct {
+		size int
+		want int64
+	}{
+		{size: 0, want: 1},
+		{size: 1, want: 1},
+		{size: 2, want: 2},
+		{size: 3, want: 4},
+		{size: 4, want: 4},
+		{size: 5, want: 8},
+		{size: 1023, want: 1024},
+		{size: 1024, want: 1024},
+		{size: 1025, want: 2048},
+		{size: 32768, want: 32768},
+		{size: 32769, want: 65536},
+	}
+
+	for _, tt := range tests {
+		if got := Bucket(tt.size); got != tt.want {
+			t.Errorf("Bucket(%d) = %d, want %d", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestNoiseBounds(t *testing.T) {
+	for _, epsilon := range []float64{0.1, 0.5, 1, 4} {
+		n := &Noise{scale: 1 / epsilon, seed: 42}
+		bound := n.Bound()
+		if want := int64(math.Ceil(noiseTail / epsilon)); bound != want {
+			t.Errorf("epsilon %v: Bound() = %d, want %d", epsilon, bound, want)
+		}
+
+		var sum float64
+		const draws = 20000
+		for count := int64(1000); count < 1000+draws; count++ {
+			got := n.Apply(64, count)
+			if diff := got - count; diff < -bound || diff > bound {
+				t.Fatalf("epsilon %v: Apply(64, %d) = %d, more than %d off", epsilon, count, got, bound)
+			}
+			sum += float64(got - count)
+		}
+		// Laplace noise is centered; the clamp is symmetric
+		if mean := sum / draws; math.Abs(mean) > 0.1*n.scale+0.05 {
+			t.Errorf("epsilon %v: mean noise = %.3f, want about 0", epsilon, mean)
+		}
+	}
+}
+
+func TestNoiseIsStableAndNonNegative(t *testing.T) {
+	n := &Noise{scale: 2, seed: 7}
+
+	for count := int64(0); count < 50; count++ {
+		first := n.Apply(1024, count)
+		if first < 0 {
+			t.Fatalf("Apply(1024, %d) = %d, want no negative counts", count, first)
+		}
+		// Repeating a query must not draw fresh noise
+		if again := n.Apply(1024, count); again != first {
+			t.Fatalf("Apply(1024, %d) = %d then %d, want a stable value", count, first, again)
+		}
+	}
+
+	var none *Noise
+	if got := none.Apply(1024, 3); got != 3 || none.Bound() != 0 {
+		t.Errorf("nil Noise Apply() = %d, Bound() = %d; want the count unchanged and 0", got, none.Bound())
+	}
+	if NewNoise(0) != nil {
+		t.Error("NewNoise(0) != nil, want no noise")
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	got := Histogram([]store.SizeBucket{{UpperBound: 64, Count: 2}, {UpperBound: 1024, Count: 3}, {UpperBound: 4096, Count: 1}})
+	want := map[string]int64{"64": 2, "1024": 5, "4096": 6, "+Inf": 6}
+	if len(got) != len(want) {
+		t.Fatalf("Histogram() = %v, want %v", got, want)
+	}
+	for le, count := range want {
+		if got[le] != count {
+			t.Errorf("Histogram()[%q] = %d, want %d", le, got[le], count)
+		}
+	}
+}
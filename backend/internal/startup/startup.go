@@ -0,0 +1,71 @@
+// Package startup reports fatal startup failures in a form orchestrators can
+// act on. Whatever a binary logged before, the last line it writes to
+// stderr is one JSON object naming the stage that failed and a stable code,
+// and the exit status tells the stages apart without reading it.
+package startup
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// Stage is the startup step that failed
+type Stage string
+
+// Stages in the order startup runs them
+const (
+	StageConfig      Stage = "config"
+	StageListen      Stage = "listen"
+	StageDBConnect   Stage = "db_connect"
+	StageMigrate     Stage = "migrate"
+	StageSchemaCheck Stage = "schema_check"
+)
+
+// exitCodes keeps clear of 1, which log.Fatal and panics exit with, and of
+// 2, which the flag package and the Go runtime use. Listening moved ahead
+// of the store so probes are answered during migrations; its code stayed.
+var exitCodes = map[Stage]int{
+	StageConfig:      10,
+	StageDBConnect:   11,
+	StageMigrate:     12,
+	StageSchemaCheck: 13,
+	StageListen:      14,
+}
+
+// ExitCode is the process exit status for a failure at s
+func (s Stage) ExitCode() int {
+	if code, ok := exitCodes[s]; ok {
+		return code
+	}
+	return 1
+}
+
+// failing is taken by the first Fail and never released, so a second
+// failure racing it cannot write another final line
+var failing sync.Mutex
+
+// Fail writes the failure as the final line on stderr and exits with the
+// stage's status. code is a stable snake_case identifier runbooks can match
+// on; err is the human detail.
+func Fail(stage Stage, code string, err error) {
+	failing.Lock()
+	write(os.Stderr, stage, code, err)
+	os.Exit(stage.ExitCode())
+}
+
+// write renders one failure line. It builds its own JSON handler so the
+// line is structured even when the failure came before logging was set up.
+func write(w io.Writer, stage Stage, code string, err error) {
+	detail := ""
+	if err != nil {
+		detail = err.Error()
+	}
+	slog.New(slog.NewJSONHandler(w, nil)).Error("startup failed",
+		"failure_stage", string(stage),
+		"failure_code", code,
+		"detail", detail,
+		"exit_code", stage.ExitCode(),
+	)
+}
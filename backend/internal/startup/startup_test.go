@@ -0,0 +1,51 @@
+package startup
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestExitCodes(t *testing.T) {
+	seen := map[int]Stage{}
+	for _, stage := range []Stage{StageConfig, StageDBConnect, StageMigrate, StageSchemaCheck, StageListen} {
+		code := stage.ExitCode()
+		if code <= 2 || code > 125 {
+			t.Errorf("%s exit code = %d, want one of its own above 2", stage, code)
+		}
+		if other, ok := seen[code]; ok {
+			t.Errorf("%s and %s share exit code %d", stage, other, code)
+		}
+		seen[code] = stage
+	}
+	if code := Stage("unknown").ExitCode(); code != 1 {
+		t.Errorf("unknown stage exit code = %d, want 1", code)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	write(&buf, StageMigrate, "schema_dirty", errors.New("database schema is dirty\nat version 12"))
+
+	if n := bytes.Count(buf.Bytes(), []byte("\n")); n != 1 {
+		t.Fatalf("write() wrote %d lines, want 1: %q", n, buf.String())
+	}
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("write() line is not JSON: %v", err)
+	}
+	want := map[string]any{
+		"level":         "ERROR",
+		"msg":           "startup failed",
+		"failure_stage": "migrate",
+		"failure_code":  "schema_dirty",
+		"detail":        "database schema is dirty\nat version 12",
+		"exit_code":     float64(12),
+	}
+	for key, value := range want {
+		if line[key] != value {
+			t.Errorf("line[%q] = %v, want %v", key, line[key], value)
+		}
+	}
+}
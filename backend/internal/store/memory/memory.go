@@ -0,0 +1,1100 @@
+// Package memory implements store.Store in process memory for tests and
+// ephemeral demos. Nothing is persisted: every secret, receipt and audit
+// event is lost when the process exits, and replicas do not share state.
+package memory
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"ots-backend/internal/store"
+)
+
+// ErrDuplicateAuditID indicates an audit event reused an existing ID
+var ErrDuplicateAuditID = errors.New("duplicate audit event id")
+
+// record is a stored secret and the state the SQL backends keep in columns
+// beside it
+type record struct {
+	secret store.Secret
+	// keyWrapped is set for crypto-shredding secrets; once their key is
+	// shredded the record only waits for CollectShredded
+	keyWrapped bool
+	shredded   bool
+	// ackTokenHash and ackDeadline hold a delivered secret: a require_ack
+	// secret for its ack, any other for its grace window
+	ackTokenHash []byte
+	ackDeadline  time.Time
+}
+
+// live reports whether the record can still be read or acknowledged
+func (r *record) live() bool {
+	return !r.keyWrapped || !r.shredded
+}
+
+// held reports whether the record was delivered and waits for its ack or
+// the end of its grace window
+func (r *record) held() bool {
+	return !r.ackDeadline.IsZero()
+}
+
+// Store is the in-memory secret store. One mutex guards everything, which
+// makes Consume trivially atomic: of concurrent consumers, the first to take
+// the lock destroys the secret before the others look.
+type Store struct {
+	mu       sync.RWMutex
+	secrets  map[string]*record
+	receipts map[string]store.Receipt
+	// audit is kept sorted by ID
+	audit   []store.AuditEvent
+	dropped []store.DroppedWork
+	sizes   map[int64]int64
+	days    map[time.Time]store.DailyStats
+	// scores is keyed by client key
+	scores map[string]store.ClientScore
+	// deletions is keyed by namespace
+	deletions map[string]*store.NamespaceDeletion
+}
+
+// New creates an empty store
+func New() *Store {
+	return &Store{
+		secrets:   make(map[string]*record),
+		receipts:  make(map[string]store.Receipt),
+		sizes:     make(map[int64]int64),
+		days:      make(map[time.Time]store.DailyStats),
+		scores:    make(map[string]store.ClientScore),
+		deletions: make(map[string]*store.NamespaceDeletion),
+	}
+}
+
+// Create stores a copy of secret
+func (s *Store) Create(ctx context.Context, secret *store.Secret) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.secrets[secret.ID]; ok {
+		return store.ErrDuplicateID
+	}
+	if _, ok := s.deletions[secret.Namespace]; ok && secret.Namespace != "" {
+		return store.ErrNamespaceDeleted
+	}
+	if secret.Creator != "" && secret.CreatorQuota > 0 && s.activeByCreator(secret.CreatedAt)[secret.Creator] >= int64(secret.CreatorQuota) {
+		return store.ErrQuotaExceeded
+	}
+
+	stored := clone(secret)
+	stored.CreatorQuota = 0
+	stored.Revision = store.FirstRevision
+	s.secrets[secret.ID] = &record{secret: *stored, keyWrapped: secret.DataKey != nil}
+	return nil
+}
+
+// activeByCreator counts each creator's live secrets at now. Callers hold
+// the lock.
+func (s *Store) activeByCreator(now time.Time) map[string]int64 {
+	active := make(map[string]int64)
+	for _, rec := range s.secrets {
+		if rec.secret.Creator != "" && rec.live() && rec.secret.ExpiresAt.After(now) {
+			active[rec.secret.Creator]++
+		}
+	}
+	return active
+}
+
+// Consume reads and destroys a secret, or holds it for acknowledgement or
+// a grace window. Expired secrets read as not found and are left for the
+// sweeper.
+func (s *Store) Consume(ctx context.Context, id string, opts store.ConsumeOptions) (*store.Secret, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.secrets[id]
+	if !ok || !rec.live() || !rec.secret.ExpiresAt.After(opts.Now) {
+		return nil, store.ErrNotFound
+	}
+	if opts.RetrievalTokenHash != nil {
+		if !rec.held() || rec.secret.RequireAck || opts.Now.After(rec.ackDeadline) ||
+			subtle.ConstantTimeCompare(rec.ackTokenHash, opts.RetrievalTokenHash) != 1 {
+			return nil, store.ErrNotFound
+		}
+	} else if rec.held() {
+		return nil, store.ErrNotFound
+	}
+	if err := store.CheckAvailable(rec.secret.AvailableAfter, opts.Now); err != nil {
+		return nil, err
+	}
+
+	secret := clone(&rec.secret)
+	if opts.Open != nil {
+		if err := opts.Open(secret); err != nil {
+			return nil, err
+		}
+	}
+	// A hold or an ending is a new revision; a retry ends a hold that was
+	// one already
+	if opts.RetrievalTokenHash == nil {
+		secret.Revision++
+	}
+
+	var acknowledged *bool
+	if rec.secret.RequireAck && opts.Ack != nil {
+		rec.ackTokenHash = bytes.Clone(opts.Ack.TokenHash)
+		rec.ackDeadline = opts.Ack.Deadline
+		rec.secret.Revision = secret.Revision
+		rec.secret.Hint = ""
+		rec.secret.NotifyEmail = nil
+		rec.secret.NotifyEmailHash = ""
+		rec.secret.ContentType = ""
+		rec.secret.Filename = ""
+		acknowledged = new(bool)
+	} else if opts.Grace != nil && opts.RetrievalTokenHash == nil {
+		rec.ackTokenHash = bytes.Clone(opts.Grace.TokenHash)
+		rec.ackDeadline = opts.Grace.Deadline
+		rec.secret.Revision = secret.Revision
+		rec.secret.NotifyEmail = nil
+		rec.secret.NotifyEmailHash = ""
+	} else {
+		s.destroy(id, rec)
+	}
+
+	if opts.Receipt != nil && opts.RetrievalTokenHash == nil {
+		if _, ok := s.receipts[id]; !ok {
+			receipt := *opts.Receipt
+			receipt.Acknowledged = acknowledged
+			receipt.Reason = store.TerminationConsumed
+			receipt.ManagementTokenHash = bytes.Clone(rec.secret.ManagementTokenHash)
+			receipt.Revision = secret.Revision
+			s.receipts[id] = receipt
+		}
+	}
+
+	return secret, nil
+}
+
+// Peek previews a secret Consume would deliver, leaving it in place
+func (s *Store) Peek(ctx context.Context, id string, now time.Time) (*store.Preview, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rec, ok := s.secrets[id]
+	if !ok || !rec.live() || rec.held() || !rec.secret.ExpiresAt.After(now) {
+		return nil, store.ErrNotFound
+	}
+	if err := store.CheckAvailable(rec.secret.AvailableAfter, now); err != nil {
+		return nil, err
+	}
+
+	preview := &store.Preview{
+		Size:        int64(len(rec.secret.Ciphertext)),
+		Hint:        rec.secret.Hint,
+		ContentType: rec.secret.ContentType,
+		Filename:    rec.secret.Filename,
+	}
+	for _, part := range rec.secret.Parts {
+		preview.Size += int64(len(part.Ciphertext))
+	}
+	return preview, nil
+}
+
+// Acknowledge destroys a held secret once its reader confirms receipt. A
+// window that has already lapsed burns the secret without acknowledging it.
+func (s *Store) Acknowledge(ctx context.Context, id string, tokenHash []byte, now time.Time, revision int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.secrets[id]
+	if !ok || !rec.held() || !rec.secret.RequireAck || !rec.live() {
+		return store.ErrNotFound
+	}
+
+	if now.After(rec.ackDeadline) {
+		s.destroy(id, rec)
+		return store.ErrNotFound
+	}
+
+	if subtle.ConstantTimeCompare(rec.ackTokenHash, tokenHash) != 1 {
+		return store.ErrNotFound
+	}
+	if err := store.CheckRevision(revision, rec.secret.Revision); err != nil {
+		return err
+	}
+
+	acknowledgedRevision := rec.secret.Revision + 1
+	s.destroy(id, rec)
+
+	if receipt, ok := s.receipts[id]; ok {
+		acknowledged := true
+		receipt.Acknowledged = &acknowledged
+		receipt.Revision = acknowledgedRevision
+		s.receipts[id] = receipt
+	}
+
+	return nil
+}
+
+// Burn destroys a secret: wrapped records are crypto-shredded, legacy
+// records are deleted outright
+func (s *Store) Burn(ctx context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.secrets[id]
+	if !ok || !rec.live() {
+		return false, nil
+	}
+	s.destroy(id, rec)
+	return true, nil
+}
+
+// Terminate destroys a secret for a burn or an expiry and returns its
+// metadata, keeping tombstone as its receipt
+func (s *Store) Terminate(ctx context.Context, id string, reason store.TerminationReason, now time.Time, revision int64, tombstone *store.Receipt) (*store.Secret, error) {
+	if err := store.CheckTermination(reason); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.secrets[id]
+	if !ok || !rec.live() {
+		return nil, store.ErrNotFound
+	}
+	// A burn, like a read, finds nothing past the expiry; an expiry only a
+	// secret past it that is not held
+	if reason == store.TerminationBurned && !rec.secret.ExpiresAt.After(now) {
+		return nil, store.ErrNotFound
+	}
+	if reason == store.TerminationExpired && (rec.held() || !rec.secret.ExpiresAt.Before(now)) {
+		return nil, store.ErrNotFound
+	}
+	if err := store.CheckRevision(revision, rec.secret.Revision); err != nil {
+		return nil, err
+	}
+
+	ended := &store.Secret{
+		ID:          id,
+		Namespace:   rec.secret.Namespace,
+		NotifyEmail: bytes.Clone(rec.secret.NotifyEmail),
+		CreatedAt:   rec.secret.CreatedAt,
+		ExpiresAt:   rec.secret.ExpiresAt,
+		Revision:    rec.secret.Revision + 1,
+	}
+	tokenHash := bytes.Clone(rec.secret.ManagementTokenHash)
+	s.destroy(id, rec)
+
+	if tombstone != nil {
+		receipt, ok := s.receipts[id]
+		if !ok {
+			receipt = *tombstone
+			receipt.Reason = reason
+			receipt.ManagementTokenHash = tokenHash
+		}
+		receipt.Revision = ended.Revision
+		s.receipts[id] = receipt
+	}
+	return ended, nil
+}
+
+// ManagementTokenHash returns the stored management token hash
+func (s *Store) ManagementTokenHash(ctx context.Context, id string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rec, ok := s.secrets[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return bytes.Clone(rec.secret.ManagementTokenHash), nil
+}
+
+// Revision returns the revision of a secret that has not been destroyed
+func (s *Store) Revision(ctx context.Context, id string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rec, ok := s.secrets[id]
+	if !ok || !rec.live() {
+		return 0, store.ErrNotFound
+	}
+	return rec.secret.Revision, nil
+}
+
+// Receipt returns the read receipt recorded when the secret was consumed
+func (s *Store) Receipt(ctx context.Context, id string) (*store.Receipt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	receipt, ok := s.receipts[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	if receipt.Acknowledged != nil {
+		acknowledged := *receipt.Acknowledged
+		receipt.Acknowledged = &acknowledged
+	}
+	receipt.ManagementTokenHash = bytes.Clone(receipt.ManagementTokenHash)
+	return &receipt, nil
+}
+
+// DeclaredKeyBits counts live, unshredded secrets by declared key length,
+// undeclared first
+func (s *Store) DeclaredKeyBits(ctx context.Context, now time.Time) ([]store.KeyBitsCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var undeclared int64
+	declared := make(map[int]int64)
+	for id, rec := range s.secrets {
+		if !rec.live() || !rec.secret.ExpiresAt.After(now) || strings.HasPrefix(id, store.CanaryIDPrefix) {
+			continue
+		}
+		if rec.secret.DeclaredKeyBits == nil {
+			undeclared++
+		} else {
+			declared[*rec.secret.DeclaredKeyBits]++
+		}
+	}
+
+	var counts []store.KeyBitsCount
+	if undeclared > 0 {
+		counts = append(counts, store.KeyBitsCount{Count: undeclared})
+	}
+	for _, bits := range slices.Sorted(maps.Keys(declared)) {
+		counts = append(counts, store.KeyBitsCount{Bits: &bits, Count: declared[bits]})
+	}
+	return counts, nil
+}
+
+// CountActive counts stored secrets that have not been shredded
+func (s *Store) CountActive(ctx context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var count int64
+	for id, rec := range s.secrets {
+		if rec.live() && !strings.HasPrefix(id, store.CanaryIDPrefix) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// NamespaceStats summarizes a namespace's live, unshredded secrets
+func (s *Store) NamespaceStats(ctx context.Context, namespace string, now time.Time) (*store.NamespaceStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var stats store.NamespaceStats
+	for _, rec := range s.secrets {
+		if rec.secret.Namespace != namespace || !rec.live() || !rec.secret.ExpiresAt.After(now) {
+			continue
+		}
+		stats.Count++
+		stats.TotalBytes += store.StoredBytes(&rec.secret)
+		if stats.OldestExpiry.IsZero() || rec.secret.ExpiresAt.Before(stats.OldestExpiry) {
+			stats.OldestExpiry = rec.secret.ExpiresAt
+		}
+	}
+	return &stats, nil
+}
+
+// ExpiryForecast counts live secrets per hour of expiry
+func (s *Store) ExpiryForecast(ctx context.Context, namespace string, from, to time.Time) ([]store.ExpiryBucket, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	byHour := make(map[time.Time]*store.ExpiryBucket)
+	for _, rec := range s.secrets {
+		expiresAt := rec.secret.ExpiresAt
+		if (namespace != "" && rec.secret.Namespace != namespace) || !rec.live() || !expiresAt.After(from) || expiresAt.After(to) {
+			continue
+		}
+		hour := expiresAt.UTC().Truncate(time.Hour)
+		bucket, ok := byHour[hour]
+		if !ok {
+			bucket = &store.ExpiryBucket{Hour: hour}
+			byHour[hour] = bucket
+		}
+		bucket.Count++
+		bucket.Bytes += store.StoredBytes(&rec.secret)
+	}
+
+	buckets := make([]store.ExpiryBucket, 0, len(byHour))
+	for _, bucket := range byHour {
+		buckets = append(buckets, *bucket)
+	}
+	slices.SortFunc(buckets, func(a, b store.ExpiryBucket) int { return a.Hour.Compare(b.Hour) })
+	return buckets, nil
+}
+
+// CreatorStats counts live secrets per creator
+func (s *Store) CreatorStats(ctx context.Context, now time.Time, quota int) (*store.CreatorStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var stats store.CreatorStats
+	for _, count := range s.activeByCreator(now) {
+		stats.Creators++
+		stats.MaxActive = max(stats.MaxActive, count)
+		if quota > 0 && count >= int64(quota) {
+			stats.AtQuota++
+		}
+	}
+	return &stats, nil
+}
+
+// PurgeNamespace removes every record in namespace, clearing data keys,
+// and counts those that were still live
+func (s *Store) PurgeNamespace(ctx context.Context, namespace string) (int64, error) {
+	_, live := s.deleteWhere(func(rec *record) bool {
+		return rec.secret.Namespace == namespace
+	})
+	return live, nil
+}
+
+// StartNamespaceDeletion records the deletion, or returns the one already
+// started
+func (s *Store) StartNamespaceDeletion(ctx context.Context, namespace string, now time.Time) (*store.NamespaceDeletion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deletion, ok := s.deletions[namespace]
+	if !ok {
+		deletion = &store.NamespaceDeletion{Namespace: namespace, StartedAt: now}
+		s.deletions[namespace] = deletion
+	}
+	progress := *deletion
+	return &progress, nil
+}
+
+// NamespaceDeletion returns a deletion's progress
+func (s *Store) NamespaceDeletion(ctx context.Context, namespace string) (*store.NamespaceDeletion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	deletion, ok := s.deletions[namespace]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	progress := *deletion
+	return &progress, nil
+}
+
+// NamespaceDeletions returns every started deletion by namespace
+func (s *Store) NamespaceDeletions(ctx context.Context) ([]store.NamespaceDeletion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var deletions []store.NamespaceDeletion
+	for _, namespace := range slices.Sorted(maps.Keys(s.deletions)) {
+		deletions = append(deletions, *s.deletions[namespace])
+	}
+	return deletions, nil
+}
+
+// DeleteNamespaceBatch removes up to limit of the namespace's secrets, or
+// once none are left clears it from up to limit audit events, and counts
+// them under the same lock
+func (s *Store) DeleteNamespaceBatch(ctx context.Context, namespace string, limit int, now time.Time) (*store.NamespaceDeletion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deletion, ok := s.deletions[namespace]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	var ids []string
+	for id, rec := range s.secrets {
+		if rec.secret.Namespace == namespace {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	for _, id := range ids[:min(limit, len(ids))] {
+		rec := s.secrets[id]
+		if rec.live() {
+			deletion.LiveSecretsDeleted++
+		}
+		clear(rec.secret.DataKey)
+		delete(s.secrets, id)
+		deletion.SecretsDeleted++
+		if _, ok := s.receipts[id]; ok {
+			delete(s.receipts, id)
+			deletion.ReceiptsDeleted++
+		}
+	}
+
+	if len(ids) < limit {
+		anonymized := 0
+		for i := range s.audit {
+			if anonymized == limit-len(ids) {
+				break
+			}
+			if s.audit[i].Namespace == namespace {
+				s.audit[i].Namespace = ""
+				anonymized++
+			}
+		}
+		deletion.AuditEventsAnonymized += int64(anonymized)
+
+		if anonymized < limit-len(ids) && deletion.FinishedAt.IsZero() {
+			deletion.FinishedAt = now
+		}
+	}
+
+	progress := *deletion
+	return &progress, nil
+}
+
+// NamespaceReferences counts secrets and audit events naming namespace
+func (s *Store) NamespaceReferences(ctx context.Context, namespace string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var n int64
+	for _, rec := range s.secrets {
+		if rec.secret.Namespace == namespace {
+			n++
+		}
+	}
+	for _, event := range s.audit {
+		if event.Namespace == namespace {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// DeleteExpired removes secrets that expired before now and counts those
+// that were still live. A secret held for an ack or grace window that is
+// still open is left to it.
+func (s *Store) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	_, live := s.deleteWhere(func(rec *record) bool {
+		return rec.secret.ExpiresAt.Before(now) && (!rec.held() || rec.ackDeadline.Before(now))
+	})
+	return live, nil
+}
+
+// ExpiredIDs returns up to limit live, unheld secrets that expired before
+// now
+func (s *Store) ExpiredIDs(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ids []string
+	for id, rec := range s.secrets {
+		if rec.live() && !rec.held() && rec.secret.ExpiresAt.Before(now) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids[:min(limit, len(ids))], nil
+}
+
+// CollectShredded removes wrapped records whose data key is gone
+func (s *Store) CollectShredded(ctx context.Context) (int64, error) {
+	removed, _ := s.deleteWhere(func(rec *record) bool {
+		return !rec.live()
+	})
+	return removed, nil
+}
+
+// ActiveSecrets returns copies of up to limit deliverable secrets in ID
+// order after afterID
+func (s *Store) ActiveSecrets(ctx context.Context, now time.Time, afterID string, limit int) ([]store.Secret, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var secrets []store.Secret
+	for id, rec := range s.secrets {
+		if id > afterID && rec.live() && !rec.held() && rec.secret.ExpiresAt.After(now) && !strings.HasPrefix(id, store.CanaryIDPrefix) {
+			secrets = append(secrets, *clone(&rec.secret))
+		}
+	}
+	slices.SortFunc(secrets, func(a, b store.Secret) int { return strings.Compare(a.ID, b.ID) })
+	if len(secrets) > limit {
+		secrets = secrets[:limit]
+	}
+	return secrets, nil
+}
+
+// hasDataKey reports whether the record holds a data key, as a row in the
+// SQL backends' secret_keys would
+func (r *record) hasDataKey() bool {
+	return r.keyWrapped && !r.shredded
+}
+
+// StaleDataKeys returns up to limit data keys not wrapped under version, in
+// secret ID order after afterID
+func (s *Store) StaleDataKeys(ctx context.Context, version, afterID string, limit int) ([]store.StoredDataKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []store.StoredDataKey
+	for id, rec := range s.secrets {
+		if id > afterID && rec.hasDataKey() && rec.secret.KeyVersion != version {
+			keys = append(keys, store.StoredDataKey{SecretID: id, Key: bytes.Clone(rec.secret.DataKey), Version: rec.secret.KeyVersion})
+		}
+	}
+	slices.SortFunc(keys, func(a, b store.StoredDataKey) int { return strings.Compare(a.SecretID, b.SecretID) })
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return keys, nil
+}
+
+// ReplaceDataKey swaps in a rewrapped data key if the stored one still
+// matches old. Consumes take the same lock, so one always finishes first.
+func (s *Store) ReplaceDataKey(ctx context.Context, old store.StoredDataKey, key []byte, version string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.secrets[old.SecretID]
+	if !ok || !rec.hasDataKey() || !bytes.Equal(rec.secret.DataKey, old.Key) {
+		return false, nil
+	}
+	clear(rec.secret.DataKey)
+	rec.secret.DataKey = bytes.Clone(key)
+	rec.secret.KeyVersion = version
+	return true, nil
+}
+
+// DataKeyVersions counts stored data keys per envelope key version
+func (s *Store) DataKeyVersions(ctx context.Context) ([]store.KeyVersionCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	byVersion := make(map[string]int64)
+	for _, rec := range s.secrets {
+		if rec.hasDataKey() {
+			byVersion[rec.secret.KeyVersion]++
+		}
+	}
+	counts := make([]store.KeyVersionCount, 0, len(byVersion))
+	for version, n := range byVersion {
+		counts = append(counts, store.KeyVersionCount{Version: version, DataKeys: n})
+	}
+	slices.SortFunc(counts, func(a, b store.KeyVersionCount) int { return strings.Compare(a.Version, b.Version) })
+	return counts, nil
+}
+
+// PruneReceipts removes read receipts consumed before cutoff
+func (s *Store) PruneReceipts(ctx context.Context, cutoff time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for id, receipt := range s.receipts {
+		if receipt.ConsumedAt.Before(cutoff) {
+			delete(s.receipts, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// BurnUnacknowledged removes held secrets whose ack or grace window ended
+// before now; receipts of require_ack secrets stay unacknowledged
+func (s *Store) BurnUnacknowledged(ctx context.Context, now time.Time) (int64, error) {
+	removed, _ := s.deleteWhere(func(rec *record) bool {
+		return rec.held() && rec.ackDeadline.Before(now) && rec.live()
+	})
+	return removed, nil
+}
+
+// ClampExpiry lowers expiries to ceiling for up to limit secrets that
+// expire after it, latest first. Held require_ack secrets are left to their
+// ack window.
+func (s *Store) ClampExpiry(ctx context.Context, ceiling time.Time, limit int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var clamp []*record
+	for _, rec := range s.secrets {
+		if rec.secret.ExpiresAt.After(ceiling) && !rec.held() {
+			clamp = append(clamp, rec)
+		}
+	}
+	slices.SortFunc(clamp, func(a, b *record) int {
+		return b.secret.ExpiresAt.Compare(a.secret.ExpiresAt)
+	})
+
+	var ids []string
+	for _, rec := range clamp[:min(limit, len(clamp))] {
+		rec.secret.ExpiresAt = ceiling
+		rec.secret.Revision++
+		ids = append(ids, rec.secret.ID)
+	}
+	return ids, nil
+}
+
+// Stragglers checks a sample of recent receipts for secrets that can still
+// be read
+func (s *Store) Stragglers(ctx context.Context, since, now time.Time, sample int) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type consumed struct {
+		id string
+		at time.Time
+	}
+	var recent []consumed
+	for id, receipt := range s.receipts {
+		if !receipt.ConsumedAt.Before(since) {
+			recent = append(recent, consumed{id, receipt.ConsumedAt})
+		}
+	}
+	slices.SortFunc(recent, func(a, b consumed) int {
+		return b.at.Compare(a.at)
+	})
+
+	var ids []string
+	for _, c := range recent[:min(sample, len(recent))] {
+		rec, ok := s.secrets[c.id]
+		if ok && rec.live() && (!rec.held() || rec.ackDeadline.Before(now)) {
+			ids = append(ids, c.id)
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// ReceiptIDs returns the IDs of secrets consumed between from and to
+func (s *Store) ReceiptIDs(ctx context.Context, from, to time.Time) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ids []string
+	for id, receipt := range s.receipts {
+		if !receipt.ConsumedAt.Before(from) && !receipt.ConsumedAt.After(to) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// RecordAudit appends an event to the audit log
+func (s *Store) RecordAudit(ctx context.Context, event *store.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, found := slices.BinarySearchFunc(s.audit, event.ID, func(e store.AuditEvent, id string) int {
+		return cmp.Compare(e.ID, id)
+	})
+	if found {
+		return ErrDuplicateAuditID
+	}
+	s.audit = slices.Insert(s.audit, i, *event)
+	return nil
+}
+
+// ScanAudit calls fn for matching audit events in ID order. Matches are
+// copied out first so fn runs without the lock held.
+func (s *Store) ScanAudit(ctx context.Context, filter store.AuditFilter, fn func(*store.AuditEvent) error) error {
+	s.mu.RLock()
+	var events []store.AuditEvent
+	for _, event := range s.audit {
+		if filter.Limit > 0 && len(events) == filter.Limit {
+			break
+		}
+		if matches(filter, &event) {
+			events = append(events, event)
+		}
+	}
+	s.mu.RUnlock()
+
+	for i := range events {
+		if err := fn(&events[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordDroppedWork appends loss records
+func (s *Store) RecordDroppedWork(ctx context.Context, losses []store.DroppedWork) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dropped = append(s.dropped, losses...)
+	return nil
+}
+
+// DroppedWorkSince sums loss records from since onward per kind and reason
+func (s *Store) DroppedWorkSince(ctx context.Context, since time.Time) ([]store.DroppedWork, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type key struct{ kind, reason string }
+	sums := make(map[key]*store.DroppedWork)
+	for _, loss := range s.dropped {
+		if loss.RecordedAt.Before(since) {
+			continue
+		}
+		k := key{loss.Kind, loss.Reason}
+		sum, ok := sums[k]
+		if !ok {
+			sum = &store.DroppedWork{Kind: loss.Kind, Reason: loss.Reason}
+			sums[k] = sum
+		}
+		sum.Count += loss.Count
+		if loss.RecordedAt.After(sum.RecordedAt) {
+			sum.RecordedAt = loss.RecordedAt
+		}
+	}
+
+	var losses []store.DroppedWork
+	for _, sum := range sums {
+		losses = append(losses, *sum)
+	}
+	slices.SortFunc(losses, func(a, b store.DroppedWork) int {
+		return cmp.Or(cmp.Compare(a.Kind, b.Kind), cmp.Compare(a.Reason, b.Reason))
+	})
+	return losses, nil
+}
+
+// PruneDroppedWork removes loss records written before cutoff
+func (s *Store) PruneDroppedWork(ctx context.Context, cutoff time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before := len(s.dropped)
+	s.dropped = slices.DeleteFunc(s.dropped, func(loss store.DroppedWork) bool {
+		return loss.RecordedAt.Before(cutoff)
+	})
+	return int64(before - len(s.dropped)), nil
+}
+
+// RecordSizeBucket increments a bucket's counter
+func (s *Store) RecordSizeBucket(ctx context.Context, upperBound int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sizes[upperBound]++
+	return nil
+}
+
+// SizeBuckets returns every bucket counter, smallest first
+func (s *Store) SizeBuckets(ctx context.Context) ([]store.SizeBucket, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var buckets []store.SizeBucket
+	for _, bound := range slices.Sorted(maps.Keys(s.sizes)) {
+		buckets = append(buckets, store.SizeBucket{UpperBound: bound, Count: s.sizes[bound]})
+	}
+	return buckets, nil
+}
+
+// AddDailyStats adds delta to its day's totals
+func (s *Store) AddDailyStats(ctx context.Context, delta store.DailyStats) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	day := store.StatsDay(delta.Day)
+	sum := s.days[day]
+	sum.Day = day
+	sum.Created += delta.Created
+	sum.Retrieved += delta.Retrieved
+	sum.Burned += delta.Burned
+	sum.Expired += delta.Expired
+	sum.Bytes += delta.Bytes
+	sum.TTLSeconds += delta.TTLSeconds
+	s.days[day] = sum
+	return nil
+}
+
+// DailyStats returns the totals for from through to, oldest first
+func (s *Store) DailyStats(ctx context.Context, from, to time.Time) ([]store.DailyStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	from, to = store.StatsDay(from), store.StatsDay(to)
+	var days []store.DailyStats
+	for _, day := range s.days {
+		if !day.Day.Before(from) && !day.Day.After(to) {
+			days = append(days, day)
+		}
+	}
+	slices.SortFunc(days, func(a, b store.DailyStats) int {
+		return a.Day.Compare(b.Day)
+	})
+	return days, nil
+}
+
+// SaveClientScores stores reputations; a stored score updated later than
+// the one given is kept
+func (s *Store) SaveClientScores(ctx context.Context, scores []store.ClientScore) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, score := range scores {
+		if stored, ok := s.scores[score.Key]; !ok || !stored.UpdatedAt.After(score.UpdatedAt) {
+			s.scores[score.Key] = score
+		}
+	}
+	return nil
+}
+
+// ClientScores returns the stored reputations of keys
+func (s *Store) ClientScores(ctx context.Context, keys []string) ([]store.ClientScore, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var scores []store.ClientScore
+	for _, key := range keys {
+		if score, ok := s.scores[key]; ok {
+			scores = append(scores, score)
+		}
+	}
+	return scores, nil
+}
+
+// LowestClientScores returns up to limit reputations, lowest score first
+func (s *Store) LowestClientScores(ctx context.Context, limit int) ([]store.ClientScore, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	scores := slices.SortedFunc(maps.Values(s.scores), func(a, b store.ClientScore) int {
+		return cmp.Or(cmp.Compare(a.Score, b.Score), cmp.Compare(a.Key, b.Key))
+	})
+	return scores[:min(limit, len(scores))], nil
+}
+
+// PruneClientScores removes reputations last updated before cutoff
+func (s *Store) PruneClientScores(ctx context.Context, cutoff time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var pruned int64
+	for key, score := range s.scores {
+		if score.UpdatedAt.Before(cutoff) {
+			delete(s.scores, key)
+			pruned++
+		}
+	}
+	return pruned, nil
+}
+
+// Ping always succeeds
+func (s *Store) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Close drops all data; the store stays usable, empty
+func (s *Store) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, rec := range s.secrets {
+		clear(rec.secret.DataKey)
+	}
+	clear(s.secrets)
+	clear(s.receipts)
+	s.audit = nil
+	s.dropped = nil
+	clear(s.sizes)
+	clear(s.days)
+	clear(s.scores)
+	clear(s.deletions)
+}
+
+// destroy shreds a wrapped secret's key or deletes a legacy record
+// outright. The caller holds the write lock.
+func (s *Store) destroy(id string, rec *record) {
+	if rec.keyWrapped {
+		clear(rec.secret.DataKey)
+		rec.secret.DataKey = nil
+		rec.secret.Hint = ""
+		rec.secret.NotifyEmail = nil
+		rec.secret.NotifyEmailHash = ""
+		rec.secret.ContentType = ""
+		rec.secret.Filename = ""
+		rec.shredded = true
+		return
+	}
+	delete(s.secrets, id)
+}
+
+// deleteWhere removes the records drop selects and counts them, and how
+// many of them were live
+func (s *Store) deleteWhere(drop func(*record) bool) (removed, live int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, rec := range s.secrets {
+		if drop(rec) {
+			if rec.live() {
+				live++
+			}
+			clear(rec.secret.DataKey)
+			delete(s.secrets, id)
+			removed++
+		}
+	}
+	return removed, live
+}
+
+// matches applies filter to one event the way AuditFilter.Where does in SQL
+func matches(filter store.AuditFilter, event *store.AuditEvent) bool {
+	if len(filter.Types) > 0 && !slices.Contains(filter.Types, event.Type) {
+		return false
+	}
+	if filter.Namespace != "" && event.Namespace != filter.Namespace {
+		return false
+	}
+	if !strings.HasPrefix(event.SecretIDHash, filter.SecretIDHashPrefix) {
+		return false
+	}
+	if filter.FromID != "" && event.ID < filter.FromID {
+		return false
+	}
+	if filter.BeforeID != "" && event.ID >= filter.BeforeID {
+		return false
+	}
+	if filter.AfterID != "" && event.ID <= filter.AfterID {
+		return false
+	}
+	return true
+}
+
+// clone deep-copies a secret so callers never share its byte slices
+func clone(secret *store.Secret) *store.Secret {
+	c := *secret
+	c.Ciphertext = bytes.Clone(secret.Ciphertext)
+	c.IV = bytes.Clone(secret.IV)
+	c.Salt = bytes.Clone(secret.Salt)
+	c.DataKey = bytes.Clone(secret.DataKey)
+	c.ManagementTokenHash = bytes.Clone(secret.ManagementTokenHash)
+	c.NotifyEmail = bytes.Clone(secret.NotifyEmail)
+	if secret.DeclaredKeyBits != nil {
+		bits := *secret.DeclaredKeyBits
+		c.DeclaredKeyBits = &bits
+	}
+	if secret.AvailableAfter != nil {
+		availableAfter := *secret.AvailableAfter
+		c.AvailableAfter = &availableAfter
+	}
+	c.Parts = make([]store.Part, len(secret.Parts))
+	for i, part := range secret.Parts {
+		c.Parts[i] = store.Part{Label: part.Label, Ciphertext: bytes.Clone(part.Ciphertext), IV: bytes.Clone(part.IV)}
+	}
+	if len(c.Parts) == 0 {
+		c.Parts = nil
+	}
+	return &c
+}
+
+var _ store.Store = (*Store)(nil)
@@ -0,0 +1,16 @@
+package memory
+
+import (
+	"testing"
+
+	"ots-backend/internal/store"
+	"ots-backend/internal/store/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		s := New()
+		t.Cleanup(s.Close)
+		return s
+	})
+}
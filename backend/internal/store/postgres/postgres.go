@@ -0,0 +1,1541 @@
+// Package postgres implements store.Store on PostgreSQL
+package postgres
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"ots-backend/internal/db"
+	"ots-backend/internal/sensitive"
+	"ots-backend/internal/store"
+	"ots-backend/internal/tracing"
+)
+
+// Store is the PostgreSQL secret store
+type Store struct {
+	db *db.DB
+}
+
+// New wraps an open database connection pool
+func New(database *db.DB) *Store {
+	return &Store{db: database}
+}
+
+// DB returns the underlying connection pool wrapper
+func (s *Store) DB() *db.DB {
+	return s.db
+}
+
+// uniqueViolation is the SQLSTATE of a duplicate key
+const uniqueViolation = "23505"
+
+// transientStates are the SQLSTATEs and SQLSTATE classes (two characters)
+// a retry may get past
+var transientStates = []string{
+	"08",    // connection exception
+	"40001", // serialization failure
+	"40P01", // deadlock detected
+	"53300", // too many connections
+	"55P03", // lock not available
+	"57014", // query canceled, which includes statement_timeout
+	"57P01", // admin shutdown
+	"57P03", // cannot connect now
+}
+
+// Transient reports whether err is a timeout, a failed connection or one of
+// transientStates
+func (s *Store) Transient(err error) bool {
+	var connectErr *pgconn.ConnectError
+	if pgconn.Timeout(err) || errors.As(err, &connectErr) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	for _, state := range transientStates {
+		if strings.HasPrefix(pgErr.Code, state) {
+			return true
+		}
+	}
+	return false
+}
+
+// namespaceLockClass is the first key of the advisory locks that order
+// creates into a namespace against the start of its deletion; the second
+// is the namespace's hashtext
+const namespaceLockClass int32 = 0x6f74736e // "otsn"
+
+// creatorLockClass keys the advisory locks that serialize one creator's
+// quota-checked creates, so two cannot both take the last slot
+const creatorLockClass int32 = 0x6f747363 // "otsc"
+
+// Create stores a new secret with its data key and parts in one transaction
+func (s *Store) Create(ctx context.Context, secret *store.Secret) (err error) {
+	ctx, span := tracing.Start(ctx, "postgres.create")
+	defer func() {
+		tracing.End(span, err, store.ErrDuplicateID, store.ErrNamespaceDeleted, store.ErrQuotaExceeded)
+	}()
+	tracing.SetSecretID(span, secret.ID)
+
+	tx, err := s.db.Pool().Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// The shared lock is held to commit, so a deletion starting now waits
+	// for this insert and its batches find the row
+	if secret.Namespace != "" {
+		var deleted bool
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock_shared($1, hashtext($2))`, namespaceLockClass, secret.Namespace); err != nil {
+			return fmt.Errorf("lock namespace: %w", err)
+		}
+		err = tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM namespace_deletions WHERE namespace = $1)`, secret.Namespace).Scan(&deleted)
+		if err != nil {
+			return fmt.Errorf("check namespace deletion: %w", err)
+		}
+		if deleted {
+			return store.ErrNamespaceDeleted
+		}
+	}
+
+	if secret.Creator != "" && secret.CreatorQuota > 0 {
+		var active int64
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1, hashtext($2))`, creatorLockClass, secret.Creator); err != nil {
+			return fmt.Errorf("lock creator: %w", err)
+		}
+		err = tx.QueryRow(ctx, `
+			SELECT COUNT(*) FROM secrets s
+			WHERE s.creator = $1 AND s.expires_at > $2
+			  AND (NOT s.key_wrapped OR EXISTS (SELECT 1 FROM secret_keys k WHERE k.secret_id = s.id))
+		`, secret.Creator, secret.CreatedAt).Scan(&active)
+		if err != nil {
+			return fmt.Errorf("count creator secrets: %w", err)
+		}
+		if active >= int64(secret.CreatorQuota) {
+			return store.ErrQuotaExceeded
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO secrets (id, ciphertext, iv, salt, expires_at, burn_after_read, created_at, key_wrapped, declared_key_bits, management_token_hash, require_ack, namespace, iv_embedded, available_after, creator, hint,
+		                     notify_email, notify_email_hash, stored_bytes, content_type, filename)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, ''), $13, $14, NULLIF($15, ''), NULLIF($16, ''), $17, NULLIF($18, ''), $19,
+		        NULLIF($20, ''), NULLIF($21, ''))
+	`, secret.ID, secret.Ciphertext, secret.IV, secret.Salt, secret.ExpiresAt, secret.BurnAfterRead, secret.CreatedAt,
+		secret.DataKey != nil, secret.DeclaredKeyBits, secret.ManagementTokenHash, secret.RequireAck, secret.Namespace, secret.IVEmbedded, secret.AvailableAfter,
+		secret.Creator, secret.Hint, secret.NotifyEmail, secret.NotifyEmailHash, store.StoredBytes(secret), secret.ContentType, secret.Filename)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return store.ErrDuplicateID
+	}
+	if err != nil {
+		return fmt.Errorf("insert secret: %w", err)
+	}
+
+	if secret.DataKey != nil {
+		_, err = tx.Exec(ctx, `INSERT INTO secret_keys (secret_id, data_key, key_version) VALUES ($1, $2, NULLIF($3, ''))`, secret.ID, secret.DataKey, secret.KeyVersion)
+		if err != nil {
+			return fmt.Errorf("insert secret key: %w", err)
+		}
+	}
+
+	for i, part := range secret.Parts {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO secret_parts (secret_id, position, label, ciphertext, iv)
+			VALUES ($1, $2, $3, $4, $5)
+		`, secret.ID, i, part.Label, part.Ciphertext, part.IV)
+		if err != nil {
+			return fmt.Errorf("insert secret part: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit secret: %w", err)
+	}
+
+	return nil
+}
+
+// Peek previews a secret Consume would deliver, leaving it in place.
+// Shredded and held rows are skipped as Consume skips them.
+func (s *Store) Peek(ctx context.Context, id string, now time.Time) (*store.Preview, error) {
+	var preview store.Preview
+	var availableAfter *time.Time
+	err := s.db.Pool().QueryRow(ctx, `
+		SELECT COALESCE(octet_length(s.ciphertext), 0)
+		       + COALESCE((SELECT SUM(octet_length(p.ciphertext)) FROM secret_parts p WHERE p.secret_id = s.id), 0),
+		       COALESCE(s.hint, ''), COALESCE(s.content_type, ''), COALESCE(s.filename, ''), s.available_after
+		FROM secrets s
+		LEFT JOIN secret_keys k ON k.secret_id = s.id
+		WHERE s.id = $1 AND s.expires_at > $2 AND s.ack_deadline IS NULL
+		  AND (NOT s.key_wrapped OR k.data_key IS NOT NULL)
+	`, id, now).Scan(&preview.Size, &preview.Hint, &preview.ContentType, &preview.Filename, &availableAfter)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("peek secret: %w", err)
+	}
+	if err := store.CheckAvailable(availableAfter, now); err != nil {
+		return nil, err
+	}
+	return &preview, nil
+}
+
+// Consume locks the row, reads the secret and destroys it in one transaction.
+// A require_ack secret is held for acknowledgement instead, and with a grace
+// hold any other secret for its grace window.
+func (s *Store) Consume(ctx context.Context, id string, opts store.ConsumeOptions) (_ *store.Secret, err error) {
+	ctx, span := tracing.Start(ctx, "postgres.consume")
+	defer func() { tracing.End(span, err, store.ErrNotFound, store.ErrNotYetAvailable) }()
+	tracing.SetSecretID(span, id)
+
+	tx, err := s.db.Pool().Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT `+secretColumns+`
+		FROM secrets s
+		WHERE s.id = $1 AND s.expires_at > $2
+		FOR UPDATE OF s
+	`, id, opts.Now)
+	if err != nil {
+		return nil, fmt.Errorf("query secret: %w", err)
+	}
+	locked, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[secretRow])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query secret: %w", err)
+	}
+	row := keyedSecretRow{secretRow: locked}
+	if row.DataKey, row.KeyVersion, err = lockedKey(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	secret, keyWrapped := row.secret(), row.KeyWrapped
+
+	// A wrapped row without its key has been shredded and awaits garbage collection
+	if keyWrapped && secret.DataKey == nil {
+		return nil, store.ErrNotFound
+	}
+
+	// A held secret was delivered already and only waits for its ack, or
+	// for a retry with its retrieval token within its grace window
+	if opts.RetrievalTokenHash != nil {
+		if row.AckDeadline == nil || secret.RequireAck || opts.Now.After(*row.AckDeadline) ||
+			subtle.ConstantTimeCompare(row.AckTokenHash, opts.RetrievalTokenHash) != 1 {
+			return nil, store.ErrNotFound
+		}
+	} else if row.AckDeadline != nil {
+		return nil, store.ErrNotFound
+	}
+
+	// A scheduled secret stays in place until its release
+	if err := store.CheckAvailable(secret.AvailableAfter, opts.Now); err != nil {
+		return nil, err
+	}
+
+	secret.Parts, err = loadParts(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Open != nil {
+		if err := opts.Open(secret); err != nil {
+			return nil, err
+		}
+	}
+	// A hold or an ending is a new revision; a retry ends a hold that was
+	// one already
+	if opts.RetrievalTokenHash == nil {
+		secret.Revision++
+	}
+
+	var acknowledged *bool
+	if secret.RequireAck && opts.Ack != nil {
+		_, err = tx.Exec(ctx, `
+			UPDATE secrets SET ack_token_hash = $2, ack_deadline = $3, hint = NULL, notify_email = NULL, notify_email_hash = NULL,
+			       content_type = NULL, filename = NULL, version = $4
+			WHERE id = $1
+		`, id, opts.Ack.TokenHash, opts.Ack.Deadline, secret.Revision)
+		if err != nil {
+			return nil, fmt.Errorf("hold secret for ack: %w", err)
+		}
+		acknowledged = new(bool)
+	} else if opts.Grace != nil && opts.RetrievalTokenHash == nil {
+		_, err = tx.Exec(ctx, `
+			UPDATE secrets SET ack_token_hash = $2, ack_deadline = $3, notify_email = NULL, notify_email_hash = NULL, version = $4
+			WHERE id = $1
+		`, id, opts.Grace.TokenHash, opts.Grace.Deadline, secret.Revision)
+		if err != nil {
+			return nil, fmt.Errorf("hold secret for grace window: %w", err)
+		}
+	} else if err := destroy(ctx, tx, id, keyWrapped); err != nil {
+		return nil, err
+	}
+
+	if opts.Receipt != nil && opts.RetrievalTokenHash == nil {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO secret_receipts (secret_id, consumed_at, network_class, acknowledged, reason, management_token_hash, country, version)
+			VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8)
+			ON CONFLICT (secret_id) DO NOTHING
+		`, id, opts.Receipt.ConsumedAt, opts.Receipt.NetworkClass, acknowledged, store.TerminationConsumed, secret.ManagementTokenHash, opts.Receipt.Country,
+			secret.Revision)
+		if err != nil {
+			return nil, fmt.Errorf("insert receipt: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit consume: %w", err)
+	}
+
+	return secret, nil
+}
+
+// Acknowledge destroys a held secret once its reader confirms receipt. A
+// window that has already lapsed burns the secret without acknowledging it.
+func (s *Store) Acknowledge(ctx context.Context, id string, tokenHash []byte, now time.Time, revision int64) error {
+	tx, err := s.db.Pool().Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var hash []byte
+	var deadline time.Time
+	var keyWrapped bool
+	var current int64
+	err = tx.QueryRow(ctx, `
+		SELECT s.ack_token_hash, s.ack_deadline, s.key_wrapped, s.version
+		FROM secrets s
+		WHERE s.id = $1 AND s.ack_deadline IS NOT NULL AND s.require_ack
+		FOR UPDATE OF s
+	`, id).Scan(&hash, &deadline, &keyWrapped, &current)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("query held secret: %w", err)
+	}
+	dataKey, _, err := lockedKey(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	if keyWrapped && dataKey == nil {
+		return store.ErrNotFound
+	}
+
+	if now.After(deadline) {
+		if err := destroy(ctx, tx, id, keyWrapped); err != nil {
+			return err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("commit lapsed ack: %w", err)
+		}
+		return store.ErrNotFound
+	}
+
+	if subtle.ConstantTimeCompare(hash, tokenHash) != 1 {
+		return store.ErrNotFound
+	}
+	if err := store.CheckRevision(revision, current); err != nil {
+		return err
+	}
+
+	if err := destroy(ctx, tx, id, keyWrapped); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE secret_receipts SET acknowledged = TRUE, version = $2 WHERE secret_id = $1`, id, current+1)
+	if err != nil {
+		return fmt.Errorf("acknowledge receipt: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit ack: %w", err)
+	}
+
+	return nil
+}
+
+// Burn destroys a secret: wrapped rows are crypto-shredded, legacy rows are
+// deleted outright
+func (s *Store) Burn(ctx context.Context, id string) (bool, error) {
+	tx, err := s.db.Pool().Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	burned, err := shredKey(ctx, tx, id)
+	if err != nil {
+		return false, err
+	}
+
+	if !burned {
+		result, err := tx.Exec(ctx, `DELETE FROM secrets WHERE id = $1 AND NOT key_wrapped`, id)
+		if err != nil {
+			return false, fmt.Errorf("delete secret: %w", err)
+		}
+		burned = result.RowsAffected() > 0
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("commit burn: %w", err)
+	}
+
+	return burned, nil
+}
+
+// Terminate locks a live secret, destroys it like Burn and stores its
+// tombstone in one transaction. The row is locked before its key, as
+// Consume takes them, so of a read and a burn racing only one ends it.
+func (s *Store) Terminate(ctx context.Context, id string, reason store.TerminationReason, now time.Time, revision int64, tombstone *store.Receipt) (_ *store.Secret, err error) {
+	if err := store.CheckTermination(reason); err != nil {
+		return nil, err
+	}
+	ctx, span := tracing.Start(ctx, "postgres.terminate")
+	defer func() { tracing.End(span, err, store.ErrNotFound) }()
+	tracing.SetSecretID(span, id)
+
+	tx, err := s.db.Pool().Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var secret store.Secret
+	var namespace *string
+	var keyWrapped, held bool
+	var tokenHash []byte
+	err = tx.QueryRow(ctx, `
+		SELECT s.id, s.namespace, s.notify_email, s.created_at, s.expires_at, s.key_wrapped, s.ack_deadline IS NOT NULL, s.management_token_hash, s.version
+		FROM secrets s
+		WHERE s.id = $1
+		FOR UPDATE OF s
+	`, id).Scan(&secret.ID, &namespace, &secret.NotifyEmail, &secret.CreatedAt, &secret.ExpiresAt, &keyWrapped, &held, &tokenHash, &secret.Revision)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query secret: %w", err)
+	}
+	secret.Namespace = deref(namespace)
+	dataKey, _, err := lockedKey(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if keyWrapped && dataKey == nil {
+		return nil, store.ErrNotFound
+	}
+	// A burn, like a read, finds nothing past the expiry; an expiry only a
+	// secret past it that is not held
+	if reason == store.TerminationBurned && !secret.ExpiresAt.After(now) {
+		return nil, store.ErrNotFound
+	}
+	if reason == store.TerminationExpired && (held || !secret.ExpiresAt.Before(now)) {
+		return nil, store.ErrNotFound
+	}
+	if err := store.CheckRevision(revision, secret.Revision); err != nil {
+		return nil, err
+	}
+	secret.Revision++
+
+	if err := destroy(ctx, tx, id, keyWrapped); err != nil {
+		return nil, err
+	}
+
+	if tombstone != nil {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO secret_receipts (secret_id, consumed_at, network_class, reason, management_token_hash, country, version)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+			ON CONFLICT (secret_id) DO UPDATE SET version = EXCLUDED.version
+		`, id, tombstone.ConsumedAt, tombstone.NetworkClass, reason, tokenHash, tombstone.Country, secret.Revision)
+		if err != nil {
+			return nil, fmt.Errorf("insert tombstone: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit terminate: %w", err)
+	}
+	return &secret, nil
+}
+
+// ManagementTokenHash returns the stored management token hash
+func (s *Store) ManagementTokenHash(ctx context.Context, id string) ([]byte, error) {
+	var hash []byte
+	err := s.db.QueryRow(ctx, `SELECT management_token_hash FROM secrets WHERE id = $1`, id).Scan(&hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query management token: %w", err)
+	}
+	return hash, nil
+}
+
+// Revision returns the revision of a secret whose key has not been shredded
+func (s *Store) Revision(ctx context.Context, id string) (int64, error) {
+	var revision int64
+	err := s.db.QueryRow(ctx, `
+		SELECT s.version
+		FROM secrets s
+		LEFT JOIN secret_keys k ON k.secret_id = s.id
+		WHERE s.id = $1 AND (NOT s.key_wrapped OR k.data_key IS NOT NULL)
+	`, id).Scan(&revision)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, store.ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("query revision: %w", err)
+	}
+	return revision, nil
+}
+
+// Receipt returns the read receipt recorded when the secret was consumed,
+// or the tombstone of its burn or expiry
+func (s *Store) Receipt(ctx context.Context, id string) (*store.Receipt, error) {
+	var receipt store.Receipt
+	var networkClass, country *string
+	err := s.db.QueryRow(ctx, `
+		SELECT consumed_at, network_class, acknowledged, reason, management_token_hash, country, version FROM secret_receipts WHERE secret_id = $1
+	`, id).Scan(&receipt.ConsumedAt, &networkClass, &receipt.Acknowledged, &receipt.Reason, &receipt.ManagementTokenHash, &country, &receipt.Revision)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query receipt: %w", err)
+	}
+	receipt.NetworkClass = deref(networkClass)
+	receipt.Country = deref(country)
+	return &receipt, nil
+}
+
+// DeclaredKeyBits counts live, unshredded secrets by declared key length
+func (s *Store) DeclaredKeyBits(ctx context.Context, now time.Time) ([]store.KeyBitsCount, error) {
+	rows, err := s.db.Pool().Query(ctx, `
+		SELECT s.declared_key_bits, COUNT(*)
+		FROM secrets s
+		WHERE s.expires_at > $1
+		  AND (NOT s.key_wrapped OR EXISTS (SELECT 1 FROM secret_keys k WHERE k.secret_id = s.id))
+		  AND substr(s.id, 1, $2) <> $3
+		GROUP BY s.declared_key_bits
+	`, now, len(store.CanaryIDPrefix), store.CanaryIDPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("query declared key bits: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []store.KeyBitsCount
+	for rows.Next() {
+		var count store.KeyBitsCount
+		if err := rows.Scan(&count.Bits, &count.Count); err != nil {
+			return nil, fmt.Errorf("scan declared key bits: %w", err)
+		}
+		counts = append(counts, count)
+	}
+
+	return counts, rows.Err()
+}
+
+// CountActive counts stored secrets that have not been shredded
+func (s *Store) CountActive(ctx context.Context) (int64, error) {
+	var count int64
+	err := s.db.QueryRow(ctx, `
+		SELECT COUNT(*) FROM secrets s
+		WHERE (NOT s.key_wrapped OR EXISTS (SELECT 1 FROM secret_keys k WHERE k.secret_id = s.id))
+		  AND substr(s.id, 1, $1) <> $2
+	`, len(store.CanaryIDPrefix), store.CanaryIDPrefix).Scan(&count)
+	return count, err
+}
+
+// CreatorStats counts live, unshredded secrets per creator using the
+// partial creator index
+func (s *Store) CreatorStats(ctx context.Context, now time.Time, quota int) (*store.CreatorStats, error) {
+	var stats store.CreatorStats
+	err := s.db.Pool().QueryRow(ctx, `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE $1 > 0 AND active >= $1), COALESCE(MAX(active), 0)
+		FROM (
+			SELECT COUNT(*) AS active FROM secrets s
+			WHERE s.creator IS NOT NULL AND s.expires_at > $2
+			  AND (NOT s.key_wrapped OR EXISTS (SELECT 1 FROM secret_keys k WHERE k.secret_id = s.id))
+			GROUP BY s.creator
+		) creators
+	`, quota, now).Scan(&stats.Creators, &stats.AtQuota, &stats.MaxActive)
+	if err != nil {
+		return nil, fmt.Errorf("query creator stats: %w", err)
+	}
+	return &stats, nil
+}
+
+// NamespaceStats summarizes a namespace's live, unshredded secrets using
+// the partial namespace index
+func (s *Store) NamespaceStats(ctx context.Context, namespace string, now time.Time) (*store.NamespaceStats, error) {
+	var stats store.NamespaceStats
+	var oldest *time.Time
+	err := s.db.QueryRow(ctx, `
+		SELECT COUNT(*),
+		       COALESCE(SUM(octet_length(s.ciphertext)
+		           + COALESCE((SELECT SUM(octet_length(p.ciphertext)) FROM secret_parts p WHERE p.secret_id = s.id), 0)), 0)::BIGINT,
+		       MIN(s.expires_at)
+		FROM secrets s
+		WHERE s.namespace = $1
+		  AND s.expires_at > $2
+		  AND (NOT s.key_wrapped OR EXISTS (SELECT 1 FROM secret_keys k WHERE k.secret_id = s.id))
+	`, namespace, now).Scan(&stats.Count, &stats.TotalBytes, &oldest)
+	if err != nil {
+		return nil, fmt.Errorf("query namespace stats: %w", err)
+	}
+	if oldest != nil {
+		stats.OldestExpiry = *oldest
+	}
+	return &stats, nil
+}
+
+// ExpiryForecast counts live, unshredded secrets per hour of expiry in one
+// grouped query. idx_secrets_expiry_forecast covers every secrets column it
+// reads and the shred check probes the secret_keys primary key, so on a
+// vacuumed table both are index-only scans.
+func (s *Store) ExpiryForecast(ctx context.Context, namespace string, from, to time.Time) ([]store.ExpiryBucket, error) {
+	rows, err := s.db.Pool().Query(ctx, `
+		SELECT date_trunc('hour', s.expires_at AT TIME ZONE 'UTC') AS hour, COUNT(*), COALESCE(SUM(s.stored_bytes), 0)::BIGINT
+		FROM secrets s
+		WHERE s.expires_at > $1 AND s.expires_at <= $2
+		  AND ($3 = '' OR s.namespace = $3)
+		  AND (NOT s.key_wrapped OR EXISTS (SELECT 1 FROM secret_keys k WHERE k.secret_id = s.id))
+		GROUP BY hour
+		ORDER BY hour
+	`, from, to, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("query expiry forecast: %w", err)
+	}
+	buckets, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (store.ExpiryBucket, error) {
+		var bucket store.ExpiryBucket
+		err := row.Scan(&bucket.Hour, &bucket.Count, &bucket.Bytes)
+		bucket.Hour = bucket.Hour.UTC()
+		return bucket, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan expiry forecast: %w", err)
+	}
+	return buckets, nil
+}
+
+// PurgeNamespace zeroes the namespace's data keys and deletes its secrets in
+// one transaction; keys and parts go with their rows. Only secrets that were
+// still live are counted.
+func (s *Store) PurgeNamespace(ctx context.Context, namespace string) (int64, error) {
+	tx, err := s.db.Pool().Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, purged, err := deleteLive(ctx, tx, `namespace = $1`, namespace)
+	if err != nil {
+		return 0, fmt.Errorf("purge namespace: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit purge: %w", err)
+	}
+	return purged, nil
+}
+
+// StartNamespaceDeletion records the deletion, or returns the one already
+// started. The exclusive lock waits out creates into the namespace that
+// are still in flight; any later create finds the row and is refused.
+func (s *Store) StartNamespaceDeletion(ctx context.Context, namespace string, now time.Time) (*store.NamespaceDeletion, error) {
+	tx, err := s.db.Pool().Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1, hashtext($2))`, namespaceLockClass, namespace); err != nil {
+		return nil, fmt.Errorf("lock namespace: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO namespace_deletions (namespace, started_at) VALUES ($1, $2)
+		ON CONFLICT (namespace) DO NOTHING
+	`, namespace, now)
+	if err != nil {
+		return nil, fmt.Errorf("start namespace deletion: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit namespace deletion: %w", err)
+	}
+	return s.NamespaceDeletion(ctx, namespace)
+}
+
+// NamespaceDeletion returns a deletion's progress
+func (s *Store) NamespaceDeletion(ctx context.Context, namespace string) (*store.NamespaceDeletion, error) {
+	deletion, err := scanNamespaceDeletion(s.db.Pool().QueryRow(ctx, `
+		SELECT `+namespaceDeletionColumns+` FROM namespace_deletions WHERE namespace = $1
+	`, namespace))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query namespace deletion: %w", err)
+	}
+	return deletion, nil
+}
+
+// NamespaceDeletions returns every started deletion by namespace
+func (s *Store) NamespaceDeletions(ctx context.Context) ([]store.NamespaceDeletion, error) {
+	rows, err := s.db.Pool().Query(ctx, `
+		SELECT `+namespaceDeletionColumns+` FROM namespace_deletions ORDER BY namespace
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query namespace deletions: %w", err)
+	}
+	defer rows.Close()
+
+	var deletions []store.NamespaceDeletion
+	for rows.Next() {
+		deletion, err := scanNamespaceDeletion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan namespace deletion: %w", err)
+		}
+		deletions = append(deletions, *deletion)
+	}
+	return deletions, rows.Err()
+}
+
+// DeleteNamespaceBatch removes up to limit of the namespace's secrets, or
+// once none are left clears it from up to limit audit events, and adds the
+// counts to the deletion in the same transaction. The deletion row is
+// locked first, so batches for one namespace run one at a time and none
+// mistakes another's locked rows for an empty namespace.
+func (s *Store) DeleteNamespaceBatch(ctx context.Context, namespace string, limit int, now time.Time) (*store.NamespaceDeletion, error) {
+	tx, err := s.db.Pool().Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var started bool
+	err = tx.QueryRow(ctx, `SELECT true FROM namespace_deletions WHERE namespace = $1 FOR UPDATE`, namespace).Scan(&started)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lock namespace deletion: %w", err)
+	}
+
+	batch := `id IN (SELECT id FROM secrets WHERE namespace = $1 ORDER BY id LIMIT $2)`
+	result, err := tx.Exec(ctx, `DELETE FROM secret_receipts WHERE secret_`+batch, namespace, limit)
+	if err != nil {
+		return nil, fmt.Errorf("delete namespace receipts: %w", err)
+	}
+	receipts := result.RowsAffected()
+	secrets, live, err := deleteLive(ctx, tx, batch, namespace, limit)
+	if err != nil {
+		return nil, fmt.Errorf("delete namespace secrets: %w", err)
+	}
+
+	var anonymized int64
+	if remaining := int64(limit) - secrets; remaining > 0 {
+		result, err := tx.Exec(ctx, `
+			UPDATE audit_events SET namespace = ''
+			WHERE id IN (SELECT id FROM audit_events WHERE namespace = $1 ORDER BY id LIMIT $2 FOR UPDATE)
+		`, namespace, remaining)
+		if err != nil {
+			return nil, fmt.Errorf("anonymize namespace audit events: %w", err)
+		}
+		anonymized = result.RowsAffected()
+
+		if anonymized < remaining {
+			_, err = tx.Exec(ctx, `
+				UPDATE namespace_deletions SET finished_at = $2 WHERE namespace = $1 AND finished_at IS NULL
+			`, namespace, now)
+			if err != nil {
+				return nil, fmt.Errorf("finish namespace deletion: %w", err)
+			}
+		}
+	}
+
+	deletion, err := scanNamespaceDeletion(tx.QueryRow(ctx, `
+		UPDATE namespace_deletions
+		SET secrets_deleted = secrets_deleted + $2,
+		    live_secrets_deleted = live_secrets_deleted + $3,
+		    receipts_deleted = receipts_deleted + $4,
+		    audit_events_anonymized = audit_events_anonymized + $5
+		WHERE namespace = $1
+		RETURNING `+namespaceDeletionColumns, namespace, secrets, live, receipts, anonymized))
+	if err != nil {
+		return nil, fmt.Errorf("record namespace deletion progress: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit namespace deletion batch: %w", err)
+	}
+	return deletion, nil
+}
+
+// NamespaceReferences counts secrets and audit events naming namespace
+func (s *Store) NamespaceReferences(ctx context.Context, namespace string) (int64, error) {
+	var n int64
+	err := s.db.Pool().QueryRow(ctx, `
+		SELECT (SELECT COUNT(*) FROM secrets WHERE namespace = $1)
+		     + (SELECT COUNT(*) FROM audit_events WHERE namespace = $1)
+	`, namespace).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("count namespace references: %w", err)
+	}
+	return n, nil
+}
+
+// DeleteExpired removes secrets that expired before now and counts those
+// that were still live. A secret held for an ack or grace window that is
+// still open is left to it.
+func (s *Store) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	tx, err := s.db.Pool().Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, expired, err := deleteLive(ctx, tx, `expires_at < $1 AND (ack_deadline IS NULL OR ack_deadline < $1)`, now)
+	if err != nil {
+		return 0, fmt.Errorf("delete expired: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit delete expired: %w", err)
+	}
+	return expired, nil
+}
+
+// ExpiredIDs returns up to limit live, unheld secrets that expired before
+// now
+func (s *Store) ExpiredIDs(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	rows, err := s.db.Pool().Query(ctx, `
+		SELECT s.id FROM secrets s
+		WHERE s.expires_at < $1 AND s.ack_deadline IS NULL
+		  AND (NOT s.key_wrapped OR EXISTS (SELECT 1 FROM secret_keys k WHERE k.secret_id = s.id))
+		ORDER BY s.id
+		LIMIT $2
+	`, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query expired secrets: %w", err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("query expired secrets: %w", err)
+	}
+	return ids, nil
+}
+
+// CollectShredded removes wrapped ciphertext rows whose data key is gone
+func (s *Store) CollectShredded(ctx context.Context) (int64, error) {
+	result, err := s.db.Pool().Exec(ctx, `
+		DELETE FROM secrets s
+		WHERE s.key_wrapped
+		  AND NOT EXISTS (SELECT 1 FROM secret_keys k WHERE k.secret_id = s.id)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// ActiveSecrets reads a page of deliverable secrets and their parts in one
+// transaction, so a page is a consistent snapshot
+func (s *Store) ActiveSecrets(ctx context.Context, now time.Time, afterID string, limit int) ([]store.Secret, error) {
+	tx, err := s.db.Pool().Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT `+secretColumns+`, k.data_key, k.key_version
+		FROM secrets s
+		LEFT JOIN secret_keys k ON k.secret_id = s.id
+		WHERE s.id > $1 AND s.expires_at > $2 AND s.ack_deadline IS NULL
+		  AND (NOT s.key_wrapped OR k.secret_id IS NOT NULL)
+		  AND substr(s.id, 1, $3) <> $4
+		ORDER BY s.id
+		LIMIT $5
+	`, afterID, now, len(store.CanaryIDPrefix), store.CanaryIDPrefix, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query active secrets: %w", err)
+	}
+	page, err := pgx.CollectRows(rows, pgx.RowToStructByName[keyedSecretRow])
+	if err != nil {
+		return nil, fmt.Errorf("query active secrets: %w", err)
+	}
+
+	secrets := make([]store.Secret, 0, len(page))
+	for i := range page {
+		secret := page[i].secret()
+		if secret.Parts, err = loadParts(ctx, tx, secret.ID); err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, *secret)
+	}
+	return secrets, tx.Commit(ctx)
+}
+
+// StaleDataKeys returns up to limit data keys not wrapped under version,
+// walking secret_keys in ID order after afterID
+func (s *Store) StaleDataKeys(ctx context.Context, version, afterID string, limit int) ([]store.StoredDataKey, error) {
+	rows, err := s.db.Pool().Query(ctx, `
+		SELECT secret_id, data_key, COALESCE(key_version, '')
+		FROM secret_keys
+		WHERE key_version IS DISTINCT FROM $1 AND secret_id > $2
+		ORDER BY secret_id
+		LIMIT $3
+	`, version, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query stale data keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []store.StoredDataKey
+	for rows.Next() {
+		var key store.StoredDataKey
+		if err := rows.Scan(&key.SecretID, &key.Key, &key.Version); err != nil {
+			return nil, fmt.Errorf("scan data key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// ReplaceDataKey swaps in a rewrapped data key. The old wrapped key is the
+// row version: a key changed or shredded since it was read does not match.
+// The secret row is locked with SKIP LOCKED, so a consume or burn holding
+// it makes this a no-op instead of a wait, and one arriving later waits
+// only for this single statement.
+func (s *Store) ReplaceDataKey(ctx context.Context, old store.StoredDataKey, key []byte, version string) (bool, error) {
+	result, err := s.db.Pool().Exec(ctx, `
+		WITH target AS (
+			SELECT id FROM secrets WHERE id = $1 FOR UPDATE SKIP LOCKED
+		)
+		UPDATE secret_keys k SET data_key = $3, key_version = NULLIF($4, '')
+		FROM target
+		WHERE k.secret_id = target.id AND k.data_key = $2
+	`, old.SecretID, old.Key, key, version)
+	if err != nil {
+		return false, fmt.Errorf("replace data key: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// DataKeyVersions counts stored data keys per envelope key version
+func (s *Store) DataKeyVersions(ctx context.Context) ([]store.KeyVersionCount, error) {
+	rows, err := s.db.Pool().Query(ctx, `
+		SELECT COALESCE(key_version, ''), COUNT(*)
+		FROM secret_keys
+		GROUP BY 1
+		ORDER BY 1
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("count data key versions: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []store.KeyVersionCount
+	for rows.Next() {
+		var count store.KeyVersionCount
+		if err := rows.Scan(&count.Version, &count.DataKeys); err != nil {
+			return nil, fmt.Errorf("scan data key version: %w", err)
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+// PruneReceipts removes read receipts consumed before cutoff
+func (s *Store) PruneReceipts(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := s.db.Pool().Exec(ctx, `DELETE FROM secret_receipts WHERE consumed_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// BurnUnacknowledged removes held secrets whose ack or grace window ended
+// before now; receipts of require_ack secrets stay unacknowledged
+func (s *Store) BurnUnacknowledged(ctx context.Context, now time.Time) (int64, error) {
+	result, err := s.db.Pool().Exec(ctx, `
+		DELETE FROM secrets s
+		WHERE s.ack_deadline < $1
+		  AND (NOT s.key_wrapped OR EXISTS (SELECT 1 FROM secret_keys k WHERE k.secret_id = s.id))
+	`, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// ClampExpiry lowers expires_at to ceiling for up to limit secrets that
+// expire after it. Held require_ack secrets are left to their ack window.
+func (s *Store) ClampExpiry(ctx context.Context, ceiling time.Time, limit int) ([]string, error) {
+	rows, err := s.db.Pool().Query(ctx, `
+		UPDATE secrets SET expires_at = $1, version = version + 1
+		WHERE id IN (
+			SELECT id FROM secrets
+			WHERE expires_at > $1 AND ack_deadline IS NULL
+			ORDER BY expires_at DESC
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id
+	`, ceiling, limit)
+	if err != nil {
+		return nil, fmt.Errorf("clamp expiry: %w", err)
+	}
+	defer rows.Close()
+
+	return scanIDs(rows)
+}
+
+// Stragglers joins a sample of recent receipts back to secrets. A receipt
+// is written in the consuming transaction, so any readable row behind it
+// escaped destruction.
+func (s *Store) Stragglers(ctx context.Context, since, now time.Time, sample int) ([]string, error) {
+	rows, err := s.db.Pool().Query(ctx, `
+		WITH sampled AS (
+			SELECT secret_id FROM secret_receipts
+			WHERE consumed_at >= $1
+			ORDER BY consumed_at DESC
+			LIMIT $3
+		)
+		SELECT s.id
+		FROM sampled r
+		JOIN secrets s ON s.id = r.secret_id
+		WHERE (NOT s.key_wrapped OR EXISTS (SELECT 1 FROM secret_keys k WHERE k.secret_id = s.id))
+		  AND (s.ack_deadline IS NULL OR s.ack_deadline < $2)
+		ORDER BY s.id
+	`, since, now, sample)
+	if err != nil {
+		return nil, fmt.Errorf("query stragglers: %w", err)
+	}
+	defer rows.Close()
+
+	return scanIDs(rows)
+}
+
+// ReceiptIDs returns the IDs of secrets consumed between from and to
+func (s *Store) ReceiptIDs(ctx context.Context, from, to time.Time) ([]string, error) {
+	rows, err := s.db.Pool().Query(ctx, `
+		SELECT secret_id FROM secret_receipts
+		WHERE consumed_at BETWEEN $1 AND $2
+		ORDER BY secret_id
+	`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("query receipts: %w", err)
+	}
+	defer rows.Close()
+
+	return scanIDs(rows)
+}
+
+// RecordAudit appends an event to the audit log
+func (s *Store) RecordAudit(ctx context.Context, event *store.AuditEvent) error {
+	_, err := s.db.Pool().Exec(ctx, `
+		INSERT INTO audit_events (id, event_type, occurred_at, namespace, secret_id_hash, network_class, reason, actor)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, event.ID, event.Type, event.OccurredAt, event.Namespace, event.SecretIDHash, event.NetworkClass, event.Reason, event.Actor)
+	if err != nil {
+		return fmt.Errorf("insert audit event: %w", err)
+	}
+	return nil
+}
+
+// ScanAudit streams matching audit events in primary key order, so the scan
+// walks the ULID index instead of sorting
+func (s *Store) ScanAudit(ctx context.Context, filter store.AuditFilter, fn func(*store.AuditEvent) error) error {
+	where, args := filter.Where(func(n int) string { return "$" + strconv.Itoa(n) })
+	query := `
+		SELECT id, event_type, occurred_at, namespace, secret_id_hash, network_class, reason, actor
+		FROM audit_events
+		WHERE ` + where + `
+		ORDER BY id`
+	if filter.Limit > 0 {
+		query += " LIMIT " + strconv.Itoa(filter.Limit)
+	}
+
+	rows, err := s.db.Pool().Query(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("query audit events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event store.AuditEvent
+		var networkClass, reason, actor *string
+		if err := rows.Scan(&event.ID, &event.Type, &event.OccurredAt, &event.Namespace, &event.SecretIDHash, &networkClass, &reason, &actor); err != nil {
+			return fmt.Errorf("scan audit event: %w", err)
+		}
+		if networkClass != nil {
+			event.NetworkClass = *networkClass
+		}
+		if reason != nil {
+			event.Reason = *reason
+		}
+		if actor != nil {
+			event.Actor = *actor
+		}
+
+		if err := fn(&event); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// RecordDroppedWork inserts loss records in one transaction
+func (s *Store) RecordDroppedWork(ctx context.Context, losses []store.DroppedWork) error {
+	tx, err := s.db.Pool().Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, loss := range losses {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO dropped_work (kind, reason, count, recorded_at)
+			VALUES ($1, $2, $3, $4)
+		`, loss.Kind, loss.Reason, loss.Count, loss.RecordedAt)
+		if err != nil {
+			return fmt.Errorf("insert dropped work: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit dropped work: %w", err)
+	}
+	return nil
+}
+
+// DroppedWorkSince sums loss records from since onward per kind and reason
+func (s *Store) DroppedWorkSince(ctx context.Context, since time.Time) ([]store.DroppedWork, error) {
+	rows, err := s.db.Pool().Query(ctx, `
+		SELECT kind, reason, SUM(count), MAX(recorded_at)
+		FROM dropped_work
+		WHERE recorded_at >= $1
+		GROUP BY kind, reason
+		ORDER BY kind, reason
+	`, since)
+	if err != nil {
+		return nil, fmt.Errorf("query dropped work: %w", err)
+	}
+	defer rows.Close()
+
+	var losses []store.DroppedWork
+	for rows.Next() {
+		var loss store.DroppedWork
+		if err := rows.Scan(&loss.Kind, &loss.Reason, &loss.Count, &loss.RecordedAt); err != nil {
+			return nil, fmt.Errorf("scan dropped work: %w", err)
+		}
+		losses = append(losses, loss)
+	}
+	return losses, rows.Err()
+}
+
+// PruneDroppedWork removes loss records written before cutoff
+func (s *Store) PruneDroppedWork(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := s.db.Pool().Exec(ctx, `DELETE FROM dropped_work WHERE recorded_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// RecordSizeBucket increments a bucket's counter, creating it on first use
+func (s *Store) RecordSizeBucket(ctx context.Context, upperBound int64) error {
+	_, err := s.db.Pool().Exec(ctx, `
+		INSERT INTO secret_size_buckets (upper_bound, count) VALUES ($1, 1)
+		ON CONFLICT (upper_bound) DO UPDATE SET count = secret_size_buckets.count + 1
+	`, upperBound)
+	if err != nil {
+		return fmt.Errorf("record size bucket: %w", err)
+	}
+	return nil
+}
+
+// SizeBuckets returns every bucket counter, smallest first
+func (s *Store) SizeBuckets(ctx context.Context) ([]store.SizeBucket, error) {
+	rows, err := s.db.Pool().Query(ctx, `SELECT upper_bound, count FROM secret_size_buckets ORDER BY upper_bound`)
+	if err != nil {
+		return nil, fmt.Errorf("query size buckets: %w", err)
+	}
+	defer rows.Close()
+
+	var buckets []store.SizeBucket
+	for rows.Next() {
+		var bucket store.SizeBucket
+		if err := rows.Scan(&bucket.UpperBound, &bucket.Count); err != nil {
+			return nil, fmt.Errorf("scan size bucket: %w", err)
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets, rows.Err()
+}
+
+// AddDailyStats adds delta to its day's row, creating it on first use
+func (s *Store) AddDailyStats(ctx context.Context, delta store.DailyStats) error {
+	_, err := s.db.Pool().Exec(ctx, `
+		INSERT INTO daily_stats (day, created, retrieved, burned, expired, bytes, ttl_seconds)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (day) DO UPDATE SET
+			created = daily_stats.created + EXCLUDED.created,
+			retrieved = daily_stats.retrieved + EXCLUDED.retrieved,
+			burned = daily_stats.burned + EXCLUDED.burned,
+			expired = daily_stats.expired + EXCLUDED.expired,
+			bytes = daily_stats.bytes + EXCLUDED.bytes,
+			ttl_seconds = daily_stats.ttl_seconds + EXCLUDED.ttl_seconds
+	`, store.StatsDay(delta.Day), delta.Created, delta.Retrieved, delta.Burned, delta.Expired, delta.Bytes, delta.TTLSeconds)
+	if err != nil {
+		return fmt.Errorf("add daily stats: %w", err)
+	}
+	return nil
+}
+
+// DailyStats returns the rows for from through to, oldest first
+func (s *Store) DailyStats(ctx context.Context, from, to time.Time) ([]store.DailyStats, error) {
+	rows, err := s.db.Pool().Query(ctx, `
+		SELECT day, created, retrieved, burned, expired, bytes, ttl_seconds
+		FROM daily_stats
+		WHERE day BETWEEN $1 AND $2
+		ORDER BY day
+	`, store.StatsDay(from), store.StatsDay(to))
+	if err != nil {
+		return nil, fmt.Errorf("query daily stats: %w", err)
+	}
+	defer rows.Close()
+
+	var days []store.DailyStats
+	for rows.Next() {
+		var day store.DailyStats
+		if err := rows.Scan(&day.Day, &day.Created, &day.Retrieved, &day.Burned, &day.Expired, &day.Bytes, &day.TTLSeconds); err != nil {
+			return nil, fmt.Errorf("scan daily stats: %w", err)
+		}
+		day.Day = store.StatsDay(day.Day)
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
+
+// SaveClientScores upserts reputations in one transaction; a stored score
+// updated later than the one given is kept
+func (s *Store) SaveClientScores(ctx context.Context, scores []store.ClientScore) error {
+	tx, err := s.db.Pool().Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, score := range scores {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO client_reputation (client_key, score, updated_at)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (client_key) DO UPDATE
+			SET score = EXCLUDED.score, updated_at = EXCLUDED.updated_at
+			WHERE client_reputation.updated_at <= EXCLUDED.updated_at
+		`, score.Key, score.Score, score.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("save client score: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit client scores: %w", err)
+	}
+	return nil
+}
+
+// ClientScores returns the stored reputations of keys
+func (s *Store) ClientScores(ctx context.Context, keys []string) ([]store.ClientScore, error) {
+	rows, err := s.db.Pool().Query(ctx, `
+		SELECT client_key, score, updated_at FROM client_reputation WHERE client_key = ANY($1)
+	`, keys)
+	if err != nil {
+		return nil, fmt.Errorf("query client scores: %w", err)
+	}
+	return collectClientScores(rows)
+}
+
+// LowestClientScores returns up to limit reputations, lowest score first
+func (s *Store) LowestClientScores(ctx context.Context, limit int) ([]store.ClientScore, error) {
+	rows, err := s.db.Pool().Query(ctx, `
+		SELECT client_key, score, updated_at FROM client_reputation
+		ORDER BY score, client_key
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query lowest client scores: %w", err)
+	}
+	return collectClientScores(rows)
+}
+
+// collectClientScores scans and closes rows of client_key, score, updated_at
+func collectClientScores(rows pgx.Rows) ([]store.ClientScore, error) {
+	defer rows.Close()
+
+	var scores []store.ClientScore
+	for rows.Next() {
+		var score store.ClientScore
+		if err := rows.Scan(&score.Key, &score.Score, &score.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan client score: %w", err)
+		}
+		scores = append(scores, score)
+	}
+	return scores, rows.Err()
+}
+
+// PruneClientScores removes reputations last updated before cutoff
+func (s *Store) PruneClientScores(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := s.db.Pool().Exec(ctx, `DELETE FROM client_reputation WHERE updated_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// Ping checks the database connection
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.Health(ctx)
+}
+
+// Warm opens and primes the pool's minimum connections
+func (s *Store) Warm(ctx context.Context) error {
+	return s.db.Warm(ctx)
+}
+
+// PoolStats reports the pgx pool's statistics
+func (s *Store) PoolStats() store.PoolStats {
+	stats := s.db.Stats()
+	return store.PoolStats{
+		AcquiredConns:        int64(stats.AcquiredConns),
+		IdleConns:            int64(stats.IdleConns),
+		TotalConns:           int64(stats.TotalConns),
+		MaxConns:             int64(stats.MaxConns),
+		AcquireCount:         stats.AcquireCount,
+		AcquireDuration:      stats.AcquireDuration,
+		CanceledAcquireCount: stats.CanceledAcquireCount,
+		EmptyAcquireCount:    stats.EmptyAcquireCount,
+		NewConnsCount:        stats.NewConnsCount,
+	}
+}
+
+// HasColumn reports whether table, and column when given, exist in the
+// connection's schema
+func (s *Store) HasColumn(ctx context.Context, table, column string) (bool, error) {
+	var exists bool
+	err := s.db.Pool().QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM information_schema.columns
+			WHERE table_schema = current_schema()
+			  AND table_name = $1
+			  AND ($2::text = '' OR column_name = $2::text)
+		)
+	`, table, column).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("inspect schema: %w", err)
+	}
+	return exists, nil
+}
+
+// Close closes the connection pool
+func (s *Store) Close() {
+	s.db.Close()
+}
+
+// loadParts reads a secret's parts in order; single-blob secrets have none
+func loadParts(ctx context.Context, tx pgx.Tx, id string) ([]store.Part, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT label, ciphertext, iv
+		FROM secret_parts
+		WHERE secret_id = $1
+		ORDER BY position
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("query secret parts: %w", err)
+	}
+	defer rows.Close()
+
+	var parts []store.Part
+	for rows.Next() {
+		var part store.Part
+		if err := rows.Scan(&part.Label, &part.Ciphertext, &part.IV); err != nil {
+			return nil, fmt.Errorf("scan secret part: %w", err)
+		}
+		parts = append(parts, part)
+	}
+
+	return parts, rows.Err()
+}
+
+// destroy shreds a wrapped secret's key or deletes a legacy row outright
+func destroy(ctx context.Context, tx pgx.Tx, id string, keyWrapped bool) error {
+	if keyWrapped {
+		// Shred the key; the ciphertext row is collected later
+		_, err := shredKey(ctx, tx, id)
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM secrets WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("delete secret: %w", err)
+	}
+	return nil
+}
+
+// deleteLive zeroes the data keys of the secrets matching where and deletes
+// them, returning how many rows it removed and how many of those were still
+// live: legacy rows plus wrapped rows whose key was not shredded yet. Secret
+// rows are locked before their keys, the order Consume takes them in, so a
+// consume, ack or burn that got there first is not counted again and one
+// that comes later finds nothing.
+func deleteLive(ctx context.Context, tx pgx.Tx, where string, args ...any) (removed, live int64, err error) {
+	rows, err := tx.Query(ctx, `SELECT id, key_wrapped FROM secrets WHERE `+where+` ORDER BY id FOR UPDATE`, args...)
+	if err != nil {
+		return 0, 0, fmt.Errorf("lock secrets: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		var keyWrapped bool
+		if err := rows.Scan(&id, &keyWrapped); err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("scan secret: %w", err)
+		}
+		ids = append(ids, id)
+		if !keyWrapped {
+			live++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, 0, fmt.Errorf("lock secrets: %w", err)
+	}
+	if len(ids) == 0 {
+		return 0, 0, nil
+	}
+
+	// Keys shredded since are gone and not counted
+	result, err := tx.Exec(ctx, `
+		UPDATE secret_keys
+		SET data_key = decode(repeat('00', length(data_key)), 'hex')
+		WHERE secret_id = ANY($1)
+	`, ids)
+	if err != nil {
+		return 0, 0, fmt.Errorf("zero secret keys: %w", err)
+	}
+	live += result.RowsAffected()
+
+	if _, err := tx.Exec(ctx, `DELETE FROM secrets WHERE id = ANY($1)`, ids); err != nil {
+		return 0, 0, fmt.Errorf("delete secrets: %w", err)
+	}
+	return int64(len(ids)), live, nil
+}
+
+// lockedKey reads the data key of a secret whose row the transaction has
+// locked, nil once shredded. It must run as its own statement after the
+// lock: a key joined into the locking select is read from the snapshot
+// taken before the lock wait, so a reader queued behind a consume that
+// shredded the key would still see it and read the secret a second time.
+func lockedKey(ctx context.Context, tx pgx.Tx, id string) (sensitive.Bytes, *string, error) {
+	var dataKey sensitive.Bytes
+	var version *string
+	err := tx.QueryRow(ctx, `SELECT data_key, key_version FROM secret_keys WHERE secret_id = $1`, id).Scan(&dataKey, &version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("query secret key: %w", err)
+	}
+	return dataKey, version, nil
+}
+
+// shredKey overwrites a secret's data key with zeros and deletes it, leaving
+// the wrapped ciphertext unrecoverable. The zeroing UPDATE ensures the key
+// bytes are replaced in the heap page rather than just marked dead. The
+// secret's hint, notify address, content type and file name are dropped
+// with its key.
+func shredKey(ctx context.Context, tx pgx.Tx, id string) (bool, error) {
+	_, err := tx.Exec(ctx, `
+		UPDATE secret_keys
+		SET data_key = decode(repeat('00', length(data_key)), 'hex')
+		WHERE secret_id = $1
+	`, id)
+	if err != nil {
+		return false, fmt.Errorf("zero secret key: %w", err)
+	}
+
+	result, err := tx.Exec(ctx, `DELETE FROM secret_keys WHERE secret_id = $1`, id)
+	if err != nil {
+		return false, fmt.Errorf("delete secret key: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE secrets SET hint = NULL, notify_email = NULL, notify_email_hash = NULL, content_type = NULL, filename = NULL
+		WHERE id = $1 AND (hint IS NOT NULL OR notify_email IS NOT NULL OR content_type IS NOT NULL OR filename IS NOT NULL)
+	`, id)
+	if err != nil {
+		return false, fmt.Errorf("drop secret hint: %w", err)
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+var (
+	_ store.Store           = (*Store)(nil)
+	_ store.Warmer          = (*Store)(nil)
+	_ store.SchemaInspector = (*Store)(nil)
+	_ store.PoolReporter    = (*Store)(nil)
+)
+
+// namespaceDeletionColumns are the columns scanNamespaceDeletion reads
+const namespaceDeletionColumns = `namespace, started_at, finished_at, secrets_deleted, live_secrets_deleted, receipts_deleted, audit_events_anonymized`
+
+// scanNamespaceDeletion reads one namespace_deletions row
+func scanNamespaceDeletion(row pgx.Row) (*store.NamespaceDeletion, error) {
+	var deletion store.NamespaceDeletion
+	var finishedAt *time.Time
+	err := row.Scan(&deletion.Namespace, &deletion.StartedAt, &finishedAt, &deletion.SecretsDeleted,
+		&deletion.LiveSecretsDeleted, &deletion.ReceiptsDeleted, &deletion.AuditEventsAnonymized)
+	if err != nil {
+		return nil, err
+	}
+	if finishedAt != nil {
+		deletion.FinishedAt = *finishedAt
+	}
+	return &deletion, nil
+}
+
+func scanIDs(rows pgx.Rows) ([]string, error) {
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,157 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	tcpostgres "github.com/testcontainers/testcontainers-go/modules/postgres"
+
+	"ots-backend/internal/db"
+	"ots-backend/internal/store"
+	"ots-backend/internal/store/storetest"
+)
+
+// startPostgres runs a migrated Postgres container and returns its URL
+func startPostgres(tb testing.TB) string {
+	tb.Helper()
+	ctx := context.Background()
+
+	container, err := tcpostgres.Run(
+		ctx,
+		"postgres:16-alpine",
+		tcpostgres.WithDatabase("ots_test"),
+		tcpostgres.WithUsername("ots"),
+		tcpostgres.WithPassword("ots"),
+		tcpostgres.BasicWaitStrategies(),
+	)
+	if err != nil {
+		tb.Fatalf("start postgres container: %v", err)
+	}
+	tb.Cleanup(func() { container.Terminate(ctx) })
+
+	connString, err := container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		tb.Fatalf("connection string: %v", err)
+	}
+
+	database, err := db.New(connString)
+	if err != nil {
+		tb.Fatalf("db.New() error: %v", err)
+	}
+	defer database.Close()
+
+	if err := database.Migrate("../../../migrations"); err != nil {
+		tb.Fatalf("Migrate() error: %v", err)
+	}
+
+	return connString
+}
+
+func TestConformance(t *testing.T) {
+	ctx := context.Background()
+
+	database, err := db.New(startPostgres(t))
+	if err != nil {
+		t.Fatalf("db.New() error: %v", err)
+	}
+	defer database.Close()
+
+	storetest.Run(t, func(t *testing.T) store.Store {
+		if _, err := database.Pool().Exec(ctx, "TRUNCATE TABLE secrets, secret_receipts CASCADE"); err != nil {
+			t.Fatalf("truncate: %v", err)
+		}
+		return New(database)
+	})
+}
+
+// TestSecretRowMatchesSchema checks secretRow against the migrated secrets
+// table: a column without a field fails every consume, and a field without a
+// column fails the query
+func TestSecretRowMatchesSchema(t *testing.T) {
+	ctx := context.Background()
+
+	database, err := db.New(startPostgres(t))
+	if err != nil {
+		t.Fatalf("db.New() error: %v", err)
+	}
+	defer database.Close()
+
+	rows, err := database.Pool().Query(ctx, `
+		SELECT column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'secrets'
+	`)
+	if err != nil {
+		t.Fatalf("query columns: %v", err)
+	}
+	columns, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		t.Fatalf("collect columns: %v", err)
+	}
+
+	fields := make(map[string]bool)
+	for _, column := range dbColumns(reflect.TypeFor[secretRow]()) {
+		fields[column] = true
+	}
+	for _, column := range columns {
+		if !fields[column] {
+			t.Errorf("secrets.%s has no secretRow field", column)
+		}
+		delete(fields, column)
+	}
+	for field := range fields {
+		t.Errorf("secretRow field %s has no secrets column", field)
+	}
+}
+
+// BenchmarkFirstBurst measures the first burst of concurrent requests after
+// startup. Against a cold pool each request pays for a connection handshake;
+// after Warm the connections are already open.
+func BenchmarkFirstBurst(b *testing.B) {
+	connString := startPostgres(b)
+
+	for _, warm := range []bool{false, true} {
+		name := "cold"
+		if warm {
+			name = "warm"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			ctx := context.Background()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				database, err := db.New(connString)
+				if err != nil {
+					b.Fatalf("db.New() error: %v", err)
+				}
+				st := New(database)
+				if warm {
+					if err := st.Warm(ctx); err != nil {
+						b.Fatalf("Warm() error: %v", err)
+					}
+				}
+				burst := int(database.Pool().Config().MinConns)
+				b.StartTimer()
+
+				var wg sync.WaitGroup
+				for j := 0; j < burst; j++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						if _, err := st.CountActive(ctx); err != nil {
+							b.Errorf("CountActive() error: %v", err)
+						}
+					}()
+				}
+				wg.Wait()
+
+				b.StopTimer()
+				database.Close()
+			}
+		})
+	}
+}
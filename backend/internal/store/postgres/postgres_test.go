@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestTransient(t *testing.T) {
+	s := &Store{}
+	tests := map[string]struct {
+		err  error
+		want bool
+	}{
+		"deadlock":             {&pgconn.PgError{Code: "40P01"}, true},
+		"serialization":        {&pgconn.PgError{Code: "40001"}, true},
+		"too many connections": {&pgconn.PgError{Code: "53300"}, true},
+		"statement timeout":    {fmt.Errorf("consume: %w", &pgconn.PgError{Code: "57014"}), true},
+		"connection failure":   {&pgconn.PgError{Code: "08006"}, true},
+		"not null violation":   {&pgconn.PgError{Code: "23502"}, false},
+		"undefined column":     {&pgconn.PgError{Code: "42703"}, false},
+		"undefined table":      {fmt.Errorf("insert secret: %w", &pgconn.PgError{Code: "42P01"}), false},
+		"other":                {errors.New("closed pool"), false},
+	}
+	for name, tt := range tests {
+		if got := s.Transient(tt.err); got != tt.want {
+			t.Errorf("%s: Transient(%v) = %v, want %v", name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestSecretColumns(t *testing.T) {
+	want := "s.id, s.ciphertext, s.iv, s.salt, s.expires_at"
+	if !strings.HasPrefix(secretColumns, want+", ") {
+		t.Errorf("secretColumns = %q, want it to start %q", secretColumns, want)
+	}
+
+	columns := dbColumns(reflect.TypeFor[keyedSecretRow]())
+	seen := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		if seen[column] {
+			t.Errorf("column %s is mapped twice", column)
+		}
+		seen[column] = true
+	}
+	if !seen["data_key"] || !seen["notify_email_hash"] {
+		t.Errorf("dbColumns(keyedSecretRow) = %v, want the embedded row's columns and the key's", columns)
+	}
+}
+
+// TestSecretRowMapsEveryField catches a store.Secret field added without
+// its mapping: every stored field must come out of a fully set row
+func TestSecretRowMapsEveryField(t *testing.T) {
+	var row keyedSecretRow
+	fill(t, reflect.ValueOf(&row).Elem())
+
+	// Parts come from their own table, and the quota is checked, not stored
+	unmapped := map[string]bool{"Parts": true, "CreatorQuota": true}
+	secret := reflect.ValueOf(row.secret()).Elem()
+	for i := range secret.NumField() {
+		name := secret.Type().Field(i).Name
+		if !unmapped[name] && secret.Field(i).IsZero() {
+			t.Errorf("Secret.%s is not mapped from the row", name)
+		}
+	}
+}
+
+// fill sets every field of v, flattening embedded structs, to a non-zero value
+func fill(t *testing.T, v reflect.Value) {
+	t.Helper()
+
+	for i := range v.NumField() {
+		field := v.Field(i)
+		if v.Type().Field(i).Anonymous {
+			fill(t, field)
+			continue
+		}
+		if field.Kind() == reflect.Pointer {
+			field.Set(reflect.New(field.Type().Elem()))
+			field = field.Elem()
+		}
+		switch field.Interface().(type) {
+		case time.Time:
+			field.Set(reflect.ValueOf(time.Unix(1, 0)))
+		default:
+			switch field.Kind() {
+			case reflect.String:
+				field.SetString("x")
+			case reflect.Bool:
+				field.SetBool(true)
+			case reflect.Int, reflect.Int64:
+				field.SetInt(1)
+			case reflect.Slice:
+				field.Set(reflect.MakeSlice(field.Type(), 1, 1))
+			default:
+				t.Fatalf("cannot fill %s", field.Type())
+			}
+		}
+	}
+}
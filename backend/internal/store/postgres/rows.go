@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"ots-backend/internal/sensitive"
+	"ots-backend/internal/store"
+)
+
+// secretRow is one row of the secrets table, a field per column named by
+// its db tag. Secrets are read into it by column name with
+// pgx.RowToStructByName, never by position, and the integration tests
+// check its fields against the migrated table; a migration adding a column
+// adds a field here.
+type secretRow struct {
+	ID                  string          `db:"id"`
+	Ciphertext          sensitive.Bytes `db:"ciphertext"`
+	IV                  sensitive.Bytes `db:"iv"`
+	Salt                sensitive.Bytes `db:"salt"`
+	ExpiresAt           time.Time       `db:"expires_at"`
+	BurnAfterRead       bool            `db:"burn_after_read"`
+	CreatedAt           time.Time       `db:"created_at"`
+	KeyWrapped          bool            `db:"key_wrapped"`
+	DeclaredKeyBits     *int            `db:"declared_key_bits"`
+	ManagementTokenHash []byte          `db:"management_token_hash"`
+	RequireAck          bool            `db:"require_ack"`
+	AckTokenHash        []byte          `db:"ack_token_hash"`
+	AckDeadline         *time.Time      `db:"ack_deadline"`
+	Namespace           *string         `db:"namespace"`
+	IVEmbedded          bool            `db:"iv_embedded"`
+	AvailableAfter      *time.Time      `db:"available_after"`
+	Creator             *string         `db:"creator"`
+	Hint                *string         `db:"hint"`
+	NotifyEmail         sensitive.Bytes `db:"notify_email"`
+	NotifyEmailHash     *string         `db:"notify_email_hash"`
+	// StoredBytes is derived from the payload at create; NULL on rows
+	// written before the column
+	StoredBytes *int64  `db:"stored_bytes"`
+	ContentType *string `db:"content_type"`
+	Filename    *string `db:"filename"`
+	Version     int64   `db:"version"`
+}
+
+// keyedSecretRow is a secrets row with its secret_keys row, whose columns
+// are NULL for a secret stored as it is. Consume fills the key with
+// lockedKey after locking the row rather than joining it.
+type keyedSecretRow struct {
+	secretRow
+	DataKey    sensitive.Bytes `db:"data_key"`
+	KeyVersion *string         `db:"key_version"`
+}
+
+// secretColumns selects every secretRow field from the secrets table
+// aliased s
+var secretColumns = columnList("s", secretRow{})
+
+// columnList returns the db tags of row's fields as a select list, each
+// qualified with alias
+func columnList(alias string, row any) string {
+	var columns []string
+	for _, column := range dbColumns(reflect.TypeOf(row)) {
+		columns = append(columns, alias+"."+column)
+	}
+	return strings.Join(columns, ", ")
+}
+
+// dbColumns returns the db tags of t's fields in order, flattening
+// embedded structs as pgx does
+func dbColumns(t reflect.Type) []string {
+	var columns []string
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			columns = append(columns, dbColumns(field.Type)...)
+			continue
+		}
+		if column := field.Tag.Get("db"); column != "" && column != "-" {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// secret maps the row to the stored secret, leaving Parts to the caller.
+// NULL text columns become empty strings.
+func (r *keyedSecretRow) secret() *store.Secret {
+	return &store.Secret{
+		ID:                  r.ID,
+		Ciphertext:          r.Ciphertext,
+		IV:                  r.IV,
+		Salt:                r.Salt,
+		ExpiresAt:           r.ExpiresAt,
+		CreatedAt:           r.CreatedAt,
+		BurnAfterRead:       r.BurnAfterRead,
+		DataKey:             r.DataKey,
+		KeyVersion:          deref(r.KeyVersion),
+		DeclaredKeyBits:     r.DeclaredKeyBits,
+		ManagementTokenHash: r.ManagementTokenHash,
+		RequireAck:          r.RequireAck,
+		Namespace:           deref(r.Namespace),
+		IVEmbedded:          r.IVEmbedded,
+		AvailableAfter:      r.AvailableAfter,
+		Creator:             deref(r.Creator),
+		Hint:                deref(r.Hint),
+		NotifyEmail:         r.NotifyEmail,
+		NotifyEmailHash:     deref(r.NotifyEmailHash),
+		ContentType:         deref(r.ContentType),
+		Filename:            deref(r.Filename),
+		Revision:            r.Version,
+	}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
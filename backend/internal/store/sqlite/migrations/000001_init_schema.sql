@@ -0,0 +1,42 @@
+-- SQLite schema for single-binary deployments. Mirrors the Postgres
+-- migrations; timestamps are stored as Unix nanoseconds.
+
+CREATE TABLE IF NOT EXISTS secrets (
+    id TEXT PRIMARY KEY,
+    ciphertext BLOB NOT NULL,
+    iv BLOB NOT NULL,
+    salt BLOB,
+    expires_at INTEGER NOT NULL,
+    burn_after_read INTEGER NOT NULL DEFAULT 1,
+    created_at INTEGER NOT NULL,
+    key_wrapped INTEGER NOT NULL DEFAULT 0,
+    declared_key_bits INTEGER,
+    management_token_hash BLOB
+);
+
+CREATE INDEX IF NOT EXISTS idx_secrets_expires_at ON secrets(expires_at);
+
+-- Per-secret data keys for crypto-shredding mode
+CREATE TABLE IF NOT EXISTS secret_keys (
+    secret_id TEXT PRIMARY KEY REFERENCES secrets(id) ON DELETE CASCADE,
+    data_key BLOB NOT NULL
+);
+
+-- Read receipts; never store client IPs
+CREATE TABLE IF NOT EXISTS secret_receipts (
+    secret_id TEXT PRIMARY KEY,
+    consumed_at INTEGER NOT NULL,
+    network_class TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_secret_receipts_consumed_at ON secret_receipts(consumed_at);
+
+-- Labelled parts of a multi-part secret
+CREATE TABLE IF NOT EXISTS secret_parts (
+    secret_id TEXT NOT NULL REFERENCES secrets(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    label TEXT NOT NULL,
+    ciphertext BLOB NOT NULL,
+    iv BLOB NOT NULL,
+    PRIMARY KEY (secret_id, position)
+);
@@ -0,0 +1,9 @@
+-- Acknowledged reads; mirrors Postgres migration 000008
+
+ALTER TABLE secrets ADD COLUMN require_ack INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE secrets ADD COLUMN ack_token_hash BLOB;
+ALTER TABLE secrets ADD COLUMN ack_deadline INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_secrets_ack_deadline ON secrets(ack_deadline) WHERE ack_deadline IS NOT NULL;
+
+ALTER TABLE secret_receipts ADD COLUMN acknowledged INTEGER;
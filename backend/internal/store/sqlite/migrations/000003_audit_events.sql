@@ -0,0 +1,12 @@
+-- Audit log; mirrors Postgres migration 000009
+
+CREATE TABLE IF NOT EXISTS audit_events (
+    id TEXT PRIMARY KEY,
+    event_type TEXT NOT NULL,
+    occurred_at INTEGER NOT NULL,
+    namespace TEXT NOT NULL DEFAULT '',
+    secret_id_hash TEXT NOT NULL,
+    network_class TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_type_id ON audit_events(event_type, id);
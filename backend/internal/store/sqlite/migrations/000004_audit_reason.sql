@@ -0,0 +1,3 @@
+-- Report reasons; mirrors Postgres migration 000010
+
+ALTER TABLE audit_events ADD COLUMN reason TEXT;
@@ -0,0 +1,3 @@
+-- Operator labels; mirrors Postgres migration 000011
+
+ALTER TABLE audit_events ADD COLUMN actor TEXT;
@@ -0,0 +1,5 @@
+-- Tenant namespaces; mirrors Postgres migration 000012
+
+ALTER TABLE secrets ADD COLUMN namespace TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_secrets_namespace ON secrets(namespace) WHERE namespace IS NOT NULL;
@@ -0,0 +1,3 @@
+-- Nonce-prefixed ciphertexts; mirrors Postgres migration 000013
+
+ALTER TABLE secrets ADD COLUMN iv_embedded INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,11 @@
+-- Dropped async work; mirrors Postgres migration 000014
+
+CREATE TABLE IF NOT EXISTS dropped_work (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    count INTEGER NOT NULL,
+    recorded_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_dropped_work_recorded_at ON dropped_work(recorded_at);
@@ -0,0 +1,6 @@
+-- Create counts per size class; mirrors Postgres migration 000016
+
+CREATE TABLE IF NOT EXISTS secret_size_buckets (
+    upper_bound INTEGER PRIMARY KEY,
+    count INTEGER NOT NULL
+);
@@ -0,0 +1,10 @@
+-- Usage totals per UTC day; mirrors Postgres migration 000017
+
+CREATE TABLE IF NOT EXISTS daily_stats (
+    day TEXT PRIMARY KEY,
+    created INTEGER NOT NULL DEFAULT 0,
+    retrieved INTEGER NOT NULL DEFAULT 0,
+    burned INTEGER NOT NULL DEFAULT 0,
+    expired INTEGER NOT NULL DEFAULT 0,
+    bytes INTEGER NOT NULL DEFAULT 0
+);
@@ -0,0 +1,3 @@
+-- Scheduled release; mirrors Postgres migration 000018
+
+ALTER TABLE secrets ADD COLUMN available_after INTEGER;
@@ -0,0 +1,13 @@
+-- Namespace deletion progress; mirrors Postgres migration 000019
+
+CREATE TABLE IF NOT EXISTS namespace_deletions (
+    namespace TEXT PRIMARY KEY,
+    started_at INTEGER NOT NULL,
+    finished_at INTEGER,
+    secrets_deleted INTEGER NOT NULL DEFAULT 0,
+    live_secrets_deleted INTEGER NOT NULL DEFAULT 0,
+    receipts_deleted INTEGER NOT NULL DEFAULT 0,
+    audit_events_anonymized INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_namespace_id ON audit_events(namespace, id) WHERE namespace <> '';
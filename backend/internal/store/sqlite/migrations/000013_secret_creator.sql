@@ -0,0 +1,5 @@
+-- Active-secret quota; mirrors Postgres migration 000020
+
+ALTER TABLE secrets ADD COLUMN creator TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_secrets_creator ON secrets(creator, expires_at) WHERE creator IS NOT NULL;
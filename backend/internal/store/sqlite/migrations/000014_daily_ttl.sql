@@ -0,0 +1,3 @@
+-- Daily TTL totals; mirrors Postgres migration 000021
+
+ALTER TABLE daily_stats ADD COLUMN ttl_seconds INTEGER NOT NULL DEFAULT 0;
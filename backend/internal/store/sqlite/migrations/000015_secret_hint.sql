@@ -0,0 +1,3 @@
+-- Sender hint shown before a read; mirrors Postgres migration 000022
+
+ALTER TABLE secrets ADD COLUMN hint TEXT;
@@ -0,0 +1,10 @@
+-- Rate limit reputations; mirrors Postgres migration 000023
+
+CREATE TABLE IF NOT EXISTS client_reputation (
+    client_key TEXT PRIMARY KEY,
+    score REAL NOT NULL,
+    updated_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_client_reputation_score ON client_reputation(score);
+CREATE INDEX IF NOT EXISTS idx_client_reputation_updated_at ON client_reputation(updated_at);
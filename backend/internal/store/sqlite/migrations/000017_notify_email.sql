@@ -0,0 +1,6 @@
+-- Sealed notification address and its hash; mirrors Postgres migration 000024
+
+ALTER TABLE secrets ADD COLUMN notify_email BLOB;
+ALTER TABLE secrets ADD COLUMN notify_email_hash TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_secrets_notify_expiry ON secrets(expires_at) WHERE notify_email IS NOT NULL;
@@ -0,0 +1,3 @@
+-- Envelope key version of each data key; mirrors Postgres migration 000025
+
+ALTER TABLE secret_keys ADD COLUMN key_version TEXT;
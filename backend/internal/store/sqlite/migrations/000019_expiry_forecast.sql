@@ -0,0 +1,11 @@
+-- Stored bytes per secret and a covering expiry index; mirrors Postgres
+-- migration 000026
+
+ALTER TABLE secrets ADD COLUMN stored_bytes INTEGER;
+
+UPDATE secrets
+SET stored_bytes = length(ciphertext)
+    + COALESCE((SELECT SUM(length(p.ciphertext)) FROM secret_parts p WHERE p.secret_id = secrets.id), 0)
+WHERE stored_bytes IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_secrets_expiry_forecast ON secrets(expires_at, namespace, key_wrapped, stored_bytes, id);
@@ -0,0 +1,3 @@
+-- Tombstones for every ending; mirrors Postgres migration 000027
+
+ALTER TABLE secret_receipts ADD COLUMN reason TEXT NOT NULL DEFAULT 'consumed';
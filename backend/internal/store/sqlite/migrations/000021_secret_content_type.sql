@@ -0,0 +1,4 @@
+-- Sender-declared content type and file name; mirrors Postgres migration 000028
+
+ALTER TABLE secrets ADD COLUMN content_type TEXT;
+ALTER TABLE secrets ADD COLUMN filename TEXT;
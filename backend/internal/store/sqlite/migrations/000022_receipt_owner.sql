@@ -0,0 +1,4 @@
+-- Creator-readable tombstones; mirrors Postgres migration 000029
+
+ALTER TABLE secret_receipts ADD COLUMN management_token_hash BLOB;
+ALTER TABLE secret_receipts ADD COLUMN country TEXT;
@@ -0,0 +1,3 @@
+-- Creators are HMACs of the client IP; mirrors Postgres migration 000030
+
+UPDATE secrets SET creator = NULL WHERE creator IS NOT NULL;
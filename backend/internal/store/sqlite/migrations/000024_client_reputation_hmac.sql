@@ -0,0 +1,3 @@
+-- Client keys are HMACs of the client IP; mirrors Postgres migration 000031
+
+DELETE FROM client_reputation;
@@ -0,0 +1,4 @@
+-- Secret revisions for If-Match; mirrors Postgres migration 000032
+
+ALTER TABLE secrets ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE secret_receipts ADD COLUMN version INTEGER NOT NULL DEFAULT 2;
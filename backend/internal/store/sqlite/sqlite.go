@@ -0,0 +1,1619 @@
+// Package sqlite implements store.Store on an embedded SQLite database for
+// single-binary deployments. It uses the pure-Go modernc.org/sqlite driver,
+// so the binary stays CGO-free.
+package sqlite
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"ots-backend/internal/store"
+	"ots-backend/internal/tracing"
+)
+
+// URLScheme selects this backend in DATABASE_URL, e.g. sqlite:///var/lib/ots/ots.db
+const URLScheme = "sqlite://"
+
+// ErrMigrate marks an Open that reached the database file but could not
+// bring its schema up to date
+var ErrMigrate = errors.New("migrate sqlite")
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Store is the SQLite secret store
+type Store struct {
+	db *sql.DB
+}
+
+// Primary result codes a retry may get past: another writer held the
+// lock past busy_timeout
+const (
+	sqliteBusy   = 5
+	sqliteLocked = 6
+)
+
+// Transient reports whether err is SQLITE_BUSY or SQLITE_LOCKED, including
+// their extended codes
+func (s *Store) Transient(err error) bool {
+	var coded interface{ Code() int }
+	if !errors.As(err, &coded) {
+		return false
+	}
+	code := coded.Code() & 0xff
+	return code == sqliteBusy || code == sqliteLocked
+}
+
+// PathFromURL extracts the database file path from a sqlite:// URL
+func PathFromURL(databaseURL string) (string, bool) {
+	path, ok := strings.CutPrefix(databaseURL, URLScheme)
+	if !ok || path == "" {
+		return "", false
+	}
+	return path, true
+}
+
+// Open opens or creates the database at path and applies pending migrations.
+// WAL mode lets readers proceed during writes; every transaction starts with
+// BEGIN IMMEDIATE so concurrent consumers, including a separate cleanup
+// process, serialize on the write lock instead of racing.
+func Open(path string) (*Store, error) {
+	pragmas := url.Values{}
+	for _, pragma := range []string{
+		"busy_timeout(5000)",
+		"journal_mode(WAL)",
+		"synchronous(NORMAL)",
+		"foreign_keys(1)",
+		// Overwrite deleted content so consumed secrets do not linger in free pages
+		"secure_delete(1)",
+	} {
+		pragmas.Add("_pragma", pragma)
+	}
+	pragmas.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+pragmas.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open sqlite: %w", err)
+	}
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%w: %w", ErrMigrate, err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+// DB returns the underlying database handle
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// migrate applies embedded migrations newer than the schema's user_version
+func migrate(db *sql.DB) error {
+	var current int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&current); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("list migrations: %w", err)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		name := strings.TrimPrefix(file, "migrations/")
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return fmt.Errorf("migration %s: bad version prefix", name)
+		}
+		if version <= current {
+			continue
+		}
+
+		script, err := migrations.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("read migration %s: %w", name, err)
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("begin migration %s: %w", name, err)
+		}
+		if _, err := tx.Exec(string(script)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("apply migration %s: %w", name, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, version)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("record migration %s: %w", name, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit migration %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// commit commits tx under a span, so a trace shows what the write cost
+func commit(ctx context.Context, tx *sql.Tx) error {
+	_, span := tracing.Start(ctx, "sqlite.commit")
+	err := tx.Commit()
+	tracing.End(span, err)
+	return err
+}
+
+// Create stores a new secret with its data key and parts in one transaction
+func (s *Store) Create(ctx context.Context, secret *store.Secret) (err error) {
+	ctx, span := tracing.Start(ctx, "sqlite.create")
+	defer func() {
+		tracing.End(span, err, store.ErrDuplicateID, store.ErrNamespaceDeleted, store.ErrQuotaExceeded)
+	}()
+	tracing.SetSecretID(span, secret.ID)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Transactions take the write lock up front, so nothing can claim the
+	// ID between this check and the insert
+	var taken bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM secrets WHERE id = ?)`, secret.ID).Scan(&taken)
+	if err != nil {
+		return fmt.Errorf("check secret ID: %w", err)
+	}
+	if taken {
+		return store.ErrDuplicateID
+	}
+
+	if secret.Namespace != "" {
+		var deleted bool
+		err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM namespace_deletions WHERE namespace = ?)`, secret.Namespace).Scan(&deleted)
+		if err != nil {
+			return fmt.Errorf("check namespace deletion: %w", err)
+		}
+		if deleted {
+			return store.ErrNamespaceDeleted
+		}
+	}
+
+	if secret.Creator != "" && secret.CreatorQuota > 0 {
+		var active int64
+		err = tx.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM secrets s
+			WHERE s.creator = ? AND s.expires_at > ?
+			  AND (NOT s.key_wrapped OR EXISTS (SELECT 1 FROM secret_keys k WHERE k.secret_id = s.id))
+		`, secret.Creator, secret.CreatedAt.UnixNano()).Scan(&active)
+		if err != nil {
+			return fmt.Errorf("count creator secrets: %w", err)
+		}
+		if active >= int64(secret.CreatorQuota) {
+			return store.ErrQuotaExceeded
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO secrets (id, ciphertext, iv, salt, expires_at, burn_after_read, created_at, key_wrapped, declared_key_bits, management_token_hash, require_ack, namespace, iv_embedded, available_after, creator, hint, notify_email, notify_email_hash,
+		                     stored_bytes, content_type, filename)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?, NULLIF(?, ''), NULLIF(?, ''), ?, NULLIF(?, ''), ?, NULLIF(?, ''), NULLIF(?, ''))
+	`, secret.ID, nonNil(secret.Ciphertext), nonNil(secret.IV), secret.Salt, secret.ExpiresAt.UnixNano(), secret.BurnAfterRead,
+		secret.CreatedAt.UnixNano(), secret.DataKey != nil, secret.DeclaredKeyBits, secret.ManagementTokenHash, secret.RequireAck, secret.Namespace, secret.IVEmbedded,
+		unixNanos(secret.AvailableAfter), secret.Creator, secret.Hint, secret.NotifyEmail, secret.NotifyEmailHash, store.StoredBytes(secret),
+		secret.ContentType, secret.Filename)
+	if err != nil {
+		return fmt.Errorf("insert secret: %w", err)
+	}
+
+	if secret.DataKey != nil {
+		_, err = tx.ExecContext(ctx, `INSERT INTO secret_keys (secret_id, data_key, key_version) VALUES (?, ?, NULLIF(?, ''))`, secret.ID, secret.DataKey, secret.KeyVersion)
+		if err != nil {
+			return fmt.Errorf("insert secret key: %w", err)
+		}
+	}
+
+	for i, part := range secret.Parts {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO secret_parts (secret_id, position, label, ciphertext, iv)
+			VALUES (?, ?, ?, ?, ?)
+		`, secret.ID, i, part.Label, part.Ciphertext, part.IV)
+		if err != nil {
+			return fmt.Errorf("insert secret part: %w", err)
+		}
+	}
+
+	if err := commit(ctx, tx); err != nil {
+		return fmt.Errorf("commit secret: %w", err)
+	}
+
+	return nil
+}
+
+// Peek previews a secret Consume would deliver, leaving it in place.
+// Shredded and held rows are skipped as Consume skips them.
+func (s *Store) Peek(ctx context.Context, id string, now time.Time) (*store.Preview, error) {
+	var preview store.Preview
+	var availableAfter sql.NullInt64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COALESCE(length(s.ciphertext), 0)
+		       + COALESCE((SELECT SUM(length(p.ciphertext)) FROM secret_parts p WHERE p.secret_id = s.id), 0),
+		       COALESCE(s.hint, ''), COALESCE(s.content_type, ''), COALESCE(s.filename, ''), s.available_after
+		FROM secrets s
+		LEFT JOIN secret_keys k ON k.secret_id = s.id
+		WHERE s.id = ? AND s.expires_at > ? AND s.ack_deadline IS NULL
+		  AND (NOT s.key_wrapped OR k.data_key IS NOT NULL)
+	`, id, now.UnixNano()).Scan(&preview.Size, &preview.Hint, &preview.ContentType, &preview.Filename, &availableAfter)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("peek secret: %w", err)
+	}
+	if err := store.CheckAvailable(timeFromNanos(availableAfter), now); err != nil {
+		return nil, err
+	}
+	return &preview, nil
+}
+
+// Consume reads and destroys a secret, or holds it for acknowledgement or
+// a grace window. The immediate transaction holds the database write lock
+// from the first statement, so a second consumer blocks until the first
+// commits and then finds nothing.
+func (s *Store) Consume(ctx context.Context, id string, opts store.ConsumeOptions) (_ *store.Secret, err error) {
+	ctx, span := tracing.Start(ctx, "sqlite.consume")
+	defer func() { tracing.End(span, err, store.ErrNotFound, store.ErrNotYetAvailable) }()
+	tracing.SetSecretID(span, id)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var secret store.Secret
+	var expiresAt, createdAt int64
+	var keyWrapped bool
+	var ackTokenHash []byte
+	var ackDeadline, availableAfter sql.NullInt64
+	err = tx.QueryRowContext(ctx, `
+		SELECT s.id, s.ciphertext, s.iv, s.salt, s.expires_at, s.burn_after_read, s.created_at, s.key_wrapped, k.data_key,
+		       s.require_ack, s.ack_deadline, COALESCE(s.namespace, ''), s.iv_embedded, s.available_after, COALESCE(s.hint, ''),
+		       s.notify_email, COALESCE(s.notify_email_hash, ''), COALESCE(k.key_version, ''), COALESCE(s.content_type, ''), COALESCE(s.filename, ''),
+		       s.management_token_hash, s.ack_token_hash, s.version
+		FROM secrets s
+		LEFT JOIN secret_keys k ON k.secret_id = s.id
+		WHERE s.id = ? AND s.expires_at > ?
+	`, id, opts.Now.UnixNano()).Scan(&secret.ID, &secret.Ciphertext, &secret.IV, &secret.Salt, &expiresAt,
+		&secret.BurnAfterRead, &createdAt, &keyWrapped, &secret.DataKey, &secret.RequireAck, &ackDeadline, &secret.Namespace, &secret.IVEmbedded,
+		&availableAfter, &secret.Hint, &secret.NotifyEmail, &secret.NotifyEmailHash, &secret.KeyVersion, &secret.ContentType, &secret.Filename,
+		&secret.ManagementTokenHash, &ackTokenHash, &secret.Revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query secret: %w", err)
+	}
+	secret.ExpiresAt = time.Unix(0, expiresAt)
+	secret.CreatedAt = time.Unix(0, createdAt)
+	secret.AvailableAfter = timeFromNanos(availableAfter)
+
+	// A wrapped row without its key has been shredded and awaits garbage collection
+	if keyWrapped && secret.DataKey == nil {
+		return nil, store.ErrNotFound
+	}
+
+	// A held secret was delivered already and only waits for its ack, or
+	// for a retry with its retrieval token within its grace window
+	if opts.RetrievalTokenHash != nil {
+		if !ackDeadline.Valid || secret.RequireAck || opts.Now.UnixNano() > ackDeadline.Int64 ||
+			subtle.ConstantTimeCompare(ackTokenHash, opts.RetrievalTokenHash) != 1 {
+			return nil, store.ErrNotFound
+		}
+	} else if ackDeadline.Valid {
+		return nil, store.ErrNotFound
+	}
+
+	// A scheduled secret stays in place until its release
+	if err := store.CheckAvailable(secret.AvailableAfter, opts.Now); err != nil {
+		return nil, err
+	}
+
+	secret.Parts, err = loadParts(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Open != nil {
+		if err := opts.Open(&secret); err != nil {
+			return nil, err
+		}
+	}
+	// A hold or an ending is a new revision; a retry ends a hold that was
+	// one already
+	if opts.RetrievalTokenHash == nil {
+		secret.Revision++
+	}
+
+	var acknowledged *bool
+	if secret.RequireAck && opts.Ack != nil {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE secrets SET ack_token_hash = ?, ack_deadline = ?, hint = NULL, notify_email = NULL, notify_email_hash = NULL,
+			       content_type = NULL, filename = NULL, version = ?
+			WHERE id = ?
+		`, opts.Ack.TokenHash, opts.Ack.Deadline.UnixNano(), secret.Revision, id)
+		if err != nil {
+			return nil, fmt.Errorf("hold secret for ack: %w", err)
+		}
+		acknowledged = new(bool)
+	} else if opts.Grace != nil && opts.RetrievalTokenHash == nil {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE secrets SET ack_token_hash = ?, ack_deadline = ?, notify_email = NULL, notify_email_hash = NULL, version = ?
+			WHERE id = ?
+		`, opts.Grace.TokenHash, opts.Grace.Deadline.UnixNano(), secret.Revision, id)
+		if err != nil {
+			return nil, fmt.Errorf("hold secret for grace window: %w", err)
+		}
+	} else if err := destroy(ctx, tx, id, keyWrapped); err != nil {
+		return nil, err
+	}
+
+	if opts.Receipt != nil && opts.RetrievalTokenHash == nil {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO secret_receipts (secret_id, consumed_at, network_class, acknowledged, reason, management_token_hash, country, version)
+			VALUES (?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?)
+			ON CONFLICT (secret_id) DO NOTHING
+		`, id, opts.Receipt.ConsumedAt.UnixNano(), opts.Receipt.NetworkClass, acknowledged, store.TerminationConsumed, secret.ManagementTokenHash, opts.Receipt.Country,
+			secret.Revision)
+		if err != nil {
+			return nil, fmt.Errorf("insert receipt: %w", err)
+		}
+	}
+
+	if err := commit(ctx, tx); err != nil {
+		return nil, fmt.Errorf("commit consume: %w", err)
+	}
+
+	return &secret, nil
+}
+
+// Acknowledge destroys a held secret once its reader confirms receipt. A
+// window that has already lapsed burns the secret without acknowledging it.
+func (s *Store) Acknowledge(ctx context.Context, id string, tokenHash []byte, now time.Time, revision int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var hash, dataKey []byte
+	var deadline, current int64
+	var keyWrapped bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT s.ack_token_hash, s.ack_deadline, s.key_wrapped, k.data_key, s.version
+		FROM secrets s
+		LEFT JOIN secret_keys k ON k.secret_id = s.id
+		WHERE s.id = ? AND s.ack_deadline IS NOT NULL AND s.require_ack
+	`, id).Scan(&hash, &deadline, &keyWrapped, &dataKey, &current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("query held secret: %w", err)
+	}
+
+	if keyWrapped && dataKey == nil {
+		return store.ErrNotFound
+	}
+
+	if now.After(time.Unix(0, deadline)) {
+		if err := destroy(ctx, tx, id, keyWrapped); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit lapsed ack: %w", err)
+		}
+		return store.ErrNotFound
+	}
+
+	if subtle.ConstantTimeCompare(hash, tokenHash) != 1 {
+		return store.ErrNotFound
+	}
+	if err := store.CheckRevision(revision, current); err != nil {
+		return err
+	}
+
+	if err := destroy(ctx, tx, id, keyWrapped); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE secret_receipts SET acknowledged = 1, version = ? WHERE secret_id = ?`, current+1, id)
+	if err != nil {
+		return fmt.Errorf("acknowledge receipt: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit ack: %w", err)
+	}
+
+	return nil
+}
+
+// Burn destroys a secret: wrapped rows are crypto-shredded, legacy rows are
+// deleted outright
+func (s *Store) Burn(ctx context.Context, id string) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	burned, err := shredKey(ctx, tx, id)
+	if err != nil {
+		return false, err
+	}
+
+	if !burned {
+		result, err := tx.ExecContext(ctx, `DELETE FROM secrets WHERE id = ? AND NOT key_wrapped`, id)
+		if err != nil {
+			return false, fmt.Errorf("delete secret: %w", err)
+		}
+		burned = rowsAffected(result) > 0
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit burn: %w", err)
+	}
+
+	return burned, nil
+}
+
+// Terminate destroys a live secret like Burn and stores its tombstone in
+// one transaction
+func (s *Store) Terminate(ctx context.Context, id string, reason store.TerminationReason, now time.Time, revision int64, tombstone *store.Receipt) (_ *store.Secret, err error) {
+	if err := store.CheckTermination(reason); err != nil {
+		return nil, err
+	}
+	ctx, span := tracing.Start(ctx, "sqlite.terminate")
+	defer func() { tracing.End(span, err, store.ErrNotFound) }()
+	tracing.SetSecretID(span, id)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var secret store.Secret
+	var expiresAt, createdAt int64
+	var keyWrapped, hasKey, held bool
+	var tokenHash []byte
+	err = tx.QueryRowContext(ctx, `
+		SELECT s.id, COALESCE(s.namespace, ''), s.notify_email, s.created_at, s.expires_at, s.key_wrapped,
+		       EXISTS (SELECT 1 FROM secret_keys k WHERE k.secret_id = s.id), s.ack_deadline IS NOT NULL, s.management_token_hash, s.version
+		FROM secrets s
+		WHERE s.id = ?
+	`, id).Scan(&secret.ID, &secret.Namespace, &secret.NotifyEmail, &createdAt, &expiresAt, &keyWrapped, &hasKey, &held, &tokenHash, &secret.Revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query secret: %w", err)
+	}
+	secret.CreatedAt = time.Unix(0, createdAt)
+	secret.ExpiresAt = time.Unix(0, expiresAt)
+
+	if keyWrapped && !hasKey {
+		return nil, store.ErrNotFound
+	}
+	// A burn, like a read, finds nothing past the expiry; an expiry only a
+	// secret past it that is not held
+	if reason == store.TerminationBurned && !secret.ExpiresAt.After(now) {
+		return nil, store.ErrNotFound
+	}
+	if reason == store.TerminationExpired && (held || !secret.ExpiresAt.Before(now)) {
+		return nil, store.ErrNotFound
+	}
+	if err := store.CheckRevision(revision, secret.Revision); err != nil {
+		return nil, err
+	}
+	secret.Revision++
+
+	if err := destroy(ctx, tx, id, keyWrapped); err != nil {
+		return nil, err
+	}
+
+	if tombstone != nil {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO secret_receipts (secret_id, consumed_at, network_class, reason, management_token_hash, country, version)
+			VALUES (?, ?, ?, ?, ?, NULLIF(?, ''), ?)
+			ON CONFLICT (secret_id) DO UPDATE SET version = excluded.version
+		`, id, tombstone.ConsumedAt.UnixNano(), tombstone.NetworkClass, reason, tokenHash, tombstone.Country, secret.Revision)
+		if err != nil {
+			return nil, fmt.Errorf("insert tombstone: %w", err)
+		}
+	}
+
+	if err := commit(ctx, tx); err != nil {
+		return nil, fmt.Errorf("commit terminate: %w", err)
+	}
+	return &secret, nil
+}
+
+// ManagementTokenHash returns the stored management token hash
+func (s *Store) ManagementTokenHash(ctx context.Context, id string) ([]byte, error) {
+	var hash []byte
+	err := s.db.QueryRowContext(ctx, `SELECT management_token_hash FROM secrets WHERE id = ?`, id).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query management token: %w", err)
+	}
+	return hash, nil
+}
+
+// Revision returns the revision of a secret whose key has not been shredded
+func (s *Store) Revision(ctx context.Context, id string) (int64, error) {
+	var revision int64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT s.version
+		FROM secrets s
+		LEFT JOIN secret_keys k ON k.secret_id = s.id
+		WHERE s.id = ? AND (NOT s.key_wrapped OR k.data_key IS NOT NULL)
+	`, id).Scan(&revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, store.ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("query revision: %w", err)
+	}
+	return revision, nil
+}
+
+// Receipt returns the read receipt recorded when the secret was consumed,
+// or the tombstone of its burn or expiry
+func (s *Store) Receipt(ctx context.Context, id string) (*store.Receipt, error) {
+	var receipt store.Receipt
+	var consumedAt int64
+	var networkClass, country sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		SELECT consumed_at, network_class, acknowledged, reason, management_token_hash, country, version FROM secret_receipts WHERE secret_id = ?
+	`, id).Scan(&consumedAt, &networkClass, &receipt.Acknowledged, &receipt.Reason, &receipt.ManagementTokenHash, &country, &receipt.Revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query receipt: %w", err)
+	}
+	receipt.ConsumedAt = time.Unix(0, consumedAt)
+	receipt.NetworkClass = networkClass.String
+	receipt.Country = country.String
+	return &receipt, nil
+}
+
+// DeclaredKeyBits counts live, unshredded secrets by declared key length
+func (s *Store) DeclaredKeyBits(ctx context.Context, now time.Time) ([]store.KeyBitsCount, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT s.declared_key_bits, COUNT(*)
+		FROM secrets s
+		WHERE s.expires_at > ?
+		  AND (NOT s.key_wrapped OR EXISTS (SELECT 1 FROM secret_keys k WHERE k.secret_id = s.id))
+		  AND substr(s.id, 1, ?) <> ?
+		GROUP BY s.declared_key_bits
+	`, now.UnixNano(), len(store.CanaryIDPrefix), store.CanaryIDPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("query declared key bits: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []store.KeyBitsCount
+	for rows.Next() {
+		var bits sql.NullInt64
+		var count store.KeyBitsCount
+		if err := rows.Scan(&bits, &count.Count); err != nil {
+			return nil, fmt.Errorf("scan declared key bits: %w", err)
+		}
+		if bits.Valid {
+			value := int(bits.Int64)
+			count.Bits = &value
+		}
+		counts = append(counts, count)
+	}
+
+	return counts, rows.Err()
+}
+
+// CountActive counts stored secrets that have not been shredded
+func (s *Store) CountActive(ctx context.Context) (int64, error) {
+	var count int64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM secrets s
+		WHERE (NOT s.key_wrapped OR EXISTS (SELECT 1 FROM secret_keys k WHERE k.secret_id = s.id))
+		  AND substr(s.id, 1, ?) <> ?
+	`, len(store.CanaryIDPrefix), store.CanaryIDPrefix).Scan(&count)
+	return count, err
+}
+
+// CreatorStats counts live, unshredded secrets per creator
+func (s *Store) CreatorStats(ctx context.Context, now time.Time, quota int) (*store.CreatorStats, error) {
+	var stats store.CreatorStats
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(active >= ? AND ? > 0), 0), COALESCE(MAX(active), 0)
+		FROM (
+			SELECT COUNT(*) AS active FROM secrets s
+			WHERE s.creator IS NOT NULL AND s.expires_at > ?
+			  AND (NOT s.key_wrapped OR EXISTS (SELECT 1 FROM secret_keys k WHERE k.secret_id = s.id))
+			GROUP BY s.creator
+		)
+	`, quota, quota, now.UnixNano()).Scan(&stats.Creators, &stats.AtQuota, &stats.MaxActive)
+	if err != nil {
+		return nil, fmt.Errorf("query creator stats: %w", err)
+	}
+	return &stats, nil
+}
+
+// NamespaceStats summarizes a namespace's live, unshredded secrets
+func (s *Store) NamespaceStats(ctx context.Context, namespace string, now time.Time) (*store.NamespaceStats, error) {
+	var stats store.NamespaceStats
+	var oldest sql.NullInt64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*),
+		       COALESCE(SUM(length(s.ciphertext)
+		           + COALESCE((SELECT SUM(length(p.ciphertext)) FROM secret_parts p WHERE p.secret_id = s.id), 0)), 0),
+		       MIN(s.expires_at)
+		FROM secrets s
+		WHERE s.namespace = ?
+		  AND s.expires_at > ?
+		  AND (NOT s.key_wrapped OR EXISTS (SELECT 1 FROM secret_keys k WHERE k.secret_id = s.id))
+	`, namespace, now.UnixNano()).Scan(&stats.Count, &stats.TotalBytes, &oldest)
+	if err != nil {
+		return nil, fmt.Errorf("query namespace stats: %w", err)
+	}
+	if oldest.Valid {
+		stats.OldestExpiry = time.Unix(0, oldest.Int64)
+	}
+	return &stats, nil
+}
+
+// expiryForecastQuery groups live, unshredded secrets by hour of expiry.
+// idx_secrets_expiry_forecast covers every secrets column it reads.
+const expiryForecastQuery = `
+	SELECT s.expires_at - s.expires_at % ?, COUNT(*), COALESCE(SUM(s.stored_bytes), 0)
+	FROM secrets s
+	WHERE s.expires_at > ? AND s.expires_at <= ?
+	  AND (? = '' OR s.namespace = ?)
+	  AND (NOT s.key_wrapped OR EXISTS (SELECT 1 FROM secret_keys k WHERE k.secret_id = s.id))
+	GROUP BY 1
+	ORDER BY 1
+`
+
+// ExpiryForecast counts live, unshredded secrets per hour of expiry from the
+// covering expiry index
+func (s *Store) ExpiryForecast(ctx context.Context, namespace string, from, to time.Time) ([]store.ExpiryBucket, error) {
+	rows, err := s.db.QueryContext(ctx, expiryForecastQuery, int64(time.Hour), from.UnixNano(), to.UnixNano(), namespace, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("query expiry forecast: %w", err)
+	}
+	defer rows.Close()
+
+	var buckets []store.ExpiryBucket
+	for rows.Next() {
+		var bucket store.ExpiryBucket
+		var hour int64
+		if err := rows.Scan(&hour, &bucket.Count, &bucket.Bytes); err != nil {
+			return nil, fmt.Errorf("scan expiry bucket: %w", err)
+		}
+		bucket.Hour = time.Unix(0, hour).UTC()
+		buckets = append(buckets, bucket)
+	}
+	return buckets, rows.Err()
+}
+
+// PurgeNamespace zeroes the namespace's data keys and deletes its secrets in
+// one transaction, counting those that were still live
+func (s *Store) PurgeNamespace(ctx context.Context, namespace string) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, purged, err := deleteLive(ctx, tx, `namespace = ?`, namespace)
+	if err != nil {
+		return 0, fmt.Errorf("purge namespace: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit purge: %w", err)
+	}
+	return purged, nil
+}
+
+// StartNamespaceDeletion records the deletion, or returns the one already
+// started. Creates check for the row under the write lock, so none lands
+// after it.
+func (s *Store) StartNamespaceDeletion(ctx context.Context, namespace string, now time.Time) (*store.NamespaceDeletion, error) {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO namespace_deletions (namespace, started_at) VALUES (?, ?)
+		ON CONFLICT (namespace) DO NOTHING
+	`, namespace, now.UnixNano())
+	if err != nil {
+		return nil, fmt.Errorf("start namespace deletion: %w", err)
+	}
+	return s.NamespaceDeletion(ctx, namespace)
+}
+
+// NamespaceDeletion returns a deletion's progress
+func (s *Store) NamespaceDeletion(ctx context.Context, namespace string) (*store.NamespaceDeletion, error) {
+	deletion, err := scanNamespaceDeletion(s.db.QueryRowContext(ctx, `
+		SELECT `+namespaceDeletionColumns+` FROM namespace_deletions WHERE namespace = ?
+	`, namespace))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query namespace deletion: %w", err)
+	}
+	return deletion, nil
+}
+
+// NamespaceDeletions returns every started deletion by namespace
+func (s *Store) NamespaceDeletions(ctx context.Context) ([]store.NamespaceDeletion, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+namespaceDeletionColumns+` FROM namespace_deletions ORDER BY namespace
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query namespace deletions: %w", err)
+	}
+	defer rows.Close()
+
+	var deletions []store.NamespaceDeletion
+	for rows.Next() {
+		deletion, err := scanNamespaceDeletion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan namespace deletion: %w", err)
+		}
+		deletions = append(deletions, *deletion)
+	}
+	return deletions, rows.Err()
+}
+
+// DeleteNamespaceBatch removes up to limit of the namespace's secrets, or
+// once none are left clears it from up to limit audit events, and adds the
+// counts to the deletion in the same transaction
+func (s *Store) DeleteNamespaceBatch(ctx context.Context, namespace string, limit int, now time.Time) (*store.NamespaceDeletion, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var started bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM namespace_deletions WHERE namespace = ?)`, namespace).Scan(&started)
+	if err != nil {
+		return nil, fmt.Errorf("check namespace deletion: %w", err)
+	}
+	if !started {
+		return nil, store.ErrNotFound
+	}
+
+	batch := `id IN (SELECT id FROM secrets WHERE namespace = ? ORDER BY id LIMIT ?)`
+	result, err := tx.ExecContext(ctx, `DELETE FROM secret_receipts WHERE secret_`+batch, namespace, limit)
+	if err != nil {
+		return nil, fmt.Errorf("delete namespace receipts: %w", err)
+	}
+	receipts := rowsAffected(result)
+	secrets, live, err := deleteLive(ctx, tx, batch, namespace, limit)
+	if err != nil {
+		return nil, fmt.Errorf("delete namespace secrets: %w", err)
+	}
+
+	var anonymized int64
+	if remaining := int64(limit) - secrets; remaining > 0 {
+		result, err := tx.ExecContext(ctx, `
+			UPDATE audit_events SET namespace = ''
+			WHERE id IN (SELECT id FROM audit_events WHERE namespace = ? ORDER BY id LIMIT ?)
+		`, namespace, remaining)
+		if err != nil {
+			return nil, fmt.Errorf("anonymize namespace audit events: %w", err)
+		}
+		anonymized = rowsAffected(result)
+
+		if anonymized < remaining {
+			_, err = tx.ExecContext(ctx, `
+				UPDATE namespace_deletions SET finished_at = ? WHERE namespace = ? AND finished_at IS NULL
+			`, now.UnixNano(), namespace)
+			if err != nil {
+				return nil, fmt.Errorf("finish namespace deletion: %w", err)
+			}
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE namespace_deletions
+		SET secrets_deleted = secrets_deleted + ?,
+		    live_secrets_deleted = live_secrets_deleted + ?,
+		    receipts_deleted = receipts_deleted + ?,
+		    audit_events_anonymized = audit_events_anonymized + ?
+		WHERE namespace = ?
+	`, secrets, live, receipts, anonymized, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("record namespace deletion progress: %w", err)
+	}
+
+	deletion, err := scanNamespaceDeletion(tx.QueryRowContext(ctx, `
+		SELECT `+namespaceDeletionColumns+` FROM namespace_deletions WHERE namespace = ?
+	`, namespace))
+	if err != nil {
+		return nil, fmt.Errorf("query namespace deletion: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit namespace deletion batch: %w", err)
+	}
+	return deletion, nil
+}
+
+// NamespaceReferences counts secrets and audit events naming namespace
+func (s *Store) NamespaceReferences(ctx context.Context, namespace string) (int64, error) {
+	var n int64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT (SELECT COUNT(*) FROM secrets WHERE namespace = ?)
+		     + (SELECT COUNT(*) FROM audit_events WHERE namespace = ?)
+	`, namespace, namespace).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("count namespace references: %w", err)
+	}
+	return n, nil
+}
+
+// DeleteExpired removes secrets that expired before now and counts those
+// that were still live. A secret held for an ack or grace window that is
+// still open is left to it.
+func (s *Store) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, expired, err := deleteLive(ctx, tx, `expires_at < ?1 AND (ack_deadline IS NULL OR ack_deadline < ?1)`, now.UnixNano())
+	if err != nil {
+		return 0, fmt.Errorf("delete expired: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit delete expired: %w", err)
+	}
+	return expired, nil
+}
+
+// ExpiredIDs returns up to limit live, unheld secrets that expired before
+// now
+func (s *Store) ExpiredIDs(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id FROM secrets
+		WHERE expires_at < ? AND ack_deadline IS NULL
+		  AND (NOT key_wrapped OR EXISTS (SELECT 1 FROM secret_keys k WHERE k.secret_id = secrets.id))
+		ORDER BY id
+		LIMIT ?
+	`, now.UnixNano(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("query expired secrets: %w", err)
+	}
+	return scanIDs(rows)
+}
+
+// CollectShredded removes wrapped ciphertext rows whose data key is gone
+func (s *Store) CollectShredded(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM secrets
+		WHERE key_wrapped
+		  AND NOT EXISTS (SELECT 1 FROM secret_keys k WHERE k.secret_id = secrets.id)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected(result), nil
+}
+
+// ActiveSecrets reads a page of deliverable secrets and their parts in one
+// transaction, so a page is a consistent snapshot
+func (s *Store) ActiveSecrets(ctx context.Context, now time.Time, afterID string, limit int) ([]store.Secret, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT s.id, s.ciphertext, s.iv, s.salt, s.expires_at, s.burn_after_read, s.created_at, k.data_key, COALESCE(k.key_version, ''),
+		       s.declared_key_bits, s.management_token_hash, s.require_ack, COALESCE(s.namespace, ''), s.iv_embedded, s.available_after,
+		       COALESCE(s.creator, ''), COALESCE(s.hint, ''), s.notify_email, COALESCE(s.notify_email_hash, ''),
+		       COALESCE(s.content_type, ''), COALESCE(s.filename, ''), s.version
+		FROM secrets s
+		LEFT JOIN secret_keys k ON k.secret_id = s.id
+		WHERE s.id > ? AND s.expires_at > ? AND s.ack_deadline IS NULL
+		  AND (NOT s.key_wrapped OR k.secret_id IS NOT NULL)
+		  AND substr(s.id, 1, ?) <> ?
+		ORDER BY s.id
+		LIMIT ?
+	`, afterID, now.UnixNano(), len(store.CanaryIDPrefix), store.CanaryIDPrefix, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query active secrets: %w", err)
+	}
+	defer rows.Close()
+
+	var secrets []store.Secret
+	for rows.Next() {
+		var secret store.Secret
+		var expiresAt, createdAt int64
+		var keyBits, availableAfter sql.NullInt64
+		if err := rows.Scan(&secret.ID, &secret.Ciphertext, &secret.IV, &secret.Salt, &expiresAt, &secret.BurnAfterRead, &createdAt,
+			&secret.DataKey, &secret.KeyVersion, &keyBits, &secret.ManagementTokenHash, &secret.RequireAck, &secret.Namespace,
+			&secret.IVEmbedded, &availableAfter, &secret.Creator, &secret.Hint, &secret.NotifyEmail, &secret.NotifyEmailHash,
+			&secret.ContentType, &secret.Filename, &secret.Revision); err != nil {
+			return nil, fmt.Errorf("scan active secret: %w", err)
+		}
+		secret.ExpiresAt = time.Unix(0, expiresAt)
+		secret.CreatedAt = time.Unix(0, createdAt)
+		secret.AvailableAfter = timeFromNanos(availableAfter)
+		if keyBits.Valid {
+			bits := int(keyBits.Int64)
+			secret.DeclaredKeyBits = &bits
+		}
+		secrets = append(secrets, secret)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query active secrets: %w", err)
+	}
+	rows.Close()
+
+	for i := range secrets {
+		if secrets[i].Parts, err = loadParts(ctx, tx, secrets[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return secrets, tx.Commit()
+}
+
+// StaleDataKeys returns up to limit data keys not wrapped under version,
+// walking secret_keys in ID order after afterID
+func (s *Store) StaleDataKeys(ctx context.Context, version, afterID string, limit int) ([]store.StoredDataKey, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT secret_id, data_key, COALESCE(key_version, '')
+		FROM secret_keys
+		WHERE key_version IS NOT ? AND secret_id > ?
+		ORDER BY secret_id
+		LIMIT ?
+	`, version, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query stale data keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []store.StoredDataKey
+	for rows.Next() {
+		var key store.StoredDataKey
+		if err := rows.Scan(&key.SecretID, &key.Key, &key.Version); err != nil {
+			return nil, fmt.Errorf("scan data key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// ReplaceDataKey swaps in a rewrapped data key. The old wrapped key is the
+// row version: a key changed or shredded since it was read does not match.
+// SQLite runs one writer at a time, so a consume is either finished, and
+// wins, or waits for this single statement.
+func (s *Store) ReplaceDataKey(ctx context.Context, old store.StoredDataKey, key []byte, version string) (bool, error) {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE secret_keys SET data_key = ?, key_version = NULLIF(?, '')
+		WHERE secret_id = ? AND data_key = ?
+	`, key, version, old.SecretID, []byte(old.Key))
+	if err != nil {
+		return false, fmt.Errorf("replace data key: %w", err)
+	}
+	return rowsAffected(result) > 0, nil
+}
+
+// DataKeyVersions counts stored data keys per envelope key version
+func (s *Store) DataKeyVersions(ctx context.Context) ([]store.KeyVersionCount, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT COALESCE(key_version, ''), COUNT(*)
+		FROM secret_keys
+		GROUP BY 1
+		ORDER BY 1
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("count data key versions: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []store.KeyVersionCount
+	for rows.Next() {
+		var count store.KeyVersionCount
+		if err := rows.Scan(&count.Version, &count.DataKeys); err != nil {
+			return nil, fmt.Errorf("scan data key version: %w", err)
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+// PruneReceipts removes read receipts consumed before cutoff
+func (s *Store) PruneReceipts(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM secret_receipts WHERE consumed_at < ?`, cutoff.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected(result), nil
+}
+
+// BurnUnacknowledged removes held secrets whose ack or grace window ended
+// before now; receipts of require_ack secrets stay unacknowledged
+func (s *Store) BurnUnacknowledged(ctx context.Context, now time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM secrets
+		WHERE ack_deadline < ?
+		  AND (NOT key_wrapped OR EXISTS (SELECT 1 FROM secret_keys k WHERE k.secret_id = secrets.id))
+	`, now.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected(result), nil
+}
+
+// ClampExpiry lowers expires_at to ceiling for up to limit secrets that
+// expire after it. Held require_ack secrets are left to their ack window.
+func (s *Store) ClampExpiry(ctx context.Context, ceiling time.Time, limit int) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE secrets SET expires_at = ?1, version = version + 1
+		WHERE id IN (
+			SELECT id FROM secrets
+			WHERE expires_at > ?1 AND ack_deadline IS NULL
+			ORDER BY expires_at DESC
+			LIMIT ?2
+		)
+		RETURNING id
+	`, ceiling.UnixNano(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("clamp expiry: %w", err)
+	}
+	defer rows.Close()
+
+	return scanIDs(rows)
+}
+
+// Stragglers joins a sample of recent receipts back to secrets. A receipt
+// is written in the consuming transaction, so any readable row behind it
+// escaped destruction.
+func (s *Store) Stragglers(ctx context.Context, since, now time.Time, sample int) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		WITH sampled AS (
+			SELECT secret_id FROM secret_receipts
+			WHERE consumed_at >= ?1
+			ORDER BY consumed_at DESC
+			LIMIT ?3
+		)
+		SELECT s.id
+		FROM sampled r
+		JOIN secrets s ON s.id = r.secret_id
+		WHERE (NOT s.key_wrapped OR EXISTS (SELECT 1 FROM secret_keys k WHERE k.secret_id = s.id))
+		  AND (s.ack_deadline IS NULL OR s.ack_deadline < ?2)
+		ORDER BY s.id
+	`, since.UnixNano(), now.UnixNano(), sample)
+	if err != nil {
+		return nil, fmt.Errorf("query stragglers: %w", err)
+	}
+	defer rows.Close()
+
+	return scanIDs(rows)
+}
+
+// ReceiptIDs returns the IDs of secrets consumed between from and to
+func (s *Store) ReceiptIDs(ctx context.Context, from, to time.Time) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT secret_id FROM secret_receipts
+		WHERE consumed_at BETWEEN ? AND ?
+		ORDER BY secret_id
+	`, from.UnixNano(), to.UnixNano())
+	if err != nil {
+		return nil, fmt.Errorf("query receipts: %w", err)
+	}
+	defer rows.Close()
+
+	return scanIDs(rows)
+}
+
+// RecordAudit appends an event to the audit log
+func (s *Store) RecordAudit(ctx context.Context, event *store.AuditEvent) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO audit_events (id, event_type, occurred_at, namespace, secret_id_hash, network_class, reason, actor)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, event.ID, event.Type, event.OccurredAt.UnixNano(), event.Namespace, event.SecretIDHash, event.NetworkClass, event.Reason, event.Actor)
+	if err != nil {
+		return fmt.Errorf("insert audit event: %w", err)
+	}
+	return nil
+}
+
+// ScanAudit streams matching audit events in ID order
+func (s *Store) ScanAudit(ctx context.Context, filter store.AuditFilter, fn func(*store.AuditEvent) error) error {
+	where, args := filter.Where(func(n int) string { return "?" + strconv.Itoa(n) })
+	query := `
+		SELECT id, event_type, occurred_at, namespace, secret_id_hash, network_class, reason, actor
+		FROM audit_events
+		WHERE ` + where + `
+		ORDER BY id`
+	if filter.Limit > 0 {
+		query += " LIMIT " + strconv.Itoa(filter.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("query audit events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event store.AuditEvent
+		var occurredAt int64
+		var networkClass, reason, actor sql.NullString
+		if err := rows.Scan(&event.ID, &event.Type, &occurredAt, &event.Namespace, &event.SecretIDHash, &networkClass, &reason, &actor); err != nil {
+			return fmt.Errorf("scan audit event: %w", err)
+		}
+		event.OccurredAt = time.Unix(0, occurredAt)
+		event.NetworkClass = networkClass.String
+		event.Reason = reason.String
+		event.Actor = actor.String
+
+		if err := fn(&event); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// RecordDroppedWork inserts loss records in one transaction
+func (s *Store) RecordDroppedWork(ctx context.Context, losses []store.DroppedWork) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, loss := range losses {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO dropped_work (kind, reason, count, recorded_at)
+			VALUES (?, ?, ?, ?)
+		`, loss.Kind, loss.Reason, loss.Count, loss.RecordedAt.UnixNano())
+		if err != nil {
+			return fmt.Errorf("insert dropped work: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit dropped work: %w", err)
+	}
+	return nil
+}
+
+// DroppedWorkSince sums loss records from since onward per kind and reason
+func (s *Store) DroppedWorkSince(ctx context.Context, since time.Time) ([]store.DroppedWork, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT kind, reason, SUM(count), MAX(recorded_at)
+		FROM dropped_work
+		WHERE recorded_at >= ?
+		GROUP BY kind, reason
+		ORDER BY kind, reason
+	`, since.UnixNano())
+	if err != nil {
+		return nil, fmt.Errorf("query dropped work: %w", err)
+	}
+	defer rows.Close()
+
+	var losses []store.DroppedWork
+	for rows.Next() {
+		var loss store.DroppedWork
+		var recordedAt int64
+		if err := rows.Scan(&loss.Kind, &loss.Reason, &loss.Count, &recordedAt); err != nil {
+			return nil, fmt.Errorf("scan dropped work: %w", err)
+		}
+		loss.RecordedAt = time.Unix(0, recordedAt)
+		losses = append(losses, loss)
+	}
+	return losses, rows.Err()
+}
+
+// PruneDroppedWork removes loss records written before cutoff
+func (s *Store) PruneDroppedWork(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM dropped_work WHERE recorded_at < ?`, cutoff.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected(result), nil
+}
+
+// RecordSizeBucket increments a bucket's counter, creating it on first use
+func (s *Store) RecordSizeBucket(ctx context.Context, upperBound int64) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO secret_size_buckets (upper_bound, count) VALUES (?, 1)
+		ON CONFLICT (upper_bound) DO UPDATE SET count = count + 1
+	`, upperBound)
+	if err != nil {
+		return fmt.Errorf("record size bucket: %w", err)
+	}
+	return nil
+}
+
+// SizeBuckets returns every bucket counter, smallest first
+func (s *Store) SizeBuckets(ctx context.Context) ([]store.SizeBucket, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT upper_bound, count FROM secret_size_buckets ORDER BY upper_bound`)
+	if err != nil {
+		return nil, fmt.Errorf("query size buckets: %w", err)
+	}
+	defer rows.Close()
+
+	var buckets []store.SizeBucket
+	for rows.Next() {
+		var bucket store.SizeBucket
+		if err := rows.Scan(&bucket.UpperBound, &bucket.Count); err != nil {
+			return nil, fmt.Errorf("scan size bucket: %w", err)
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets, rows.Err()
+}
+
+// statsDayFormat keys daily_stats rows; it sorts in date order
+const statsDayFormat = time.DateOnly
+
+// AddDailyStats adds delta to its day's row, creating it on first use
+func (s *Store) AddDailyStats(ctx context.Context, delta store.DailyStats) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO daily_stats (day, created, retrieved, burned, expired, bytes, ttl_seconds)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (day) DO UPDATE SET
+			created = created + excluded.created,
+			retrieved = retrieved + excluded.retrieved,
+			burned = burned + excluded.burned,
+			expired = expired + excluded.expired,
+			bytes = bytes + excluded.bytes,
+			ttl_seconds = ttl_seconds + excluded.ttl_seconds
+	`, store.StatsDay(delta.Day).Format(statsDayFormat), delta.Created, delta.Retrieved, delta.Burned, delta.Expired, delta.Bytes, delta.TTLSeconds)
+	if err != nil {
+		return fmt.Errorf("add daily stats: %w", err)
+	}
+	return nil
+}
+
+// DailyStats returns the rows for from through to, oldest first
+func (s *Store) DailyStats(ctx context.Context, from, to time.Time) ([]store.DailyStats, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT day, created, retrieved, burned, expired, bytes, ttl_seconds
+		FROM daily_stats
+		WHERE day BETWEEN ? AND ?
+		ORDER BY day
+	`, store.StatsDay(from).Format(statsDayFormat), store.StatsDay(to).Format(statsDayFormat))
+	if err != nil {
+		return nil, fmt.Errorf("query daily stats: %w", err)
+	}
+	defer rows.Close()
+
+	var days []store.DailyStats
+	for rows.Next() {
+		var day store.DailyStats
+		var date string
+		if err := rows.Scan(&date, &day.Created, &day.Retrieved, &day.Burned, &day.Expired, &day.Bytes, &day.TTLSeconds); err != nil {
+			return nil, fmt.Errorf("scan daily stats: %w", err)
+		}
+		day.Day, err = time.Parse(statsDayFormat, date)
+		if err != nil {
+			return nil, fmt.Errorf("parse daily stats day: %w", err)
+		}
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
+
+// SaveClientScores upserts reputations in one transaction; a stored score
+// updated later than the one given is kept
+func (s *Store) SaveClientScores(ctx context.Context, scores []store.ClientScore) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, score := range scores {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO client_reputation (client_key, score, updated_at)
+			VALUES (?, ?, ?)
+			ON CONFLICT (client_key) DO UPDATE
+			SET score = excluded.score, updated_at = excluded.updated_at
+			WHERE client_reputation.updated_at <= excluded.updated_at
+		`, score.Key, score.Score, score.UpdatedAt.UnixNano())
+		if err != nil {
+			return fmt.Errorf("save client score: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit client scores: %w", err)
+	}
+	return nil
+}
+
+// ClientScores returns the stored reputations of keys
+func (s *Store) ClientScores(ctx context.Context, keys []string) ([]store.ClientScore, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	args := make([]any, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT client_key, score, updated_at FROM client_reputation
+		WHERE client_key IN (?`+strings.Repeat(", ?", len(keys)-1)+`)
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query client scores: %w", err)
+	}
+	return collectClientScores(rows)
+}
+
+// LowestClientScores returns up to limit reputations, lowest score first
+func (s *Store) LowestClientScores(ctx context.Context, limit int) ([]store.ClientScore, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT client_key, score, updated_at FROM client_reputation
+		ORDER BY score, client_key
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query lowest client scores: %w", err)
+	}
+	return collectClientScores(rows)
+}
+
+// collectClientScores scans and closes rows of client_key, score, updated_at
+func collectClientScores(rows *sql.Rows) ([]store.ClientScore, error) {
+	defer rows.Close()
+
+	var scores []store.ClientScore
+	for rows.Next() {
+		var score store.ClientScore
+		var updatedAt int64
+		if err := rows.Scan(&score.Key, &score.Score, &updatedAt); err != nil {
+			return nil, fmt.Errorf("scan client score: %w", err)
+		}
+		score.UpdatedAt = time.Unix(0, updatedAt)
+		scores = append(scores, score)
+	}
+	return scores, rows.Err()
+}
+
+// PruneClientScores removes reputations last updated before cutoff
+func (s *Store) PruneClientScores(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM client_reputation WHERE updated_at < ?`, cutoff.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected(result), nil
+}
+
+// Ping checks the database is readable
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// PoolStats reports database/sql's connection statistics. It does not
+// count acquires or new connections; waits for a connection at the open
+// limit stand in for empty acquires, and their duration for the acquire
+// wait.
+func (s *Store) PoolStats() store.PoolStats {
+	stats := s.db.Stats()
+	return store.PoolStats{
+		AcquiredConns:     int64(stats.InUse),
+		IdleConns:         int64(stats.Idle),
+		TotalConns:        int64(stats.OpenConnections),
+		MaxConns:          int64(stats.MaxOpenConnections),
+		AcquireDuration:   stats.WaitDuration,
+		EmptyAcquireCount: stats.WaitCount,
+	}
+}
+
+// Close closes the database
+func (s *Store) Close() {
+	s.db.Close()
+}
+
+// loadParts reads a secret's parts in order; single-blob secrets have none
+func loadParts(ctx context.Context, tx *sql.Tx, id string) ([]store.Part, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT label, ciphertext, iv
+		FROM secret_parts
+		WHERE secret_id = ?
+		ORDER BY position
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("query secret parts: %w", err)
+	}
+	defer rows.Close()
+
+	var parts []store.Part
+	for rows.Next() {
+		var part store.Part
+		if err := rows.Scan(&part.Label, &part.Ciphertext, &part.IV); err != nil {
+			return nil, fmt.Errorf("scan secret part: %w", err)
+		}
+		parts = append(parts, part)
+	}
+
+	return parts, rows.Err()
+}
+
+// destroy shreds a wrapped secret's key or deletes a legacy row outright
+func destroy(ctx context.Context, tx *sql.Tx, id string, keyWrapped bool) error {
+	if keyWrapped {
+		// Shred the key; the ciphertext row is collected later
+		_, err := shredKey(ctx, tx, id)
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM secrets WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("delete secret: %w", err)
+	}
+	return nil
+}
+
+// shredKey overwrites a secret's data key with zeros and deletes it. With
+// secure_delete on, the freed page content is zeroed as well. The secret's
+// hint, notify address, content type and file name are dropped with its key.
+func shredKey(ctx context.Context, tx *sql.Tx, id string) (bool, error) {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE secret_keys
+		SET data_key = zeroblob(length(data_key))
+		WHERE secret_id = ?
+	`, id)
+	if err != nil {
+		return false, fmt.Errorf("zero secret key: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM secret_keys WHERE secret_id = ?`, id)
+	if err != nil {
+		return false, fmt.Errorf("delete secret key: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE secrets SET hint = NULL, notify_email = NULL, notify_email_hash = NULL, content_type = NULL, filename = NULL
+		WHERE id = ? AND (hint IS NOT NULL OR notify_email IS NOT NULL OR content_type IS NOT NULL OR filename IS NOT NULL)
+	`, id); err != nil {
+		return false, fmt.Errorf("drop secret hint: %w", err)
+	}
+
+	return rowsAffected(result) > 0, nil
+}
+
+// deleteLive zeroes the data keys of the secrets matching where and deletes
+// them, returning how many rows it removed and how many of those were still
+// live: legacy rows plus wrapped rows whose key was not shredded yet. The
+// caller's immediate transaction keeps a consume, ack or burn from
+// shredding in between.
+func deleteLive(ctx context.Context, tx *sql.Tx, where string, args ...any) (removed, live int64, err error) {
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM secrets
+		WHERE `+where+`
+		  AND (NOT key_wrapped OR EXISTS (SELECT 1 FROM secret_keys k WHERE k.secret_id = secrets.id))
+	`, args...).Scan(&live)
+	if err != nil {
+		return 0, 0, fmt.Errorf("count live secrets: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE secret_keys SET data_key = zeroblob(length(data_key))
+		WHERE secret_id IN (SELECT id FROM secrets WHERE `+where+`)
+	`, args...)
+	if err != nil {
+		return 0, 0, fmt.Errorf("zero secret keys: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM secrets WHERE `+where, args...)
+	if err != nil {
+		return 0, 0, fmt.Errorf("delete secrets: %w", err)
+	}
+	return rowsAffected(result), live, nil
+}
+
+// namespaceDeletionColumns are the columns scanNamespaceDeletion reads
+const namespaceDeletionColumns = `namespace, started_at, finished_at, secrets_deleted, live_secrets_deleted, receipts_deleted, audit_events_anonymized`
+
+// scanNamespaceDeletion reads one namespace_deletions row
+func scanNamespaceDeletion(row interface{ Scan(...any) error }) (*store.NamespaceDeletion, error) {
+	var deletion store.NamespaceDeletion
+	var startedAt int64
+	var finishedAt sql.NullInt64
+	err := row.Scan(&deletion.Namespace, &startedAt, &finishedAt, &deletion.SecretsDeleted,
+		&deletion.LiveSecretsDeleted, &deletion.ReceiptsDeleted, &deletion.AuditEventsAnonymized)
+	if err != nil {
+		return nil, err
+	}
+	deletion.StartedAt = time.Unix(0, startedAt)
+	if finishedAt.Valid {
+		deletion.FinishedAt = time.Unix(0, finishedAt.Int64)
+	}
+	return &deletion, nil
+}
+
+// rowsAffected reads a result's row count; the driver always reports it
+func rowsAffected(result sql.Result) int64 {
+	n, _ := result.RowsAffected()
+	return n
+}
+
+// nonNil stores empty blobs rather than NULL for NOT NULL columns
+func nonNil(b []byte) []byte {
+	if b == nil {
+		return []byte{}
+	}
+	return b
+}
+
+// unixNanos stores an optional time as Unix nanoseconds, NULL when nil
+func unixNanos(t *time.Time) *int64 {
+	if t == nil {
+		return nil
+	}
+	n := t.UnixNano()
+	return &n
+}
+
+// timeFromNanos reads an optional time stored by unixNanos
+func timeFromNanos(n sql.NullInt64) *time.Time {
+	if !n.Valid {
+		return nil
+	}
+	t := time.Unix(0, n.Int64)
+	return &t
+}
+
+var (
+	_ store.Store        = (*Store)(nil)
+	_ store.PoolReporter = (*Store)(nil)
+)
+
+func scanIDs(rows *sql.Rows) ([]string, error) {
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
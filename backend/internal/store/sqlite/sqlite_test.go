@@ -0,0 +1,167 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"ots-backend/internal/store"
+	"ots-backend/internal/store/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		s, err := Open(filepath.Join(t.TempDir(), "ots.db"))
+		if err != nil {
+			t.Fatalf("Open() error: %v", err)
+		}
+		t.Cleanup(s.Close)
+		return s
+	})
+}
+
+func TestOpenIsIdempotent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ots.db")
+
+	for range 2 {
+		s, err := Open(path)
+		if err != nil {
+			t.Fatalf("Open() error: %v", err)
+		}
+
+		var mode string
+		if err := s.DB().QueryRow(`PRAGMA journal_mode`).Scan(&mode); err != nil {
+			t.Fatalf("read journal_mode: %v", err)
+		}
+		if mode != "wal" {
+			t.Errorf("journal_mode = %q, want wal", mode)
+		}
+		s.Close()
+	}
+}
+
+// TestExpiryForecastPlan guards the forecast against falling back to a
+// table scan: every secrets column it reads is in the expiry index
+func TestExpiryForecastPlan(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "ots.db"))
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer s.Close()
+
+	rows, err := s.DB().Query("EXPLAIN QUERY PLAN "+expiryForecastQuery, 1, 0, 1, "", "")
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	defer rows.Close()
+
+	var plan []string
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+		if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+			t.Fatalf("scan plan: %v", err)
+		}
+		plan = append(plan, detail)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("read plan: %v", err)
+	}
+	if !slices.ContainsFunc(plan, func(detail string) bool {
+		return strings.Contains(detail, "USING COVERING INDEX idx_secrets_expiry_forecast")
+	}) {
+		t.Errorf("plan does not scan the covering expiry index:\n%s", strings.Join(plan, "\n"))
+	}
+}
+
+func TestPoolStatsAtSaturation(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "ots.db"))
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer s.Close()
+	s.DB().SetMaxOpenConns(2)
+
+	ctx := context.Background()
+	var conns []*sql.Conn
+	for range 2 {
+		conn, err := s.DB().Conn(ctx)
+		if err != nil {
+			t.Fatalf("Conn() error: %v", err)
+		}
+		conns = append(conns, conn)
+	}
+
+	// A third caller waits for a connection until it gives up
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := s.DB().Conn(waitCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Conn() on a saturated pool error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	stats := s.PoolStats()
+	if stats.AcquiredConns != 2 || stats.IdleConns != 0 || stats.TotalConns != 2 || stats.MaxConns != 2 {
+		t.Errorf("PoolStats() saturated = %+v, want 2 of 2 acquired, none idle", stats)
+	}
+	if stats.EmptyAcquireCount != 1 || stats.AcquireDuration < 20*time.Millisecond {
+		t.Errorf("PoolStats() waits = %d for %s, want 1 for at least 20ms", stats.EmptyAcquireCount, stats.AcquireDuration)
+	}
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+	if stats := s.PoolStats(); stats.AcquiredConns != 0 || stats.IdleConns != 2 {
+		t.Errorf("PoolStats() released = %+v, want 2 idle", stats)
+	}
+}
+
+func TestPathFromURL(t *testing.T) {
+	tests := []struct {
+		url    string
+		want   string
+		wantOK bool
+	}{
+		{"sqlite:///var/lib/ots/ots.db", "/var/lib/ots/ots.db", true},
+		{"sqlite://ots.db", "ots.db", true},
+		{"sqlite://", "", false},
+		{"postgres://localhost/ots", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := PathFromURL(tt.url)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("PathFromURL(%q) = %q, %v; want %q, %v", tt.url, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+// codedError stands in for the driver's error, whose fields are unexported
+type codedError int
+
+func (e codedError) Error() string { return "sqlite error " + strconv.Itoa(int(e)) }
+func (e codedError) Code() int     { return int(e) }
+
+func TestTransient(t *testing.T) {
+	s := &Store{}
+	tests := map[error]bool{
+		codedError(5):                            true,  // SQLITE_BUSY
+		codedError(5 | 2<<8):                     true,  // SQLITE_BUSY_SNAPSHOT
+		codedError(6):                            true,  // SQLITE_LOCKED
+		codedError(11):                           false, // SQLITE_CORRUPT
+		codedError(19 | 8<<8):                    false, // SQLITE_CONSTRAINT_PRIMARYKEY
+		fmt.Errorf("consume: %w", codedError(5)): true,
+		errors.New("no such column: creator"):    false,
+	}
+	for err, want := range tests {
+		if got := s.Transient(err); got != want {
+			t.Errorf("Transient(%v) = %v, want %v", err, got, want)
+		}
+	}
+}
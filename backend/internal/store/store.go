@@ -0,0 +1,780 @@
+// Package store defines the persistence contract for secrets. The API
+// handlers and the cleanup worker only talk to a Store, so Postgres and the
+// single-binary SQLite backend are interchangeable.
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"ots-backend/internal/crypto"
+	"ots-backend/internal/sensitive"
+	"ots-backend/pkg/ots"
+)
+
+// ErrNotFound indicates the secret does not exist, has expired or was shredded
+var ErrNotFound = errors.New("secret not found")
+
+// ErrDuplicateID indicates a create with the ID of a secret still stored
+var ErrDuplicateID = errors.New("secret ID already in use")
+
+// ErrNamespaceDeleted indicates a create into a namespace that is being, or
+// has been, deleted
+var ErrNamespaceDeleted = ots.ErrNamespaceDeleted
+
+// ErrQuotaExceeded indicates a create whose creator already holds as many
+// live secrets as its CreatorQuota allows
+var ErrQuotaExceeded = ots.ErrQuotaExceeded
+
+// ErrNotYetAvailable indicates a read before a secret's AvailableAfter.
+// Stores return it as a *NotYetAvailableError carrying the time.
+var ErrNotYetAvailable = ots.ErrNotYetAvailable
+
+// NotYetAvailableError reports a secret held back until AvailableAfter
+type NotYetAvailableError struct {
+	AvailableAfter time.Time
+}
+
+func (e *NotYetAvailableError) Error() string {
+	return ErrNotYetAvailable.Error() + " until " + e.AvailableAfter.UTC().Format(time.RFC3339)
+}
+
+// Unwrap makes the error match ErrNotYetAvailable
+func (e *NotYetAvailableError) Unwrap() error {
+	return ErrNotYetAvailable
+}
+
+// CheckAvailable returns a *NotYetAvailableError while now is before
+// availableAfter, and nil once it has passed or when it is nil
+func CheckAvailable(availableAfter *time.Time, now time.Time) error {
+	if availableAfter != nil && now.Before(*availableAfter) {
+		return &NotYetAvailableError{AvailableAfter: *availableAfter}
+	}
+	return nil
+}
+
+// ErrRevisionMismatch indicates a change made conditional on a revision the
+// secret has moved past. Stores return it as a *RevisionMismatchError
+// carrying the current revision.
+var ErrRevisionMismatch = ots.ErrRevisionMismatch
+
+// RevisionMismatchError reports the revision a conditional change found
+type RevisionMismatchError struct {
+	Current int64
+}
+
+func (e *RevisionMismatchError) Error() string {
+	return fmt.Sprintf("%s: secret is at revision %d", ErrRevisionMismatch, e.Current)
+}
+
+// Unwrap makes the error match ErrRevisionMismatch
+func (e *RevisionMismatchError) Unwrap() error {
+	return ErrRevisionMismatch
+}
+
+// CheckRevision returns a *RevisionMismatchError unless want is zero, for
+// an unconditional change, or current
+func CheckRevision(want, current int64) error {
+	if want != 0 && want != current {
+		return &RevisionMismatchError{Current: current}
+	}
+	return nil
+}
+
+// FirstRevision is the revision of a newly created secret. Every state
+// change after it adds one: an ack or grace hold, a lowered expiry, the
+// ending, whose revision its receipt keeps, and an acknowledgement.
+const FirstRevision int64 = 1
+
+// CanaryIDPrefix starts the IDs of secrets the self-test canary writes. No
+// ID a client can send has it, and CountActive and DeclaredKeyBits skip
+// these secrets so they never show up in user-facing stats.
+const CanaryIDPrefix = "canary_"
+
+// Part is one labelled, independently encrypted part of a secret
+type Part struct {
+	Label      string
+	Ciphertext sensitive.Bytes
+	IV         sensitive.Bytes
+}
+
+// Secret is a stored secret. Ciphertext and part ciphertexts are stored as
+// given; in crypto-shredding mode they are already wrapped with DataKey.
+type Secret struct {
+	ID            string
+	Ciphertext    sensitive.Bytes
+	IV            sensitive.Bytes
+	Salt          sensitive.Bytes
+	Parts         []Part
+	ExpiresAt     time.Time
+	CreatedAt     time.Time
+	BurnAfterRead bool
+	// DataKey is the per-secret key in crypto-shredding mode, nil otherwise.
+	// With envelope keys it is stored wrapped under KeyVersion.
+	DataKey sensitive.Bytes
+	// KeyVersion names the envelope key wrapping DataKey, empty when the
+	// data key is stored as it is
+	KeyVersion string
+	// DeclaredKeyBits is the client's declared link key length, nil if undeclared
+	DeclaredKeyBits     *int
+	ManagementTokenHash []byte
+	// RequireAck holds the secret after its first read until the reader
+	// acknowledges it or the acknowledgement window runs out
+	RequireAck bool
+	// Namespace is the tenant the secret belongs to, empty for none
+	Namespace string
+	// IVEmbedded marks a Ciphertext that starts with its nonce; IV is empty
+	IVEmbedded bool
+	// AvailableAfter refuses reads until it passes, without consuming the
+	// secret; nil for none
+	AvailableAfter *time.Time
+	// Creator is an opaque hash of who created the secret, empty for none
+	Creator string
+	// Hint is the sender's HTML-escaped plaintext preview, shown before a
+	// read and removed with the secret; empty for none
+	Hint string
+	// ContentType and Filename are what the sender says the plaintext is,
+	// returned to the recipient for rendering and removed with the secret
+	// like Hint; empty for none
+	ContentType string
+	Filename    string
+	// NotifyEmail is the address told when the secret is read or expires
+	// unread, sealed with the server's notification key; nil for none
+	NotifyEmail sensitive.Bytes
+	// NotifyEmailHash is HashNotifyEmail of that address, empty for none
+	NotifyEmailHash string
+	// CreatorQuota caps the live secrets one Creator may hold: Create
+	// reports ErrQuotaExceeded when it already holds that many. Zero is no
+	// cap. It is checked, not stored.
+	CreatorQuota int
+	// Revision is set by the store: FirstRevision on create, and on a
+	// secret Consume or Terminate returns, the revision its ending or hold
+	// left
+	Revision int64
+}
+
+// Preview is what Peek reveals of a secret
+type Preview struct {
+	// Size is the stored ciphertext bytes across the blob and all parts
+	Size int64
+	// Hint is the sender's hint, empty for none
+	Hint string
+	// ContentType and Filename are the sender's, empty for none
+	ContentType string
+	Filename    string
+}
+
+// Receipt records that a secret was consumed and from which network class.
+// Receipts are the tombstones of burned and expired secrets too; Reason
+// tells them apart.
+type Receipt struct {
+	// ConsumedAt is when the secret ended, whatever the reason
+	ConsumedAt   time.Time
+	NetworkClass string
+	// Acknowledged is nil for secrets created without require_ack
+	Acknowledged *bool
+	// Reason is set by the store from how the secret ended
+	Reason TerminationReason
+	// ManagementTokenHash is copied by the store from the ended secret, so
+	// its creator can still ask what became of it; nil for none
+	ManagementTokenHash []byte
+	// Country is the reader's ISO 3166-1 alpha-2 country, empty when
+	// unknown or not looked up
+	Country string
+	// Revision is set by the store: the ended secret's revision
+	Revision int64
+}
+
+// TerminationReason is how a secret ended
+type TerminationReason string
+
+const (
+	// TerminationConsumed is a read
+	TerminationConsumed TerminationReason = "consumed"
+	// TerminationBurned is a burn through the API
+	TerminationBurned TerminationReason = "burned"
+	// TerminationExpired is the cleanup worker finding a secret past its TTL
+	TerminationExpired TerminationReason = "expired"
+)
+
+// CheckTermination refuses reasons Store.Terminate cannot end a secret for;
+// reads go through Consume
+func CheckTermination(reason TerminationReason) error {
+	if reason != TerminationBurned && reason != TerminationExpired {
+		return fmt.Errorf("cannot terminate a secret as %q", reason)
+	}
+	return nil
+}
+
+// NamespaceStats summarizes one namespace's live, unshredded secrets.
+// TotalBytes counts stored ciphertext, parts included; OldestExpiry is the
+// soonest expiry, zero when the namespace holds nothing.
+type NamespaceStats struct {
+	Count        int64
+	TotalBytes   int64
+	OldestExpiry time.Time
+}
+
+// ExpiryBucket counts the live secrets expiring within one hour and their
+// stored ciphertext, parts included
+type ExpiryBucket struct {
+	// Hour is the start of the hour, in UTC
+	Hour  time.Time
+	Count int64
+	Bytes int64
+}
+
+// StoredBytes is the ciphertext bytes a secret stores across the blob and
+// all parts
+func StoredBytes(secret *Secret) int64 {
+	n := int64(len(secret.Ciphertext))
+	for _, part := range secret.Parts {
+		n += int64(len(part.Ciphertext))
+	}
+	return n
+}
+
+// NamespaceDeletion is the progress of one namespace's deletion. Counts
+// are added in the transaction that removes the rows, so a job stopped at
+// any point and resumed counts every row exactly once.
+type NamespaceDeletion struct {
+	Namespace string
+	StartedAt time.Time
+	// FinishedAt is zero while rows referencing the namespace remain
+	FinishedAt time.Time
+	// SecretsDeleted counts secret rows removed, with their parts and keys;
+	// LiveSecretsDeleted those of them that were still readable
+	SecretsDeleted     int64
+	LiveSecretsDeleted int64
+	// ReceiptsDeleted counts read receipts of the removed secrets
+	ReceiptsDeleted int64
+	// AuditEventsAnonymized counts audit events whose namespace was cleared
+	AuditEventsAnonymized int64
+}
+
+// Finished reports whether every row referencing the namespace is gone
+func (d *NamespaceDeletion) Finished() bool {
+	return !d.FinishedAt.IsZero()
+}
+
+// CreatorStats summarizes live secrets per creator against a quota
+type CreatorStats struct {
+	// Creators counts creators holding at least one live secret
+	Creators int64
+	// AtQuota counts creators holding the quota or more
+	AtQuota int64
+	// MaxActive is the most live secrets any one creator holds
+	MaxActive int64
+}
+
+// AckHold is how a require_ack secret is held after delivery
+type AckHold struct {
+	// TokenHash is the SHA-256 of the ack token handed to the reader
+	TokenHash []byte
+	// Deadline is when the held secret is burned without an acknowledgement
+	Deadline time.Time
+}
+
+// GraceHold is how any other secret is held after delivery with a grace
+// window, so a reader whose response was lost can fetch it once more
+type GraceHold struct {
+	// TokenHash is the SHA-256 of the retrieval nonce handed to the reader
+	TokenHash []byte
+	// Deadline is when the held secret is destroyed if not fetched again
+	Deadline time.Time
+}
+
+// ConsumeOptions controls an atomic consume
+type ConsumeOptions struct {
+	// Now decides expiry; expired secrets are reported as not found without
+	// being locked, and the cleanup worker deletes them
+	Now time.Time
+	// Receipt is recorded in the consuming transaction when set
+	Receipt *Receipt
+	// Open runs before the secret is destroyed; an error aborts the consume
+	// and leaves the secret in place
+	Open func(*Secret) error
+	// Ack holds a require_ack secret for acknowledgement instead of
+	// destroying it. Other secrets ignore it; without it every secret is
+	// destroyed on read.
+	Ack *AckHold
+	// Grace holds a secret not held for an ack until Deadline instead of
+	// destroying it; nil destroys it on read
+	Grace *GraceHold
+	// RetrievalTokenHash, when set, makes the consume a retry: only a
+	// secret held in its grace window under this token is delivered, and
+	// destroyed. Receipt is not recorded again.
+	RetrievalTokenHash []byte
+}
+
+// KeyBitsCount is one bucket of the declared key length distribution
+type KeyBitsCount struct {
+	// Bits is nil for secrets created without a declaration
+	Bits  *int
+	Count int64
+}
+
+// Audit event types
+const (
+	AuditSecretCreated          = "secret.created"
+	AuditSecretConsumed         = "secret.consumed"
+	AuditSecretBurned           = "secret.burned"
+	AuditSecretExpired          = "secret.expired"
+	AuditSecretAcknowledged     = "secret.acknowledged"
+	AuditSecretExpiryReduced    = "secret.expiry_reduced"
+	AuditSecretStragglerRemoved = "secret.straggler_removed"
+	AuditSecretReported         = "secret.reported"
+	AuditSecretDossierGenerated = "secret.dossier_generated"
+)
+
+// HashSecretID returns the hex SHA-256 the audit log stores in place of id
+func HashSecretID(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
+
+// clientHashKey keys the hashes that stand for client IPs. A plain hash
+// of an IP is reversed by hashing every address, so they are HMACs under a
+// server-side key: random per process until SetClientHashKey installs
+// CLIENT_HASH_KEY.
+var clientHashKey atomic.Pointer[crypto.Keyring]
+
+func init() {
+	// Reading the system random source cannot fail
+	key, _ := crypto.EphemeralKeyring()
+	clientHashKey.Store(key)
+}
+
+// SetClientHashKey keys HashCreatorIP and HashClientKey with the signing
+// key of k. Hashes
+// made under another key no longer match, so every replica must share it.
+func SetClientHashKey(k *crypto.Keyring) {
+	clientHashKey.Store(k)
+}
+
+// hashClientIP returns the hex HMAC of a client IP under prefix
+func hashClientIP(prefix, ip string) string {
+	return hex.EncodeToString(clientHashKey.Load().Sign([]byte(prefix + ip)))
+}
+
+// HashCreatorIP returns the Secret.Creator that stands for a client IP. The
+// prefix keeps IP creators apart from any other kind of creator.
+func HashCreatorIP(ip string) string {
+	return hashClientIP("ip:", ip)
+}
+
+// HashClientKey returns the ClientScore.Key that stands for a client IP
+func HashClientKey(ip string) string {
+	return hashClientIP("limit:", ip)
+}
+
+// HashNotifyEmail returns the Secret.NotifyEmailHash of an address
+func HashNotifyEmail(address string) string {
+	sum := sha256.Sum256([]byte("email:" + strings.ToLower(address)))
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditEvent is one entry of the audit log. It never holds a raw secret ID.
+type AuditEvent struct {
+	// ID is a ULID, so the primary key orders events by time
+	ID           string
+	Type         string
+	OccurredAt   time.Time
+	Namespace    string
+	SecretIDHash string
+	NetworkClass string
+	// Reason is the reason code of a secret.reported event
+	Reason string
+	// Actor is the admin credential label behind an operator event
+	Actor string
+}
+
+// DroppedWork counts async work of one kind the server gave up on for one
+// reason. Kinds and reasons are the dropped package's constants.
+type DroppedWork struct {
+	Kind   string
+	Reason string
+	Count  int64
+	// RecordedAt is when the loss was written; in a summary, the latest write
+	RecordedAt time.Time
+}
+
+// ClientScore is a client's rate limit reputation as of UpdatedAt. Scores
+// run from 0 for an abusive client to 1 for a well-behaved one.
+type ClientScore struct {
+	// Key is HashClientKey of the client's IP
+	Key       string
+	Score     float64
+	UpdatedAt time.Time
+}
+
+// SizeBucket counts the secrets created in one power-of-two size class.
+// Exact sizes are never aggregated, only the class each create fell in.
+type SizeBucket struct {
+	// UpperBound is the largest size in bytes the bucket holds; the bucket
+	// starts just above half of it
+	UpperBound int64
+	Count      int64
+}
+
+// DailyStats counts one UTC day's secret activity. Only totals are kept,
+// never which secrets they cover.
+type DailyStats struct {
+	// Day is the UTC midnight that starts the day
+	Day       time.Time
+	Created   int64
+	Retrieved int64
+	Burned    int64
+	Expired   int64
+	// Bytes is the ciphertext the day's creates stored
+	Bytes int64
+	// TTLSeconds sums the TTLs the day's creates chose; zero on days
+	// before it was recorded
+	TTLSeconds int64
+}
+
+// StatsDay returns the UTC midnight starting t's day
+func StatsDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// AuditFilter selects audit events in ID order. Zero fields match everything.
+type AuditFilter struct {
+	Types     []string
+	Namespace string
+	// SecretIDHashPrefix matches the start of the hex secret ID hash
+	SecretIDHashPrefix string
+	// FromID and BeforeID bound the ID range, inclusive and exclusive; time
+	// ranges are expressed as ULID bounds so scans use the primary key
+	FromID   string
+	BeforeID string
+	// AfterID is the keyset cursor: the last ID already returned
+	AfterID string
+	// Limit caps the rows scanned; 0 scans all
+	Limit int
+}
+
+// Where renders the filter as a SQL condition with placeholders from
+// placeholder(n), numbered from 1
+func (f AuditFilter) Where(placeholder func(n int) string) (string, []any) {
+	var conds []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, strings.ReplaceAll(cond, "?", placeholder(len(args))))
+	}
+
+	if len(f.Types) > 0 {
+		marks := make([]string, len(f.Types))
+		for i, eventType := range f.Types {
+			args = append(args, eventType)
+			marks[i] = placeholder(len(args))
+		}
+		conds = append(conds, "event_type IN ("+strings.Join(marks, ", ")+")")
+	}
+	if f.Namespace != "" {
+		add("namespace = ?", f.Namespace)
+	}
+	if f.SecretIDHashPrefix != "" {
+		add("secret_id_hash LIKE ?", f.SecretIDHashPrefix+"%")
+	}
+	if f.FromID != "" {
+		add("id >= ?", f.FromID)
+	}
+	if f.BeforeID != "" {
+		add("id < ?", f.BeforeID)
+	}
+	if f.AfterID != "" {
+		add("id > ?", f.AfterID)
+	}
+
+	if len(conds) == 0 {
+		return "TRUE", nil
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// StoredDataKey is one secret's data key as stored
+type StoredDataKey struct {
+	SecretID string
+	// Key is the data key, wrapped under Version unless Version is empty
+	Key     sensitive.Bytes
+	Version string
+}
+
+// KeyVersionCount is how many stored data keys an envelope key version
+// wraps; the empty version counts data keys stored as they are
+type KeyVersionCount struct {
+	Version  string
+	DataKeys int64
+}
+
+// Store persists secrets. Implementations must make Consume atomic: of any
+// number of concurrent consumers of one secret, exactly one succeeds.
+type Store interface {
+	// Create stores a new secret, or reports ErrDuplicateID, or
+	// ErrNamespaceDeleted once its namespace's deletion has started, or
+	// ErrQuotaExceeded. The quota check and the insert are atomic: creates
+	// racing for a creator's last slot store one secret.
+	Create(ctx context.Context, secret *Secret) error
+	// Consume reads and destroys a secret in one transaction. Wrapped
+	// secrets have their key shredded; the ciphertext row is collected later.
+	// A held require_ack secret is never delivered again; a secret held in
+	// its grace window only to a retry with its retrieval token, and not
+	// past its expiry. Before its AvailableAfter a secret is left in place
+	// and a *NotYetAvailableError returned.
+	Consume(ctx context.Context, id string, opts ConsumeOptions) (*Secret, error)
+	// Peek previews a secret Consume would deliver at now without
+	// consuming it. It reports a secret not yet available as Consume does.
+	Peek(ctx context.Context, id string, now time.Time) (*Preview, error)
+	// Acknowledge destroys a held require_ack secret whose ack token hashes
+	// to tokenHash and marks its receipt acknowledged. Unknown secrets,
+	// wrong tokens, lapsed windows and grace holds all report ErrNotFound.
+	// A non-zero revision must be the held secret's, or a
+	// *RevisionMismatchError leaves it in place.
+	Acknowledge(ctx context.Context, id string, tokenHash []byte, now time.Time, revision int64) error
+	// Burn destroys a secret without reading it and reports whether it
+	// existed. It leaves no tombstone; burns a user asks for go through
+	// Terminate.
+	Burn(ctx context.Context, id string) (bool, error)
+	// Terminate ends a secret without reading it, for TerminationBurned or
+	// TerminationExpired, and returns its ID, namespace, notify address,
+	// creation and expiry. A burn ends any live secret that has not expired
+	// by now; an expiry only one that expired before now and is not held for
+	// an ack. A non-zero revision must be the secret's, or a
+	// *RevisionMismatchError leaves it in place. A tombstone is
+	// stored as the secret's receipt in the same transaction, unless a
+	// receipt exists already, which then only takes the new revision.
+	// Nothing to end reports ErrNotFound.
+	Terminate(ctx context.Context, id string, reason TerminationReason, now time.Time, revision int64, tombstone *Receipt) (*Secret, error)
+	// ManagementTokenHash returns the stored token hash, nil if the secret has none
+	ManagementTokenHash(ctx context.Context, id string) ([]byte, error)
+	// Revision returns the revision of a stored secret that has not been
+	// destroyed, held or not, expired or not; ErrNotFound for any other
+	Revision(ctx context.Context, id string) (int64, error)
+	// Receipt returns a secret's read receipt, ErrNotFound if none was recorded
+	Receipt(ctx context.Context, id string) (*Receipt, error)
+	// DeclaredKeyBits counts live secrets by declared key length; like
+	// CountActive it skips canary secrets
+	DeclaredKeyBits(ctx context.Context, now time.Time) ([]KeyBitsCount, error)
+	// CountActive counts stored secrets that have not been shredded
+	CountActive(ctx context.Context) (int64, error)
+	// CreatorStats counts live secrets per creator against quota, live
+	// meaning unexpired at now and not shredded, as the quota counts them
+	CreatorStats(ctx context.Context, now time.Time, quota int) (*CreatorStats, error)
+	// NamespaceStats summarizes the live secrets in namespace
+	NamespaceStats(ctx context.Context, namespace string, now time.Time) (*NamespaceStats, error)
+	// ExpiryForecast counts live secrets expiring after from and up to to,
+	// with their stored bytes, per UTC hour of expiry, earliest first.
+	// Hours without expiries are left out. An empty namespace covers every
+	// secret.
+	ExpiryForecast(ctx context.Context, namespace string, from, to time.Time) ([]ExpiryBucket, error)
+	// PurgeNamespace destroys every stored secret in namespace, shredding
+	// data keys, and returns how many were still live. Secrets already
+	// consumed, burned or shredded are removed but not counted, so a purge
+	// never claims a secret another path destroyed.
+	PurgeNamespace(ctx context.Context, namespace string) (int64, error)
+
+	// StartNamespaceDeletion marks namespace deleted, refusing creates into
+	// it from then on, and returns its progress. Starting a deletion that
+	// already started returns its progress unchanged.
+	StartNamespaceDeletion(ctx context.Context, namespace string, now time.Time) (*NamespaceDeletion, error)
+	// NamespaceDeletion returns a deletion's progress, ErrNotFound if none
+	// was started
+	NamespaceDeletion(ctx context.Context, namespace string) (*NamespaceDeletion, error)
+	// NamespaceDeletions returns every started deletion, finished or not,
+	// ordered by namespace
+	NamespaceDeletions(ctx context.Context) ([]NamespaceDeletion, error)
+	// DeleteNamespaceBatch removes up to limit of the lowest-ID rows still
+	// referencing a started deletion's namespace, secrets first and then
+	// audit events, which are kept with their namespace cleared. It adds
+	// what it removed to the progress in the same transaction and marks the
+	// deletion finished once nothing references the namespace. On a
+	// finished deletion it sweeps stragglers the same way. It reports
+	// ErrNotFound for a namespace whose deletion was never started.
+	DeleteNamespaceBatch(ctx context.Context, namespace string, limit int, now time.Time) (*NamespaceDeletion, error)
+	// NamespaceReferences counts stored secrets and audit events that still
+	// name namespace
+	NamespaceReferences(ctx context.Context, namespace string) (int64, error)
+
+	// DeleteExpired removes secrets that expired before now and returns how
+	// many were still live, like PurgeNamespace. A secret held for an ack or
+	// grace window that is still open is left to it.
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+	// ExpiredIDs returns the IDs of up to limit live secrets that expired
+	// before now and are not held for an ack, lowest first
+	ExpiredIDs(ctx context.Context, now time.Time, limit int) ([]string, error)
+	// CollectShredded removes wrapped ciphertext whose key has been shredded
+	CollectShredded(ctx context.Context) (int64, error)
+
+	// ActiveSecrets returns up to limit secrets that Consume would deliver
+	// at now, release time aside, in ID order after the ID afterID, with
+	// their parts and data keys as stored. Canary secrets are left out.
+	ActiveSecrets(ctx context.Context, now time.Time, afterID string, limit int) ([]Secret, error)
+	// StaleDataKeys returns up to limit stored data keys not wrapped under
+	// version, in secret ID order after the ID afterID
+	StaleDataKeys(ctx context.Context, version, afterID string, limit int) ([]StoredDataKey, error)
+	// ReplaceDataKey stores key under version in place of old, and reports
+	// whether it did. It does nothing when the data key no longer matches
+	// old or a consume, burn or shred holds the secret, so those always win.
+	ReplaceDataKey(ctx context.Context, old StoredDataKey, key []byte, version string) (bool, error)
+	// DataKeyVersions counts the stored data keys per envelope key version,
+	// ordered by version
+	DataKeyVersions(ctx context.Context) ([]KeyVersionCount, error)
+	// PruneReceipts removes read receipts consumed before cutoff
+	PruneReceipts(ctx context.Context, cutoff time.Time) (int64, error)
+	// BurnUnacknowledged destroys held secrets whose ack or grace window
+	// ended before now
+	BurnUnacknowledged(ctx context.Context, now time.Time) (int64, error)
+
+	// ClampExpiry lowers expires_at to ceiling for at most limit live
+	// secrets that expire after it, adding one to their revisions, and
+	// returns their IDs; expiries are never raised
+	ClampExpiry(ctx context.Context, ceiling time.Time, limit int) ([]string, error)
+
+	// Stragglers checks the sample most recent read receipts from since
+	// onward and returns the IDs of those secrets that can still be read:
+	// the row survived its consume, or its ack hold lapsed before now
+	Stragglers(ctx context.Context, since, now time.Time, sample int) ([]string, error)
+	// ReceiptIDs returns the IDs of secrets consumed between from and to,
+	// inclusive
+	ReceiptIDs(ctx context.Context, from, to time.Time) ([]string, error)
+
+	// RecordAudit appends an event to the audit log
+	RecordAudit(ctx context.Context, event *AuditEvent) error
+	// ScanAudit calls fn for each event matching filter in ID order, as rows
+	// are read; an error from fn stops the scan and is returned
+	ScanAudit(ctx context.Context, filter AuditFilter, fn func(*AuditEvent) error) error
+
+	// RecordDroppedWork appends loss records for dropped async work
+	RecordDroppedWork(ctx context.Context, losses []DroppedWork) error
+	// DroppedWorkSince sums losses recorded from since onward per kind and
+	// reason, ordered by kind then reason
+	DroppedWorkSince(ctx context.Context, since time.Time) ([]DroppedWork, error)
+	// PruneDroppedWork removes loss records written before cutoff
+	PruneDroppedWork(ctx context.Context, cutoff time.Time) (int64, error)
+
+	// RecordSizeBucket counts one create in the bucket ending at upperBound
+	RecordSizeBucket(ctx context.Context, upperBound int64) error
+	// SizeBuckets returns every bucket with creates, smallest first
+	SizeBuckets(ctx context.Context) ([]SizeBucket, error)
+
+	// AddDailyStats adds delta's counts to the row for delta.Day
+	AddDailyStats(ctx context.Context, delta DailyStats) error
+	// DailyStats returns the days from from through to with any activity,
+	// oldest first
+	DailyStats(ctx context.Context, from, to time.Time) ([]DailyStats, error)
+
+	// SaveClientScores stores rate limit reputations, each replacing the
+	// client's stored score unless that one was updated later
+	SaveClientScores(ctx context.Context, scores []ClientScore) error
+	// ClientScores returns the stored reputations of keys, leaving out keys
+	// without one
+	ClientScores(ctx context.Context, keys []string) ([]ClientScore, error)
+	// LowestClientScores returns up to limit reputations, lowest score first
+	LowestClientScores(ctx context.Context, limit int) ([]ClientScore, error)
+	// PruneClientScores removes reputations last updated before cutoff
+	PruneClientScores(ctx context.Context, cutoff time.Time) (int64, error)
+
+	// Ping checks the backend is reachable
+	Ping(ctx context.Context) error
+	// Close releases the backend's resources
+	Close()
+}
+
+// Warmer is implemented by stores that can open connections ahead of traffic.
+// The server calls it during warm-up, before readiness reports 200.
+type Warmer interface {
+	Warm(ctx context.Context) error
+}
+
+// PoolReporter is implemented by stores that hold a connection pool. The
+// metrics endpoint reports its statistics, so an exhausted pool can be
+// told from a failing database.
+type PoolReporter interface {
+	PoolStats() PoolStats
+}
+
+// PoolStats is a snapshot of a store's connection pool. A backend fills in
+// what it tracks and leaves the rest zero. Counts and durations are totals
+// since the pool opened.
+type PoolStats struct {
+	AcquiredConns        int64
+	IdleConns            int64
+	TotalConns           int64
+	MaxConns             int64
+	AcquireCount         int64
+	AcquireDuration      time.Duration
+	CanceledAcquireCount int64
+	EmptyAcquireCount    int64
+	NewConnsCount        int64
+}
+
+// SchemaInspector is implemented by stores whose schema is migrated apart
+// from the binary and can lag behind it. Startup checks that the tables and
+// columns each enabled feature needs are there.
+type SchemaInspector interface {
+	// HasColumn reports whether table exists and, when column is not
+	// empty, has that column
+	HasColumn(ctx context.Context, table, column string) (bool, error)
+}
+
+// Classifier is implemented by stores that can tell a failure a retry may
+// get past, such as a lock held by another writer or a lost connection,
+// from one that will recur, such as a constraint violation or a missing
+// column
+type Classifier interface {
+	Transient(err error) bool
+}
+
+// Transient reports whether err, returned by s, is worth retrying: a
+// deadline passed, which is also how an exhausted pool shows, or s
+// classifies it as transient
+func Transient(s Store, err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	classifier, ok := s.(Classifier)
+	return ok && classifier.Transient(err)
+}
+
+// NamespaceDeletionBatch is how many rows one DeleteNamespaceBatch of a
+// deletion job removes, few enough that no batch holds its locks for long
+const NamespaceDeletionBatch = 500
+
+// DeleteNamespace runs namespace's started deletion in batches of batchSize
+// until nothing references the namespace, and returns the final progress.
+// It stops early with ctx's error; since every batch commits with its
+// counts, running it again, or alongside another run, picks up where it
+// stopped.
+func DeleteNamespace(ctx context.Context, s Store, namespace string, batchSize int, now func() time.Time) (*NamespaceDeletion, error) {
+	last, err := s.NamespaceDeletion(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		deletion, err := s.DeleteNamespaceBatch(ctx, namespace, batchSize, now())
+		if err != nil {
+			return nil, err
+		}
+
+		removed := deletion.SecretsDeleted + deletion.AuditEventsAnonymized - last.SecretsDeleted - last.AuditEventsAnonymized
+		if deletion.Finished() && removed < int64(batchSize) {
+			return deletion, nil
+		}
+		last = deletion
+	}
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"ots-backend/internal/crypto"
+)
+
+// TestClientIPHashesAreKeyed checks creators and reputation keys are HMACs
+// under the client hash key, which a table of every address's plain hash
+// does not reverse
+func TestClientIPHashesAreKeyed(t *testing.T) {
+	previous := clientHashKey.Load()
+	t.Cleanup(func() { SetClientHashKey(previous) })
+
+	const ip = "203.0.113.5"
+
+	first, err := crypto.NewKeyring(bytes.Repeat([]byte{1}, crypto.MinKeyringKeySize))
+	if err != nil {
+		t.Fatalf("NewKeyring() error: %v", err)
+	}
+	second, err := crypto.NewKeyring(bytes.Repeat([]byte{2}, crypto.MinKeyringKeySize))
+	if err != nil {
+		t.Fatalf("NewKeyring() error: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		prefix string
+		hash   func(string) string
+	}{
+		{"HashCreatorIP", "ip:", HashCreatorIP},
+		{"HashClientKey", "limit:", HashClientKey},
+	} {
+		plain := sha256.Sum256([]byte(tt.prefix + ip))
+
+		SetClientHashKey(first)
+		hash := tt.hash(ip)
+		if hash == hex.EncodeToString(plain[:]) {
+			t.Errorf("%s() is the plain SHA-256 of the IP", tt.name)
+		}
+		if again := tt.hash(ip); again != hash {
+			t.Errorf("%s() under one key = %s then %s, want it stable", tt.name, hash, again)
+		}
+
+		SetClientHashKey(second)
+		if other := tt.hash(ip); other == hash {
+			t.Errorf("%s() is the same under another key", tt.name)
+		}
+	}
+	if HashCreatorIP(ip) == HashClientKey(ip) {
+		t.Error("HashCreatorIP() and HashClientKey() agree; their prefixes should keep them apart")
+	}
+}
@@ -0,0 +1,2227 @@
+// Package storetest is the conformance suite every store.Store backend must
+// pass. Backends call Run from their own tests with a constructor that
+// returns an empty store.
+package storetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ots-backend/internal/crypto"
+	"ots-backend/internal/store"
+	"ots-backend/internal/ulid"
+)
+
+// Run exercises a backend. open must return an empty store for each call.
+func Run(t *testing.T, open func(t *testing.T) store.Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s store.Store)
+	}{
+		{"CreateAndConsume", testCreateAndConsume},
+		{"ConsumeIsOneTime", testConsumeIsOneTime},
+		{"ConsumeExpired", testConsumeExpired},
+		{"Peek", testPeek},
+		{"AvailableAfter", testAvailableAfter},
+		{"OpenErrorKeepsSecret", testOpenErrorKeepsSecret},
+		{"ShreddedSecret", testShreddedSecret},
+		{"Burn", testBurn},
+		{"Parts", testParts},
+		{"ManagementTokenHash", testManagementTokenHash},
+		{"DuplicateID", testDuplicateID},
+		{"DeclaredKeyBits", testDeclaredKeyBits},
+		{"Namespaces", testNamespaces},
+		{"ExpiryForecast", testExpiryForecast},
+		{"NamespaceDeletion", testNamespaceDeletion},
+		{"CreatorQuota", testCreatorQuota},
+		{"Cleanup", testCleanup},
+		{"DeleteExpiredKeepsHolds", testDeleteExpiredKeepsHolds},
+		{"AckHold", testAckHold},
+		{"AckWindowLapses", testAckWindowLapses},
+		{"BurnUnacknowledged", testBurnUnacknowledged},
+		{"GraceHold", testGraceHold},
+		{"ConcurrentConsume", testConcurrentConsume},
+		{"BurnRaces", testBurnRaces},
+		{"ClampExpiry", testClampExpiry},
+		{"Stragglers", testStragglers},
+		{"AuditScan", testAuditScan},
+		{"DroppedWork", testDroppedWork},
+		{"SizeBuckets", testSizeBuckets},
+		{"DailyStats", testDailyStats},
+		{"ClientScores", testClientScores},
+		{"ExpiredIDs", testExpiredIDs},
+		{"Terminate", testTerminate},
+		{"Revisions", testRevisions},
+		{"DataKeyRewrap", testDataKeyRewrap},
+		{"ActiveSecrets", testActiveSecrets},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, open(t))
+		})
+	}
+}
+
+func testCreateAndConsume(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	secret := newSecret(t, time.Hour)
+	secret.Salt = []byte("salt-salt-salt-!")
+	create(t, s, secret)
+
+	got, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{Now: time.Now()})
+	if err != nil {
+		t.Fatalf("Consume() error: %v", err)
+	}
+
+	if !bytes.Equal(got.Ciphertext, secret.Ciphertext) || !bytes.Equal(got.IV, secret.IV) || !bytes.Equal(got.Salt, secret.Salt) {
+		t.Errorf("Consume() = %+v, want payload of %+v", got, secret)
+	}
+	if !got.ExpiresAt.Equal(secret.ExpiresAt.Truncate(time.Microsecond)) && !got.ExpiresAt.Equal(secret.ExpiresAt) {
+		t.Errorf("Consume() expires_at = %v, want %v", got.ExpiresAt, secret.ExpiresAt)
+	}
+	if got.DataKey != nil || len(got.Parts) != 0 || got.IVEmbedded {
+		t.Errorf("Consume() unexpected data key, parts or embedded IV: %+v", got)
+	}
+
+	// A nonce-prefixed blob keeps its flag and an empty IV
+	embedded := newSecret(t, time.Hour)
+	embedded.IV = []byte{}
+	embedded.IVEmbedded = true
+	create(t, s, embedded)
+
+	got, err = s.Consume(ctx, embedded.ID, store.ConsumeOptions{Now: time.Now()})
+	if err != nil {
+		t.Fatalf("Consume() embedded error: %v", err)
+	}
+	if !got.IVEmbedded || len(got.IV) != 0 || !bytes.Equal(got.Ciphertext, embedded.Ciphertext) {
+		t.Errorf("Consume() embedded = %+v, want the blob flagged iv_embedded", got)
+	}
+}
+
+func testConsumeIsOneTime(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	secret := newSecret(t, time.Hour)
+	create(t, s, secret)
+
+	if _, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{Now: time.Now()}); err != nil {
+		t.Fatalf("first Consume() error: %v", err)
+	}
+
+	if _, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{Now: time.Now()}); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("second Consume() error = %v, want ErrNotFound", err)
+	}
+
+	if _, err := s.Consume(ctx, "does-not-exist", store.ConsumeOptions{Now: time.Now()}); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Consume() of unknown id error = %v, want ErrNotFound", err)
+	}
+}
+
+func testConsumeExpired(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	secret := newSecret(t, time.Minute)
+	create(t, s, secret)
+
+	later := time.Now().Add(2 * time.Minute)
+	_, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{Now: later})
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Consume() after expiry error = %v, want ErrNotFound", err)
+	}
+
+	// The read leaves the expired row for the cleanup worker
+	if n, err := s.DeleteExpired(ctx, later); err != nil || n != 1 {
+		t.Fatalf("DeleteExpired() after expired read = %d, %v; want 1, nil", n, err)
+	}
+	if _, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{Now: time.Now()}); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Consume() of cleaned up secret error = %v, want ErrNotFound", err)
+	}
+}
+
+func testOpenErrorKeepsSecret(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	secret := newSecret(t, time.Hour)
+	create(t, s, secret)
+
+	openErr := errors.New("unwrap failed")
+	_, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{
+		Now:  time.Now(),
+		Open: func(*store.Secret) error { return openErr },
+	})
+	if !errors.Is(err, openErr) {
+		t.Fatalf("Consume() error = %v, want %v", err, openErr)
+	}
+
+	if _, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{Now: time.Now()}); err != nil {
+		t.Fatalf("Consume() after aborted open error: %v", err)
+	}
+}
+
+func testShreddedSecret(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	secret := newSecret(t, time.Hour)
+	secret.DataKey = bytes.Repeat([]byte{0x42}, 32)
+	create(t, s, secret)
+
+	got, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{Now: time.Now()})
+	if err != nil {
+		t.Fatalf("Consume() error: %v", err)
+	}
+	if !bytes.Equal(got.DataKey, secret.DataKey) {
+		t.Fatalf("Consume() data key = %x, want %x", got.DataKey, secret.DataKey)
+	}
+
+	if _, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{Now: time.Now()}); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Consume() after shred error = %v, want ErrNotFound", err)
+	}
+
+	if burned, err := s.Burn(ctx, secret.ID); err != nil || burned {
+		t.Fatalf("Burn() after shred = %v, %v; want false, nil", burned, err)
+	}
+
+	if n, err := s.CollectShredded(ctx); err != nil || n != 1 {
+		t.Fatalf("CollectShredded() = %d, %v; want 1, nil", n, err)
+	}
+}
+
+func testBurn(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	for _, wrapped := range []bool{false, true} {
+		secret := newSecret(t, time.Hour)
+		if wrapped {
+			secret.DataKey = bytes.Repeat([]byte{0x07}, 32)
+		}
+		create(t, s, secret)
+
+		if burned, err := s.Burn(ctx, secret.ID); err != nil || !burned {
+			t.Fatalf("wrapped=%v: Burn() = %v, %v; want true, nil", wrapped, burned, err)
+		}
+		if burned, err := s.Burn(ctx, secret.ID); err != nil || burned {
+			t.Fatalf("wrapped=%v: second Burn() = %v, %v; want false, nil", wrapped, burned, err)
+		}
+		if _, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{Now: time.Now()}); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("wrapped=%v: Consume() after burn error = %v, want ErrNotFound", wrapped, err)
+		}
+	}
+}
+
+func testParts(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	secret := newSecret(t, time.Hour)
+	secret.Ciphertext = []byte{}
+	secret.IV = []byte{}
+	secret.Parts = []store.Part{
+		{Label: "username", Ciphertext: []byte("alice"), IV: bytes.Repeat([]byte{1}, 12)},
+		{Label: "password", Ciphertext: []byte("hunter2"), IV: bytes.Repeat([]byte{2}, 12)},
+		{Label: "totp", Ciphertext: []byte("JBSWY3DP"), IV: bytes.Repeat([]byte{3}, 12)},
+	}
+	create(t, s, secret)
+
+	got, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{Now: time.Now()})
+	if err != nil {
+		t.Fatalf("Consume() error: %v", err)
+	}
+
+	if len(got.Parts) != len(secret.Parts) {
+		t.Fatalf("Consume() parts = %d, want %d", len(got.Parts), len(secret.Parts))
+	}
+	for i, part := range secret.Parts {
+		if got.Parts[i].Label != part.Label || !bytes.Equal(got.Parts[i].Ciphertext, part.Ciphertext) || !bytes.Equal(got.Parts[i].IV, part.IV) {
+			t.Errorf("part %d = %+v, want %+v", i, got.Parts[i], part)
+		}
+	}
+}
+
+func testPeek(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	now := time.Now()
+
+	secret := newSecret(t, time.Hour)
+	secret.Hint = "VPN password for ACME &amp; staging"
+	secret.ContentType, secret.Filename = "application/json", "vpn.json"
+	create(t, s, secret)
+	parts := newSecret(t, time.Hour)
+	parts.Ciphertext, parts.IV = []byte{}, []byte{}
+	parts.Parts = []store.Part{
+		{Label: "username", Ciphertext: []byte("alice"), IV: bytes.Repeat([]byte{1}, 12)},
+		{Label: "password", Ciphertext: []byte("hunter2"), IV: bytes.Repeat([]byte{2}, 12)},
+	}
+	create(t, s, parts)
+
+	for _, tt := range []struct {
+		secret *store.Secret
+		want   store.Preview
+	}{
+		{secret, store.Preview{Size: int64(len(secret.Ciphertext)), Hint: secret.Hint, ContentType: secret.ContentType, Filename: secret.Filename}},
+		{parts, store.Preview{Size: 12}},
+	} {
+		// Peeking twice leaves the secret readable
+		for range 2 {
+			if preview, err := s.Peek(ctx, tt.secret.ID, now); err != nil || *preview != tt.want {
+				t.Fatalf("Peek() = %+v, %v; want %+v, nil", preview, err, tt.want)
+			}
+		}
+		got, err := s.Consume(ctx, tt.secret.ID, store.ConsumeOptions{Now: now})
+		if err != nil {
+			t.Fatalf("Consume() after Peek() error: %v", err)
+		}
+		if got.Hint != tt.want.Hint || got.ContentType != tt.want.ContentType || got.Filename != tt.want.Filename {
+			t.Errorf("Consume() hint, content type and file name = %q, %q, %q; want %q, %q, %q",
+				got.Hint, got.ContentType, got.Filename, tt.want.Hint, tt.want.ContentType, tt.want.Filename)
+		}
+		if _, err := s.Peek(ctx, tt.secret.ID, now); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Peek() after Consume() error = %v, want ErrNotFound", err)
+		}
+	}
+
+	if _, err := s.Peek(ctx, "missing", now); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Peek() of unknown ID error = %v, want ErrNotFound", err)
+	}
+
+	expired := newSecret(t, time.Minute)
+	create(t, s, expired)
+	if _, err := s.Peek(ctx, expired.ID, now.Add(time.Hour)); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Peek() of expired secret error = %v, want ErrNotFound", err)
+	}
+
+	// Wrapped secrets are shredded by their read; held ones were delivered
+	wrapped := newSecret(t, time.Hour)
+	wrapped.DataKey = bytes.Repeat([]byte{0x0B}, 32)
+	held := newSecret(t, time.Hour)
+	held.RequireAck = true
+	for _, secret := range []*store.Secret{wrapped, held} {
+		create(t, s, secret)
+		_, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{
+			Now: now,
+			Ack: &store.AckHold{TokenHash: bytes.Repeat([]byte{0x5A}, 32), Deadline: now.Add(time.Minute)},
+		})
+		if err != nil {
+			t.Fatalf("Consume() error: %v", err)
+		}
+		if _, err := s.Peek(ctx, secret.ID, now); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Peek() of a read secret with a data key or ack hold error = %v, want ErrNotFound", err)
+		}
+	}
+}
+
+func testAvailableAfter(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	now := time.Now()
+	release := now.Add(time.Hour).Truncate(time.Microsecond)
+
+	secret := newSecret(t, 2*time.Hour)
+	secret.AvailableAfter = &release
+	create(t, s, secret)
+
+	// Early reads are refused with the release time and leave it in place
+	for range 2 {
+		var notYet *store.NotYetAvailableError
+		if _, err := s.Peek(ctx, secret.ID, now); !errors.As(err, &notYet) || !notYet.AvailableAfter.Equal(release) {
+			t.Fatalf("Peek() before release error = %v, want NotYetAvailableError until %v", err, release)
+		}
+		_, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{Now: release.Add(-time.Nanosecond), Receipt: &store.Receipt{ConsumedAt: now}})
+		if !errors.As(err, &notYet) || !errors.Is(err, store.ErrNotYetAvailable) || !notYet.AvailableAfter.Equal(release) {
+			t.Fatalf("Consume() before release error = %v, want NotYetAvailableError until %v", err, release)
+		}
+	}
+	if _, err := s.Receipt(ctx, secret.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Receipt() after an early read error = %v, want ErrNotFound", err)
+	}
+
+	if preview, err := s.Peek(ctx, secret.ID, release); err != nil || preview.Size != int64(len(secret.Ciphertext)) {
+		t.Errorf("Peek() at release = %+v, %v; want %d bytes, nil", preview, err, len(secret.Ciphertext))
+	}
+	got, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{Now: release})
+	if err != nil {
+		t.Fatalf("Consume() at release error: %v", err)
+	}
+	if got.AvailableAfter == nil || !got.AvailableAfter.Equal(release) {
+		t.Errorf("Consume() AvailableAfter = %v, want %v", got.AvailableAfter, release)
+	}
+	if _, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{Now: release}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("second Consume() error = %v, want ErrNotFound", err)
+	}
+
+	// An expired scheduled secret is simply gone
+	late := newSecret(t, time.Minute)
+	late.AvailableAfter = &release
+	create(t, s, late)
+	if _, err := s.Consume(ctx, late.ID, store.ConsumeOptions{Now: now.Add(2 * time.Minute)}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Consume() of an expired scheduled secret error = %v, want ErrNotFound", err)
+	}
+}
+
+func testManagementTokenHash(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	_, hash, err := crypto.GenerateManagementToken()
+	if err != nil {
+		t.Fatalf("GenerateManagementToken() error: %v", err)
+	}
+
+	secret := newSecret(t, time.Hour)
+	secret.ManagementTokenHash = hash
+	create(t, s, secret)
+
+	got, err := s.ManagementTokenHash(ctx, secret.ID)
+	if err != nil || !bytes.Equal(got, hash) {
+		t.Fatalf("ManagementTokenHash() = %x, %v; want %x", got, err, hash)
+	}
+
+	legacy := newSecret(t, time.Hour)
+	create(t, s, legacy)
+	if got, err := s.ManagementTokenHash(ctx, legacy.ID); err != nil || got != nil {
+		t.Fatalf("ManagementTokenHash() without hash = %x, %v; want nil, nil", got, err)
+	}
+
+	if _, err := s.ManagementTokenHash(ctx, "does-not-exist"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("ManagementTokenHash() of unknown id error = %v, want ErrNotFound", err)
+	}
+}
+
+func testDeclaredKeyBits(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	bits := 256
+
+	for range 2 {
+		declared := newSecret(t, time.Hour)
+		declared.DeclaredKeyBits = &bits
+		create(t, s, declared)
+	}
+	create(t, s, newSecret(t, time.Hour))
+
+	// Expired and shredded secrets do not count
+	create(t, s, newSecret(t, -time.Minute))
+	shredded := newSecret(t, time.Hour)
+	shredded.DataKey = bytes.Repeat([]byte{0x01}, 32)
+	create(t, s, shredded)
+	if _, err := s.Burn(ctx, shredded.ID); err != nil {
+		t.Fatalf("Burn() error: %v", err)
+	}
+
+	// Nor do the canary's
+	canary := newSecret(t, time.Hour)
+	canary.ID = store.CanaryIDPrefix + canary.ID
+	canary.DeclaredKeyBits = &bits
+	create(t, s, canary)
+
+	// The expired row is still stored until cleanup; the shredded one is not active
+	if n, err := s.CountActive(ctx); err != nil || n != 4 {
+		t.Fatalf("CountActive() = %d, %v; want 4, nil", n, err)
+	}
+
+	counts, err := s.DeclaredKeyBits(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("DeclaredKeyBits() error: %v", err)
+	}
+
+	got := make(map[int]int64)
+	for _, c := range counts {
+		key := 0
+		if c.Bits != nil {
+			key = *c.Bits
+		}
+		got[key] += c.Count
+	}
+
+	if len(got) != 2 || got[256] != 2 || got[0] != 1 {
+		t.Errorf("DeclaredKeyBits() = %v, want 256:2 undeclared:1", got)
+	}
+}
+
+func testNamespaces(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	now := time.Now()
+
+	soon := newSecret(t, time.Minute)
+	soon.Namespace = "team-a"
+	create(t, s, soon)
+	parts := newSecret(t, time.Hour)
+	parts.Namespace = "team-a"
+	parts.Ciphertext = []byte{}
+	parts.Parts = []store.Part{
+		{Label: "user", Ciphertext: []byte("12345"), IV: bytes.Repeat([]byte{0x01}, 12)},
+		{Label: "pass", Ciphertext: []byte("123"), IV: bytes.Repeat([]byte{0x02}, 12)},
+	}
+	create(t, s, parts)
+	wrapped := newSecret(t, time.Hour)
+	wrapped.Namespace = "team-a"
+	wrapped.DataKey = bytes.Repeat([]byte{0x01}, 32)
+	create(t, s, wrapped)
+
+	// Expired secrets and other tenants do not count
+	expired := newSecret(t, -time.Minute)
+	expired.Namespace = "team-a"
+	create(t, s, expired)
+	other := newSecret(t, time.Hour)
+	other.Namespace = "team-b"
+	create(t, s, other)
+	create(t, s, newSecret(t, time.Hour))
+
+	stats, err := s.NamespaceStats(ctx, "team-a", now)
+	if err != nil {
+		t.Fatalf("NamespaceStats() error: %v", err)
+	}
+	wantBytes := int64(len(soon.Ciphertext) + 8 + len(wrapped.Ciphertext))
+	oldest := stats.OldestExpiry.Equal(soon.ExpiresAt.Truncate(time.Microsecond)) || stats.OldestExpiry.Equal(soon.ExpiresAt)
+	if stats.Count != 3 || stats.TotalBytes != wantBytes || !oldest {
+		t.Errorf("NamespaceStats() = %+v, want 3 secrets, %d bytes, oldest expiry %v", stats, wantBytes, soon.ExpiresAt)
+	}
+
+	// Consuming a secret returns its namespace
+	got, err := s.Consume(ctx, soon.ID, store.ConsumeOptions{Now: now})
+	if err != nil || got.Namespace != "team-a" {
+		t.Fatalf("Consume() = %+v, %v; want namespace team-a", got, err)
+	}
+
+	if n, err := s.PurgeNamespace(ctx, "team-a"); err != nil || n != 3 {
+		t.Fatalf("PurgeNamespace() = %d, %v; want 3, nil", n, err)
+	}
+	for _, id := range []string{parts.ID, wrapped.ID} {
+		if _, err := s.Consume(ctx, id, store.ConsumeOptions{Now: now}); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Consume(%s) after purge error = %v, want ErrNotFound", id, err)
+		}
+	}
+	if stats, err := s.NamespaceStats(ctx, "team-a", now); err != nil || stats.Count != 0 || !stats.OldestExpiry.IsZero() {
+		t.Errorf("NamespaceStats() after purge = %+v, %v; want empty", stats, err)
+	}
+	if _, err := s.ManagementTokenHash(ctx, other.ID); err != nil {
+		t.Errorf("PurgeNamespace() removed another tenant's secret: %v", err)
+	}
+}
+
+func testExpiryForecast(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Hour).Add(30 * time.Minute)
+	expiring := func(at time.Time, namespace string, size int, parts ...store.Part) *store.Secret {
+		t.Helper()
+		secret := newSecret(t, time.Hour)
+		secret.ExpiresAt = at
+		secret.Namespace = namespace
+		secret.Ciphertext = bytes.Repeat([]byte{0x01}, size)
+		secret.Parts = parts
+		create(t, s, secret)
+		return secret
+	}
+
+	// Two in the current hour, one of them in parts; two in the next and
+	// one in the hour after, across namespaces
+	expiring(now.Add(10*time.Minute), "", 100)
+	expiring(now.Add(20*time.Minute), "team-a", 0,
+		store.Part{Label: "user", Ciphertext: []byte("12345"), IV: bytes.Repeat([]byte{0x01}, 12)},
+		store.Part{Label: "pass", Ciphertext: []byte("123"), IV: bytes.Repeat([]byte{0x02}, 12)})
+	expiring(now.Add(40*time.Minute), "team-a", 7)
+	expiring(now.Add(80*time.Minute), "team-b", 10)
+	expiring(now.Add(100*time.Minute), "team-a", 20)
+
+	// Secrets already expired, past the horizon, or read do not count
+	expiring(now.Add(-time.Minute), "", 1000)
+	expiring(now.Add(5*time.Hour), "", 1000)
+	wrapped := newSecret(t, time.Hour)
+	wrapped.DataKey = bytes.Repeat([]byte{0x01}, 32)
+	create(t, s, wrapped)
+	consumed := expiring(now.Add(10*time.Minute), "", 1000)
+	for _, id := range []string{wrapped.ID, consumed.ID} {
+		if _, err := s.Consume(ctx, id, store.ConsumeOptions{Now: now}); err != nil {
+			t.Fatalf("Consume() error: %v", err)
+		}
+	}
+	hour := now.Truncate(time.Hour)
+	got, err := s.ExpiryForecast(ctx, "", now, now.Add(4*time.Hour))
+	if err != nil {
+		t.Fatalf("ExpiryForecast() error: %v", err)
+	}
+	want := []store.ExpiryBucket{
+		{Hour: hour, Count: 2, Bytes: 100 + 8},
+		{Hour: hour.Add(time.Hour), Count: 2, Bytes: 7 + 10},
+		{Hour: hour.Add(2 * time.Hour), Count: 1, Bytes: 20},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("ExpiryForecast() = %+v, want %+v", got, want)
+	}
+
+	got, err = s.ExpiryForecast(ctx, "team-a", now, now.Add(4*time.Hour))
+	if err != nil {
+		t.Fatalf("ExpiryForecast(team-a) error: %v", err)
+	}
+	want = []store.ExpiryBucket{
+		{Hour: hour, Count: 1, Bytes: 8},
+		{Hour: hour.Add(time.Hour), Count: 1, Bytes: 7},
+		{Hour: hour.Add(2 * time.Hour), Count: 1, Bytes: 20},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("ExpiryForecast(team-a) = %+v, want %+v", got, want)
+	}
+
+	if got, err := s.ExpiryForecast(ctx, "team-c", now, now.Add(4*time.Hour)); err != nil || len(got) != 0 {
+		t.Errorf("ExpiryForecast(team-c) = %+v, %v; want none", got, err)
+	}
+}
+
+func testNamespaceDeletion(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	now := time.Now()
+	var gen ulid.Generator
+	audit := func(namespace string) {
+		t.Helper()
+		event := &store.AuditEvent{ID: gen.New(now), Type: store.AuditSecretCreated, OccurredAt: now, Namespace: namespace, SecretIDHash: "aa"}
+		if err := s.RecordAudit(ctx, event); err != nil {
+			t.Fatalf("RecordAudit() error: %v", err)
+		}
+	}
+
+	// A tenant of plain, part, wrapped and consumed secrets, some with
+	// receipts, and their audit events, beside another tenant's
+	const tenantSecrets, tenantEvents = 57, 31
+	var live, receipts int64
+	for i := range tenantSecrets {
+		secret := newSecret(t, time.Hour)
+		secret.Namespace = "doomed"
+		switch i % 4 {
+		case 1:
+			secret.Ciphertext = []byte{}
+			secret.Parts = []store.Part{{Label: "user", Ciphertext: []byte("123"), IV: bytes.Repeat([]byte{0x01}, 12)}}
+		case 2, 3:
+			secret.DataKey = bytes.Repeat([]byte{0x01}, 32)
+		}
+		create(t, s, secret)
+
+		if i%4 == 3 {
+			_, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{Now: now, Receipt: &store.Receipt{ConsumedAt: now, NetworkClass: "external"}})
+			if err != nil {
+				t.Fatalf("Consume() error: %v", err)
+			}
+			receipts++
+			continue
+		}
+		live++
+	}
+	for range tenantEvents {
+		audit("doomed")
+	}
+	kept := newSecret(t, time.Hour)
+	kept.Namespace = "kept"
+	create(t, s, kept)
+	audit("kept")
+
+	if _, err := s.DeleteNamespaceBatch(ctx, "doomed", 10, now); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("DeleteNamespaceBatch() before start error = %v, want ErrNotFound", err)
+	}
+
+	started, err := s.StartNamespaceDeletion(ctx, "doomed", now)
+	if err != nil || started.Finished() || started.SecretsDeleted != 0 {
+		t.Fatalf("StartNamespaceDeletion() = %+v, %v; want a fresh deletion", started, err)
+	}
+	again, err := s.StartNamespaceDeletion(ctx, "doomed", now.Add(time.Hour))
+	if err != nil || !again.StartedAt.Equal(started.StartedAt) {
+		t.Fatalf("second StartNamespaceDeletion() = %+v, %v; want the first unchanged", again, err)
+	}
+
+	// Creates into the namespace are refused from the start on
+	refused := newSecret(t, time.Hour)
+	refused.Namespace = "doomed"
+	if err := s.Create(ctx, refused); !errors.Is(err, store.ErrNamespaceDeleted) {
+		t.Fatalf("Create() into deleted namespace error = %v, want ErrNamespaceDeleted", err)
+	}
+	create(t, s, newSecret(t, time.Hour))
+
+	// A job killed after three batches leaves counted progress behind
+	for range 3 {
+		if _, err := s.DeleteNamespaceBatch(ctx, "doomed", 10, now); err != nil {
+			t.Fatalf("DeleteNamespaceBatch() error: %v", err)
+		}
+	}
+	partial, err := s.NamespaceDeletion(ctx, "doomed")
+	if err != nil || partial.Finished() || partial.SecretsDeleted != 30 || partial.AuditEventsAnonymized != 0 {
+		t.Fatalf("NamespaceDeletion() midway = %+v, %v; want 30 secrets deleted and unfinished", partial, err)
+	}
+
+	// Two resumed runs racing each other count every row once
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.DeleteNamespace(ctx, s, "doomed", 7, time.Now)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("DeleteNamespace() error: %v", err)
+		}
+	}
+
+	done, err := s.NamespaceDeletion(ctx, "doomed")
+	if err != nil {
+		t.Fatalf("NamespaceDeletion() error: %v", err)
+	}
+	want := store.NamespaceDeletion{
+		SecretsDeleted:        tenantSecrets,
+		LiveSecretsDeleted:    live,
+		ReceiptsDeleted:       receipts,
+		AuditEventsAnonymized: tenantEvents,
+	}
+	if !done.Finished() || done.SecretsDeleted != want.SecretsDeleted || done.LiveSecretsDeleted != want.LiveSecretsDeleted ||
+		done.ReceiptsDeleted != want.ReceiptsDeleted || done.AuditEventsAnonymized != want.AuditEventsAnonymized {
+		t.Fatalf("NamespaceDeletion() = %+v, want finished with %+v", done, want)
+	}
+	if n, err := s.NamespaceReferences(ctx, "doomed"); err != nil || n != 0 {
+		t.Errorf("NamespaceReferences() = %d, %v; want 0", n, err)
+	}
+	if n, err := s.NamespaceReferences(ctx, "kept"); err != nil || n != 2 {
+		t.Errorf("NamespaceReferences(kept) = %d, %v; want its secret and event", n, err)
+	}
+
+	// Running again finds nothing and counts nothing
+	rerun, err := store.DeleteNamespace(ctx, s, "doomed", 7, time.Now)
+	if err != nil || rerun.SecretsDeleted != done.SecretsDeleted || rerun.AuditEventsAnonymized != done.AuditEventsAnonymized {
+		t.Errorf("DeleteNamespace() rerun = %+v, %v; want %+v", rerun, err, done)
+	}
+
+	// A straggler is swept without reopening the deletion
+	audit("doomed")
+	swept, err := store.DeleteNamespace(ctx, s, "doomed", 7, time.Now)
+	if err != nil || swept.AuditEventsAnonymized != tenantEvents+1 || !swept.FinishedAt.Equal(done.FinishedAt) {
+		t.Errorf("DeleteNamespace() over a straggler = %+v, %v; want it counted and finished_at kept", swept, err)
+	}
+
+	deletions, err := s.NamespaceDeletions(ctx)
+	if err != nil || len(deletions) != 1 || deletions[0].Namespace != "doomed" {
+		t.Errorf("NamespaceDeletions() = %+v, %v; want the one deletion", deletions, err)
+	}
+}
+
+func testCreatorQuota(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	now := time.Now()
+	secretOf := func(creator string, ttl time.Duration) *store.Secret {
+		secret := newSecret(t, ttl)
+		secret.Creator = creator
+		secret.CreatorQuota = 3
+		return secret
+	}
+
+	// A short-lived secret, a wrapped one, and a plain one fill the quota
+	short := secretOf("alice", time.Minute)
+	wrapped := secretOf("alice", time.Hour)
+	wrapped.DataKey = bytes.Repeat([]byte{0x01}, 32)
+	plain := secretOf("alice", time.Hour)
+	for _, secret := range []*store.Secret{short, wrapped, plain} {
+		create(t, s, secret)
+	}
+	if err := s.Create(ctx, secretOf("alice", time.Hour)); !errors.Is(err, store.ErrQuotaExceeded) {
+		t.Fatalf("Create() over quota error = %v, want ErrQuotaExceeded", err)
+	}
+	create(t, s, secretOf("bob", time.Hour))
+	create(t, s, newSecret(t, time.Hour))
+
+	stats, err := s.CreatorStats(ctx, now, 3)
+	if err != nil || stats.Creators != 2 || stats.AtQuota != 1 || stats.MaxActive != 3 {
+		t.Fatalf("CreatorStats() = %+v, %v; want 2 creators, 1 at quota, max 3", stats, err)
+	}
+
+	// Reading the wrapped secret shreds it and frees its slot, though its
+	// row is only collected later
+	if _, err := s.Consume(ctx, wrapped.ID, store.ConsumeOptions{Now: now}); err != nil {
+		t.Fatalf("Consume() error: %v", err)
+	}
+	create(t, s, secretOf("alice", time.Hour))
+	if err := s.Create(ctx, secretOf("alice", time.Hour)); !errors.Is(err, store.ErrQuotaExceeded) {
+		t.Fatalf("Create() over quota after a read error = %v, want ErrQuotaExceeded", err)
+	}
+
+	// Once the short-lived secret expires its slot is free too
+	later := secretOf("alice", time.Hour)
+	later.CreatedAt = now.Add(2 * time.Minute)
+	later.ExpiresAt = later.CreatedAt.Add(time.Hour)
+	create(t, s, later)
+
+	// Creates racing for the last slots store exactly as many as fit
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	stored := 0
+	for range 12 {
+		wg.Go(func() {
+			err := s.Create(ctx, secretOf("carol", time.Hour))
+			if err != nil && !errors.Is(err, store.ErrQuotaExceeded) {
+				t.Errorf("concurrent Create() error: %v", err)
+			}
+			if err == nil {
+				mu.Lock()
+				stored++
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+	if stored != 3 {
+		t.Errorf("concurrent creates stored %d secrets, want 3", stored)
+	}
+
+	// The cap is checked, not stored: a read returns no quota
+	got, err := s.Consume(ctx, plain.ID, store.ConsumeOptions{Now: now})
+	if err != nil || got.CreatorQuota != 0 {
+		t.Errorf("Consume() = %+v, %v; want the secret without a quota", got, err)
+	}
+}
+
+func testCleanup(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	now := time.Now()
+
+	create(t, s, newSecret(t, -time.Minute))
+	create(t, s, newSecret(t, -time.Second))
+	live := newSecret(t, time.Hour)
+	create(t, s, live)
+
+	if n, err := s.DeleteExpired(ctx, now); err != nil || n != 2 {
+		t.Fatalf("DeleteExpired() = %d, %v; want 2, nil", n, err)
+	}
+	if n, err := s.DeleteExpired(ctx, now); err != nil || n != 0 {
+		t.Fatalf("second DeleteExpired() = %d, %v; want 0, nil", n, err)
+	}
+
+	_, err := s.Consume(ctx, live.ID, store.ConsumeOptions{
+		Now:     now,
+		Receipt: &store.Receipt{ConsumedAt: now.Add(-time.Hour), NetworkClass: "external"},
+	})
+	if err != nil {
+		t.Fatalf("Consume() error: %v", err)
+	}
+
+	if n, err := s.PruneReceipts(ctx, now.Add(-2*time.Hour)); err != nil || n != 0 {
+		t.Fatalf("PruneReceipts() before receipt = %d, %v; want 0, nil", n, err)
+	}
+	if n, err := s.PruneReceipts(ctx, now); err != nil || n != 1 {
+		t.Fatalf("PruneReceipts() = %d, %v; want 1, nil", n, err)
+	}
+
+	if err := s.Ping(ctx); err != nil {
+		t.Fatalf("Ping() error: %v", err)
+	}
+}
+
+// testDeleteExpiredKeepsHolds checks the expiry sweep leaves a secret held
+// for an ack or grace window that is still open, even past its expiry
+func testDeleteExpiredKeepsHolds(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	now := time.Now()
+	tokenHash := bytes.Repeat([]byte{0x5D}, 32)
+
+	acked := newSecret(t, time.Minute)
+	acked.RequireAck = true
+	create(t, s, acked)
+	_, err := s.Consume(ctx, acked.ID, store.ConsumeOptions{
+		Now:     now,
+		Receipt: &store.Receipt{ConsumedAt: now},
+		Ack:     &store.AckHold{TokenHash: tokenHash, Deadline: now.Add(time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("Consume() with ack hold error: %v", err)
+	}
+	graced := newSecret(t, time.Minute)
+	create(t, s, graced)
+	_, err = s.Consume(ctx, graced.ID, store.ConsumeOptions{
+		Now:     now,
+		Receipt: &store.Receipt{ConsumedAt: now},
+		Grace:   &store.GraceHold{TokenHash: tokenHash, Deadline: now.Add(time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("Consume() with grace hold error: %v", err)
+	}
+
+	// Both are past their expiry, but their windows are open
+	later := now.Add(2 * time.Minute)
+	if n, err := s.DeleteExpired(ctx, later); err != nil || n != 0 {
+		t.Fatalf("DeleteExpired() with open holds = %d, %v; want 0, nil", n, err)
+	}
+	if err := s.Acknowledge(ctx, acked.ID, tokenHash, later, 0); err != nil {
+		t.Fatalf("Acknowledge() after DeleteExpired() error: %v", err)
+	}
+	assertAcknowledged(t, s, acked.ID, true)
+
+	// Once the window has closed the sweep takes it
+	if n, err := s.DeleteExpired(ctx, now.Add(2*time.Hour)); err != nil || n != 1 {
+		t.Fatalf("DeleteExpired() after the window = %d, %v; want 1, nil", n, err)
+	}
+	if burned, err := s.Burn(ctx, graced.ID); err != nil || burned {
+		t.Fatalf("Burn() after DeleteExpired() = %v, %v; want false, nil", burned, err)
+	}
+}
+
+func testAckHold(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	now := time.Now()
+	tokenHash := bytes.Repeat([]byte{0x5A}, 32)
+
+	for _, wrapped := range []bool{false, true} {
+		secret := newSecret(t, time.Hour)
+		secret.RequireAck = true
+		if wrapped {
+			secret.DataKey = bytes.Repeat([]byte{0x0B}, 32)
+		}
+		create(t, s, secret)
+
+		got, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{
+			Now:     now,
+			Receipt: &store.Receipt{ConsumedAt: now},
+			Ack:     &store.AckHold{TokenHash: tokenHash, Deadline: now.Add(time.Minute)},
+		})
+		if err != nil {
+			t.Fatalf("wrapped=%v: Consume() error: %v", wrapped, err)
+		}
+		if !got.RequireAck || !bytes.Equal(got.Ciphertext, secret.Ciphertext) {
+			t.Fatalf("wrapped=%v: Consume() = %+v, want held payload", wrapped, got)
+		}
+
+		// Held, but never delivered twice
+		if _, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{Now: now}); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("wrapped=%v: Consume() of held secret error = %v, want ErrNotFound", wrapped, err)
+		}
+		assertAcknowledged(t, s, secret.ID, false)
+
+		if err := s.Acknowledge(ctx, secret.ID, bytes.Repeat([]byte{0x00}, 32), now, 0); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("wrapped=%v: Acknowledge() with wrong token error = %v, want ErrNotFound", wrapped, err)
+		}
+		if err := s.Acknowledge(ctx, secret.ID, tokenHash, now, 0); err != nil {
+			t.Fatalf("wrapped=%v: Acknowledge() error: %v", wrapped, err)
+		}
+		assertAcknowledged(t, s, secret.ID, true)
+
+		if err := s.Acknowledge(ctx, secret.ID, tokenHash, now, 0); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("wrapped=%v: second Acknowledge() error = %v, want ErrNotFound", wrapped, err)
+		}
+		if burned, err := s.Burn(ctx, secret.ID); err != nil || burned {
+			t.Fatalf("wrapped=%v: Burn() after ack = %v, %v; want false, nil", wrapped, burned, err)
+		}
+	}
+
+	// Without a hold a require_ack secret is consumed like any other
+	secret := newSecret(t, time.Hour)
+	secret.RequireAck = true
+	create(t, s, secret)
+	if _, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{Now: now}); err != nil {
+		t.Fatalf("Consume() without hold error: %v", err)
+	}
+	if err := s.Acknowledge(ctx, secret.ID, tokenHash, now, 0); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Acknowledge() of consumed secret error = %v, want ErrNotFound", err)
+	}
+
+	// Secrets created without require_ack ignore the hold
+	plain := newSecret(t, time.Hour)
+	create(t, s, plain)
+	_, err := s.Consume(ctx, plain.ID, store.ConsumeOptions{
+		Now:     now,
+		Receipt: &store.Receipt{ConsumedAt: now},
+		Ack:     &store.AckHold{TokenHash: tokenHash, Deadline: now.Add(time.Minute)},
+	})
+	if err != nil {
+		t.Fatalf("Consume() of plain secret error: %v", err)
+	}
+	if err := s.Acknowledge(ctx, plain.ID, tokenHash, now, 0); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Acknowledge() of plain secret error = %v, want ErrNotFound", err)
+	}
+	receipt, err := s.Receipt(ctx, plain.ID)
+	if err != nil {
+		t.Fatalf("Receipt() error: %v", err)
+	}
+	if receipt.Acknowledged != nil {
+		t.Errorf("Receipt() of plain secret acknowledged = %v, want nil", *receipt.Acknowledged)
+	}
+}
+
+func testAckWindowLapses(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	now := time.Now()
+	tokenHash := bytes.Repeat([]byte{0x5B}, 32)
+
+	secret := newSecret(t, time.Hour)
+	secret.RequireAck = true
+	create(t, s, secret)
+
+	_, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{
+		Now:     now,
+		Receipt: &store.Receipt{ConsumedAt: now},
+		Ack:     &store.AckHold{TokenHash: tokenHash, Deadline: now.Add(time.Minute)},
+	})
+	if err != nil {
+		t.Fatalf("Consume() error: %v", err)
+	}
+
+	// A late ack burns the secret without acknowledging it
+	if err := s.Acknowledge(ctx, secret.ID, tokenHash, now.Add(2*time.Minute), 0); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Acknowledge() after window error = %v, want ErrNotFound", err)
+	}
+	if burned, err := s.Burn(ctx, secret.ID); err != nil || burned {
+		t.Fatalf("Burn() after lapsed ack = %v, %v; want false, nil", burned, err)
+	}
+	assertAcknowledged(t, s, secret.ID, false)
+}
+
+func testBurnUnacknowledged(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	now := time.Now()
+	tokenHash := bytes.Repeat([]byte{0x5C}, 32)
+
+	hold := func(window time.Duration, wrapped bool) *store.Secret {
+		secret := newSecret(t, time.Hour)
+		secret.RequireAck = true
+		if wrapped {
+			secret.DataKey = bytes.Repeat([]byte{0x0C}, 32)
+		}
+		create(t, s, secret)
+
+		_, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{
+			Now:     now,
+			Receipt: &store.Receipt{ConsumedAt: now},
+			Ack:     &store.AckHold{TokenHash: tokenHash, Deadline: now.Add(window)},
+		})
+		if err != nil {
+			t.Fatalf("Consume() error: %v", err)
+		}
+		return secret
+	}
+
+	lapsed := hold(time.Second, false)
+	lapsedWrapped := hold(time.Second, true)
+	pending := hold(time.Hour, false)
+	create(t, s, newSecret(t, time.Hour))
+
+	if n, err := s.BurnUnacknowledged(ctx, now.Add(time.Minute)); err != nil || n != 2 {
+		t.Fatalf("BurnUnacknowledged() = %d, %v; want 2, nil", n, err)
+	}
+	if n, err := s.BurnUnacknowledged(ctx, now.Add(time.Minute)); err != nil || n != 0 {
+		t.Fatalf("second BurnUnacknowledged() = %d, %v; want 0, nil", n, err)
+	}
+
+	for _, secret := range []*store.Secret{lapsed, lapsedWrapped} {
+		if err := s.Acknowledge(ctx, secret.ID, tokenHash, now, 0); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Acknowledge() of burned secret error = %v, want ErrNotFound", err)
+		}
+		assertAcknowledged(t, s, secret.ID, false)
+	}
+
+	if err := s.Acknowledge(ctx, pending.ID, tokenHash, now, 0); err != nil {
+		t.Fatalf("Acknowledge() of pending secret error: %v", err)
+	}
+}
+
+func testGraceHold(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	now := time.Now()
+	tokenHash := bytes.Repeat([]byte{0x5E}, 32)
+
+	hold := func(secret *store.Secret, ack *store.AckHold) {
+		t.Helper()
+		create(t, s, secret)
+		got, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{
+			Now:     now,
+			Receipt: &store.Receipt{ConsumedAt: now},
+			Ack:     ack,
+			Grace:   &store.GraceHold{TokenHash: tokenHash, Deadline: now.Add(time.Minute)},
+		})
+		if err != nil || !bytes.Equal(got.Ciphertext, secret.Ciphertext) {
+			t.Fatalf("Consume() = %+v, %v; want the payload", got, err)
+		}
+	}
+	retry := func(id string, token []byte, at time.Time) (*store.Secret, error) {
+		return s.Consume(ctx, id, store.ConsumeOptions{Now: at, RetrievalTokenHash: token})
+	}
+
+	for _, wrapped := range []bool{false, true} {
+		secret := newSecret(t, time.Hour)
+		secret.Parts = []store.Part{{Label: "password", Ciphertext: []byte("hunter2"), IV: bytes.Repeat([]byte{0x03}, 12)}}
+		if wrapped {
+			secret.DataKey = bytes.Repeat([]byte{0x0E}, 32)
+		}
+		hold(secret, nil)
+
+		// Held: no plain read, preview, ack or wrong token gets it
+		if _, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{Now: now}); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("wrapped=%v: Consume() of held secret error = %v, want ErrNotFound", wrapped, err)
+		}
+		if _, err := s.Peek(ctx, secret.ID, now); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("wrapped=%v: Peek() of held secret error = %v, want ErrNotFound", wrapped, err)
+		}
+		if err := s.Acknowledge(ctx, secret.ID, tokenHash, now, 0); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("wrapped=%v: Acknowledge() of grace hold error = %v, want ErrNotFound", wrapped, err)
+		}
+		if _, err := retry(secret.ID, bytes.Repeat([]byte{0x00}, 32), now); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("wrapped=%v: retry with wrong token error = %v, want ErrNotFound", wrapped, err)
+		}
+
+		// The retry delivers the payload once more, then it is gone
+		got, err := retry(secret.ID, tokenHash, now.Add(30*time.Second))
+		if err != nil {
+			t.Fatalf("wrapped=%v: retry error: %v", wrapped, err)
+		}
+		if !bytes.Equal(got.Ciphertext, secret.Ciphertext) || len(got.Parts) != 1 || (wrapped && got.DataKey == nil) {
+			t.Fatalf("wrapped=%v: retry = %+v, want the payload", wrapped, got)
+		}
+		if _, err := retry(secret.ID, tokenHash, now.Add(30*time.Second)); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("wrapped=%v: second retry error = %v, want ErrNotFound", wrapped, err)
+		}
+		receipt, err := s.Receipt(ctx, secret.ID)
+		if err != nil || receipt.Reason != store.TerminationConsumed || receipt.Acknowledged != nil {
+			t.Fatalf("wrapped=%v: Receipt() = %+v, %v; want a consumed receipt without an ack", wrapped, receipt, err)
+		}
+	}
+
+	// A retry after the window finds nothing, and the sweep removes it
+	lapsed := newSecret(t, time.Hour)
+	hold(lapsed, nil)
+	if _, err := retry(lapsed.ID, tokenHash, now.Add(2*time.Minute)); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("retry after window error = %v, want ErrNotFound", err)
+	}
+	if n, err := s.BurnUnacknowledged(ctx, now.Add(2*time.Minute)); err != nil || n != 1 {
+		t.Fatalf("BurnUnacknowledged() = %d, %v; want 1, nil", n, err)
+	}
+	if burned, err := s.Burn(ctx, lapsed.ID); err != nil || burned {
+		t.Fatalf("Burn() after the sweep = %v, %v; want false, nil", burned, err)
+	}
+
+	// A require_ack secret takes its ack hold, never the grace hold
+	acked := newSecret(t, time.Hour)
+	acked.RequireAck = true
+	hold(acked, &store.AckHold{TokenHash: tokenHash, Deadline: now.Add(time.Minute)})
+	if _, err := retry(acked.ID, tokenHash, now); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("retry of ack hold error = %v, want ErrNotFound", err)
+	}
+	if err := s.Acknowledge(ctx, acked.ID, tokenHash, now, 0); err != nil {
+		t.Fatalf("Acknowledge() error: %v", err)
+	}
+
+	// Without a hold a secret is destroyed, so a retry finds nothing
+	plain := newSecret(t, time.Hour)
+	create(t, s, plain)
+	if _, err := s.Consume(ctx, plain.ID, store.ConsumeOptions{Now: now}); err != nil {
+		t.Fatalf("Consume() error: %v", err)
+	}
+	if _, err := retry(plain.ID, tokenHash, now); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("retry without hold error = %v, want ErrNotFound", err)
+	}
+}
+
+func testConcurrentConsume(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	for _, wrapped := range []bool{false, true} {
+		secret := newSecret(t, time.Hour)
+		if wrapped {
+			secret.DataKey = bytes.Repeat([]byte{0x09}, 32)
+		}
+		create(t, s, secret)
+
+		const readers = 8
+		var wg sync.WaitGroup
+		results := make(chan error, readers)
+		for range readers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{Now: time.Now()})
+				results <- err
+			}()
+		}
+		wg.Wait()
+		close(results)
+
+		successes := 0
+		for err := range results {
+			switch {
+			case err == nil:
+				successes++
+			case !errors.Is(err, store.ErrNotFound):
+				t.Errorf("wrapped=%v: Consume() unexpected error: %v", wrapped, err)
+			}
+		}
+
+		if successes != 1 {
+			t.Fatalf("wrapped=%v: %d concurrent consumes succeeded, want exactly 1", wrapped, successes)
+		}
+	}
+}
+
+// burnTrigger is one way a secret can end. fire reports whether this call
+// destroyed the secret; reads that must never deliver have destroys unset
+// and report true only if they did.
+type burnTrigger struct {
+	name     string
+	destroys bool
+	fire     func(ctx context.Context, s store.Store, secret *store.Secret) (bool, error)
+}
+
+// raceTokenHash is the ack token hash burn race holds are taken with
+var raceTokenHash = bytes.Repeat([]byte{0x5D}, 32)
+
+// raceAckWindow is how long burn race holds wait for their ack
+const raceAckWindow = time.Minute
+
+var (
+	triggerConsume = burnTrigger{"consume", true, func(ctx context.Context, s store.Store, secret *store.Secret) (bool, error) {
+		now := time.Now()
+		_, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{Now: now, Receipt: &store.Receipt{ConsumedAt: now}})
+		return err == nil, err
+	}}
+	triggerExpiredRead = burnTrigger{"expired read", false, func(ctx context.Context, s store.Store, secret *store.Secret) (bool, error) {
+		_, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{Now: secret.ExpiresAt.Add(time.Second)})
+		return err == nil, err
+	}}
+	triggerHeldRead = burnTrigger{"held read", false, func(ctx context.Context, s store.Store, secret *store.Secret) (bool, error) {
+		_, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{Now: time.Now()})
+		return err == nil, err
+	}}
+	triggerBurn = burnTrigger{"burn", true, func(ctx context.Context, s store.Store, secret *store.Secret) (bool, error) {
+		return s.Burn(ctx, secret.ID)
+	}}
+	triggerPurge = burnTrigger{"namespace purge", true, func(ctx context.Context, s store.Store, secret *store.Secret) (bool, error) {
+		n, err := s.PurgeNamespace(ctx, secret.Namespace)
+		return n > 0, err
+	}}
+	triggerCleanup = burnTrigger{"expiry cleanup", true, func(ctx context.Context, s store.Store, secret *store.Secret) (bool, error) {
+		n, err := s.DeleteExpired(ctx, secret.ExpiresAt.Add(time.Second))
+		return n > 0, err
+	}}
+	triggerAck = burnTrigger{"acknowledge", true, func(ctx context.Context, s store.Store, secret *store.Secret) (bool, error) {
+		err := s.Acknowledge(ctx, secret.ID, raceTokenHash, time.Now(), 0)
+		return err == nil, err
+	}}
+	triggerAckSweep = burnTrigger{"ack window sweep", true, func(ctx context.Context, s store.Store, secret *store.Secret) (bool, error) {
+		n, err := s.BurnUnacknowledged(ctx, time.Now().Add(raceAckWindow+time.Second))
+		return n > 0, err
+	}}
+)
+
+// testBurnRaces runs every pair of burn triggers against one secret at the
+// same time, for live and for held require_ack secrets, plain and wrapped.
+// However the two interleave, exactly one destroying trigger reports the
+// secret as its own, the loser sees ErrNotFound or nothing, and what is left
+// behind (receipt, acknowledgement, a readable row) matches the winner.
+func testBurnRaces(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	const rounds = 3
+
+	matrices := []struct {
+		name     string
+		held     bool
+		triggers []burnTrigger
+	}{
+		{"live", false, []burnTrigger{triggerConsume, triggerExpiredRead, triggerBurn, triggerPurge, triggerCleanup}},
+		{"held", true, []burnTrigger{triggerAck, triggerHeldRead, triggerBurn, triggerPurge, triggerCleanup, triggerAckSweep}},
+	}
+
+	for _, matrix := range matrices {
+		for i, a := range matrix.triggers {
+			for _, b := range matrix.triggers[i:] {
+				for _, wrapped := range []bool{false, true} {
+					name := matrix.name + ": " + a.name + " vs " + b.name
+					if wrapped {
+						name += " (wrapped)"
+					}
+					// Both orders once, then the two at the same time
+					raceTriggers(t, ctx, s, name+" in order", matrix.held, wrapped, false, a, b)
+					raceTriggers(t, ctx, s, name+" reversed", matrix.held, wrapped, false, b, a)
+					for range rounds {
+						raceTriggers(t, ctx, s, name+" racing", matrix.held, wrapped, true, a, b)
+					}
+				}
+			}
+		}
+	}
+}
+
+// raceTriggers creates a secret, fires a and b at it, one after the other
+// or concurrently, and checks the outcome
+func raceTriggers(t *testing.T, ctx context.Context, s store.Store, name string, held, wrapped, concurrent bool, a, b burnTrigger) {
+	t.Helper()
+
+	secret := newSecret(t, time.Hour)
+	// A namespace of its own keeps purges to this secret
+	secret.Namespace = "race-" + strings.ToLower(secret.ID)
+	secret.RequireAck = held
+	if wrapped {
+		secret.DataKey = bytes.Repeat([]byte{0x0D}, 32)
+	}
+	create(t, s, secret)
+	if held {
+		now := time.Now()
+		_, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{
+			Now:     now,
+			Receipt: &store.Receipt{ConsumedAt: now},
+			Ack:     &store.AckHold{TokenHash: raceTokenHash, Deadline: now.Add(raceAckWindow)},
+		})
+		if err != nil {
+			t.Fatalf("%s: Consume() to hold error: %v", name, err)
+		}
+	}
+
+	type result struct {
+		trigger burnTrigger
+		won     bool
+		err     error
+	}
+	results := make(chan result, 2)
+	fire := func(trigger burnTrigger) {
+		won, err := trigger.fire(ctx, s, secret)
+		results <- result{trigger, won, err}
+	}
+	if concurrent {
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		for _, trigger := range []burnTrigger{a, b} {
+			wg.Go(func() {
+				<-start
+				fire(trigger)
+			})
+		}
+		close(start)
+		wg.Wait()
+	} else {
+		fire(a)
+		fire(b)
+	}
+	close(results)
+
+	var winners []string
+	destroyers := 0
+	for r := range results {
+		if r.err != nil && !errors.Is(r.err, store.ErrNotFound) {
+			t.Errorf("%s: %s unexpected error: %v", name, r.trigger.name, r.err)
+		}
+		if r.won {
+			winners = append(winners, r.trigger.name)
+			if !r.trigger.destroys {
+				t.Errorf("%s: %s delivered the secret", name, r.trigger.name)
+			}
+		}
+		if r.trigger.destroys {
+			destroyers++
+		}
+	}
+	if want := min(destroyers, 1); len(winners) != want {
+		t.Fatalf("%s: winners = %v, want %d", name, winners, want)
+	}
+
+	if destroyers > 0 {
+		if _, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{Now: time.Now()}); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("%s: Consume() afterwards error = %v, want ErrNotFound", name, err)
+		}
+		if _, err := s.Peek(ctx, secret.ID, time.Now()); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("%s: Peek() afterwards error = %v, want ErrNotFound", name, err)
+		}
+		if burned, err := s.Burn(ctx, secret.ID); err != nil || burned {
+			t.Errorf("%s: Burn() afterwards = %v, %v; want false, nil", name, burned, err)
+		}
+	}
+
+	won := func(trigger string) bool { return slices.Contains(winners, trigger) }
+	receipt, err := s.Receipt(ctx, secret.ID)
+	switch {
+	case held && err != nil:
+		t.Errorf("%s: Receipt() error: %v", name, err)
+	case held && (receipt.Acknowledged == nil || *receipt.Acknowledged != won(triggerAck.name)):
+		t.Errorf("%s: Receipt() acknowledged = %v, want %v", name, receipt.Acknowledged, won(triggerAck.name))
+	case !held && (err == nil) != won(triggerConsume.name):
+		t.Errorf("%s: Receipt() error = %v with winners %v; want a receipt only for a consume", name, err, winners)
+	}
+
+	// Leave nothing behind for the next pair's store-wide sweeps
+	if _, err := s.Burn(ctx, secret.ID); err != nil {
+		t.Fatalf("%s: Burn() error: %v", name, err)
+	}
+	if _, err := s.CollectShredded(ctx); err != nil {
+		t.Fatalf("%s: CollectShredded() error: %v", name, err)
+	}
+}
+
+func testClampExpiry(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	now := time.Now()
+	ceiling := now.Add(time.Hour)
+
+	long := []*store.Secret{newSecret(t, 24*time.Hour), newSecret(t, 12*time.Hour), newSecret(t, 6*time.Hour)}
+	for _, secret := range long {
+		create(t, s, secret)
+	}
+	within := newSecret(t, 30*time.Minute)
+	create(t, s, within)
+
+	// A held require_ack secret keeps its ack window
+	held := newSecret(t, 24*time.Hour)
+	held.RequireAck = true
+	create(t, s, held)
+	_, err := s.Consume(ctx, held.ID, store.ConsumeOptions{
+		Now: now,
+		Ack: &store.AckHold{TokenHash: bytes.Repeat([]byte{0x01}, 32), Deadline: now.Add(time.Minute)},
+	})
+	if err != nil {
+		t.Fatalf("Consume() error: %v", err)
+	}
+
+	first, err := s.ClampExpiry(ctx, ceiling, 2)
+	if err != nil || len(first) != 2 {
+		t.Fatalf("ClampExpiry() = %v, %v; want 2 IDs", first, err)
+	}
+	rest, err := s.ClampExpiry(ctx, ceiling, 2)
+	if err != nil || len(rest) != 1 {
+		t.Fatalf("second ClampExpiry() = %v, %v; want 1 ID", rest, err)
+	}
+	if again, err := s.ClampExpiry(ctx, ceiling, 2); err != nil || len(again) != 0 {
+		t.Fatalf("third ClampExpiry() = %v, %v; want none", again, err)
+	}
+
+	clamped := map[string]bool{}
+	for _, id := range append(first, rest...) {
+		clamped[id] = true
+	}
+	for _, secret := range long {
+		if !clamped[secret.ID] {
+			t.Errorf("secret expiring at %v was not clamped", secret.ExpiresAt)
+		}
+	}
+
+	// The in-policy secret keeps its earlier expiry; clamped ones now
+	// expire exactly at the ceiling
+	if n, err := s.DeleteExpired(ctx, within.ExpiresAt.Add(time.Second)); err != nil || n != 1 {
+		t.Fatalf("DeleteExpired() past the in-policy expiry = %d, %v; want 1", n, err)
+	}
+	if n, err := s.DeleteExpired(ctx, ceiling.Add(-time.Second)); err != nil || n != 0 {
+		t.Fatalf("DeleteExpired() before the ceiling = %d, %v; want 0", n, err)
+	}
+	if n, err := s.DeleteExpired(ctx, ceiling.Add(time.Second)); err != nil || n != int64(len(long)) {
+		t.Fatalf("DeleteExpired() past the ceiling = %d, %v; want %d", n, err, len(long))
+	}
+	if err := s.Acknowledge(ctx, held.ID, bytes.Repeat([]byte{0x01}, 32), now, 0); err != nil {
+		t.Fatalf("Acknowledge() of held secret error: %v", err)
+	}
+}
+
+func testStragglers(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	now := time.Now()
+	consume := func(secret *store.Secret, at time.Time, ack *store.AckHold) {
+		t.Helper()
+		_, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{Now: now, Receipt: &store.Receipt{ConsumedAt: at}, Ack: ack})
+		if err != nil {
+			t.Fatalf("Consume() error: %v", err)
+		}
+	}
+
+	gone := newSecret(t, time.Hour)
+	create(t, s, gone)
+	consume(gone, now, nil)
+
+	// A row that reappears after its consume is exactly what the check is for
+	straggler := newSecret(t, time.Hour)
+	create(t, s, straggler)
+	consume(straggler, now, nil)
+	create(t, s, straggler)
+
+	// Held secrets are readable by nobody until their window lapses
+	held := newSecret(t, time.Hour)
+	held.RequireAck = true
+	create(t, s, held)
+	consume(held, now, &store.AckHold{TokenHash: bytes.Repeat([]byte{0x01}, 32), Deadline: now.Add(time.Minute)})
+
+	// Receipts before the window are not sampled
+	old := newSecret(t, time.Hour)
+	create(t, s, old)
+	consume(old, now.Add(-2*time.Hour), nil)
+	create(t, s, old)
+
+	since := now.Add(-time.Hour)
+	if ids, err := s.Stragglers(ctx, since, now, 10); err != nil || !slices.Equal(ids, []string{straggler.ID}) {
+		t.Errorf("Stragglers() = %v, %v; want [%s]", ids, err, straggler.ID)
+	}
+
+	want := []string{straggler.ID, held.ID}
+	slices.Sort(want)
+	if ids, err := s.Stragglers(ctx, since, now.Add(2*time.Minute), 10); err != nil || !slices.Equal(ids, want) {
+		t.Errorf("Stragglers() past the ack window = %v, %v; want %v", ids, err, want)
+	}
+
+	want = []string{gone.ID, straggler.ID, held.ID}
+	slices.Sort(want)
+	if ids, err := s.ReceiptIDs(ctx, since, now); err != nil || !slices.Equal(ids, want) {
+		t.Errorf("ReceiptIDs() = %v, %v; want %v", ids, err, want)
+	}
+	if ids, err := s.ReceiptIDs(ctx, now.Add(time.Second), now.Add(time.Hour)); err != nil || len(ids) != 0 {
+		t.Errorf("ReceiptIDs() after every consume = %v, %v; want none", ids, err)
+	}
+}
+
+func testAuditScan(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	var gen ulid.Generator
+	var ids []string
+	record := func(offset time.Duration, eventType, namespace, hash string) {
+		event := &store.AuditEvent{
+			ID:           gen.New(base.Add(offset)),
+			Type:         eventType,
+			OccurredAt:   base.Add(offset),
+			Namespace:    namespace,
+			SecretIDHash: hash,
+		}
+		if err := s.RecordAudit(ctx, event); err != nil {
+			t.Fatalf("RecordAudit() error: %v", err)
+		}
+		ids = append(ids, event.ID)
+	}
+
+	record(0, "secret.created", "", "aa01")
+	record(time.Second, "secret.consumed", "", "aa01")
+	record(2*time.Second, "secret.created", "team", "bb02")
+	record(3*time.Second, "secret.burned", "team", "bb02")
+	record(4*time.Second, "secret.created", "", "aa03")
+
+	scan := func(filter store.AuditFilter) []string {
+		t.Helper()
+		var got []string
+		err := s.ScanAudit(ctx, filter, func(event *store.AuditEvent) error {
+			got = append(got, event.ID)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("ScanAudit(%+v) error: %v", filter, err)
+		}
+		return got
+	}
+
+	tests := []struct {
+		name   string
+		filter store.AuditFilter
+		want   []string
+	}{
+		{"all", store.AuditFilter{}, ids},
+		{"type", store.AuditFilter{Types: []string{"secret.created"}}, []string{ids[0], ids[2], ids[4]}},
+		{"types", store.AuditFilter{Types: []string{"secret.consumed", "secret.burned"}}, []string{ids[1], ids[3]}},
+		{"namespace", store.AuditFilter{Namespace: "team"}, []string{ids[2], ids[3]}},
+		{"hash prefix", store.AuditFilter{SecretIDHashPrefix: "aa"}, []string{ids[0], ids[1], ids[4]}},
+		{"type and prefix", store.AuditFilter{Types: []string{"secret.created"}, SecretIDHashPrefix: "aa0"}, []string{ids[0], ids[4]}},
+		{"time range", store.AuditFilter{FromID: ulid.Floor(base.Add(time.Second)), BeforeID: ulid.Floor(base.Add(3 * time.Second))}, []string{ids[1], ids[2]}},
+		{"cursor", store.AuditFilter{AfterID: ids[2]}, []string{ids[3], ids[4]}},
+		{"limit", store.AuditFilter{Limit: 2}, ids[:2]},
+		{"no match", store.AuditFilter{Namespace: "other"}, nil},
+	}
+	for _, tt := range tests {
+		got := scan(tt.filter)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: ScanAudit() = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: ScanAudit() = %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+
+	// Fields survive the round trip and fn errors stop the scan
+	stop := errors.New("stop")
+	var first *store.AuditEvent
+	err := s.ScanAudit(ctx, store.AuditFilter{}, func(event *store.AuditEvent) error {
+		first = event
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("ScanAudit() error = %v, want fn error", err)
+	}
+	if first.ID != ids[0] || first.Type != "secret.created" || first.SecretIDHash != "aa01" || !first.OccurredAt.Equal(base) {
+		t.Errorf("ScanAudit() first event = %+v", first)
+	}
+
+	// Report reasons and operator labels round-trip; other events have none
+	reported := &store.AuditEvent{ID: gen.New(base.Add(5 * time.Second)), Type: "secret.reported", OccurredAt: base.Add(5 * time.Second), SecretIDHash: "aa01", Reason: "suspected_interception"}
+	if err := s.RecordAudit(ctx, reported); err != nil {
+		t.Fatalf("RecordAudit() error: %v", err)
+	}
+	dossier := &store.AuditEvent{ID: gen.New(base.Add(6 * time.Second)), Type: "secret.dossier_generated", OccurredAt: base.Add(6 * time.Second), SecretIDHash: "aa01", Actor: "support"}
+	if err := s.RecordAudit(ctx, dossier); err != nil {
+		t.Fatalf("RecordAudit() error: %v", err)
+	}
+	reasons := map[string]string{}
+	actors := map[string]string{}
+	err = s.ScanAudit(ctx, store.AuditFilter{SecretIDHashPrefix: "aa01"}, func(event *store.AuditEvent) error {
+		reasons[event.Type] = event.Reason
+		actors[event.Type] = event.Actor
+		return nil
+	})
+	if err != nil || reasons["secret.reported"] != reported.Reason || reasons["secret.consumed"] != "" {
+		t.Errorf("ScanAudit() reasons = %v, %v; want only secret.reported to carry %q", reasons, err, reported.Reason)
+	}
+	if actors["secret.dossier_generated"] != dossier.Actor || actors["secret.reported"] != "" {
+		t.Errorf("ScanAudit() actors = %v; want only secret.dossier_generated to carry %q", actors, dossier.Actor)
+	}
+}
+
+func newSecret(t *testing.T, ttl time.Duration) *store.Secret {
+	t.Helper()
+
+	id, err := crypto.GenerateSecretID()
+	if err != nil {
+		t.Fatalf("GenerateSecretID() error: %v", err)
+	}
+
+	now := time.Now()
+	return &store.Secret{
+		ID:            id,
+		Ciphertext:    []byte("ciphertext-" + id),
+		IV:            bytes.Repeat([]byte{0xAA}, 12),
+		ExpiresAt:     now.Add(ttl),
+		CreatedAt:     now,
+		BurnAfterRead: true,
+	}
+}
+
+func assertAcknowledged(t *testing.T, s store.Store, id string, want bool) {
+	t.Helper()
+
+	receipt, err := s.Receipt(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Receipt() error: %v", err)
+	}
+	if receipt.Acknowledged == nil || *receipt.Acknowledged != want {
+		t.Fatalf("Receipt() acknowledged = %v, want %v", receipt.Acknowledged, want)
+	}
+}
+
+func create(t *testing.T, s store.Store, secret *store.Secret) {
+	t.Helper()
+
+	if err := s.Create(context.Background(), secret); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+}
+
+func testDroppedWork(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	err := s.RecordDroppedWork(ctx, []store.DroppedWork{
+		{Kind: "audit_event", Reason: "shutdown", Count: 2, RecordedAt: now.Add(-48 * time.Hour)},
+		{Kind: "audit_event", Reason: "shutdown", Count: 3, RecordedAt: now.Add(-time.Hour)},
+		{Kind: "listener_event", Reason: "overflow", Count: 7, RecordedAt: now.Add(-2 * time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("RecordDroppedWork() error: %v", err)
+	}
+	if err := s.RecordDroppedWork(ctx, []store.DroppedWork{{Kind: "audit_event", Reason: "shutdown", Count: 1, RecordedAt: now}}); err != nil {
+		t.Fatalf("second RecordDroppedWork() error: %v", err)
+	}
+
+	losses, err := s.DroppedWorkSince(ctx, now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("DroppedWorkSince() error: %v", err)
+	}
+	want := []store.DroppedWork{
+		{Kind: "audit_event", Reason: "shutdown", Count: 4, RecordedAt: now},
+		{Kind: "listener_event", Reason: "overflow", Count: 7, RecordedAt: now.Add(-2 * time.Hour)},
+	}
+	if len(losses) != len(want) {
+		t.Fatalf("DroppedWorkSince() = %+v, want %+v", losses, want)
+	}
+	for i := range want {
+		got := losses[i]
+		if got.Kind != want[i].Kind || got.Reason != want[i].Reason || got.Count != want[i].Count || !got.RecordedAt.Equal(want[i].RecordedAt) {
+			t.Errorf("DroppedWorkSince()[%d] = %+v, want %+v", i, got, want[i])
+		}
+	}
+
+	if n, err := s.PruneDroppedWork(ctx, now.Add(-24*time.Hour)); err != nil || n != 1 {
+		t.Fatalf("PruneDroppedWork() = %d, %v; want 1, nil", n, err)
+	}
+	if losses, err := s.DroppedWorkSince(ctx, time.Time{}); err != nil || len(losses) != 2 || losses[0].Count != 4 {
+		t.Errorf("DroppedWorkSince() after prune = %+v, %v; want the last day only", losses, err)
+	}
+}
+
+func testSizeBuckets(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	if buckets, err := s.SizeBuckets(ctx); err != nil || len(buckets) != 0 {
+		t.Fatalf("SizeBuckets() on an empty store = %+v, %v; want none", buckets, err)
+	}
+
+	for _, bound := range []int64{1024, 64, 1024, 1 << 20, 1024} {
+		if err := s.RecordSizeBucket(ctx, bound); err != nil {
+			t.Fatalf("RecordSizeBucket(%d) error: %v", bound, err)
+		}
+	}
+
+	buckets, err := s.SizeBuckets(ctx)
+	if err != nil {
+		t.Fatalf("SizeBuckets() error: %v", err)
+	}
+	want := []store.SizeBucket{{UpperBound: 64, Count: 1}, {UpperBound: 1024, Count: 3}, {UpperBound: 1 << 20, Count: 1}}
+	if !slices.Equal(buckets, want) {
+		t.Errorf("SizeBuckets() = %+v, want %+v", buckets, want)
+	}
+}
+
+func testDailyStats(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	today := time.Date(2026, 5, 14, 0, 0, 0, 0, time.UTC)
+	yesterday := today.AddDate(0, 0, -1)
+
+	for _, delta := range []store.DailyStats{
+		{Day: today.Add(9 * time.Hour), Created: 1, Bytes: 100, TTLSeconds: 3600},
+		{Day: today.Add(10 * time.Hour), Created: 1, Bytes: 50, TTLSeconds: 300},
+		{Day: today.Add(23 * time.Hour), Retrieved: 1},
+		{Day: yesterday.Add(time.Hour), Burned: 1, Expired: 3},
+		{Day: today.AddDate(0, 0, -40), Created: 9},
+	} {
+		if err := s.AddDailyStats(ctx, delta); err != nil {
+			t.Fatalf("AddDailyStats(%+v) error: %v", delta, err)
+		}
+	}
+
+	days, err := s.DailyStats(ctx, yesterday, today.Add(12*time.Hour))
+	if err != nil {
+		t.Fatalf("DailyStats() error: %v", err)
+	}
+	want := []store.DailyStats{
+		{Day: yesterday, Burned: 1, Expired: 3},
+		{Day: today, Created: 2, Retrieved: 1, Bytes: 150, TTLSeconds: 3900},
+	}
+	if len(days) != len(want) {
+		t.Fatalf("DailyStats() = %+v, want %+v", days, want)
+	}
+	for i := range want {
+		if !days[i].Day.Equal(want[i].Day) || days[i].Day.Location() != time.UTC {
+			t.Errorf("DailyStats()[%d].Day = %v, want %v", i, days[i].Day, want[i].Day)
+		}
+		days[i].Day = want[i].Day
+		if days[i] != want[i] {
+			t.Errorf("DailyStats()[%d] = %+v, want %+v", i, days[i], want[i])
+		}
+	}
+}
+
+func testDuplicateID(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	now := time.Now()
+
+	// Slugs are as long as 64 characters
+	secret := newSecret(t, time.Hour)
+	secret.ID = strings.Repeat("slug-", 12) + "abcd"
+	create(t, s, secret)
+
+	again := newSecret(t, time.Hour)
+	again.ID = secret.ID
+	if err := s.Create(ctx, again); !errors.Is(err, store.ErrDuplicateID) {
+		t.Fatalf("Create() of a stored ID error = %v, want ErrDuplicateID", err)
+	}
+
+	// Once the secret is gone its ID is free again
+	if _, err := s.Consume(ctx, secret.ID, store.ConsumeOptions{Now: now}); err != nil {
+		t.Fatalf("Consume() error: %v", err)
+	}
+	create(t, s, again)
+	got, err := s.Consume(ctx, again.ID, store.ConsumeOptions{Now: now})
+	if err != nil || !bytes.Equal(got.Ciphertext, again.Ciphertext) {
+		t.Fatalf("Consume() of the reused ID = %v, %v; want the new secret", got, err)
+	}
+}
+
+func testClientScores(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	good, bad, idle := store.HashClientKey("192.0.2.1"), store.HashClientKey("192.0.2.2"), store.HashClientKey("192.0.2.3")
+
+	err := s.SaveClientScores(ctx, []store.ClientScore{
+		{Key: good, Score: 0.9, UpdatedAt: now},
+		{Key: bad, Score: 0.1, UpdatedAt: now},
+		{Key: idle, Score: 0.6, UpdatedAt: now.Add(-48 * time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("SaveClientScores() error: %v", err)
+	}
+
+	// Another replica's older view must not overwrite a newer score
+	err = s.SaveClientScores(ctx, []store.ClientScore{
+		{Key: good, Score: 0.2, UpdatedAt: now.Add(-time.Minute)},
+		{Key: bad, Score: 0.05, UpdatedAt: now.Add(time.Minute)},
+	})
+	if err != nil {
+		t.Fatalf("second SaveClientScores() error: %v", err)
+	}
+
+	scores, err := s.ClientScores(ctx, []string{good, bad, store.HashClientKey("192.0.2.99")})
+	if err != nil {
+		t.Fatalf("ClientScores() error: %v", err)
+	}
+	got := map[string]store.ClientScore{}
+	for _, score := range scores {
+		got[score.Key] = score
+	}
+	if len(got) != 2 || got[good].Score != 0.9 || !got[good].UpdatedAt.Equal(now) || got[bad].Score != 0.05 || !got[bad].UpdatedAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("ClientScores() = %+v, want good at 0.9 and bad at its newer 0.05", scores)
+	}
+	if scores, err := s.ClientScores(ctx, nil); err != nil || len(scores) != 0 {
+		t.Errorf("ClientScores(nil) = %+v, %v; want none", scores, err)
+	}
+
+	lowest, err := s.LowestClientScores(ctx, 2)
+	if err != nil || len(lowest) != 2 || lowest[0].Key != bad || lowest[1].Key != idle {
+		t.Errorf("LowestClientScores(2) = %+v, %v; want bad then idle", lowest, err)
+	}
+
+	if n, err := s.PruneClientScores(ctx, now.Add(-24*time.Hour)); err != nil || n != 1 {
+		t.Fatalf("PruneClientScores() = %d, %v; want 1, nil", n, err)
+	}
+	if scores, err := s.ClientScores(ctx, []string{idle}); err != nil || len(scores) != 0 {
+		t.Errorf("ClientScores() after prune = %+v, %v; want the idle client gone", scores, err)
+	}
+}
+
+func testExpiredIDs(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	now := time.Now()
+
+	read := newSecret(t, time.Minute)
+	create(t, s, read)
+	if _, err := s.Consume(ctx, read.ID, store.ConsumeOptions{Now: now}); err != nil {
+		t.Fatalf("Consume() error: %v", err)
+	}
+
+	expired := newSecret(t, time.Minute)
+	create(t, s, expired)
+	wrapped := newSecret(t, time.Minute)
+	wrapped.DataKey = bytes.Repeat([]byte{0x21}, 32)
+	create(t, s, wrapped)
+	shredded := newSecret(t, time.Minute)
+	shredded.DataKey = bytes.Repeat([]byte{0x22}, 32)
+	create(t, s, shredded)
+	if _, err := s.Burn(ctx, shredded.ID); err != nil {
+		t.Fatalf("Burn() error: %v", err)
+	}
+	held := newSecret(t, time.Minute)
+	held.RequireAck = true
+	create(t, s, held)
+	if _, err := s.Consume(ctx, held.ID, store.ConsumeOptions{
+		Now: now,
+		Ack: &store.AckHold{TokenHash: bytes.Repeat([]byte{0x5A}, 32), Deadline: now.Add(time.Hour)},
+	}); err != nil {
+		t.Fatalf("Consume() with hold error: %v", err)
+	}
+	create(t, s, newSecret(t, time.Hour))
+
+	// Only live secrets past expiry are listed: not the read, shredded,
+	// held or unexpired ones
+	later := now.Add(2 * time.Minute)
+	ids, err := s.ExpiredIDs(ctx, later, 10)
+	if err != nil {
+		t.Fatalf("ExpiredIDs() error: %v", err)
+	}
+	want := []string{expired.ID, wrapped.ID}
+	slices.Sort(want)
+	if !slices.Equal(ids, want) {
+		t.Fatalf("ExpiredIDs() = %v, want %v", ids, want)
+	}
+
+	ids, err = s.ExpiredIDs(ctx, later, 1)
+	if err != nil || len(ids) != 1 || ids[0] != want[0] {
+		t.Fatalf("ExpiredIDs() with limit 1 = %v, %v; want [%s]", ids, err, want[0])
+	}
+}
+
+func testTerminate(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	now := time.Now()
+	sealed := []byte("sealed-address")
+
+	burned := newSecret(t, time.Hour)
+	burned.Namespace = "tenant"
+	burned.NotifyEmail = bytes.Clone(sealed)
+	burned.NotifyEmailHash = store.HashNotifyEmail("alice@example.com")
+	burned.DataKey = bytes.Repeat([]byte{0x31}, 32)
+	burned.ManagementTokenHash = bytes.Repeat([]byte{0x4d}, 32)
+	create(t, s, burned)
+
+	tombstone := &store.Receipt{ConsumedAt: now.UTC().Truncate(time.Microsecond), NetworkClass: "office"}
+	got, err := s.Terminate(ctx, burned.ID, store.TerminationBurned, now, 0, tombstone)
+	if err != nil {
+		t.Fatalf("Terminate() burn error: %v", err)
+	}
+	if got.ID != burned.ID || got.Namespace != "tenant" || !bytes.Equal(got.NotifyEmail, sealed) ||
+		got.ExpiresAt.Sub(burned.ExpiresAt).Abs() > time.Second || got.Ciphertext != nil {
+		t.Errorf("Terminate() = %+v, want the metadata of %s and no payload", got, burned.ID)
+	}
+	receipt, err := s.Receipt(ctx, burned.ID)
+	if err != nil {
+		t.Fatalf("Receipt() of burned secret error: %v", err)
+	}
+	if receipt.Reason != store.TerminationBurned || receipt.NetworkClass != "office" || !receipt.ConsumedAt.Equal(tombstone.ConsumedAt) {
+		t.Errorf("tombstone = %+v, want burned from office at %v", receipt, tombstone.ConsumedAt)
+	}
+	// The tombstone outlives the secret's row, so it keeps the token hash
+	// for the creator's receipt query
+	if !bytes.Equal(receipt.ManagementTokenHash, burned.ManagementTokenHash) || receipt.Country != "" {
+		t.Errorf("tombstone token hash = %x, country %q; want the secret's hash and no country", receipt.ManagementTokenHash, receipt.Country)
+	}
+
+	// An ended secret cannot be ended or read again
+	if _, err := s.Terminate(ctx, burned.ID, store.TerminationBurned, now, 0, tombstone); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("second Terminate() error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Consume(ctx, burned.ID, store.ConsumeOptions{Now: now}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Consume() after Terminate() error = %v, want ErrNotFound", err)
+	}
+
+	// An expiry leaves unexpired secrets alone
+	expiring := newSecret(t, time.Minute)
+	create(t, s, expiring)
+	if _, err := s.Terminate(ctx, expiring.ID, store.TerminationExpired, now, 0, &store.Receipt{ConsumedAt: now}); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Terminate() expiry before expires_at error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Receipt(ctx, expiring.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Receipt() after refused expiry error = %v, want ErrNotFound", err)
+	}
+
+	later := now.Add(2 * time.Minute)
+	if _, err := s.Terminate(ctx, expiring.ID, store.TerminationExpired, later, 0, &store.Receipt{ConsumedAt: later}); err != nil {
+		t.Fatalf("Terminate() expiry error: %v", err)
+	}
+	if receipt, err := s.Receipt(ctx, expiring.ID); err != nil || receipt.Reason != store.TerminationExpired {
+		t.Errorf("Receipt() of expired secret = %+v, %v; want an expired tombstone", receipt, err)
+	}
+
+	// A burn, like a read, finds nothing past the expiry, though the
+	// cleanup worker has not reached it
+	stale := newSecret(t, time.Minute)
+	create(t, s, stale)
+	if _, err := s.Terminate(ctx, stale.ID, store.TerminationBurned, later, 0, &store.Receipt{ConsumedAt: later}); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Terminate() burn past expires_at error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Receipt(ctx, stale.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Receipt() after refused burn error = %v, want ErrNotFound", err)
+	}
+
+	// Reads end through Consume, whose receipt carries the consumed reason
+	read := newSecret(t, time.Hour)
+	read.ManagementTokenHash = bytes.Repeat([]byte{0x52}, 32)
+	create(t, s, read)
+	if _, err := s.Terminate(ctx, read.ID, store.TerminationConsumed, now, 0, nil); err == nil || errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Terminate() as consumed error = %v, want a refusal", err)
+	}
+	if _, err := s.Consume(ctx, read.ID, store.ConsumeOptions{Now: now, Receipt: &store.Receipt{ConsumedAt: now, Country: "NL"}}); err != nil {
+		t.Fatalf("Consume() error: %v", err)
+	}
+	receipt, err = s.Receipt(ctx, read.ID)
+	if err != nil || receipt.Reason != store.TerminationConsumed {
+		t.Fatalf("Receipt() of read secret = %+v, %v; want a consumed receipt", receipt, err)
+	}
+	if !bytes.Equal(receipt.ManagementTokenHash, read.ManagementTokenHash) || receipt.Country != "NL" {
+		t.Errorf("receipt token hash = %x, country %q; want the secret's hash from NL", receipt.ManagementTokenHash, receipt.Country)
+	}
+}
+
+// testRevisions follows a secret's revision through each change: a hold,
+// a clamp, an ending and an acknowledgement each add one, and a burn or ack
+// conditional on any other revision changes nothing
+func testRevisions(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	now := time.Now()
+	tokenHash := bytes.Repeat([]byte{0x6E}, 32)
+	assertRevision := func(id string, want int64) {
+		t.Helper()
+		if got, err := s.Revision(ctx, id); err != nil || got != want {
+			t.Errorf("Revision() = %d, %v; want %d, nil", got, err, want)
+		}
+	}
+	assertReceiptRevision := func(id string, want int64) {
+		t.Helper()
+		if receipt, err := s.Receipt(ctx, id); err != nil || receipt.Revision != want {
+			t.Errorf("Receipt() = %+v, %v; want revision %d", receipt, err, want)
+		}
+	}
+	assertMismatch := func(err error, current int64) {
+		t.Helper()
+		var mismatch *store.RevisionMismatchError
+		if !errors.As(err, &mismatch) || mismatch.Current != current {
+			t.Errorf("error = %v, want a revision mismatch at %d", err, current)
+		}
+	}
+
+	// A burn against a stale revision leaves the secret; a clamp moves it on
+	burned := newSecret(t, time.Hour)
+	create(t, s, burned)
+	assertRevision(burned.ID, store.FirstRevision)
+	_, err := s.Terminate(ctx, burned.ID, store.TerminationBurned, now, 2, &store.Receipt{ConsumedAt: now})
+	assertMismatch(err, 1)
+	if _, err := s.ClampExpiry(ctx, now.Add(30*time.Minute), 10); err != nil {
+		t.Fatalf("ClampExpiry() error: %v", err)
+	}
+	assertRevision(burned.ID, 2)
+	_, err = s.Terminate(ctx, burned.ID, store.TerminationBurned, now, 1, &store.Receipt{ConsumedAt: now})
+	assertMismatch(err, 2)
+	got, err := s.Terminate(ctx, burned.ID, store.TerminationBurned, now, 2, &store.Receipt{ConsumedAt: now})
+	if err != nil || got.Revision != 3 {
+		t.Fatalf("Terminate() at the current revision = %+v, %v; want revision 3", got, err)
+	}
+	assertReceiptRevision(burned.ID, 3)
+	if _, err := s.Revision(ctx, burned.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Revision() after burn error = %v, want ErrNotFound", err)
+	}
+
+	// A read that destroys the secret ends it at the next revision
+	read := newSecret(t, time.Hour)
+	create(t, s, read)
+	got, err = s.Consume(ctx, read.ID, store.ConsumeOptions{Now: now, Receipt: &store.Receipt{ConsumedAt: now}})
+	if err != nil || got.Revision != 2 {
+		t.Fatalf("Consume() = %+v, %v; want revision 2", got, err)
+	}
+	assertReceiptRevision(read.ID, 2)
+
+	// An ack hold is one revision and the acknowledgement another
+	acked := newSecret(t, time.Hour)
+	acked.RequireAck = true
+	acked.DataKey = bytes.Repeat([]byte{0x0C}, 32)
+	create(t, s, acked)
+	got, err = s.Consume(ctx, acked.ID, store.ConsumeOptions{
+		Now:     now,
+		Receipt: &store.Receipt{ConsumedAt: now},
+		Ack:     &store.AckHold{TokenHash: tokenHash, Deadline: now.Add(time.Minute)},
+	})
+	if err != nil || got.Revision != 2 {
+		t.Fatalf("Consume() with ack hold = %+v, %v; want revision 2", got, err)
+	}
+	assertRevision(acked.ID, 2)
+	assertReceiptRevision(acked.ID, 2)
+	assertMismatch(s.Acknowledge(ctx, acked.ID, tokenHash, now, 1), 2)
+	assertAcknowledged(t, s, acked.ID, false)
+	if err := s.Acknowledge(ctx, acked.ID, tokenHash, now, 2); err != nil {
+		t.Fatalf("Acknowledge() at the current revision error: %v", err)
+	}
+	assertAcknowledged(t, s, acked.ID, true)
+	assertReceiptRevision(acked.ID, 3)
+
+	// Burning a secret held for its grace window moves its receipt on
+	graced := newSecret(t, time.Hour)
+	create(t, s, graced)
+	_, err = s.Consume(ctx, graced.ID, store.ConsumeOptions{
+		Now:     now,
+		Receipt: &store.Receipt{ConsumedAt: now},
+		Grace:   &store.GraceHold{TokenHash: tokenHash, Deadline: now.Add(time.Minute)},
+	})
+	if err != nil {
+		t.Fatalf("Consume() with grace hold error: %v", err)
+	}
+	got, err = s.Terminate(ctx, graced.ID, store.TerminationBurned, now, 2, &store.Receipt{ConsumedAt: now})
+	if err != nil || got.Revision != 3 {
+		t.Fatalf("Terminate() of a grace hold = %+v, %v; want revision 3", got, err)
+	}
+	assertReceiptRevision(graced.ID, 3)
+	if receipt, err := s.Receipt(ctx, graced.ID); err != nil || receipt.Reason != store.TerminationConsumed {
+		t.Errorf("Receipt() of a burned grace hold = %+v, %v; want the read's", receipt, err)
+	}
+}
+
+func testDataKeyRewrap(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	newKeyed := func(key byte, version string) *store.Secret {
+		secret := newSecret(t, time.Hour)
+		secret.DataKey = bytes.Repeat([]byte{key}, 32)
+		secret.KeyVersion = version
+		create(t, s, secret)
+		return secret
+	}
+	plain1 := newKeyed(0x01, "")
+	plain2 := newKeyed(0x02, "")
+	current := newKeyed(0x03, "v2")
+	burned := newKeyed(0x04, "")
+	create(t, s, newSecret(t, time.Hour))
+	if ok, err := s.Burn(ctx, burned.ID); err != nil || !ok {
+		t.Fatalf("Burn() = %v, %v; want burned", ok, err)
+	}
+
+	versions, err := s.DataKeyVersions(ctx)
+	if err != nil {
+		t.Fatalf("DataKeyVersions() error: %v", err)
+	}
+	want := []store.KeyVersionCount{{Version: "", DataKeys: 2}, {Version: "v2", DataKeys: 1}}
+	if !reflect.DeepEqual(versions, want) {
+		t.Errorf("DataKeyVersions() = %+v, want %+v", versions, want)
+	}
+
+	// Stale keys come in ID order, one page at a time; shredded and current
+	// ones are left out
+	var stale []store.StoredDataKey
+	for after := ""; ; {
+		page, err := s.StaleDataKeys(ctx, "v2", after, 1)
+		if err != nil {
+			t.Fatalf("StaleDataKeys() error: %v", err)
+		}
+		if len(page) > 1 {
+			t.Fatalf("StaleDataKeys() returned %d keys, want at most the limit 1", len(page))
+		}
+		if len(page) == 0 {
+			break
+		}
+		stale = append(stale, page...)
+		after = page[0].SecretID
+	}
+	wantIDs := []string{plain1.ID, plain2.ID}
+	slices.Sort(wantIDs)
+	if len(stale) != 2 || stale[0].SecretID != wantIDs[0] || stale[1].SecretID != wantIDs[1] {
+		t.Fatalf("StaleDataKeys() = %+v, want %v", stale, wantIDs)
+	}
+	if stale[0].Version != "" || len(stale[0].Key) != 32 {
+		t.Errorf("StaleDataKeys() key = %+v, want the stored key without a version", stale[0])
+	}
+
+	// A replacement applies once; the old key no longer matches after it
+	rewrapped := bytes.Repeat([]byte{0x11}, 48)
+	for _, key := range stale {
+		if ok, err := s.ReplaceDataKey(ctx, key, rewrapped, "v2"); err != nil || !ok {
+			t.Fatalf("ReplaceDataKey(%s) = %v, %v; want replaced", key.SecretID, ok, err)
+		}
+		if ok, err := s.ReplaceDataKey(ctx, key, bytes.Repeat([]byte{0x12}, 48), "v3"); err != nil || ok {
+			t.Errorf("ReplaceDataKey(%s) with a stale key = %v, %v; want no change", key.SecretID, ok, err)
+		}
+	}
+	if ok, err := s.ReplaceDataKey(ctx, store.StoredDataKey{SecretID: burned.ID, Key: burned.DataKey}, rewrapped, "v2"); err != nil || ok {
+		t.Errorf("ReplaceDataKey() of a shredded key = %v, %v; want no change", ok, err)
+	}
+
+	got, err := s.Consume(ctx, plain1.ID, store.ConsumeOptions{Now: time.Now()})
+	if err != nil {
+		t.Fatalf("Consume() error: %v", err)
+	}
+	if !bytes.Equal(got.DataKey, rewrapped) || got.KeyVersion != "v2" {
+		t.Errorf("Consume() data key = %x under %q, want %x under v2", []byte(got.DataKey), got.KeyVersion, rewrapped)
+	}
+	got, err = s.Consume(ctx, current.ID, store.ConsumeOptions{Now: time.Now()})
+	if err != nil || !bytes.Equal(got.DataKey, current.DataKey) || got.KeyVersion != "v2" {
+		t.Errorf("Consume() untouched key = %+v, %v; want it as created", got, err)
+	}
+
+	versions, err = s.DataKeyVersions(ctx)
+	if err != nil {
+		t.Fatalf("DataKeyVersions() error: %v", err)
+	}
+	if want := []store.KeyVersionCount{{Version: "v2", DataKeys: 1}}; !reflect.DeepEqual(versions, want) {
+		t.Errorf("DataKeyVersions() after rewrap = %+v, want %+v", versions, want)
+	}
+}
+
+func testActiveSecrets(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	now := time.Now()
+
+	full := newSecret(t, time.Hour)
+	full.Salt = []byte("salt-salt-salt-!")
+	full.Ciphertext = []byte{}
+	full.IV = []byte{}
+	full.Parts = []store.Part{
+		{Label: "user", Ciphertext: []byte("part-1"), IV: bytes.Repeat([]byte{0x01}, 12)},
+		{Label: "pass", Ciphertext: []byte("part-2"), IV: bytes.Repeat([]byte{0x02}, 12)},
+	}
+	full.DataKey = bytes.Repeat([]byte{0x42}, 32)
+	full.KeyVersion = "v1"
+	bits := 256
+	full.DeclaredKeyBits = &bits
+	full.ManagementTokenHash = bytes.Repeat([]byte{0x07}, 32)
+	full.RequireAck = true
+	full.Namespace = "team-a"
+	later := now.Add(30 * time.Minute).Truncate(time.Microsecond)
+	full.AvailableAfter = &later
+	full.Hint = "db password"
+	full.ContentType = "text/plain"
+	full.Filename = "notes.txt"
+	create(t, s, full)
+
+	plain := newSecret(t, time.Hour)
+	create(t, s, plain)
+
+	// Expired, held, shredded and canary secrets are not active
+	expired := newSecret(t, time.Minute)
+	create(t, s, expired)
+	held := newSecret(t, time.Hour)
+	held.RequireAck = true
+	create(t, s, held)
+	if _, err := s.Consume(ctx, held.ID, store.ConsumeOptions{
+		Now: now,
+		Ack: &store.AckHold{TokenHash: bytes.Repeat([]byte{0x5A}, 32), Deadline: now.Add(time.Minute)},
+	}); err != nil {
+		t.Fatalf("Consume() held error: %v", err)
+	}
+	shredded := newSecret(t, time.Hour)
+	shredded.DataKey = bytes.Repeat([]byte{0x43}, 32)
+	create(t, s, shredded)
+	if _, err := s.Consume(ctx, shredded.ID, store.ConsumeOptions{Now: now}); err != nil {
+		t.Fatalf("Consume() shredded error: %v", err)
+	}
+	canary := newSecret(t, time.Hour)
+	canary.ID = store.CanaryIDPrefix + canary.ID
+	create(t, s, canary)
+
+	var active []store.Secret
+	for after := ""; ; {
+		page, err := s.ActiveSecrets(ctx, now.Add(2*time.Minute), after, 1)
+		if err != nil {
+			t.Fatalf("ActiveSecrets() error: %v", err)
+		}
+		if len(page) > 1 {
+			t.Fatalf("ActiveSecrets() returned %d secrets, want at most the limit 1", len(page))
+		}
+		if len(page) == 0 {
+			break
+		}
+		active = append(active, page...)
+		after = page[0].ID
+	}
+
+	wantIDs := []string{full.ID, plain.ID}
+	slices.Sort(wantIDs)
+	if len(active) != 2 || active[0].ID != wantIDs[0] || active[1].ID != wantIDs[1] {
+		t.Fatalf("ActiveSecrets() IDs = %v, want %v", secretIDs(active), wantIDs)
+	}
+
+	got := active[0]
+	if got.ID != full.ID {
+		got = active[1]
+	}
+	if len(got.Parts) != 2 || got.Parts[1].Label != "pass" || !bytes.Equal(got.Parts[1].Ciphertext, []byte("part-2")) {
+		t.Errorf("ActiveSecrets() parts = %+v, want both parts in order", got.Parts)
+	}
+	if !bytes.Equal(got.DataKey, full.DataKey) || got.KeyVersion != "v1" || !bytes.Equal(got.Salt, full.Salt) {
+		t.Errorf("ActiveSecrets() key or salt = %x under %q, salt %q; want them as created", []byte(got.DataKey), got.KeyVersion, got.Salt)
+	}
+	if got.DeclaredKeyBits == nil || *got.DeclaredKeyBits != 256 || !bytes.Equal(got.ManagementTokenHash, full.ManagementTokenHash) {
+		t.Errorf("ActiveSecrets() key bits or token hash differ: %+v", got)
+	}
+	if !got.RequireAck || !got.BurnAfterRead || got.Namespace != "team-a" || got.Hint != "db password" ||
+		got.ContentType != "text/plain" || got.Filename != "notes.txt" {
+		t.Errorf("ActiveSecrets() flags or metadata differ: %+v", got)
+	}
+	if got.AvailableAfter == nil || !got.AvailableAfter.Equal(later) {
+		t.Errorf("ActiveSecrets() available_after = %v, want %v", got.AvailableAfter, later)
+	}
+	if !got.ExpiresAt.Equal(full.ExpiresAt.Truncate(time.Microsecond)) && !got.ExpiresAt.Equal(full.ExpiresAt) {
+		t.Errorf("ActiveSecrets() expires_at = %v, want %v", got.ExpiresAt, full.ExpiresAt)
+	}
+}
+
+func secretIDs(secrets []store.Secret) []string {
+	ids := make([]string, len(secrets))
+	for i := range secrets {
+		ids[i] = secrets[i].ID
+	}
+	return ids
+}
@@ -0,0 +1,158 @@
+// Package terminate ends secrets. A read, a burn and an expiry all go
+// through Terminator.Terminate, so each leaves the same trail: a tombstone
+// in the store, an audit event, a daily count, an in-process count, a
+// notice to a creator who asked for one and an event for anyone watching.
+package terminate
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"ots-backend/internal/clock"
+	"ots-backend/internal/dropped"
+	"ots-backend/internal/events"
+	"ots-backend/internal/logger"
+	"ots-backend/internal/notify"
+	"ots-backend/internal/store"
+	"ots-backend/internal/ulid"
+	"ots-backend/pkg/webhook"
+)
+
+// trail is what an ending of each reason records
+type trail struct {
+	audit  string
+	notice string
+	daily  store.DailyStats
+	event  events.Type
+	count  *atomic.Int64
+}
+
+// counts has one counter per reason, so counting an ending takes no lock
+var counts struct {
+	consumed, burned, expired atomic.Int64
+}
+
+var trails = map[store.TerminationReason]trail{
+	store.TerminationConsumed: {store.AuditSecretConsumed, webhook.EventConsumed, store.DailyStats{Retrieved: 1}, events.Read, &counts.consumed},
+	store.TerminationBurned:   {store.AuditSecretBurned, webhook.EventBurned, store.DailyStats{Burned: 1}, events.Burned, &counts.burned},
+	store.TerminationExpired:  {store.AuditSecretExpired, webhook.EventExpired, store.DailyStats{Expired: 1}, events.Expired, &counts.expired},
+}
+
+// Count returns how many secrets this process has ended for reason
+func Count(reason store.TerminationReason) int64 {
+	if trail, ok := trails[reason]; ok {
+		return trail.count.Load()
+	}
+	return 0
+}
+
+// Terminator ends secrets in its store. The zero value of every field but
+// Store is usable.
+type Terminator struct {
+	Store store.Store
+	// Clock stamps the tombstone, audit event and notice; nil is the
+	// system clock
+	Clock clock.Clock
+	// Notifier tells creators that set notify_email; nil sends nothing
+	Notifier *notify.Service
+	// AuditIDs issues audit event IDs; nil uses a fresh generator
+	AuditIDs *ulid.Generator
+	// SkipAudit leaves the audit log alone, for servers that disable it
+	SkipAudit bool
+	// Events tells this process's watchers of the secret; nil tells no one
+	Events *events.Hub
+}
+
+// Options carries what an ending needs besides its reason
+type Options struct {
+	// NetworkClass labels the tombstone and audit event
+	NetworkClass string
+	// Country is the reader's coarse country for the tombstone only; the
+	// audit event never carries it
+	Country string
+	// Consume configures a read; only TerminationConsumed uses it. Its Now
+	// and Receipt are set by Terminate.
+	Consume store.ConsumeOptions
+	// IfRevision makes a burn or expiry conditional on the secret's
+	// revision, as Store.Terminate takes it; zero for none
+	IfRevision int64
+}
+
+// Terminate ends id for reason and records its trail. A read goes through
+// Store.Consume and returns the secret's payload; a burn or expiry goes
+// through Store.Terminate and returns its metadata. Nothing to end reports
+// the store's error, usually store.ErrNotFound, and records nothing.
+func (t *Terminator) Terminate(ctx context.Context, id string, reason store.TerminationReason, opts Options) (*store.Secret, error) {
+	trail, ok := trails[reason]
+	if !ok {
+		return nil, fmt.Errorf("cannot terminate a secret as %q", reason)
+	}
+
+	now := t.now()
+	tombstone := &store.Receipt{ConsumedAt: now.UTC(), NetworkClass: opts.NetworkClass, Country: opts.Country}
+
+	var secret *store.Secret
+	var err error
+	if reason == store.TerminationConsumed {
+		consume := opts.Consume
+		consume.Now = now
+		consume.Receipt = tombstone
+		secret, err = t.Store.Consume(ctx, id, consume)
+	} else {
+		secret, err = t.Store.Terminate(ctx, id, reason, now, opts.IfRevision, tombstone)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	t.record(ctx, id, secret, trail, opts.NetworkClass, now)
+
+	trail.count.Add(1)
+	return secret, nil
+}
+
+// record writes the audit event, daily count, notice and event of an ending.
+// Failures are logged and counted as dropped; the secret is gone either way.
+func (t *Terminator) record(ctx context.Context, id string, secret *store.Secret, trail trail, networkClass string, now time.Time) {
+	if !t.SkipAudit {
+		ids := t.AuditIDs
+		if ids == nil {
+			ids = new(ulid.Generator)
+		}
+		err := t.Store.RecordAudit(ctx, &store.AuditEvent{
+			ID:           ids.New(now.UTC()),
+			Type:         trail.audit,
+			OccurredAt:   now.UTC(),
+			Namespace:    secret.Namespace,
+			SecretIDHash: store.HashSecretID(id),
+			NetworkClass: networkClass,
+		})
+		if err != nil {
+			reason := dropped.Reason(ctx, err)
+			dropped.Record(dropped.KindAuditEvent, reason)
+			logger.Warn("failed to record audit event", "error", err, "type", trail.audit, "reason", reason)
+		}
+	}
+
+	delta := trail.daily
+	delta.Day = now
+	if err := t.Store.AddDailyStats(ctx, delta); err != nil {
+		logger.Warn("failed to record daily stats", "error", err)
+	}
+
+	if t.Notifier != nil && secret.NotifyEmail != nil {
+		t.Notifier.Send(trail.notice, id, now, secret.NotifyEmail)
+	}
+
+	at := now.UTC()
+	t.Events.Publish(id, events.Event{Type: trail.event, At: &at, Revision: secret.Revision})
+}
+
+func (t *Terminator) now() time.Time {
+	if t.Clock == nil {
+		return clock.System.Now()
+	}
+	return t.Clock.Now()
+}
@@ -0,0 +1,171 @@
+package terminate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ots-backend/internal/events"
+	"ots-backend/internal/notify"
+	"ots-backend/internal/store"
+	"ots-backend/internal/store/memory"
+	"ots-backend/internal/testutil"
+	"ots-backend/pkg/webhook"
+)
+
+// recorder is a notify channel that hands each event to a test
+type recorder chan notify.Event
+
+func (r recorder) Notify(_ context.Context, e notify.Event) error {
+	r <- e
+	return nil
+}
+
+// TestTerminateLeavesTheSameTrail ends a secret for each reason and checks
+// all three leave a tombstone, an audit event, a daily count, an
+// in-process count, a notice and an event, differing only in their reason
+func TestTerminateLeavesTheSameTrail(t *testing.T) {
+	ctx := context.Background()
+
+	notices := make(recorder, 10)
+	d := notify.NewDispatcher(10)
+	d.Add("recorder", notices)
+	notifier := notify.NewService(bytes.Repeat([]byte{0x42}, 32), d)
+	notifier.Start(ctx)
+	defer notifier.Stop()
+
+	for _, tt := range []struct {
+		reason store.TerminationReason
+		audit  string
+		notice string
+		daily  func(store.DailyStats) int64
+		event  events.Type
+	}{
+		{store.TerminationConsumed, store.AuditSecretConsumed, webhook.EventConsumed, func(d store.DailyStats) int64 { return d.Retrieved }, events.Read},
+		{store.TerminationBurned, store.AuditSecretBurned, webhook.EventBurned, func(d store.DailyStats) int64 { return d.Burned }, events.Burned},
+		{store.TerminationExpired, store.AuditSecretExpired, webhook.EventExpired, func(d store.DailyStats) int64 { return d.Expired }, events.Expired},
+	} {
+		t.Run(string(tt.reason), func(t *testing.T) {
+			secrets := memory.New()
+			clk := testutil.NewFakeClock(time.Date(2026, 5, 14, 9, 30, 0, 0, time.UTC))
+			now := clk.Now()
+
+			sealed, hash, err := notifier.Seal("alice@example.com")
+			if err != nil {
+				t.Fatalf("Seal() error: %v", err)
+			}
+			secret := &store.Secret{ID: "secret-" + string(tt.reason), Namespace: "tenant", Ciphertext: []byte("x"), IV: []byte("iv"),
+				CreatedAt: now, ExpiresAt: now.Add(time.Minute), NotifyEmail: sealed, NotifyEmailHash: hash}
+			if err := secrets.Create(ctx, secret); err != nil {
+				t.Fatalf("Create() error: %v", err)
+			}
+			if tt.reason == store.TerminationExpired {
+				clk.Advance(2 * time.Minute)
+				now = clk.Now()
+			}
+
+			hub := events.NewHub()
+			watch, stop := hub.Watch(secret.ID)
+			defer stop()
+
+			before := Count(tt.reason)
+			terminator := &Terminator{Store: secrets, Clock: clk, Notifier: notifier, Events: hub}
+			got, err := terminator.Terminate(ctx, secret.ID, tt.reason, Options{NetworkClass: "office"})
+			if err != nil {
+				t.Fatalf("Terminate() error: %v", err)
+			}
+			if got.ID != secret.ID || got.Namespace != "tenant" {
+				t.Errorf("Terminate() = %+v, want %s in tenant", got, secret.ID)
+			}
+
+			receipt, err := secrets.Receipt(ctx, secret.ID)
+			if err != nil {
+				t.Fatalf("Receipt() error: %v", err)
+			}
+			if receipt.Reason != tt.reason || receipt.NetworkClass != "office" || !receipt.ConsumedAt.Equal(now) {
+				t.Errorf("tombstone = %+v, want %s from office at %v", receipt, tt.reason, now)
+			}
+
+			var audit []*store.AuditEvent
+			err = secrets.ScanAudit(ctx, store.AuditFilter{}, func(e *store.AuditEvent) error {
+				audit = append(audit, e)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("ScanAudit() error: %v", err)
+			}
+			if len(audit) != 1 || audit[0].Type != tt.audit || audit[0].Namespace != "tenant" ||
+				audit[0].SecretIDHash != store.HashSecretID(secret.ID) || audit[0].NetworkClass != "office" || !audit[0].OccurredAt.Equal(now) {
+				t.Errorf("audit events = %+v, want one %s for the secret", audit, tt.audit)
+			}
+
+			days, err := secrets.DailyStats(ctx, now, now)
+			if err != nil {
+				t.Fatalf("DailyStats() error: %v", err)
+			}
+			if len(days) != 1 || tt.daily(days[0]) != 1 {
+				t.Errorf("daily stats = %+v, want one %s", days, tt.reason)
+			}
+
+			if n := Count(tt.reason) - before; n != 1 {
+				t.Errorf("Count(%s) grew by %d, want 1", tt.reason, n)
+			}
+
+			select {
+			case e := <-watch:
+				if e.Type != tt.event || e.At == nil || !e.At.Equal(now) {
+					t.Errorf("event = %+v, want %s at %v", e, tt.event, now)
+				}
+			default:
+				t.Errorf("no %s event published", tt.event)
+			}
+
+			select {
+			case e := <-notices:
+				if e.Type != tt.notice || e.SecretID != secret.ID || e.Email != "alice@example.com" || !e.OccurredAt.Equal(now) {
+					t.Errorf("notice = %+v, want %s to alice@example.com", e, tt.notice)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("no %s notice sent", tt.notice)
+			}
+
+			// The secret is gone, and ending it again leaves no second trail
+			if _, err := terminator.Terminate(ctx, secret.ID, tt.reason, Options{}); !errors.Is(err, store.ErrNotFound) {
+				t.Errorf("second Terminate() error = %v, want ErrNotFound", err)
+			}
+			if n := Count(tt.reason) - before; n != 1 {
+				t.Errorf("Count(%s) after a miss grew by %d, want 1", tt.reason, n)
+			}
+			if len(watch) != 0 {
+				t.Errorf("a miss published %d more events, want none", len(watch))
+			}
+		})
+	}
+}
+
+func TestTerminateSkipsAuditWhenDisabled(t *testing.T) {
+	ctx := context.Background()
+	secrets := memory.New()
+	now := time.Now()
+	if err := secrets.Create(ctx, &store.Secret{ID: "burned", Ciphertext: []byte("x"), IV: []byte("iv"), CreatedAt: now, ExpiresAt: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	terminator := &Terminator{Store: secrets, SkipAudit: true}
+	if _, err := terminator.Terminate(ctx, "burned", store.TerminationBurned, Options{}); err != nil {
+		t.Fatalf("Terminate() error: %v", err)
+	}
+
+	err := secrets.ScanAudit(ctx, store.AuditFilter{}, func(e *store.AuditEvent) error {
+		t.Errorf("audit event %+v recorded with the audit log off", e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ScanAudit() error: %v", err)
+	}
+	if _, err := secrets.Receipt(ctx, "burned"); err != nil {
+		t.Errorf("Receipt() error = %v, want the tombstone kept", err)
+	}
+}
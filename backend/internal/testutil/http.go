@@ -0,0 +1,15 @@
+package testutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+// Do serves one request from remoteAddr through h and returns the response
+func Do(h http.Handler, method, path, remoteAddr string) *http.Response {
+	request := httptest.NewRequest(method, path, nil)
+	request.RemoteAddr = remoteAddr
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, request)
+	return recorder.Result()
+}
@@ -0,0 +1,151 @@
+package testutil
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Mail is one message an SMTPServer accepted
+type Mail struct {
+	From string
+	To   []string
+	// Data is the message as sent, headers and body, with CRLF line ends
+	Data string
+}
+
+// SMTPServer is a minimal plaintext SMTP server on the loopback interface.
+// It accepts any sender and recipient unless RejectRcpt is set.
+type SMTPServer struct {
+	// Addr is the host:port it listens on
+	Addr string
+
+	listener net.Listener
+	received chan Mail
+
+	mu sync.Mutex
+	// rejectRcpt is the reply code for every RCPT, zero to accept
+	rejectRcpt int
+}
+
+// NewSMTPServer starts a server that stops when t ends
+func NewSMTPServer(t *testing.T) *SMTPServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen for smtp: %v", err)
+	}
+	s := &SMTPServer{Addr: listener.Addr().String(), listener: listener, received: make(chan Mail, 100)}
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+// RejectRcpt makes every RCPT fail with code, echoing the recipient the way
+// real servers do; zero accepts again
+func (s *SMTPServer) RejectRcpt(code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejectRcpt = code
+}
+
+// Next waits up to timeout for the next accepted message
+func (s *SMTPServer) Next(timeout time.Duration) (Mail, bool) {
+	select {
+	case m := <-s.received:
+		return m, true
+	case <-time.After(timeout):
+		return Mail{}, false
+	}
+}
+
+func (s *SMTPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+// handle speaks just enough SMTP for net/smtp's client
+func (s *SMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	reply := func(code int, msg string) { text.PrintfLine("%d %s", code, msg) }
+
+	reply(220, "localhost ESMTP test")
+	var mail Mail
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			text.PrintfLine("250-localhost")
+			reply(250, "8BITMIME")
+		case "HELO", "NOOP":
+			reply(250, "OK")
+		case "MAIL":
+			mail = Mail{From: address(arg)}
+			reply(250, "OK")
+		case "RCPT":
+			s.mu.Lock()
+			code := s.rejectRcpt
+			s.mu.Unlock()
+			if code != 0 {
+				reply(code, fmt.Sprintf("<%s>: Recipient address rejected", address(arg)))
+				continue
+			}
+			mail.To = append(mail.To, address(arg))
+			reply(250, "OK")
+		case "DATA":
+			reply(354, "End data with <CR><LF>.<CR><LF>")
+			data, err := readData(text.R)
+			if err != nil {
+				return
+			}
+			s.received <- Mail{From: mail.From, To: mail.To, Data: data}
+			reply(250, "OK: queued")
+		case "RSET":
+			mail = Mail{}
+			reply(250, "OK")
+		case "QUIT":
+			reply(221, "Bye")
+			return
+		default:
+			reply(502, "Command not implemented")
+		}
+	}
+}
+
+// address strips "FROM:<...>" or "TO:<...>" down to the address
+func address(arg string) string {
+	_, value, _ := strings.Cut(arg, ":")
+	value, _, _ = strings.Cut(value, " ")
+	return strings.Trim(value, "<>")
+}
+
+// readData reads a DATA section up to its lone dot, undoing dot stuffing
+func readData(r *bufio.Reader) (string, error) {
+	var b strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if line == ".\r\n" {
+			return b.String(), nil
+		}
+		b.WriteString(strings.TrimPrefix(line, "."))
+	}
+}
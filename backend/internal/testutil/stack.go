@@ -0,0 +1,53 @@
+package testutil
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+
+	httpMiddleware "ots-backend/internal/middleware"
+)
+
+// Limit configures the rate limiter in a Stack
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// Stack is the server's HTTP middleware chain with every time-dependent
+// component driven by one fake clock and all state held in memory
+type Stack struct {
+	Clock   *FakeClock
+	Limiter *httpMiddleware.RateLimiter
+	Router  chi.Router
+}
+
+// NewStack builds the middleware chain the server installs in front of the
+// secret routes: request IDs, client IP resolution, security headers, panic
+// recovery, no-store and the per-IP rate limiter. observers go right after
+// panic recovery, where the server counts requests for its metrics; this
+// package cannot import the API's. Mount handlers on Router.
+func NewStack(clk *FakeClock, limit Limit, observers ...func(http.Handler) http.Handler) *Stack {
+	limiter := httpMiddleware.NewRateLimiter(httpMiddleware.RateLimiterOptions{
+		Limit: func() (int, time.Duration) { return limit.Requests, limit.Window },
+		Clock: clk,
+	})
+
+	r := chi.NewRouter()
+	r.Use(chimiddleware.RequestID)
+	r.Use(httpMiddleware.RealIP)
+	r.Use(httpMiddleware.SecurityHeaders)
+	r.Use(chimiddleware.Recoverer)
+	r.Use(observers...)
+	r.Use(httpMiddleware.NoStore)
+	r.Use(limiter.Handler)
+
+	return &Stack{Clock: clk, Limiter: limiter, Router: r}
+}
+
+// Do serves one request from remoteAddr and returns the recorded response
+func (s *Stack) Do(method, path, remoteAddr string) *http.Response {
+	return Do(s.Router, method, path, remoteAddr)
+}
@@ -0,0 +1,62 @@
+package testutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RunMainEnv tells a command's test binary to run main instead of its
+// tests; see RunMain
+const RunMainEnv = "OTS_TEST_RUN_MAIN"
+
+// StartupFailure is the final line a command writes when it fails to start
+type StartupFailure struct {
+	Stage    string `json:"failure_stage"`
+	Code     string `json:"failure_code"`
+	Detail   string `json:"detail"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// RunMain reruns the test binary as the command under test with only env
+// set, and returns its exit status and final stderr line. The package's
+// TestMain must call main when RunMainEnv is set. The test fails if the
+// command runs on past timeout, or if it wrote anything but exactly one
+// failure line, last.
+func RunMain(t *testing.T, timeout time.Duration, env ...string) (int, StartupFailure) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=^$")
+	cmd.Env = append([]string{RunMainEnv + "=1"}, env...)
+	cmd.Dir = t.TempDir()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		t.Fatalf("command still running after %v; stderr:\n%s", timeout, stderr.String())
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		t.Fatalf("run command: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+	if n := strings.Count(stderr.String(), `"failure_stage"`); n != 1 {
+		t.Fatalf("stderr holds %d failure lines, want 1:\n%s", n, stderr.String())
+	}
+	var failure StartupFailure
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &failure); err != nil || failure.Stage == "" {
+		t.Fatalf("last stderr line %q is not a failure line: %v", lines[len(lines)-1], err)
+	}
+	return cmd.ProcessState.ExitCode(), failure
+}
@@ -0,0 +1,139 @@
+// Package tracing sends OpenTelemetry traces of request handling, store
+// transactions and cleanup passes to an OTLP collector. Tracing is off
+// unless OTEL_EXPORTER_OTLP_ENDPOINT is set. While it is off, Middleware
+// returns its handler unwrapped and Start hands back the context it was
+// given with a span that records nothing, so instrumented code allocates
+// nothing extra.
+//
+// Secret IDs are bearer credentials: spans carry at most their first
+// SecretIDPrefixLen characters, and request paths are cut the same way
+// before the HTTP instrumentation sees them.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName names this service in exported traces
+const ServiceName = "ots-backend"
+
+// SecretIDPrefixLen is how much of a secret ID a span may carry
+const SecretIDPrefixLen = 6
+
+// Attribute keys this service adds to spans
+const (
+	SecretIDPrefixKey = attribute.Key("ots.secret_id_prefix")
+	RequestIDKey      = attribute.Key("ots.request_id")
+)
+
+// state is the installed provider and its tracer; nil while tracing is off
+type state struct {
+	provider trace.TracerProvider
+	tracer   trace.Tracer
+}
+
+var current atomic.Pointer[state]
+
+// noSpan is the span Start returns while tracing is off
+var noSpan = trace.SpanFromContext(context.Background())
+
+// Setup installs an OTLP/HTTP exporter sending to endpoint, a URL such as
+// http://tempo:4318. An empty endpoint leaves tracing off. The returned
+// function flushes buffered spans and stops the exporter.
+func Setup(ctx context.Context, endpoint, instanceID string) (func(context.Context) error, error) {
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	parsed, err := url.Parse(endpoint)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("OTEL_EXPORTER_OTLP_ENDPOINT %q is not an http or https URL", endpoint)
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, err
+	}
+	attrs := []attribute.KeyValue{attribute.String("service.name", ServiceName)}
+	if instanceID != "" {
+		attrs = append(attrs, attribute.String("service.instance.id", instanceID))
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attrs...)),
+	)
+
+	SetProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// SetProvider turns tracing on with provider, or off when provider is nil.
+// Middleware built before the call keeps the setting it was built with.
+func SetProvider(provider trace.TracerProvider) {
+	if provider == nil {
+		current.Store(nil)
+		return
+	}
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	current.Store(&state{provider: provider, tracer: provider.Tracer(ServiceName)})
+}
+
+// Enabled reports whether spans are being recorded
+func Enabled() bool {
+	return current.Load() != nil
+}
+
+// Start starts a span named name as a child of ctx's span
+func Start(ctx context.Context, name string) (context.Context, trace.Span) {
+	s := current.Load()
+	if s == nil {
+		return ctx, noSpan
+	}
+	return s.tracer.Start(ctx, name)
+}
+
+// End ends span, marking it failed when err is set and is none of
+// expected, the errors that answer a request rather than fail it
+func End(span trace.Span, err error, expected ...error) {
+	if err != nil && span.IsRecording() {
+		failed := true
+		for _, target := range expected {
+			if errors.Is(err, target) {
+				failed = false
+			}
+		}
+		if failed {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+	span.End()
+}
+
+// SetSecretID records the first SecretIDPrefixLen characters of id on span
+func SetSecretID(span trace.Span, id string) {
+	if span.IsRecording() {
+		span.SetAttributes(SecretIDPrefixKey.String(SecretIDPrefix(id)))
+	}
+}
+
+// SecretIDPrefix returns as much of id as a span may carry
+func SecretIDPrefix(id string) string {
+	if len(id) > SecretIDPrefixLen {
+		return id[:SecretIDPrefixLen]
+	}
+	return id
+}
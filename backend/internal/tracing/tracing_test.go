@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// nopHandler is comparable, so a test can tell whether Middleware wrapped it
+type nopHandler struct{}
+
+func (*nopHandler) ServeHTTP(http.ResponseWriter, *http.Request) {}
+
+func TestDisabledCostsNothing(t *testing.T) {
+	SetProvider(nil)
+
+	next := &nopHandler{}
+	if got := Middleware(next); got != http.Handler(next) {
+		t.Error("Middleware() wrapped the handler with tracing off")
+	}
+
+	ctx := context.Background()
+	errMissing := errors.New("missing")
+	allocs := testing.AllocsPerRun(100, func() {
+		spanCtx, span := Start(ctx, "store.create")
+		SetSecretID(span, "abcdefghijklmnop")
+		End(span, errMissing, errMissing)
+		if spanCtx != ctx {
+			t.Fatal("Start() changed the context with tracing off")
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("a span with tracing off allocates %v times, want 0", allocs)
+	}
+}
+
+func TestRedactPath(t *testing.T) {
+	tests := map[string]string{
+		"/api/secrets/01HZXK7Q8M2N3P4R5S6T7V8W9X":               "/api/secrets/01HZXK",
+		"/api/secrets/01HZXK7Q8M2N3P4R5S6T7V8W9X/ack":           "/api/secrets/01HZXK/ack",
+		"/api/admin/secrets/01HZXK7Q8M2N3P4R5S6T7V8W9X/dossier": "/api/admin/secrets/01HZXK/dossier",
+		"/api/agent/secrets":                                    "/api/agent/secrets",
+		"/api/secrets/":                                         "/api/secrets/",
+		"/api/secrets/abc":                                      "/api/secrets/abc",
+		"/api/admin/namespaces/team-a/stats":                    "/api/admin/namespaces/team-a/stats",
+	}
+	for path, want := range tests {
+		if got := RedactPath(path); got != want {
+			t.Errorf("RedactPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestSetupEndpoint(t *testing.T) {
+	shutdown, err := Setup(context.Background(), "", "")
+	if err != nil || shutdown(context.Background()) != nil || Enabled() {
+		t.Errorf("Setup() without an endpoint = %v, enabled %v; want tracing off", err, Enabled())
+	}
+
+	for _, endpoint := range []string{"tempo:4318", "ftp://tempo:4318", "http://"} {
+		if _, err := Setup(context.Background(), endpoint, ""); err == nil {
+			t.Errorf("Setup(%q) error = nil, want one", endpoint)
+		}
+	}
+}
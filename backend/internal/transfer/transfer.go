@@ -0,0 +1,315 @@
+// Package transfer moves the active secrets of one deployment to another
+// without invalidating their links. An export is NDJSON: a plaintext header
+// line naming the format and key derivation salt, then one line per secret
+// and a closing trailer, each sealed with crypto.ExportCipher and base64
+// encoded. Secrets are exported as stored, so wrapped data keys and sealed
+// notify addresses only open on a target with the same ENVELOPE_KEYS and
+// NOTIFY_EMAIL_KEY.
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"ots-backend/internal/crypto"
+	"ots-backend/internal/store"
+)
+
+// Format names the export format in the header line
+const Format = "ots-export"
+
+// Version is the export format version
+const Version = 1
+
+// KDF names the key derivation in the header line
+const KDF = "PBKDF2-HMAC-SHA256"
+
+// pageSize is how many secrets are read from the store at a time, which
+// bounds the memory an export holds whatever the size of the store
+const pageSize = 256
+
+// ErrTruncated indicates an export that ends before its trailer. The
+// secrets before the cut were imported.
+var ErrTruncated = errors.New("export is truncated")
+
+// header is the first, unencrypted line of an export
+type header struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Salt    []byte `json:"salt"`
+}
+
+// entry is one sealed line: a secret, or the trailer closing the export
+type entry struct {
+	Secret *record `json:"secret,omitempty"`
+	End    *end    `json:"end,omitempty"`
+}
+
+// end counts the secrets before it, so an import can tell a complete
+// export from a cut one
+type end struct {
+	Count int64 `json:"count"`
+}
+
+// record is a secret as exported. Byte fields are plain []byte because
+// sensitive.Bytes redacts itself in JSON.
+type record struct {
+	ID                  string     `json:"id"`
+	Ciphertext          []byte     `json:"ciphertext"`
+	IV                  []byte     `json:"iv"`
+	Salt                []byte     `json:"salt,omitempty"`
+	Parts               []part     `json:"parts,omitempty"`
+	ExpiresAt           time.Time  `json:"expires_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+	Flags               flags      `json:"flags"`
+	DataKey             []byte     `json:"data_key,omitempty"`
+	KeyVersion          string     `json:"key_version,omitempty"`
+	DeclaredKeyBits     *int       `json:"declared_key_bits,omitempty"`
+	ManagementTokenHash []byte     `json:"management_token_hash,omitempty"`
+	Namespace           string     `json:"namespace,omitempty"`
+	AvailableAfter      *time.Time `json:"available_after,omitempty"`
+	Creator             string     `json:"creator,omitempty"`
+	Hint                string     `json:"hint,omitempty"`
+	ContentType         string     `json:"content_type,omitempty"`
+	Filename            string     `json:"filename,omitempty"`
+	NotifyEmail         []byte     `json:"notify_email,omitempty"`
+	NotifyEmailHash     string     `json:"notify_email_hash,omitempty"`
+}
+
+type part struct {
+	Label      string `json:"label"`
+	Ciphertext []byte `json:"ciphertext"`
+	IV         []byte `json:"iv"`
+}
+
+type flags struct {
+	BurnAfterRead bool `json:"burn_after_read"`
+	RequireAck    bool `json:"require_ack"`
+	IVEmbedded    bool `json:"iv_embedded"`
+}
+
+// ImportCounts reports what an import did with each secret
+type ImportCounts struct {
+	// Imported secrets were created on the target
+	Imported int64 `json:"imported"`
+	// Skipped secrets had an ID already taken on the target, or a
+	// namespace deleted there
+	Skipped int64 `json:"skipped"`
+	// Expired secrets ran out between export and import
+	Expired int64 `json:"expired"`
+}
+
+// Export writes every secret active at now to w, encrypted under
+// passphrase, and returns how many it wrote. Secrets are read a page at a
+// time, so a secret created during the export may or may not be included.
+func Export(ctx context.Context, s store.Store, w io.Writer, passphrase string, now time.Time) (int64, error) {
+	salt, err := crypto.NewExportSalt()
+	if err != nil {
+		return 0, err
+	}
+	sealer, err := crypto.NewExportCipher(passphrase, salt)
+	if err != nil {
+		return 0, err
+	}
+
+	out := bufio.NewWriter(w)
+	line, _ := json.Marshal(header{Format: Format, Version: Version, KDF: KDF, Salt: salt})
+	out.Write(append(line, '\n'))
+
+	var seq uint64
+	write := func(e entry) error {
+		seq++
+		plain, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("encode record %d: %w", seq, err)
+		}
+		sealed := sealer.Seal(seq, plain)
+		clear(plain)
+		line := base64.StdEncoding.AppendEncode(nil, sealed)
+		_, err = out.Write(append(line, '\n'))
+		return err
+	}
+
+	var count int64
+	for after := ""; ; {
+		page, err := s.ActiveSecrets(ctx, now, after, pageSize)
+		if err != nil {
+			return count, fmt.Errorf("read active secrets: %w", err)
+		}
+		for i := range page {
+			if err := write(entry{Secret: fromSecret(&page[i])}); err != nil {
+				return count, err
+			}
+			count++
+		}
+		if len(page) < pageSize {
+			break
+		}
+		after = page[len(page)-1].ID
+	}
+
+	if err := write(entry{End: &end{Count: count}}); err != nil {
+		return count, err
+	}
+	return count, out.Flush()
+}
+
+// Import creates the secrets of an export read from r on s. IDs already
+// taken on s are skipped rather than overwritten, and secrets that expired
+// by now are left out. An export cut short reports ErrTruncated after
+// importing what came before the cut.
+func Import(ctx context.Context, s store.Store, r io.Reader, passphrase string, now time.Time) (ImportCounts, error) {
+	var counts ImportCounts
+	in := bufio.NewReader(r)
+
+	line, err := readLine(in)
+	if err != nil {
+		return counts, fmt.Errorf("read header: %w", err)
+	}
+	var head header
+	if err := json.Unmarshal(line, &head); err != nil || head.Format != Format {
+		return counts, fmt.Errorf("not an %s file", Format)
+	}
+	if head.Version != Version || head.KDF != KDF {
+		return counts, fmt.Errorf("unsupported export version %d with %s", head.Version, head.KDF)
+	}
+	opener, err := crypto.NewExportCipher(passphrase, head.Salt)
+	if err != nil {
+		return counts, err
+	}
+
+	for seq := uint64(1); ; seq++ {
+		line, err := readLine(in)
+		if errors.Is(err, io.EOF) {
+			return counts, ErrTruncated
+		}
+		if err != nil {
+			return counts, fmt.Errorf("read record %d: %w", seq, err)
+		}
+		sealed, err := base64.StdEncoding.DecodeString(string(line))
+		if err != nil {
+			return counts, fmt.Errorf("decode record %d: %w", seq, err)
+		}
+		plain, err := opener.Open(seq, sealed)
+		if err != nil {
+			return counts, fmt.Errorf("record %d: wrong passphrase or damaged export: %w", seq, err)
+		}
+		var e entry
+		err = json.Unmarshal(plain, &e)
+		clear(plain)
+		if err != nil {
+			return counts, fmt.Errorf("decode record %d: %w", seq, err)
+		}
+
+		if e.End != nil {
+			if total := counts.Imported + counts.Skipped + counts.Expired; e.End.Count != total {
+				return counts, fmt.Errorf("export holds %d secrets, read %d", e.End.Count, total)
+			}
+			if _, err := readLine(in); !errors.Is(err, io.EOF) {
+				return counts, fmt.Errorf("data after the end of the export")
+			}
+			return counts, nil
+		}
+		if e.Secret == nil {
+			return counts, fmt.Errorf("record %d is empty", seq)
+		}
+
+		secret := e.Secret.secret()
+		if !secret.ExpiresAt.After(now) {
+			counts.Expired++
+			continue
+		}
+		err = s.Create(ctx, secret)
+		switch {
+		case err == nil:
+			counts.Imported++
+		case errors.Is(err, store.ErrDuplicateID), errors.Is(err, store.ErrNamespaceDeleted):
+			counts.Skipped++
+		default:
+			return counts, fmt.Errorf("create secret from record %d: %w", seq, err)
+		}
+	}
+}
+
+// readLine returns the next line without its newline, io.EOF when none is
+// left. Lines are as long as the largest secret, so no fixed buffer is used.
+func readLine(in *bufio.Reader) ([]byte, error) {
+	line, err := in.ReadBytes('\n')
+	if errors.Is(err, io.EOF) && len(line) > 0 {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(line, "\r\n"), nil
+}
+
+func fromSecret(s *store.Secret) *record {
+	r := &record{
+		ID:         s.ID,
+		Ciphertext: s.Ciphertext,
+		IV:         s.IV,
+		Salt:       s.Salt,
+		ExpiresAt:  s.ExpiresAt.UTC(),
+		CreatedAt:  s.CreatedAt.UTC(),
+		Flags: flags{
+			BurnAfterRead: s.BurnAfterRead,
+			RequireAck:    s.RequireAck,
+			IVEmbedded:    s.IVEmbedded,
+		},
+		DataKey:             s.DataKey,
+		KeyVersion:          s.KeyVersion,
+		DeclaredKeyBits:     s.DeclaredKeyBits,
+		ManagementTokenHash: s.ManagementTokenHash,
+		Namespace:           s.Namespace,
+		AvailableAfter:      s.AvailableAfter,
+		Creator:             s.Creator,
+		Hint:                s.Hint,
+		ContentType:         s.ContentType,
+		Filename:            s.Filename,
+		NotifyEmail:         s.NotifyEmail,
+		NotifyEmailHash:     s.NotifyEmailHash,
+	}
+	for _, p := range s.Parts {
+		r.Parts = append(r.Parts, part{Label: p.Label, Ciphertext: p.Ciphertext, IV: p.IV})
+	}
+	return r
+}
+
+func (r *record) secret() *store.Secret {
+	s := &store.Secret{
+		ID:                  r.ID,
+		Ciphertext:          r.Ciphertext,
+		IV:                  r.IV,
+		Salt:                r.Salt,
+		ExpiresAt:           r.ExpiresAt,
+		CreatedAt:           r.CreatedAt,
+		BurnAfterRead:       r.Flags.BurnAfterRead,
+		RequireAck:          r.Flags.RequireAck,
+		IVEmbedded:          r.Flags.IVEmbedded,
+		DataKey:             r.DataKey,
+		KeyVersion:          r.KeyVersion,
+		DeclaredKeyBits:     r.DeclaredKeyBits,
+		ManagementTokenHash: r.ManagementTokenHash,
+		Namespace:           r.Namespace,
+		AvailableAfter:      r.AvailableAfter,
+		Creator:             r.Creator,
+		Hint:                r.Hint,
+		ContentType:         r.ContentType,
+		Filename:            r.Filename,
+		NotifyEmail:         r.NotifyEmail,
+		NotifyEmailHash:     r.NotifyEmailHash,
+	}
+	for _, p := range r.Parts {
+		s.Parts = append(s.Parts, store.Part{Label: p.Label, Ciphertext: p.Ciphertext, IV: p.IV})
+	}
+	return s
+}
@@ -0,0 +1,186 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"ots-backend/internal/store"
+	"ots-backend/internal/store/memory"
+)
+
+const passphrase = "correct horse battery staple"
+
+func newSecret(id string, expiresAt time.Time) *store.Secret {
+	return &store.Secret{
+		ID:            id,
+		Ciphertext:    []byte("ciphertext-" + id),
+		IV:            bytes.Repeat([]byte{0xAA}, 12),
+		ExpiresAt:     expiresAt,
+		CreatedAt:     expiresAt.Add(-2 * time.Hour),
+		BurnAfterRead: true,
+	}
+}
+
+func mustCreate(t *testing.T, s store.Store, secret *store.Secret) {
+	t.Helper()
+	if err := s.Create(context.Background(), secret); err != nil {
+		t.Fatalf("Create(%s) error: %v", secret.ID, err)
+	}
+}
+
+func TestRoundTripKeepsIDsAndExpiries(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 5, 14, 12, 0, 0, 0, time.UTC)
+	source := memory.New()
+
+	// More than a page, so the export pages through the store
+	want := map[string]time.Time{}
+	for i := range pageSize + 44 {
+		id := fmt.Sprintf("secret-%04d", i)
+		expiresAt := now.Add(time.Duration(i+1) * time.Minute)
+		mustCreate(t, source, newSecret(id, expiresAt))
+		want[id] = expiresAt
+	}
+
+	bits := 256
+	release := now.Add(10 * time.Minute)
+	rich := newSecret("secret-rich", now.Add(time.Hour))
+	rich.Ciphertext, rich.IV = []byte{}, []byte{}
+	rich.Salt = []byte("salt-salt-salt-!")
+	rich.Parts = []store.Part{{Label: "password", Ciphertext: []byte("hunter2"), IV: bytes.Repeat([]byte{0x02}, 12)}}
+	rich.DataKey = bytes.Repeat([]byte{0x42}, 32)
+	rich.KeyVersion = "v1"
+	rich.DeclaredKeyBits = &bits
+	rich.ManagementTokenHash = bytes.Repeat([]byte{0x07}, 32)
+	rich.RequireAck = true
+	rich.Namespace = "team-a"
+	rich.AvailableAfter = &release
+	rich.Hint = "db password"
+	rich.Filename = "notes.txt"
+	mustCreate(t, source, rich)
+	want[rich.ID] = rich.ExpiresAt
+
+	// Secrets that can no longer be read stay behind
+	mustCreate(t, source, newSecret("secret-expired", now.Add(-time.Minute)))
+	mustCreate(t, source, newSecret("secret-read", now.Add(time.Hour)))
+	if _, err := source.Consume(ctx, "secret-read", store.ConsumeOptions{Now: now}); err != nil {
+		t.Fatalf("Consume() error: %v", err)
+	}
+
+	var export bytes.Buffer
+	n, err := Export(ctx, source, &export, passphrase, now)
+	if err != nil {
+		t.Fatalf("Export() error: %v", err)
+	}
+	if n != int64(len(want)) {
+		t.Errorf("Export() = %d secrets, want %d", n, len(want))
+	}
+	if bytes.Contains(export.Bytes(), []byte("ciphertext-secret-0001")) || bytes.Contains(export.Bytes(), []byte("db password")) {
+		t.Fatal("export holds secret fields in the clear")
+	}
+
+	target := memory.New()
+	counts, err := Import(ctx, target, bytes.NewReader(export.Bytes()), passphrase, now)
+	if err != nil {
+		t.Fatalf("Import() error: %v", err)
+	}
+	if counts != (ImportCounts{Imported: int64(len(want))}) {
+		t.Errorf("Import() = %+v, want %d imported", counts, len(want))
+	}
+
+	imported, err := target.ActiveSecrets(ctx, now, "", len(want)+1)
+	if err != nil {
+		t.Fatalf("ActiveSecrets() error: %v", err)
+	}
+	if len(imported) != len(want) {
+		t.Fatalf("target holds %d secrets, want %d", len(imported), len(want))
+	}
+	for _, secret := range imported {
+		if expiresAt, ok := want[secret.ID]; !ok || !secret.ExpiresAt.Equal(expiresAt) {
+			t.Errorf("imported %s expiring %v, want %v", secret.ID, secret.ExpiresAt, expiresAt)
+		}
+	}
+
+	got, err := target.Consume(ctx, rich.ID, store.ConsumeOptions{Now: release})
+	if err != nil {
+		t.Fatalf("Consume() imported secret error: %v", err)
+	}
+	if len(got.Parts) != 1 || !bytes.Equal(got.Parts[0].Ciphertext, []byte("hunter2")) || !bytes.Equal(got.Salt, rich.Salt) ||
+		!bytes.Equal(got.DataKey, rich.DataKey) || got.KeyVersion != "v1" || *got.DeclaredKeyBits != 256 ||
+		!bytes.Equal(got.ManagementTokenHash, rich.ManagementTokenHash) || !got.RequireAck || !got.BurnAfterRead ||
+		got.Namespace != "team-a" || !got.AvailableAfter.Equal(release) || got.Hint != "db password" || got.Filename != "notes.txt" {
+		t.Errorf("imported secret = %+v, want it as exported", got)
+	}
+}
+
+func TestImportSkipsExistingAndExpired(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 5, 14, 12, 0, 0, 0, time.UTC)
+	source := memory.New()
+	mustCreate(t, source, newSecret("secret-a", now.Add(time.Hour)))
+	mustCreate(t, source, newSecret("secret-b", now.Add(time.Minute)))
+
+	var export bytes.Buffer
+	if _, err := Export(ctx, source, &export, passphrase, now); err != nil {
+		t.Fatalf("Export() error: %v", err)
+	}
+
+	// The target already holds secret-a with other contents, and secret-b
+	// runs out before the import
+	target := memory.New()
+	existing := newSecret("secret-a", now.Add(time.Hour))
+	existing.Ciphertext = []byte("already here")
+	mustCreate(t, target, existing)
+
+	counts, err := Import(ctx, target, bytes.NewReader(export.Bytes()), passphrase, now.Add(2*time.Minute))
+	if err != nil {
+		t.Fatalf("Import() error: %v", err)
+	}
+	if want := (ImportCounts{Skipped: 1, Expired: 1}); counts != want {
+		t.Errorf("Import() = %+v, want %+v", counts, want)
+	}
+	got, err := target.Consume(ctx, "secret-a", store.ConsumeOptions{Now: now})
+	if err != nil || string(got.Ciphertext) != "already here" {
+		t.Errorf("existing secret after import = %+v, %v; want it untouched", got, err)
+	}
+}
+
+func TestImportRefusesWrongPassphraseAndDamage(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 5, 14, 12, 0, 0, 0, time.UTC)
+	source := memory.New()
+	mustCreate(t, source, newSecret("secret-a", now.Add(time.Hour)))
+	mustCreate(t, source, newSecret("secret-b", now.Add(time.Hour)))
+
+	var export bytes.Buffer
+	if _, err := Export(ctx, source, &export, passphrase, now); err != nil {
+		t.Fatalf("Export() error: %v", err)
+	}
+	lines := strings.SplitAfter(export.String(), "\n")
+
+	if _, err := Import(ctx, memory.New(), strings.NewReader(export.String()), "wrong", now); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Errorf("Import() with the wrong passphrase error = %v, want a wrong passphrase error", err)
+	}
+
+	// Cut before the trailer: what came before is imported
+	target := memory.New()
+	counts, err := Import(ctx, target, strings.NewReader(strings.Join(lines[:3], "")), passphrase, now)
+	if !errors.Is(err, ErrTruncated) || counts.Imported != 2 {
+		t.Errorf("Import() of a cut export = %+v, %v; want 2 imported and ErrTruncated", counts, err)
+	}
+
+	// Records are bound to their position
+	swapped := lines[0] + lines[2] + lines[1] + lines[3]
+	if _, err := Import(ctx, memory.New(), strings.NewReader(swapped), passphrase, now); err == nil {
+		t.Error("Import() accepted reordered records")
+	}
+
+	if _, err := Import(ctx, memory.New(), strings.NewReader("{}\n"), passphrase, now); err == nil {
+		t.Error("Import() accepted a file without the export header")
+	}
+}
@@ -0,0 +1,136 @@
+// Package ulid generates ULIDs: 128-bit IDs whose 26-character Crockford
+// base32 form sorts by creation time. A 48-bit millisecond timestamp is
+// followed by 80 random bits; within one millisecond the random part is
+// incremented instead of redrawn, so IDs from one process never sort before
+// an earlier one.
+package ulid
+
+import (
+	"crypto/rand"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Length is the length of an encoded ULID
+const Length = 26
+
+const alphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ErrInvalid indicates a string is not a ULID
+var ErrInvalid = errors.New("invalid ulid")
+
+// Generator issues monotonic ULIDs
+type Generator struct {
+	mu     sync.Mutex
+	lastMS uint64
+	random [10]byte
+}
+
+var defaultGenerator Generator
+
+// New returns a ULID for t from the process-wide generator
+func New(t time.Time) string {
+	return defaultGenerator.New(t)
+}
+
+// New returns a ULID for t. A t at or before the previous call's millisecond
+// reuses that millisecond with the random part incremented.
+func (g *Generator) New(t time.Time) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(t.UnixMilli())
+	if ms > g.lastMS {
+		g.lastMS = ms
+		rand.Read(g.random[:])
+	} else {
+		increment(&g.random)
+	}
+
+	var id [16]byte
+	putTime(&id, g.lastMS)
+	copy(id[6:], g.random[:])
+	return encode(id)
+}
+
+// Floor returns the smallest ULID with t's millisecond, so id >= Floor(t)
+// selects IDs created at or after t
+func Floor(t time.Time) string {
+	var id [16]byte
+	putTime(&id, uint64(t.UnixMilli()))
+	return encode(id)
+}
+
+// Time returns the millisecond timestamp encoded in id
+func Time(id string) (time.Time, error) {
+	if !Valid(id) {
+		return time.Time{}, ErrInvalid
+	}
+
+	var ms uint64
+	for i := 0; i < 10; i++ {
+		ms = ms<<5 | uint64(decodeChar(id[i]))
+	}
+	return time.UnixMilli(int64(ms)), nil
+}
+
+// Valid reports whether s is a canonical, upper-case ULID
+func Valid(s string) bool {
+	if len(s) != Length || s[0] > '7' {
+		return false
+	}
+	for i := 0; i < Length; i++ {
+		if decodeChar(s[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func putTime(id *[16]byte, ms uint64) {
+	for i := 5; i >= 0; i-- {
+		id[i] = byte(ms)
+		ms >>= 8
+	}
+}
+
+// increment adds one to the big-endian random part; it wraps on overflow,
+// which needs 2^80 IDs in one millisecond
+func increment(random *[10]byte) {
+	for i := len(random) - 1; i >= 0; i-- {
+		random[i]++
+		if random[i] != 0 {
+			return
+		}
+	}
+}
+
+// encode writes the 128 bits as 26 base32 characters, the first carrying
+// only the top 3 bits
+func encode(id [16]byte) string {
+	var out [Length]byte
+	var acc uint64
+	bits := 2 // 130 output bits for 128 input bits: pad two zero bits in front
+
+	pos := 0
+	for _, b := range id {
+		acc = acc<<8 | uint64(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			out[pos] = alphabet[(acc>>uint(bits))&0x1F]
+			pos++
+		}
+	}
+	return string(out[:])
+}
+
+func decodeChar(c byte) int {
+	for i := 0; i < len(alphabet); i++ {
+		if alphabet[i] == c {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,74 @@
+package ulid
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestTimeDecodesSpecExample(t *testing.T) {
+	got, err := Time("01ARZ3NDEKTSV4RRFFQ69G5FAV")
+	if err != nil {
+		t.Fatalf("Time() error: %v", err)
+	}
+	if got.UnixMilli() != 1469922850259 {
+		t.Errorf("Time() = %d, want 1469922850259", got.UnixMilli())
+	}
+}
+
+func TestNewRoundTripsTime(t *testing.T) {
+	now := time.UnixMilli(1735732800123)
+	id := New(now)
+
+	if !Valid(id) {
+		t.Fatalf("New() = %q, not a valid ULID", id)
+	}
+	got, err := Time(id)
+	if err != nil || !got.Equal(now) {
+		t.Errorf("Time(New(t)) = %v, %v; want %v", got, err, now)
+	}
+	if floor := Floor(now); floor > id || floor[:10] != id[:10] {
+		t.Errorf("Floor() = %q, want lowest ID of %q's millisecond", floor, id)
+	}
+}
+
+func TestNewIsMonotonic(t *testing.T) {
+	var g Generator
+	now := time.UnixMilli(1735732800000)
+
+	var ids []string
+	for i := range 1000 {
+		// Repeated and backwards clock readings still sort after earlier IDs
+		ts := now.Add(time.Duration(i/100) * time.Millisecond)
+		if i%7 == 0 {
+			ts = now
+		}
+		ids = append(ids, g.New(ts))
+	}
+
+	if !sort.StringsAreSorted(ids) {
+		t.Fatal("IDs are not issued in sorted order")
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] == ids[i-1] {
+			t.Fatalf("duplicate ID %q", ids[i])
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	for _, tt := range []struct {
+		id   string
+		want bool
+	}{
+		{"01ARZ3NDEKTSV4RRFFQ69G5FAV", true},
+		{"01arz3ndektsv4rrffq69g5fav", false},
+		{"81ARZ3NDEKTSV4RRFFQ69G5FAV", false},
+		{"01ARZ3NDEKTSV4RRFFQ69G5FA", false},
+		{"01ARZ3NDEKTSV4RRFFQ69G5FAU", false},
+	} {
+		if got := Valid(tt.id); got != tt.want {
+			t.Errorf("Valid(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
@@ -2,66 +2,183 @@ package validation
 
 import (
 	"encoding/base64"
-	"errors"
+	"encoding/json"
 	"fmt"
+	"html"
+	"net/mail"
 	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"ots-backend/internal/models"
+	"ots-backend/internal/policy"
+	"ots-backend/internal/scan"
+	"ots-backend/internal/sensitive"
+	"ots-backend/pkg/ots"
 )
 
+// Validation errors are the ots sentinels, so embedders can match them
+// without importing this package
 var (
-	// ErrInvalidCiphertext indicates invalid ciphertext format
-	ErrInvalidCiphertext = errors.New("invalid ciphertext format")
-	// ErrInvalidIV indicates invalid IV format
-	ErrInvalidIV = errors.New("invalid IV format")
-	// ErrInvalidSalt indicates invalid salt format
-	ErrInvalidSalt = errors.New("invalid salt format")
-	// ErrInvalidPlaintext indicates invalid plaintext content
-	ErrInvalidPlaintext = errors.New("invalid plaintext content")
-	// ErrInvalidSecretID indicates invalid secret ID
-	ErrInvalidSecretID = errors.New("invalid secret ID")
-	// ErrInvalidTTL indicates invalid TTL value
-	ErrInvalidTTL = errors.New("invalid TTL value")
-	// ErrSecretTooLarge indicates secret exceeds maximum size
-	ErrSecretTooLarge = errors.New("secret exceeds maximum size")
+	ErrInvalidCiphertext     = ots.ErrInvalidCiphertext
+	ErrInvalidIV             = ots.ErrInvalidIV
+	ErrInvalidSalt           = ots.ErrInvalidSalt
+	ErrInvalidPlaintext      = ots.ErrInvalidPlaintext
+	ErrInvalidSecretID       = ots.ErrInvalidSecretID
+	ErrInvalidTTL            = ots.ErrInvalidTTL
+	ErrSecretTooLarge        = ots.ErrSecretTooLarge
+	ErrInvalidParts          = ots.ErrInvalidParts
+	ErrInvalidKeyBits        = ots.ErrInvalidKeyBits
+	ErrKeyTooWeak            = ots.ErrKeyTooWeak
+	ErrKeyBitsRequired       = ots.ErrKeyBitsRequired
+	ErrInvalidNamespace      = ots.ErrInvalidNamespace
+	ErrInvalidAlgorithm      = ots.ErrInvalidAlgorithm
+	ErrInvalidSlug           = ots.ErrInvalidSlug
+	ErrInvalidAvailableAfter = ots.ErrInvalidAvailableAfter
+	ErrInvalidHint           = ots.ErrInvalidHint
+	ErrInvalidNotifyEmail    = ots.ErrInvalidNotifyEmail
+	ErrInvalidContentType    = ots.ErrInvalidContentType
+	ErrInvalidFilename       = ots.ErrInvalidFilename
 )
 
+// maxEmailLength is the longest address SMTP can deliver to (RFC 5321)
+const maxEmailLength = 254
+
+// ContentTypes are the content_type values a sender may declare. They only
+// tell the recipient's client how to render the plaintext, and are kept to
+// types no client will execute.
+var ContentTypes = []string{"text/plain", "application/json", "application/octet-stream"}
+
+// MaxFilenameLength is the longest filename, in characters
+const MaxFilenameLength = 255
+
+// Algorithms a client may declare with iv_embedded
 const (
-	MaxSecretSize   = 32768 // 32KB
-	MinSecretSize   = 1
-	MaxTTL          = 24 * time.Hour
-	MinTTL          = 5 * time.Minute
-	SecretIDPattern = `^[A-Za-z0-9_-]{22}$` // Base64URL encoding of 16 bytes
+	AlgorithmAESGCM            = "aes-256-gcm"
+	AlgorithmXChaCha20Poly1305 = "xchacha20-poly1305"
+	AlgorithmXSalsa20Poly1305  = "xsalsa20-poly1305"
 )
 
-var secretIDRegex = regexp.MustCompile(SecretIDPattern)
+// embeddedOverhead is the nonce plus authentication tag length each
+// algorithm adds to a blob with the nonce prepended. xsalsa20-poly1305 is
+// libsodium's secretbox.
+var embeddedOverhead = map[string]int{
+	AlgorithmAESGCM:            12 + 16,
+	AlgorithmXChaCha20Poly1305: 24 + 16,
+	AlgorithmXSalsa20Poly1305:  24 + 16,
+}
+
+// Limits (sizes, TTL bounds, part counts) live in internal/policy
+
+const (
+	// SecretIDPattern is an optional region code followed by the Base64URL
+	// encoding of 16 bytes; IDs from before regions carry no prefix
+	SecretIDPattern = `^(?:[a-z]{2})?[A-Za-z0-9_-]{22}$`
+	// RegionCodePattern matches the region codes prefixed to secret IDs
+	RegionCodePattern = `^[a-z]{2}$`
+	// NamespacePattern is a lowercase slug of up to 64 characters that
+	// starts and ends with a letter or digit
+	NamespacePattern = `^[a-z0-9](?:[a-z0-9-]{0,62}[a-z0-9])?$`
+	// SlugPattern is a custom secret ID chosen at create time
+	SlugPattern = `^[a-z0-9-]{8,64}$`
+)
+
+// unprefixedIDLength is the length of a secret ID without a region code
+const unprefixedIDLength = 22
+
+var (
+	secretIDRegex   = regexp.MustCompile(SecretIDPattern)
+	regionCodeRegex = regexp.MustCompile(RegionCodePattern)
+	namespaceRegex  = regexp.MustCompile(NamespacePattern)
+	slugRegex       = regexp.MustCompile(SlugPattern)
+)
 
 // CreateSecretRequest represents the validated create request
 type CreateSecretRequest struct {
-	Ciphertext    []byte
-	IV            []byte
-	Salt          []byte
+	Ciphertext    sensitive.Bytes
+	IV            sensitive.Bytes
+	Salt          sensitive.Bytes
 	ExpiresIn     time.Duration
 	BurnAfterRead bool
+	// Parts replaces Ciphertext/IV for multi-part secrets
+	Parts []Part
+	// DeclaredKeyBits is the client's claimed link key length, nil if undeclared
+	DeclaredKeyBits *int
+	// RequireAck holds the secret after its first read until acknowledged
+	RequireAck bool
+	// Namespace is the tenant the secret is filed under, empty for none
+	Namespace string
+	// IVEmbedded marks a Ciphertext that starts with its nonce; IV is empty
+	IVEmbedded bool
+	// Slug replaces the generated ID when set
+	Slug string
+	// AvailableAfter refuses reads until it passes; nil for none
+	AvailableAfter *time.Time
+	// Hint is the HTML-escaped preview shown before a read, empty for none
+	Hint string
+	// NotifyEmail is the address told when the secret is read or expires
+	// unread, empty for none
+	NotifyEmail sensitive.String
+	// ContentType is one of ContentTypes and Filename a bare file name,
+	// each empty for none
+	ContentType string
+	Filename    string
+}
+
+// Size returns the ciphertext bytes across the blob and all parts
+func (r *CreateSecretRequest) Size() int {
+	size := len(r.Ciphertext)
+	for _, part := range r.Parts {
+		size += len(part.Ciphertext)
+	}
+	return size
+}
+
+// Part is a validated, decoded secret part
+type Part struct {
+	Label      string
+	Ciphertext sensitive.Bytes
+	IV         sensitive.Bytes
+}
+
+// decodeBase64 decodes standard or URL-safe base64, padded or not, since
+// client libraries differ in which they emit. The alphabets are not mixed,
+// and padding, when present, must be complete.
+func decodeBase64(s string) ([]byte, error) {
+	trimmed := strings.TrimRight(s, "=")
+	if padding := len(s) - len(trimmed); padding > 0 && (padding > 2 || len(s)%4 != 0) {
+		return nil, base64.CorruptInputError(len(trimmed))
+	}
+
+	enc := base64.RawStdEncoding
+	if strings.ContainsAny(trimmed, "-_") {
+		enc = base64.RawURLEncoding
+	}
+	return enc.DecodeString(trimmed)
 }
 
 // ValidateCreateRequest validates a secret creation request
-func ValidateCreateRequest(ciphertextB64, ivB64, saltB64 string, expiresIn int, maxSize int) (*CreateSecretRequest, error) {
+func ValidateCreateRequest(ciphertextB64, ivB64, saltB64 string, expiresIn int, p *policy.Policy) (*CreateSecretRequest, error) {
 	// Validate and decode ciphertext
 	if ciphertextB64 == "" {
 		return nil, fmt.Errorf("%w: ciphertext is required", ErrInvalidCiphertext)
 	}
 
-	ciphertext, err := base64.StdEncoding.DecodeString(ciphertextB64)
+	ciphertext, err := decodeBase64(ciphertextB64)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrInvalidCiphertext, err)
 	}
 
-	if len(ciphertext) < MinSecretSize {
+	if len(ciphertext) < p.MinSecretSize {
 		return nil, fmt.Errorf("%w: ciphertext too small", ErrInvalidCiphertext)
 	}
 
-	if len(ciphertext) > maxSize {
-		return nil, fmt.Errorf("%w: %d bytes (max %d)", ErrSecretTooLarge, len(ciphertext), maxSize)
+	if len(ciphertext) > p.MaxSecretSize {
+		return nil, fmt.Errorf("%w: %d bytes (max %d)", ErrSecretTooLarge, len(ciphertext), p.MaxSecretSize)
 	}
 
 	// Validate and decode IV
@@ -69,7 +186,7 @@ func ValidateCreateRequest(ciphertextB64, ivB64, saltB64 string, expiresIn int,
 		return nil, fmt.Errorf("%w: IV is required", ErrInvalidIV)
 	}
 
-	iv, err := base64.StdEncoding.DecodeString(ivB64)
+	iv, err := decodeBase64(ivB64)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrInvalidIV, err)
 	}
@@ -82,13 +199,184 @@ func ValidateCreateRequest(ciphertextB64, ivB64, saltB64 string, expiresIn int,
 	// Validate and decode salt (optional)
 	var salt []byte
 	if saltB64 != "" {
-		salt, err = base64.StdEncoding.DecodeString(saltB64)
+		salt, err = decodeBase64(saltB64)
 		if err != nil {
 			return nil, fmt.Errorf("%w: %v", ErrInvalidSalt, err)
 		}
 	}
 
-	return ValidateEncryptedPayload(ciphertext, iv, salt, expiresIn, maxSize)
+	return ValidateEncryptedPayload(ciphertext, iv, salt, expiresIn, p)
+}
+
+// ValidateEmbeddedRequest validates a create request whose ciphertext
+// carries its nonce in front, as libsodium and some WebCrypto wrappers emit
+// it. Only the total size and the declared algorithm's minimum length are
+// checked; algorithm defaults to aes-256-gcm.
+func ValidateEmbeddedRequest(ciphertextB64, ivB64, saltB64, algorithm string, expiresIn int, p *policy.Policy) (*CreateSecretRequest, error) {
+	if ivB64 != "" {
+		return nil, fmt.Errorf("%w: send either iv or iv_embedded, not both", ErrInvalidIV)
+	}
+
+	if algorithm == "" {
+		algorithm = AlgorithmAESGCM
+	}
+	overhead, ok := embeddedOverhead[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q is not one of aes-256-gcm, xchacha20-poly1305, xsalsa20-poly1305", ErrInvalidAlgorithm, algorithm)
+	}
+
+	if ciphertextB64 == "" {
+		return nil, fmt.Errorf("%w: ciphertext is required", ErrInvalidCiphertext)
+	}
+
+	ciphertext, err := decodeBase64(ciphertextB64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCiphertext, err)
+	}
+
+	if len(ciphertext) > p.MaxSecretSize {
+		return nil, fmt.Errorf("%w: %d bytes (max %d)", ErrSecretTooLarge, len(ciphertext), p.MaxSecretSize)
+	}
+
+	if len(ciphertext) < overhead+p.MinSecretSize {
+		return nil, fmt.Errorf("%w: %s with an embedded nonce needs at least %d bytes, got %d", ErrInvalidCiphertext, algorithm, overhead+p.MinSecretSize, len(ciphertext))
+	}
+
+	var salt []byte
+	if saltB64 != "" {
+		salt, err = decodeBase64(saltB64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSalt, err)
+		}
+		if len(salt) < 16 {
+			return nil, fmt.Errorf("%w: salt must be at least 16 bytes", ErrInvalidSalt)
+		}
+	}
+
+	ttl, err := ValidateTTL(expiresIn, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CreateSecretRequest{
+		Ciphertext:    ciphertext,
+		Salt:          salt,
+		ExpiresIn:     ttl,
+		BurnAfterRead: true,
+		IVEmbedded:    true,
+	}, nil
+}
+
+// ValidateMultipartRequest validates a creation request that carries labelled
+// parts instead of a single ciphertext. The combined part size counts
+// against maxSize.
+func ValidateMultipartRequest(ciphertextB64, ivB64, saltB64 string, parts []models.SecretPart, expiresIn int, p *policy.Policy) (*CreateSecretRequest, error) {
+	if ciphertextB64 != "" || ivB64 != "" {
+		return nil, fmt.Errorf("%w: send either ciphertext/iv or parts, not both", ErrInvalidParts)
+	}
+
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("%w: at least one part is required", ErrInvalidParts)
+	}
+
+	if len(parts) > p.MaxParts {
+		return nil, fmt.Errorf("%w: %d parts (max %d)", ErrInvalidParts, len(parts), p.MaxParts)
+	}
+
+	decoded := make([]Part, 0, len(parts))
+	labels := make(map[string]bool, len(parts))
+	total := 0
+	for i, part := range parts {
+		label := strings.TrimSpace(part.Label)
+		if label == "" || len(label) > p.MaxPartLabel || strings.IndexFunc(label, unicode.IsControl) >= 0 {
+			return nil, fmt.Errorf("%w: part %d label must be 1-%d printable characters", ErrInvalidParts, i, p.MaxPartLabel)
+		}
+
+		if labels[label] {
+			return nil, fmt.Errorf("%w: duplicate label %q", ErrInvalidParts, label)
+		}
+		labels[label] = true
+
+		ciphertext, err := decodeBase64(part.Ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("%w: part %d: %v", ErrInvalidCiphertext, i, err)
+		}
+
+		if len(ciphertext) < p.MinSecretSize {
+			return nil, fmt.Errorf("%w: part %d ciphertext too small", ErrInvalidCiphertext, i)
+		}
+
+		iv, err := decodeBase64(part.IV)
+		if err != nil {
+			return nil, fmt.Errorf("%w: part %d: %v", ErrInvalidIV, i, err)
+		}
+
+		if len(iv) != 12 {
+			return nil, fmt.Errorf("%w: part %d IV must be 12 bytes, got %d", ErrInvalidIV, i, len(iv))
+		}
+
+		total += len(ciphertext)
+		decoded = append(decoded, Part{Label: label, Ciphertext: ciphertext, IV: iv})
+	}
+
+	if total > p.MaxSecretSize {
+		return nil, fmt.Errorf("%w: %d bytes across parts (max %d)", ErrSecretTooLarge, total, p.MaxSecretSize)
+	}
+
+	fields := make([]scan.Field, len(decoded))
+	for i, part := range decoded {
+		fields[i] = scan.Field{Name: scan.FieldPartLabel, Value: part.Label}
+	}
+	if err := ValidateMetadata(fields...); err != nil {
+		return nil, err
+	}
+
+	var salt []byte
+	if saltB64 != "" {
+		var err error
+		salt, err = decodeBase64(saltB64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSalt, err)
+		}
+
+		if len(salt) < 16 {
+			return nil, fmt.Errorf("%w: salt must be at least 16 bytes", ErrInvalidSalt)
+		}
+	}
+
+	ttl, err := ValidateTTL(expiresIn, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CreateSecretRequest{
+		Parts:         decoded,
+		Salt:          salt,
+		ExpiresIn:     ttl,
+		BurnAfterRead: true,
+	}, nil
+}
+
+// ValidateDeclaredKeyBits enforces the key length policy. A MinKeyBits of 0
+// disables the minimum. It reports undeclared=true when the create did not
+// declare a length and the policy lets it through, so callers can count it.
+func ValidateDeclaredKeyBits(bits *int, p *policy.Policy) (undeclared bool, err error) {
+	if bits == nil {
+		if p.MinKeyBits > 0 && p.KeyBitsMissing == policy.KeyBitsMissingReject {
+			return false, fmt.Errorf("%w: at least %d bits", ErrKeyBitsRequired, p.MinKeyBits)
+		}
+		return true, nil
+	}
+
+	if *bits <= 0 || *bits > p.MaxDeclaredKeyBits {
+		return false, fmt.Errorf("%w: must be between 1 and %d", ErrInvalidKeyBits, p.MaxDeclaredKeyBits)
+	}
+
+	if p.MinKeyBits > 0 && *bits < p.MinKeyBits {
+		return false, fmt.Errorf("%w: declared %d bits, minimum is %d", ErrKeyTooWeak, *bits, p.MinKeyBits)
+	}
+
+	return false, nil
 }
 
 // ValidateSecretID validates a secret ID format
@@ -104,37 +392,202 @@ func ValidateSecretID(id string) error {
 	return nil
 }
 
+// ValidateSlug checks a custom slug. Slugs that could pass for a generated
+// ID are refused, so a slug is never mistaken for another region's secret.
+func ValidateSlug(slug string) error {
+	if !slugRegex.MatchString(slug) {
+		return fmt.Errorf("%w: must be 8 to 64 lowercase letters, digits or hyphens", ErrInvalidSlug)
+	}
+	if secretIDRegex.MatchString(slug) {
+		return fmt.Errorf("%w: has the shape of a generated ID", ErrInvalidSlug)
+	}
+	return nil
+}
+
+// ValidateMetadata runs the policy scanners over metadata fields. Callers
+// pass only what the server may read; ciphertext never goes through here.
+func ValidateMetadata(fields ...scan.Field) error {
+	return scan.Check(fields...)
+}
+
+// ValidateHint strips control characters and surrounding whitespace from a
+// sender's hint, checks its length and returns it HTML-escaped for storage
+func ValidateHint(hint string, p *policy.Policy) (string, error) {
+	hint = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, hint))
+	if n := utf8.RuneCountInString(hint); n > p.MaxHint {
+		return "", fmt.Errorf("%w: %d characters (max %d)", ErrInvalidHint, n, p.MaxHint)
+	}
+	return html.EscapeString(hint), nil
+}
+
+// ValidateContentType checks a sender's content_type against ContentTypes,
+// ignoring case and surrounding whitespace, and returns it lowercased
+func ValidateContentType(contentType string) (string, error) {
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	if contentType == "" || slices.Contains(ContentTypes, contentType) {
+		return contentType, nil
+	}
+	return "", fmt.Errorf("%w: %q is not one of %s", ErrInvalidContentType, contentType, strings.Join(ContentTypes, ", "))
+}
+
+// ValidateFilename checks a sender's filename and returns it trimmed. The
+// name is shown to the recipient and may become the name of a saved file,
+// so path separators, "." and "..", control characters and invisible
+// formatting characters such as a right-to-left override are refused
+// rather than stripped.
+func ValidateFilename(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if !utf8.ValidString(name) {
+		return "", fmt.Errorf("%w: not valid UTF-8", ErrInvalidFilename)
+	}
+	if n := utf8.RuneCountInString(name); n > MaxFilenameLength {
+		return "", fmt.Errorf("%w: %d characters (max %d)", ErrInvalidFilename, n, MaxFilenameLength)
+	}
+	if name == "." || name == ".." {
+		return "", fmt.Errorf("%w: %q names a directory", ErrInvalidFilename, name)
+	}
+	for _, r := range name {
+		switch {
+		case r == '/' || r == '\\':
+			return "", fmt.Errorf("%w: contains a path separator", ErrInvalidFilename)
+		case unicode.IsControl(r) || unicode.Is(unicode.Cf, r):
+			return "", fmt.Errorf("%w: contains control character %U", ErrInvalidFilename, r)
+		}
+	}
+	return name, nil
+}
+
+// ValidateNotifyEmail checks that address is one bare email address, as in
+// alice@example.com, without a display name or comments, and returns it
+// trimmed
+func ValidateNotifyEmail(address string) (sensitive.String, error) {
+	address = strings.TrimSpace(address)
+	if len(address) > maxEmailLength {
+		return "", fmt.Errorf("%w: longer than %d bytes", ErrInvalidNotifyEmail, maxEmailLength)
+	}
+	if strings.ContainsFunc(address, unicode.IsControl) {
+		return "", fmt.Errorf("%w: contains control characters", ErrInvalidNotifyEmail)
+	}
+	parsed, err := mail.ParseAddress(address)
+	if err != nil || parsed.Name != "" || parsed.Address != address {
+		return "", fmt.Errorf("%w: must be a single address like alice@example.com", ErrInvalidNotifyEmail)
+	}
+	return sensitive.String(address), nil
+}
+
+// ValidateRegionCode checks a deployment's REGION_CODE
+func ValidateRegionCode(code string) error {
+	if !regionCodeRegex.MatchString(code) {
+		return fmt.Errorf("region code %q is not two lowercase letters", code)
+	}
+	return nil
+}
+
+// ValidateNamespace checks a tenant namespace slug
+func ValidateNamespace(namespace string) error {
+	if !namespaceRegex.MatchString(namespace) {
+		return fmt.Errorf("%w: must be a lowercase slug of at most 64 characters", ErrInvalidNamespace)
+	}
+	return nil
+}
+
+// SecretIDRegion returns the region code prefixed to a valid secret ID, or
+// "" for an unprefixed legacy ID or a slug
+func SecretIDRegion(id string) string {
+	if len(id) <= unprefixedIDLength || !secretIDRegex.MatchString(id) {
+		return ""
+	}
+	return id[:len(id)-unprefixedIDLength]
+}
+
 // ValidatePlaintextContent validates a plaintext secret payload before encryption.
-func ValidatePlaintextContent(content []byte, maxSize int) error {
-	if len(content) < MinSecretSize {
+func ValidatePlaintextContent(content []byte, p *policy.Policy) error {
+	if len(content) < p.MinSecretSize {
 		return fmt.Errorf("%w: content is required", ErrInvalidPlaintext)
 	}
 
-	if len(content) > maxSize {
-		return fmt.Errorf("%w: %d bytes (max %d)", ErrSecretTooLarge, len(content), maxSize)
+	if len(content) > p.MaxSecretSize {
+		return fmt.Errorf("%w: %d bytes (max %d)", ErrSecretTooLarge, len(content), p.MaxSecretSize)
+	}
+
+	return nil
+}
+
+// ValidateSize checks a ciphertext length a client has yet to produce
+// against the policy's bounds, as the create checks the decoded blob
+func ValidateSize(size int, p *policy.Policy) error {
+	if size < p.MinSecretSize {
+		return fmt.Errorf("%w: ciphertext too small", ErrInvalidCiphertext)
+	}
+
+	if size > p.MaxSecretSize {
+		return fmt.Errorf("%w: %d bytes (max %d)", ErrSecretTooLarge, size, p.MaxSecretSize)
 	}
 
 	return nil
 }
 
 // ValidateTTL validates a TTL in seconds.
-func ValidateTTL(expiresIn int) (time.Duration, error) {
+func ValidateTTL(expiresIn int, p *policy.Policy) (time.Duration, error) {
 	ttl := time.Duration(expiresIn) * time.Second
-	if ttl < MinTTL || ttl > MaxTTL {
-		return 0, fmt.Errorf("%w: must be between %v and %v", ErrInvalidTTL, MinTTL, MaxTTL)
+	if ttl < p.MinTTL || ttl > p.MaxTTL {
+		return 0, fmt.Errorf("%w: must be between %v and %v", ErrInvalidTTL, p.MinTTL, p.MaxTTL)
 	}
 
 	return ttl, nil
 }
 
+// ValidateAvailableAfter reads available_after, an RFC 3339 time or a whole
+// number of seconds from now, and returns the release time. Empty, null
+// and times not after now return nil: the secret is readable at once. The
+// release must come before the secret expires, ttl after now.
+func ValidateAvailableAfter(raw json.RawMessage, now time.Time, ttl time.Duration) (*time.Time, error) {
+	text := strings.TrimSpace(string(raw))
+	if text == "" || text == "null" {
+		return nil, nil
+	}
+
+	var release time.Time
+	if strings.HasPrefix(text, `"`) {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidAvailableAfter, err)
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: want an RFC 3339 time, got %q", ErrInvalidAvailableAfter, s)
+		}
+		release = t
+	} else {
+		seconds, err := strconv.ParseInt(text, 10, 64)
+		if err != nil || seconds < 0 || seconds > int64(ttl/time.Second) {
+			return nil, fmt.Errorf("%w: want whole seconds from now below the TTL, got %s", ErrInvalidAvailableAfter, text)
+		}
+		release = now.Add(time.Duration(seconds) * time.Second)
+	}
+
+	if !release.After(now) {
+		return nil, nil
+	}
+	if !release.Before(now.Add(ttl)) {
+		return nil, fmt.Errorf("%w: must be before the secret expires", ErrInvalidAvailableAfter)
+	}
+	return &release, nil
+}
+
 // ValidateEncryptedPayload validates already-decoded encrypted secret material.
-func ValidateEncryptedPayload(ciphertext, iv, salt []byte, expiresIn int, maxSize int) (*CreateSecretRequest, error) {
-	if len(ciphertext) < MinSecretSize {
+func ValidateEncryptedPayload(ciphertext, iv, salt []byte, expiresIn int, p *policy.Policy) (*CreateSecretRequest, error) {
+	if len(ciphertext) < p.MinSecretSize {
 		return nil, fmt.Errorf("%w: ciphertext too small", ErrInvalidCiphertext)
 	}
 
-	if len(ciphertext) > maxSize {
-		return nil, fmt.Errorf("%w: %d bytes (max %d)", ErrSecretTooLarge, len(ciphertext), maxSize)
+	if len(ciphertext) > p.MaxSecretSize {
+		return nil, fmt.Errorf("%w: %d bytes (max %d)", ErrSecretTooLarge, len(ciphertext), p.MaxSecretSize)
 	}
 
 	if len(iv) != 12 {
@@ -145,7 +598,7 @@ func ValidateEncryptedPayload(ciphertext, iv, salt []byte, expiresIn int, maxSiz
 		return nil, fmt.Errorf("%w: salt must be at least 16 bytes", ErrInvalidSalt)
 	}
 
-	ttl, err := ValidateTTL(expiresIn)
+	ttl, err := ValidateTTL(expiresIn, p)
 	if err != nil {
 		return nil, err
 	}
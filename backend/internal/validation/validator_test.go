@@ -6,6 +6,9 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"ots-backend/internal/models"
+	"ots-backend/internal/policy"
 )
 
 func TestValidateCreateRequest(t *testing.T) {
@@ -111,7 +114,7 @@ func TestValidateCreateRequest(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			req, err := ValidateCreateRequest(tt.ciphertext, tt.iv, tt.salt, tt.expiresIn, tt.maxSize)
+			req, err := ValidateCreateRequest(tt.ciphertext, tt.iv, tt.salt, tt.expiresIn, withMaxSize(tt.maxSize))
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ValidateCreateRequest() error = %v, wantErr %v", err, tt.wantErr)
@@ -127,10 +130,60 @@ func TestValidateCreateRequest(t *testing.T) {
 			if !tt.wantErr && req == nil {
 				t.Error("ValidateCreateRequest() returned nil request without error")
 			}
+
+			// No salt is stored as none, so reads leave the key out
+			if !tt.wantErr && tt.salt == "" && req.Salt != nil {
+				t.Errorf("ValidateCreateRequest() salt = %#v, want nil", req.Salt)
+			}
 		})
 	}
 }
 
+func TestDecodeBase64(t *testing.T) {
+	encodings := map[string]*base64.Encoding{
+		"std":     base64.StdEncoding,
+		"raw std": base64.RawStdEncoding,
+		"url":     base64.URLEncoding,
+		"raw url": base64.RawURLEncoding,
+	}
+	// Lengths covering every padding case, with bytes that encode to + / - _
+	for _, data := range [][]byte{{}, {0xfb}, {0xfb, 0xff}, {0xfb, 0xff, 0xbf}, []byte("twelve bytes"), {0x3e, 0xff, 0xfe, 0x00, 0x01}} {
+		for name, enc := range encodings {
+			encoded := enc.EncodeToString(data)
+			got, err := decodeBase64(encoded)
+			if err != nil || string(got) != string(data) {
+				t.Errorf("decodeBase64(%s %q) = %x, %v; want %x", name, encoded, got, err, data)
+			}
+		}
+	}
+
+	for _, invalid := range []string{
+		"!!!not-valid-base64!!!",
+		"+/8-_w",   // mixed alphabets
+		"QQ=",      // incomplete padding
+		"QQ===",    // too much padding
+		"QUJD=",    // padding where none belongs
+		"QQ==QQ==", // padding inside
+		"Q",        // not a whole byte
+	} {
+		if got, err := decodeBase64(invalid); err == nil {
+			t.Errorf("decodeBase64(%q) = %x, want an error", invalid, got)
+		}
+	}
+
+	// Every field accepts every variant
+	ciphertext := base64.RawURLEncoding.EncodeToString([]byte{0xfb, 0xff, 0xbf, 0xfb, 0xff})
+	iv := base64.URLEncoding.EncodeToString(append([]byte{0xff, 0xfe}, make([]byte, 10)...))
+	salt := base64.RawStdEncoding.EncodeToString(append([]byte{0xfb}, make([]byte, 16)...))
+	req, err := ValidateCreateRequest(ciphertext, iv, salt, 3600, withMaxSize(32768))
+	if err != nil {
+		t.Fatalf("ValidateCreateRequest() with URL-safe and unpadded fields error = %v", err)
+	}
+	if len(req.IV) != 12 || len(req.Salt) != 17 || req.Ciphertext[0] != 0xfb {
+		t.Errorf("ValidateCreateRequest() decoded %+v, want the sent bytes", req)
+	}
+}
+
 func TestValidateSecretID(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -167,6 +220,21 @@ func TestValidateSecretID(t *testing.T) {
 			id:      "abcdefghABCDEFGH1234==",
 			wantErr: true,
 		},
+		{
+			name:    "region prefixed ID",
+			id:      "euabcdefghABCDEFGH1234_-",
+			wantErr: false,
+		},
+		{
+			name:    "uppercase region prefix",
+			id:      "EUabcdefghABCDEFGH1234_-",
+			wantErr: true,
+		},
+		{
+			name:    "one character prefix",
+			id:      "eabcdefghABCDEFGH1234_-",
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -179,6 +247,191 @@ func TestValidateSecretID(t *testing.T) {
 	}
 }
 
+func TestSecretIDRegion(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{id: "abcdefghABCDEFGH1234_-", want: ""},
+		{id: "euabcdefghABCDEFGH1234_-", want: "eu"},
+		{id: "usABCDEFGHabcdefgh5678-_", want: "us"},
+		{id: "quarterly-report-password", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := SecretIDRegion(tt.id); got != tt.want {
+			t.Errorf("SecretIDRegion(%q) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestValidateRegionCode(t *testing.T) {
+	for _, code := range []string{"eu", "us", "ap"} {
+		if err := ValidateRegionCode(code); err != nil {
+			t.Errorf("ValidateRegionCode(%q) error = %v", code, err)
+		}
+	}
+	for _, code := range []string{"", "e", "EU", "eu1", "e-"} {
+		if err := ValidateRegionCode(code); err == nil {
+			t.Errorf("ValidateRegionCode(%q) = nil, want error", code)
+		}
+	}
+}
+
+func TestValidateEmbeddedRequest(t *testing.T) {
+	blob := func(n int) string {
+		return base64.StdEncoding.EncodeToString(make([]byte, n))
+	}
+	p := policy.Default()
+
+	tests := []struct {
+		name       string
+		ciphertext string
+		iv         string
+		algorithm  string
+		wantErr    error
+	}{
+		{name: "aes-256-gcm by default", ciphertext: blob(12 + 16 + 1)},
+		{name: "aes-256-gcm", ciphertext: blob(12 + 16 + 1), algorithm: AlgorithmAESGCM},
+		{name: "xchacha20-poly1305", ciphertext: blob(24 + 16 + 1), algorithm: AlgorithmXChaCha20Poly1305},
+		{name: "xsalsa20-poly1305", ciphertext: blob(24 + 16 + 1), algorithm: AlgorithmXSalsa20Poly1305},
+		{name: "aes-256-gcm too short", ciphertext: blob(12 + 16), wantErr: ErrInvalidCiphertext},
+		{name: "xchacha20-poly1305 too short", ciphertext: blob(12 + 16 + 1), algorithm: AlgorithmXChaCha20Poly1305, wantErr: ErrInvalidCiphertext},
+		{name: "too large", ciphertext: blob(p.MaxSecretSize + 1), wantErr: ErrSecretTooLarge},
+		{name: "iv and iv_embedded", ciphertext: blob(64), iv: blob(12), wantErr: ErrInvalidIV},
+		{name: "unknown algorithm", ciphertext: blob(64), algorithm: "rot13", wantErr: ErrInvalidAlgorithm},
+		{name: "missing ciphertext", wantErr: ErrInvalidCiphertext},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := ValidateEmbeddedRequest(tt.ciphertext, tt.iv, "", tt.algorithm, 3600, p)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("ValidateEmbeddedRequest() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateEmbeddedRequest() error = %v", err)
+			}
+			if !req.IVEmbedded || req.IV != nil || base64.StdEncoding.EncodeToString(req.Ciphertext) != tt.ciphertext {
+				t.Errorf("ValidateEmbeddedRequest() = %+v, want the blob as-is with IVEmbedded", req)
+			}
+		})
+	}
+}
+
+func TestValidateNamespace(t *testing.T) {
+	for _, namespace := range []string{"a", "team-a", "sre2", strings.Repeat("x", 64)} {
+		if err := ValidateNamespace(namespace); err != nil {
+			t.Errorf("ValidateNamespace(%q) error = %v", namespace, err)
+		}
+	}
+	for _, namespace := range []string{"", "Team", "-team", "team-", "team_a", "team a", "tëam", strings.Repeat("x", 65)} {
+		if err := ValidateNamespace(namespace); !errors.Is(err, ErrInvalidNamespace) {
+			t.Errorf("ValidateNamespace(%q) error = %v, want ErrInvalidNamespace", namespace, err)
+		}
+	}
+}
+
+func TestValidateHint(t *testing.T) {
+	p := policy.Default()
+	tests := map[string]string{
+		"VPN password for ACME staging":       "VPN password for ACME staging",
+		"  <b>db</b> & \"co\"\n":              "&lt;b&gt;db&lt;/b&gt; &amp; &#34;co&#34;",
+		"line\none\r\ttab\x00\u0085":          "lineonetab",
+		"":                                    "",
+		strings.Repeat("é", p.MaxHint):        strings.Repeat("é", p.MaxHint),
+		strings.Repeat("x", p.MaxHint) + "\n": strings.Repeat("x", p.MaxHint),
+	}
+	for hint, want := range tests {
+		if got, err := ValidateHint(hint, p); err != nil || got != want {
+			t.Errorf("ValidateHint(%q) = %q, %v; want %q, nil", hint, got, err, want)
+		}
+	}
+
+	if _, err := ValidateHint(strings.Repeat("é", p.MaxHint+1), p); !errors.Is(err, ErrInvalidHint) {
+		t.Errorf("ValidateHint() of %d characters error = %v, want ErrInvalidHint", p.MaxHint+1, err)
+	}
+}
+
+func TestValidateContentType(t *testing.T) {
+	tests := map[string]string{
+		"":                         "",
+		"text/plain":               "text/plain",
+		" Application/JSON ":       "application/json",
+		"application/octet-stream": "application/octet-stream",
+	}
+	for contentType, want := range tests {
+		if got, err := ValidateContentType(contentType); err != nil || got != want {
+			t.Errorf("ValidateContentType(%q) = %q, %v; want %q, nil", contentType, got, err, want)
+		}
+	}
+
+	for _, contentType := range []string{"text/html", "image/svg+xml", "application/javascript", "text/plain; charset=utf-8", "text/*"} {
+		if _, err := ValidateContentType(contentType); !errors.Is(err, ErrInvalidContentType) {
+			t.Errorf("ValidateContentType(%q) error = %v, want ErrInvalidContentType", contentType, err)
+		}
+	}
+}
+
+func TestValidateFilename(t *testing.T) {
+	tests := map[string]string{
+		"":                                     "",
+		"id_ed25519":                           "id_ed25519",
+		"  prod .env  ":                        "prod .env",
+		"résumé (final).pdf":                   "résumé (final).pdf",
+		"..hidden":                             "..hidden",
+		strings.Repeat("é", MaxFilenameLength): strings.Repeat("é", MaxFilenameLength),
+	}
+	for name, want := range tests {
+		if got, err := ValidateFilename(name); err != nil || got != want {
+			t.Errorf("ValidateFilename(%q) = %q, %v; want %q, nil", name, got, err, want)
+		}
+	}
+
+	for _, name := range []string{
+		"../etc/passwd",
+		"keys/prod.pem",
+		`C:\secrets.txt`,
+		".",
+		"..",
+		"report\x00.pdf",
+		"line\nbreak.txt",
+		"invoice\u202Efdp.exe",
+		"\xff.bin",
+		strings.Repeat("x", MaxFilenameLength+1),
+	} {
+		if _, err := ValidateFilename(name); !errors.Is(err, ErrInvalidFilename) {
+			t.Errorf("ValidateFilename(%q) error = %v, want ErrInvalidFilename", name, err)
+		}
+	}
+}
+
+func TestValidateSlug(t *testing.T) {
+	for _, slug := range []string{"deploy-key", "12345678", "-release-", strings.Repeat("x", 7) + "-" + strings.Repeat("y", 56)} {
+		if err := ValidateSlug(slug); err != nil {
+			t.Errorf("ValidateSlug(%q) error = %v", slug, err)
+		}
+	}
+	for _, slug := range []string{
+		"",
+		"short",
+		"Deploy-Key",
+		"deploy_key",
+		"deploy key",
+		strings.Repeat("x", 65),
+		// Shaped like generated IDs, with and without a region
+		strings.Repeat("a", 22),
+		"eu" + strings.Repeat("a", 22),
+	} {
+		if err := ValidateSlug(slug); !errors.Is(err, ErrInvalidSlug) {
+			t.Errorf("ValidateSlug(%q) error = %v, want ErrInvalidSlug", slug, err)
+		}
+	}
+}
+
 func TestValidatePlaintextContent(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -210,7 +463,7 @@ func TestValidatePlaintextContent(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := ValidatePlaintextContent(tt.content, tt.maxSize)
+			err := ValidatePlaintextContent(tt.content, withMaxSize(tt.maxSize))
 			if (err != nil) != tt.wantErr {
 				t.Fatalf("ValidatePlaintextContent() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -246,10 +499,199 @@ func TestValidateTTL(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := ValidateTTL(tt.expiresIn)
+			_, err := ValidateTTL(tt.expiresIn, policy.Default())
 			if (err != nil) != tt.wantErr {
 				t.Fatalf("ValidateTTL() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+func TestValidateAvailableAfter(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	ttl := time.Hour
+
+	tests := []struct {
+		raw     string
+		want    time.Time
+		wantErr bool
+	}{
+		{raw: ``},
+		{raw: `null`},
+		{raw: `0`},
+		{raw: `60`, want: now.Add(time.Minute)},
+		{raw: `3599`, want: now.Add(3599 * time.Second)},
+		{raw: `3600`, wantErr: true},
+		{raw: `"2026-03-01T12:30:00Z"`, want: now.Add(30 * time.Minute)},
+		{raw: `"2026-03-01T13:30:00+02:00"`},
+		{raw: `"2026-03-01T13:00:00Z"`, wantErr: true},
+		{raw: `"2026-03-01 12:30"`, wantErr: true},
+		{raw: `-5`, wantErr: true},
+		{raw: `1e3`, wantErr: true},
+		{raw: `{}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ValidateAvailableAfter([]byte(tt.raw), now, ttl)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidAvailableAfter) {
+				t.Errorf("ValidateAvailableAfter(%s) error = %v, want ErrInvalidAvailableAfter", tt.raw, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ValidateAvailableAfter(%s) error: %v", tt.raw, err)
+			continue
+		}
+		if tt.want.IsZero() != (got == nil) || got != nil && !got.Equal(tt.want) {
+			t.Errorf("ValidateAvailableAfter(%s) = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestValidateDeclaredKeyBits(t *testing.T) {
+	bits := func(n int) *int { return &n }
+
+	tests := []struct {
+		name           string
+		bits           *int
+		minBits        int
+		missing        string
+		wantUndeclared bool
+		wantErr        error
+	}{
+		{name: "no policy, undeclared", bits: nil, minBits: 0, missing: policy.KeyBitsMissingReject, wantUndeclared: true},
+		{name: "no policy, weak declared", bits: bits(64), minBits: 0, missing: policy.KeyBitsMissingAllow},
+		{name: "strong enough", bits: bits(128), minBits: 128, missing: policy.KeyBitsMissingReject},
+		{name: "too weak", bits: bits(64), minBits: 128, missing: policy.KeyBitsMissingAllow, wantErr: ErrKeyTooWeak},
+		{name: "zero bits", bits: bits(0), minBits: 128, missing: policy.KeyBitsMissingAllow, wantErr: ErrInvalidKeyBits},
+		{name: "absurd bits", bits: bits(policy.DefaultMaxDeclaredKeyBits + 1), minBits: 0, missing: policy.KeyBitsMissingAllow, wantErr: ErrInvalidKeyBits},
+		{name: "missing, reject", bits: nil, minBits: 128, missing: policy.KeyBitsMissingReject, wantErr: ErrKeyBitsRequired},
+		{name: "missing, warn", bits: nil, minBits: 128, missing: policy.KeyBitsMissingWarn, wantUndeclared: true},
+		{name: "missing, allow", bits: nil, minBits: 128, missing: policy.KeyBitsMissingAllow, wantUndeclared: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			undeclared, err := ValidateDeclaredKeyBits(tt.bits, &policy.Policy{
+				MinKeyBits:         tt.minBits,
+				KeyBitsMissing:     tt.missing,
+				MaxDeclaredKeyBits: policy.DefaultMaxDeclaredKeyBits,
+			})
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ValidateDeclaredKeyBits() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ValidateDeclaredKeyBits() unexpected error: %v", err)
+			}
+
+			if undeclared != tt.wantUndeclared {
+				t.Errorf("ValidateDeclaredKeyBits() undeclared = %v, want %v", undeclared, tt.wantUndeclared)
+			}
+		})
+	}
+}
+
+func TestValidateMultipartRequest(t *testing.T) {
+	iv := base64.StdEncoding.EncodeToString(make([]byte, 12))
+	part := func(label string, size int) models.SecretPart {
+		return models.SecretPart{
+			Label:      label,
+			Ciphertext: base64.StdEncoding.EncodeToString(make([]byte, size)),
+			IV:         iv,
+		}
+	}
+
+	tooMany := make([]models.SecretPart, policy.DefaultMaxParts+1)
+	for i := range tooMany {
+		tooMany[i] = part(strings.Repeat("p", i+1), 8)
+	}
+
+	tests := []struct {
+		name       string
+		ciphertext string
+		parts      []models.SecretPart
+		maxSize    int
+		wantErr    error
+	}{
+		{name: "three parts", parts: []models.SecretPart{part("username", 16), part("password", 16), part("totp", 16)}, maxSize: 1024},
+		{name: "blob and parts", ciphertext: "dGVzdA==", parts: []models.SecretPart{part("a", 8)}, maxSize: 1024, wantErr: ErrInvalidParts},
+		{name: "too many parts", parts: tooMany, maxSize: 1024, wantErr: ErrInvalidParts},
+		{name: "empty label", parts: []models.SecretPart{part(" ", 8)}, maxSize: 1024, wantErr: ErrInvalidParts},
+		{name: "control char label", parts: []models.SecretPart{part("a\x00b", 8)}, maxSize: 1024, wantErr: ErrInvalidParts},
+		{name: "duplicate label", parts: []models.SecretPart{part("a", 8), part("a", 8)}, maxSize: 1024, wantErr: ErrInvalidParts},
+		{name: "bad part iv", parts: []models.SecretPart{{Label: "a", Ciphertext: "dGVzdA==", IV: "AAAA"}}, maxSize: 1024, wantErr: ErrInvalidIV},
+		{name: "combined size over limit", parts: []models.SecretPart{part("a", 600), part("b", 600)}, maxSize: 1024, wantErr: ErrSecretTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateMultipartRequest(tt.ciphertext, "", "", tt.parts, 3600, withMaxSize(tt.maxSize))
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ValidateMultipartRequest() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ValidateMultipartRequest() unexpected error: %v", err)
+			}
+
+			if len(got.Parts) != len(tt.parts) {
+				t.Errorf("parts = %d, want %d", len(got.Parts), len(tt.parts))
+			}
+
+			if got.Size() != 48 {
+				t.Errorf("Size() = %d, want 48", got.Size())
+			}
+		})
+	}
+}
+
+// withMaxSize returns the default policy with a different size limit
+func withMaxSize(maxSize int) *policy.Policy {
+	p := policy.Default()
+	p.MaxSecretSize = maxSize
+	return p
+}
+
+func TestValidateNotifyEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		want    string
+		wantErr bool
+	}{
+		{name: "bare address", address: "alice@example.com", want: "alice@example.com"},
+		{name: "surrounding space", address: "  alice@example.com\t", want: "alice@example.com"},
+		{name: "empty", address: "", wantErr: true},
+		{name: "no domain", address: "alice", wantErr: true},
+		{name: "display name", address: "Alice <alice@example.com>", wantErr: true},
+		{name: "angle brackets", address: "<alice@example.com>", wantErr: true},
+		{name: "two addresses", address: "alice@example.com, bob@example.com", wantErr: true},
+		{name: "header injection", address: "alice@example.com\r\nBcc: bob@example.com", wantErr: true},
+		{name: "too long", address: strings.Repeat("a", 250) + "@example.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateNotifyEmail(tt.address)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateNotifyEmail() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidNotifyEmail) {
+				t.Errorf("ValidateNotifyEmail() error = %v, want ErrInvalidNotifyEmail", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("ValidateNotifyEmail() = %q, want %q", string(got), tt.want)
+			}
+		})
+	}
+}
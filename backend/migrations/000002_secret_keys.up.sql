@@ -0,0 +1,16 @@
+-- Per-secret data keys for crypto-shredding mode
+
+-- Rows written in shredding mode store ciphertext wrapped with a data key
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS key_wrapped BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS secret_keys (
+    secret_id VARCHAR(32) PRIMARY KEY REFERENCES secrets(id) ON DELETE CASCADE,
+    data_key BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Lets the cleanup worker find wrapped rows whose key has been shredded
+CREATE INDEX IF NOT EXISTS idx_secrets_key_wrapped ON secrets(id) WHERE key_wrapped;
+
+COMMENT ON TABLE secret_keys IS 'Per-secret data keys; deleting a row crypto-shreds the secret';
+COMMENT ON COLUMN secrets.key_wrapped IS 'Whether ciphertext is wrapped with a key in secret_keys';
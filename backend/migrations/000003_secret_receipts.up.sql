@@ -0,0 +1,13 @@
+-- Read receipts record that a secret was consumed without retaining client IPs
+
+CREATE TABLE IF NOT EXISTS secret_receipts (
+    secret_id VARCHAR(32) PRIMARY KEY,
+    consumed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    network_class VARCHAR(64)
+);
+
+-- Index for retention cleanup
+CREATE INDEX IF NOT EXISTS idx_secret_receipts_consumed_at ON secret_receipts(consumed_at);
+
+COMMENT ON TABLE secret_receipts IS 'Read receipts for consumed secrets; never stores client IPs';
+COMMENT ON COLUMN secret_receipts.network_class IS 'Operator-defined network label of the reader, e.g. corp-vpn or external';
@@ -0,0 +1,5 @@
+-- Client-declared link key length, kept for policy audits and stats
+
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS declared_key_bits SMALLINT;
+
+COMMENT ON COLUMN secrets.declared_key_bits IS 'Key length the client declared at create; never returned to readers';
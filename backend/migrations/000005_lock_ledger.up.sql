@@ -0,0 +1,23 @@
+-- Ledger of advisory lock holders so stale holders can be detected and broken
+
+CREATE TABLE IF NOT EXISTS lock_ledger (
+    lock_name VARCHAR(64) PRIMARY KEY,
+    instance_id VARCHAR(128) NOT NULL,
+    backend_pid INTEGER NOT NULL,
+    acquired_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    heartbeat_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Audit trail of forcibly broken locks
+CREATE TABLE IF NOT EXISTS lock_breaks (
+    id BIGSERIAL PRIMARY KEY,
+    lock_name VARCHAR(64) NOT NULL,
+    holder_instance_id VARCHAR(128) NOT NULL,
+    holder_backend_pid INTEGER NOT NULL,
+    holder_heartbeat_at TIMESTAMPTZ NOT NULL,
+    broken_by VARCHAR(128) NOT NULL,
+    broken_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE lock_ledger IS 'Current holder of each advisory lock, refreshed by heartbeat';
+COMMENT ON TABLE lock_breaks IS 'Audit log of stale advisory lock holders terminated by another instance';
@@ -0,0 +1,13 @@
+-- Multi-part secrets: independently encrypted labelled parts of one secret
+
+CREATE TABLE IF NOT EXISTS secret_parts (
+    secret_id VARCHAR(32) NOT NULL REFERENCES secrets(id) ON DELETE CASCADE,
+    position SMALLINT NOT NULL,
+    label VARCHAR(64) NOT NULL,
+    ciphertext BYTEA NOT NULL,
+    iv BYTEA NOT NULL,
+    PRIMARY KEY (secret_id, position)
+);
+
+COMMENT ON TABLE secret_parts IS 'Labelled parts of a multi-part secret; consumed with the parent row';
+COMMENT ON COLUMN secret_parts.ciphertext IS 'AES-256-GCM encrypted part ciphertext (wrapped in crypto-shredding mode)';
@@ -0,0 +1,5 @@
+-- Management tokens authorize burning; only their SHA-256 hash is stored
+
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS management_token_hash BYTEA;
+
+COMMENT ON COLUMN secrets.management_token_hash IS 'SHA-256 of the management token returned once at create';
@@ -0,0 +1,14 @@
+-- Secrets created with require_ack are held after the first read until the
+-- recipient acknowledges them or the acknowledgement window runs out
+
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS require_ack BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS ack_token_hash BYTEA;
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS ack_deadline TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_secrets_ack_deadline ON secrets(ack_deadline) WHERE ack_deadline IS NOT NULL;
+
+ALTER TABLE secret_receipts ADD COLUMN IF NOT EXISTS acknowledged BOOLEAN;
+
+COMMENT ON COLUMN secrets.ack_token_hash IS 'SHA-256 of the ack token handed to the reader; set once the secret was delivered';
+COMMENT ON COLUMN secrets.ack_deadline IS 'When an unacknowledged delivered secret is burned anyway';
+COMMENT ON COLUMN secret_receipts.acknowledged IS 'Whether the reader acknowledged a require_ack secret; NULL for other secrets';
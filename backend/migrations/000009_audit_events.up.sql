@@ -0,0 +1,17 @@
+-- Audit log of secret lifecycle events. IDs are ULIDs, so the primary key
+-- orders events by time and doubles as the pagination keyset; raw secret IDs
+-- are never stored, only their SHA-256.
+
+CREATE TABLE IF NOT EXISTS audit_events (
+    id CHAR(26) COLLATE "C" PRIMARY KEY,
+    event_type TEXT NOT NULL,
+    occurred_at TIMESTAMPTZ NOT NULL,
+    namespace TEXT NOT NULL DEFAULT '',
+    secret_id_hash TEXT COLLATE "C" NOT NULL,
+    network_class TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_type_id ON audit_events(event_type, id);
+
+COMMENT ON TABLE audit_events IS 'Secret lifecycle events for the admin audit listing';
+COMMENT ON COLUMN audit_events.secret_id_hash IS 'Hex SHA-256 of the secret ID; filterable by prefix';
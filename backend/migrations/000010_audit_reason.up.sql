@@ -0,0 +1,6 @@
+-- Recipients can report a consumed secret as compromised; the report's
+-- reason is one of a fixed set, never free text
+
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS reason TEXT;
+
+COMMENT ON COLUMN audit_events.reason IS 'Reason code of a secret.reported event; NULL for other events';
@@ -0,0 +1,6 @@
+-- Events an operator causes, such as generating a support dossier, name the
+-- admin credential's label
+
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS actor TEXT;
+
+COMMENT ON COLUMN audit_events.actor IS 'Label of the admin credential behind an operator event; NULL for events clients cause';
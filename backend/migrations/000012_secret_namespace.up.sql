@@ -0,0 +1,8 @@
+-- Secrets may belong to a tenant namespace, so one deployment can report
+-- usage and purge per team
+
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS namespace TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_secrets_namespace ON secrets(namespace) WHERE namespace IS NOT NULL;
+
+COMMENT ON COLUMN secrets.namespace IS 'Tenant slug from the create request; NULL for secrets without one. Never returned to readers';
@@ -0,0 +1,6 @@
+-- Some clients send one blob with the nonce prepended to the ciphertext
+-- instead of a separate IV; the row keeps an empty iv and this flag
+
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS iv_embedded BOOLEAN NOT NULL DEFAULT FALSE;
+
+COMMENT ON COLUMN secrets.iv_embedded IS 'Whether ciphertext starts with its nonce; iv is empty when set';
@@ -0,0 +1,16 @@
+-- Losses of async work the server gave up on under overload or during
+-- shutdown. Rows are aggregated per flush, so one row can stand for many
+-- dropped items; they are written best-effort and pruned after a week.
+
+CREATE TABLE IF NOT EXISTS dropped_work (
+    id BIGSERIAL PRIMARY KEY,
+    kind TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    count BIGINT NOT NULL,
+    recorded_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_dropped_work_recorded_at ON dropped_work(recorded_at);
+
+COMMENT ON TABLE dropped_work IS 'Dropped async work by kind and reason, summarized by the admin stats endpoint';
+COMMENT ON COLUMN dropped_work.count IS 'Items dropped for this kind and reason since the previous flush';
@@ -0,0 +1,7 @@
+-- Custom slugs may stand in for generated IDs when ALLOW_SLUGS is on and
+-- run to 64 characters, so every column holding a secret ID widens to fit
+
+ALTER TABLE secrets ALTER COLUMN id TYPE VARCHAR(64);
+ALTER TABLE secret_keys ALTER COLUMN secret_id TYPE VARCHAR(64);
+ALTER TABLE secret_parts ALTER COLUMN secret_id TYPE VARCHAR(64);
+ALTER TABLE secret_receipts ALTER COLUMN secret_id TYPE VARCHAR(64);
@@ -0,0 +1,10 @@
+-- Creates counted per power-of-two size class. Only the class is recorded,
+-- so the distribution cannot be joined back to individual secrets.
+
+CREATE TABLE IF NOT EXISTS secret_size_buckets (
+    upper_bound BIGINT PRIMARY KEY,
+    count BIGINT NOT NULL
+);
+
+COMMENT ON TABLE secret_size_buckets IS 'Create counts per size class, summarized by the admin stats endpoint';
+COMMENT ON COLUMN secret_size_buckets.upper_bound IS 'Largest ciphertext size in bytes the class holds; always a power of two';
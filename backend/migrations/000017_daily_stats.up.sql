@@ -0,0 +1,14 @@
+-- Usage totals per UTC day for operator reporting. Rows hold counts only,
+-- never secret IDs, so the table cannot tie activity to a secret.
+
+CREATE TABLE IF NOT EXISTS daily_stats (
+    day DATE PRIMARY KEY,
+    created BIGINT NOT NULL DEFAULT 0,
+    retrieved BIGINT NOT NULL DEFAULT 0,
+    burned BIGINT NOT NULL DEFAULT 0,
+    expired BIGINT NOT NULL DEFAULT 0,
+    bytes BIGINT NOT NULL DEFAULT 0
+);
+
+COMMENT ON TABLE daily_stats IS 'Secrets created, read, burned and expired per UTC day, served by the admin stats endpoint';
+COMMENT ON COLUMN daily_stats.bytes IS 'Ciphertext bytes stored by the day''s creates; not recorded in strict privacy mode';
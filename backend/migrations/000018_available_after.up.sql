@@ -0,0 +1,6 @@
+-- Scheduled release: reads are refused, and the secret left in place,
+-- until available_after passes
+
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS available_after TIMESTAMPTZ;
+
+COMMENT ON COLUMN secrets.available_after IS 'Reads are refused before this time; NULL for none';
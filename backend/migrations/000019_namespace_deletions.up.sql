@@ -0,0 +1,20 @@
+-- Progress of namespace deletions. A row blocks creates into its namespace
+-- for good; the counts are added in the transactions that remove the rows,
+-- so a job stopped and resumed counts each row once.
+
+CREATE TABLE IF NOT EXISTS namespace_deletions (
+    namespace TEXT PRIMARY KEY,
+    started_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ,
+    secrets_deleted BIGINT NOT NULL DEFAULT 0,
+    live_secrets_deleted BIGINT NOT NULL DEFAULT 0,
+    receipts_deleted BIGINT NOT NULL DEFAULT 0,
+    audit_events_anonymized BIGINT NOT NULL DEFAULT 0
+);
+
+-- Deletion batches walk a namespace's audit events in ID order
+CREATE INDEX IF NOT EXISTS idx_audit_events_namespace_id ON audit_events(namespace, id) WHERE namespace <> '';
+
+COMMENT ON TABLE namespace_deletions IS 'Namespaces deleted through the admin API and how far each deletion has got';
+COMMENT ON COLUMN namespace_deletions.finished_at IS 'Set once no secret or audit event names the namespace; NULL while the job still has rows to remove';
+COMMENT ON COLUMN namespace_deletions.audit_events_anonymized IS 'Audit events kept with their namespace cleared';
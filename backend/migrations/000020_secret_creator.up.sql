@@ -0,0 +1,8 @@
+-- Secrets remember a hash of who created them, so an active-secret quota
+-- can cap how many live secrets one client holds
+
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS creator TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_secrets_creator ON secrets(creator, expires_at) WHERE creator IS NOT NULL;
+
+COMMENT ON COLUMN secrets.creator IS 'SHA-256 of the creating client IP while the active-secret quota is on; NULL otherwise. Never returned to readers';
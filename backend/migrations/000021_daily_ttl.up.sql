@@ -0,0 +1,6 @@
+-- Daily totals also sum the TTLs creates chose, so capacity projections
+-- know how long secrets are meant to live
+
+ALTER TABLE daily_stats ADD COLUMN IF NOT EXISTS ttl_seconds BIGINT NOT NULL DEFAULT 0;
+
+COMMENT ON COLUMN daily_stats.ttl_seconds IS 'Sum of the TTLs, in seconds, the day''s creates chose; 0 on days before it was recorded';
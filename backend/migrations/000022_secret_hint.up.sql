@@ -0,0 +1,5 @@
+-- Plaintext preview shown before a read; deleted with the row on consume
+
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS hint TEXT;
+
+COMMENT ON COLUMN secrets.hint IS 'HTML-escaped sender hint shown before a read; NULL for none';
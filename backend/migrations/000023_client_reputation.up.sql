@@ -0,0 +1,16 @@
+-- Rate limit reputations for ADAPTIVE_RATE_LIMIT. Each replica scores the
+-- clients it serves and writes the scores here so the others adopt them.
+-- Clients are keyed by a hash of their IP, never the IP itself.
+
+CREATE TABLE IF NOT EXISTS client_reputation (
+    client_key TEXT PRIMARY KEY,
+    score DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_client_reputation_score ON client_reputation(score);
+CREATE INDEX IF NOT EXISTS idx_client_reputation_updated_at ON client_reputation(updated_at);
+
+COMMENT ON TABLE client_reputation IS 'Adaptive rate limit score per client, pruned once it has decayed back to neutral';
+COMMENT ON COLUMN client_reputation.client_key IS 'Hex SHA-256 of the client IP with a limit: prefix';
+COMMENT ON COLUMN client_reputation.score IS '0 (abusive) to 1 (well-behaved) as of updated_at; 0.5 is neutral';
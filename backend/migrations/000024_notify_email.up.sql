@@ -0,0 +1,10 @@
+-- Address told when a secret is read or expires unread, sealed with the
+-- server's notification key, and its hash for lookups without the key
+
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS notify_email BYTEA;
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS notify_email_hash TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_secrets_notify_expiry ON secrets(expires_at) WHERE notify_email IS NOT NULL;
+
+COMMENT ON COLUMN secrets.notify_email IS 'AES-256-GCM sealed notification address; NULL for none or once claimed';
+COMMENT ON COLUMN secrets.notify_email_hash IS 'SHA-256 of "email:" and the lowercased address';
@@ -0,0 +1,6 @@
+-- Version of the envelope key wrapping each data key; NULL for data keys
+-- stored before envelope keys were configured
+
+ALTER TABLE secret_keys ADD COLUMN IF NOT EXISTS key_version TEXT;
+
+COMMENT ON COLUMN secret_keys.key_version IS 'First 8 bytes of SHA-256 of the envelope key wrapping data_key, hex; NULL when data_key is stored as it is';
@@ -0,0 +1,13 @@
+-- Stored ciphertext bytes per secret, parts included, and an expiry index
+-- covering what the expiry forecast reads, so it scans the index only
+
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS stored_bytes BIGINT;
+
+UPDATE secrets s
+SET stored_bytes = octet_length(s.ciphertext)
+    + COALESCE((SELECT SUM(octet_length(p.ciphertext)) FROM secret_parts p WHERE p.secret_id = s.id), 0)
+WHERE s.stored_bytes IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_secrets_expiry_forecast ON secrets(expires_at) INCLUDE (id, namespace, key_wrapped, stored_bytes);
+
+COMMENT ON COLUMN secrets.stored_bytes IS 'Ciphertext bytes of the secret and its parts, set at create';
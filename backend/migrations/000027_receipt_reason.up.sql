@@ -0,0 +1,6 @@
+-- Receipts double as tombstones: burns and expiries leave one too, and the
+-- reason says which ending it records. Existing receipts are all reads.
+
+ALTER TABLE secret_receipts ADD COLUMN IF NOT EXISTS reason VARCHAR(16) NOT NULL DEFAULT 'consumed';
+
+COMMENT ON COLUMN secret_receipts.reason IS 'How the secret ended: consumed, burned or expired';
@@ -0,0 +1,8 @@
+-- Sender-declared content type and file name, returned with the secret so
+-- the recipient knows how to render what it decrypts to
+
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS content_type VARCHAR(64);
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS filename VARCHAR(1024);
+
+COMMENT ON COLUMN secrets.content_type IS 'Sender-declared type of the plaintext from a fixed allowlist; NULL for none';
+COMMENT ON COLUMN secrets.filename IS 'Sender-declared file name without path separators or control characters; NULL for none';
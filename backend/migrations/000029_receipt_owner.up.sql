@@ -0,0 +1,9 @@
+-- Tombstones answer the creator's receipt query after the secret is gone:
+-- they keep the secret's management token hash to check the caller, and
+-- optionally the reader's country. Both go when the receipt is pruned.
+
+ALTER TABLE secret_receipts ADD COLUMN IF NOT EXISTS management_token_hash BYTEA;
+ALTER TABLE secret_receipts ADD COLUMN IF NOT EXISTS country VARCHAR(2);
+
+COMMENT ON COLUMN secret_receipts.management_token_hash IS 'The ended secret''s management token hash, checked by the receipt endpoint';
+COMMENT ON COLUMN secret_receipts.country IS 'Coarse ISO 3166-1 alpha-2 country of the reader; NULL when GeoIP is off';
@@ -0,0 +1,7 @@
+-- Creators were a plain SHA-256 of the client IP, which hashing every IPv4
+-- address reverses. They are now HMACs under CLIENT_HASH_KEY; the old
+-- values are cleared, so secrets created before count against no quota.
+
+UPDATE secrets SET creator = NULL WHERE creator IS NOT NULL;
+
+COMMENT ON COLUMN secrets.creator IS 'HMAC-SHA256 of the creating client IP under CLIENT_HASH_KEY while the active-secret quota is on; NULL otherwise. Never returned to readers';
@@ -0,0 +1,7 @@
+-- Client keys were a plain SHA-256 of the client IP, which hashing every
+-- IPv4 address reverses. They are now HMACs under CLIENT_HASH_KEY; the old
+-- scores are deleted and every client starts again from neutral.
+
+DELETE FROM client_reputation;
+
+COMMENT ON COLUMN client_reputation.client_key IS 'Hex HMAC-SHA256 of the client IP with a limit: prefix under CLIENT_HASH_KEY';
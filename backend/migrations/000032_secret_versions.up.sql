@@ -0,0 +1,12 @@
+-- Each secret keeps a version, its revision, so holders of its management
+-- token can make a change conditional on the state they last saw. It starts
+-- at 1 and every state change adds one: an ack or grace hold, a lowered
+-- expiry, and the ending, which writes the new version on the receipt. An
+-- acknowledgement adds one to the receipt.
+
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+-- Receipts written before versions end a secret at its first version
+ALTER TABLE secret_receipts ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 2;
+
+COMMENT ON COLUMN secrets.version IS 'Revision of the secret, sent back in If-Match; bumped on every state change';
+COMMENT ON COLUMN secret_receipts.version IS 'Revision of the ended secret, one past the last stored on its row';
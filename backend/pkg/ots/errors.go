@@ -0,0 +1,193 @@
+// Package ots exposes the service's error taxonomy to embedders. Every error
+// the API can return maps to exactly one HTTP status and one stable
+// machine-readable code, so host applications can translate errors into
+// their own HTTP or gRPC envelopes without matching on message text.
+package ots
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Service errors. The server's own packages return these very values, so
+// errors.Is works on anything it returns.
+var (
+	// ErrInvalidRequestBody indicates the request body could not be decoded
+	ErrInvalidRequestBody = errors.New("invalid request body")
+	// ErrNotFound indicates the secret does not exist, has expired or was consumed
+	ErrNotFound = errors.New("not found")
+	// ErrManagementTokenRequired indicates a burn or link request without a
+	// valid management token
+	ErrManagementTokenRequired = errors.New("valid X-Management-Token header required")
+	// ErrLookupThrottled indicates lookups of unknown IDs are being refused
+	// because of a service-wide flood of failed lookups
+	ErrLookupThrottled = errors.New("too many failed lookups, retry later")
+	// ErrRateLimited indicates a client sent more requests than its budget
+	// allows in the current window
+	ErrRateLimited = errors.New("rate limit exceeded")
+	// ErrCreateNonceRequired indicates a browser create without a valid
+	// nonce cookie and matching X-Create-Nonce header
+	ErrCreateNonceRequired = errors.New("valid create nonce required")
+	// ErrCreateNonceExpired indicates the create nonce is past its expiry;
+	// fetch a new one from /api/secrets/nonce
+	ErrCreateNonceExpired = errors.New("create nonce expired")
+	// ErrInvalidAuditQuery indicates a malformed filter or cursor on the
+	// admin audit listing
+	ErrInvalidAuditQuery = errors.New("invalid audit query")
+	// ErrInvalidStatsQuery indicates a malformed or oversized date range on
+	// the admin stats endpoint
+	ErrInvalidStatsQuery = errors.New("invalid stats query")
+	// ErrInvalidReputationQuery indicates a malformed limit or IP on the
+	// admin reputation listing
+	ErrInvalidReputationQuery = errors.New("invalid reputation query")
+	// ErrWrongRegion indicates a secret ID created by another regional
+	// deployment; the response names the region and, when known, its URL
+	ErrWrongRegion = errors.New("secret belongs to another region")
+	// ErrSlugTaken indicates a create whose slug names a stored secret, or
+	// one read recently enough that its receipt is still kept
+	ErrSlugTaken = errors.New("slug already in use")
+	// ErrMethodNotAllowed indicates a method the path does not serve; the
+	// Allow header lists those it does
+	ErrMethodNotAllowed = errors.New("method not allowed")
+
+	// ErrInvalidCiphertext indicates invalid ciphertext format
+	ErrInvalidCiphertext = errors.New("invalid ciphertext format")
+	// ErrInvalidIV indicates invalid IV format
+	ErrInvalidIV = errors.New("invalid IV format")
+	// ErrInvalidSalt indicates invalid salt format
+	ErrInvalidSalt = errors.New("invalid salt format")
+	// ErrInvalidPlaintext indicates invalid plaintext content
+	ErrInvalidPlaintext = errors.New("invalid plaintext content")
+	// ErrInvalidSecretID indicates invalid secret ID
+	ErrInvalidSecretID = errors.New("invalid secret ID")
+	// ErrInvalidTTL indicates invalid TTL value
+	ErrInvalidTTL = errors.New("invalid TTL value")
+	// ErrSecretTooLarge indicates secret exceeds maximum size
+	ErrSecretTooLarge = errors.New("secret exceeds maximum size")
+	// ErrInvalidParts indicates a malformed parts array
+	ErrInvalidParts = errors.New("invalid secret parts")
+	// ErrInvalidKeyBits indicates a nonsensical declared key length
+	ErrInvalidKeyBits = errors.New("invalid declared key bits")
+	// ErrKeyTooWeak indicates the declared key length is below policy
+	ErrKeyTooWeak = errors.New("declared key too weak")
+	// ErrKeyBitsRequired indicates policy requires a declared key length
+	ErrKeyBitsRequired = errors.New("declared key bits required")
+	// ErrInvalidNamespace indicates a namespace that is not a slug
+	ErrInvalidNamespace = errors.New("invalid namespace")
+	// ErrInvalidAlgorithm indicates an unknown algorithm for an embedded IV
+	ErrInvalidAlgorithm = errors.New("invalid algorithm")
+	// ErrInvalidSlug indicates a custom slug that is malformed, shaped like
+	// a generated ID, or not enabled
+	ErrInvalidSlug = errors.New("invalid slug")
+	// ErrInvalidAvailableAfter indicates a malformed release time or one
+	// not before the secret expires
+	ErrInvalidAvailableAfter = errors.New("invalid available_after")
+	// ErrInvalidHint indicates a hint longer than the policy allows
+	ErrInvalidHint = errors.New("invalid hint")
+	// ErrInvalidNotifyEmail indicates a notify_email that is not one bare
+	// address, or a server without notifications
+	ErrInvalidNotifyEmail = errors.New("invalid notify_email")
+	// ErrInvalidContentType indicates a content_type outside the allowed list
+	ErrInvalidContentType = errors.New("invalid content_type")
+	// ErrInvalidFilename indicates a filename that is too long or could
+	// be mistaken for a path or hide its extension
+	ErrInvalidFilename = errors.New("invalid filename")
+
+	// ErrNotYetAvailable indicates a read before a secret's scheduled
+	// release; the secret is left in place
+	ErrNotYetAvailable = errors.New("secret not yet available")
+	// ErrNamespaceDeleted indicates a create into a namespace an operator
+	// deleted; the namespace takes no new secrets
+	ErrNamespaceDeleted = errors.New("namespace deleted")
+	// ErrQuotaExceeded indicates a create from a client that already holds
+	// as many live secrets as the server allows one client
+	ErrQuotaExceeded = errors.New("active secret quota exceeded")
+	// ErrRevisionMismatch indicates a burn or ack whose If-Match names a
+	// revision the secret has moved past; ETag carries the current one
+	ErrRevisionMismatch = errors.New("secret revision does not match")
+
+	// ErrPolicyViolation indicates metadata rejected by a policy scanner;
+	// the error is a *Violation naming the rule
+	ErrPolicyViolation = errors.New("policy violation")
+)
+
+// CodeInternal is reported for errors outside the taxonomy
+const CodeInternal = "internal_error"
+
+// Mapping ties one error to its HTTP status and code
+type Mapping struct {
+	Err    error
+	Status int
+	Code   string
+}
+
+// Mappings is the complete error table. Codes are stable and unique;
+// embedders may render their own envelopes from it.
+var Mappings = []Mapping{
+	{Err: ErrInvalidRequestBody, Status: http.StatusBadRequest, Code: "invalid_request_body"},
+	{Err: ErrNotFound, Status: http.StatusNotFound, Code: "not_found"},
+	{Err: ErrManagementTokenRequired, Status: http.StatusUnauthorized, Code: "management_token_required"},
+	{Err: ErrLookupThrottled, Status: http.StatusTooManyRequests, Code: "lookup_throttled"},
+	{Err: ErrRateLimited, Status: http.StatusTooManyRequests, Code: "rate_limited"},
+	{Err: ErrCreateNonceRequired, Status: http.StatusForbidden, Code: "create_nonce_required"},
+	{Err: ErrCreateNonceExpired, Status: http.StatusForbidden, Code: "create_nonce_expired"},
+	{Err: ErrInvalidAuditQuery, Status: http.StatusBadRequest, Code: "invalid_audit_query"},
+	{Err: ErrInvalidStatsQuery, Status: http.StatusBadRequest, Code: "invalid_stats_query"},
+	{Err: ErrInvalidReputationQuery, Status: http.StatusBadRequest, Code: "invalid_reputation_query"},
+	{Err: ErrWrongRegion, Status: http.StatusMisdirectedRequest, Code: "wrong_region"},
+	{Err: ErrSlugTaken, Status: http.StatusConflict, Code: "slug_taken"},
+	{Err: ErrMethodNotAllowed, Status: http.StatusMethodNotAllowed, Code: "method_not_allowed"},
+	{Err: ErrInvalidCiphertext, Status: http.StatusBadRequest, Code: "invalid_ciphertext"},
+	{Err: ErrInvalidIV, Status: http.StatusBadRequest, Code: "invalid_iv"},
+	{Err: ErrInvalidSalt, Status: http.StatusBadRequest, Code: "invalid_salt"},
+	{Err: ErrInvalidPlaintext, Status: http.StatusBadRequest, Code: "invalid_plaintext"},
+	{Err: ErrInvalidSecretID, Status: http.StatusBadRequest, Code: "invalid_secret_id"},
+	{Err: ErrInvalidTTL, Status: http.StatusBadRequest, Code: "invalid_ttl"},
+	{Err: ErrSecretTooLarge, Status: http.StatusRequestEntityTooLarge, Code: "secret_too_large"},
+	{Err: ErrInvalidParts, Status: http.StatusBadRequest, Code: "invalid_parts"},
+	{Err: ErrInvalidKeyBits, Status: http.StatusBadRequest, Code: "invalid_key_bits"},
+	{Err: ErrKeyTooWeak, Status: http.StatusBadRequest, Code: "key_too_weak"},
+	{Err: ErrKeyBitsRequired, Status: http.StatusBadRequest, Code: "key_bits_required"},
+	{Err: ErrInvalidNamespace, Status: http.StatusBadRequest, Code: "invalid_namespace"},
+	{Err: ErrInvalidAlgorithm, Status: http.StatusBadRequest, Code: "invalid_algorithm"},
+	{Err: ErrInvalidSlug, Status: http.StatusBadRequest, Code: "invalid_slug"},
+	{Err: ErrInvalidAvailableAfter, Status: http.StatusBadRequest, Code: "invalid_available_after"},
+	{Err: ErrInvalidHint, Status: http.StatusBadRequest, Code: "invalid_hint"},
+	{Err: ErrInvalidNotifyEmail, Status: http.StatusBadRequest, Code: "invalid_notify_email"},
+	{Err: ErrInvalidContentType, Status: http.StatusBadRequest, Code: "invalid_content_type"},
+	{Err: ErrInvalidFilename, Status: http.StatusBadRequest, Code: "invalid_filename"},
+	{Err: ErrNotYetAvailable, Status: http.StatusForbidden, Code: "not_yet_available"},
+	{Err: ErrNamespaceDeleted, Status: http.StatusGone, Code: "tenant_deleted"},
+	{Err: ErrQuotaExceeded, Status: http.StatusTooManyRequests, Code: "quota_exceeded"},
+	{Err: ErrRevisionMismatch, Status: http.StatusPreconditionFailed, Code: "revision_mismatch"},
+	{Err: ErrPolicyViolation, Status: http.StatusUnprocessableEntity, Code: "policy_violation"},
+}
+
+// Lookup returns the mapping for err, following wrapped errors
+func Lookup(err error) (Mapping, bool) {
+	if err == nil {
+		return Mapping{}, false
+	}
+	for _, m := range Mappings {
+		if errors.Is(err, m.Err) {
+			return m, true
+		}
+	}
+	return Mapping{}, false
+}
+
+// StatusCode returns the HTTP status for err, or 500 for unknown errors
+func StatusCode(err error) int {
+	if m, ok := Lookup(err); ok {
+		return m.Status
+	}
+	return http.StatusInternalServerError
+}
+
+// ErrorCode returns the stable code for err, or CodeInternal for unknown errors
+func ErrorCode(err error) string {
+	if m, ok := Lookup(err); ok {
+		return m.Code
+	}
+	return CodeInternal
+}
@@ -0,0 +1,203 @@
+package ots
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStatusAndCode(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{"sentinel", ErrNotFound, http.StatusNotFound, "not_found"},
+		{"wrapped", fmt.Errorf("%w: 40000 bytes (max 32768)", ErrSecretTooLarge), http.StatusRequestEntityTooLarge, "secret_too_large"},
+		{"unknown", errors.New("connection reset"), http.StatusInternalServerError, CodeInternal},
+		{"nil", nil, http.StatusInternalServerError, CodeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StatusCode(tt.err); got != tt.wantStatus {
+				t.Errorf("StatusCode() = %d, want %d", got, tt.wantStatus)
+			}
+			if got := ErrorCode(tt.err); got != tt.wantCode {
+				t.Errorf("ErrorCode() = %q, want %q", got, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestMappingsAreUnambiguous(t *testing.T) {
+	codes := make(map[string]bool)
+	for _, m := range Mappings {
+		if m.Code == "" || m.Code == CodeInternal {
+			t.Errorf("%v has reserved or empty code %q", m.Err, m.Code)
+		}
+		if codes[m.Code] {
+			t.Errorf("code %q is used more than once", m.Code)
+		}
+		codes[m.Code] = true
+
+		if http.StatusText(m.Status) == "" || m.Status < 400 {
+			t.Errorf("%v has invalid status %d", m.Err, m.Status)
+		}
+
+		// Each error must match its own row and no other
+		matches := 0
+		for _, other := range Mappings {
+			if errors.Is(m.Err, other.Err) {
+				matches++
+			}
+		}
+		if matches != 1 {
+			t.Errorf("%v matches %d mappings, want 1", m.Err, matches)
+		}
+	}
+}
+
+// TestEveryServiceErrorIsMapped fails when a package the service returns
+// errors from declares an Err* sentinel that is missing from Mappings
+func TestEveryServiceErrorIsMapped(t *testing.T) {
+	mapped := make(map[string]bool)
+	for _, name := range exportedErrors(t, ".") {
+		mapped[name] = true
+	}
+
+	sources := map[string]string{
+		"validation": filepath.Join("..", "..", "internal", "validation"),
+		"scan":       filepath.Join("..", "..", "internal", "scan"),
+	}
+	for pkg, dir := range sources {
+		for _, name := range exportedErrors(t, dir) {
+			if !mapped[name] {
+				t.Errorf("%s.%s is not re-exported by ots", pkg, name)
+			}
+		}
+	}
+
+	for _, name := range exportedErrors(t, ".") {
+		err := errorsByName()[name]
+		if err == nil {
+			t.Errorf("ots.%s is missing from errorsByName", name)
+			continue
+		}
+		if _, ok := Lookup(err); !ok {
+			t.Errorf("ots.%s has no mapping", name)
+		}
+	}
+}
+
+// TestImportsNoInternalPackages keeps the dependency one way: the server's
+// packages map their errors onto ots, never the reverse, so embedders get
+// the taxonomy without pulling in the server
+func TestImportsNoInternalPackages(t *testing.T) {
+	paths, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatalf("glob: %v", err)
+	}
+
+	fset := token.NewFileSet()
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, parser.ImportsOnly)
+		if err != nil {
+			t.Fatalf("parse %s: %v", path, err)
+		}
+		for _, spec := range file.Imports {
+			if strings.Contains(spec.Path.Value, "/internal/") {
+				t.Errorf("%s imports %s", path, spec.Path.Value)
+			}
+		}
+	}
+}
+
+// exportedErrors lists the package-level Err* variables declared in dir
+func exportedErrors(t *testing.T, dir string) []string {
+	t.Helper()
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		t.Fatalf("glob %s: %v", dir, err)
+	}
+
+	var names []string
+	fset := token.NewFileSet()
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			t.Fatalf("parse %s: %v", path, err)
+		}
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				for _, name := range spec.(*ast.ValueSpec).Names {
+					if strings.HasPrefix(name.Name, "Err") && name.IsExported() {
+						names = append(names, name.Name)
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+// errorsByName resolves the ots sentinels found by exportedErrors
+func errorsByName() map[string]error {
+	return map[string]error{
+		"ErrInvalidRequestBody":      ErrInvalidRequestBody,
+		"ErrNotFound":                ErrNotFound,
+		"ErrManagementTokenRequired": ErrManagementTokenRequired,
+		"ErrCreateNonceRequired":     ErrCreateNonceRequired,
+		"ErrCreateNonceExpired":      ErrCreateNonceExpired,
+		"ErrInvalidAuditQuery":       ErrInvalidAuditQuery,
+		"ErrInvalidStatsQuery":       ErrInvalidStatsQuery,
+		"ErrInvalidReputationQuery":  ErrInvalidReputationQuery,
+		"ErrWrongRegion":             ErrWrongRegion,
+		"ErrMethodNotAllowed":        ErrMethodNotAllowed,
+		"ErrSlugTaken":               ErrSlugTaken,
+		"ErrLookupThrottled":         ErrLookupThrottled,
+		"ErrRateLimited":             ErrRateLimited,
+		"ErrInvalidCiphertext":       ErrInvalidCiphertext,
+		"ErrInvalidIV":               ErrInvalidIV,
+		"ErrInvalidSalt":             ErrInvalidSalt,
+		"ErrInvalidPlaintext":        ErrInvalidPlaintext,
+		"ErrInvalidSecretID":         ErrInvalidSecretID,
+		"ErrInvalidTTL":              ErrInvalidTTL,
+		"ErrSecretTooLarge":          ErrSecretTooLarge,
+		"ErrInvalidParts":            ErrInvalidParts,
+		"ErrInvalidKeyBits":          ErrInvalidKeyBits,
+		"ErrKeyTooWeak":              ErrKeyTooWeak,
+		"ErrKeyBitsRequired":         ErrKeyBitsRequired,
+		"ErrInvalidNamespace":        ErrInvalidNamespace,
+		"ErrInvalidAlgorithm":        ErrInvalidAlgorithm,
+		"ErrInvalidSlug":             ErrInvalidSlug,
+		"ErrInvalidAvailableAfter":   ErrInvalidAvailableAfter,
+		"ErrInvalidHint":             ErrInvalidHint,
+		"ErrInvalidNotifyEmail":      ErrInvalidNotifyEmail,
+		"ErrInvalidContentType":      ErrInvalidContentType,
+		"ErrInvalidFilename":         ErrInvalidFilename,
+		"ErrNotYetAvailable":         ErrNotYetAvailable,
+		"ErrNamespaceDeleted":        ErrNamespaceDeleted,
+		"ErrQuotaExceeded":           ErrQuotaExceeded,
+		"ErrRevisionMismatch":        ErrRevisionMismatch,
+		"ErrPolicyViolation":         ErrPolicyViolation,
+	}
+}
@@ -0,0 +1,64 @@
+package ots_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"ots-backend/internal/validation"
+	"ots-backend/pkg/ots"
+)
+
+// hostError is the envelope a host application already uses for its own API
+type hostError struct {
+	Status int    `json:"status"`
+	Kind   string `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+// A host application translates ots errors into its own envelope using the
+// exported status and code, never the message text.
+func Example() {
+	err := validation.ValidateSecretID("not-a-valid-id")
+
+	json.NewEncoder(os.Stdout).Encode(hostError{
+		Status: ots.StatusCode(err),
+		Kind:   "ots." + ots.ErrorCode(err),
+		Detail: err.Error(),
+	})
+	// Output:
+	// {"status":400,"kind":"ots.invalid_secret_id","detail":"invalid secret ID: invalid format"}
+}
+
+// Embedders can render their own table, for example to document the codes.
+func ExampleMappings() {
+	for _, m := range ots.Mappings[:3] {
+		fmt.Printf("%d %s\n", m.Status, m.Code)
+	}
+	// Output:
+	// 400 invalid_request_body
+	// 404 not_found
+	// 401 management_token_required
+}
+
+// A host application adds its own metadata policy. The scanner sees part
+// labels and upload filenames, never ciphertext.
+func ExampleRegisterScanner() {
+	unregister := ots.RegisterScanner(ots.ScannerFunc(func(field ots.Field) *ots.Violation {
+		if field.Name == ots.FieldPartLabel && strings.Contains(strings.ToLower(field.Value), "prod") {
+			return &ots.Violation{Rule: "no-prod-labels", Field: field.Name}
+		}
+		return nil
+	}))
+	defer unregister()
+
+	err := validation.ValidateMetadata(ots.Field{Name: ots.FieldPartLabel, Value: "PROD database"})
+
+	var violation *ots.Violation
+	errors.As(err, &violation)
+	fmt.Println(ots.StatusCode(err), ots.ErrorCode(err), violation.Rule)
+	// Output:
+	// 422 policy_violation no-prod-labels
+}
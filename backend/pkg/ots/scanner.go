@@ -0,0 +1,86 @@
+package ots
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Metadata field names
+const (
+	FieldPartLabel = "part_label"
+	FieldFilename  = "filename"
+)
+
+// Field is one metadata value passed to a Scanner
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Violation names the rule a field broke; return one from Scan to reject
+// the create with 422 policy_violation. It matches ErrPolicyViolation with
+// errors.Is.
+type Violation struct {
+	Rule  string `json:"rule"`
+	Field string `json:"field"`
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("%v: %s rejected by rule %q", ErrPolicyViolation, v.Field, v.Rule)
+}
+
+// Is reports ErrPolicyViolation as the violation's sentinel
+func (v *Violation) Is(target error) bool {
+	return target == ErrPolicyViolation
+}
+
+// Scanner enforces policy on create metadata: part labels, upload filenames
+// and any other field the server can read. It never sees ciphertext. Scan
+// returns a violation, or nil to let the field pass, and is called
+// concurrently.
+type Scanner interface {
+	Scan(field Field) *Violation
+}
+
+// ScannerFunc adapts a function to Scanner
+type ScannerFunc func(field Field) *Violation
+
+// Scan calls f
+func (f ScannerFunc) Scan(field Field) *Violation {
+	return f(field)
+}
+
+var registry struct {
+	mu     sync.RWMutex
+	custom []*Scanner
+}
+
+// RegisterScanner adds a custom scanner to every create, after the rules
+// loaded from SCAN_RULES or SCAN_RULES_FILE. Call the returned function to
+// remove it.
+func RegisterScanner(s Scanner) (unregister func()) {
+	entry := &s
+	registry.mu.Lock()
+	registry.custom = append(registry.custom, entry)
+	registry.mu.Unlock()
+
+	return func() {
+		registry.mu.Lock()
+		defer registry.mu.Unlock()
+		registry.custom = slices.DeleteFunc(registry.custom, func(e *Scanner) bool { return e == entry })
+	}
+}
+
+// Scanners returns the custom scanners registered, in the order they were
+// added
+func Scanners() []Scanner {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	scanners := make([]Scanner, 0, len(registry.custom))
+	for _, entry := range registry.custom {
+		scanners = append(scanners, *entry)
+	}
+	return scanners
+}
@@ -0,0 +1,124 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SchemaV1 identifies the first payload schema version.
+//
+// Evolution rules: fields may be added to an existing version as long as they
+// are optional (omitempty) and consumers can ignore them. Renaming, removing,
+// or changing the type of a field requires a new version, which targets opt
+// into via Target.Schemas so both versions can be emitted side by side.
+const SchemaV1 = "ots.webhook.v1"
+
+// Event types emitted to webhook targets
+const (
+	EventConsumed = "secret.consumed"
+	EventBurned   = "secret.burned"
+	EventExpired  = "secret.expired"
+	// EventAcknowledged fires when the reader of a require_ack secret
+	// confirms receipt and the secret is burned
+	EventAcknowledged = "secret.acknowledged"
+	// EventExpiryReduced fires when a tightened TTL ceiling moves a
+	// secret's expiry earlier
+	EventExpiryReduced = "secret.expiry_reduced"
+	// EventStragglerRemoved fires when the cleanup worker finds a consumed
+	// secret still readable and destroys it; it is meant for operators
+	EventStragglerRemoved = "secret.straggler_removed"
+	// EventReported fires when a recipient reports a secret as possibly
+	// compromised
+	EventReported = "secret.reported"
+)
+
+// Event is the version-independent description of something that happened.
+// Encoders render it into a concrete payload version.
+type Event struct {
+	ID         string
+	Type       string
+	SecretID   string
+	OccurredAt time.Time
+	// NetworkClass is the operator-defined label of the reader's network
+	NetworkClass string
+}
+
+// PayloadV1 is the wire format for schema ots.webhook.v1
+type PayloadV1 struct {
+	Schema       string    `json:"schema"`
+	ID           string    `json:"id"`
+	Event        string    `json:"event"`
+	SecretID     string    `json:"secret_id"`
+	OccurredAt   time.Time `json:"occurred_at"`
+	NetworkClass string    `json:"network_class,omitempty"`
+}
+
+// encoders maps each supported schema version to its renderer
+var encoders = map[string]func(Event) any{
+	SchemaV1: func(e Event) any {
+		return PayloadV1{
+			Schema:       SchemaV1,
+			ID:           e.ID,
+			Event:        e.Type,
+			SecretID:     e.SecretID,
+			OccurredAt:   e.OccurredAt.UTC(),
+			NetworkClass: e.NetworkClass,
+		}
+	},
+}
+
+// Versions returns the schema versions this build can emit
+func Versions() []string {
+	return []string{SchemaV1}
+}
+
+// Encode renders e using the given schema version
+func Encode(schema string, e Event) ([]byte, error) {
+	encode, ok := encoders[schema]
+	if !ok {
+		return nil, fmt.Errorf("unknown schema %q", schema)
+	}
+
+	return json.Marshal(encode(e))
+}
+
+// Target is a webhook destination. Schemas lists the payload versions it
+// receives; more than one entry enables dual emission during a migration.
+type Target struct {
+	URL     string
+	Key     []byte
+	Schemas []string
+}
+
+// Delivery is a signed, ready-to-send payload for one schema version
+type Delivery struct {
+	Schema    string
+	Body      []byte
+	Signature string
+}
+
+// Deliveries renders and signs e for every schema version t subscribes to.
+// Targets with no explicit versions receive SchemaV1.
+func (t Target) Deliveries(e Event, now time.Time) ([]Delivery, error) {
+	schemas := t.Schemas
+	if len(schemas) == 0 {
+		schemas = []string{SchemaV1}
+	}
+
+	deliveries := make([]Delivery, 0, len(schemas))
+	for _, schema := range schemas {
+		body, err := Encode(schema, e)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, Delivery{
+			Schema:    schema,
+			Body:      body,
+			Signature: Sign(body, t.Key, now),
+		})
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"embed"
+	"encoding/json"
+	"time"
+)
+
+// TestKey is a published signing key used only to generate example payloads.
+// It must never be used for real deliveries.
+const TestKey = "whsec_test_0123456789abcdef0123456789abcdef"
+
+// exampleTime is the fixed timestamp used for examples so output is stable
+var exampleTime = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+//go:embed schema/*.json
+var schemaFS embed.FS
+
+// Example is a signed sample delivery
+type Example struct {
+	Payload   json.RawMessage `json:"payload"`
+	Signature string          `json:"signature"`
+}
+
+// VersionDocument describes one schema version
+type VersionDocument struct {
+	JSONSchema json.RawMessage `json:"json_schema"`
+	Examples   []Example       `json:"examples"`
+}
+
+// Document is the machine-readable description of the webhook contract
+type Document struct {
+	SignatureHeader string                     `json:"signature_header"`
+	SchemaHeader    string                     `json:"schema_header"`
+	Tolerance       int                        `json:"timestamp_tolerance_seconds"`
+	TestKey         string                     `json:"test_key"`
+	Versions        map[string]VersionDocument `json:"versions"`
+}
+
+// JSONSchema returns the JSON Schema for a version
+func JSONSchema(schema string) ([]byte, error) {
+	return schemaFS.ReadFile("schema/" + versionFile(schema))
+}
+
+// ExampleEvents returns one sample event per event type
+func ExampleEvents() []Event {
+	return []Event{
+		{ID: "evt_example_consumed", Type: EventConsumed, SecretID: "AAAAAAAAAAAAAAAAAAAAAA", OccurredAt: exampleTime},
+		{ID: "evt_example_burned", Type: EventBurned, SecretID: "AAAAAAAAAAAAAAAAAAAAAA", OccurredAt: exampleTime},
+		{ID: "evt_example_expired", Type: EventExpired, SecretID: "AAAAAAAAAAAAAAAAAAAAAA", OccurredAt: exampleTime},
+		{ID: "evt_example_acknowledged", Type: EventAcknowledged, SecretID: "AAAAAAAAAAAAAAAAAAAAAA", OccurredAt: exampleTime},
+		{ID: "evt_example_expiry_reduced", Type: EventExpiryReduced, SecretID: "AAAAAAAAAAAAAAAAAAAAAA", OccurredAt: exampleTime},
+		{ID: "evt_example_straggler_removed", Type: EventStragglerRemoved, SecretID: "AAAAAAAAAAAAAAAAAAAAAA", OccurredAt: exampleTime},
+		{ID: "evt_example_reported", Type: EventReported, SecretID: "AAAAAAAAAAAAAAAAAAAAAA", OccurredAt: exampleTime},
+	}
+}
+
+// BuildDocument assembles the schema document with examples signed by TestKey
+func BuildDocument() (*Document, error) {
+	doc := &Document{
+		SignatureHeader: SignatureHeader,
+		SchemaHeader:    SchemaHeader,
+		Tolerance:       int(DefaultTolerance.Seconds()),
+		TestKey:         TestKey,
+		Versions:        make(map[string]VersionDocument),
+	}
+
+	for _, schema := range Versions() {
+		raw, err := JSONSchema(schema)
+		if err != nil {
+			return nil, err
+		}
+
+		version := VersionDocument{JSONSchema: raw}
+		for _, e := range ExampleEvents() {
+			body, err := Encode(schema, e)
+			if err != nil {
+				return nil, err
+			}
+
+			version.Examples = append(version.Examples, Example{
+				Payload:   body,
+				Signature: Sign(body, []byte(TestKey), exampleTime),
+			})
+		}
+
+		doc.Versions[schema] = version
+	}
+
+	return doc, nil
+}
+
+func versionFile(schema string) string {
+	switch schema {
+	case SchemaV1:
+		return "v1.json"
+	default:
+		return schema + ".json"
+	}
+}
@@ -0,0 +1,132 @@
+// Package webhook defines the versioned webhook payload contract and the
+// signature scheme used to authenticate deliveries. It is importable by
+// consumers so they can verify deliveries with the same code the server uses.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SignatureHeader is the HTTP header carrying the delivery signature
+	SignatureHeader = "X-OTS-Signature"
+	// SchemaHeader is the HTTP header carrying the payload schema version
+	SchemaHeader = "X-OTS-Schema"
+	// DefaultTolerance is the maximum accepted clock skew for the timestamp claim
+	DefaultTolerance = 5 * time.Minute
+)
+
+var (
+	// ErrInvalidHeader indicates the signature header could not be parsed
+	ErrInvalidHeader = errors.New("invalid signature header")
+	// ErrNoSignature indicates the header carries no signature for a supported scheme
+	ErrNoSignature = errors.New("no v1 signature in header")
+	// ErrSignatureMismatch indicates none of the signatures match the body
+	ErrSignatureMismatch = errors.New("signature mismatch")
+	// ErrTimestampOutOfRange indicates the timestamp claim is outside the tolerance
+	ErrTimestampOutOfRange = errors.New("timestamp outside tolerance")
+)
+
+// Sign computes the signature header value for body at timestamp t.
+// The signed message is "<unix seconds>.<body>" and the MAC is HMAC-SHA256.
+func Sign(body, key []byte, t time.Time) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + computeMAC(body, key, ts)
+}
+
+// Verify checks header against body using key and the default tolerance.
+func Verify(body []byte, header string, key []byte) error {
+	return VerifyAt(body, header, key, time.Now(), DefaultTolerance)
+}
+
+// VerifyAt checks header against body as of now, rejecting timestamp claims
+// further than tolerance away in either direction. A tolerance <= 0 disables
+// the timestamp check.
+func VerifyAt(body []byte, header string, key []byte, now time.Time, tolerance time.Duration) error {
+	ts, sigs, err := parseHeader(header)
+	if err != nil {
+		return err
+	}
+
+	if len(sigs) == 0 {
+		return ErrNoSignature
+	}
+
+	if tolerance > 0 {
+		unix, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: bad timestamp", ErrInvalidHeader)
+		}
+
+		skew := now.Sub(time.Unix(unix, 0))
+		if skew > tolerance || skew < -tolerance {
+			return fmt.Errorf("%w: skew %v exceeds %v", ErrTimestampOutOfRange, skew.Truncate(time.Second), tolerance)
+		}
+	}
+
+	expected := computeMAC(body, key, ts)
+	for _, sig := range sigs {
+		if hmac.Equal([]byte(sig), []byte(expected)) {
+			return nil
+		}
+	}
+
+	return ErrSignatureMismatch
+}
+
+// Decode parses a verified delivery body and checks its schema tag.
+func Decode(body []byte) (*PayloadV1, error) {
+	var p PayloadV1
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, fmt.Errorf("decode payload: %w", err)
+	}
+
+	if p.Schema != SchemaV1 {
+		return nil, fmt.Errorf("unsupported schema %q", p.Schema)
+	}
+
+	return &p, nil
+}
+
+func computeMAC(body, key []byte, ts string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// parseHeader splits "t=<ts>,v1=<sig>[,v1=<sig>...]". Multiple v1 entries are
+// allowed so senders can sign with an old and new key during rotation.
+func parseHeader(header string) (string, []string, error) {
+	var ts string
+	var sigs []string
+
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return "", nil, ErrInvalidHeader
+		}
+
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sigs = append(sigs, value)
+		}
+	}
+
+	if ts == "" {
+		return "", nil, fmt.Errorf("%w: missing timestamp", ErrInvalidHeader)
+	}
+
+	return ts, sigs, nil
+}
@@ -0,0 +1,180 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite golden fixtures")
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"schema":"ots.webhook.v1"}`)
+	key := []byte("secret-key")
+	now := time.Unix(1700000000, 0)
+	header := Sign(body, key, now)
+
+	tests := []struct {
+		name    string
+		body    []byte
+		header  string
+		key     []byte
+		now     time.Time
+		wantErr error
+	}{
+		{name: "valid", body: body, header: header, key: key, now: now},
+		{name: "valid within skew", body: body, header: header, key: key, now: now.Add(4 * time.Minute)},
+		{name: "valid within negative skew", body: body, header: header, key: key, now: now.Add(-4 * time.Minute)},
+		{name: "too old", body: body, header: header, key: key, now: now.Add(6 * time.Minute), wantErr: ErrTimestampOutOfRange},
+		{name: "from the future", body: body, header: header, key: key, now: now.Add(-6 * time.Minute), wantErr: ErrTimestampOutOfRange},
+		{name: "tampered body", body: []byte(`{"schema":"x"}`), header: header, key: key, now: now, wantErr: ErrSignatureMismatch},
+		{name: "wrong key", body: body, header: header, key: []byte("other"), now: now, wantErr: ErrSignatureMismatch},
+		{name: "rotated key second signature", body: body, header: header + ",v1=" + computeMAC(body, []byte("new"), "1700000000"), key: []byte("new"), now: now},
+		{name: "missing timestamp", body: body, header: "v1=abc", key: key, now: now, wantErr: ErrInvalidHeader},
+		{name: "no signature", body: body, header: "t=1700000000", key: key, now: now, wantErr: ErrNoSignature},
+		{name: "garbage", body: body, header: "garbage", key: key, now: now, wantErr: ErrInvalidHeader},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyAt(tt.body, tt.header, tt.key, tt.now, DefaultTolerance)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyAt() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGoldenFixtures(t *testing.T) {
+	doc, err := BuildDocument()
+	if err != nil {
+		t.Fatalf("BuildDocument() error: %v", err)
+	}
+
+	for _, schema := range Versions() {
+		for i, example := range doc.Versions[schema].Examples {
+			name := filepath.Join("testdata", versionFile(schema)[:2]+"_"+ExampleEvents()[i].Type+".json")
+
+			var buf bytes.Buffer
+			if err := json.Indent(&buf, example.Payload, "", "  "); err != nil {
+				t.Fatalf("indent: %v", err)
+			}
+			buf.WriteString("\n")
+
+			if *update {
+				if err := os.WriteFile(name, buf.Bytes(), 0o644); err != nil {
+					t.Fatalf("write %s: %v", name, err)
+				}
+				continue
+			}
+
+			golden, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatalf("read %s: %v (run with -update to create)", name, err)
+			}
+
+			if !bytes.Equal(golden, buf.Bytes()) {
+				t.Errorf("%s drifted:\n got: %s\nwant: %s", name, buf.String(), golden)
+			}
+
+			if err := VerifyAt(example.Payload, example.Signature, []byte(TestKey), exampleTime, DefaultTolerance); err != nil {
+				t.Errorf("example %s does not verify with the test key: %v", name, err)
+			}
+		}
+	}
+}
+
+// TestSchemaV1Frozen guards the evolution rules: the required field set of a
+// published version never changes, and every struct field is documented.
+func TestSchemaV1Frozen(t *testing.T) {
+	frozen := []string{"event", "id", "occurred_at", "schema", "secret_id"}
+
+	raw, err := JSONSchema(SchemaV1)
+	if err != nil {
+		t.Fatalf("JSONSchema() error: %v", err)
+	}
+
+	var doc struct {
+		Required             []string                   `json:"required"`
+		AdditionalProperties bool                       `json:"additionalProperties"`
+		Properties           map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatalf("parse schema: %v", err)
+	}
+
+	sort.Strings(doc.Required)
+	if !reflect.DeepEqual(doc.Required, frozen) {
+		t.Fatalf("v1 required fields = %v, want %v; bump the schema version instead", doc.Required, frozen)
+	}
+
+	if !doc.AdditionalProperties {
+		t.Fatal("v1 must allow additional properties so additive changes stay compatible")
+	}
+
+	typ := reflect.TypeOf(PayloadV1{})
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if _, ok := doc.Properties[name]; !ok {
+			t.Errorf("field %s (%q) missing from JSON schema", field.Name, name)
+		}
+
+		isRequired := false
+		for _, req := range frozen {
+			if req == name {
+				isRequired = true
+			}
+		}
+		if !isRequired && !strings.Contains(opts, "omitempty") {
+			t.Errorf("field %q was added to v1 without omitempty", name)
+		}
+	}
+}
+
+func TestDecodeIgnoresAdditiveFields(t *testing.T) {
+	body := []byte(`{"schema":"ots.webhook.v1","id":"evt_1","event":"secret.burned","secret_id":"abc","occurred_at":"2025-01-01T00:00:00Z","future_field":42}`)
+
+	p, err := Decode(body)
+	if err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+
+	if p.Event != EventBurned {
+		t.Errorf("Decode() event = %q, want %q", p.Event, EventBurned)
+	}
+
+	if _, err := Decode([]byte(`{"schema":"ots.webhook.v9"}`)); err == nil {
+		t.Error("Decode() accepted an unknown schema version")
+	}
+}
+
+func TestTargetDualEmission(t *testing.T) {
+	e := ExampleEvents()[0]
+
+	deliveries, err := Target{Key: []byte("k")}.Deliveries(e, exampleTime)
+	if err != nil {
+		t.Fatalf("Deliveries() error: %v", err)
+	}
+
+	if len(deliveries) != 1 || deliveries[0].Schema != SchemaV1 {
+		t.Fatalf("default target deliveries = %+v, want one v1 delivery", deliveries)
+	}
+
+	deliveries, err = Target{Key: []byte("k"), Schemas: []string{SchemaV1, SchemaV1}}.Deliveries(e, exampleTime)
+	if err != nil || len(deliveries) != 2 {
+		t.Fatalf("dual target deliveries = %d (err %v), want 2", len(deliveries), err)
+	}
+
+	if _, err := (Target{Schemas: []string{"ots.webhook.v0"}}).Deliveries(e, exampleTime); err == nil {
+		t.Fatal("Deliveries() accepted an unknown schema version")
+	}
+}
@@ -162,9 +162,16 @@ test.describe('API Endpoints', () => {
       },
     });
 
-    const { id } = await createResponse.json();
+    const { id, management_token } = await createResponse.json();
+
+    const unauthorizedResponse = await request.delete(`${API_URL}/secrets/${id}`);
+    expect(unauthorizedResponse.status()).toBe(401);
 
-    const burnResponse = await request.delete(`${API_URL}/secrets/${id}`);
+    const burnResponse = await request.delete(`${API_URL}/secrets/${id}`, {
+      headers: {
+        'X-Management-Token': management_token,
+      },
+    });
     expect(burnResponse.status()).toBe(204);
 
     const getResponse = await request.get(`${API_URL}/secrets/${id}`);
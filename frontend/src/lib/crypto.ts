@@ -179,6 +179,30 @@ export async function decryptWithPassphrase(
   return await decrypt(data.ciphertext, data.iv, key);
 }
 
+/**
+ * Split a nonce-prefixed ciphertext (iv_embedded) into the separate
+ * ciphertext and iv that decrypt expects. Secrets stored with a separate iv
+ * are returned unchanged.
+ */
+export function splitEmbeddedIV(data: {
+  ciphertext: string;
+  iv?: string;
+  iv_embedded?: boolean;
+}): { ciphertext: string; iv: string } {
+  if (!data.iv_embedded) {
+    return { ciphertext: data.ciphertext, iv: data.iv ?? '' };
+  }
+
+  const blob = new Uint8Array(base64ToArrayBuffer(data.ciphertext));
+  if (blob.length <= IV_LENGTH) {
+    throw new Error('Ciphertext too short for an embedded IV');
+  }
+  return {
+    ciphertext: arrayBufferToBase64(blob.subarray(IV_LENGTH)),
+    iv: arrayBufferToBase64(blob.subarray(0, IV_LENGTH)),
+  };
+}
+
 /**
  * Generate a shareable URL with the key in the fragment
  */
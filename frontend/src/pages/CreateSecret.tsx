@@ -53,9 +53,18 @@ export default function CreateSecret() {
         key = await crypto.exportKey(cryptoKey);
       }
 
+      // The nonce pairs with an HttpOnly cookie so a third-party page cannot
+      // submit creates through this browser
+      const nonceResponse = await fetch(`${API_URL}/secrets/nonce`, { credentials: 'same-origin' });
+      if (!nonceResponse.ok) {
+        throw new Error('Failed to store secret');
+      }
+      const { nonce } = await nonceResponse.json();
+
       const response = await fetch(`${API_URL}/secrets`, {
         method: 'POST',
-        headers: { 'Content-Type': 'application/json' },
+        credentials: 'same-origin',
+        headers: { 'Content-Type': 'application/json', 'X-Create-Nonce': nonce },
         body: JSON.stringify({
           ciphertext: encryptedData.ciphertext,
           iv: encryptedData.iv,
@@ -33,16 +33,31 @@ export default function ViewSecret() {
     setError(null);
 
     try {
-      const response = await fetch(`${API_URL}/secrets/${id}`);
+      // Servers with REQUIRE_CLIENT_HEADER only hand secrets to interactive clients
+      const response = await fetch(`${API_URL}/secrets/${id}`, {
+        headers: { 'X-OTS-Client': 'interactive' },
+      });
 
       if (!response.ok) {
+        // Links created by another regional deployment are only readable there
+        if (response.status === 421) {
+          const body = await response.json().catch(() => null);
+          const baseUrl: string | undefined = body?.region?.base_url;
+          if (baseUrl) {
+            window.location.replace(`${baseUrl}${window.location.pathname}${window.location.hash}`);
+            return;
+          }
+          throw new Error(`This secret was created in another region (${body?.region?.code ?? 'unknown'}); open the link there`);
+        }
         if (response.status === 404) {
           throw new Error('This secret does not exist or has already been viewed');
         }
         throw new Error('Failed to retrieve secret');
       }
 
-      const data = await response.json();
+      const body = await response.json();
+      // Secrets from SDKs that prefix the nonce arrive with iv_embedded set
+      const data = { ...body, ...crypto.splitEmbeddedIV(body) };
       const keyFromUrl = crypto.parseKeyFromUrl();
 
       if (keyFromUrl) {